@@ -0,0 +1,187 @@
+package api
+
+import (
+	"Chat-Server/mail"
+	"Chat-Server/repository"
+	"Chat-Server/token"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// errInvalidVerificationToken is returned for the verification tokens which are forged, expired or of an
+	// address the user no longer has
+	errInvalidVerificationToken = errors.New("invalid or expired verification link")
+
+	// errEmailNotVerified is returned to the users joining the hub without a verified email address while
+	// the configurations require one
+	errEmailNotVerified = errors.New("verify your email address before joining the hub")
+)
+
+// emailVerificationDomain separates the signatures of the verification tokens from any other use of the
+// token symmetric key
+const emailVerificationDomain = "email-verification\n"
+
+// getEmail is the handler for the "/api/email" GET route, returns the email address of the user and whether
+// it is verified
+func (s *server) getEmail(context *gin.Context) {
+	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+	username := accessTokenPayload.Username
+
+	user, err := s.repository.GetUser(repository.WithUser(context.Request.Context(), username), username)
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, EmailResponse{Email: user.Email, Verified: user.EmailVerified})
+}
+
+// setEmail is the handler for the "/api/email" POST route, replaces the email address of the user by an
+// unverified one and sends a verification link to it. an empty email removes the address of the user
+func (s *server) setEmail(context *gin.Context) {
+	if _, ok := context.Get(authorizationBotKey); ok {
+		context.JSON(http.StatusForbidden, errorResponse(fmt.Errorf("bots do not have email addresses")))
+		return
+	}
+
+	var req SetEmailRequest
+	if err := context.ShouldBindJSON(&req); err != nil {
+		context.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid email")))
+		return
+	}
+
+	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+	username := accessTokenPayload.Username
+	email := normalizeEmail(req.Email)
+
+	if err := s.repository.SetEmail(repository.WithUser(context.Request.Context(), username), username, email); err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	if email == "" {
+		context.Status(http.StatusNoContent)
+		return
+	}
+
+	s.sendEmailVerification(username, email)
+	context.Status(http.StatusAccepted)
+}
+
+// verifyEmail is the handler for the "/api/email/verify" route, marks the address of a verification token as
+// verified. the token is signed, so it does not need the user to be logged in on the device opening the link
+func (s *server) verifyEmail(context *gin.Context) {
+	var req VerifyEmailRequest
+	if err := context.ShouldBindJSON(&req); err != nil {
+		bindErrorResponse(context, err)
+		return
+	}
+
+	username, email, err := parseEmailVerificationToken(s.configs.TokenSymmetricKey(), req.Token, time.Now())
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponse(errInvalidVerificationToken))
+		return
+	}
+
+	// the address is only verified while the user still has it
+	err = s.repository.VerifyEmail(repository.WithUser(context.Request.Context(), username), username, email)
+	if errors.Is(err, repository.ErrNotFound) {
+		context.JSON(http.StatusBadRequest, errorResponse(errInvalidVerificationToken))
+		return
+	}
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	context.Status(http.StatusNoContent)
+}
+
+// sendEmailVerification queues a link verifying the input address of the user to be sent to it, the errors are
+// logged since the user asks for a new link by setting the address again
+func (s *server) sendEmailVerification(username, email string) {
+	expiresAt := time.Now().Add(s.configs.EmailVerificationDuration())
+	verificationToken := newEmailVerificationToken(s.configs.TokenSymmetricKey(), username, email, expiresAt)
+
+	link, err := tokenLink(s.configs.EmailVerificationURL(), verificationToken)
+	if err != nil {
+		log.Printf("error: invalid email verification url: %v", err)
+		return
+	}
+
+	err = s.mails.Enqueue(mail.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Text: fmt.Sprintf("Open the link below to verify the email address of %s, it works until %s:\n\n%s\n\n"+
+			"If you did not add this address, ignore this email.",
+			username, expiresAt.UTC().Format(time.RFC1123), link),
+	})
+	if err != nil {
+		log.Printf("error: cannot queue email verification of %s: %v", username, err)
+	}
+}
+
+// normalizeEmail returns the input email address trimmed and in lower case, so the same address is stored once
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// newEmailVerificationToken returns a token verifying the input address of the user until the input time,
+// signed with the input key. the claims are not secret, only their signature is checked
+func newEmailVerificationToken(key, username, email string, expiresAt time.Time) string {
+	claims := []byte(username + "\n" + email + "\n" + strconv.FormatInt(expiresAt.Unix(), 10))
+
+	return base64.RawURLEncoding.EncodeToString(claims) + "." +
+		base64.RawURLEncoding.EncodeToString(signEmailVerification(key, claims))
+}
+
+// parseEmailVerificationToken returns the username and the address of the input token, and an error if its
+// signature is not valid or it expired before the input time
+func parseEmailVerificationToken(key, verificationToken string, now time.Time) (string, string, error) {
+	encodedClaims, encodedSignature, ok := strings.Cut(verificationToken, ".")
+	if !ok {
+		return "", "", errInvalidVerificationToken
+	}
+	claims, err := base64.RawURLEncoding.DecodeString(encodedClaims)
+	if err != nil {
+		return "", "", errInvalidVerificationToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return "", "", errInvalidVerificationToken
+	}
+	if !hmac.Equal(signature, signEmailVerification(key, claims)) {
+		return "", "", errInvalidVerificationToken
+	}
+
+	fields := bytes.Split(claims, []byte("\n"))
+	if len(fields) != 3 {
+		return "", "", errInvalidVerificationToken
+	}
+	expiresAt, err := strconv.ParseInt(string(fields[2]), 10, 64)
+	if err != nil || !now.Before(time.Unix(expiresAt, 0)) {
+		return "", "", errInvalidVerificationToken
+	}
+
+	return string(fields[0]), string(fields[1]), nil
+}
+
+// signEmailVerification returns the HMAC-SHA256 signature of the input claims of a verification token
+func signEmailVerification(key string, claims []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(emailVerificationDomain))
+	mac.Write(claims)
+
+	return mac.Sum(nil)
+}
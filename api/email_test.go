@@ -0,0 +1,173 @@
+package api
+
+import (
+	"Chat-Server/repository"
+	"Chat-Server/util"
+	"context"
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// verificationToken returns the token of the verification link of the input email
+func verificationToken(t *testing.T, text string) string {
+	link, err := url.Parse(regexp.MustCompile(`https?://\S+`).FindString(text))
+	require.NoError(t, err)
+	require.Equal(t, testConfigs.EmailVerificationURL(), link.Scheme+"://"+link.Host+link.Path)
+	verificationToken := link.Query().Get("token")
+	require.NotEmpty(t, verificationToken)
+
+	return verificationToken
+}
+
+// TestEmailVerification tests adding an email address to an account and verifying it with the signed link
+// sent to it
+func TestEmailVerification(t *testing.T) {
+	server, _, _, serve := passwordTestServer(t)
+	mailer := recordMails(t, server)
+	username := util.RandomUsername()
+
+	getEmail := func(cookies ...*http.Cookie) EmailResponse {
+		req, err := http.NewRequest(http.MethodGet, "/api/email", nil)
+		require.NoError(t, err)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, req)
+		require.Equal(t, http.StatusOK, recorder.Code)
+
+		var response EmailResponse
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+		return response
+	}
+
+	recorder := serve("/api/signup", SignupRequest{Username: username, Password: util.RandomPassword(), Email: "not an email"})
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+	require.JSONEq(t, `{"error":"invalid Email"}`, recorder.Body.String())
+
+	// the address is stored in lower case and a verification link is sent to it on signup
+	recorder = serve("/api/signup", SignupRequest{Username: username, Password: util.RandomPassword(), Email: "Alice@Example.com"})
+	require.Equal(t, http.StatusOK, recorder.Code)
+	accessToken := responseCookie(t, recorder, "accessToken")
+	require.Equal(t, EmailResponse{Email: "alice@example.com"}, getEmail(accessToken))
+
+	// the link is sent in the background
+	sent := mailer.waitSent(t, 1)
+	require.Len(t, sent, 1)
+	require.Equal(t, "alice@example.com", sent[0].To)
+	firstToken := verificationToken(t, sent[0].Text)
+
+	// a forged token is rejected
+	claims, _, _ := strings.Cut(firstToken, ".")
+	forged := newEmailVerificationToken("forged key of 32 characters long", username, "alice@example.com", time.Now().Add(time.Hour))
+	_, forgedSignature, _ := strings.Cut(forged, ".")
+	recorder = serve("/api/email/verify", VerifyEmailRequest{Token: claims + "." + forgedSignature})
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+	require.JSONEq(t, `{"error":"invalid or expired verification link"}`, recorder.Body.String())
+
+	recorder = serve("/api/email/verify", VerifyEmailRequest{})
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+	require.JSONEq(t, `{"error":"invalid Token"}`, recorder.Body.String())
+
+	// the link works without being logged in
+	require.Equal(t, http.StatusNoContent, serve("/api/email/verify", VerifyEmailRequest{Token: firstToken}).Code)
+	require.Equal(t, EmailResponse{Email: "alice@example.com", Verified: true}, getEmail(accessToken))
+
+	// a new address is not verified until its own link is opened, the links of the old address stop working
+	require.Equal(t, http.StatusBadRequest, serve("/api/email", SetEmailRequest{Email: "alice@"}, accessToken).Code)
+	require.Equal(t, http.StatusAccepted, serve("/api/email", SetEmailRequest{Email: "alice@chat.example.com"}, accessToken).Code)
+	require.Equal(t, EmailResponse{Email: "alice@chat.example.com"}, getEmail(accessToken))
+	require.Equal(t, http.StatusBadRequest, serve("/api/email/verify", VerifyEmailRequest{Token: firstToken}).Code)
+
+	sent = mailer.waitSent(t, 2)
+	require.Len(t, sent, 2)
+	require.Equal(t, "alice@chat.example.com", sent[1].To)
+	require.Equal(t, http.StatusNoContent, serve("/api/email/verify", VerifyEmailRequest{Token: verificationToken(t, sent[1].Text)}).Code)
+	require.Equal(t, EmailResponse{Email: "alice@chat.example.com", Verified: true}, getEmail(accessToken))
+
+	// an expired link is rejected
+	expired := newEmailVerificationToken(testConfigs.TokenSymmetricKey(), username, "alice@chat.example.com", time.Now().Add(-time.Minute))
+	require.Equal(t, http.StatusBadRequest, serve("/api/email/verify", VerifyEmailRequest{Token: expired}).Code)
+
+	// the address is removed with an empty email, without sending anything
+	require.Equal(t, http.StatusNoContent, serve("/api/email", SetEmailRequest{}, accessToken).Code)
+	require.Equal(t, EmailResponse{}, getEmail(accessToken))
+	require.Len(t, mailer.sent(), 2)
+	require.Equal(t, http.StatusUnauthorized, serve("/api/email", SetEmailRequest{Email: "alice@example.com"}).Code)
+}
+
+// TestParseEmailVerificationToken tests checking the signature and the expiry of the verification tokens
+func TestParseEmailVerificationToken(t *testing.T) {
+	key := testConfigs.TokenSymmetricKey()
+	now := time.Now()
+	valid := newEmailVerificationToken(key, "alice", "alice@example.com", now.Add(time.Hour))
+
+	username, email, err := parseEmailVerificationToken(key, valid, now)
+	require.NoError(t, err)
+	require.Equal(t, "alice", username)
+	require.Equal(t, "alice@example.com", email)
+
+	testCases := []struct {
+		name  string
+		token string
+	}{
+		{name: "Expired", token: newEmailVerificationToken(key, "alice", "alice@example.com", now)},
+		{name: "OtherKey", token: newEmailVerificationToken("other key of 32 characters long!", "alice", "alice@example.com", now.Add(time.Hour))},
+		{name: "NoSignature", token: strings.Split(valid, ".")[0]},
+		{name: "InvalidEncoding", token: "!!!." + strings.Split(valid, ".")[1]},
+		{name: "Empty", token: ""},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := parseEmailVerificationToken(key, tc.token, now)
+			require.ErrorIs(t, err, errInvalidVerificationToken)
+		})
+	}
+}
+
+// TestRequireVerifiedEmail tests keeping the users without a verified email address out of the hub
+func TestRequireVerifiedEmail(t *testing.T) {
+	server, user, _, _ := passwordTestServer(t)
+	server.requireVerifiedEmail = true
+
+	writer := repository.NewMessageWriter(server.repository, repository.MessageWriterConfig{QueueSize: 10, BatchSize: 1})
+	defer writer.Close(context.Background())
+	hubContext, stopHub := context.WithCancel(context.Background())
+	defer stopHub()
+	go server.chatHub.RunChatHub(hubContext, server.repository, writer)
+
+	httpServer := httptest.NewServer(server.router)
+	defer httpServer.Close()
+
+	dial := func() (*websocket.Conn, *http.Response, error) {
+		accessToken, _ := createToken(t, user.Username, time.Minute)
+		header := http.Header{"Cookie": []string{authorizationCookieName + "=" + accessToken}}
+		return websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http")+"/api/chat", header)
+	}
+
+	_, response, err := dial()
+	require.ErrorIs(t, err, websocket.ErrBadHandshake)
+	require.Equal(t, http.StatusForbidden, response.StatusCode)
+
+	email := user.Username + "@example.com"
+	require.NoError(t, server.repository.SetEmail(context.Background(), user.Username, email))
+	_, response, err = dial()
+	require.ErrorIs(t, err, websocket.ErrBadHandshake)
+	require.Equal(t, http.StatusForbidden, response.StatusCode)
+
+	require.NoError(t, server.repository.VerifyEmail(context.Background(), user.Username, email))
+	conn, _, err := dial()
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+}
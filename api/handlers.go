@@ -2,20 +2,46 @@ package api
 
 import (
 	"Chat-Server/api/ws"
+	"Chat-Server/media"
 	"Chat-Server/repository"
+	"Chat-Server/storage"
 	"Chat-Server/token"
 	"Chat-Server/util"
+	"Chat-Server/webhook"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
-	"github.com/jackc/pgx/v5/pgconn"
-	"gorm.io/gorm"
+	"github.com/google/uuid"
+	"html"
+	"io"
+	"log"
+	"mime"
 	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
 )
 
 var InternalServerError = fmt.Errorf("something went wrong please try later")
 
+var ServiceUnavailableError = fmt.Errorf("service is temporarily unavailable please try later")
+
+// errReservedUsername is returned for the bots and incoming hooks named after an administrator, who may not
+// have signed up yet
+var errReservedUsername = fmt.Errorf("username is reserved")
+
+// refreshTokenPurpose is the purpose of the refresh tokens, so a refresh token is never taken for an access token
+const refreshTokenPurpose = "refresh"
+
 // signup route handler
 func (s *server) signup(context *gin.Context) {
 	var req SignupRequest
@@ -39,71 +65,29 @@ func (s *server) signup(context *gin.Context) {
 		context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
 	}
 
-	newUser, err := s.repository.AddUser(&repository.User{
+	newUser, err := s.repository.AddUser(repository.WithUser(context.Request.Context(), req.Username), &repository.User{
 		Username: req.Username,
 		Password: hashedPassword,
+		Email:    normalizeEmail(req.Email),
 	})
 	if err != nil {
-		var pgError *pgconn.PgError
-		if errors.As(err, &pgError) {
-			switch pgError.ConstraintName {
-			case "users_pkey":
-				err = fmt.Errorf("username already exists")
-				context.JSON(http.StatusForbidden, errorResponse(err))
-				return
-			}
+		switch {
+		case errors.Is(err, repository.ErrUserExists):
+			err = fmt.Errorf("username already exists")
+			context.JSON(http.StatusForbidden, errorResponse(err))
+		case errors.Is(err, repository.ErrUnavailable):
+			context.JSON(http.StatusServiceUnavailable, errorResponse(ServiceUnavailableError))
+		default:
+			context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
 		}
-		context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
-		return
-	}
-
-	accessToken, accessTokenPayload, err := s.tokenMaker.CreateToken(
-		newUser.Username,
-		s.configs.AccessTokenDuration(),
-	)
-	if err != nil {
-		context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
 		return
 	}
-
-	refreshToken, refreshTokenPayload, err := s.tokenMaker.CreateToken(
-		newUser.Username,
-		s.configs.RefreshTokenDuration())
-	if err != nil {
-		context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
-		return
+	s.emit(webhook.EventUserSignedUp, webhook.UserData{Username: newUser.Username})
+	if newUser.Email != "" {
+		s.sendEmailVerification(newUser.Username, newUser.Email)
 	}
 
-	// setting refresh token and access token in the cookies
-	http.SetCookie(context.Writer, &http.Cookie{
-		Name:     "refreshToken",
-		Value:    refreshToken,
-		Expires:  refreshTokenPayload.ExpiredAt,
-		Path:     s.configs.RefreshTokenCookiePath(),
-		HttpOnly: true,
-		Secure:   s.configs.IsProductionEnv(),
-		SameSite: http.SameSiteStrictMode,
-	})
-	http.SetCookie(context.Writer, &http.Cookie{
-		Name:     "accessToken",
-		Value:    accessToken,
-		Expires:  accessTokenPayload.ExpiredAt,
-		Path:     s.configs.AccessTokenCookiePath(),
-		HttpOnly: true,
-		Secure:   s.configs.IsProductionEnv(),
-		SameSite: http.SameSiteStrictMode,
-	})
-	http.SetCookie(context.Writer, &http.Cookie{
-		Name:     "username",
-		Value:    newUser.Username,
-		Expires:  accessTokenPayload.ExpiredAt,
-		Path:     s.configs.UsernameCookiePath(),
-		HttpOnly: false,
-		Secure:   s.configs.IsProductionEnv(),
-		SameSite: http.SameSiteStrictMode,
-	})
-
-	context.Status(http.StatusOK)
+	s.setAuthCookies(context, newUser.Username)
 }
 
 // login route handler
@@ -123,25 +107,74 @@ func (s *server) login(context *gin.Context) {
 		return
 	}
 
-	user, err := s.repository.GetUser(req.Username)
+	ctx := repository.WithUser(context.Request.Context(), req.Username)
+	address := clientAddress(context)
+
+	// the usernames and the addresses with too many failed logins wait before their next login
+	retryAfter, err := s.loginGuard.check(ctx, req.Username, address)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			err = fmt.Errorf("this username doesn't have an account")
-			context.JSON(http.StatusNotFound, errorResponse(err))
-			return
+		repositoryErrorResponse(context, err)
+		return
+	}
+	if retryAfter > 0 {
+		abortTooManyRequests(context, retryAfter, errLoginThrottled)
+		return
+	}
+
+	// the password is checked against the primary, so a changed password stops working on every instance at once
+	user, err := s.repository.GetUser(repository.WithPrimary(ctx), req.Username)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	// the password of an unknown username is checked too, so the response does not tell it apart by its time
+	passwordHash := dummyPasswordHash()
+	if user != nil {
+		passwordHash = user.Password
+	}
+	if err := util.CheckPassword(req.Password, passwordHash); err != nil || user == nil {
+		if err := s.loginGuard.fail(ctx, req.Username, address); err != nil {
+			log.Printf("error: recording the failed login of %q: %v", req.Username, err)
 		}
-		context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
+		context.JSON(http.StatusUnauthorized, errorResponse(errLoginFailed))
 		return
 	}
 
-	if err := util.CheckPassword(req.Password, user.Password); err != nil {
-		err = fmt.Errorf("wrong passowrd")
-		context.JSON(http.StatusUnauthorized, errorResponse(err))
+	// a user with a TOTP authenticator sends its code with the token of the second step, the failed logins
+	// of the username are kept until then
+	twoFactor, err := s.repository.GetTwoFactor(ctx, user.Username)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		repositoryErrorResponse(context, err)
+		return
+	}
+	if twoFactor != nil && twoFactor.Enabled {
+		twoFactorToken, payload, err := s.tokenMaker.CreatePurposeToken(
+			twoFactorTokenPurpose,
+			user.Username,
+			s.configs.TwoFactorTokenDuration(),
+		)
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
+			return
+		}
+
+		context.JSON(http.StatusOK, TwoFactorChallengeResponse{TwoFactorToken: twoFactorToken, ExpiresAt: payload.ExpiredAt})
 		return
 	}
 
+	if err := s.loginGuard.succeed(ctx, req.Username); err != nil {
+		log.Printf("error: clearing the failed logins of %q: %v", req.Username, err)
+	}
+
+	s.setAuthCookies(context, user.Username)
+}
+
+// setAuthCookies starts a session of the logged in user and sets its refresh token, access token and
+// username cookies
+func (s *server) setAuthCookies(context *gin.Context, username string) {
 	accessToken, accessTokenPayload, err := s.tokenMaker.CreateToken(
-		req.Username,
+		username,
 		s.configs.AccessTokenDuration(),
 	)
 	if err != nil {
@@ -149,14 +182,30 @@ func (s *server) login(context *gin.Context) {
 		return
 	}
 
-	refreshToken, refreshTokenPayload, err := s.tokenMaker.CreateToken(
-		req.Username,
-		s.configs.RefreshTokenDuration())
+	refreshToken, refreshTokenPayload, err := s.tokenMaker.CreatePurposeToken(
+		refreshTokenPurpose,
+		username,
+		s.configs.RefreshTokenDuration(),
+	)
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
 		return
 	}
 
+	// the refresh token is only valid as long as its session is not blocked, e.g. by a password change
+	_, err = s.repository.AddSession(repository.WithUser(context.Request.Context(), username), &repository.Session{
+		Username:     username,
+		RefreshToken: hashSecretToken(refreshToken),
+		UserAgent:    context.Request.UserAgent(),
+		ClientIP:     context.ClientIP(),
+		CreatedAt:    refreshTokenPayload.IssuedAt,
+		ExpiresAt:    refreshTokenPayload.ExpiredAt,
+	})
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
 	// setting refresh token and access token in the cookies
 	http.SetCookie(context.Writer, &http.Cookie{
 		Name:     "refreshToken",
@@ -178,7 +227,7 @@ func (s *server) login(context *gin.Context) {
 	})
 	http.SetCookie(context.Writer, &http.Cookie{
 		Name:     "username",
-		Value:    user.Username,
+		Value:    username,
 		Expires:  accessTokenPayload.ExpiredAt,
 		Path:     s.configs.UsernameCookiePath(),
 		HttpOnly: false,
@@ -196,6 +245,18 @@ func errorResponse(err error) gin.H {
 	}
 }
 
+// bindErrorResponse responds with 400 and the invalid field of a request which cannot be bound, without
+// the text of the validator
+func bindErrorResponse(context *gin.Context, err error) {
+	var valErrs validator.ValidationErrors
+	if errors.As(err, &valErrs) && len(valErrs) > 0 {
+		context.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid %s", valErrs[0].Field())))
+		return
+	}
+
+	context.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid credentials")))
+}
+
 // refreshToken reads refresh token from the cookies, and if valid creates another access token for the client
 func (s *server) refreshToken(context *gin.Context) {
 	refreshToken, err := context.Cookie("refreshToken")
@@ -205,7 +266,7 @@ func (s *server) refreshToken(context *gin.Context) {
 		return
 	}
 
-	payload, err := s.tokenMaker.VerifyToken(refreshToken)
+	payload, err := s.tokenMaker.VerifyPurposeToken(refreshToken, refreshTokenPurpose)
 	if err != nil {
 		if errors.Is(err, token.ErrExpiredToken) {
 			err = fmt.Errorf("expired refresh token")
@@ -217,6 +278,22 @@ func (s *server) refreshToken(context *gin.Context) {
 		return
 	}
 
+	// the session is read from the primary, so a session started or blocked a moment ago is seen as it is
+	ctx := repository.WithPrimary(repository.WithUser(context.Request.Context(), payload.Username))
+	session, err := s.repository.GetSessionByRefreshToken(ctx, hashSecretToken(refreshToken))
+	if errors.Is(err, repository.ErrNotFound) {
+		context.JSON(http.StatusUnauthorized, errorResponse(fmt.Errorf("invalid refresh token")))
+		return
+	}
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+	if session.IsBlocked || session.Username != payload.Username {
+		context.JSON(http.StatusUnauthorized, errorResponse(fmt.Errorf("revoked refresh token")))
+		return
+	}
+
 	newAccessToken, newAccessTokenPayload, err := s.tokenMaker.CreateToken(
 		payload.Username,
 		s.configs.AccessTokenDuration(),
@@ -248,10 +325,36 @@ func (s *server) refreshToken(context *gin.Context) {
 	context.Status(http.StatusOK)
 }
 
+// ready is the handler for the "/api/ready" route, a readiness probe reporting whether the server
+// can serve requests, which needs a reachable repository
+func (s *server) ready(context *gin.Context) {
+	if err := s.repository.Ping(context.Request.Context()); err != nil {
+		context.JSON(http.StatusServiceUnavailable, errorResponse(ServiceUnavailableError))
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
 // chat is the handler for the "/api/chat" route, starts a websocket connection to enter the chat server
 func (s *server) chat(context *gin.Context) {
 	// get access token payload to get username of the client from it
 	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+	_, isBot := context.Get(authorizationBotKey)
+
+	// the bots have no email address, the users need a verified one when the configurations require it
+	if s.requireVerifiedEmail && !isBot {
+		username := accessTokenPayload.Username
+		user, err := s.repository.GetUser(repository.WithUser(context.Request.Context(), username), username)
+		if err != nil {
+			repositoryErrorResponse(context, err)
+			return
+		}
+		if !user.EmailVerified {
+			context.JSON(http.StatusForbidden, errorResponse(errEmailNotVerified))
+			return
+		}
+	}
 
 	// upgrade the connection to a websocket connection
 	conn, err := ws.Upgrader.Upgrade(context.Writer, context.Request, nil)
@@ -261,11 +364,976 @@ func (s *server) chat(context *gin.Context) {
 	}
 	defer conn.Close()
 
-	// create a new client instance
-	client := ws.NewClient(s.chatHub, conn, make(chan ws.Message, 10), accessTokenPayload.Username)
-	client.Register()
+	// create a new client instance, bots usually only want the messages sent after they connect
+	client := ws.NewClient(s.chatHub, conn, make(chan ws.Event, 10), accessTokenPayload.Username)
+	if context.Query("history") == "false" {
+		client.SkipHistory()
+	}
+	if isBot {
+		client.SetBot()
+	}
+	if err := client.Register(); err != nil {
+		return
+	}
 
 	// start writing and reading logics
 	go client.Write()
 	client.Read()
 }
+
+// defaultSearchLimit is the number of search results in a page if the request does not set a limit
+const defaultSearchLimit = 20
+
+// snippetReplacer turns the delimiters of the matches in an escaped snippet into html
+var snippetReplacer = strings.NewReplacer(
+	repository.SnippetMatchStart, "<mark>",
+	repository.SnippetMatchEnd, "</mark>",
+)
+
+// searchMessages is the handler for the "/api/messages/search" route, returns a page of the messages
+// matching the search newest first, and the cursor of the next page
+func (s *server) searchMessages(context *gin.Context) {
+	var req SearchMessagesRequest
+	if err := context.ShouldBindQuery(&req); err != nil {
+		context.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid search")))
+		return
+	}
+
+	beforeID, err := decodeCursor(req.Cursor)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid cursor")))
+		return
+	}
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+
+	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	// one more message than the limit is retrieved to know if there is a next page
+	matches, err := s.repository.SearchMessages(
+		repository.WithUser(context.Request.Context(), accessTokenPayload.Username),
+		&repository.MessageSearch{
+			Text:     req.Query,
+			Author:   req.Author,
+			Room:     req.Room,
+			Since:    req.From,
+			Until:    req.To,
+			BeforeID: beforeID,
+			Limit:    limit + 1,
+		},
+	)
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	var res SearchMessagesResponse
+	if len(matches) > limit {
+		matches = matches[:limit]
+		res.NextCursor = encodeCursor(matches[limit-1].ID)
+	}
+
+	res.Results = make([]MessageSearchResult, len(matches))
+	for i, match := range matches {
+		res.Results[i] = MessageSearchResult{
+			ID:        match.ID,
+			Author:    match.Author,
+			Text:      match.Text,
+			Room:      match.Room,
+			CreatedAt: match.CreatedAt,
+			Snippet:   snippetReplacer.Replace(html.EscapeString(match.Snippet)),
+		}
+	}
+
+	context.JSON(http.StatusOK, res)
+}
+
+// defaultNotificationsLimit is the number of notifications in a page if the request does not set a limit
+const defaultNotificationsLimit = 20
+
+// getNotifications is the handler for the "/api/notifications" route, returns a page of the notifications
+// of the user newest first, the number of unread notifications and the cursor of the next page
+func (s *server) getNotifications(context *gin.Context) {
+	var req NotificationsRequest
+	if err := context.ShouldBindQuery(&req); err != nil {
+		context.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid query")))
+		return
+	}
+
+	beforeID, err := decodeCursor(req.Cursor)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid cursor")))
+		return
+	}
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultNotificationsLimit
+	}
+
+	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+	ctx := repository.WithUser(context.Request.Context(), accessTokenPayload.Username)
+
+	// one more notification than the limit is retrieved to know if there is a next page
+	notifications, err := s.repository.GetNotifications(ctx, &repository.NotificationQuery{
+		Username:   accessTokenPayload.Username,
+		UnreadOnly: req.Unread,
+		BeforeID:   beforeID,
+		Limit:      limit + 1,
+	})
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	unreadCount, err := s.repository.CountUnreadNotifications(ctx, accessTokenPayload.Username)
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	res := NotificationsResponse{UnreadCount: unreadCount}
+	if len(notifications) > limit {
+		notifications = notifications[:limit]
+		res.NextCursor = encodeCursor(notifications[limit-1].ID)
+	}
+
+	res.Notifications = make([]NotificationResponse, len(notifications))
+	for i, notification := range notifications {
+		res.Notifications[i] = NotificationResponse{
+			ID:        notification.ID,
+			MessageID: notification.MessageID,
+			Author:    notification.Author,
+			Room:      notification.Room,
+			Text:      notification.Text,
+			Read:      notification.Read,
+			CreatedAt: notification.CreatedAt,
+		}
+	}
+
+	context.JSON(http.StatusOK, res)
+}
+
+// markNotificationsRead is the handler for the "/api/notifications/read" route, marks the notifications
+// of the user with the ids of the request as read, all of them if the request has no id
+func (s *server) markNotificationsRead(context *gin.Context) {
+	var req MarkNotificationsReadRequest
+	if err := context.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		context.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid request")))
+		return
+	}
+
+	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	err := s.repository.MarkNotificationsRead(
+		repository.WithUser(context.Request.Context(), accessTokenPayload.Username),
+		accessTokenPayload.Username,
+		req.IDs,
+	)
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	context.Status(http.StatusNoContent)
+}
+
+// repositoryErrorResponse responds with the status of the input repository error, 503 while the
+// repository is unreachable and 500 otherwise
+func repositoryErrorResponse(context *gin.Context, err error) {
+	if errors.Is(err, repository.ErrUnavailable) {
+		context.JSON(http.StatusServiceUnavailable, errorResponse(ServiceUnavailableError))
+		return
+	}
+	context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
+}
+
+// encodeCursor returns the opaque cursor of the page of search results after the message with the input id
+func encodeCursor(id uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(id), 10)))
+}
+
+// decodeCursor returns the message id of the input cursor, 0 for an empty cursor
+func decodeCursor(cursor string) (uint, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := strconv.ParseUint(string(decoded), 10, 0)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+
+	return uint(id), nil
+}
+
+const (
+	// multipartOverhead is the size allowed for the multipart encoding of an upload besides the file
+	multipartOverhead = 64 << 10
+
+	// sniffLength is the number of bytes the content type of an uploaded file is detected from
+	sniffLength = 512
+
+	// maxFilenameLength is the maximum length of the filename of an attachment
+	maxFilenameLength = 255
+)
+
+// inlineContentTypes are the content types of the attachments browsers display instead of downloading
+var inlineContentTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+// uploadAttachment is the handler for the "/api/attachments" route, stores the file uploaded in the "file"
+// field of a multipart form in the blob store and returns the attachment, which the client then attaches
+// to one of its messages
+func (s *server) uploadAttachment(context *gin.Context) {
+	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+	maxSize := int64(s.configs.AttachmentMaxSize())
+
+	context.Request.Body = http.MaxBytesReader(context.Writer, context.Request.Body, maxSize+multipartOverhead)
+
+	fileHeader, err := context.FormFile("file")
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			context.JSON(http.StatusRequestEntityTooLarge, errorResponse(fmt.Errorf("file is too large")))
+			return
+		}
+		context.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("no file uploaded")))
+		return
+	}
+	if fileHeader.Size > maxSize {
+		context.JSON(http.StatusRequestEntityTooLarge, errorResponse(fmt.Errorf("file is too large")))
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
+		return
+	}
+	defer file.Close()
+
+	// the content type is detected from the content, the content type sent by the client is not trusted
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
+		return
+	}
+	contentType := http.DetectContentType(head[:n])
+	if !s.isAllowedContentType(contentType) {
+		context.JSON(http.StatusUnsupportedMediaType, errorResponse(fmt.Errorf("file type is not allowed")))
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
+		return
+	}
+
+	var content io.Reader = file
+	size := fileHeader.Size
+	width, height := 0, 0
+
+	// the metadata of images, such as the location a photo was taken at, is stripped before they are stored
+	if media.IsImage(contentType) {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
+			return
+		}
+		if data, err = media.StripMetadata(data, contentType); err == nil {
+			width, height, err = media.Dimensions(data, contentType)
+		}
+		if err != nil {
+			context.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid image")))
+			return
+		}
+		content, size = bytes.NewReader(data), int64(len(data))
+	}
+
+	// the checksum is computed while the file is stored
+	id := uuid.NewString()
+	hash := sha256.New()
+	err = s.blobStore.Put(context.Request.Context(), media.AttachmentKey(id), io.TeeReader(content, hash), size, contentType)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
+		return
+	}
+
+	attachment, err := s.repository.AddAttachment(
+		repository.WithUser(context.Request.Context(), accessTokenPayload.Username),
+		&repository.Attachment{
+			ID:          id,
+			Owner:       accessTokenPayload.Username,
+			Filename:    sanitizeFilename(fileHeader.Filename),
+			ContentType: contentType,
+			Size:        size,
+			Checksum:    hex.EncodeToString(hash.Sum(nil)),
+			Width:       width,
+			Height:      height,
+		},
+	)
+	if err != nil {
+		// the file of an attachment which could not be saved is never downloaded
+		if err := s.blobStore.Delete(context.Request.Context(), media.AttachmentKey(id)); err != nil {
+			log.Printf("error: %v", err)
+		}
+
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	// the thumbnail is generated in the background and sent to the clients once the attachment is sent
+	if err := s.thumbnails.Enqueue(attachment); err != nil {
+		log.Printf("error: thumbnail of attachment %s is not generated: %v", attachment.ID, err)
+	}
+
+	context.JSON(http.StatusCreated, toAttachmentResponse(attachment))
+}
+
+// downloadAttachment is the handler for the "/api/attachments/:id" route, serves the file of an attachment.
+// attachments not attached to a message yet are only served to their owner
+func (s *server) downloadAttachment(context *gin.Context) {
+	attachment, ok := s.getAttachment(context)
+	if !ok {
+		return
+	}
+
+	disposition := "attachment"
+	if slices.Contains(inlineContentTypes, attachment.ContentType) {
+		disposition = "inline"
+	}
+
+	// the content of an attachment never changes, its checksum is its entity tag
+	s.serveBlob(context, media.AttachmentKey(attachment.ID), attachment.Size, attachment.ContentType,
+		`"`+attachment.Checksum+`"`, mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}))
+}
+
+// downloadThumbnail is the handler for the "/api/attachments/:id/thumbnail" route, serves the thumbnail of
+// an image attachment once it is generated, with the access rules of the attachment
+func (s *server) downloadThumbnail(context *gin.Context) {
+	attachment, ok := s.getAttachment(context)
+	if !ok {
+		return
+	}
+	if attachment.Thumbnail == nil {
+		context.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("thumbnail not found")))
+		return
+	}
+
+	// a thumbnail is generated once from the content of its attachment, so it never changes either
+	s.serveBlob(context, media.ThumbnailKey(attachment.ID), attachment.Thumbnail.Size, attachment.Thumbnail.ContentType,
+		`"`+attachment.Checksum+`-thumbnail"`, "inline")
+}
+
+// getAttachment retrieves the attachment of the "id" parameter the authenticated user may download,
+// and writes the error response and returns false if there is none
+func (s *server) getAttachment(context *gin.Context) (*repository.Attachment, bool) {
+	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	attachment, err := s.repository.GetAttachment(
+		repository.WithUser(context.Request.Context(), accessTokenPayload.Username),
+		context.Param("id"),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			context.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("attachment not found")))
+		case errors.Is(err, repository.ErrUnavailable):
+			context.JSON(http.StatusServiceUnavailable, errorResponse(ServiceUnavailableError))
+		default:
+			context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
+		}
+		return nil, false
+	}
+	if attachment.MessageID == 0 && attachment.Owner != accessTokenPayload.Username {
+		context.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("attachment not found")))
+		return nil, false
+	}
+
+	return attachment, true
+}
+
+// serveBlob serves the blob with the input key, or responds with 304 if the client has the blob of the input
+// entity tag already. blobs are served in a sandbox so they cannot run scripts in the origin of the server
+func (s *server) serveBlob(context *gin.Context, key string, size int64, contentType, etag, disposition string) {
+	if context.GetHeader("If-None-Match") == etag {
+		context.Status(http.StatusNotModified)
+		return
+	}
+
+	content, err := s.blobStore.Get(context.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			context.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("attachment not found")))
+			return
+		}
+		context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
+		return
+	}
+	defer content.Close()
+
+	context.DataFromReader(http.StatusOK, size, contentType, content, map[string]string{
+		"Content-Disposition":     disposition,
+		"Content-Security-Policy": "default-src 'none'; sandbox",
+		"X-Content-Type-Options":  "nosniff",
+		"Cache-Control":           "private, max-age=86400",
+		"ETag":                    etag,
+	})
+}
+
+// isAllowedContentType reports whether attachments may have the input content type, parameters of the
+// content type such as the charset are ignored
+func (s *server) isAllowedContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, allowed := range s.configs.AttachmentAllowedTypes() {
+		if strings.EqualFold(mediaType, allowed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// attachmentURL returns the url the attachment with the input id is downloaded from
+func attachmentURL(id string) string {
+	return "/api/attachments/" + id
+}
+
+// thumbnailURL returns the url the thumbnail of the attachment with the input id is downloaded from
+func thumbnailURL(id string) string {
+	return attachmentURL(id) + "/thumbnail"
+}
+
+// sanitizeFilename returns the base name of the input filename without control characters, truncated
+// to maxFilenameLength characters
+func sanitizeFilename(filename string) string {
+	filename = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, filepath.Base(strings.ReplaceAll(filename, "\\", "/")))
+
+	if runes := []rune(filename); len(runes) > maxFilenameLength {
+		filename = string(runes[:maxFilenameLength])
+	}
+	if filename == "." || filename == "/" || filename == "" {
+		return "file"
+	}
+
+	return filename
+}
+
+// defaultDeliveriesLimit is the number of delivery attempts of a webhook listed when the request has no limit
+const defaultDeliveriesLimit = 20
+
+// defaultLockoutsLimit is the number of lockouts listed when the request has no limit
+const defaultLockoutsLimit = 50
+
+// webhookSecretSize is the number of random bytes of the secrets of the webhooks
+const webhookSecretSize = 32
+
+// createWebhook is the handler for the "/api/admin/webhooks" POST route, registers a webhook receiving the
+// events of the request. its secret is generated and sent only in this response
+func (s *server) createWebhook(context *gin.Context) {
+	var req CreateWebhookRequest
+	if err := context.ShouldBindJSON(&req); err != nil {
+		context.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid request")))
+		return
+	}
+
+	parsedURL, err := url.Parse(req.URL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		context.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid url")))
+		return
+	}
+
+	events := slices.Clone(req.Events)
+	for _, event := range events {
+		if !webhook.IsEventType(event) {
+			context.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid event %q", event)))
+			return
+		}
+	}
+	slices.Sort(events)
+	events = slices.Compact(events)
+
+	secret := make([]byte, webhookSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
+		return
+	}
+
+	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+	newWebhook, err := s.repository.AddWebhook(
+		repository.WithUser(context.Request.Context(), accessTokenPayload.Username),
+		&repository.Webhook{
+			URL:       req.URL,
+			Secret:    hex.EncodeToString(secret),
+			Events:    events,
+			CreatedBy: accessTokenPayload.Username,
+		},
+	)
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+	s.invalidateWebhooks()
+
+	res := toWebhookResponse(newWebhook)
+	res.Secret = newWebhook.Secret
+	context.JSON(http.StatusCreated, res)
+}
+
+// getWebhooks is the handler for the "/api/admin/webhooks" GET route, lists the registered webhooks
+func (s *server) getWebhooks(context *gin.Context) {
+	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	webhooks, err := s.repository.GetWebhooks(repository.WithUser(context.Request.Context(), accessTokenPayload.Username))
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	res := WebhooksResponse{Webhooks: make([]WebhookResponse, len(webhooks))}
+	for i, webhook := range webhooks {
+		res.Webhooks[i] = toWebhookResponse(webhook)
+	}
+
+	context.JSON(http.StatusOK, res)
+}
+
+// deleteWebhook is the handler for the "/api/admin/webhooks/:id" DELETE route, deletes a webhook and its
+// delivery attempts
+func (s *server) deleteWebhook(context *gin.Context) {
+	id, ok := webhookIDParam(context)
+	if !ok {
+		return
+	}
+
+	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	err := s.repository.DeleteWebhook(repository.WithUser(context.Request.Context(), accessTokenPayload.Username), id)
+	if err != nil {
+		webhookErrorResponse(context, err)
+		return
+	}
+	s.invalidateWebhooks()
+
+	context.Status(http.StatusNoContent)
+}
+
+// enableWebhook is the handler for the "/api/admin/webhooks/:id/enable" route, enables a webhook disabled
+// after too many failures and resets its failures
+func (s *server) enableWebhook(context *gin.Context) {
+	id, ok := webhookIDParam(context)
+	if !ok {
+		return
+	}
+
+	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	webhook, err := s.repository.EnableWebhook(repository.WithUser(context.Request.Context(), accessTokenPayload.Username), id)
+	if err != nil {
+		webhookErrorResponse(context, err)
+		return
+	}
+	s.invalidateWebhooks()
+
+	context.JSON(http.StatusOK, toWebhookResponse(webhook))
+}
+
+// getWebhookDeliveries is the handler for the "/api/admin/webhooks/:id/deliveries" route, lists the latest
+// delivery attempts of a webhook, newest first
+func (s *server) getWebhookDeliveries(context *gin.Context) {
+	id, ok := webhookIDParam(context)
+	if !ok {
+		return
+	}
+
+	var req WebhookDeliveriesRequest
+	if err := context.ShouldBindQuery(&req); err != nil {
+		context.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid query")))
+		return
+	}
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultDeliveriesLimit
+	}
+
+	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+	ctx := repository.WithUser(context.Request.Context(), accessTokenPayload.Username)
+
+	// a webhook without deliveries is told apart from a webhook which does not exist
+	if _, err := s.repository.GetWebhook(ctx, id); err != nil {
+		webhookErrorResponse(context, err)
+		return
+	}
+
+	deliveries, err := s.repository.GetWebhookDeliveries(ctx, id, limit)
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	res := WebhookDeliveriesResponse{Deliveries: make([]WebhookDeliveryResponse, len(deliveries))}
+	for i, delivery := range deliveries {
+		res.Deliveries[i] = WebhookDeliveryResponse{
+			ID:         delivery.ID,
+			EventID:    delivery.EventID,
+			EventType:  delivery.EventType,
+			Attempt:    delivery.Attempt,
+			StatusCode: delivery.StatusCode,
+			Error:      delivery.Error,
+			DurationMs: delivery.Duration.Milliseconds(),
+			CreatedAt:  delivery.CreatedAt,
+		}
+	}
+
+	context.JSON(http.StatusOK, res)
+}
+
+// secretTokenSize is the number of random bytes of the tokens in the urls of the incoming hooks and of the
+// API tokens of the bots
+const secretTokenSize = 32
+
+// createIncomingHook is the handler for the "/api/admin/hooks" POST route, creates an incoming hook and the bot
+// user its messages are posted as. the secret url of the hook is only sent in this response
+func (s *server) createIncomingHook(context *gin.Context) {
+	var req CreateIncomingHookRequest
+	if err := context.ShouldBindJSON(&req); err != nil {
+		context.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid request")))
+		return
+	}
+	if s.isAdminUsername(req.Username) {
+		context.JSON(http.StatusConflict, errorResponse(errReservedUsername))
+		return
+	}
+
+	hookToken, err := newSecretToken("")
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
+		return
+	}
+
+	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+	hook, err := s.repository.AddIncomingHook(
+		repository.WithUser(context.Request.Context(), accessTokenPayload.Username),
+		&repository.IncomingHook{
+			TokenHash: hashSecretToken(hookToken),
+			Name:      req.Name,
+			Username:  req.Username,
+			CreatedBy: accessTokenPayload.Username,
+		},
+	)
+	if errors.Is(err, repository.ErrUserExists) {
+		context.JSON(http.StatusConflict, errorResponse(fmt.Errorf("username already exists")))
+		return
+	}
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	res := toIncomingHookResponse(hook)
+	res.URL = incomingHookURL(hookToken)
+	context.JSON(http.StatusCreated, res)
+}
+
+// getIncomingHooks is the handler for the "/api/admin/hooks" GET route, lists the incoming hooks
+func (s *server) getIncomingHooks(context *gin.Context) {
+	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	hooks, err := s.repository.GetIncomingHooks(repository.WithUser(context.Request.Context(), accessTokenPayload.Username))
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	res := IncomingHooksResponse{Hooks: make([]IncomingHookResponse, len(hooks))}
+	for i, hook := range hooks {
+		res.Hooks[i] = toIncomingHookResponse(hook)
+	}
+
+	context.JSON(http.StatusOK, res)
+}
+
+// revokeIncomingHook is the handler for the "/api/admin/hooks/:id/revoke" route, revokes an incoming hook so
+// its url posts no more messages. the bot user and its messages are kept
+func (s *server) revokeIncomingHook(context *gin.Context) {
+	id, err := strconv.ParseUint(context.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		context.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid hook id")))
+		return
+	}
+
+	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	hook, err := s.repository.RevokeIncomingHook(repository.WithUser(context.Request.Context(), accessTokenPayload.Username), uint(id))
+	if errors.Is(err, repository.ErrNotFound) {
+		context.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("hook not found")))
+		return
+	}
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, toIncomingHookResponse(hook))
+}
+
+// getLockouts is the handler for the "/api/admin/lockouts" route, lists the latest lockouts of the usernames
+// and the addresses after failed logins, newest first
+func (s *server) getLockouts(context *gin.Context) {
+	var req LockoutsRequest
+	if err := context.ShouldBindQuery(&req); err != nil {
+		context.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid query")))
+		return
+	}
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultLockoutsLimit
+	}
+
+	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	lockouts, err := s.repository.GetLockouts(repository.WithUser(context.Request.Context(), accessTokenPayload.Username), limit)
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	res := LockoutsResponse{Lockouts: make([]LockoutResponse, len(lockouts))}
+	for i, lockout := range lockouts {
+		res.Lockouts[i] = LockoutResponse{
+			ID:          lockout.ID,
+			Scope:       lockout.Scope,
+			Username:    lockout.Username,
+			ClientIP:    lockout.ClientIP,
+			Failures:    lockout.Failures,
+			LockedUntil: lockout.LockedUntil,
+			CreatedAt:   lockout.CreatedAt,
+		}
+	}
+
+	context.JSON(http.StatusOK, res)
+}
+
+// postIncomingHook is the handler for the "/api/hooks/:id" route, posts the message of the request body to the
+// chat as the bot user of the hook. the id is the secret token of the hook, so no other authentication is needed
+func (s *server) postIncomingHook(context *gin.Context) {
+	// an unknown and a revoked hook are not told apart
+	hook, err := s.repository.GetIncomingHookByToken(context.Request.Context(), hashSecretToken(context.Param("id")))
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && hook.Revoked) {
+		context.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("hook not found")))
+		return
+	}
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	allowed, retryAfter, err := s.hookLimiter.Allow(context.Request.Context(), strconv.FormatUint(uint64(hook.ID), 10))
+	if err != nil {
+		log.Printf("error: cannot rate limit incoming hook: %v", err)
+	} else if !allowed {
+		abortTooManyRequests(context, retryAfter, fmt.Errorf("too many messages, retry later"))
+		return
+	}
+
+	var req IncomingHookMessageRequest
+	if err := context.ShouldBindJSON(&req); err != nil {
+		context.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid request")))
+		return
+	}
+
+	if err := s.chatHub.Post(context.Request.Context(), hook.Username, req.Text); err != nil {
+		context.JSON(http.StatusServiceUnavailable, errorResponse(ServiceUnavailableError))
+		return
+	}
+
+	// the message is saved and broadcast by the hub
+	context.Status(http.StatusAccepted)
+}
+
+// createBot is the handler for the "/api/bots" POST route, creates a bot owned by the user along with its bot
+// user. the API token of the bot is only sent in this response. bots cannot create bots
+func (s *server) createBot(context *gin.Context) {
+	if _, ok := context.Get(authorizationBotKey); ok {
+		context.JSON(http.StatusForbidden, errorResponse(fmt.Errorf("bots cannot manage bots")))
+		return
+	}
+
+	var req CreateBotRequest
+	if err := context.ShouldBindJSON(&req); err != nil {
+		context.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid request")))
+		return
+	}
+	if s.isAdminUsername(req.Username) {
+		context.JSON(http.StatusConflict, errorResponse(errReservedUsername))
+		return
+	}
+
+	apiToken, err := newSecretToken(apiTokenPrefix)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
+		return
+	}
+
+	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+	bot, err := s.repository.AddBot(
+		repository.WithUser(context.Request.Context(), accessTokenPayload.Username),
+		&repository.Bot{
+			Username:  req.Username,
+			Owner:     accessTokenPayload.Username,
+			TokenHash: hashSecretToken(apiToken),
+		},
+	)
+	if errors.Is(err, repository.ErrUserExists) {
+		context.JSON(http.StatusConflict, errorResponse(fmt.Errorf("username already exists")))
+		return
+	}
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	res := toBotResponse(bot)
+	res.Token = apiToken
+	context.JSON(http.StatusCreated, res)
+}
+
+// isAdminUsername reports whether the input username is the username of an administrator
+func (s *server) isAdminUsername(username string) bool {
+	return slices.Contains(s.configs.AdminUsernames(), username)
+}
+
+// getBots is the handler for the "/api/bots" GET route, lists the bots of the user
+func (s *server) getBots(context *gin.Context) {
+	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	bots, err := s.repository.GetBots(
+		repository.WithUser(context.Request.Context(), accessTokenPayload.Username),
+		accessTokenPayload.Username,
+	)
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	res := BotsResponse{Bots: make([]BotResponse, len(bots))}
+	for i, bot := range bots {
+		res.Bots[i] = toBotResponse(bot)
+	}
+
+	context.JSON(http.StatusOK, res)
+}
+
+// getCurrentBot is the handler for the "/api/bots/me" route, returns the bot authenticated by the API token
+// of the request, so bots learn their username
+func (s *server) getCurrentBot(context *gin.Context) {
+	bot, ok := context.Get(authorizationBotKey)
+	if !ok {
+		context.JSON(http.StatusForbidden, errorResponse(fmt.Errorf("api token required")))
+		return
+	}
+
+	context.JSON(http.StatusOK, toBotResponse(bot.(*repository.Bot)))
+}
+
+// replaceBotToken is the handler for the "/api/bots/:username/token" route, replaces the API token of a bot of
+// the user, the previous token stops working. the new token is only sent in this response
+func (s *server) replaceBotToken(context *gin.Context) {
+	if _, ok := context.Get(authorizationBotKey); ok {
+		context.JSON(http.StatusForbidden, errorResponse(fmt.Errorf("bots cannot manage bots")))
+		return
+	}
+
+	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+	ctx := repository.WithUser(context.Request.Context(), accessTokenPayload.Username)
+
+	// the bots of other users are not told apart from bots which do not exist
+	bot, err := s.repository.GetBot(ctx, context.Param("username"))
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && bot.Owner != accessTokenPayload.Username) {
+		context.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("bot not found")))
+		return
+	}
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	apiToken, err := newSecretToken(apiTokenPrefix)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
+		return
+	}
+
+	bot, err = s.repository.SetBotToken(ctx, bot.Username, hashSecretToken(apiToken))
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	res := toBotResponse(bot)
+	res.Token = apiToken
+	context.JSON(http.StatusOK, res)
+}
+
+// newSecretToken returns a new random token of secretTokenSize bytes, base64 url encoded after the input prefix
+func newSecretToken(prefix string) (string, error) {
+	secret := make([]byte, secretTokenSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return prefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// hashSecretToken returns the hex encoded sha256 hash of the input secret token, only the hashes of the
+// tokens of the incoming hooks and bots are stored
+func hashSecretToken(secretToken string) string {
+	hash := sha256.Sum256([]byte(secretToken))
+
+	return hex.EncodeToString(hash[:])
+}
+
+// incomingHookURL returns the url path messages are posted to through the incoming hook of the input token
+func incomingHookURL(hookToken string) string {
+	return "/api/hooks/" + hookToken
+}
+
+// webhookIDParam returns the webhook id of the "id" parameter, and writes the error response and returns
+// false if it is not a valid id
+func webhookIDParam(context *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(context.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		context.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid webhook id")))
+		return 0, false
+	}
+
+	return uint(id), true
+}
+
+// webhookErrorResponse responds with the status of the input repository error of an operation on a webhook
+func webhookErrorResponse(context *gin.Context, err error) {
+	if errors.Is(err, repository.ErrNotFound) {
+		context.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("webhook not found")))
+		return
+	}
+	repositoryErrorResponse(context, err)
+}
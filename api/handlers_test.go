@@ -1,22 +1,41 @@
 package api
 
 import (
+	"Chat-Server/api/ws"
+	"Chat-Server/chatbot"
+	"Chat-Server/media"
+	"Chat-Server/ratelimit"
 	"Chat-Server/repository"
+	"Chat-Server/repository/db/memory"
 	mockdb "Chat-Server/repository/mock"
+	"Chat-Server/storage"
 	"Chat-Server/token"
 	mockmaker "Chat-Server/token/mock"
 	"Chat-Server/util"
+	"Chat-Server/webhook"
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/gorilla/websocket"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
-	"gorm.io/gorm"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -48,6 +67,12 @@ func newUserMatcher(username, password string) gomock.Matcher {
 	}
 }
 
+// primaryContext matches the contexts whose reads are served from the primary
+var primaryContext = gomock.Cond(func(x any) bool {
+	ctx, ok := x.(context.Context)
+	return ok && repository.PrimaryFromContext(ctx)
+})
+
 // TestSignup tests signup route handler
 func TestSignup(t *testing.T) {
 	randomUser, password := randomUser(t)
@@ -79,7 +104,7 @@ func TestSignup(t *testing.T) {
 				req SignupRequest,
 			) {
 				repository.EXPECT().
-					AddUser(newUserMatcher(randomUser.Username, password)).
+					AddUser(gomock.Any(), newUserMatcher(randomUser.Username, password)).
 					Times(1).
 					Return(randomUser, nil)
 				tokenMaker.EXPECT().
@@ -87,9 +112,13 @@ func TestSignup(t *testing.T) {
 					Times(1).
 					Return(accessToken, accessTokenPayload, nil)
 				tokenMaker.EXPECT().
-					CreateToken(req.Username, testConfigs.RefreshTokenDuration()).
+					CreatePurposeToken(refreshTokenPurpose, req.Username, testConfigs.RefreshTokenDuration()).
 					Times(1).
 					Return(refreshToken, refreshTokenPayload, nil)
+				repository.EXPECT().
+					AddSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, nil)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
 				require.Equal(t, http.StatusOK, recorder.Code)
@@ -104,14 +133,14 @@ func TestSignup(t *testing.T) {
 				Password: password,
 			},
 			buildStubs: func(
-				repository *mockdb.MockRepository,
+				repo *mockdb.MockRepository,
 				tokenMaker *mockmaker.MockMaker,
 				req SignupRequest,
 			) {
-				repository.EXPECT().
-					AddUser(newUserMatcher(randomUser.Username, password)).
+				repo.EXPECT().
+					AddUser(gomock.Any(), newUserMatcher(randomUser.Username, password)).
 					Times(1).
-					Return(nil, &pgconn.PgError{ConstraintName: "users_pkey"})
+					Return(nil, repository.ErrUserExists)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
 				require.Equal(t, http.StatusForbidden, recorder.Code)
@@ -145,7 +174,7 @@ func TestSignup(t *testing.T) {
 				req SignupRequest,
 			) {
 				repository.EXPECT().
-					AddUser(newUserMatcher(randomUser.Username, password)).
+					AddUser(gomock.Any(), newUserMatcher(randomUser.Username, password)).
 					Times(1).
 					Return(nil, sql.ErrConnDone)
 			},
@@ -153,6 +182,26 @@ func TestSignup(t *testing.T) {
 				require.Equal(t, http.StatusInternalServerError, recorder.Code)
 			},
 		},
+		{
+			name: "DBUnavailable",
+			req: SignupRequest{
+				Username: randomUser.Username,
+				Password: password,
+			},
+			buildStubs: func(
+				repo *mockdb.MockRepository,
+				tokenMaker *mockmaker.MockMaker,
+				req SignupRequest,
+			) {
+				repo.EXPECT().
+					AddUser(gomock.Any(), newUserMatcher(randomUser.Username, password)).
+					Times(1).
+					Return(nil, fmt.Errorf("%w: connection refused", repository.ErrUnavailable))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+			},
+		},
 		{
 			name: "CreateAccessTokenInternalServerError",
 			req: SignupRequest{
@@ -165,7 +214,7 @@ func TestSignup(t *testing.T) {
 				req SignupRequest,
 			) {
 				repository.EXPECT().
-					AddUser(newUserMatcher(randomUser.Username, password)).
+					AddUser(gomock.Any(), newUserMatcher(randomUser.Username, password)).
 					Times(1).
 					Return(randomUser, nil)
 				tokenMaker.EXPECT().
@@ -189,7 +238,7 @@ func TestSignup(t *testing.T) {
 				req SignupRequest,
 			) {
 				repository.EXPECT().
-					AddUser(newUserMatcher(randomUser.Username, password)).
+					AddUser(gomock.Any(), newUserMatcher(randomUser.Username, password)).
 					Times(1).
 					Return(randomUser, nil)
 				tokenMaker.EXPECT().
@@ -197,7 +246,7 @@ func TestSignup(t *testing.T) {
 					Times(1).
 					Return(accessToken, accessTokenPayload, nil)
 				tokenMaker.EXPECT().
-					CreateToken(req.Username, testConfigs.RefreshTokenDuration()).
+					CreatePurposeToken(refreshTokenPurpose, req.Username, testConfigs.RefreshTokenDuration()).
 					Times(1).
 					Return("", &token.Payload{}, errors.New("failed to encode payload to []byte"))
 			},
@@ -264,12 +313,12 @@ func TestLogin(t *testing.T) {
 				Password: password,
 			},
 			buildStubs: func(
-				repository *mockdb.MockRepository,
+				repo *mockdb.MockRepository,
 				tokenMaker *mockmaker.MockMaker,
 				req LoginRequest,
 			) {
-				repository.EXPECT().
-					GetUser(gomock.Eq(req.Username)).
+				repo.EXPECT().
+					GetUser(primaryContext, gomock.Eq(req.Username)).
 					Times(1).
 					Return(randomUser, nil)
 				tokenMaker.EXPECT().
@@ -277,9 +326,18 @@ func TestLogin(t *testing.T) {
 					Times(1).
 					Return(accessToken, accessTokenPayload, nil)
 				tokenMaker.EXPECT().
-					CreateToken(req.Username, testConfigs.RefreshTokenDuration()).
+					CreatePurposeToken(refreshTokenPurpose, req.Username, testConfigs.RefreshTokenDuration()).
 					Times(1).
 					Return(refreshToken, refreshTokenPayload, nil)
+				repo.EXPECT().
+					AddSession(gomock.Any(), gomock.Cond(func(x any) bool {
+						session := x.(*repository.Session)
+						return session.Username == req.Username &&
+							session.RefreshToken == hashSecretToken(refreshToken) &&
+							session.ExpiresAt.Equal(refreshTokenPayload.ExpiredAt)
+					})).
+					Times(1).
+					Return(&repository.Session{ID: 1}, nil)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
 				require.Equal(t, http.StatusOK, recorder.Code)
@@ -294,17 +352,96 @@ func TestLogin(t *testing.T) {
 				Password: password,
 			},
 			buildStubs: func(
-				repository *mockdb.MockRepository,
+				repo *mockdb.MockRepository,
 				tokenMaker *mockmaker.MockMaker,
 				req LoginRequest,
 			) {
-				repository.EXPECT().
-					GetUser(gomock.Eq(req.Username)).
+				repo.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(req.Username)).
 					Times(1).
-					Return(nil, gorm.ErrRecordNotFound)
+					Return(nil, repository.ErrNotFound)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
-				require.Equal(t, http.StatusNotFound, recorder.Code)
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.JSONEq(t, `{"error":"invalid username or password"}`, recorder.Body.String())
+			},
+		},
+		{
+			name: "TwoFactorRequired",
+			req: LoginRequest{
+				Username: randomUser.Username,
+				Password: password,
+			},
+			buildStubs: func(
+				repo *mockdb.MockRepository,
+				tokenMaker *mockmaker.MockMaker,
+				req LoginRequest,
+			) {
+				repo.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(req.Username)).
+					Times(1).
+					Return(randomUser, nil)
+				repo.EXPECT().
+					GetTwoFactor(gomock.Any(), req.Username).
+					Times(1).
+					Return(&repository.TwoFactor{Username: req.Username, Enabled: true}, nil)
+				tokenMaker.EXPECT().
+					CreatePurposeToken(twoFactorTokenPurpose, req.Username, testConfigs.TwoFactorTokenDuration()).
+					Times(1).
+					Return("two_factor_token", &token.Payload{ExpiredAt: time.Now().Add(time.Minute)}, nil)
+				tokenMaker.EXPECT().CreateToken(gomock.Any(), gomock.Any()).Times(0)
+				repo.EXPECT().DeleteLoginThrottle(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Empty(t, recorder.Result().Cookies())
+
+				var res TwoFactorChallengeResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Equal(t, "two_factor_token", res.TwoFactorToken)
+			},
+		},
+		{
+			name: "LockedOut",
+			req: LoginRequest{
+				Username: randomUser.Username,
+				Password: password,
+			},
+			buildStubs: func(
+				repo *mockdb.MockRepository,
+				tokenMaker *mockmaker.MockMaker,
+				req LoginRequest,
+			) {
+				repo.EXPECT().
+					GetLoginThrottle(gomock.Any(), "username:"+req.Username).
+					Times(1).
+					Return(&repository.LoginThrottle{Failures: 5, LockedUntil: time.Now().Add(time.Minute)}, nil)
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+				require.Equal(t, "60", recorder.Header().Get("Retry-After"))
+			},
+		},
+		{
+			name: "ThrottleUnavailable",
+			req: LoginRequest{
+				Username: randomUser.Username,
+				Password: password,
+			},
+			buildStubs: func(
+				repo *mockdb.MockRepository,
+				tokenMaker *mockmaker.MockMaker,
+				req LoginRequest,
+			) {
+				repo.EXPECT().
+					GetLoginThrottle(gomock.Any(), "username:"+req.Username).
+					Times(1).
+					Return(nil, fmt.Errorf("%w: connection refused", repository.ErrUnavailable))
+				repo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
 			},
 		},
 		{
@@ -335,7 +472,7 @@ func TestLogin(t *testing.T) {
 				req LoginRequest,
 			) {
 				repository.EXPECT().
-					GetUser(gomock.Eq(req.Username)).
+					GetUser(gomock.Any(), gomock.Eq(req.Username)).
 					Times(1).
 					Return(nil, sql.ErrConnDone)
 			},
@@ -343,6 +480,26 @@ func TestLogin(t *testing.T) {
 				require.Equal(t, http.StatusInternalServerError, recorder.Code)
 			},
 		},
+		{
+			name: "DBUnavailable",
+			req: LoginRequest{
+				Username: randomUser.Username,
+				Password: password,
+			},
+			buildStubs: func(
+				repo *mockdb.MockRepository,
+				tokenMaker *mockmaker.MockMaker,
+				req LoginRequest,
+			) {
+				repo.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(req.Username)).
+					Times(1).
+					Return(nil, fmt.Errorf("%w: connection refused", repository.ErrUnavailable))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+			},
+		},
 		{
 			name: "WrongPasswordUnAuthorized",
 			req: LoginRequest{
@@ -355,12 +512,17 @@ func TestLogin(t *testing.T) {
 				req LoginRequest,
 			) {
 				repository.EXPECT().
-					GetUser(gomock.Eq(req.Username)).
+					GetUser(gomock.Any(), gomock.Eq(req.Username)).
 					Times(1).
 					Return(randomUser, nil)
+				repository.EXPECT().
+					UpdateLoginThrottle(gomock.Any(), "username:"+req.Username, gomock.Any()).
+					Times(1).
+					Return(nil, nil)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.JSONEq(t, `{"error":"invalid username or password"}`, recorder.Body.String())
 			},
 		},
 		{
@@ -375,7 +537,7 @@ func TestLogin(t *testing.T) {
 				req LoginRequest,
 			) {
 				repository.EXPECT().
-					GetUser(gomock.Eq(req.Username)).
+					GetUser(gomock.Any(), gomock.Eq(req.Username)).
 					Times(1).
 					Return(randomUser, nil)
 				tokenMaker.EXPECT().
@@ -399,7 +561,7 @@ func TestLogin(t *testing.T) {
 				req LoginRequest,
 			) {
 				repository.EXPECT().
-					GetUser(gomock.Eq(req.Username)).
+					GetUser(gomock.Any(), gomock.Eq(req.Username)).
 					Times(1).
 					Return(randomUser, nil)
 				tokenMaker.EXPECT().
@@ -407,7 +569,7 @@ func TestLogin(t *testing.T) {
 					Times(1).
 					Return(accessToken, accessTokenPayload, nil)
 				tokenMaker.EXPECT().
-					CreateToken(req.Username, testConfigs.RefreshTokenDuration()).
+					CreatePurposeToken(refreshTokenPurpose, req.Username, testConfigs.RefreshTokenDuration()).
 					Times(1).
 					Return("", &token.Payload{}, errors.New("failed to encode payload to []byte"))
 			},
@@ -429,6 +591,7 @@ func TestLogin(t *testing.T) {
 			refreshToken, refreshTokenPayload = createToken(t, randomUser.Username, testConfigs.RefreshTokenDuration())
 
 			testCase.buildStubs(services, tokenMaker, testCase.req)
+			stubLogin(services)
 
 			testServer := NewTestServer(t, services, tokenMaker)
 
@@ -448,6 +611,122 @@ func TestLogin(t *testing.T) {
 
 }
 
+// stubLogin stubs the login throttles and the TOTP authenticators of the users without failed logins nor
+// two-factor authentication, after the stubs of a test case so its own are matched first
+func stubLogin(repo *mockdb.MockRepository) {
+	repo.EXPECT().DeleteExpiredLoginThrottles(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+	repo.EXPECT().GetLoginThrottle(gomock.Any(), gomock.Any()).AnyTimes().Return(nil, repository.ErrNotFound)
+	repo.EXPECT().UpdateLoginThrottle(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, key string, update func(*repository.LoginThrottle)) (*repository.LoginThrottle, error) {
+			throttle := &repository.LoginThrottle{Key: key}
+			update(throttle)
+			return throttle, nil
+		})
+	repo.EXPECT().DeleteLoginThrottle(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+	repo.EXPECT().GetTwoFactor(gomock.Any(), gomock.Any()).AnyTimes().Return(nil, repository.ErrNotFound)
+}
+
+// TestLoginLockout tests delaying and locking out the usernames and the addresses after failed logins
+func TestLoginLockout(t *testing.T) {
+	repo := memory.NewMemoryRepository()
+	tokenMaker, err := token.NewPasetoMaker(testConfigs.TokenSymmetricKey())
+	require.NoError(t, err)
+	server := NewTestServer(t, repo, tokenMaker)
+
+	now := time.Now()
+	server.loginGuard = &loginGuard{
+		repository:         repo,
+		maxFailures:        3,
+		addressMaxFailures: 5,
+		lockoutDuration:    10 * time.Minute,
+		failureDelay:       time.Second,
+		failureWindow:      15 * time.Minute,
+		now:                func() time.Time { return now },
+	}
+
+	user, password := randomUser(t)
+	_, err = repo.AddUser(context.Background(), user)
+	require.NoError(t, err)
+
+	login := func(username, password, address string) *httptest.ResponseRecorder {
+		body, err := json.Marshal(LoginRequest{Username: username, Password: password})
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, "/api/login", bytes.NewBuffer(body))
+		require.NoError(t, err)
+		req.RemoteAddr = address + ":40000"
+
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// an unknown username and a wrong password get the same response
+	recorder := login(util.RandomUsername(), password, "192.0.2.1")
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+	unknownBody := recorder.Body.String()
+	recorder = login(user.Username, "wrong_password", "192.0.2.1")
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+	require.Equal(t, unknownBody, recorder.Body.String())
+
+	// the second failed login of a username delays its next login, from any address
+	require.Equal(t, http.StatusUnauthorized, login(user.Username, "wrong_password", "192.0.2.1").Code)
+	recorder = login(user.Username, password, "192.0.2.2")
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	require.Equal(t, "1", recorder.Header().Get("Retry-After"))
+
+	// the third failed login locks the username out, even with the right password
+	now = now.Add(time.Second)
+	require.Equal(t, http.StatusUnauthorized, login(user.Username, "wrong_password", "192.0.2.1").Code)
+	now = now.Add(30 * time.Second)
+	recorder = login(user.Username, password, "192.0.2.2")
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	require.Equal(t, "570", recorder.Header().Get("Retry-After"))
+
+	lockouts, err := repo.GetLockouts(context.Background(), 10)
+	require.NoError(t, err)
+	require.Len(t, lockouts, 1)
+	require.Equal(t, repository.LoginScopeUsername, lockouts[0].Scope)
+	require.Equal(t, user.Username, lockouts[0].Username)
+	require.Equal(t, "192.0.2.1", lockouts[0].ClientIP)
+	require.Equal(t, 3, lockouts[0].Failures)
+
+	// the login after the lockout clears the failed logins of the username
+	now = now.Add(10 * time.Minute)
+	require.Equal(t, http.StatusOK, login(user.Username, password, "192.0.2.2").Code)
+	_, err = repo.GetLoginThrottle(context.Background(), "username:"+user.Username)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	// the fifth failed login from an address locks it out, whatever the usernames
+	require.Equal(t, http.StatusUnauthorized, login(util.RandomUsername(), password, "192.0.2.1").Code)
+	recorder = login(user.Username, password, "192.0.2.1")
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	require.Equal(t, "600", recorder.Header().Get("Retry-After"))
+	require.Equal(t, http.StatusOK, login(user.Username, password, "192.0.2.3").Code)
+
+	// the admins list the lockouts, newest first
+	listLockouts := func(username string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, "/api/admin/lockouts?limit=10", nil)
+		require.NoError(t, err)
+		addTokenCookie(t, username, req, authorizationCookieName, time.Minute, "/")
+
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	require.Equal(t, http.StatusForbidden, listLockouts(user.Username).Code)
+
+	recorder = listLockouts(testAdminUsername)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var res LockoutsResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+	require.Len(t, res.Lockouts, 2)
+	require.Equal(t, repository.LoginScopeAddress, res.Lockouts[0].Scope)
+	require.Equal(t, "192.0.2.1", res.Lockouts[0].ClientIP)
+	require.Equal(t, 5, res.Lockouts[0].Failures)
+	require.Equal(t, repository.LoginScopeUsername, res.Lockouts[1].Scope)
+}
+
 // TestRefresh tests refreshToken route handler
 func TestRefresh(t *testing.T) {
 	randomUser, _ := randomUser(t)
@@ -462,7 +741,7 @@ func TestRefresh(t *testing.T) {
 		name          string
 		req           *http.Request
 		setupAuth     func(t *testing.T, request *http.Request)
-		buildStubs    func(tokenMaker *mockmaker.MockMaker)
+		buildStubs    func(repo *mockdb.MockRepository, tokenMaker *mockmaker.MockMaker)
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{
 		{
@@ -477,13 +756,18 @@ func TestRefresh(t *testing.T) {
 					testConfigs.RefreshTokenCookiePath(),
 				)
 			},
-			buildStubs: func(tokenMaker *mockmaker.MockMaker) {
+			buildStubs: func(repo *mockdb.MockRepository, tokenMaker *mockmaker.MockMaker) {
 				tokenMaker.
 					EXPECT().
-					VerifyToken(refreshToken).
+					VerifyPurposeToken(refreshToken, refreshTokenPurpose).
 					Times(1).
 					Return(refreshTokenPayload, nil)
 
+				repo.EXPECT().
+					GetSessionByRefreshToken(primaryContext, hashSecretToken(refreshToken)).
+					Times(1).
+					Return(&repository.Session{ID: 1, Username: randomUser.Username}, nil)
+
 				tokenMaker.
 					EXPECT().
 					CreateToken(refreshTokenPayload.Username, testConfigs.AccessTokenDuration()).
@@ -499,7 +783,7 @@ func TestRefresh(t *testing.T) {
 			name: "RefreshTokenNotProvided",
 			setupAuth: func(t *testing.T, request *http.Request) {
 			},
-			buildStubs: func(tokenMaker *mockmaker.MockMaker) {
+			buildStubs: func(repo *mockdb.MockRepository, tokenMaker *mockmaker.MockMaker) {
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
@@ -516,10 +800,10 @@ func TestRefresh(t *testing.T) {
 					Value: refreshToken,
 				})
 			},
-			buildStubs: func(tokenMaker *mockmaker.MockMaker) {
+			buildStubs: func(repo *mockdb.MockRepository, tokenMaker *mockmaker.MockMaker) {
 				tokenMaker.
 					EXPECT().
-					VerifyToken(refreshToken).
+					VerifyPurposeToken(refreshToken, refreshTokenPurpose).
 					Times(1).
 					Return(nil, token.ErrInvalidToken)
 			},
@@ -539,10 +823,10 @@ func TestRefresh(t *testing.T) {
 					testConfigs.RefreshTokenCookiePath(),
 				)
 			},
-			buildStubs: func(tokenMaker *mockmaker.MockMaker) {
+			buildStubs: func(repo *mockdb.MockRepository, tokenMaker *mockmaker.MockMaker) {
 				tokenMaker.
 					EXPECT().
-					VerifyToken(refreshToken).
+					VerifyPurposeToken(refreshToken, refreshTokenPurpose).
 					Times(1).
 					Return(nil, token.ErrExpiredToken)
 			},
@@ -550,6 +834,65 @@ func TestRefresh(t *testing.T) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
 			},
 		},
+		{
+			name: "RevokedSession",
+			setupAuth: func(t *testing.T, request *http.Request) {
+				refreshToken, refreshTokenPayload = addTokenCookie(
+					t,
+					randomUser.Username,
+					request,
+					"refreshToken",
+					testConfigs.RefreshTokenDuration(),
+					testConfigs.RefreshTokenCookiePath(),
+				)
+			},
+			buildStubs: func(repo *mockdb.MockRepository, tokenMaker *mockmaker.MockMaker) {
+				tokenMaker.
+					EXPECT().
+					VerifyPurposeToken(refreshToken, refreshTokenPurpose).
+					Times(1).
+					Return(refreshTokenPayload, nil)
+
+				repo.EXPECT().
+					GetSessionByRefreshToken(gomock.Any(), hashSecretToken(refreshToken)).
+					Times(1).
+					Return(&repository.Session{ID: 1, Username: randomUser.Username, IsBlocked: true}, nil)
+
+				tokenMaker.EXPECT().CreateToken(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.JSONEq(t, `{"error":"revoked refresh token"}`, recorder.Body.String())
+			},
+		},
+		{
+			name: "SessionNotFound",
+			setupAuth: func(t *testing.T, request *http.Request) {
+				refreshToken, refreshTokenPayload = addTokenCookie(
+					t,
+					randomUser.Username,
+					request,
+					"refreshToken",
+					testConfigs.RefreshTokenDuration(),
+					testConfigs.RefreshTokenCookiePath(),
+				)
+			},
+			buildStubs: func(repo *mockdb.MockRepository, tokenMaker *mockmaker.MockMaker) {
+				tokenMaker.
+					EXPECT().
+					VerifyPurposeToken(refreshToken, refreshTokenPurpose).
+					Times(1).
+					Return(refreshTokenPayload, nil)
+
+				repo.EXPECT().
+					GetSessionByRefreshToken(gomock.Any(), hashSecretToken(refreshToken)).
+					Times(1).
+					Return(nil, repository.ErrNotFound)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
 		{
 			name: "InternalServerError",
 			setupAuth: func(t *testing.T, request *http.Request) {
@@ -562,13 +905,18 @@ func TestRefresh(t *testing.T) {
 					testConfigs.RefreshTokenCookiePath(),
 				)
 			},
-			buildStubs: func(tokenMaker *mockmaker.MockMaker) {
+			buildStubs: func(repo *mockdb.MockRepository, tokenMaker *mockmaker.MockMaker) {
 				tokenMaker.
 					EXPECT().
-					VerifyToken(refreshToken).
+					VerifyPurposeToken(refreshToken, refreshTokenPurpose).
 					Times(1).
 					Return(refreshTokenPayload, nil)
 
+				repo.EXPECT().
+					GetSessionByRefreshToken(gomock.Any(), hashSecretToken(refreshToken)).
+					Times(1).
+					Return(&repository.Session{ID: 1, Username: randomUser.Username}, nil)
+
 				tokenMaker.
 					EXPECT().
 					CreateToken(refreshTokenPayload.Username, testConfigs.AccessTokenDuration()).
@@ -587,6 +935,7 @@ func TestRefresh(t *testing.T) {
 			controller := gomock.NewController(t)
 			defer controller.Finish()
 
+			repo := mockdb.NewMockRepository(controller)
 			tokenMaker := mockmaker.NewMockMaker(controller)
 
 			accessToken, accessTokenPayload = createToken(
@@ -600,9 +949,9 @@ func TestRefresh(t *testing.T) {
 
 			testCase.setupAuth(t, req)
 
-			testCase.buildStubs(tokenMaker)
+			testCase.buildStubs(repo, tokenMaker)
 
-			server := NewTestServer(t, nil, tokenMaker)
+			server := NewTestServer(t, repo, tokenMaker)
 
 			recorder := httptest.NewRecorder()
 			server.router.ServeHTTP(recorder, req)
@@ -613,53 +962,1813 @@ func TestRefresh(t *testing.T) {
 	}
 }
 
-// randomUser creates a random user
-func randomUser(t *testing.T) (*repository.User, string) {
-	password := util.RandomPassword()
-	hashedPassword, err := util.HashPassword(password)
-	require.NoError(t, err)
+// TestReady tests the readiness probe
+func TestReady(t *testing.T) {
+	testCases := []struct {
+		name          string
+		pingErr       error
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:    "DBUnavailable",
+			pingErr: fmt.Errorf("%w: connection refused", repository.ErrUnavailable),
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+			},
+		},
+	}
 
-	return &repository.User{
-		Username: util.RandomUsername(),
-		Password: hashedPassword,
-	}, password
-}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
 
-// createToken creates a token and returns it with its payload
-func createToken(t *testing.T, username string, duration time.Duration) (string, *token.Payload) {
-	tokenMaker, err := token.NewPasetoMaker(testConfigs.TokenSymmetricKey())
-	require.NoError(t, err)
+			repo := mockdb.NewMockRepository(controller)
+			repo.EXPECT().Ping(gomock.Any()).Times(1).Return(testCase.pingErr)
 
-	accessToken, payload, err := tokenMaker.CreateToken(username, duration)
-	require.NoError(t, err)
-	require.NotEmpty(t, accessToken)
-	require.NotEmpty(t, payload)
+			server := NewTestServer(t, repo, nil)
 
-	require.Equal(t, username, payload.Username)
-	require.WithinDuration(t, time.Now().Add(duration), payload.ExpiredAt, time.Second)
-	require.WithinDuration(t, time.Now(), payload.IssuedAt, time.Second)
+			req, err := http.NewRequest(http.MethodGet, "/api/ready", nil)
+			require.NoError(t, err)
 
-	return accessToken, payload
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, req)
+
+			testCase.checkResponse(t, recorder)
+		})
+	}
 }
 
-// checkLoginResponse checks login response
-func checkLoginResponse(t *testing.T, username, accessToken, refreshToken string, recorder *httptest.ResponseRecorder) {
-	cookies := recorder.Result().Cookies()
+// TestSearchMessages tests the message search handler
+func TestSearchMessages(t *testing.T) {
+	randomUser, _ := randomUser(t)
+
+	// matches returns n message matches with descending ids starting from the input id
+	matches := func(id uint, n int) []*repository.MessageMatch {
+		result := make([]*repository.MessageMatch, n)
+		for i := range result {
+			result[i] = &repository.MessageMatch{
+				Message: repository.Message{ID: id - uint(i), Author: randomUser.Username, Text: "hello", Room: repository.DefaultRoom},
+				Snippet: repository.SnippetMatchStart + "hello" + repository.SnippetMatchEnd,
+			}
+		}
+		return result
+	}
+
+	testCases := []struct {
+		name          string
+		query         string
+		authorized    bool
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:       "OK",
+			query:      "?q=hello&author=" + randomUser.Username + "&room=general&from=2024-01-01T00:00:00Z&limit=2",
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					SearchMessages(gomock.Any(), gomock.Eq(&repository.MessageSearch{
+						Text:   "hello",
+						Author: randomUser.Username,
+						Room:   repository.DefaultRoom,
+						Since:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+						Limit:  3,
+					})).
+					Times(1).
+					Return(matches(10, 3), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res SearchMessagesResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Len(t, res.Results, 2)
+				require.Equal(t, uint(10), res.Results[0].ID)
+				require.Equal(t, "<mark>hello</mark>", res.Results[0].Snippet)
+
+				beforeID, err := decodeCursor(res.NextCursor)
+				require.NoError(t, err)
+				require.Equal(t, uint(9), beforeID)
+			},
+		},
+		{
+			name:       "LastPage",
+			query:      "?q=hello&cursor=" + encodeCursor(9),
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					SearchMessages(gomock.Any(), gomock.Eq(&repository.MessageSearch{
+						Text:     "hello",
+						BeforeID: 9,
+						Limit:    defaultSearchLimit + 1,
+					})).
+					Times(1).
+					Return(matches(8, 1), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res SearchMessagesResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Len(t, res.Results, 1)
+				require.Empty(t, res.NextCursor)
+			},
+		},
+		{
+			name:       "SnippetEscaped",
+			query:      "?q=script",
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					SearchMessages(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]*repository.MessageMatch{{
+						Message: repository.Message{ID: 1, Text: "<script>alert(1)</script>"},
+						Snippet: "<" + repository.SnippetMatchStart + "script" + repository.SnippetMatchEnd + ">alert(1)</script>",
+					}}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res SearchMessagesResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Equal(t, "&lt;<mark>script</mark>&gt;alert(1)&lt;/script&gt;", res.Results[0].Snippet)
+			},
+		},
+		{
+			name:       "QueryNotProvided",
+			query:      "?author=" + randomUser.Username,
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:       "InvalidCursor",
+			query:      "?q=hello&cursor=invalid",
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:       "InvalidDate",
+			query:      "?q=hello&from=yesterday",
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:       "Unauthorized",
+			query:      "?q=hello",
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:       "DBUnavailable",
+			query:      "?q=hello",
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					SearchMessages(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, fmt.Errorf("%w: connection refused", repository.ErrUnavailable))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			repo := mockdb.NewMockRepository(controller)
+			testCase.buildStubs(repo)
+
+			tokenMaker, err := token.NewPasetoMaker(testConfigs.TokenSymmetricKey())
+			require.NoError(t, err)
+
+			server := NewTestServer(t, repo, tokenMaker)
+
+			req, err := http.NewRequest(http.MethodGet, "/api/messages/search"+testCase.query, nil)
+			require.NoError(t, err)
+
+			if testCase.authorized {
+				addTokenCookie(t, randomUser.Username, req, authorizationCookieName, time.Minute, "/")
+			}
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, req)
+
+			testCase.checkResponse(t, recorder)
+		})
+	}
+}
+
+// TestGetNotifications tests the handler listing the notifications of the user
+func TestGetNotifications(t *testing.T) {
+	randomUser, _ := randomUser(t)
+
+	// notifications returns n notifications of the user with descending ids starting from the input id
+	notifications := func(id uint, n int) []*repository.Notification {
+		result := make([]*repository.Notification, n)
+		for i := range result {
+			result[i] = &repository.Notification{
+				ID:        id - uint(i),
+				Username:  randomUser.Username,
+				MessageID: 100 + id - uint(i),
+				Author:    "author",
+				Room:      repository.DefaultRoom,
+				Text:      "hi @" + randomUser.Username,
+			}
+		}
+		return result
+	}
+
+	testCases := []struct {
+		name          string
+		query         string
+		authorized    bool
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:       "OK",
+			query:      "?unread=true&limit=2",
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					GetNotifications(gomock.Any(), gomock.Eq(&repository.NotificationQuery{
+						Username:   randomUser.Username,
+						UnreadOnly: true,
+						Limit:      3,
+					})).
+					Times(1).
+					Return(notifications(10, 3), nil)
+				repo.EXPECT().CountUnreadNotifications(gomock.Any(), randomUser.Username).Times(1).Return(int64(7), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res NotificationsResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Len(t, res.Notifications, 2)
+				require.Equal(t, uint(10), res.Notifications[0].ID)
+				require.Equal(t, uint(110), res.Notifications[0].MessageID)
+				require.Equal(t, "author", res.Notifications[0].Author)
+				require.False(t, res.Notifications[0].Read)
+				require.Equal(t, int64(7), res.UnreadCount)
+
+				beforeID, err := decodeCursor(res.NextCursor)
+				require.NoError(t, err)
+				require.Equal(t, uint(9), beforeID)
+			},
+		},
+		{
+			name:       "LastPage",
+			query:      "?cursor=" + encodeCursor(9),
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					GetNotifications(gomock.Any(), gomock.Eq(&repository.NotificationQuery{
+						Username: randomUser.Username,
+						BeforeID: 9,
+						Limit:    defaultNotificationsLimit + 1,
+					})).
+					Times(1).
+					Return(notifications(8, 1), nil)
+				repo.EXPECT().CountUnreadNotifications(gomock.Any(), randomUser.Username).Times(1).Return(int64(1), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res NotificationsResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Len(t, res.Notifications, 1)
+				require.Empty(t, res.NextCursor)
+			},
+		},
+		{
+			name:       "InvalidLimit",
+			query:      "?limit=1000",
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:       "InvalidCursor",
+			query:      "?cursor=invalid",
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:       "Unauthorized",
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:       "DBUnavailable",
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					GetNotifications(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, fmt.Errorf("%w: connection refused", repository.ErrUnavailable))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			repo := mockdb.NewMockRepository(controller)
+			testCase.buildStubs(repo)
+
+			tokenMaker, err := token.NewPasetoMaker(testConfigs.TokenSymmetricKey())
+			require.NoError(t, err)
+
+			server := NewTestServer(t, repo, tokenMaker)
+
+			req, err := http.NewRequest(http.MethodGet, "/api/notifications"+testCase.query, nil)
+			require.NoError(t, err)
+
+			if testCase.authorized {
+				addTokenCookie(t, randomUser.Username, req, authorizationCookieName, time.Minute, "/")
+			}
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, req)
+
+			testCase.checkResponse(t, recorder)
+		})
+	}
+}
+
+// TestMarkNotificationsRead tests the handler marking the notifications of the user as read
+func TestMarkNotificationsRead(t *testing.T) {
+	randomUser, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		body          string
+		authorized    bool
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:       "IDs",
+			body:       `{"ids":[3,5]}`,
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().MarkNotificationsRead(gomock.Any(), randomUser.Username, []uint{3, 5}).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name:       "All",
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().MarkNotificationsRead(gomock.Any(), randomUser.Username, gomock.Nil()).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name:       "InvalidBody",
+			body:       `{"ids":["first"]}`,
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:       "Unauthorized",
+			body:       `{"ids":[3]}`,
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:       "InternalError",
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().MarkNotificationsRead(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			repo := mockdb.NewMockRepository(controller)
+			testCase.buildStubs(repo)
+
+			tokenMaker, err := token.NewPasetoMaker(testConfigs.TokenSymmetricKey())
+			require.NoError(t, err)
+
+			server := NewTestServer(t, repo, tokenMaker)
+
+			req, err := http.NewRequest(http.MethodPost, "/api/notifications/read", bytes.NewBufferString(testCase.body))
+			require.NoError(t, err)
+
+			if testCase.authorized {
+				addTokenCookie(t, randomUser.Username, req, authorizationCookieName, time.Minute, "/")
+			}
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, req)
+
+			testCase.checkResponse(t, recorder)
+		})
+	}
+}
+
+// pngContent is the content of an uploaded 4x3 png file, its content type is detected from its signature
+var pngContent = encodeTestPNG(4, 3)
+
+// pngWithMetadata is pngContent with a text chunk holding the location the image was taken at
+var pngWithMetadata = insertPNGChunk(pngContent, "tEXt", "Location\x0035.6892,51.3890")
+
+// encodeTestPNG encodes a blank png image of the input dimensions
+func encodeTestPNG(width, height int) []byte {
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		panic(err)
+	}
+	return buffer.Bytes()
+}
+
+// insertPNGChunk returns the input png with a chunk of the input type and data inserted after its header chunk
+func insertPNGChunk(content []byte, chunkType, data string) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, chunkType+data...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE([]byte(chunkType+data)))
+
+	// the signature and the header chunk
+	headerEnd := 8 + 25
+	return slices.Concat(content[:headerEnd], chunk, content[headerEnd:])
+}
+
+// newUploadRequest returns a request uploading the input content as the "file" field of a multipart form
+func newUploadRequest(t *testing.T, filename string, content []byte) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	part, err := writer.CreateFormFile("file", filename)
+	require.NoError(t, err)
+	_, err = part.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req, err := http.NewRequest(http.MethodPost, "/api/attachments", &body)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	return req
+}
+
+// TestUploadAttachment tests the attachment upload handler
+func TestUploadAttachment(t *testing.T) {
+	randomUser, _ := randomUser(t)
+	checksum := sha256.Sum256(pngContent)
+
+	testCases := []struct {
+		name          string
+		request       func(t *testing.T) *http.Request
+		authorized    bool
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(t *testing.T, server *server, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:       "OK",
+			request:    func(t *testing.T) *http.Request { return newUploadRequest(t, "../photo.png", pngWithMetadata) },
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					AddAttachment(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, attachment *repository.Attachment) (*repository.Attachment, error) {
+						require.NotEmpty(t, attachment.ID)
+						require.Equal(t, randomUser.Username, attachment.Owner)
+						require.Equal(t, "photo.png", attachment.Filename)
+						require.Equal(t, "image/png", attachment.ContentType)
+						require.Equal(t, int64(len(pngContent)), attachment.Size)
+						require.Equal(t, hex.EncodeToString(checksum[:]), attachment.Checksum)
+						require.Equal(t, 4, attachment.Width)
+						require.Equal(t, 3, attachment.Height)
+						return attachment, nil
+					})
+				repo.EXPECT().
+					SetAttachmentThumbnail(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, id string, thumbnail *repository.Thumbnail) (*repository.Attachment, error) {
+						require.Equal(t, "image/png", thumbnail.ContentType)
+						require.Equal(t, 4, thumbnail.Width)
+						require.Equal(t, 3, thumbnail.Height)
+						return &repository.Attachment{ID: id, Thumbnail: thumbnail}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, server *server, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+
+				var res AttachmentResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Equal(t, "/api/attachments/"+res.ID, res.URL)
+				require.Equal(t, hex.EncodeToString(checksum[:]), res.Checksum)
+				require.Equal(t, 4, res.Width)
+				require.Equal(t, 3, res.Height)
+
+				// the file is in the blob store without its metadata
+				content, err := server.blobStore.Get(context.Background(), media.AttachmentKey(res.ID))
+				require.NoError(t, err)
+				defer content.Close()
+				stored, err := io.ReadAll(content)
+				require.NoError(t, err)
+				require.Equal(t, pngContent, stored)
+
+				// the thumbnail is generated in the background
+				require.NoError(t, server.thumbnails.Close(context.Background()))
+				thumbnail, err := server.blobStore.Get(context.Background(), media.ThumbnailKey(res.ID))
+				require.NoError(t, err)
+				thumbnail.Close()
+			},
+		},
+		{
+			name: "InvalidImage",
+			request: func(t *testing.T) *http.Request {
+				return newUploadRequest(t, "photo.png", append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 64)...))
+			},
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, server *server, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "TypeNotAllowed",
+			request: func(t *testing.T) *http.Request {
+				// the content type of the form is not trusted, the content is not an image
+				return newUploadRequest(t, "photo.png", []byte("MZ\x90\x00\x03\x00\x00\x00\x04\x00"))
+			},
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, server *server, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnsupportedMediaType, recorder.Code)
+			},
+		},
+		{
+			name: "TooLarge",
+			request: func(t *testing.T) *http.Request {
+				return newUploadRequest(t, "photo.png", append(pngContent, make([]byte, testConfigs.AttachmentMaxSize())...))
+			},
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, server *server, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+			},
+		},
+		{
+			name: "NoFile",
+			request: func(t *testing.T) *http.Request {
+				req, err := http.NewRequest(http.MethodPost, "/api/attachments", nil)
+				require.NoError(t, err)
+				return req
+			},
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, server *server, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:       "Unauthorized",
+			request:    func(t *testing.T) *http.Request { return newUploadRequest(t, "photo.png", pngContent) },
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, server *server, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:       "DBUnavailable",
+			request:    func(t *testing.T) *http.Request { return newUploadRequest(t, "photo.png", pngContent) },
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					AddAttachment(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, fmt.Errorf("%w: connection refused", repository.ErrUnavailable))
+			},
+			checkResponse: func(t *testing.T, server *server, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+				// the file of the attachment which could not be saved is deleted
+				entries, err := os.ReadDir(server.blobStore.(*storage.FileStore).Root())
+				require.NoError(t, err)
+				for _, entry := range entries {
+					files, err := os.ReadDir(filepath.Join(server.blobStore.(*storage.FileStore).Root(), entry.Name()))
+					require.NoError(t, err)
+					require.Empty(t, files)
+				}
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			repo := mockdb.NewMockRepository(controller)
+			testCase.buildStubs(repo)
+
+			tokenMaker, err := token.NewPasetoMaker(testConfigs.TokenSymmetricKey())
+			require.NoError(t, err)
+
+			server := NewTestServer(t, repo, tokenMaker)
+
+			req := testCase.request(t)
+			if testCase.authorized {
+				addTokenCookie(t, randomUser.Username, req, authorizationCookieName, time.Minute, "/")
+			}
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, req)
+
+			testCase.checkResponse(t, server, recorder)
+		})
+	}
+}
+
+// TestDownloadAttachment tests the attachment download handler
+func TestDownloadAttachment(t *testing.T) {
+	randomUser, _ := randomUser(t)
+	checksum := sha256.Sum256(pngContent)
+
+	// attachment returns an attachment of the input owner attached to the input message
+	attachment := func(owner string, messageID uint) *repository.Attachment {
+		return &repository.Attachment{
+			ID:          "attachment",
+			Owner:       owner,
+			MessageID:   messageID,
+			Filename:    "my photo.png",
+			ContentType: "image/png",
+			Size:        int64(len(pngContent)),
+			Checksum:    hex.EncodeToString(checksum[:]),
+		}
+	}
+
+	testCases := []struct {
+		name          string
+		header        http.Header
+		authorized    bool
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:       "OK",
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					GetAttachment(gomock.Any(), gomock.Eq("attachment")).
+					Times(1).
+					Return(attachment("other", 1), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, pngContent, recorder.Body.Bytes())
+				require.Equal(t, "image/png", recorder.Header().Get("Content-Type"))
+				require.Equal(t, `inline; filename="my photo.png"`, recorder.Header().Get("Content-Disposition"))
+				require.Equal(t, "nosniff", recorder.Header().Get("X-Content-Type-Options"))
+				require.Equal(t, `"`+hex.EncodeToString(checksum[:])+`"`, recorder.Header().Get("ETag"))
+			},
+		},
+		{
+			name:       "NotModified",
+			header:     http.Header{"If-None-Match": {`"` + hex.EncodeToString(checksum[:]) + `"`}},
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					GetAttachment(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(attachment("other", 1), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotModified, recorder.Code)
+				require.Empty(t, recorder.Body.Bytes())
+			},
+		},
+		{
+			name:       "UnattachedOwner",
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					GetAttachment(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(attachment(randomUser.Username, 0), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:       "UnattachedOtherUser",
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					GetAttachment(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(attachment("other", 0), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:       "NotFound",
+			authorized: true,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().
+					GetAttachment(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, repository.ErrNotFound)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:       "Unauthorized",
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			repo := mockdb.NewMockRepository(controller)
+			testCase.buildStubs(repo)
+
+			tokenMaker, err := token.NewPasetoMaker(testConfigs.TokenSymmetricKey())
+			require.NoError(t, err)
+
+			server := NewTestServer(t, repo, tokenMaker)
+			err = server.blobStore.Put(context.Background(), media.AttachmentKey("attachment"), bytes.NewReader(pngContent), int64(len(pngContent)), "image/png")
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodGet, "/api/attachments/attachment", nil)
+			require.NoError(t, err)
+			for name, values := range testCase.header {
+				req.Header[name] = values
+			}
+
+			if testCase.authorized {
+				addTokenCookie(t, randomUser.Username, req, authorizationCookieName, time.Minute, "/")
+			}
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, req)
+
+			testCase.checkResponse(t, recorder)
+		})
+	}
+}
+
+// TestDownloadThumbnail tests the thumbnail download handler
+func TestDownloadThumbnail(t *testing.T) {
+	randomUser, _ := randomUser(t)
+	thumbnailContent := encodeTestPNG(2, 1)
+
+	// attachment returns an attachment of the input owner attached to the input message with the input thumbnail
+	attachment := func(owner string, messageID uint, thumbnail *repository.Thumbnail) *repository.Attachment {
+		return &repository.Attachment{
+			ID:          "attachment",
+			Owner:       owner,
+			MessageID:   messageID,
+			Filename:    "photo.png",
+			ContentType: "image/png",
+			Size:        int64(len(pngContent)),
+			Checksum:    "checksum",
+			Width:       4,
+			Height:      3,
+			Thumbnail:   thumbnail,
+		}
+	}
+	thumbnail := &repository.Thumbnail{ContentType: "image/png", Width: 2, Height: 1, Size: int64(len(thumbnailContent))}
+
+	testCases := []struct {
+		name          string
+		attachment    *repository.Attachment
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:       "OK",
+			attachment: attachment("other", 1, thumbnail),
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, thumbnailContent, recorder.Body.Bytes())
+				require.Equal(t, "image/png", recorder.Header().Get("Content-Type"))
+				require.Equal(t, "inline", recorder.Header().Get("Content-Disposition"))
+				require.Equal(t, `"checksum-thumbnail"`, recorder.Header().Get("ETag"))
+			},
+		},
+		{
+			name:       "NotGenerated",
+			attachment: attachment("other", 1, nil),
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:       "UnattachedOtherUser",
+			attachment: attachment("other", 0, thumbnail),
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			repo := mockdb.NewMockRepository(controller)
+			repo.EXPECT().
+				GetAttachment(gomock.Any(), gomock.Eq("attachment")).
+				Times(1).
+				Return(testCase.attachment, nil)
+
+			tokenMaker, err := token.NewPasetoMaker(testConfigs.TokenSymmetricKey())
+			require.NoError(t, err)
+
+			server := NewTestServer(t, repo, tokenMaker)
+			err = server.blobStore.Put(context.Background(), media.ThumbnailKey("attachment"), bytes.NewReader(thumbnailContent), int64(len(thumbnailContent)), "image/png")
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodGet, "/api/attachments/attachment/thumbnail", nil)
+			require.NoError(t, err)
+			addTokenCookie(t, randomUser.Username, req, authorizationCookieName, time.Minute, "/")
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, req)
+
+			testCase.checkResponse(t, recorder)
+		})
+	}
+}
+
+// TestCreateWebhook tests the route registering a webhook
+func TestCreateWebhook(t *testing.T) {
+	randomUser, _ := randomUser(t)
+	createdAt := time.Now().UTC()
+
+	testCases := []struct {
+		name          string
+		body          string
+		username      string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			body:     `{"url":"https://example.com/hooks","events":["user.joined","message.created","user.joined"]}`,
+			username: testAdminUsername,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().AddWebhook(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ context.Context, webhook *repository.Webhook) (*repository.Webhook, error) {
+						// the events are deduplicated and the secret is generated
+						require.Equal(t, []string{"message.created", "user.joined"}, webhook.Events)
+						require.Len(t, webhook.Secret, 64)
+						require.Equal(t, testAdminUsername, webhook.CreatedBy)
+
+						saved := *webhook
+						saved.ID = 7
+						saved.CreatedAt = createdAt
+						return &saved, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+
+				var res WebhookResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Equal(t, uint(7), res.ID)
+				require.Equal(t, "https://example.com/hooks", res.URL)
+				require.Equal(t, []string{"message.created", "user.joined"}, res.Events)
+				require.Equal(t, testAdminUsername, res.CreatedBy)
+				require.False(t, res.Disabled)
+				require.Len(t, res.Secret, 64)
+			},
+		},
+		{
+			name:       "InvalidURL",
+			body:       `{"url":"ftp://example.com/hooks","events":["user.joined"]}`,
+			username:   testAdminUsername,
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:       "InvalidEvent",
+			body:       `{"url":"https://example.com/hooks","events":["user.deleted"]}`,
+			username:   testAdminUsername,
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:       "NoEvents",
+			body:       `{"url":"https://example.com/hooks","events":[]}`,
+			username:   testAdminUsername,
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:       "NotAdmin",
+			body:       `{"url":"https://example.com/hooks","events":["user.joined"]}`,
+			username:   randomUser.Username,
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:       "Unauthorized",
+			body:       `{"url":"https://example.com/hooks","events":["user.joined"]}`,
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "Unavailable",
+			body:     `{"url":"https://example.com/hooks","events":["user.joined"]}`,
+			username: testAdminUsername,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().AddWebhook(gomock.Any(), gomock.Any()).Times(1).Return(nil, repository.ErrUnavailable)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			repo := mockdb.NewMockRepository(controller)
+			testCase.buildStubs(repo)
+
+			recorder := serveAdminRequest(t, repo, testCase.username, http.MethodPost, "/api/admin/webhooks", testCase.body)
+			testCase.checkResponse(t, recorder)
+		})
+	}
+}
+
+// TestGetWebhooks tests the route listing the webhooks
+func TestGetWebhooks(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	repo := mockdb.NewMockRepository(controller)
+	repo.EXPECT().GetWebhooks(gomock.Any()).Times(1).Return([]*repository.Webhook{
+		{ID: 1, URL: "https://example.com/a", Secret: "secret", Events: []string{"user.joined"}, CreatedBy: testAdminUsername},
+		{ID: 2, URL: "https://example.com/b", Secret: "secret", Events: []string{"message.created"}, Failures: 10, Disabled: true},
+	}, nil)
+
+	recorder := serveAdminRequest(t, repo, testAdminUsername, http.MethodGet, "/api/admin/webhooks", "")
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	// the secrets are never listed
+	require.NotContains(t, recorder.Body.String(), "secret")
+
+	var res WebhooksResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+	require.Len(t, res.Webhooks, 2)
+	require.Equal(t, "https://example.com/a", res.Webhooks[0].URL)
+	require.True(t, res.Webhooks[1].Disabled)
+	require.Equal(t, 10, res.Webhooks[1].Failures)
+}
+
+// TestDeleteWebhook tests the route deleting a webhook
+func TestDeleteWebhook(t *testing.T) {
+	testCases := []struct {
+		name       string
+		path       string
+		buildStubs func(repo *mockdb.MockRepository)
+		code       int
+	}{
+		{
+			name: "OK",
+			path: "/api/admin/webhooks/3",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().DeleteWebhook(gomock.Any(), uint(3)).Times(1).Return(nil)
+			},
+			code: http.StatusNoContent,
+		},
+		{
+			name: "NotFound",
+			path: "/api/admin/webhooks/3",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().DeleteWebhook(gomock.Any(), uint(3)).Times(1).Return(repository.ErrNotFound)
+			},
+			code: http.StatusNotFound,
+		},
+		{
+			name:       "InvalidID",
+			path:       "/api/admin/webhooks/first",
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			code:       http.StatusBadRequest,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			repo := mockdb.NewMockRepository(controller)
+			testCase.buildStubs(repo)
+
+			recorder := serveAdminRequest(t, repo, testAdminUsername, http.MethodDelete, testCase.path, "")
+			require.Equal(t, testCase.code, recorder.Code)
+		})
+	}
+}
+
+// TestEnableWebhook tests the route enabling a disabled webhook
+func TestEnableWebhook(t *testing.T) {
+	testCases := []struct {
+		name       string
+		buildStubs func(repo *mockdb.MockRepository)
+		code       int
+	}{
+		{
+			name: "OK",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().EnableWebhook(gomock.Any(), uint(3)).Times(1).
+					Return(&repository.Webhook{ID: 3, URL: "https://example.com/a", Events: []string{"user.joined"}}, nil)
+			},
+			code: http.StatusOK,
+		},
+		{
+			name: "NotFound",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().EnableWebhook(gomock.Any(), uint(3)).Times(1).Return(nil, repository.ErrNotFound)
+			},
+			code: http.StatusNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			repo := mockdb.NewMockRepository(controller)
+			testCase.buildStubs(repo)
+
+			recorder := serveAdminRequest(t, repo, testAdminUsername, http.MethodPost, "/api/admin/webhooks/3/enable", "")
+			require.Equal(t, testCase.code, recorder.Code)
+		})
+	}
+}
+
+// TestGetWebhookDeliveries tests the route listing the delivery attempts of a webhook
+func TestGetWebhookDeliveries(t *testing.T) {
+	testCases := []struct {
+		name          string
+		query         string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: "?limit=2",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetWebhook(gomock.Any(), uint(3)).Times(1).Return(&repository.Webhook{ID: 3}, nil)
+				repo.EXPECT().GetWebhookDeliveries(gomock.Any(), uint(3), 2).Times(1).Return([]*repository.WebhookDelivery{
+					{ID: 2, WebhookID: 3, EventID: "event", EventType: "user.joined", Attempt: 2, StatusCode: 200, Duration: 1500 * time.Millisecond},
+					{ID: 1, WebhookID: 3, EventID: "event", EventType: "user.joined", Attempt: 1, StatusCode: 502, Error: "unexpected status 502"},
+				}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res WebhookDeliveriesResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Len(t, res.Deliveries, 2)
+				require.Equal(t, int64(1500), res.Deliveries[0].DurationMs)
+				require.Empty(t, res.Deliveries[0].Error)
+				require.Equal(t, 502, res.Deliveries[1].StatusCode)
+				require.Equal(t, "unexpected status 502", res.Deliveries[1].Error)
+			},
+		},
+		{
+			name: "DefaultLimit",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetWebhook(gomock.Any(), uint(3)).Times(1).Return(&repository.Webhook{ID: 3}, nil)
+				repo.EXPECT().GetWebhookDeliveries(gomock.Any(), uint(3), defaultDeliveriesLimit).Times(1).Return([]*repository.WebhookDelivery{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.JSONEq(t, `{"deliveries":[]}`, recorder.Body.String())
+			},
+		},
+		{
+			name: "NotFound",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetWebhook(gomock.Any(), uint(3)).Times(1).Return(nil, repository.ErrNotFound)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:       "InvalidLimit",
+			query:      "?limit=1000",
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			repo := mockdb.NewMockRepository(controller)
+			testCase.buildStubs(repo)
+
+			recorder := serveAdminRequest(t, repo, testAdminUsername, http.MethodGet, "/api/admin/webhooks/3/deliveries"+testCase.query, "")
+			testCase.checkResponse(t, recorder)
+		})
+	}
+}
+
+// TestSignup_Webhook tests the event of a signup delivered to a webhook
+func TestSignup_Webhook(t *testing.T) {
+	events := make(chan string, 1)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		events <- string(body)
+	}))
+	defer endpoint.Close()
+
+	repo := memory.NewMemoryRepository()
+	_, err := repo.AddUser(context.Background(), &repository.User{Username: testAdminUsername, Password: "password"})
+	require.NoError(t, err)
+	_, err = repo.AddWebhook(context.Background(), &repository.Webhook{
+		URL:       endpoint.URL,
+		Events:    []string{webhook.EventUserSignedUp},
+		CreatedBy: testAdminUsername,
+	})
+	require.NoError(t, err)
+
+	tokenMaker, err := token.NewPasetoMaker(testConfigs.TokenSymmetricKey())
+	require.NoError(t, err)
+
+	server := NewTestServer(t, repo, tokenMaker)
+	server.webhooks = webhook.New(repo, webhook.Config{AllowPrivateNetworks: true})
+
+	randomUser, password := randomUser(t)
+	body, err := json.Marshal(SignupRequest{Username: randomUser.Username, Password: password})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, "/api/signup", bytes.NewReader(body))
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.NoError(t, server.webhooks.Close(context.Background()))
+
+	var event webhook.Event
+	require.NoError(t, json.Unmarshal([]byte(<-events), &event))
+	require.Equal(t, webhook.EventUserSignedUp, event.Type)
+	require.Equal(t, map[string]any{"username": randomUser.Username}, event.Data)
+}
+
+// TestCreateIncomingHook tests the route creating an incoming hook
+func TestCreateIncomingHook(t *testing.T) {
+	randomUser, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		body          string
+		username      string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			body:     `{"name":"CI","username":"ci_bot"}`,
+			username: testAdminUsername,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().AddIncomingHook(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ context.Context, hook *repository.IncomingHook) (*repository.IncomingHook, error) {
+						require.Equal(t, "CI", hook.Name)
+						require.Equal(t, "ci_bot", hook.Username)
+						require.Equal(t, testAdminUsername, hook.CreatedBy)
+						require.Len(t, hook.TokenHash, 64)
+
+						saved := *hook
+						saved.ID = 5
+						return &saved, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+
+				var res IncomingHookResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Equal(t, uint(5), res.ID)
+				require.Equal(t, "ci_bot", res.Username)
+				require.Regexp(t, `^/api/hooks/[A-Za-z0-9_-]{43}$`, res.URL)
+			},
+		},
+		{
+			name:     "UsernameExists",
+			body:     `{"name":"CI","username":"ci_bot"}`,
+			username: testAdminUsername,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().AddIncomingHook(gomock.Any(), gomock.Any()).Times(1).Return(nil, repository.ErrUserExists)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name:     "AdminUsername",
+			body:     `{"name":"CI","username":"` + testAdminUsername + `"}`,
+			username: testAdminUsername,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().AddIncomingHook(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+				require.JSONEq(t, `{"error":"username is reserved"}`, recorder.Body.String())
+			},
+		},
+		{
+			name:       "InvalidUsername",
+			body:       `{"name":"CI","username":"ci bot"}`,
+			username:   testAdminUsername,
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:       "NotAdmin",
+			body:       `{"name":"CI","username":"ci_bot"}`,
+			username:   randomUser.Username,
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:     "Unavailable",
+			body:     `{"name":"CI","username":"ci_bot"}`,
+			username: testAdminUsername,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().AddIncomingHook(gomock.Any(), gomock.Any()).Times(1).Return(nil, repository.ErrUnavailable)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			repo := mockdb.NewMockRepository(controller)
+			testCase.buildStubs(repo)
+
+			recorder := serveAdminRequest(t, repo, testCase.username, http.MethodPost, "/api/admin/hooks", testCase.body)
+			testCase.checkResponse(t, recorder)
+		})
+	}
+}
+
+// TestGetIncomingHooks tests the route listing the incoming hooks
+func TestGetIncomingHooks(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	repo := mockdb.NewMockRepository(controller)
+	repo.EXPECT().GetIncomingHooks(gomock.Any()).Times(1).Return([]*repository.IncomingHook{
+		{ID: 1, TokenHash: "token_hash", Name: "CI", Username: "ci_bot", CreatedBy: testAdminUsername},
+		{ID: 2, TokenHash: "token_hash", Name: "Alerts", Username: "alerts_bot", Revoked: true},
+	}, nil)
+
+	recorder := serveAdminRequest(t, repo, testAdminUsername, http.MethodGet, "/api/admin/hooks", "")
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	// the urls of the hooks are never listed
+	require.NotContains(t, recorder.Body.String(), "token_hash")
+	require.NotContains(t, recorder.Body.String(), "url")
+
+	var res IncomingHooksResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+	require.Len(t, res.Hooks, 2)
+	require.Equal(t, "ci_bot", res.Hooks[0].Username)
+	require.True(t, res.Hooks[1].Revoked)
+}
+
+// TestRevokeIncomingHook tests the route revoking an incoming hook
+func TestRevokeIncomingHook(t *testing.T) {
+	testCases := []struct {
+		name       string
+		path       string
+		buildStubs func(repo *mockdb.MockRepository)
+		code       int
+	}{
+		{
+			name: "OK",
+			path: "/api/admin/hooks/4/revoke",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().RevokeIncomingHook(gomock.Any(), uint(4)).Times(1).
+					Return(&repository.IncomingHook{ID: 4, Name: "CI", Username: "ci_bot", Revoked: true}, nil)
+			},
+			code: http.StatusOK,
+		},
+		{
+			name: "NotFound",
+			path: "/api/admin/hooks/4/revoke",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().RevokeIncomingHook(gomock.Any(), uint(4)).Times(1).Return(nil, repository.ErrNotFound)
+			},
+			code: http.StatusNotFound,
+		},
+		{
+			name:       "InvalidID",
+			path:       "/api/admin/hooks/first/revoke",
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			code:       http.StatusBadRequest,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			repo := mockdb.NewMockRepository(controller)
+			testCase.buildStubs(repo)
+
+			recorder := serveAdminRequest(t, repo, testAdminUsername, http.MethodPost, testCase.path, "")
+			require.Equal(t, testCase.code, recorder.Code)
+		})
+	}
+}
+
+// TestPostIncomingHook tests posting messages through an incoming hook to a running hub
+func TestPostIncomingHook(t *testing.T) {
+	repo := memory.NewMemoryRepository()
+	_, err := repo.AddUser(context.Background(), &repository.User{Username: testAdminUsername, Password: "password"})
+	require.NoError(t, err)
+
+	tokenMaker, err := token.NewPasetoMaker(testConfigs.TokenSymmetricKey())
+	require.NoError(t, err)
+
+	server := NewTestServer(t, repo, tokenMaker)
+	server.hookLimiter = ratelimit.New(ratelimit.NewMemoryStore(), "hooks", ratelimit.Limit{PerMinute: 1, Burst: 2})
+
+	writer := repository.NewMessageWriter(repo, repository.MessageWriterConfig{QueueSize: 10, BatchSize: 1})
+	defer writer.Close(context.Background())
+	ctx, stopHub := context.WithCancel(context.Background())
+	defer stopHub()
+	go server.chatHub.RunChatHub(ctx, repo, writer)
+
+	serve := func(method, path, body string, admin bool) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		if admin {
+			addTokenCookie(t, testAdminUsername, req, authorizationCookieName, time.Minute, "/")
+		}
+
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	recorder := serve(http.MethodPost, "/api/admin/hooks", `{"name":"CI","username":"ci_bot"}`, true)
+	require.Equal(t, http.StatusCreated, recorder.Code)
+	var hook IncomingHookResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &hook))
+
+	// the message is posted as the bot user and saved like the messages of the clients
+	recorder = serve(http.MethodPost, hook.URL, `{"text":"build **failed**"}`, false)
+	require.Equal(t, http.StatusAccepted, recorder.Code)
+	require.Eventually(t, func() bool {
+		messages, err := repo.GetAllMessages(context.Background())
+		return err == nil && len(messages) == 1 && messages[0].Author == "ci_bot" && messages[0].Text == "build **failed**"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	recorder = serve(http.MethodPost, hook.URL, `{"text":""}`, false)
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	// the burst is used up, the hook waits a minute for its next message
+	recorder = serve(http.MethodPost, hook.URL, `{"text":"build passed"}`, false)
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	require.Equal(t, "60", recorder.Header().Get("Retry-After"))
+
+	recorder = serve(http.MethodPost, "/api/hooks/unknown", `{"text":"build passed"}`, false)
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = serve(http.MethodPost, fmt.Sprintf("/api/admin/hooks/%d/revoke", hook.ID), "", true)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	server.hookLimiter = ratelimit.New(ratelimit.NewMemoryStore(), "hooks", ratelimit.Limit{PerMinute: 1, Burst: 2})
+	recorder = serve(http.MethodPost, hook.URL, `{"text":"build passed"}`, false)
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+// TestCreateBot tests the route creating a bot
+func TestCreateBot(t *testing.T) {
+	randomUser, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		body          string
+		buildStubs    func(repo *mockdb.MockRepository)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: `{"username":"deploy_bot"}`,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().AddBot(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ context.Context, bot *repository.Bot) (*repository.Bot, error) {
+						require.Equal(t, "deploy_bot", bot.Username)
+						require.Equal(t, randomUser.Username, bot.Owner)
+						require.Len(t, bot.TokenHash, 64)
+						return bot, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+
+				var res BotResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Equal(t, "deploy_bot", res.Username)
+				require.Equal(t, randomUser.Username, res.Owner)
+				require.Regexp(t, `^bot_[A-Za-z0-9_-]{43}$`, res.Token)
+			},
+		},
+		{
+			name: "UsernameExists",
+			body: `{"username":"deploy_bot"}`,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().AddBot(gomock.Any(), gomock.Any()).Times(1).Return(nil, repository.ErrUserExists)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			// a bot named after an administrator who has not signed up would get the admin routes
+			name: "AdminUsername",
+			body: `{"username":"` + testAdminUsername + `"}`,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().AddBot(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+				require.JSONEq(t, `{"error":"username is reserved"}`, recorder.Body.String())
+			},
+		},
+		{
+			name:       "InvalidUsername",
+			body:       `{"username":"deploy-bot!"}`,
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "Unavailable",
+			body: `{"username":"deploy_bot"}`,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().AddBot(gomock.Any(), gomock.Any()).Times(1).Return(nil, repository.ErrUnavailable)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			repo := mockdb.NewMockRepository(controller)
+			testCase.buildStubs(repo)
+
+			recorder := serveAdminRequest(t, repo, randomUser.Username, http.MethodPost, "/api/bots", testCase.body)
+			testCase.checkResponse(t, recorder)
+		})
+	}
+}
+
+// TestReplaceBotToken tests the route replacing the API token of a bot
+func TestReplaceBotToken(t *testing.T) {
+	randomUser, _ := randomUser(t)
+
+	testCases := []struct {
+		name       string
+		buildStubs func(repo *mockdb.MockRepository)
+		code       int
+	}{
+		{
+			name: "OK",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				bot := &repository.Bot{Username: "deploy_bot", Owner: randomUser.Username, TokenHash: "token_hash"}
+				repo.EXPECT().GetBot(gomock.Any(), "deploy_bot").Times(1).Return(bot, nil)
+				repo.EXPECT().SetBotToken(gomock.Any(), "deploy_bot", gomock.Not("token_hash")).Times(1).Return(bot, nil)
+			},
+			code: http.StatusOK,
+		},
+		{
+			name: "OtherOwner",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetBot(gomock.Any(), "deploy_bot").Times(1).
+					Return(&repository.Bot{Username: "deploy_bot", Owner: "other_user"}, nil)
+				repo.EXPECT().SetBotToken(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			code: http.StatusNotFound,
+		},
+		{
+			name: "NotFound",
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetBot(gomock.Any(), "deploy_bot").Times(1).Return(nil, repository.ErrNotFound)
+			},
+			code: http.StatusNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			repo := mockdb.NewMockRepository(controller)
+			testCase.buildStubs(repo)
+
+			recorder := serveAdminRequest(t, repo, randomUser.Username, http.MethodPost, "/api/bots/deploy_bot/token", "")
+			require.Equal(t, testCase.code, recorder.Code)
+		})
+	}
+}
+
+// TestBots tests bots created by a user connecting to the hub with their API tokens and replying to commands
+func TestBots(t *testing.T) {
+	repo := memory.NewMemoryRepository()
+	owner, _ := randomUser(t)
+	_, err := repo.AddUser(context.Background(), owner)
+	require.NoError(t, err)
+	_, err = repo.AddMessage(context.Background(), &repository.Message{Author: owner.Username, Text: "!ping before"})
+	require.NoError(t, err)
+
+	tokenMaker, err := token.NewPasetoMaker(testConfigs.TokenSymmetricKey())
+	require.NoError(t, err)
+
+	server := NewTestServer(t, repo, tokenMaker)
+	writer := repository.NewMessageWriter(repo, repository.MessageWriterConfig{QueueSize: 10, BatchSize: 1})
+	defer writer.Close(context.Background())
+	hubContext, stopHub := context.WithCancel(context.Background())
+	defer stopHub()
+	go server.chatHub.RunChatHub(hubContext, repo, writer)
+
+	httpServer := httptest.NewServer(server.router)
+	defer httpServer.Close()
+
+	// the owner creates the bot with their cookie
+	req, err := http.NewRequest(http.MethodPost, "/api/bots", bytes.NewBufferString(`{"username":"ping_bot"}`))
+	require.NoError(t, err)
+	authToken, _ := addTokenCookie(t, owner.Username, req, authorizationCookieName, time.Minute, "/")
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusCreated, recorder.Code)
+	var created BotResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &created))
+
+	// bots cannot log in, nor create bots
+	bot, err := repo.GetUser(context.Background(), "ping_bot")
+	require.NoError(t, err)
+	require.Error(t, util.CheckPassword("", bot.Password))
+
+	req, err = http.NewRequest(http.MethodPost, "/api/bots", bytes.NewBufferString(`{"username":"other_bot"}`))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+created.Token)
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+
+	// the bot replies to the commands sent after it connects
+	client, err := chatbot.Dial(context.Background(), httpServer.URL, created.Token)
+	require.NoError(t, err)
+	require.Equal(t, "ping_bot", client.Username())
+
+	pingBot := chatbot.NewBot(client, "")
+	pingBot.Handle("ping", func(ctx context.Context, command chatbot.Command) (string, error) {
+		return "pong " + command.Args[0], nil
+	})
+	botContext, stopBot := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- pingBot.Run(botContext) }()
+
+	header := http.Header{"Cookie": []string{authorizationCookieName + "=" + authToken}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http")+"/api/chat", header)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var event ws.Event
+	require.NoError(t, conn.ReadJSON(&event))
+	require.Equal(t, "!ping before", event.Message.Text)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("!ping after")))
+	for event.Message == nil || event.Message.Author != "ping_bot" {
+		event = ws.Event{}
+		require.NoError(t, conn.ReadJSON(&event))
+	}
+	require.Equal(t, "pong after", event.Message.Text)
+
+	stopBot()
+	require.ErrorIs(t, <-done, context.Canceled)
+
+	// a replaced token stops working
+	req, err = http.NewRequest(http.MethodPost, "/api/bots/ping_bot/token", nil)
+	require.NoError(t, err)
+	addTokenCookie(t, owner.Username, req, authorizationCookieName, time.Minute, "/")
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	_, err = chatbot.Dial(context.Background(), httpServer.URL, created.Token)
+	require.ErrorIs(t, err, chatbot.ErrUnauthorized)
+
+	// the owner lists their bots
+	req, err = http.NewRequest(http.MethodGet, "/api/bots", nil)
+	require.NoError(t, err)
+	addTokenCookie(t, owner.Username, req, authorizationCookieName, time.Minute, "/")
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var bots BotsResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &bots))
+	require.Len(t, bots.Bots, 1)
+	require.Equal(t, "ping_bot", bots.Bots[0].Username)
+	require.Empty(t, bots.Bots[0].Token)
+
+	// bots never get the admin routes, even a bot named after an administrator, e.g. created before the
+	// administrator usernames were reserved
+	adminBotToken, err := newSecretToken(apiTokenPrefix)
+	require.NoError(t, err)
+	_, err = repo.AddBot(context.Background(), &repository.Bot{
+		Username:  testAdminUsername,
+		Owner:     owner.Username,
+		TokenHash: hashSecretToken(adminBotToken),
+	})
+	require.NoError(t, err)
+	req, err = http.NewRequest(http.MethodGet, "/api/admin/hooks", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+adminBotToken)
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+}
+
+// serveAdminRequest serves a request of the input user with the input method, path and body by a test server
+// of the input repository
+func serveAdminRequest(t *testing.T, repo repository.Repository, username, method, path, body string) *httptest.ResponseRecorder {
+	tokenMaker, err := token.NewPasetoMaker(testConfigs.TokenSymmetricKey())
+	require.NoError(t, err)
+
+	server := NewTestServer(t, repo, tokenMaker)
+
+	req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+	require.NoError(t, err)
+
+	if username != "" {
+		addTokenCookie(t, username, req, authorizationCookieName, time.Minute, "/")
+	}
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+// randomUser creates a random user
+func randomUser(t *testing.T) (*repository.User, string) {
+	password := util.RandomPassword()
+	hashedPassword, err := util.HashPassword(password)
+	require.NoError(t, err)
+
+	return &repository.User{
+		Username: util.RandomUsername(),
+		Password: hashedPassword,
+	}, password
+}
+
+// createToken creates a token and returns it with its payload
+func createToken(t *testing.T, username string, duration time.Duration) (string, *token.Payload) {
+	tokenMaker, err := token.NewPasetoMaker(testConfigs.TokenSymmetricKey())
+	require.NoError(t, err)
+
+	accessToken, payload, err := tokenMaker.CreateToken(username, duration)
+	require.NoError(t, err)
+	require.NotEmpty(t, accessToken)
+	require.NotEmpty(t, payload)
+
+	require.Equal(t, username, payload.Username)
+	require.WithinDuration(t, time.Now().Add(duration), payload.ExpiredAt, time.Second)
+	require.WithinDuration(t, time.Now(), payload.IssuedAt, time.Second)
+
+	return accessToken, payload
+}
+
+// checkLoginResponse checks login response
+func checkLoginResponse(t *testing.T, username, accessToken, refreshToken string, recorder *httptest.ResponseRecorder) {
+	cookies := recorder.Result().Cookies()
+
+	// the expiry of a cookie is sent in whole seconds, so it is compared at that precision
+	expiry := func(duration time.Duration) time.Time {
+		return time.Now().Add(duration).Truncate(time.Second)
+	}
 
 	for _, cookie := range cookies {
 		if cookie.Name == "accessToken" {
 			require.Equal(t, accessToken, cookie.Value)
-			require.WithinDuration(t, time.Now().Add(15*time.Minute), cookie.Expires, time.Second)
+			require.WithinDuration(t, expiry(15*time.Minute), cookie.Expires, time.Second)
 			require.True(t, cookie.HttpOnly)
 			require.Equal(t, testConfigs.AccessTokenCookiePath(), cookie.Path)
 		} else if cookie.Name == "refreshToken" {
 			require.Equal(t, refreshToken, cookie.Value)
-			require.WithinDuration(t, time.Now().Add(24*time.Hour), cookie.Expires, time.Second)
+			require.WithinDuration(t, expiry(24*time.Hour), cookie.Expires, time.Second)
 			require.True(t, cookie.HttpOnly)
 			require.Equal(t, testConfigs.RefreshTokenCookiePath(), cookie.Path)
 		} else if cookie.Name == "username" {
 			require.Equal(t, username, cookie.Value)
-			require.WithinDuration(t, time.Now().Add(15*time.Minute), cookie.Expires, time.Second)
+			require.WithinDuration(t, expiry(15*time.Minute), cookie.Expires, time.Second)
 			require.False(t, cookie.HttpOnly)
 			require.Equal(t, testConfigs.UsernameCookiePath(), cookie.Path)
 		}
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"Chat-Server/config"
+	"Chat-Server/repository"
+	"Chat-Server/util"
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// errors of the logins, a failed login does not tell an unknown username from a wrong password
+var (
+	errLoginFailed    = errors.New("invalid username or password")
+	errLoginThrottled = errors.New("too many failed logins, retry later")
+)
+
+// dummyPasswordHash returns the hash the passwords of the unknown usernames are checked against, so their
+// failed logins take as long as the wrong passwords of the known usernames
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, err := util.HashPassword(util.RandomString(32, util.ALPHANUMERIC))
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+// loginSweepInterval is the minimum time between two sweeps of the expired login throttles of an instance
+const loginSweepInterval = time.Minute
+
+// loginGuard throttles the failed logins of every username and of every client address. the failed logins
+// of a username delay its next login progressively, a username or an address reaching its maximum of failed
+// logins is locked out. the throttles are kept in the repository so the instances of the server share them
+type loginGuard struct {
+	repository repository.Repository
+
+	maxFailures        int           // failed logins of a username before it is locked out, 0 disables it
+	addressMaxFailures int           // failed logins from an address before it is locked out, 0 disables it
+	lockoutDuration    time.Duration // time a username or an address is locked out for
+	failureDelay       time.Duration // delay after the second failed login of a username, 0 disables delays
+	failureWindow      time.Duration // time after which the failed logins are forgotten
+
+	// expired throttles are deleted at most once per loginSweepInterval by every instance
+	mu      sync.Mutex
+	sweptAt time.Time
+
+	// now returns the current time, replaced in tests
+	now func() time.Time
+}
+
+// newLoginGuard returns a loginGuard keeping its throttles in the input repository
+func newLoginGuard(repository repository.Repository, configs *config.Config) *loginGuard {
+	return &loginGuard{
+		repository:         repository,
+		maxFailures:        configs.LoginMaxFailures(),
+		addressMaxFailures: configs.LoginAddressMaxFailures(),
+		lockoutDuration:    configs.LoginLockoutDuration(),
+		failureDelay:       configs.LoginFailureDelay(),
+		failureWindow:      configs.LoginFailureWindow(),
+		now:                time.Now,
+	}
+}
+
+// throttlesUsernames reports whether the failed logins of the usernames are tracked
+func (g *loginGuard) throttlesUsernames() bool {
+	return g.maxFailures > 0 || g.failureDelay > 0
+}
+
+// throttlesAddresses reports whether the failed logins from the client addresses are tracked
+func (g *loginGuard) throttlesAddresses() bool {
+	return g.addressMaxFailures > 0
+}
+
+// check returns the time until a login of the input username from the input address is allowed, zero if it
+// is allowed now
+func (g *loginGuard) check(ctx context.Context, username, address string) (time.Duration, error) {
+	now := g.now().UTC().Truncate(time.Microsecond)
+
+	if g.sweepDue(now) {
+		if err := g.repository.DeleteExpiredLoginThrottles(ctx, now); err != nil {
+			return 0, err
+		}
+	}
+
+	var allowedAt time.Time
+	if g.throttlesUsernames() {
+		throttle, err := g.throttle(ctx, loginThrottleKey(repository.LoginScopeUsername, username))
+		if err != nil {
+			return 0, err
+		}
+		allowedAt = later(throttle.LockedUntil, throttle.LastFailureAt.Add(g.delay(throttle.Failures)))
+	}
+	if g.throttlesAddresses() {
+		throttle, err := g.throttle(ctx, loginThrottleKey(repository.LoginScopeAddress, address))
+		if err != nil {
+			return 0, err
+		}
+		allowedAt = later(allowedAt, throttle.LockedUntil)
+	}
+
+	if !allowedAt.After(now) {
+		return 0, nil
+	}
+	return allowedAt.Sub(now), nil
+}
+
+// fail records a failed login of the input username from the input address, and locks out the username or
+// the address reaching its maximum of failed logins
+func (g *loginGuard) fail(ctx context.Context, username, address string) error {
+	now := g.now().UTC().Truncate(time.Microsecond)
+
+	if g.throttlesUsernames() {
+		if err := g.recordFailure(ctx, repository.LoginScopeUsername, username, address, now); err != nil {
+			return err
+		}
+	}
+	if g.throttlesAddresses() {
+		if err := g.recordFailure(ctx, repository.LoginScopeAddress, username, address, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// succeed forgets the failed logins of the input username. the failed logins from the address of the client
+// are kept, or an attacker could log in to its own account between its guesses to reset them
+func (g *loginGuard) succeed(ctx context.Context, username string) error {
+	if !g.throttlesUsernames() {
+		return nil
+	}
+
+	return g.repository.DeleteLoginThrottle(ctx, loginThrottleKey(repository.LoginScopeUsername, username))
+}
+
+// recordFailure counts a failed login in the throttle of the input scope, and saves the audit record of the
+// lockout if the throttle reaches its maximum of failed logins
+func (g *loginGuard) recordFailure(ctx context.Context, scope, username, address string, now time.Time) error {
+	maxFailures, subject := g.maxFailures, username
+	if scope == repository.LoginScopeAddress {
+		maxFailures, subject = g.addressMaxFailures, address
+	}
+
+	var locked bool
+	throttle, err := g.repository.UpdateLoginThrottle(ctx, loginThrottleKey(scope, subject), func(throttle *repository.LoginThrottle) {
+		locked = false
+		if throttle.LastFailureAt.Add(g.failureWindow).Before(now) && !throttle.LockedUntil.After(now) {
+			throttle.Failures = 0
+		}
+
+		throttle.Failures++
+		throttle.LastFailureAt = now
+		if maxFailures > 0 && throttle.Failures >= maxFailures && !throttle.LockedUntil.After(now) {
+			throttle.LockedUntil = now.Add(g.lockoutDuration)
+			locked = true
+		}
+		throttle.ExpiresAt = later(now.Add(g.failureWindow), throttle.LockedUntil)
+	})
+	if err != nil || !locked {
+		return err
+	}
+
+	log.Printf("%s %q locked out until %s after %d failed logins", scope, subject,
+		throttle.LockedUntil.Format(time.RFC3339), throttle.Failures)
+
+	_, err = g.repository.AddLockout(ctx, &repository.Lockout{
+		Scope:       scope,
+		Username:    username,
+		ClientIP:    address,
+		Failures:    throttle.Failures,
+		LockedUntil: throttle.LockedUntil,
+	})
+	return err
+}
+
+// throttle returns the login throttle of the input key, a zero throttle if the key has none
+func (g *loginGuard) throttle(ctx context.Context, key string) (*repository.LoginThrottle, error) {
+	throttle, err := g.repository.GetLoginThrottle(ctx, key)
+	if errors.Is(err, repository.ErrNotFound) {
+		return &repository.LoginThrottle{Key: key}, nil
+	}
+
+	return throttle, err
+}
+
+// delay returns the time a username waits after its last failed login, doubled after every failed login
+// from the second and capped at the lockout duration
+func (g *loginGuard) delay(failures int) time.Duration {
+	if failures < 2 || g.failureDelay <= 0 {
+		return 0
+	}
+
+	delay := g.failureDelay
+	for i := 2; i < failures && delay < g.lockoutDuration; i++ {
+		delay *= 2
+	}
+
+	return min(delay, max(g.lockoutDuration, g.failureDelay))
+}
+
+// sweepDue reports whether the expired throttles are swept at the input time
+func (g *loginGuard) sweepDue(now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if now.Sub(g.sweptAt) < loginSweepInterval {
+		return false
+	}
+	g.sweptAt = now
+
+	return true
+}
+
+// loginThrottleKey returns the key of the login throttle of the input username or address
+func loginThrottleKey(scope, subject string) string {
+	return scope + ":" + subject
+}
+
+// later returns the later of the input times
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
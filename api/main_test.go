@@ -2,20 +2,88 @@ package api
 
 import (
 	"Chat-Server/config"
+	"Chat-Server/mail"
+	"Chat-Server/media"
 	"Chat-Server/repository"
+	"Chat-Server/storage"
 	"Chat-Server/token"
+	"context"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/require"
 	"os"
+	"sync"
 	"testing"
+	"time"
 )
 
 var testConfigs *config.Config
 
-// NewTestServer returns a new test server
+// testAdminUsername is the username of the administrator of the test server
+const testAdminUsername = "test_admin"
+
+// testMailer records the emails sent by the test server instead of sending them
+type testMailer struct {
+	mu       sync.Mutex
+	messages []mail.Message
+}
+
+// Send records the input message
+func (m *testMailer) Send(ctx context.Context, message mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, message)
+	return ctx.Err()
+}
+
+// sent returns the messages sent so far
+func (m *testMailer) sent() []mail.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]mail.Message(nil), m.messages...)
+}
+
+// waitSent waits until the input number of messages are sent in the background and returns the messages
+// sent so far
+func (m *testMailer) waitSent(t *testing.T, count int) []mail.Message {
+	require.Eventually(t, func() bool { return len(m.sent()) >= count }, 5*time.Second, 10*time.Millisecond)
+	return m.sent()
+}
+
+// recordMails replaces the mail queue of the input test server with one sending the emails to a new
+// testMailer, and returns the testMailer
+func recordMails(t *testing.T, server *server) *testMailer {
+	require.NoError(t, server.mails.Close(context.Background()))
+
+	mailer := &testMailer{}
+	server.mails = mail.NewQueue(mailer, mail.QueueConfig{})
+	t.Cleanup(func() { server.mails.Close(context.Background()) })
+
+	return mailer
+}
+
+// NewTestServer returns a new test server, storing attachments in a temporary directory and recording the
+// emails in a testMailer. the thumbnails and emails queued by a test are processed before the test ends
 func NewTestServer(t *testing.T, repository repository.Repository, tokenMaker token.Maker) *server {
-	server := NewServer(repository, tokenMaker, testConfigs)
+	blobStore, err := storage.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	server := NewServer(repository, blobStore, &testMailer{}, tokenMaker, testConfigs)
 	require.NotEmpty(t, server)
+	t.Cleanup(func() { server.mails.Close(context.Background()) })
+
+	// the hub does not run in the tests, so the thumbnails are generated without being sent to it
+	require.NoError(t, server.thumbnails.Close(context.Background()))
+	server.thumbnails = media.NewProcessor(blobStore, repository, media.ProcessorConfig{}, nil)
+	t.Cleanup(func() { server.thumbnails.Close(context.Background()) })
+
+	// the dispatcher would read the webhooks from the stubbed repository in the background, so the
+	// tests delivering events set up their own
+	if server.webhooks != nil {
+		require.NoError(t, server.webhooks.Close(context.Background()))
+		server.webhooks = nil
+	}
 
 	return server
 }
@@ -24,6 +92,17 @@ func NewTestServer(t *testing.T, repository repository.Repository, tokenMaker to
 func TestMain(m *testing.M) {
 	gin.SetMode(gin.TestMode)
 
+	// the administrators are set in the environment so they do not depend on the local config file
+	if err := os.Setenv("ADMIN_USERNAMES", testAdminUsername); err != nil {
+		panic(err)
+	}
+	// the test requests share the address of the client, so the rate limits are disabled unless a test sets
+	// up its own limiters
+	for _, key := range []string{"AUTH_RATE", "API_RATE", "MESSAGE_RATE"} {
+		if err := os.Setenv(key, "0"); err != nil {
+			panic(err)
+		}
+	}
 	testConfigs = config.GetConfig("config", "json", "../config")
 
 	exitCode := m.Run()
@@ -1,21 +1,40 @@
 package api
 
 import (
+	"Chat-Server/ratelimit"
+	"Chat-Server/repository"
 	"Chat-Server/token"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"log"
+	"math"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
 	authorizationCookieName string = "accessToken"
 	authorizationPayloadKey string = "authorization_payload"
+	authorizationBotKey     string = "authorization_bot"
+
+	// apiTokenPrefix starts the API tokens of the bots, which are sent in the Authorization header
+	apiTokenPrefix string = "bot_"
 )
 
 // authMiddleware checks for access token in the cookies and if valid, extracts the token payload
 // and saves it as authorizationPayloadKey in the context
 func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
 	return func(context *gin.Context) {
+		// a bot is already authenticated by its API token
+		if _, ok := context.Get(authorizationPayloadKey); ok {
+			context.Next()
+			return
+		}
+
 		accessToken, err := context.Cookie(authorizationCookieName)
 
 		if err != nil {
@@ -34,3 +53,89 @@ func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
 		context.Next()
 	}
 }
+
+// rateLimitMiddleware rejects the requests over the limit of the input limiter with 429, the key of a
+// request is returned by key, e.g. the address of the client or the authenticated user
+func rateLimitMiddleware(limiter *ratelimit.Limiter, key func(context *gin.Context) string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		allowed, retryAfter, err := limiter.Allow(context.Request.Context(), key(context))
+		if err != nil {
+			// the requests are let through while the buckets are unavailable rather than all rejected
+			log.Printf("error: cannot rate limit request: %v", err)
+		} else if !allowed {
+			abortTooManyRequests(context, retryAfter, fmt.Errorf("too many requests, retry later"))
+			return
+		}
+
+		context.Next()
+	}
+}
+
+// clientAddress returns the address of the client of the request, the key of the requests limited by address
+func clientAddress(context *gin.Context) string {
+	return context.ClientIP()
+}
+
+// authenticatedUsername returns the username of the authenticated user of the request, the key of the
+// requests limited by user. it runs after authMiddleware
+func authenticatedUsername(context *gin.Context) string {
+	return context.MustGet(authorizationPayloadKey).(*token.Payload).Username
+}
+
+// abortTooManyRequests rejects a rate limited request with 429, the Retry-After header has the number of
+// seconds until the request is allowed
+func abortTooManyRequests(context *gin.Context, retryAfter time.Duration, err error) {
+	context.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	context.AbortWithStatusJSON(http.StatusTooManyRequests, errorResponse(err))
+}
+
+// adminMiddleware lets only the input administrators through, it runs after authMiddleware. bots are never
+// administrators, even a bot named after an administrator who has not signed up
+func adminMiddleware(admins []string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		payload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+		_, isBot := context.Get(authorizationBotKey)
+
+		if isBot || !slices.Contains(admins, payload.Username) {
+			err := fmt.Errorf("admin access required")
+			context.AbortWithStatusJSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
+
+		context.Next()
+	}
+}
+
+// apiTokenMiddleware authenticates the bots by the API token of the "Authorization: Bearer" header, and saves
+// the payload of the bot as authorizationPayloadKey and the bot as authorizationBotKey in the context. requests
+// without the header are left to authMiddleware, which runs after it
+func apiTokenMiddleware(bots repository.Repository) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		apiToken, ok := strings.CutPrefix(context.GetHeader("Authorization"), "Bearer ")
+		if !ok {
+			context.Next()
+			return
+		}
+
+		if !strings.HasPrefix(apiToken, apiTokenPrefix) {
+			context.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(fmt.Errorf("invalid api token")))
+			return
+		}
+
+		bot, err := bots.GetBotByToken(context.Request.Context(), hashSecretToken(apiToken))
+		if errors.Is(err, repository.ErrNotFound) {
+			context.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(fmt.Errorf("invalid api token")))
+			return
+		}
+		if err != nil {
+			repositoryErrorResponse(context, err)
+			context.Abort()
+			return
+		}
+
+		// the API tokens do not expire, they are replaced by the owner of the bot
+		context.Set(authorizationPayloadKey, &token.Payload{Username: bot.Username, IssuedAt: time.Now()})
+		context.Set(authorizationBotKey, bot)
+		context.Next()
+	}
+}
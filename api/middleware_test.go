@@ -1,8 +1,13 @@
 package api
 
 import (
+	"Chat-Server/ratelimit"
+	"Chat-Server/repository"
+	"Chat-Server/repository/db/memory"
+	mockdb "Chat-Server/repository/mock"
 	"Chat-Server/token"
 	"Chat-Server/token/mock"
+	"Chat-Server/util"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
@@ -147,3 +152,138 @@ func TestAuthMiddleware(t *testing.T) {
 	}
 
 }
+
+// TestAPITokenMiddleware tests apiTokenMiddleware
+func TestAPITokenMiddleware(t *testing.T) {
+	apiToken := apiTokenPrefix + "test_token"
+
+	testCases := []struct {
+		name          string
+		authorization string
+		buildStubs    func(repo *mockdb.MockRepository)
+		code          int
+	}{
+		{
+			name:          "OK",
+			authorization: "Bearer " + apiToken,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetBotByToken(gomock.Any(), hashSecretToken(apiToken)).Times(1).
+					Return(&repository.Bot{Username: "test_bot", Owner: "test_owner"}, nil)
+			},
+			code: http.StatusOK,
+		},
+		{
+			name:       "NoHeader",
+			buildStubs: func(repo *mockdb.MockRepository) {},
+			code:       http.StatusUnauthorized,
+		},
+		{
+			name:          "NotBotToken",
+			authorization: "Bearer test_token",
+			buildStubs:    func(repo *mockdb.MockRepository) {},
+			code:          http.StatusUnauthorized,
+		},
+		{
+			name:          "UnknownToken",
+			authorization: "Bearer " + apiToken,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetBotByToken(gomock.Any(), gomock.Any()).Times(1).Return(nil, repository.ErrNotFound)
+			},
+			code: http.StatusUnauthorized,
+		},
+		{
+			name:          "Unavailable",
+			authorization: "Bearer " + apiToken,
+			buildStubs: func(repo *mockdb.MockRepository) {
+				repo.EXPECT().GetBotByToken(gomock.Any(), gomock.Any()).Times(1).Return(nil, repository.ErrUnavailable)
+			},
+			code: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			testCase.buildStubs(repo)
+
+			server := NewTestServer(t, repo, mockmaker.NewMockMaker(ctrl))
+			authRoutes := server.router.Group("/").Use(apiTokenMiddleware(repo), authMiddleware(server.tokenMaker))
+			authRoutes.GET("/auth",
+				func(context *gin.Context) {
+					payload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+					require.Equal(t, "test_bot", payload.Username)
+					context.JSON(http.StatusOK, gin.H{})
+				},
+			)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, "/auth", nil)
+			require.NoError(t, err)
+			if testCase.authorization != "" {
+				request.Header.Set("Authorization", testCase.authorization)
+			}
+
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, testCase.code, recorder.Code)
+		})
+	}
+}
+
+// TestRateLimitMiddleware tests limiting the requests of the clients by address and of the users
+func TestRateLimitMiddleware(t *testing.T) {
+	repo := memory.NewMemoryRepository()
+	server := NewTestServer(t, repo, nil)
+	tokenMaker, err := token.NewPasetoMaker(testConfigs.TokenSymmetricKey())
+	require.NoError(t, err)
+	server.tokenMaker = tokenMaker
+
+	// the routes get the limiters of the test
+	store := ratelimit.NewMemoryStore()
+	server.authLimiter = ratelimit.New(store, "auth", ratelimit.Limit{PerMinute: 2, Burst: 2})
+	server.apiLimiter = ratelimit.New(store, "api", ratelimit.Limit{PerMinute: 1, Burst: 1})
+	server.router = gin.New()
+	require.NoError(t, server.router.SetTrustedProxies(testConfigs.TrustedProxies()))
+	server.addRouteHandlers()
+
+	serve := func(method, path, address, forwardedFor, username string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, nil)
+		require.NoError(t, err)
+		req.RemoteAddr = address + ":40000"
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		if username != "" {
+			addTokenCookie(t, username, req, authorizationCookieName, time.Minute, "/")
+		}
+
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// the login attempts of an address are limited, whatever the login requests are
+	for range 2 {
+		require.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/api/login", "192.0.2.1", "", "").Code)
+	}
+	recorder := serve(http.MethodPost, "/api/login", "192.0.2.1", "", "")
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	require.Equal(t, "30", recorder.Header().Get("Retry-After"))
+
+	// the routes share the limit of the address, the header of an untrusted client is ignored
+	require.Equal(t, http.StatusTooManyRequests, serve(http.MethodPost, "/api/signup", "192.0.2.1", "198.51.100.7", "").Code)
+	require.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/api/login", "192.0.2.2", "", "").Code)
+
+	// the requests of a user are limited whatever its address
+	username := util.RandomUsername()
+	require.Equal(t, http.StatusOK, serve(http.MethodGet, "/api/notifications", "192.0.2.3", "", username).Code)
+	recorder = serve(http.MethodGet, "/api/notifications", "192.0.2.4", "", username)
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	require.Equal(t, "60", recorder.Header().Get("Retry-After"))
+	require.Equal(t, http.StatusOK, serve(http.MethodGet, "/api/notifications", "192.0.2.4", "", util.RandomUsername()).Code)
+
+	// the requests are not limited before they are authenticated
+	require.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "/api/notifications", "192.0.2.3", "", "").Code)
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"Chat-Server/mail"
+	"Chat-Server/repository"
+	"Chat-Server/token"
+	"Chat-Server/util"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// errInvalidResetToken is returned for the reset tokens which are unknown, used or expired
+var errInvalidResetToken = errors.New("invalid or expired reset token")
+
+// changePassword is the handler for the "/api/password" route, replaces the password of the user once the old
+// password is checked. the other sessions of the user are revoked and the client gets the cookies of a new one
+func (s *server) changePassword(context *gin.Context) {
+	if _, ok := context.Get(authorizationBotKey); ok {
+		context.JSON(http.StatusForbidden, errorResponse(fmt.Errorf("bots do not have passwords")))
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := context.ShouldBindJSON(&req); err != nil {
+		bindErrorResponse(context, err)
+		return
+	}
+
+	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+	username := accessTokenPayload.Username
+	ctx := repository.WithUser(context.Request.Context(), username)
+
+	user, err := s.repository.GetUser(repository.WithPrimary(ctx), username)
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+	if err := util.CheckPassword(req.OldPassword, user.Password); err != nil {
+		context.JSON(http.StatusUnauthorized, errorResponse(fmt.Errorf("wrong password")))
+		return
+	}
+
+	hashedPassword, err := util.HashPassword(req.NewPassword)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
+		return
+	}
+
+	if err := s.repository.ChangePassword(ctx, username, hashedPassword); err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	s.setAuthCookies(context, username)
+}
+
+// forgotPassword is the handler for the "/api/password/forgot" route, sends a link with a single-use reset token
+// to the verified email address of the user. it responds the same whether the user exists or not, so it does
+// not tell which usernames are taken
+func (s *server) forgotPassword(context *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := context.ShouldBindJSON(&req); err != nil {
+		bindErrorResponse(context, err)
+		return
+	}
+
+	ctx := context.Request.Context()
+
+	user, err := s.repository.GetUser(ctx, req.Username)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		repositoryErrorResponse(context, err)
+		return
+	}
+	// the bot users have no password to reset, and the links are only sent to verified addresses
+	if err != nil || user.Password == "" || !user.EmailVerified {
+		context.Status(http.StatusAccepted)
+		return
+	}
+
+	// the expired resets are swept along with the requests of new ones
+	now := time.Now()
+	if err := s.repository.DeleteExpiredPasswordResets(ctx, now); err != nil {
+		log.Printf("error: cannot delete expired password resets: %v", err)
+	}
+
+	resetToken, err := newSecretToken("")
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
+		return
+	}
+	link, err := tokenLink(s.configs.PasswordResetURL(), resetToken)
+	if err != nil {
+		log.Printf("error: invalid password reset url: %v", err)
+		context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
+		return
+	}
+
+	reset, err := s.repository.AddPasswordReset(ctx, &repository.PasswordReset{
+		TokenHash: hashSecretToken(resetToken),
+		Username:  user.Username,
+		ExpiresAt: now.Add(s.configs.PasswordResetTokenDuration()),
+	})
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	err = s.mails.Enqueue(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Text: fmt.Sprintf("Someone asked to reset the password of %s. Open the link below to choose a new "+
+			"password, it works once until %s:\n\n%s\n\nIf you did not ask for it, ignore this email.",
+			user.Username, reset.ExpiresAt.UTC().Format(time.RFC1123), link),
+	})
+	if err != nil {
+		// the user asks again, failing the request would tell the user exists
+		log.Printf("error: cannot queue password reset of %s: %v", user.Username, err)
+	}
+
+	context.Status(http.StatusAccepted)
+}
+
+// resetPassword is the handler for the "/api/password/reset" route, replaces the password of the user of a
+// reset token. the token is used once, and all the sessions of the user are revoked
+func (s *server) resetPassword(context *gin.Context) {
+	var req ResetPasswordRequest
+	if err := context.ShouldBindJSON(&req); err != nil {
+		bindErrorResponse(context, err)
+		return
+	}
+
+	hashedPassword, err := util.HashPassword(req.NewPassword)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
+		return
+	}
+
+	ctx := context.Request.Context()
+
+	reset, err := s.repository.ConsumePasswordReset(ctx, hashSecretToken(req.Token), time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		context.JSON(http.StatusBadRequest, errorResponse(errInvalidResetToken))
+		return
+	}
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	err = s.repository.ChangePassword(repository.WithUser(ctx, reset.Username), reset.Username, hashedPassword)
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	context.Status(http.StatusNoContent)
+}
+
+// tokenLink returns the link of the input page, the password reset or email verification page, with the input
+// token in its query
+func tokenLink(pageURL, linkToken string) (string, error) {
+	link, err := url.Parse(pageURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := link.Query()
+	query.Set("token", linkToken)
+	link.RawQuery = query.Encode()
+
+	return link.String(), nil
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"Chat-Server/repository"
+	"Chat-Server/repository/db/memory"
+	"Chat-Server/token"
+	"Chat-Server/util"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// passwordTestServer returns a test server on a memory repository with a user, and a function serving a
+// request with a json body and cookies
+func passwordTestServer(t *testing.T) (*server, *repository.User, string, func(path string, body any, cookies ...*http.Cookie) *httptest.ResponseRecorder) {
+	repo := memory.NewMemoryRepository()
+	tokenMaker, err := token.NewPasetoMaker(testConfigs.TokenSymmetricKey())
+	require.NoError(t, err)
+	server := NewTestServer(t, repo, tokenMaker)
+
+	user, password := randomUser(t)
+	_, err = repo.AddUser(context.Background(), user)
+	require.NoError(t, err)
+
+	serve := func(path string, body any, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		jsonBody, err := json.Marshal(body)
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, path, bytes.NewBuffer(jsonBody))
+		require.NoError(t, err)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	return server, user, password, serve
+}
+
+// responseCookie returns the cookie of the input name set by the response
+func responseCookie(t *testing.T, recorder *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == name {
+			return &http.Cookie{Name: cookie.Name, Value: cookie.Value}
+		}
+	}
+
+	require.Failf(t, "missing cookie", "the response does not set the %s cookie", name)
+	return nil
+}
+
+// TestChangePassword tests changing the password, which revokes the other sessions of the user
+func TestChangePassword(t *testing.T) {
+	_, user, password, serve := passwordTestServer(t)
+	newPassword := util.RandomPassword()
+
+	login := func(password string) *httptest.ResponseRecorder {
+		return serve("/api/login", LoginRequest{Username: user.Username, Password: password})
+	}
+
+	// the user is logged in on two devices
+	recorder := login(password)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	accessToken := responseCookie(t, recorder, "accessToken")
+	refreshToken := responseCookie(t, recorder, "refreshToken")
+	recorder = login(password)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	otherRefreshToken := responseCookie(t, recorder, "refreshToken")
+	require.Equal(t, http.StatusOK, serve("/api/refresh", nil, otherRefreshToken).Code)
+
+	recorder = serve("/api/password", ChangePasswordRequest{OldPassword: newPassword, NewPassword: newPassword}, accessToken)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+	require.JSONEq(t, `{"error":"wrong password"}`, recorder.Body.String())
+	recorder = serve("/api/password", ChangePasswordRequest{OldPassword: password, NewPassword: "short"}, accessToken)
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+	require.JSONEq(t, `{"error":"invalid NewPassword"}`, recorder.Body.String())
+	require.Equal(t, http.StatusUnauthorized, serve("/api/password", ChangePasswordRequest{OldPassword: password, NewPassword: newPassword}).Code)
+
+	// a refresh token is not taken for an access token, nor an access token for a refresh token
+	stolenRefreshToken := &http.Cookie{Name: "accessToken", Value: refreshToken.Value}
+	require.Equal(t, http.StatusUnauthorized, serve("/api/password", ChangePasswordRequest{OldPassword: password, NewPassword: newPassword}, stolenRefreshToken).Code)
+	require.Equal(t, http.StatusUnauthorized, serve("/api/refresh", nil, &http.Cookie{Name: "refreshToken", Value: accessToken.Value}).Code)
+
+	// the device changing the password gets the cookies of a new session
+	recorder = serve("/api/password", ChangePasswordRequest{OldPassword: password, NewPassword: newPassword}, accessToken)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	checkLoginCookies(t, user.Username, recorder)
+	newRefreshToken := responseCookie(t, recorder, "refreshToken")
+
+	for _, revoked := range []*http.Cookie{refreshToken, otherRefreshToken} {
+		recorder = serve("/api/refresh", nil, revoked)
+		require.Equal(t, http.StatusUnauthorized, recorder.Code)
+		require.JSONEq(t, `{"error":"revoked refresh token"}`, recorder.Body.String())
+	}
+	require.Equal(t, http.StatusOK, serve("/api/refresh", nil, newRefreshToken).Code)
+
+	require.Equal(t, http.StatusUnauthorized, login(password).Code)
+	require.Equal(t, http.StatusOK, login(newPassword).Code)
+}
+
+// TestPasswordReset tests resetting a forgotten password with the single-use token of a reset link
+func TestPasswordReset(t *testing.T) {
+	server, user, password, serve := passwordTestServer(t)
+	mailer := recordMails(t, server)
+	newPassword := util.RandomPassword()
+
+	// the response does not tell whether the user exists
+	require.Equal(t, http.StatusAccepted, serve("/api/password/forgot", ForgotPasswordRequest{Username: "unknown_user"}).Code)
+	require.Empty(t, mailer.sent())
+
+	recorder := serve("/api/login", LoginRequest{Username: user.Username, Password: password})
+	require.Equal(t, http.StatusOK, recorder.Code)
+	refreshToken := responseCookie(t, recorder, "refreshToken")
+
+	// the links are only sent to verified email addresses
+	email := user.Username + "@example.com"
+	require.NoError(t, server.repository.SetEmail(context.Background(), user.Username, email))
+	require.Equal(t, http.StatusAccepted, serve("/api/password/forgot", ForgotPasswordRequest{Username: user.Username}).Code)
+	require.Empty(t, mailer.sent())
+	require.NoError(t, server.repository.VerifyEmail(context.Background(), user.Username, email))
+
+	require.Equal(t, http.StatusAccepted, serve("/api/password/forgot", ForgotPasswordRequest{Username: user.Username}).Code)
+	sent := mailer.waitSent(t, 1)
+	require.Len(t, sent, 1)
+	require.Equal(t, email, sent[0].To)
+
+	link, err := url.Parse(regexp.MustCompile(`https?://\S+`).FindString(sent[0].Text))
+	require.NoError(t, err)
+	require.Equal(t, testConfigs.PasswordResetURL(), link.Scheme+"://"+link.Host+link.Path)
+	resetToken := link.Query().Get("token")
+	require.NotEmpty(t, resetToken)
+
+	recorder = serve("/api/password/reset", ResetPasswordRequest{Token: "unknown", NewPassword: newPassword})
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+	require.JSONEq(t, `{"error":"invalid or expired reset token"}`, recorder.Body.String())
+	require.Equal(t, http.StatusBadRequest, serve("/api/password/reset", ResetPasswordRequest{Token: resetToken, NewPassword: "short"}).Code)
+
+	// the token is used once, and the sessions of the user are revoked
+	require.Equal(t, http.StatusNoContent, serve("/api/password/reset", ResetPasswordRequest{Token: resetToken, NewPassword: newPassword}).Code)
+	require.Equal(t, http.StatusBadRequest, serve("/api/password/reset", ResetPasswordRequest{Token: resetToken, NewPassword: password}).Code)
+	require.Equal(t, http.StatusUnauthorized, serve("/api/refresh", nil, refreshToken).Code)
+
+	require.Equal(t, http.StatusUnauthorized, serve("/api/login", LoginRequest{Username: user.Username, Password: password}).Code)
+	require.Equal(t, http.StatusOK, serve("/api/login", LoginRequest{Username: user.Username, Password: newPassword}).Code)
+
+	// an expired token is rejected
+	_, err = server.repository.AddPasswordReset(context.Background(), &repository.PasswordReset{
+		TokenHash: hashSecretToken("expired_token"),
+		Username:  user.Username,
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, serve("/api/password/reset", ResetPasswordRequest{Token: "expired_token", NewPassword: password}).Code)
+}
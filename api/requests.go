@@ -1,9 +1,12 @@
 package api
 
+import "time"
+
 // SignupRequest represents a signup request body
 type SignupRequest struct {
 	Username string `json:"username" binding:"required,validUsername"`
 	Password string `json:"password" binding:"required,validPassword"`
+	Email    string `json:"email" binding:"omitempty,email,max=254"` // optional, a verification link is sent to it
 }
 
 // LoginRequest represents a login request body
@@ -11,3 +14,107 @@ type LoginRequest struct {
 	Username string `json:"username" binding:"required,validUsername"`
 	Password string `json:"password" binding:"required,validPassword"`
 }
+
+// SearchMessagesRequest represents the query of a message search request
+type SearchMessagesRequest struct {
+	Query  string    `form:"q" binding:"required,max=256"`
+	Author string    `form:"author" binding:"omitempty,validUsername"`
+	Room   string    `form:"room" binding:"omitempty,max=64"`
+	From   time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To     time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Cursor string    `form:"cursor"`
+	Limit  int       `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// NotificationsRequest represents the query of a request listing the notifications of the user
+type NotificationsRequest struct {
+	Unread bool   `form:"unread"`
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// MarkNotificationsReadRequest represents the body of a request marking notifications as read, all the
+// notifications of the user are marked if there is no id
+type MarkNotificationsReadRequest struct {
+	IDs []uint `json:"ids" binding:"max=100"`
+}
+
+// CreateWebhookRequest represents the body of a request registering a webhook
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" binding:"required,url,max=2048"`
+	Events []string `json:"events" binding:"required,min=1,max=10"`
+}
+
+// WebhookDeliveriesRequest represents the query of a request listing the latest delivery attempts of a webhook
+type WebhookDeliveriesRequest struct {
+	Limit int `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// LockoutsRequest represents the query of a request listing the latest lockouts after failed logins
+type LockoutsRequest struct {
+	Limit int `form:"limit" binding:"omitempty,min=1,max=500"`
+}
+
+// CreateIncomingHookRequest represents the body of a request creating an incoming hook and its bot user
+type CreateIncomingHookRequest struct {
+	Name     string `json:"name" binding:"required,max=64"`
+	Username string `json:"username" binding:"required,validUsername"`
+}
+
+// IncomingHookMessageRequest represents the body of a request posting a message through an incoming hook
+type IncomingHookMessageRequest struct {
+	Text string `json:"text" binding:"required,max=2048"`
+}
+
+// CreateBotRequest represents the body of a request creating a bot owned by the user
+type CreateBotRequest struct {
+	Username string `json:"username" binding:"required,validUsername"`
+}
+
+// ConfirmTwoFactorRequest represents the body of a request confirming the enrollment of a TOTP authenticator
+type ConfirmTwoFactorRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// DisableTwoFactorRequest represents the body of a request disabling the TOTP authenticator of the user, the
+// code is a code of the authenticator or a recovery code
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required,max=64"`
+	Code     string `json:"code" binding:"required,max=32"`
+}
+
+// LoginTwoFactorRequest represents the body of the second step of the login of a user with a TOTP
+// authenticator, the code is a code of the authenticator or a recovery code
+type LoginTwoFactorRequest struct {
+	TwoFactorToken string `json:"two_factor_token" binding:"required"`
+	Code           string `json:"code" binding:"required,max=32"`
+}
+
+// ChangePasswordRequest represents the body of a password change of the authenticated user
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required,max=64"`
+	NewPassword string `json:"new_password" binding:"required,validPassword"`
+}
+
+// ForgotPasswordRequest represents the body of a request of a password reset link
+type ForgotPasswordRequest struct {
+	Username string `json:"username" binding:"required,validUsername"`
+}
+
+// ResetPasswordRequest represents the body of a password reset with the token of a reset link
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required,max=128"`
+	NewPassword string `json:"new_password" binding:"required,validPassword"`
+}
+
+// SetEmailRequest represents the body of a request setting the email address of the authenticated user, an
+// empty email removes it
+type SetEmailRequest struct {
+	Email string `json:"email" binding:"omitempty,email,max=254"`
+}
+
+// VerifyEmailRequest represents the body of a request verifying an email address with the token of a
+// verification link
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required,max=1024"`
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"Chat-Server/repository"
+	"time"
+)
+
+// MessageSearchResult represents a message matching a search
+type MessageSearchResult struct {
+	ID        uint      `json:"id"`
+	Author    string    `json:"author"`
+	Text      string    `json:"text"`
+	Room      string    `json:"room"`
+	CreatedAt time.Time `json:"created_at"`
+	Snippet   string    `json:"snippet"` // html of the matching part of the text with the matches in <mark> elements
+}
+
+// SearchMessagesResponse represents a page of the results of a message search
+type SearchMessagesResponse struct {
+	Results    []MessageSearchResult `json:"results"`
+	NextCursor string                `json:"next_cursor,omitempty"` // cursor of the next page, empty on the last page
+}
+
+// NotificationResponse represents the notification of a user mentioned in a message
+type NotificationResponse struct {
+	ID        uint      `json:"id"`
+	MessageID uint      `json:"message_id"`
+	Author    string    `json:"author"`
+	Room      string    `json:"room"`
+	Text      string    `json:"text"`
+	Read      bool      `json:"read"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NotificationsResponse represents a page of the notifications of a user
+type NotificationsResponse struct {
+	Notifications []NotificationResponse `json:"notifications"`
+	UnreadCount   int64                  `json:"unread_count"`          // number of unread notifications of the user
+	NextCursor    string                 `json:"next_cursor,omitempty"` // cursor of the next page, empty on the last page
+}
+
+// WebhookResponse represents a registered webhook
+type WebhookResponse struct {
+	ID        uint      `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatedBy string    `json:"created_by"`
+	Failures  int       `json:"failures"` // number of consecutive events the webhook failed to receive
+	Disabled  bool      `json:"disabled"`
+	CreatedAt time.Time `json:"created_at"`
+	Secret    string    `json:"secret,omitempty"` // only sent in the response registering the webhook
+}
+
+// WebhooksResponse represents the registered webhooks
+type WebhooksResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+}
+
+// WebhookDeliveryResponse represents an attempt to deliver an event to a webhook
+type WebhookDeliveryResponse struct {
+	ID         uint      `json:"id"`
+	EventID    string    `json:"event_id"`
+	EventType  string    `json:"event_type"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code"`     // 0 if the webhook did not respond
+	Error      string    `json:"error,omitempty"` // empty if the event was delivered
+	DurationMs int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// WebhookDeliveriesResponse represents the latest delivery attempts of a webhook, newest first
+type WebhookDeliveriesResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+}
+
+// LockoutResponse represents the lockout of a username or an address after failed logins
+type LockoutResponse struct {
+	ID          uint      `json:"id"`
+	Scope       string    `json:"scope"`    // "username" or "address"
+	Username    string    `json:"username"` // username of the failed login which caused the lockout
+	ClientIP    string    `json:"client_ip"`
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"locked_until"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// LockoutsResponse represents the latest lockouts, newest first
+type LockoutsResponse struct {
+	Lockouts []LockoutResponse `json:"lockouts"`
+}
+
+// TwoFactorChallengeResponse represents the first step of the login of a user with a TOTP authenticator, the
+// token is sent with a code to the second step
+type TwoFactorChallengeResponse struct {
+	TwoFactorToken string    `json:"two_factor_token"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+// TwoFactorEnrollmentResponse represents the secret of a pending TOTP authenticator, and its otpauth uri to
+// show as a QR code
+type TwoFactorEnrollmentResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// RecoveryCodesResponse represents the recovery codes of a TOTP authenticator, each accepted once instead of
+// a code
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// EmailResponse represents the email address of a user, empty if the user has none
+type EmailResponse struct {
+	Email    string `json:"email"`
+	Verified bool   `json:"verified"`
+}
+
+// toWebhookResponse converts a repository webhook to a webhook response, without its secret
+func toWebhookResponse(webhook *repository.Webhook) WebhookResponse {
+	return WebhookResponse{
+		ID:        webhook.ID,
+		URL:       webhook.URL,
+		Events:    webhook.Events,
+		CreatedBy: webhook.CreatedBy,
+		Failures:  webhook.Failures,
+		Disabled:  webhook.Disabled,
+		CreatedAt: webhook.CreatedAt,
+	}
+}
+
+// IncomingHookResponse represents an incoming hook
+type IncomingHookResponse struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Username  string    `json:"username"` // the bot user the messages of the hook are posted as
+	CreatedBy string    `json:"created_by"`
+	Revoked   bool      `json:"revoked"`
+	CreatedAt time.Time `json:"created_at"`
+	URL       string    `json:"url,omitempty"` // secret url of the hook, only sent in the response creating the hook
+}
+
+// IncomingHooksResponse represents the incoming hooks
+type IncomingHooksResponse struct {
+	Hooks []IncomingHookResponse `json:"hooks"`
+}
+
+// toIncomingHookResponse converts a repository incoming hook to an incoming hook response, without its url
+func toIncomingHookResponse(hook *repository.IncomingHook) IncomingHookResponse {
+	return IncomingHookResponse{
+		ID:        hook.ID,
+		Name:      hook.Name,
+		Username:  hook.Username,
+		CreatedBy: hook.CreatedBy,
+		Revoked:   hook.Revoked,
+		CreatedAt: hook.CreatedAt,
+	}
+}
+
+// BotResponse represents a bot
+type BotResponse struct {
+	Username  string    `json:"username"`
+	Owner     string    `json:"owner"`
+	CreatedAt time.Time `json:"created_at"`
+	Token     string    `json:"token,omitempty"` // API token of the bot, only sent in the responses creating or replacing it
+}
+
+// BotsResponse represents the bots of a user
+type BotsResponse struct {
+	Bots []BotResponse `json:"bots"`
+}
+
+// toBotResponse converts a repository bot to a bot response, without its token
+func toBotResponse(bot *repository.Bot) BotResponse {
+	return BotResponse{
+		Username:  bot.Username,
+		Owner:     bot.Owner,
+		CreatedAt: bot.CreatedAt,
+	}
+}
+
+// AttachmentResponse represents an uploaded attachment
+type AttachmentResponse struct {
+	ID          string `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Checksum    string `json:"checksum"`
+	URL         string `json:"url"`
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+}
+
+// toAttachmentResponse converts a repository attachment to an attachment response
+func toAttachmentResponse(attachment *repository.Attachment) AttachmentResponse {
+	return AttachmentResponse{
+		ID:          attachment.ID,
+		Filename:    attachment.Filename,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		Checksum:    attachment.Checksum,
+		URL:         attachmentURL(attachment.ID),
+		Width:       attachment.Width,
+		Height:      attachment.Height,
+	}
+}
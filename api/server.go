@@ -3,8 +3,16 @@ package api
 import (
 	"Chat-Server/api/ws"
 	"Chat-Server/config"
+	"Chat-Server/mail"
+	"Chat-Server/media"
+	"Chat-Server/ratelimit"
 	"Chat-Server/repository"
+	"Chat-Server/storage"
 	"Chat-Server/token"
+	"Chat-Server/unfurl"
+	"Chat-Server/webhook"
+	"context"
+	"errors"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
@@ -17,34 +25,147 @@ import (
 type server struct {
 	router     *gin.Engine
 	repository repository.Repository
+	blobStore  storage.BlobStore
 	tokenMaker token.Maker
 	configs    *config.Config
 	chatHub    *ws.Hub
+	thumbnails *media.Processor
+
+	// mails sends the emails to the users in the background, so the requests do not wait for the mail server
+	mails *mail.Queue
+
+	// linkPreviews fetches the previews of the links in the messages, nil if link previews are disabled
+	linkPreviews *unfurl.Unfurler
+
+	// webhooks delivers the events of the chat to the registered webhooks, nil if webhooks are disabled
+	webhooks *webhook.Dispatcher
+
+	// authLimiter limits the rate of the signup, login and refresh requests of every address
+	authLimiter *ratelimit.Limiter
+
+	// apiLimiter limits the rate of the requests of every user to the authenticated endpoints
+	apiLimiter *ratelimit.Limiter
+
+	// hookLimiter limits the rate of the messages posted by every incoming hook
+	hookLimiter *ratelimit.Limiter
+
+	// loginGuard throttles the failed logins of every username and address
+	loginGuard *loginGuard
+
+	// requireVerifiedEmail makes the users verify their email address before joining the hub
+	requireVerifiedEmail bool
 }
 
-// NewServer initializes and returns a server
-func NewServer(repository repository.Repository, tokenMaker token.Maker, configs *config.Config) *server {
+// NewServer initializes and returns a server, the files of the attachments are stored in the input blob store
+// and the emails to the users are sent in the background by the input mailer
+func NewServer(repository repository.Repository, blobStore storage.BlobStore, mailer mail.Mailer, tokenMaker token.Maker, configs *config.Config) *server {
 	// get a gin router with default middlewares
 	router := gin.Default()
 
+	// the address of a client, by which its requests are rate limited, is only read from the X-Forwarded-For
+	// header set by a trusted proxy. the proxies are validated with the configurations
+	if err := router.SetTrustedProxies(configs.TrustedProxies()); err != nil {
+		log.Fatalf("invalid trusted proxies: %v", err)
+	}
+
 	// CORS middleware configuration
 	corsConfig := cors.DefaultConfig()
 	corsConfig.AllowOrigins = []string{"*"}
-	corsConfig.AllowMethods = []string{"GET", "POST", "OPTIONS"}
-	corsConfig.AllowHeaders = []string{"Origin", "Content-Type"}
+	corsConfig.AllowMethods = []string{"GET", "POST", "DELETE", "OPTIONS"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
 
 	// use the CORS middleware with the custom configuration
 	router.Use(cors.New(corsConfig))
 
+	// the rate limit buckets are kept in the database when the instances of the server share them
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if configs.RateLimitStore() == "database" {
+		rateLimitStore = ratelimit.NewRepositoryStore(repository)
+	}
+
+	hubConfig := ws.HubConfig{
+		Durability:    ws.Durability(configs.MessageDurability()),
+		Attachments:   repository,
+		Users:         repository,
+		AttachmentURL: attachmentURL,
+		ThumbnailURL:  thumbnailURL,
+		Admins:        configs.AdminUsernames(),
+		MessageLimiter: ratelimit.New(rateLimitStore, "messages", ratelimit.Limit{
+			PerMinute: configs.MessageRate(),
+			Burst:     configs.MessageBurst(),
+		}),
+	}
+
+	// previews of the links in the messages are fetched in the background and sent to the clients through the hub
+	var linkPreviews *unfurl.Unfurler
+	if configs.LinkPreviewWorkers() > 0 {
+		linkPreviews = unfurl.New(unfurl.Config{
+			Workers:   configs.LinkPreviewWorkers(),
+			QueueSize: configs.LinkPreviewQueueSize(),
+			Timeout:   configs.LinkPreviewTimeout(),
+			CacheSize: configs.LinkPreviewCacheSize(),
+			CacheTTL:  configs.LinkPreviewCacheTTL(),
+		})
+		hubConfig.LinkPreviews = linkPreviews
+	}
+
+	// events are delivered to the webhooks in the background, the admins register webhooks even while disabled
+	var webhooks *webhook.Dispatcher
+	if configs.WebhookWorkers() > 0 {
+		webhooks = webhook.New(repository, webhook.Config{
+			Workers:     configs.WebhookWorkers(),
+			QueueSize:   configs.WebhookQueueSize(),
+			Timeout:     configs.WebhookTimeout(),
+			MaxAttempts: configs.WebhookMaxAttempts(),
+			Backoff:     configs.WebhookBackoff(),
+			MaxBackoff:  configs.WebhookMaxBackoff(),
+			MaxFailures: configs.WebhookMaxFailures(),
+
+			AllowPrivateNetworks: configs.WebhookAllowPrivateNetworks(),
+		})
+		hubConfig.Events = webhooks
+	}
+
 	// create and return a server
 	apiServer := server{
-		repository: repository,
-		router:     router,
-		tokenMaker: tokenMaker,
-		configs:    configs,
-		chatHub:    ws.NewHub(),
+		repository:   repository,
+		blobStore:    blobStore,
+		router:       router,
+		tokenMaker:   tokenMaker,
+		configs:      configs,
+		chatHub:      ws.NewHub(hubConfig),
+		linkPreviews: linkPreviews,
+		webhooks:     webhooks,
+		authLimiter: ratelimit.New(rateLimitStore, "auth", ratelimit.Limit{
+			PerMinute: configs.AuthRate(),
+			Burst:     configs.AuthBurst(),
+		}),
+		apiLimiter: ratelimit.New(rateLimitStore, "api", ratelimit.Limit{
+			PerMinute: configs.APIRate(),
+			Burst:     configs.APIBurst(),
+		}),
+		hookLimiter: ratelimit.New(rateLimitStore, "hooks", ratelimit.Limit{
+			PerMinute: configs.IncomingHookRate(),
+			Burst:     configs.IncomingHookBurst(),
+		}),
+		loginGuard:           newLoginGuard(repository, configs),
+		requireVerifiedEmail: configs.RequireVerifiedEmail(),
 	}
 
+	// thumbnails of uploaded images are generated in the background and sent to the clients through the hub
+	apiServer.thumbnails = media.NewProcessor(blobStore, repository, media.ProcessorConfig{
+		Workers:       configs.ThumbnailWorkers(),
+		QueueSize:     configs.ThumbnailQueueSize(),
+		ThumbnailSize: configs.ThumbnailMaxDimension(),
+	}, apiServer.chatHub.UpdateAttachment)
+
+	// the emails are sent in the background with their own timeout, so a slow mail server does not hold up the
+	// requests sending them
+	apiServer.mails = mail.NewQueue(mailer, mail.QueueConfig{
+		QueueSize: configs.MailQueueSize(),
+		Timeout:   configs.MailTimeout(),
+	})
+
 	// register custom validators
 	registerCustomValidators()
 
@@ -56,17 +177,58 @@ func NewServer(repository repository.Repository, tokenMaker token.Maker, configs
 
 // addRouteHandlers adds route handlers to server's router
 func (s *server) addRouteHandlers() {
-	s.router.POST("/api/signup", s.signup)
-	s.router.POST("/api/login", s.login)
-	s.router.POST("/api/refresh", s.refreshToken)
+	// the requests of the clients which are not authenticated yet are limited by address
+	authLimit := rateLimitMiddleware(s.authLimiter, clientAddress)
+	s.router.POST("/api/signup", authLimit, s.signup)
+	s.router.POST("/api/login", authLimit, s.login)
+	s.router.POST("/api/login/2fa", authLimit, s.loginTwoFactor)
+	s.router.POST("/api/refresh", authLimit, s.refreshToken)
+	s.router.POST("/api/password/forgot", authLimit, s.forgotPassword)
+	s.router.POST("/api/password/reset", authLimit, s.resetPassword)
+	s.router.POST("/api/email/verify", authLimit, s.verifyEmail)
+	s.router.GET("/api/ready", s.ready)
+
+	// incoming hooks are authenticated by the secret token in their url
+	s.router.POST("/api/hooks/:id", s.postIncomingHook)
 
 	// Set up static files using the Static method
 	s.router.Static("/signup", "./static/signup")
 	s.router.Static("/login", "./static/login")
 	s.router.Static("/chat", "./static/chat")
+	s.router.Static("/reset", "./static/reset")
+	s.router.Static("/verify", "./static/verify")
 
-	authGroup := s.router.Group("/", authMiddleware(s.tokenMaker))
-	authGroup.GET("/api/chat", authMiddleware(s.tokenMaker), s.chat)
+	// bots authenticate with their API token, users with their access token cookie
+	authGroup := s.router.Group("/", apiTokenMiddleware(s.repository), authMiddleware(s.tokenMaker),
+		rateLimitMiddleware(s.apiLimiter, authenticatedUsername))
+	authGroup.GET("/api/chat", s.chat)
+	authGroup.GET("/api/messages/search", s.searchMessages)
+	authGroup.GET("/api/notifications", s.getNotifications)
+	authGroup.POST("/api/notifications/read", s.markNotificationsRead)
+	authGroup.POST("/api/attachments", s.uploadAttachment)
+	authGroup.GET("/api/attachments/:id", s.downloadAttachment)
+	authGroup.GET("/api/attachments/:id/thumbnail", s.downloadThumbnail)
+	authGroup.POST("/api/bots", s.createBot)
+	authGroup.GET("/api/bots", s.getBots)
+	authGroup.GET("/api/bots/me", s.getCurrentBot)
+	authGroup.POST("/api/bots/:username/token", s.replaceBotToken)
+	authGroup.POST("/api/2fa/enroll", s.enrollTwoFactor)
+	authGroup.POST("/api/2fa/confirm", s.confirmTwoFactor)
+	authGroup.POST("/api/2fa/disable", s.disableTwoFactor)
+	authGroup.POST("/api/password", s.changePassword)
+	authGroup.GET("/api/email", s.getEmail)
+	authGroup.POST("/api/email", s.setEmail)
+
+	adminGroup := authGroup.Group("/api/admin", adminMiddleware(s.configs.AdminUsernames()))
+	adminGroup.POST("/webhooks", s.createWebhook)
+	adminGroup.GET("/webhooks", s.getWebhooks)
+	adminGroup.DELETE("/webhooks/:id", s.deleteWebhook)
+	adminGroup.POST("/webhooks/:id/enable", s.enableWebhook)
+	adminGroup.GET("/webhooks/:id/deliveries", s.getWebhookDeliveries)
+	adminGroup.POST("/hooks", s.createIncomingHook)
+	adminGroup.GET("/hooks", s.getIncomingHooks)
+	adminGroup.POST("/hooks/:id/revoke", s.revokeIncomingHook)
+	adminGroup.GET("/lockouts", s.getLockouts)
 
 	// Handle requests that don't match any defined routes
 	s.router.NoRoute(func(c *gin.Context) {
@@ -74,10 +236,95 @@ func (s *server) addRouteHandlers() {
 	})
 }
 
-// Start starts server on the given address
-func (s *server) Start(address string) error {
-	go s.chatHub.RunChatHub(s.repository)
-	return s.router.Run(address)
+// Start starts server on the given address and serves requests until the input context is canceled,
+// then shuts the server down gracefully
+func (s *server) Start(ctx context.Context, address string) error {
+	// the chat hub has its own context so it is stopped only after the http server stops accepting connections
+	hubContext, stopChatHub := context.WithCancel(context.Background())
+	defer stopChatHub()
+
+	// messages of the hub are saved into the repository in batches by the message writer
+	messageWriter := repository.NewMessageWriter(s.repository, repository.MessageWriterConfig{
+		QueueSize:    s.configs.MessageQueueSize(),
+		BatchSize:    s.configs.MessageBatchSize(),
+		BatchWindow:  s.configs.MessageBatchWindow(),
+		MaxRetries:   s.configs.MessageWriteRetries(),
+		RetryBackoff: s.configs.MessageRetryBackoff(),
+	})
+	go s.chatHub.RunChatHub(hubContext, s.repository, messageWriter)
+
+	httpServer := &http.Server{
+		Addr:    address,
+		Handler: s.router,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownContext, cancel := context.WithTimeout(context.Background(), s.configs.ShutdownTimeout())
+	defer cancel()
+
+	// every step of the shutdown runs even when an earlier one fails or the deadline passes, so the clients
+	// still get their close frames and the queued messages are still flushed
+	var errs []error
+
+	// stop accepting new connections and wait for the in-flight requests, the requests still running at the
+	// deadline are cut off
+	if err := httpServer.Shutdown(shutdownContext); err != nil {
+		errs = append(errs, err, httpServer.Close())
+	}
+	if err := <-serverErr; !errors.Is(err, http.ErrServerClosed) {
+		errs = append(errs, err)
+	}
+
+	// finish the queued thumbnails and link previews while the hub can still send them to the clients
+	errs = append(errs, s.thumbnails.Close(shutdownContext))
+	if s.linkPreviews != nil {
+		errs = append(errs, s.linkPreviews.Close(shutdownContext))
+	}
+
+	// send close frames to websocket clients
+	stopChatHub()
+	errs = append(errs, s.chatHub.Wait(shutdownContext))
+
+	// flush the messages waiting to be saved, the saved messages emit the last events
+	errs = append(errs, messageWriter.Close(shutdownContext))
+
+	if s.webhooks != nil {
+		errs = append(errs, s.webhooks.Close(shutdownContext))
+	}
+
+	// send the emails queued by the last requests
+	errs = append(errs, s.mails.Close(shutdownContext))
+
+	return errors.Join(errs...)
+}
+
+// emit emits an event of the input type and data to the webhooks if webhooks are enabled, an event which
+// cannot be queued is dropped
+func (s *server) emit(eventType string, data any) {
+	if s.webhooks == nil {
+		return
+	}
+
+	if err := s.webhooks.Emit(eventType, data); err != nil {
+		log.Println("webhook event dropped:", eventType, err)
+	}
+}
+
+// invalidateWebhooks makes the changes of the webhooks apply to the next event
+func (s *server) invalidateWebhooks() {
+	if s.webhooks != nil {
+		s.webhooks.Invalidate()
+	}
 }
 
 // registerCustomValidators registers custom validators to gin's binding package
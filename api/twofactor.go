@@ -0,0 +1,296 @@
+package api
+
+import (
+	"Chat-Server/otp"
+	"Chat-Server/repository"
+	"Chat-Server/token"
+	"Chat-Server/util"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// twoFactorTokenPurpose is the purpose of the tokens of the second step of the logins
+const twoFactorTokenPurpose = "2fa"
+
+// recoveryCodeCount is the number of recovery codes of a TOTP authenticator
+const recoveryCodeCount = 10
+
+// errors of the two-factor authentication
+var (
+	errInvalidTwoFactorCode  = errors.New("invalid two-factor code")
+	errInvalidTwoFactorToken = errors.New("invalid or expired two-factor token, log in again")
+	errTwoFactorEnabled      = errors.New("two-factor authentication is already enabled")
+	errTwoFactorNotEnabled   = errors.New("two-factor authentication is not enabled")
+	errNoTwoFactorEnrollment = errors.New("no two-factor enrollment to confirm")
+)
+
+// enrollTwoFactor is the handler for the "/api/2fa/enroll" route, generates the secret of a TOTP authenticator
+// of the user. the authenticator is pending until the user confirms it with a code, a new enrollment replaces
+// a pending one
+func (s *server) enrollTwoFactor(context *gin.Context) {
+	if _, ok := context.Get(authorizationBotKey); ok {
+		context.JSON(http.StatusForbidden, errorResponse(fmt.Errorf("bots do not log in with two factors")))
+		return
+	}
+
+	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+	ctx := repository.WithUser(context.Request.Context(), accessTokenPayload.Username)
+
+	twoFactor, err := s.repository.GetTwoFactor(ctx, accessTokenPayload.Username)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		repositoryErrorResponse(context, err)
+		return
+	}
+	if twoFactor != nil && twoFactor.Enabled {
+		context.JSON(http.StatusConflict, errorResponse(errTwoFactorEnabled))
+		return
+	}
+
+	secret, err := otp.GenerateSecret()
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
+		return
+	}
+
+	_, err = s.repository.SetTwoFactor(ctx, &repository.TwoFactor{Username: accessTokenPayload.Username, Secret: secret})
+	if errors.Is(err, repository.ErrNotFound) {
+		context.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("user not found")))
+		return
+	}
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, TwoFactorEnrollmentResponse{
+		Secret: secret,
+		URI:    otp.URI(s.configs.TOTPIssuer(), accessTokenPayload.Username, secret),
+	})
+}
+
+// confirmTwoFactor is the handler for the "/api/2fa/confirm" route, enables the pending TOTP authenticator of
+// the user once it sends a code of it, and responds with the recovery codes, which are never sent again
+func (s *server) confirmTwoFactor(context *gin.Context) {
+	var req ConfirmTwoFactorRequest
+	if err := context.ShouldBindJSON(&req); err != nil {
+		context.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid code")))
+		return
+	}
+
+	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+	ctx := repository.WithUser(context.Request.Context(), accessTokenPayload.Username)
+	now := time.Now()
+
+	// a wrong code is rejected before the recovery codes are hashed, so it does not cost their hashing. the code
+	// is validated again while the authenticator is locked, so it is used once
+	twoFactor, err := s.repository.GetTwoFactor(ctx, accessTokenPayload.Username)
+	if err == nil {
+		_, err = validateTwoFactorConfirmation(twoFactor, req.Code, now)
+	}
+	if err != nil {
+		confirmTwoFactorErrorResponse(context, err)
+		return
+	}
+
+	// only the hashes of the recovery codes are saved, hashed before the authenticator is locked
+	recoveryCodes, err := otp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
+		return
+	}
+	hashes := make([]string, len(recoveryCodes))
+	for i, code := range recoveryCodes {
+		if hashes[i], err = util.HashPassword(code); err != nil {
+			context.JSON(http.StatusInternalServerError, errorResponse(InternalServerError))
+			return
+		}
+	}
+
+	_, err = s.repository.UpdateTwoFactor(ctx, accessTokenPayload.Username, func(twoFactor *repository.TwoFactor) error {
+		step, err := validateTwoFactorConfirmation(twoFactor, req.Code, now)
+		if err != nil {
+			return err
+		}
+
+		twoFactor.Enabled = true
+		twoFactor.LastStep = step
+		twoFactor.RecoveryCodes = hashes
+		return nil
+	})
+	if err != nil {
+		confirmTwoFactorErrorResponse(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+}
+
+// validateTwoFactorConfirmation returns the time step of the input code confirming the input pending
+// authenticator, or the error of a wrong code or of an authenticator which is already enabled
+func validateTwoFactorConfirmation(twoFactor *repository.TwoFactor, code string, now time.Time) (int64, error) {
+	if twoFactor.Enabled {
+		return 0, errTwoFactorEnabled
+	}
+
+	step, ok := otp.Validate(twoFactor.Secret, code, now, twoFactor.LastStep)
+	if !ok {
+		return 0, errInvalidTwoFactorCode
+	}
+
+	return step, nil
+}
+
+// confirmTwoFactorErrorResponse responds with the error of a confirmation of an authenticator
+func confirmTwoFactorErrorResponse(context *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		context.JSON(http.StatusNotFound, errorResponse(errNoTwoFactorEnrollment))
+	case errors.Is(err, errTwoFactorEnabled):
+		context.JSON(http.StatusConflict, errorResponse(err))
+	case errors.Is(err, errInvalidTwoFactorCode):
+		context.JSON(http.StatusUnauthorized, errorResponse(err))
+	default:
+		repositoryErrorResponse(context, err)
+	}
+}
+
+// disableTwoFactor is the handler for the "/api/2fa/disable" route, deletes the TOTP authenticator of the user
+// once it sends its password and a code or a recovery code
+func (s *server) disableTwoFactor(context *gin.Context) {
+	var req DisableTwoFactorRequest
+	if err := context.ShouldBindJSON(&req); err != nil {
+		context.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid password or code")))
+		return
+	}
+
+	accessTokenPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+	username := accessTokenPayload.Username
+	ctx := repository.WithUser(context.Request.Context(), username)
+
+	user, err := s.repository.GetUser(repository.WithPrimary(ctx), username)
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+	if err := util.CheckPassword(req.Password, user.Password); err != nil {
+		context.JSON(http.StatusUnauthorized, errorResponse(fmt.Errorf("wrong password")))
+		return
+	}
+
+	err = s.verifySecondFactor(ctx, username, req.Code)
+	if err == nil {
+		err = s.repository.DeleteTwoFactor(ctx, username)
+	}
+	switch {
+	case errors.Is(err, errTwoFactorNotEnabled), errors.Is(err, repository.ErrNotFound):
+		context.JSON(http.StatusNotFound, errorResponse(errTwoFactorNotEnabled))
+	case errors.Is(err, errInvalidTwoFactorCode):
+		context.JSON(http.StatusUnauthorized, errorResponse(err))
+	case err != nil:
+		repositoryErrorResponse(context, err)
+	default:
+		context.Status(http.StatusNoContent)
+	}
+}
+
+// loginTwoFactor is the handler for the "/api/login/2fa" route, the second step of the login of a user with a
+// TOTP authenticator. the token of the first step and a code or a recovery code set the cookies of the login
+func (s *server) loginTwoFactor(context *gin.Context) {
+	var req LoginTwoFactorRequest
+	if err := context.ShouldBindJSON(&req); err != nil {
+		context.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid token or code")))
+		return
+	}
+
+	payload, err := s.tokenMaker.VerifyPurposeToken(req.TwoFactorToken, twoFactorTokenPurpose)
+	if err != nil {
+		context.JSON(http.StatusUnauthorized, errorResponse(errInvalidTwoFactorToken))
+		return
+	}
+
+	ctx := repository.WithUser(context.Request.Context(), payload.Username)
+	address := clientAddress(context)
+
+	// the wrong codes count as failed logins of the username, so the codes are not guessed either
+	retryAfter, err := s.loginGuard.check(ctx, payload.Username, address)
+	if err != nil {
+		repositoryErrorResponse(context, err)
+		return
+	}
+	if retryAfter > 0 {
+		abortTooManyRequests(context, retryAfter, errLoginThrottled)
+		return
+	}
+
+	err = s.verifySecondFactor(ctx, payload.Username, req.Code)
+	switch {
+	case errors.Is(err, errInvalidTwoFactorCode), errors.Is(err, errTwoFactorNotEnabled):
+		if err := s.loginGuard.fail(ctx, payload.Username, address); err != nil {
+			log.Printf("error: recording the failed login of %q: %v", payload.Username, err)
+		}
+		context.JSON(http.StatusUnauthorized, errorResponse(errInvalidTwoFactorCode))
+		return
+	case err != nil:
+		repositoryErrorResponse(context, err)
+		return
+	}
+
+	if err := s.loginGuard.succeed(ctx, payload.Username); err != nil {
+		log.Printf("error: clearing the failed logins of %q: %v", payload.Username, err)
+	}
+
+	s.setAuthCookies(context, payload.Username)
+}
+
+// verifySecondFactor checks the input code or recovery code of the enabled TOTP authenticator of a user and
+// uses it up, so it is accepted once. returns errInvalidTwoFactorCode if it is wrong and errTwoFactorNotEnabled
+// if the user has no enabled authenticator
+func (s *server) verifySecondFactor(ctx context.Context, username, code string) error {
+	twoFactor, err := s.repository.GetTwoFactor(ctx, username)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && !twoFactor.Enabled) {
+		return errTwoFactorNotEnabled
+	}
+	if err != nil {
+		return err
+	}
+
+	if otp.IsCode(code) {
+		now := time.Now()
+		_, err = s.repository.UpdateTwoFactor(ctx, username, func(twoFactor *repository.TwoFactor) error {
+			step, ok := otp.Validate(twoFactor.Secret, code, now, twoFactor.LastStep)
+			if !ok {
+				return errInvalidTwoFactorCode
+			}
+			twoFactor.LastStep = step
+			return nil
+		})
+		return err
+	}
+
+	// the hashes of the recovery codes are checked before the authenticator is locked, the matching one is
+	// then removed unless a concurrent request used it first
+	code = otp.NormalizeRecoveryCode(code)
+	i := slices.IndexFunc(twoFactor.RecoveryCodes, func(hash string) bool {
+		return util.CheckPassword(code, hash) == nil
+	})
+	if i < 0 {
+		return errInvalidTwoFactorCode
+	}
+	hash := twoFactor.RecoveryCodes[i]
+
+	_, err = s.repository.UpdateTwoFactor(ctx, username, func(twoFactor *repository.TwoFactor) error {
+		i := slices.Index(twoFactor.RecoveryCodes, hash)
+		if i < 0 {
+			return errInvalidTwoFactorCode
+		}
+		twoFactor.RecoveryCodes = slices.Delete(twoFactor.RecoveryCodes, i, i+1)
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"Chat-Server/otp"
+	"Chat-Server/repository"
+	"Chat-Server/repository/db/memory"
+	"Chat-Server/token"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTwoFactor tests enrolling a TOTP authenticator, logging in with its codes and recovery codes, and
+// disabling it
+func TestTwoFactor(t *testing.T) {
+	repo := memory.NewMemoryRepository()
+	tokenMaker, err := token.NewPasetoMaker(testConfigs.TokenSymmetricKey())
+	require.NoError(t, err)
+	server := NewTestServer(t, repo, tokenMaker)
+
+	// the wrong codes of the test are not delayed
+	server.loginGuard.maxFailures, server.loginGuard.failureDelay = 10, 0
+
+	user, password := randomUser(t)
+	_, err = repo.AddUser(context.Background(), user)
+	require.NoError(t, err)
+
+	serve := func(path string, body any, accessToken string) *httptest.ResponseRecorder {
+		jsonBody, err := json.Marshal(body)
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, path, bytes.NewBuffer(jsonBody))
+		require.NoError(t, err)
+		if accessToken != "" {
+			req.AddCookie(&http.Cookie{Name: authorizationCookieName, Value: accessToken})
+		}
+
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, req)
+		return recorder
+	}
+	// the token outlives the password and recovery code hashing of the test, also under the race detector
+	accessToken, _ := createToken(t, user.Username, time.Hour)
+	login := func() *httptest.ResponseRecorder {
+		return serve("/api/login", LoginRequest{Username: user.Username, Password: password}, "")
+	}
+
+	require.Equal(t, http.StatusNotFound, serve("/api/2fa/confirm", ConfirmTwoFactorRequest{Code: "123456"}, accessToken).Code)
+
+	// the authenticator is pending until it is confirmed, a new enrollment replaces it
+	require.Equal(t, http.StatusOK, serve("/api/2fa/enroll", nil, accessToken).Code)
+	recorder := serve("/api/2fa/enroll", nil, accessToken)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var enrollment TwoFactorEnrollmentResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &enrollment))
+	uri, err := url.Parse(enrollment.URI)
+	require.NoError(t, err)
+	require.Equal(t, enrollment.Secret, uri.Query().Get("secret"))
+	require.Equal(t, testConfigs.TOTPIssuer(), uri.Query().Get("issuer"))
+	require.Equal(t, "/"+testConfigs.TOTPIssuer()+":"+user.Username, uri.Path)
+
+	recorder = login()
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.NotEmpty(t, recorder.Result().Cookies())
+
+	// codes returns the code of the authenticator at the input time and a wrong one
+	codes := func(at time.Time) (string, string) {
+		code, err := otp.Code(enrollment.Secret, at)
+		require.NoError(t, err)
+		n, err := strconv.Atoi(code)
+		require.NoError(t, err)
+		return code, fmt.Sprintf("%06d", (n+1)%1_000_000)
+	}
+
+	now := time.Now()
+	code, wrongCode := codes(now)
+	require.Equal(t, http.StatusUnauthorized, serve("/api/2fa/confirm", ConfirmTwoFactorRequest{Code: wrongCode}, accessToken).Code)
+	recorder = serve("/api/2fa/confirm", ConfirmTwoFactorRequest{Code: code}, accessToken)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var recovery RecoveryCodesResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &recovery))
+	require.Len(t, recovery.RecoveryCodes, recoveryCodeCount)
+
+	// only the hashes of the recovery codes are saved
+	twoFactor, err := repo.GetTwoFactor(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.True(t, twoFactor.Enabled)
+	require.Len(t, twoFactor.RecoveryCodes, recoveryCodeCount)
+	require.NotContains(t, twoFactor.RecoveryCodes, recovery.RecoveryCodes[0])
+
+	require.Equal(t, http.StatusConflict, serve("/api/2fa/confirm", ConfirmTwoFactorRequest{Code: code}, accessToken).Code)
+	require.Equal(t, http.StatusConflict, serve("/api/2fa/enroll", nil, accessToken).Code)
+
+	// the password only gets the token of the second step, which is not an access token
+	recorder = login()
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Empty(t, recorder.Result().Cookies())
+	var challenge TwoFactorChallengeResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &challenge))
+	require.NotEmpty(t, challenge.TwoFactorToken)
+	require.Equal(t, http.StatusUnauthorized, serve("/api/2fa/enroll", nil, challenge.TwoFactorToken).Code)
+
+	secondStep := func(twoFactorToken, code string) *httptest.ResponseRecorder {
+		return serve("/api/login/2fa", LoginTwoFactorRequest{TwoFactorToken: twoFactorToken, Code: code}, "")
+	}
+
+	require.Equal(t, http.StatusUnauthorized, secondStep(accessToken, code).Code)
+	require.Equal(t, http.StatusUnauthorized, secondStep(challenge.TwoFactorToken, wrongCode).Code)
+
+	// the code used to confirm the enrollment is not accepted again, the code of the next step is, once
+	require.Equal(t, http.StatusUnauthorized, secondStep(challenge.TwoFactorToken, code).Code)
+	nextCode, _ := codes(now.Add(otp.Period))
+	recorder = secondStep(challenge.TwoFactorToken, nextCode)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	checkLoginCookies(t, user.Username, recorder)
+	require.Equal(t, http.StatusUnauthorized, secondStep(challenge.TwoFactorToken, nextCode).Code)
+
+	// a recovery code is accepted once, in any case
+	recoveryCode := strings.ToUpper(recovery.RecoveryCodes[3])
+	recorder = secondStep(challenge.TwoFactorToken, recoveryCode)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	checkLoginCookies(t, user.Username, recorder)
+	require.Equal(t, http.StatusUnauthorized, secondStep(challenge.TwoFactorToken, recoveryCode).Code)
+
+	twoFactor, err = repo.GetTwoFactor(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Len(t, twoFactor.RecoveryCodes, recoveryCodeCount-1)
+
+	// the wrong codes count as failed logins until the next login
+	throttle, err := repo.GetLoginThrottle(context.Background(), "username:"+user.Username)
+	require.NoError(t, err)
+	require.Equal(t, 1, throttle.Failures)
+
+	// disabling the authenticator takes the password and a code or a recovery code
+	disable := func(password, code string) int {
+		return serve("/api/2fa/disable", DisableTwoFactorRequest{Password: password, Code: code}, accessToken).Code
+	}
+	require.Equal(t, http.StatusUnauthorized, disable("wrong_password", recovery.RecoveryCodes[0]))
+	require.Equal(t, http.StatusUnauthorized, disable(password, recovery.RecoveryCodes[3]))
+	require.Equal(t, http.StatusNoContent, disable(password, recovery.RecoveryCodes[0]))
+	require.Equal(t, http.StatusNotFound, disable(password, recovery.RecoveryCodes[1]))
+
+	_, err = repo.GetTwoFactor(context.Background(), user.Username)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	recorder = login()
+	require.Equal(t, http.StatusOK, recorder.Code)
+	checkLoginCookies(t, user.Username, recorder)
+
+	// the token of the second step is rejected once the authenticator is disabled
+	require.Equal(t, http.StatusUnauthorized, secondStep(challenge.TwoFactorToken, recovery.RecoveryCodes[1]).Code)
+}
+
+// checkLoginCookies checks the response of a login sets the cookies of the input user
+func checkLoginCookies(t *testing.T, username string, recorder *httptest.ResponseRecorder) {
+	cookies := map[string]string{}
+	for _, cookie := range recorder.Result().Cookies() {
+		cookies[cookie.Name] = cookie.Value
+	}
+
+	require.NotEmpty(t, cookies["accessToken"])
+	require.NotEmpty(t, cookies["refreshToken"])
+	require.Equal(t, username, cookies["username"])
+}
@@ -1,9 +1,17 @@
 package ws
 
 import (
+	"Chat-Server/markup"
+	"Chat-Server/repository"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -20,7 +28,16 @@ const (
 	pingPeriod = (pongWait * 9) / 10
 
 	// Maximum message size allowed from peer.
-	maxMessageSize = 1024
+	maxMessageSize = 2048
+
+	// Maximum number of attachments of a message.
+	maxAttachments = 10
+
+	// Maximum number of links of a message which are previewed.
+	maxLinkPreviews = 3
+
+	// Time allowed to look up the attachments and the rate limit of a message.
+	lookupTimeout = 5 * time.Second
 )
 
 // Upgrader is a websocket Upgrader instance with the desired configurations
@@ -42,25 +59,51 @@ type Client struct {
 	// The websocket connection.
 	conn *websocket.Conn
 
-	// Buffered channel of outbound messages.
-	send chan Message
+	// Buffered channel of outbound events.
+	send chan Event
+
+	// skipHistory is set for clients which only receive the messages sent after they register, e.g. bots
+	skipHistory bool
+
+	// bot is set for the clients of bots, which may register commands
+	bot bool
 }
 
 // NewClient creates and returns a new Client object
-func NewClient(hub *Hub, conn *websocket.Conn, send chan Message, username string) *Client {
+func NewClient(hub *Hub, conn *websocket.Conn, send chan Event, username string) *Client {
 	return &Client{hub: hub, conn: conn, send: send, username: username}
 }
 
-// Register the client to the hub
-func (c *Client) Register() {
-	c.hub.register <- c
+// SkipHistory makes the client receive only the messages sent after it registers, instead of all the
+// previous messages first. it is called before Register
+func (c *Client) SkipHistory() {
+	c.skipHistory = true
+}
+
+// SetBot marks the client as the client of a bot, bots may register slash commands. it is called before
+// Register
+func (c *Client) SetBot() {
+	c.bot = true
+}
+
+// Register the client to the hub, returns ErrHubClosed if the hub is not running anymore
+func (c *Client) Register() error {
+	select {
+	case c.hub.register <- c:
+		return nil
+	case <-c.hub.done:
+		return ErrHubClosed
+	}
 }
 
 // Read reads messages from the websocket connection and sends them to the hub.
 func (c *Client) Read() {
 	// unregister the client and close the connection after method done executing
 	defer func() {
-		c.hub.unregister <- c
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.done:
+		}
 		c.conn.Close()
 	}()
 
@@ -79,18 +122,155 @@ func (c *Client) Read() {
 			break
 		}
 
-		// create a Message instance and initialize it with the text and its author
-		message := Message{
-			Author: c.username,   // author will be client's username
-			Text:   string(text), // text is the text read from the client
+		frame := parseFrame(text)
+
+		// a frame over the rate limit of the user is reported to the client instead
+		if err := c.checkRateLimit(); err != nil {
+			select {
+			case c.hub.broadcast <- inboundMessage{sender: c, err: err}:
+			case <-c.hub.done:
+				return
+			}
+			continue
+		}
+
+		// commands, command registrations and replies of bots are handled by the hub instead of broadcast
+		if request, ok := c.commandRequest(frame); ok {
+			select {
+			case c.hub.commands <- request:
+			case <-c.hub.done:
+				return
+			}
+			continue
+		}
+
+		// "//" escapes a message starting with a slash
+		if strings.HasPrefix(frame.Text, "//") {
+			frame.Text = frame.Text[1:]
 		}
 
+		// create a Message of the text read from the client, its author is the client's username
+		message := c.hub.newMessage(c.username, frame.Text)
+
+		// resolve the attachments, a message with an invalid attachment is reported to the client instead
+		attachments, err := c.resolveAttachments(frame.Attachments)
+		message.Attachments = attachments
+
 		// send the message to the hub (hub will save the message and broadcast it to other clients)
-		c.hub.broadcast <- message
+		select {
+		case c.hub.broadcast <- inboundMessage{sender: c, message: message, err: err}:
+		case <-c.hub.done:
+			return
+		}
+	}
+}
+
+// checkRateLimit takes a token from the bucket of the user of the client, returns an error telling the
+// client when to retry if the bucket is empty
+func (c *Client) checkRateLimit() error {
+	ctx, cancel := c.lookupContext()
+	defer cancel()
+
+	allowed, retryAfter, err := c.hub.config.MessageLimiter.Allow(ctx, c.username)
+	if err != nil {
+		// the messages are let through while the buckets are unavailable
+		log.Printf("error: cannot rate limit message: %v", err)
+		return nil
+	}
+	if !allowed {
+		return fmt.Errorf("%w, retry in %ds", errTooManyMessages, int(math.Ceil(retryAfter.Seconds())))
 	}
+
+	return nil
+}
+
+// commandRequest returns the command request of the input frame, false if the frame is a chat message
+func (c *Client) commandRequest(frame inboundFrame) (commandRequest, bool) {
+	switch {
+	case frame.Command != nil:
+		return commandRequest{sender: c, spec: frame.Command}, true
+	case frame.ReplyTo != "":
+		return commandRequest{sender: c, reply: &commandReply{id: frame.ReplyTo, text: frame.Text, err: frame.Error}}, true
+	case isCommand(frame.Text):
+		return commandRequest{sender: c, text: frame.Text}, true
+	default:
+		return commandRequest{}, false
+	}
+}
+
+// render returns the sanitized html and the entities of the input text
+func render(text string) (string, []Entity) {
+	result := markup.Render(text)
+
+	var entities []Entity
+	for _, entity := range result.Entities {
+		entities = append(entities, Entity(entity))
+	}
+
+	return result.HTML, entities
+}
+
+// parseFrame parses a frame read from the client, a frame which is not a JSON object of inboundFrame
+// is the plain text of a message
+func parseFrame(data []byte) inboundFrame {
+	var frame inboundFrame
+	if len(data) > 0 && data[0] == '{' {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&frame); err == nil && !decoder.More() {
+			return frame
+		}
+	}
+
+	return inboundFrame{Text: string(data)}
+}
+
+// lookupContext returns the context of a lookup made on behalf of the user of the client, e.g. of the
+// attachments of a message, bounded by lookupTimeout
+func (c *Client) lookupContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(repository.WithUser(context.Background(), c.username), lookupTimeout)
+}
+
+// resolveAttachments retrieves the attachments of the input ids, which must be unattached attachments
+// uploaded by the client
+func (c *Client) resolveAttachments(ids []string) ([]Attachment, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if c.hub.config.Attachments == nil {
+		return nil, errAttachmentsDisabled
+	}
+	if len(ids) > maxAttachments {
+		return nil, errTooManyAttachments
+	}
+
+	// the attachments were uploaded by the user, so they are read like the other writes of the user
+	ctx, cancel := c.lookupContext()
+	defer cancel()
+
+	attachments := make([]Attachment, len(ids))
+	for i, id := range ids {
+		attachment, err := c.hub.config.Attachments.GetAttachment(ctx, id)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, errAttachmentNotFound
+		}
+		if err != nil {
+			log.Printf("error: cannot look up attachment %s: %v", id, err)
+			return nil, errAttachmentLookup
+		}
+
+		// attachments of other users and attachments of sent messages cannot be attached
+		if attachment.Owner != c.username || attachment.MessageID != 0 {
+			return nil, errAttachmentNotFound
+		}
+
+		attachments[i] = c.hub.toHubAttachment(attachment)
+	}
+
+	return attachments, nil
 }
 
-// Write receives messages from the hub and sends them to the client.
+// Write receives events from the hub and sends them to the client.
 func (c *Client) Write() {
 	// ticker is used to send ping messages periodically
 	ticker := time.NewTicker(pingPeriod)
@@ -104,7 +284,7 @@ func (c *Client) Write() {
 	// start write loop
 	for {
 		select {
-		case message, ok := <-c.send:
+		case event, ok := <-c.send:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
 				// the hub closed the channel.
@@ -117,11 +297,11 @@ func (c *Client) Write() {
 				return
 			}
 
-			jsonMessage, err := json.Marshal(message)
+			jsonEvent, err := json.Marshal(event)
 			if err != nil {
 				return
 			}
-			w.Write(jsonMessage)
+			w.Write(jsonEvent)
 
 			if err := w.Close(); err != nil {
 				return
@@ -148,11 +328,11 @@ func (c *Client) WriteMessages(messages []*Message) error {
 			return err
 		}
 
-		jsonMessage, err := json.Marshal(message)
+		jsonEvent, err := json.Marshal(Event{Type: MessageEvent, Message: message})
 		if err != nil {
 			continue
 		}
-		w.Write(jsonMessage)
+		w.Write(jsonEvent)
 
 		if err := w.Close(); err != nil {
 			return err
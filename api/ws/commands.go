@@ -0,0 +1,407 @@
+package ws
+
+import (
+	"Chat-Server/repository"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Permission defines who may run a command
+type Permission string
+
+const (
+	// PermissionEveryone lets every user run the command
+	PermissionEveryone Permission = "everyone"
+	// PermissionAdmin lets only the administrators of the hub run the command
+	PermissionAdmin Permission = "admin"
+)
+
+// errors of the commands reported to the clients in error events
+var (
+	errNotBot             = errors.New("only bots register commands")
+	errInvalidCommand     = errors.New("invalid command")
+	errCommandExists      = errors.New("command already registered")
+	errInvocationNotFound = errors.New("command invocation not found")
+	errBotLeft            = errors.New("the bot of the command left before replying")
+	errNicknameTaken      = errors.New("nickname is taken")
+	errInvalidNickname    = errors.New("nicknames have 2 to 32 letters, digits, '_' or '-'")
+)
+
+// maxPendingInvocations is the maximum number of invocations of bot commands waiting for the replies of the bots
+const maxPendingInvocations = 1024
+
+var (
+	// commandNameRegex matches the names of the commands
+	commandNameRegex = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+	// nicknameRegex matches the nicknames
+	nicknameRegex = regexp.MustCompile(`^[\p{L}\p{N}_-]{2,32}$`)
+)
+
+// CommandHandler runs a command, it is called on the hub goroutine so it must not block. a returned error is
+// sent to the invoker in an error event
+type CommandHandler func(call *CommandCall) error
+
+// Command is a slash command the clients run by sending a message starting with "/" and its name, e.g.
+// "/nick alice". the message is not broadcast, the command handles it instead
+type Command struct {
+	Name        string     // name of the command, without the slash
+	Usage       string     // arguments of the command shown by /help, e.g. "<nickname>"
+	Description string     // what the command does, shown by /help
+	Permission  Permission // who may run the command, everyone if empty
+	Handler     CommandHandler
+
+	// bot is the client of the bot the invocations are sent to, nil for the commands handled by the hub
+	bot *Client
+}
+
+// CommandCall is an invocation of a command handled by the hub
+type CommandCall struct {
+	Command  *Command
+	Username string // username of the invoker
+	Args     string // text after the name of the command, without surrounding white space
+
+	hub    *Hub
+	client *Client
+	writer *repository.MessageWriter
+}
+
+// Fields returns the arguments of the command split on white space
+func (c *CommandCall) Fields() []string {
+	return strings.Fields(c.Args)
+}
+
+// Reply sends the input text to the invoker only, in a reply event. the reply is not saved
+func (c *CommandCall) Reply(text string) {
+	message := c.hub.newMessage("", text)
+	c.hub.sendEvent(c.client, Event{Type: ReplyEvent, Message: &message})
+}
+
+// Post sends a message of the input text to the chat as the invoker, it is saved and broadcast like the
+// messages of the clients
+func (c *CommandCall) Post(text string) {
+	c.hub.receive(c.writer, inboundMessage{sender: c.client, message: c.hub.newMessage(c.Username, text)})
+}
+
+// UsageError returns the error telling the invoker how the command is used
+func (c *CommandCall) UsageError() error {
+	return fmt.Errorf("usage: %s", c.Command.synopsis())
+}
+
+// synopsis returns the name and usage of the command, e.g. "/nick <nickname>"
+func (c *Command) synopsis() string {
+	if c.Usage == "" {
+		return "/" + c.Name
+	}
+
+	return "/" + c.Name + " " + c.Usage
+}
+
+// isCommand reports whether the input text of a message is a slash command, "//" escapes a message
+// starting with a slash
+func isCommand(text string) bool {
+	return strings.HasPrefix(text, "/") && !strings.HasPrefix(text, "//")
+}
+
+// runCommand handles a command request of a client on the hub goroutine
+func (h *Hub) runCommand(w *repository.MessageWriter, request commandRequest) {
+	if _, ok := h.clients[request.sender]; !ok {
+		return
+	}
+
+	switch {
+	case request.spec != nil:
+		h.registerBotCommand(request.sender, request.spec)
+	case request.reply != nil:
+		h.replyToInvocation(request.sender, request.reply)
+	default:
+		h.invoke(w, request.sender, request.text)
+	}
+}
+
+// invoke runs the slash command of the input text for the input client
+func (h *Hub) invoke(w *repository.MessageWriter, client *Client, text string) {
+	name, args, _ := strings.Cut(strings.TrimPrefix(text, "/"), " ")
+	if i := strings.IndexFunc(name, unicode.IsSpace); i >= 0 {
+		name, args = name[:i], name[i:]+" "+args
+	}
+	args = strings.TrimSpace(args)
+
+	command, ok := h.registry[name]
+	if !ok {
+		h.sendError(client, fmt.Errorf("unknown command /%s, see /help", name))
+		return
+	}
+	if !h.allowed(command, client) {
+		h.sendError(client, fmt.Errorf("you are not allowed to run /%s", name))
+		return
+	}
+
+	if command.bot != nil {
+		h.forward(client, command, args)
+		return
+	}
+
+	call := &CommandCall{Command: command, Username: client.username, Args: args, hub: h, client: client, writer: w}
+	if err := command.Handler(call); err != nil {
+		h.sendError(client, err)
+	}
+}
+
+// allowed reports whether the input client may run the input command, bots are never administrators
+func (h *Hub) allowed(command *Command, client *Client) bool {
+	if command.Permission == PermissionAdmin {
+		return !client.bot && slices.Contains(h.config.Admins, client.username)
+	}
+
+	return true
+}
+
+// forward sends an invocation of the input bot command to its bot, the bot replies to the invoker
+func (h *Hub) forward(invoker *Client, command *Command, args string) {
+	if len(h.invocations) >= maxPendingInvocations {
+		h.sendError(invoker, errHubBusy)
+		return
+	}
+
+	h.lastInvocationID++
+	id := strconv.FormatUint(h.lastInvocationID, 10)
+	h.invocations[id] = invocation{invoker: invoker, bot: command.bot}
+
+	h.sendEvent(command.bot, Event{Type: CommandEvent, Command: &CommandInvocation{
+		ID:      id,
+		Name:    command.Name,
+		Args:    args,
+		Invoker: invoker.username,
+	}})
+}
+
+// replyToInvocation sends the reply of a bot to the invoker of its command
+func (h *Hub) replyToInvocation(bot *Client, reply *commandReply) {
+	invocation, ok := h.invocations[reply.id]
+	if !ok || invocation.bot != bot {
+		h.sendError(bot, errInvocationNotFound)
+		return
+	}
+	delete(h.invocations, reply.id)
+
+	if reply.err != "" {
+		h.sendError(invocation.invoker, errors.New(reply.err))
+		return
+	}
+	if reply.text == "" {
+		return
+	}
+
+	message := h.newMessage(bot.username, reply.text)
+	message.Nickname = h.nicknames[bot.username]
+	h.sendEvent(invocation.invoker, Event{Type: ReplyEvent, Message: &message})
+}
+
+// registerBotCommand registers a command of the input bot, its invocations are sent to the bot until it leaves
+func (h *Hub) registerBotCommand(bot *Client, spec *CommandSpec) {
+	if !bot.bot {
+		h.sendError(bot, errNotBot)
+		return
+	}
+	if !commandNameRegex.MatchString(spec.Name) || len(spec.Usage) > 64 || len(spec.Description) > 256 {
+		h.sendError(bot, errInvalidCommand)
+		return
+	}
+	if spec.Permission != "" && spec.Permission != PermissionEveryone && spec.Permission != PermissionAdmin {
+		h.sendError(bot, errInvalidCommand)
+		return
+	}
+	if _, ok := h.registry[spec.Name]; ok {
+		h.sendError(bot, errCommandExists)
+		return
+	}
+
+	h.registry[spec.Name] = &Command{
+		Name:        spec.Name,
+		Usage:       spec.Usage,
+		Description: spec.Description,
+		Permission:  spec.Permission,
+		bot:         bot,
+	}
+}
+
+// removeBotCommands removes the commands of the input client if it is a bot, the invokers waiting for its
+// replies are told it left
+func (h *Hub) removeBotCommands(client *Client) {
+	if !client.bot {
+		return
+	}
+
+	for name, command := range h.registry {
+		if command.bot == client {
+			delete(h.registry, name)
+		}
+	}
+	for id, invocation := range h.invocations {
+		if invocation.bot == client {
+			delete(h.invocations, id)
+			h.sendError(invocation.invoker, errBotLeft)
+		}
+	}
+}
+
+// builtinCommands returns the commands every hub has
+func builtinCommands() []*Command {
+	return []*Command{
+		{Name: "help", Usage: "[command]", Description: "list the commands you can run", Handler: helpCommand},
+		{Name: "me", Usage: "<action>", Description: "send an action, e.g. /me waves", Handler: meCommand},
+		{Name: "nick", Usage: "[nickname]", Description: "set your nickname, or clear it", Handler: nickCommand},
+		{Name: "who", Description: "list the connected users", Handler: whoCommand},
+	}
+}
+
+// helpCommand replies with the commands the invoker may run, or with the usage of one command
+func helpCommand(call *CommandCall) error {
+	h := call.hub
+
+	if call.Args != "" {
+		command, ok := h.registry[strings.TrimPrefix(call.Args, "/")]
+		if !ok || !h.allowed(command, call.client) {
+			return fmt.Errorf("unknown command /%s", strings.TrimPrefix(call.Args, "/"))
+		}
+		call.Reply(command.synopsis() + ": " + command.Description)
+		return nil
+	}
+
+	var lines []string
+	for _, command := range h.registry {
+		if h.allowed(command, call.client) {
+			lines = append(lines, command.synopsis()+": "+command.Description)
+		}
+	}
+	slices.Sort(lines)
+	call.Reply(strings.Join(lines, "\n"))
+
+	return nil
+}
+
+// meCommand posts the action of the invoker as a message, e.g. "/me waves" posts "*alice waves*"
+func meCommand(call *CommandCall) error {
+	if call.Args == "" {
+		return call.UsageError()
+	}
+
+	call.Post("*" + call.Username + " " + call.Args + "*")
+	return nil
+}
+
+// nickCommand sets the nickname the messages of the invoker are broadcast with, until the hub stops. the
+// registered users are looked up off the hub goroutine, the nickname is set once they are
+func nickCommand(call *CommandCall) error {
+	h := call.hub
+
+	if call.Args == "" {
+		delete(h.nicknames, call.Username)
+		call.Reply("your nickname is cleared")
+		return nil
+	}
+	if !nicknameRegex.MatchString(call.Args) {
+		return errInvalidNickname
+	}
+	if err := h.checkNickname(call.Username, call.Args); err != nil {
+		return err
+	}
+
+	if h.config.Users == nil {
+		h.setNickname(nicknameCheck{client: call.client, nickname: call.Args})
+		return nil
+	}
+
+	client, nickname := call.client, call.Args
+	go func() {
+		ctx, cancel := client.lookupContext()
+		defer cancel()
+
+		user, err := h.config.Users.GetUser(ctx, nickname)
+		switch {
+		case err == nil && user.Username != client.username:
+			err = errNicknameTaken
+		case err == nil, errors.Is(err, repository.ErrNotFound):
+			err = nil
+		default:
+			log.Printf("error: cannot look up the user of nickname %q: %v", nickname, err)
+			err = errHubBusy
+		}
+
+		// the hub does not set nicknames after it stops
+		select {
+		case h.nicknameChecks <- nicknameCheck{client: client, nickname: nickname, err: err}:
+		case <-h.done:
+		}
+	}()
+
+	return nil
+}
+
+// checkNickname returns errNicknameTaken if the input nickname passes for another connected user, or is the
+// nickname of another user
+func (h *Hub) checkNickname(username, nickname string) error {
+	for client := range h.clients {
+		if client.username != username && strings.EqualFold(client.username, nickname) {
+			return errNicknameTaken
+		}
+	}
+	for other, otherNickname := range h.nicknames {
+		if other != username && strings.EqualFold(otherNickname, nickname) {
+			return errNicknameTaken
+		}
+	}
+
+	return nil
+}
+
+// setNickname sets the nickname of a checked /nick of a client, the connected users are checked again since
+// they may have changed during the lookup of the registered users
+func (h *Hub) setNickname(check nicknameCheck) {
+	if _, ok := h.clients[check.client]; !ok {
+		return
+	}
+
+	err := check.err
+	if err == nil {
+		err = h.checkNickname(check.client.username, check.nickname)
+	}
+	if err != nil {
+		h.sendError(check.client, err)
+		return
+	}
+
+	h.nicknames[check.client.username] = check.nickname
+	message := h.newMessage("", "you are now known as "+check.nickname)
+	h.sendEvent(check.client, Event{Type: ReplyEvent, Message: &message})
+}
+
+// whoCommand replies with the connected users, with their nicknames
+func whoCommand(call *CommandCall) error {
+	h := call.hub
+
+	var users []string
+	for client := range h.clients {
+		user := client.username
+		if nickname, ok := h.nicknames[user]; ok {
+			user += " (" + nickname + ")"
+		}
+		if client.bot {
+			user += " [bot]"
+		}
+		users = append(users, user)
+	}
+	slices.Sort(users)
+	users = slices.Compact(users)
+
+	call.Reply(fmt.Sprintf("%d online: %s", len(users), strings.Join(users, ", ")))
+
+	return nil
+}
@@ -0,0 +1,300 @@
+package ws
+
+import (
+	"Chat-Server/ratelimit"
+	"Chat-Server/repository"
+	"Chat-Server/repository/db/memory"
+	"Chat-Server/util"
+	"Chat-Server/webhook"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestBotServer returns a test http server which connects its websocket clients to the input hub as bots
+func newTestBotServer(t *testing.T, hub *Hub, username string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		client := NewClient(hub, conn, make(chan Event, 10), username)
+		client.SkipHistory()
+		client.SetBot()
+		if err := client.Register(); err != nil {
+			return
+		}
+
+		go client.Write()
+		client.Read()
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// runTestCommandHub runs a hub of the input configurations and returns it with the emitter of its events, the
+// input users are added to its repository
+func runTestCommandHub(t *testing.T, config HubConfig, usernames ...string) (*Hub, *testEmitter) {
+	repo := memory.NewMemoryRepository()
+	for _, username := range usernames {
+		_, err := repo.AddUser(context.Background(), &repository.User{Username: username})
+		require.NoError(t, err)
+	}
+
+	emitter := &testEmitter{events: make(chan webhook.Event, 10)}
+	config.Events = emitter
+	config.Users = repo
+
+	hub := NewHub(config)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go hub.RunChatHub(ctx, repo, newTestMessageWriter(t, repo))
+
+	return hub, emitter
+}
+
+// sendText sends the input text to the hub and returns the next event received
+func sendText(t *testing.T, conn *websocket.Conn, text string) Event {
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(text)))
+	return readEvent(t, conn)
+}
+
+// readEvent returns the next event received from the hub
+func readEvent(t *testing.T, conn *websocket.Conn) Event {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	var event Event
+	require.NoError(t, conn.ReadJSON(&event))
+
+	return event
+}
+
+// TestHub_Commands tests the built-in commands, the replies sent to the invoker only, the usage errors and
+// the permissions of the commands
+func TestHub_Commands(t *testing.T) {
+	alice, bob, admin := util.RandomUsername(), util.RandomUsername()+"_b", util.RandomUsername()+"_admin"
+	offline, bot := util.RandomUsername()+"_off", util.RandomUsername()+"_bot"
+	kick := Command{
+		Name:        "kick",
+		Usage:       "<username>",
+		Description: "disconnect a user",
+		Permission:  PermissionAdmin,
+		Handler: func(call *CommandCall) error {
+			if len(call.Fields()) != 1 {
+				return call.UsageError()
+			}
+			call.Reply("kicked " + call.Args)
+			return nil
+		},
+	}
+	hub, emitter := runTestCommandHub(t, HubConfig{Admins: []string{admin}, Commands: []Command{kick}}, alice, bob, admin, offline, bot)
+
+	aliceConn := dialTestHubServer(t, newTestHubServer(t, hub, alice))
+	require.Equal(t, webhook.EventUserJoined, receiveEmitted(t, emitter).Type)
+	bobConn := dialTestHubServer(t, newTestHubServer(t, hub, bob))
+	require.Equal(t, webhook.EventUserJoined, receiveEmitted(t, emitter).Type)
+	adminConn := dialTestHubServer(t, newTestHubServer(t, hub, admin))
+	require.Equal(t, webhook.EventUserJoined, receiveEmitted(t, emitter).Type)
+
+	users := []string{alice, bob, admin}
+	slices.Sort(users)
+	event := sendText(t, aliceConn, "/who")
+	require.Equal(t, ReplyEvent, event.Type)
+	require.Equal(t, "3 online: "+strings.Join(users, ", "), event.Message.Text)
+
+	event = sendText(t, aliceConn, "/nick x")
+	require.Equal(t, ErrorEvent, event.Type)
+	require.Equal(t, errInvalidNickname.Error(), event.Error)
+
+	event = sendText(t, aliceConn, "/nick  Ally ")
+	require.Equal(t, ReplyEvent, event.Type)
+	require.Equal(t, "you are now known as Ally", event.Message.Text)
+
+	// a nickname cannot be the nickname or the username of another user
+	event = sendText(t, bobConn, "/nick ally")
+	require.Equal(t, ErrorEvent, event.Type)
+	require.Equal(t, errNicknameTaken.Error(), event.Error)
+	event = sendText(t, bobConn, "/nick "+alice)
+	require.Equal(t, ErrorEvent, event.Type)
+	require.Equal(t, errNicknameTaken.Error(), event.Error)
+
+	// nor the username of a registered user or bot who is not connected
+	for _, username := range []string{offline, bot} {
+		event = sendText(t, bobConn, "/nick "+username)
+		require.Equal(t, ErrorEvent, event.Type)
+		require.Equal(t, errNicknameTaken.Error(), event.Error)
+	}
+	event = sendText(t, bobConn, "/nick "+bob)
+	require.Equal(t, ReplyEvent, event.Type)
+	require.Equal(t, "you are now known as "+bob, event.Message.Text)
+	event = sendText(t, bobConn, "/nick")
+	require.Equal(t, "your nickname is cleared", event.Message.Text)
+
+	// the replies of the commands of alice were sent to alice only, the next event of bob is the action
+	event = sendText(t, aliceConn, "/me waves")
+	require.Equal(t, MessageEvent, event.Type)
+	require.Equal(t, "*"+alice+" waves*", event.Message.Text)
+	require.Equal(t, "<em>"+alice+" waves</em>", event.Message.HTML)
+	require.Equal(t, alice, event.Message.Author)
+	require.Equal(t, "Ally", event.Message.Nickname)
+	require.Equal(t, event, readEvent(t, bobConn))
+
+	testCases := []struct {
+		name  string
+		text  string
+		error string
+	}{
+		{name: "Usage", text: "/me", error: "usage: /me <action>"},
+		{name: "Unknown", text: "/dance", error: "unknown command /dance, see /help"},
+		{name: "NotAllowed", text: "/kick " + bob, error: "you are not allowed to run /kick"},
+		{name: "HelpNotAllowed", text: "/help kick", error: "unknown command /kick"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			event := sendText(t, aliceConn, testCase.text)
+			require.Equal(t, ErrorEvent, event.Type)
+			require.Equal(t, testCase.error, event.Error)
+		})
+	}
+
+	// the commands the user cannot run are not listed
+	event = sendText(t, aliceConn, "/help")
+	require.Equal(t, ReplyEvent, event.Type)
+	require.Contains(t, event.Message.Text, "/me <action>: send an action")
+	require.Contains(t, event.Message.Text, "/who: list the connected users")
+	require.NotContains(t, event.Message.Text, "/kick")
+
+	// "//" escapes a message starting with a slash
+	event = sendText(t, bobConn, "//shrug")
+	require.Equal(t, MessageEvent, event.Type)
+	require.Equal(t, "/shrug", event.Message.Text)
+	require.Empty(t, event.Message.Nickname)
+	require.Equal(t, event, readEvent(t, aliceConn))
+
+	// clearing the nickname
+	event = sendText(t, aliceConn, "/nick")
+	require.Equal(t, "your nickname is cleared", event.Message.Text)
+	event = sendText(t, aliceConn, "hello")
+	require.Equal(t, MessageEvent, event.Type)
+	require.Empty(t, event.Message.Nickname)
+
+	// the administrators run the admin commands
+	for _, text := range []string{"*" + alice + " waves*", "/shrug", "hello"} {
+		require.Equal(t, text, readEvent(t, adminConn).Message.Text)
+	}
+	event = sendText(t, adminConn, "/help kick")
+	require.Equal(t, "/kick <username>: disconnect a user", event.Message.Text)
+	event = sendText(t, adminConn, "/kick "+bob)
+	require.Equal(t, ReplyEvent, event.Type)
+	require.Equal(t, "kicked "+bob, event.Message.Text)
+	event = sendText(t, adminConn, "/kick")
+	require.Equal(t, ErrorEvent, event.Type)
+	require.Equal(t, "usage: /kick <username>", event.Error)
+
+	// a bot named after an administrator does not run the admin commands
+	adminBotConn := dialTestHubServer(t, newTestBotServer(t, hub, admin))
+	event = sendText(t, adminBotConn, "/kick "+bob)
+	require.Equal(t, ErrorEvent, event.Type)
+	require.Equal(t, "you are not allowed to run /kick", event.Error)
+}
+
+// TestHub_BotCommands tests registering commands of bots, forwarding their invocations to the bots and
+// sending the replies of the bots to the invokers
+func TestHub_BotCommands(t *testing.T) {
+	username, bot := util.RandomUsername(), util.RandomUsername()+"_bot"
+	hub, emitter := runTestCommandHub(t, HubConfig{}, username, bot)
+
+	userConn := dialTestHubServer(t, newTestHubServer(t, hub, username))
+	require.Equal(t, webhook.EventUserJoined, receiveEmitted(t, emitter).Type)
+	botConn := dialTestHubServer(t, newTestBotServer(t, hub, bot))
+	require.Equal(t, webhook.EventUserJoined, receiveEmitted(t, emitter).Type)
+
+	// only bots register commands
+	event := sendText(t, userConn, `{"command":{"name":"deploy"}}`)
+	require.Equal(t, ErrorEvent, event.Type)
+	require.Equal(t, errNotBot.Error(), event.Error)
+
+	for _, frame := range []string{`{"command":{"name":"Deploy!"}}`, `{"command":{"name":"deploy","permission":"owner"}}`} {
+		event = sendText(t, botConn, frame)
+		require.Equal(t, ErrorEvent, event.Type)
+		require.Equal(t, errInvalidCommand.Error(), event.Error)
+	}
+	event = sendText(t, botConn, `{"command":{"name":"help"}}`)
+	require.Equal(t, ErrorEvent, event.Type)
+	require.Equal(t, errCommandExists.Error(), event.Error)
+
+	// the frames of a client are handled in order, so the command is registered once /help replies
+	require.NoError(t, botConn.WriteMessage(websocket.TextMessage,
+		[]byte(`{"command":{"name":"deploy","usage":"<service>","description":"deploy a service"}}`)))
+	event = sendText(t, botConn, "/help deploy")
+	require.Equal(t, "/deploy <service>: deploy a service", event.Message.Text)
+
+	require.NoError(t, userConn.WriteMessage(websocket.TextMessage, []byte("/deploy  api ")))
+	event = readEvent(t, botConn)
+	require.Equal(t, CommandEvent, event.Type)
+	require.Equal(t, &CommandInvocation{ID: "1", Name: "deploy", Args: "api", Invoker: username}, event.Command)
+
+	require.NoError(t, botConn.WriteMessage(websocket.TextMessage, []byte(`{"reply_to":"1","text":"deploying **api**"}`)))
+	event = readEvent(t, userConn)
+	require.Equal(t, ReplyEvent, event.Type)
+	require.Equal(t, bot, event.Message.Author)
+	require.Equal(t, "deploying **api**", event.Message.Text)
+	require.Equal(t, "deploying <strong>api</strong>", event.Message.HTML)
+
+	// an invocation is replied to once
+	event = sendText(t, botConn, `{"reply_to":"1","text":"again"}`)
+	require.Equal(t, ErrorEvent, event.Type)
+	require.Equal(t, errInvocationNotFound.Error(), event.Error)
+
+	// the usage errors of the bot are sent to the invoker as error events
+	require.NoError(t, userConn.WriteMessage(websocket.TextMessage, []byte("/deploy")))
+	event = readEvent(t, botConn)
+	require.Equal(t, "2", event.Command.ID)
+	require.NoError(t, botConn.WriteMessage(websocket.TextMessage, []byte(`{"reply_to":"2","error":"usage: /deploy <service>"}`)))
+	event = readEvent(t, userConn)
+	require.Equal(t, ErrorEvent, event.Type)
+	require.Equal(t, "usage: /deploy <service>", event.Error)
+
+	// the invokers waiting for a bot are told it left, and its commands are removed
+	require.NoError(t, userConn.WriteMessage(websocket.TextMessage, []byte("/deploy web")))
+	event = readEvent(t, botConn)
+	require.Equal(t, "3", event.Command.ID)
+	require.NoError(t, botConn.Close())
+	event = readEvent(t, userConn)
+	require.Equal(t, ErrorEvent, event.Type)
+	require.Equal(t, errBotLeft.Error(), event.Error)
+
+	event = sendText(t, userConn, "/deploy web")
+	require.Equal(t, ErrorEvent, event.Type)
+	require.Equal(t, "unknown command /deploy, see /help", event.Error)
+}
+
+// TestHub_MessageRateLimit tests that the messages and commands of a user over its rate limit are reported to
+// the client instead of handled
+func TestHub_MessageRateLimit(t *testing.T) {
+	username := util.RandomUsername()
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), "messages", ratelimit.Limit{PerMinute: 1, Burst: 2})
+	hub, emitter := runTestCommandHub(t, HubConfig{MessageLimiter: limiter}, username)
+
+	conn := dialTestHubServer(t, newTestHubServer(t, hub, username))
+	require.Equal(t, webhook.EventUserJoined, receiveEmitted(t, emitter).Type)
+
+	event := sendText(t, conn, "hello")
+	require.Equal(t, MessageEvent, event.Type)
+	event = sendText(t, conn, "/who")
+	require.Equal(t, ReplyEvent, event.Type)
+
+	for _, text := range []string{"again", "/who"} {
+		event = sendText(t, conn, text)
+		require.Equal(t, ErrorEvent, event.Type)
+		require.Equal(t, "too many messages, retry in 60s", event.Error)
+	}
+}
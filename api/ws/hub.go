@@ -2,17 +2,72 @@ package ws
 
 import (
 	"Chat-Server/repository"
+	"Chat-Server/unfurl"
+	"Chat-Server/webhook"
+	"context"
+	"errors"
 	"log"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrHubClosed is returned when a client tries to interact with a hub which is not running anymore
+var ErrHubClosed = errors.New("chat hub is closed")
+
+// errors reported to the clients in error events
+var (
+	errMessageNotSaved = errors.New("message could not be saved, please try again")
+	errHubBusy         = errors.New("server is busy, please try again")
+	errTooManyMessages = errors.New("too many messages")
+
+	errAttachmentsDisabled = errors.New("attachments are not supported")
+	errTooManyAttachments  = errors.New("too many attachments")
+	errAttachmentNotFound  = errors.New("attachment not found")
+	errAttachmentLookup    = errors.New("attachments could not be retrieved, please try again")
 )
 
+// shutdownReason is the reason sent to the clients in the close frame when the hub shuts down
+const shutdownReason = "server restarting"
+
+// maxPendingAttachments is the maximum number of updates kept for attachments whose messages are not
+// in the hub yet, the pending updates are dropped when there are more
+const maxPendingAttachments = 1024
+
 // Hub maintains the set of active clients and broadcasts messages to the
 // clients.
 type Hub struct {
+	// configurations of the hub
+	config HubConfig
+
 	// Registered clients.
 	clients map[*Client]bool
 
 	// Inbound messages from the clients.
-	broadcast chan Message
+	broadcast chan inboundMessage
+
+	// slash commands, registrations of bot commands and replies of bots sent by the clients
+	commands chan commandRequest
+
+	// registered commands by name, the built-in ones, the ones of the configurations and the ones of the
+	// connected bots
+	registry map[string]*Command
+
+	// invocations of bot commands waiting for the replies of the bots, by id
+	invocations map[string]invocation
+
+	// last assigned invocation id
+	lastInvocationID uint64
+
+	// last assigned message key
+	lastMessageKey uint64
+
+	// nicknames of the users by username, set with /nick until the hub stops
+	nicknames map[string]string
+
+	// nicknames asked for with /nick, once the registered users are looked up
+	nicknameChecks chan nicknameCheck
 
 	// register requests from the clients.
 	register chan *Client
@@ -22,38 +77,99 @@ type Hub struct {
 
 	// Hub messages
 	messages []*Message
+
+	// messages saved in sync mode, ready to be broadcast
+	persisted chan persistedMessage
+
+	// updated attachments, e.g. attachments whose thumbnails were generated
+	attachmentUpdates chan *repository.Attachment
+
+	// hub messages by the ids of their attachments
+	attachmentMessages map[string]*Message
+
+	// updates of attachments whose messages are not in the hub yet, applied once the messages arrive
+	pendingAttachments map[string]*repository.Attachment
+
+	// ids of the messages saved in async mode whose links are previewed
+	saved chan savedMessage
+
+	// fetched previews of the links of the hub messages
+	previews chan messagePreviews
+
+	// done is closed when the hub stops running
+	done chan struct{}
 }
 
 // NewHub creates and returns a new hub
-func NewHub() *Hub {
+func NewHub(config HubConfig) *Hub {
+	if config.Durability == "" {
+		config.Durability = DurabilityAsync
+	}
+
+	registry := make(map[string]*Command)
+	for _, command := range builtinCommands() {
+		registry[command.Name] = command
+	}
+	for _, command := range config.Commands {
+		registry[command.Name] = &command
+	}
+
 	return &Hub{
-		broadcast:  make(chan Message),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		clients:    make(map[*Client]bool),
+		config:             config,
+		broadcast:          make(chan inboundMessage),
+		commands:           make(chan commandRequest),
+		registry:           registry,
+		invocations:        make(map[string]invocation),
+		nicknames:          make(map[string]string),
+		nicknameChecks:     make(chan nicknameCheck),
+		register:           make(chan *Client),
+		unregister:         make(chan *Client),
+		clients:            make(map[*Client]bool),
+		persisted:          make(chan persistedMessage),
+		attachmentUpdates:  make(chan *repository.Attachment),
+		attachmentMessages: make(map[string]*Message),
+		pendingAttachments: make(map[string]*repository.Attachment),
+		saved:              make(chan savedMessage),
+		previews:           make(chan messagePreviews),
+		done:               make(chan struct{}),
 	}
 }
 
-// RunChatHub runs chat hub
-func (h *Hub) RunChatHub(r repository.Repository) {
+// RunChatHub runs chat hub until the input context is canceled. on cancellation
+// a close frame is sent to every client and the hub stops running. new messages are
+// saved into the repository through the input message writer
+func (h *Hub) RunChatHub(ctx context.Context, r repository.Repository, w *repository.MessageWriter) {
+	defer close(h.done)
+
 	// get all previous messages in the hub from the repository
-	messages, _ := r.GetAllMessages()
+	messages, _ := r.GetAllMessages(ctx)
 
 	// initialize hub's messages with the size of retrieved messages from repository
-	h.messages = make([]*Message, len(messages))
+	h.messages = make([]*Message, 0, len(messages))
 
 	// insert messages into hub messages slice
 	for i := 0; i < len(messages); i++ {
-		h.messages[i] = &Message{Author: messages[i].Author, Text: messages[i].Text}
+		h.addMessage(h.toHubMessage(messages[i]))
 	}
 
 	for {
 		select {
+		case <-ctx.Done():
+			// tell the clients the server is going away and stop the hub
+			h.closeClients()
+			return
+
 		case client := <-h.register:
 			// initialize clients chat page with all previous messages
-			err := client.WriteMessages(h.messages)
-			if err != nil {
-				continue
+			if !client.skipHistory {
+				if err := client.WriteMessages(h.messages); err != nil {
+					continue
+				}
+			}
+
+			// a user joins the chat with their first connection
+			if !h.connected(client.username) {
+				h.emit(webhook.EventUserJoined, webhook.UserData{Username: client.username})
 			}
 
 			// register the client to the hub
@@ -65,34 +181,423 @@ func (h *Hub) RunChatHub(r repository.Repository) {
 				close(client.send)
 			}
 
-		case message := <-h.broadcast:
-			// save the message into the repository in a separate go routine
-			go saveMessageToRepository(r, message)
-			h.messages = append(h.messages, &message)
+			// the commands of a bot leave with it, also when it was removed for not keeping up
+			h.removeBotCommands(client)
+
+		case inbound := <-h.broadcast:
+			h.receive(w, inbound)
+
+		case request := <-h.commands:
+			h.runCommand(w, request)
+
+		case check := <-h.nicknameChecks:
+			h.setNickname(check)
+
+		case persisted := <-h.persisted:
+			if persisted.err != nil {
+				h.sendError(persisted.inbound.sender, errMessageNotSaved)
+				continue
+			}
 
-			// broadcast the new message to all the clients
+			message := persisted.inbound.message
+			h.addMessage(&message)
 			broadCastMessage(message, h.clients)
+			h.previewLinks(&message)
+			h.notify(persisted.notifications)
+
+		case saved := <-h.saved:
+			saved.message.ID = saved.id
+			h.previewLinks(saved.message)
+			h.notify(saved.notifications)
+
+		case previews := <-h.previews:
+			h.updatePreviews(previews.message, previews.previews)
+
+		case attachment := <-h.attachmentUpdates:
+			h.updateAttachment(attachment)
 		}
 	}
 }
 
-// saveMessageToRepository saves the input message into the repository
-func saveMessageToRepository(r repository.Repository, message Message) {
-	_, err := r.AddMessage(&repository.Message{
-		Author: message.Author,
-		Text:   message.Text,
+// receive saves and broadcasts the input inbound message, or sends its error to its sender
+func (h *Hub) receive(w *repository.MessageWriter, inbound inboundMessage) {
+	if inbound.err != nil {
+		h.sendError(inbound.sender, inbound.err)
+		return
+	}
+
+	// the nickname is only known to the hub, it is not saved with the message
+	inbound.message.Nickname = h.nicknames[inbound.message.Author]
+
+	if h.config.Durability == DurabilitySync {
+		// the message is broadcast after it is saved
+		h.persistMessage(w, inbound)
+		return
+	}
+
+	// queue the message to be saved into the repository
+	message := inbound.message
+	hasLinks := len(message.links) > 0
+	err := w.Write(toRepositoryMessage(message), func(saved *repository.Message, err error) {
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		h.emitMessageCreated(saved)
+
+		// the links are previewed once the message has an id the preview event refers to, and
+		// the mentioned users are notified once their notifications are saved
+		if hasLinks || len(saved.Notifications) > 0 {
+			select {
+			case h.saved <- savedMessage{message: &message, id: saved.ID, notifications: saved.Notifications}:
+			case <-h.done:
+			}
+		}
 	})
 	if err != nil {
+		// the message would be gone after a restart, so it is neither kept nor broadcast
 		log.Println(err)
+		h.sendError(inbound.sender, errMessageNotSaved)
+		return
+	}
+	h.addMessage(&message)
+
+	// broadcast the new message to all the clients
+	broadCastMessage(message, h.clients)
+}
+
+// Wait blocks until the hub stops running or until the input context is done
+func (h *Hub) Wait(ctx context.Context) error {
+	select {
+	case <-h.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// UpdateAttachment updates an attachment of the hub messages, e.g. once its thumbnail is generated,
+// and sends the updated attachment to the clients in an attachment event
+func (h *Hub) UpdateAttachment(attachment *repository.Attachment) {
+	select {
+	case h.attachmentUpdates <- attachment:
+	case <-h.done:
+	}
+}
+
+// Post sends a message of the input author and text to the hub, as if the author sent it from a client.
+// the message is saved and broadcast like the messages of the clients, returns once the hub accepts the
+// message or ErrHubClosed if the hub is not running anymore
+func (h *Hub) Post(ctx context.Context, author, text string) error {
+	select {
+	case h.broadcast <- inboundMessage{message: h.newMessage(author, text)}:
+		return nil
+	case <-h.done:
+		return ErrHubClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// newMessage returns a new Message of the input author and text, rendered and with the links to preview
+func (h *Hub) newMessage(author, text string) Message {
+	message := Message{Author: author, Text: text}
+
+	// render the text so clients never insert the raw text into their pages
+	message.HTML, message.Entities = render(text)
+
+	// the previews of the links are fetched once the message is saved
+	if h.config.LinkPreviews != nil {
+		message.links = unfurl.ExtractURLs(text, maxLinkPreviews)
+	}
+
+	return message
+}
+
+// addMessage adds the input message to the hub messages, applying the pending updates of its attachments.
+// the message is given a key before it is broadcast, so the events of the message sent in async mode before
+// it has an id refer to it
+func (h *Hub) addMessage(message *Message) {
+	h.lastMessageKey++
+	message.Key = strconv.FormatUint(h.lastMessageKey, 10)
+
+	for _, attachment := range message.Attachments {
+		if updated, ok := h.pendingAttachments[attachment.ID]; ok {
+			delete(h.pendingAttachments, attachment.ID)
+			replaceAttachment(message, h.toHubAttachment(updated))
+		}
+		h.attachmentMessages[attachment.ID] = message
+	}
+
+	h.messages = append(h.messages, message)
+}
+
+// updateAttachment replaces the input attachment in its hub message and broadcasts it. updates of
+// attachments whose messages are not in the hub yet are kept until the messages arrive, since the
+// messages may still be on their way from the clients or the repository
+func (h *Hub) updateAttachment(attachment *repository.Attachment) {
+	message, ok := h.attachmentMessages[attachment.ID]
+	if !ok {
+		if len(h.pendingAttachments) >= maxPendingAttachments {
+			clear(h.pendingAttachments)
+		}
+		h.pendingAttachments[attachment.ID] = attachment
+		return
+	}
+
+	updated := h.toHubAttachment(attachment)
+	replaceAttachment(message, updated)
+	broadcastEvent(Event{Type: AttachmentEvent, Attachment: &updated}, h.clients)
+}
+
+// replaceAttachment replaces the attachment of the input message with the id of the input attachment.
+// the attachments are copied since broadcast copies of the message may still be written to the clients
+func replaceAttachment(message *Message, attachment Attachment) {
+	attachments := make([]Attachment, len(message.Attachments))
+	for i := range message.Attachments {
+		attachments[i] = message.Attachments[i]
+		if attachments[i].ID == attachment.ID {
+			attachments[i] = attachment
+		}
 	}
+	message.Attachments = attachments
+}
+
+// previewLinks queues the links of the input message to have their previews fetched, the message
+// must have been saved so the preview event carries its id along with its key
+func (h *Hub) previewLinks(message *Message) {
+	if len(message.links) == 0 || h.config.LinkPreviews == nil {
+		return
+	}
+
+	err := h.config.LinkPreviews.Enqueue(message.links, func(previews []unfurl.Preview) {
+		// the hub does not receive previews after it stops
+		select {
+		case h.previews <- messagePreviews{message: message, previews: previews}:
+		case <-h.done:
+		}
+	})
+	if err != nil {
+		log.Printf("error: links of message %d are not previewed: %v", message.ID, err)
+	}
+	message.links = nil
+}
+
+// updatePreviews sets the previews of the input hub message and broadcasts the message in a preview event
+func (h *Hub) updatePreviews(message *Message, previews []unfurl.Preview) {
+	// a new slice is set since broadcast copies of the message may still be written to the clients
+	hubPreviews := make([]Preview, len(previews))
+	for i, preview := range previews {
+		hubPreviews[i] = Preview{
+			URL:         preview.URL,
+			Title:       preview.Title,
+			Description: preview.Description,
+			Image:       preview.Image,
+			SiteName:    preview.SiteName,
+		}
+	}
+	message.Previews = hubPreviews
+
+	updated := *message
+	broadcastEvent(Event{Type: PreviewEvent, Message: &updated}, h.clients)
+}
+
+// notify sends the input notifications to all the clients of their users, users who are not connected
+// find them in their saved notifications
+func (h *Hub) notify(notifications []*repository.Notification) {
+	for _, notification := range notifications {
+		event := Event{Type: NotificationEvent, Notification: &Notification{
+			ID:        notification.ID,
+			MessageID: notification.MessageID,
+			Author:    notification.Author,
+			Room:      notification.Room,
+			Text:      notification.Text,
+			Read:      notification.Read,
+			CreatedAt: notification.CreatedAt,
+		}}
+
+		for client := range h.clients {
+			if client.username != notification.Username {
+				continue
+			}
+
+			select {
+			case client.send <- event:
+			default:
+				close(client.send)
+				delete(h.clients, client)
+			}
+		}
+	}
+}
+
+// connected reports whether the input user has a client registered to the hub
+func (h *Hub) connected(username string) bool {
+	for client := range h.clients {
+		if client.username == username {
+			return true
+		}
+	}
+	return false
+}
+
+// emitMessageCreated emits the event of the input saved message, it is called from the writer goroutine
+func (h *Hub) emitMessageCreated(saved *repository.Message) {
+	h.emit(webhook.EventMessageCreated, webhook.MessageData{
+		ID:        saved.ID,
+		Author:    saved.Author,
+		Room:      saved.RoomName(),
+		Text:      saved.Text,
+		HTML:      saved.Rendered,
+		CreatedAt: saved.CreatedAt,
+	})
+}
+
+// emit emits an event of the input type and data if the hub emits events, an event which cannot be
+// queued is dropped
+func (h *Hub) emit(eventType string, data any) {
+	if h.config.Events == nil {
+		return
+	}
+
+	if err := h.config.Events.Emit(eventType, data); err != nil {
+		log.Println("webhook event dropped:", eventType, err)
+	}
+}
+
+// closeClients sends a close frame to all clients and removes them from the hub
+func (h *Hub) closeClients() {
+	closeMessage := websocket.FormatCloseMessage(websocket.CloseServiceRestart, shutdownReason)
+
+	for client := range h.clients {
+		// WriteControl is safe to be called concurrently with the client's writer
+		err := client.conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(writeWait))
+		if err != nil {
+			log.Printf("error: %v", err)
+		}
+
+		close(client.send)
+		delete(h.clients, client)
+	}
+}
+
+// sendError sends an error event to the input client if it is still registered
+func (h *Hub) sendError(client *Client, err error) {
+	h.sendEvent(client, Event{Type: ErrorEvent, Error: err.Error()})
+}
+
+// sendEvent sends the input event to the input client if it is still registered, a client which cannot keep
+// up is removed
+func (h *Hub) sendEvent(client *Client, event Event) {
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+
+	select {
+	case client.send <- event:
+	default:
+		close(client.send)
+		delete(h.clients, client)
+	}
+}
+
+// persistMessage queues the inbound message to be saved, once saved the message is handed back to
+// the hub to be broadcast. the writer saves messages in order, so they are broadcast in the same order
+func (h *Hub) persistMessage(w *repository.MessageWriter, inbound inboundMessage) {
+	err := w.Write(toRepositoryMessage(inbound.message), func(saved *repository.Message, err error) {
+		persisted := persistedMessage{inbound: inbound, err: err}
+		if err != nil {
+			log.Println(err)
+		} else {
+			persisted.inbound.message.ID = saved.ID
+			persisted.notifications = saved.Notifications
+			h.emitMessageCreated(saved)
+		}
+
+		// the hub does not receive results after it stops, saved messages are not broadcast anymore
+		select {
+		case h.persisted <- persisted:
+		case <-h.done:
+		}
+	})
+	if errors.Is(err, repository.ErrWriterQueueFull) {
+		h.sendError(inbound.sender, errHubBusy)
+	} else if err != nil {
+		h.sendError(inbound.sender, errMessageNotSaved)
+	}
+}
+
+// toRepositoryMessage converts a hub message to a repository message
+func toRepositoryMessage(message Message) *repository.Message {
+	repositoryMessage := &repository.Message{
+		Author:   message.Author,
+		Text:     message.Text,
+		Rendered: message.HTML,
+	}
+	for _, entity := range message.Entities {
+		repositoryMessage.Entities = append(repositoryMessage.Entities, repository.Entity(entity))
+	}
+	for _, attachment := range message.Attachments {
+		repositoryMessage.Attachments = append(repositoryMessage.Attachments, &repository.Attachment{ID: attachment.ID})
+	}
+
+	return repositoryMessage
+}
+
+// toHubMessage converts a repository message to a hub message
+func (h *Hub) toHubMessage(message *repository.Message) *Message {
+	hubMessage := &Message{ID: message.ID, Author: message.Author, Text: message.Text, HTML: message.Rendered}
+	for _, entity := range message.Entities {
+		hubMessage.Entities = append(hubMessage.Entities, Entity(entity))
+	}
+
+	// messages saved before the texts were rendered are rendered when they are loaded
+	if hubMessage.HTML == "" && hubMessage.Text != "" {
+		hubMessage.HTML, hubMessage.Entities = render(hubMessage.Text)
+	}
+	for _, attachment := range message.Attachments {
+		hubMessage.Attachments = append(hubMessage.Attachments, h.toHubAttachment(attachment))
+	}
+
+	return hubMessage
+}
+
+// toHubAttachment converts a repository attachment to a hub attachment
+func (h *Hub) toHubAttachment(attachment *repository.Attachment) Attachment {
+	hubAttachment := Attachment{
+		ID:          attachment.ID,
+		Filename:    attachment.Filename,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		Checksum:    attachment.Checksum,
+		Width:       attachment.Width,
+		Height:      attachment.Height,
+	}
+	if h.config.AttachmentURL != nil {
+		hubAttachment.URL = h.config.AttachmentURL(attachment.ID)
+	}
+	if attachment.Thumbnail != nil && h.config.ThumbnailURL != nil {
+		hubAttachment.Thumbnail = &Thumbnail{
+			URL:    h.config.ThumbnailURL(attachment.ID),
+			Width:  attachment.Thumbnail.Width,
+			Height: attachment.Thumbnail.Height,
+		}
+	}
+
+	return hubAttachment
 }
 
 // broadCastMessage broadcast the input message to all clients
 func broadCastMessage(message Message, clients map[*Client]bool) {
+	broadcastEvent(Event{Type: MessageEvent, Message: &message}, clients)
+}
+
+// broadcastEvent sends the input event to all clients, clients which cannot keep up are removed
+func broadcastEvent(event Event, clients map[*Client]bool) {
 	// broadcast
 	for client := range clients {
 		select {
-		case client.send <- message:
+		case client.send <- event:
 		default:
 			close(client.send)
 			delete(clients, client)
@@ -0,0 +1,768 @@
+package ws
+
+import (
+	"Chat-Server/repository"
+	"Chat-Server/repository/db/memory"
+	mockdb "Chat-Server/repository/mock"
+	"Chat-Server/unfurl"
+	"Chat-Server/util"
+	"Chat-Server/webhook"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// newTestHubServer returns a test http server which connects its websocket clients to the input hub
+func newTestHubServer(t *testing.T, hub *Hub, username string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		client := NewClient(hub, conn, make(chan Event, 10), username)
+		if err := client.Register(); err != nil {
+			return
+		}
+
+		go client.Write()
+		client.Read()
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// dialTestHubServer opens a websocket connection to the input test server
+func dialTestHubServer(t *testing.T, server *httptest.Server) *websocket.Conn {
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// newTestMessageWriter returns a message writer which writes every message immediately
+func newTestMessageWriter(t *testing.T, repo repository.Repository) *repository.MessageWriter {
+	writer := repository.NewMessageWriter(repo, repository.MessageWriterConfig{QueueSize: 10, BatchSize: 1})
+	t.Cleanup(func() { writer.Close(context.Background()) })
+
+	return writer
+}
+
+// TestHub_Shutdown tests that the hub sends close frames to its clients when its context is canceled
+// and pending messages are saved when the writer is closed afterwards
+func TestHub_Shutdown(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	username := util.RandomUsername()
+	text := util.RandomString(32, util.ALPHANUMERIC)
+
+	var saved atomic.Bool
+	repo := mockdb.NewMockRepository(ctrl)
+	repo.EXPECT().GetAllMessages(gomock.Any()).Times(1).Return(nil, nil)
+	repo.EXPECT().
+		AddMessages(gomock.Any(), []*repository.Message{{Author: username, Text: text, Rendered: text}}).
+		Times(1).
+		DoAndReturn(func(_ context.Context, messages []*repository.Message) ([]*repository.Message, error) {
+			time.Sleep(200 * time.Millisecond)
+			saved.Store(true)
+			return messages, nil
+		})
+
+	hub := NewHub(HubConfig{Durability: DurabilityAsync})
+	writer := newTestMessageWriter(t, repo)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.RunChatHub(ctx, repo, writer)
+
+	conn := dialTestHubServer(t, newTestHubServer(t, hub, username))
+
+	// send a message and wait for it to be broadcast so its save is pending
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(text)))
+
+	var event Event
+	require.NoError(t, conn.ReadJSON(&event))
+	require.Equal(t, MessageEvent, event.Type)
+	require.Equal(t, username, event.Message.Author)
+	require.Equal(t, text, event.Message.Text)
+
+	cancel()
+
+	_, _, err := conn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseServiceRestart))
+	require.Equal(t, shutdownReason, err.(*websocket.CloseError).Text)
+
+	waitContext, cancelWait := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelWait()
+	require.NoError(t, hub.Wait(waitContext))
+	require.NoError(t, writer.Close(waitContext))
+	require.True(t, saved.Load())
+
+	// clients cannot register to a stopped hub
+	client := NewClient(hub, nil, make(chan Event, 10), username)
+	require.ErrorIs(t, client.Register(), ErrHubClosed)
+}
+
+// TestHub_WaitTimeout tests that Wait returns when its context is done before the hub stops
+func TestHub_WaitTimeout(t *testing.T) {
+	hub := NewHub(HubConfig{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	require.ErrorIs(t, hub.Wait(ctx), context.DeadlineExceeded)
+}
+
+// TestHub_SyncDurability tests that in sync mode messages are saved before they are broadcast
+// and that failures are reported to the sender
+func TestHub_SyncDurability(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	username := util.RandomUsername()
+	texts := []string{util.RandomString(32, util.ALPHANUMERIC), util.RandomString(32, util.ALPHANUMERIC)}
+
+	repo := mockdb.NewMockRepository(ctrl)
+	repo.EXPECT().GetAllMessages(gomock.Any()).Times(1).Return(nil, nil)
+	gomock.InOrder(
+		repo.EXPECT().
+			AddMessages(gomock.Any(), gomock.Len(len(texts))).
+			Times(1).
+			DoAndReturn(func(_ context.Context, messages []*repository.Message) ([]*repository.Message, error) {
+				for i, message := range messages {
+					require.Equal(t, texts[i], message.Text)
+					message.ID = uint(i + 1)
+				}
+				return messages, nil
+			}),
+		repo.EXPECT().
+			AddMessages(gomock.Any(), gomock.Len(1)).
+			Times(1).
+			Return(nil, errors.New("connection refused")),
+	)
+
+	// the batch window is long enough for both messages to be saved in the same batch
+	hub := NewHub(HubConfig{Durability: DurabilitySync})
+	writer := repository.NewMessageWriter(repo, repository.MessageWriterConfig{
+		QueueSize:   10,
+		BatchSize:   len(texts),
+		BatchWindow: time.Second,
+	})
+	defer writer.Close(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.RunChatHub(ctx, repo, writer)
+
+	conn := dialTestHubServer(t, newTestHubServer(t, hub, username))
+
+	for _, text := range texts {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(text)))
+	}
+
+	for i, text := range texts {
+		var event Event
+		require.NoError(t, conn.ReadJSON(&event))
+		require.Equal(t, MessageEvent, event.Type)
+		require.Equal(t, uint(i+1), event.Message.ID)
+		require.Equal(t, text, event.Message.Text)
+	}
+
+	// a message which cannot be saved is not broadcast and the sender gets an error event
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(util.RandomString(32, util.ALPHANUMERIC))))
+
+	var event Event
+	require.NoError(t, conn.ReadJSON(&event))
+	require.Equal(t, ErrorEvent, event.Type)
+	require.Equal(t, errMessageNotSaved.Error(), event.Error)
+	require.Nil(t, event.Message)
+}
+
+// TestHub_WriterQueueFull tests that in async mode a message which cannot be queued to be saved is neither
+// broadcast nor kept in the history, and that the sender gets an error event
+func TestHub_WriterQueueFull(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	username := util.RandomUsername()
+	texts := []string{"saving", "queued", "dropped", "after"}
+
+	// the first message is saved until it is released, so the queue of a single message fills up
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	repo := mockdb.NewMockRepository(ctrl)
+	repo.EXPECT().GetAllMessages(gomock.Any()).Times(1).Return(nil, nil)
+	repo.EXPECT().
+		AddMessages(gomock.Any(), gomock.Len(1)).
+		AnyTimes().
+		DoAndReturn(func(_ context.Context, messages []*repository.Message) ([]*repository.Message, error) {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			<-release
+			return messages, nil
+		})
+
+	hub := NewHub(HubConfig{Durability: DurabilityAsync})
+	writer := repository.NewMessageWriter(repo, repository.MessageWriterConfig{QueueSize: 1, BatchSize: 1})
+	defer writer.Close(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.RunChatHub(ctx, repo, writer)
+
+	server := newTestHubServer(t, hub, username)
+	conn := dialTestHubServer(t, server)
+	observer := dialTestHubServer(t, server)
+
+	readMessage := func(conn *websocket.Conn) string {
+		var event Event
+		require.NoError(t, conn.ReadJSON(&event))
+		require.Equal(t, MessageEvent, event.Type)
+		return event.Message.Text
+	}
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(texts[0])))
+	require.Equal(t, texts[0], readMessage(conn))
+	<-started
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(texts[1])))
+	require.Equal(t, texts[1], readMessage(conn))
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(texts[2])))
+	var event Event
+	require.NoError(t, conn.ReadJSON(&event))
+	require.Equal(t, ErrorEvent, event.Type)
+	require.Equal(t, errMessageNotSaved.Error(), event.Error)
+
+	close(release)
+	require.Eventually(t, func() bool { return writer.QueueDepth() == 0 }, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(texts[3])))
+
+	// the dropped message is skipped by the broadcasts and the history of the clients joining later
+	require.Equal(t, texts[3], readMessage(conn))
+	for _, text := range []string{texts[0], texts[1], texts[3]} {
+		require.Equal(t, text, readMessage(observer))
+	}
+	late := dialTestHubServer(t, server)
+	for _, text := range []string{texts[0], texts[1], texts[3]} {
+		require.Equal(t, text, readMessage(late))
+	}
+}
+
+// TestHub_Attachments tests that clients attach their own unattached attachments to their messages
+// and that the attachments are broadcast with their download urls
+func TestHub_Attachments(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	username := util.RandomUsername()
+	attachment := &repository.Attachment{
+		ID:          "attachment",
+		Owner:       username,
+		Filename:    "photo.png",
+		ContentType: "image/png",
+		Size:        1024,
+		Checksum:    util.RandomString(64, util.ALPHANUMERIC),
+	}
+	othersAttachment := &repository.Attachment{ID: "others", Owner: "other"}
+
+	repo := mockdb.NewMockRepository(ctrl)
+	repo.EXPECT().GetAllMessages(gomock.Any()).Times(1).Return(nil, nil)
+	// the attachments are looked up on behalf of their uploader, which reads its own writes, with a deadline
+	userLookup := gomock.Cond(func(x any) bool {
+		ctx := x.(context.Context)
+		user, ok := repository.UserFromContext(ctx)
+		_, hasDeadline := ctx.Deadline()
+		return ok && user == username && hasDeadline
+	})
+	repo.EXPECT().GetAttachment(userLookup, attachment.ID).Times(1).Return(attachment, nil)
+	repo.EXPECT().GetAttachment(gomock.Any(), othersAttachment.ID).Times(1).Return(othersAttachment, nil)
+	repo.EXPECT().GetAttachment(gomock.Any(), "missing").Times(1).Return(nil, repository.ErrNotFound)
+	repo.EXPECT().GetAttachment(gomock.Any(), "unavailable").Times(1).Return(nil, repository.ErrUnavailable)
+	repo.EXPECT().
+		AddMessages(gomock.Any(), []*repository.Message{{
+			Author:      username,
+			Text:        "look",
+			Rendered:    "look",
+			Attachments: []*repository.Attachment{{ID: attachment.ID}},
+		}}).
+		Times(1).
+		DoAndReturn(func(_ context.Context, messages []*repository.Message) ([]*repository.Message, error) {
+			messages[0].ID = 1
+			return messages, nil
+		})
+
+	hub := NewHub(HubConfig{
+		Durability:    DurabilitySync,
+		Attachments:   repo,
+		AttachmentURL: func(id string) string { return "/api/attachments/" + id },
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.RunChatHub(ctx, repo, newTestMessageWriter(t, repo))
+
+	conn := dialTestHubServer(t, newTestHubServer(t, hub, username))
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"text":"look","attachments":["attachment"]}`)))
+
+	var event Event
+	require.NoError(t, conn.ReadJSON(&event))
+	require.Equal(t, MessageEvent, event.Type)
+	require.Equal(t, "look", event.Message.Text)
+	require.Equal(t, []Attachment{{
+		ID:          attachment.ID,
+		Filename:    attachment.Filename,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		Checksum:    attachment.Checksum,
+		URL:         "/api/attachments/attachment",
+	}}, event.Message.Attachments)
+
+	// attachments of other users and missing attachments are reported to the sender
+	for _, id := range []string{othersAttachment.ID, "missing"} {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"text":"look","attachments":["`+id+`"]}`)))
+
+		var event Event
+		require.NoError(t, conn.ReadJSON(&event))
+		require.Equal(t, ErrorEvent, event.Type)
+		require.Equal(t, errAttachmentNotFound.Error(), event.Error)
+	}
+
+	// a failed lookup is reported apart from a missing attachment
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"text":"look","attachments":["unavailable"]}`)))
+	event = Event{}
+	require.NoError(t, conn.ReadJSON(&event))
+	require.Equal(t, ErrorEvent, event.Type)
+	require.Equal(t, errAttachmentLookup.Error(), event.Error)
+}
+
+// TestHub_Markup tests that the texts of the messages are rendered into sanitized html which is saved and
+// broadcast with their entities, and that messages saved before the texts were rendered are rendered when loaded
+func TestHub_Markup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	username := util.RandomUsername()
+	text := "**hi** @someone <img src=x onerror=alert(1)>"
+	html := `<strong>hi</strong> <span class="mention" data-username="someone">@someone</span> &lt;img src=x onerror=alert(1)&gt;`
+
+	repo := mockdb.NewMockRepository(ctrl)
+	repo.EXPECT().GetAllMessages(gomock.Any()).Times(1).Return([]*repository.Message{
+		{ID: 1, Author: username, Text: "saved *before*"},
+	}, nil)
+	repo.EXPECT().
+		AddMessages(gomock.Any(), []*repository.Message{{
+			Author:   username,
+			Text:     text,
+			Rendered: html,
+			Entities: []repository.Entity{{Type: "mention", Offset: 7, Length: 8, Value: "someone"}},
+		}}).
+		Times(1).
+		DoAndReturn(func(_ context.Context, messages []*repository.Message) ([]*repository.Message, error) {
+			messages[0].ID = 2
+			return messages, nil
+		})
+
+	hub := NewHub(HubConfig{Durability: DurabilitySync})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.RunChatHub(ctx, repo, newTestMessageWriter(t, repo))
+
+	conn := dialTestHubServer(t, newTestHubServer(t, hub, username))
+
+	var event Event
+	require.NoError(t, conn.ReadJSON(&event))
+	require.Equal(t, "saved <em>before</em>", event.Message.HTML)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(text)))
+
+	event = Event{}
+	require.NoError(t, conn.ReadJSON(&event))
+	require.Equal(t, MessageEvent, event.Type)
+	require.Equal(t, text, event.Message.Text)
+	require.Equal(t, html, event.Message.HTML)
+	require.Equal(t, []Entity{{Type: "mention", Offset: 7, Length: 8, Value: "someone"}}, event.Message.Entities)
+}
+
+// TestHub_UpdateAttachment tests that updated attachments are broadcast in attachment events and
+// replace the attachments of the hub messages, including messages which arrive after the update
+func TestHub_UpdateAttachment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	username := util.RandomUsername()
+	sent := &repository.Attachment{ID: "sent", Owner: username, ContentType: "image/png", Width: 640, Height: 480, MessageID: 1}
+	unsent := &repository.Attachment{ID: "unsent", Owner: username, ContentType: "image/jpeg", Width: 300, Height: 600}
+	thumbnail := &repository.Thumbnail{ContentType: "image/png", Width: 320, Height: 240, Size: 100}
+
+	repo := mockdb.NewMockRepository(ctrl)
+	repo.EXPECT().GetAllMessages(gomock.Any()).Times(1).Return([]*repository.Message{{
+		ID:          1,
+		Author:      username,
+		Text:        "sent",
+		Attachments: []*repository.Attachment{sent},
+	}}, nil)
+	repo.EXPECT().GetAttachment(gomock.Any(), unsent.ID).Times(1).Return(unsent, nil)
+	repo.EXPECT().AddMessages(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+		func(_ context.Context, messages []*repository.Message) ([]*repository.Message, error) {
+			return messages, nil
+		})
+
+	hub := NewHub(HubConfig{
+		Durability:    DurabilityAsync,
+		Attachments:   repo,
+		AttachmentURL: func(id string) string { return "/api/attachments/" + id },
+		ThumbnailURL:  func(id string) string { return "/api/attachments/" + id + "/thumbnail" },
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.RunChatHub(ctx, repo, newTestMessageWriter(t, repo))
+
+	server := newTestHubServer(t, hub, username)
+	conn := dialTestHubServer(t, server)
+
+	var event Event
+	require.NoError(t, conn.ReadJSON(&event))
+	require.Equal(t, MessageEvent, event.Type)
+	require.Nil(t, event.Message.Attachments[0].Thumbnail)
+	require.Equal(t, 640, event.Message.Attachments[0].Width)
+	require.Equal(t, 480, event.Message.Attachments[0].Height)
+
+	// the thumbnail of an attachment of a sent message is broadcast
+	updated := *sent
+	updated.Thumbnail = thumbnail
+	hub.UpdateAttachment(&updated)
+
+	event = Event{}
+	require.NoError(t, conn.ReadJSON(&event))
+	require.Equal(t, AttachmentEvent, event.Type)
+	require.Equal(t, "sent", event.Attachment.ID)
+	require.Equal(t, &Thumbnail{URL: "/api/attachments/sent/thumbnail", Width: 320, Height: 240}, event.Attachment.Thumbnail)
+
+	// the thumbnail of an attachment of a message which is not in the hub yet is sent with the message
+	updated = *unsent
+	updated.Thumbnail = thumbnail
+	hub.UpdateAttachment(&updated)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"text":"unsent","attachments":["unsent"]}`)))
+
+	event = Event{}
+	require.NoError(t, conn.ReadJSON(&event))
+	require.Equal(t, MessageEvent, event.Type)
+	require.Equal(t, "unsent", event.Message.Text)
+	require.Equal(t, &Thumbnail{URL: "/api/attachments/unsent/thumbnail", Width: 320, Height: 240}, event.Message.Attachments[0].Thumbnail)
+
+	// new clients receive the messages with the thumbnails
+	conn = dialTestHubServer(t, server)
+	for _, text := range []string{"sent", "unsent"} {
+		event = Event{}
+		require.NoError(t, conn.ReadJSON(&event))
+		require.Equal(t, text, event.Message.Text)
+		require.NotNil(t, event.Message.Attachments[0].Thumbnail)
+	}
+}
+
+// TestHub_LinkPreviews tests that the previews of the links in the messages are fetched from a local
+// stand-in web server once the messages are saved and broadcast in preview events
+func TestHub_LinkPreviews(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><meta property="og:title" content="Linked page"><meta property="og:site_name" content="Stand-in"></head></html>`))
+	}))
+	defer page.Close()
+
+	for _, durability := range []Durability{DurabilityAsync, DurabilitySync} {
+		t.Run(string(durability), func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockdb.NewMockRepository(ctrl)
+			repo.EXPECT().GetAllMessages(gomock.Any()).Times(1).Return(nil, nil)
+			repo.EXPECT().AddMessages(gomock.Any(), gomock.Any()).Times(2).DoAndReturn(
+				func(_ context.Context, messages []*repository.Message) ([]*repository.Message, error) {
+					saved := *messages[0]
+					saved.ID = uint(len(saved.Text))
+					return []*repository.Message{&saved}, nil
+				})
+
+			unfurler := unfurl.New(unfurl.Config{AllowPrivateNetworks: true})
+			defer unfurler.Close(context.Background())
+
+			hub := NewHub(HubConfig{Durability: durability, LinkPreviews: unfurler})
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go hub.RunChatHub(ctx, repo, newTestMessageWriter(t, repo))
+
+			conn := dialTestHubServer(t, newTestHubServer(t, hub, util.RandomUsername()))
+
+			// messages without links have no preview event
+			require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("no links")))
+			text := "read " + page.URL + "/post, it is good"
+			require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(text)))
+
+			var event Event
+			keys := make([]string, 0, 2)
+			for _, expected := range []string{"no links", text} {
+				event = Event{}
+				require.NoError(t, conn.ReadJSON(&event))
+				require.Equal(t, MessageEvent, event.Type)
+				require.Equal(t, expected, event.Message.Text)
+				require.Empty(t, event.Message.Previews)
+				require.NotEmpty(t, event.Message.Key)
+				keys = append(keys, event.Message.Key)
+			}
+			require.NotEqual(t, keys[0], keys[1])
+
+			// the preview event refers to the broadcast message by its key, which it has before it has an id
+			event = Event{}
+			require.NoError(t, conn.ReadJSON(&event))
+			require.Equal(t, PreviewEvent, event.Type)
+			require.Equal(t, keys[1], event.Message.Key)
+			require.Equal(t, uint(len(text)), event.Message.ID)
+			require.Equal(t, text, event.Message.Text)
+			require.Equal(t, []Preview{{URL: page.URL + "/post", Title: "Linked page", SiteName: "Stand-in"}}, event.Message.Previews)
+		})
+	}
+}
+
+// TestHub_Notifications tests that the users mentioned in a message are notified on all their connections
+// once the message is saved, and that the author is not notified
+func TestHub_Notifications(t *testing.T) {
+	for _, durability := range []Durability{DurabilityAsync, DurabilitySync} {
+		t.Run(string(durability), func(t *testing.T) {
+			repo := memory.NewMemoryRepository()
+			author, mentioned := util.RandomUsername(), util.RandomUsername()+"_x"
+			for _, username := range []string{author, mentioned} {
+				_, err := repo.AddUser(context.Background(), &repository.User{Username: username, Password: "password"})
+				require.NoError(t, err)
+			}
+			// every client receives the message on registration, so messages are sent once all are registered
+			_, err := repo.AddMessage(context.Background(), &repository.Message{Author: author, Text: "welcome"})
+			require.NoError(t, err)
+
+			hub := NewHub(HubConfig{Durability: durability})
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go hub.RunChatHub(ctx, repo, newTestMessageWriter(t, repo))
+
+			authorConn := dialTestHubServer(t, newTestHubServer(t, hub, author))
+			mentionedServer := newTestHubServer(t, hub, mentioned)
+			mentionedConns := []*websocket.Conn{dialTestHubServer(t, mentionedServer), dialTestHubServer(t, mentionedServer)}
+
+			var event Event
+			for _, conn := range append(mentionedConns, authorConn) {
+				event = Event{}
+				require.NoError(t, conn.ReadJSON(&event))
+				require.Equal(t, "welcome", event.Message.Text)
+			}
+
+			text := "hey @" + mentioned + " and @" + author
+			require.NoError(t, authorConn.WriteMessage(websocket.TextMessage, []byte(text)))
+
+			for _, conn := range mentionedConns {
+				event = Event{}
+				require.NoError(t, conn.ReadJSON(&event))
+				require.Equal(t, MessageEvent, event.Type)
+				require.Equal(t, text, event.Message.Text)
+
+				event = Event{}
+				require.NoError(t, conn.ReadJSON(&event))
+				require.Equal(t, NotificationEvent, event.Type)
+				require.NotZero(t, event.Notification.ID)
+				require.Equal(t, uint(2), event.Notification.MessageID)
+				require.Equal(t, author, event.Notification.Author)
+				require.Equal(t, repository.DefaultRoom, event.Notification.Room)
+				require.Equal(t, text, event.Notification.Text)
+				require.False(t, event.Notification.Read)
+			}
+
+			// the author mentioning itself is not notified, its next event is its next message
+			event = Event{}
+			require.NoError(t, authorConn.ReadJSON(&event))
+			require.Equal(t, text, event.Message.Text)
+
+			require.NoError(t, authorConn.WriteMessage(websocket.TextMessage, []byte("bye")))
+			event = Event{}
+			require.NoError(t, authorConn.ReadJSON(&event))
+			require.Equal(t, MessageEvent, event.Type)
+			require.Equal(t, "bye", event.Message.Text)
+
+			// the notification is saved for the mentioned user
+			notifications, err := repo.GetNotifications(context.Background(), &repository.NotificationQuery{Username: mentioned, Limit: 10})
+			require.NoError(t, err)
+			require.Len(t, notifications, 1)
+		})
+	}
+}
+
+// testEmitter records the emitted events
+type testEmitter struct {
+	events chan webhook.Event
+}
+
+// Emit records the input event
+func (e *testEmitter) Emit(eventType string, data any) error {
+	e.events <- webhook.Event{Type: eventType, Data: data}
+	return nil
+}
+
+// receiveEmitted waits for the next emitted event
+func receiveEmitted(t *testing.T, emitter *testEmitter) webhook.Event {
+	select {
+	case event := <-emitter.events:
+		return event
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "event was not emitted")
+		return webhook.Event{}
+	}
+}
+
+// TestHub_Webhooks tests emitting the events of the hub to the webhooks
+func TestHub_Webhooks(t *testing.T) {
+	for _, durability := range []Durability{DurabilityAsync, DurabilitySync} {
+		t.Run(string(durability), func(t *testing.T) {
+			repo := memory.NewMemoryRepository()
+			username := util.RandomUsername()
+			_, err := repo.AddUser(context.Background(), &repository.User{Username: username, Password: "password"})
+			require.NoError(t, err)
+
+			emitter := &testEmitter{events: make(chan webhook.Event, 10)}
+			hub := NewHub(HubConfig{Durability: durability, Events: emitter})
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go hub.RunChatHub(ctx, repo, newTestMessageWriter(t, repo))
+
+			// the user joins with its first connection only
+			server := newTestHubServer(t, hub, username)
+			conn := dialTestHubServer(t, server)
+			event := receiveEmitted(t, emitter)
+			require.Equal(t, webhook.EventUserJoined, event.Type)
+			require.Equal(t, webhook.UserData{Username: username}, event.Data)
+
+			second := dialTestHubServer(t, server)
+			require.NoError(t, second.WriteMessage(websocket.TextMessage, []byte("**hello**")))
+
+			// the message is emitted once it is saved
+			event = receiveEmitted(t, emitter)
+			require.Equal(t, webhook.EventMessageCreated, event.Type)
+			data := event.Data.(webhook.MessageData)
+			require.Equal(t, uint(1), data.ID)
+			require.Equal(t, username, data.Author)
+			require.Equal(t, repository.DefaultRoom, data.Room)
+			require.Equal(t, "**hello**", data.Text)
+			require.Equal(t, "<strong>hello</strong>", data.HTML)
+			require.False(t, data.CreatedAt.IsZero())
+
+			var message Event
+			require.NoError(t, conn.ReadJSON(&message))
+			require.Equal(t, "**hello**", message.Message.Text)
+			require.Empty(t, emitter.events)
+		})
+	}
+}
+
+// TestHub_Post tests that posted messages are saved and broadcast like the messages of the clients
+func TestHub_Post(t *testing.T) {
+	for _, durability := range []Durability{DurabilityAsync, DurabilitySync} {
+		t.Run(string(durability), func(t *testing.T) {
+			repo := memory.NewMemoryRepository()
+			username, bot := util.RandomUsername(), util.RandomUsername()+"_bot"
+			for _, user := range []string{username, bot} {
+				_, err := repo.AddUser(context.Background(), &repository.User{Username: user})
+				require.NoError(t, err)
+			}
+
+			emitter := &testEmitter{events: make(chan webhook.Event, 10)}
+			hub := NewHub(HubConfig{Durability: durability, Events: emitter})
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go hub.RunChatHub(ctx, repo, newTestMessageWriter(t, repo))
+
+			// the client is registered once the user joins
+			conn := dialTestHubServer(t, newTestHubServer(t, hub, username))
+			require.Equal(t, webhook.EventUserJoined, receiveEmitted(t, emitter).Type)
+
+			require.NoError(t, hub.Post(context.Background(), bot, "build **failed**"))
+
+			var event Event
+			require.NoError(t, conn.ReadJSON(&event))
+			require.Equal(t, MessageEvent, event.Type)
+			require.Equal(t, bot, event.Message.Author)
+			require.Equal(t, "build **failed**", event.Message.Text)
+			require.Equal(t, "build <strong>failed</strong>", event.Message.HTML)
+
+			require.Equal(t, webhook.EventMessageCreated, receiveEmitted(t, emitter).Type)
+			messages, err := repo.GetAllMessages(context.Background())
+			require.NoError(t, err)
+			require.Len(t, messages, 1)
+			require.Equal(t, bot, messages[0].Author)
+
+			// nothing is posted once the hub stops
+			cancel()
+			require.NoError(t, hub.Wait(context.Background()))
+			require.ErrorIs(t, hub.Post(context.Background(), bot, "too late"), ErrHubClosed)
+		})
+	}
+}
+
+// TestParseFrame tests parseFrame
+func TestParseFrame(t *testing.T) {
+	testCases := []struct {
+		name  string
+		data  string
+		frame inboundFrame
+	}{
+		{
+			name:  "PlainText",
+			data:  "hello",
+			frame: inboundFrame{Text: "hello"},
+		},
+		{
+			name:  "JSON",
+			data:  `{"text":"hello","attachments":["a","b"]}`,
+			frame: inboundFrame{Text: "hello", Attachments: []string{"a", "b"}},
+		},
+		{
+			name:  "InvalidJSON",
+			data:  "{hello}",
+			frame: inboundFrame{Text: "{hello}"},
+		},
+		{
+			name:  "UnknownFields",
+			data:  `{"text":"hello","author":"someone"}`,
+			frame: inboundFrame{Text: `{"text":"hello","author":"someone"}`},
+		},
+		{
+			name:  "TrailingData",
+			data:  `{"text":"hello"} {"text":"again"}`,
+			frame: inboundFrame{Text: `{"text":"hello"} {"text":"again"}`},
+		},
+		{
+			name:  "Command",
+			data:  `{"command":{"name":"deploy","usage":"<service>","permission":"admin"}}`,
+			frame: inboundFrame{Command: &CommandSpec{Name: "deploy", Usage: "<service>", Permission: PermissionAdmin}},
+		},
+		{
+			name:  "Reply",
+			data:  `{"reply_to":"7","error":"usage: /deploy <service>"}`,
+			frame: inboundFrame{ReplyTo: "7", Error: "usage: /deploy <service>"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			require.Equal(t, testCase.frame, parseFrame([]byte(testCase.data)))
+		})
+	}
+}
@@ -1,8 +1,251 @@
 package ws
 
+import (
+	"Chat-Server/ratelimit"
+	"Chat-Server/repository"
+	"Chat-Server/unfurl"
+	"context"
+	"time"
+)
+
 // Message represents a hub message
 // all messages in the hub are transported in this type
 type Message struct {
-	Author string `json:"author"` // username of the client who wrote the text message
-	Text   string `json:"text"`   // text of the message
+	ID          uint         `json:"id,omitempty"`          // id of the message, assigned when the message is saved
+	Key         string       `json:"key,omitempty"`         // key of the message in the hub, assigned before it is first broadcast
+	Author      string       `json:"author"`                // username of the client who wrote the text message
+	Nickname    string       `json:"nickname,omitempty"`    // nickname the author set with /nick, not saved with the message
+	Text        string       `json:"text"`                  // text of the message
+	HTML        string       `json:"html"`                  // sanitized html of the text, safe to insert into a page
+	Entities    []Entity     `json:"entities,omitempty"`    // mentions, links and code spans of the text
+	Attachments []Attachment `json:"attachments,omitempty"` // files attached to the message
+	Previews    []Preview    `json:"previews,omitempty"`    // previews of the pages linked in the text, sent in a preview event once fetched
+
+	// urls in the text whose previews are fetched once the message is saved
+	links []string
+}
+
+// Entity represents a mention, link or code span of the text of a hub message, its offset and length are
+// in unicode code points of the text
+type Entity struct {
+	Type   string `json:"type"`   // type of the entity: mention, link or code
+	Offset int    `json:"offset"` // offset of the entity in the text
+	Length int    `json:"length"` // length of the entity in the text, including its markup
+	Value  string `json:"value"`  // username of a mention, url of a link or code of a code span
+}
+
+// Attachment represents a file attached to a hub message
+type Attachment struct {
+	ID          string `json:"id"`           // id of the attachment
+	Filename    string `json:"filename"`     // name of the uploaded file
+	ContentType string `json:"content_type"` // content type of the file
+	Size        int64  `json:"size"`         // size of the file in bytes
+	Checksum    string `json:"checksum"`     // hex encoded sha256 hash of the file
+	URL         string `json:"url"`          // url the file is downloaded from
+
+	Width     int        `json:"width,omitempty"`     // width of an image in pixels
+	Height    int        `json:"height,omitempty"`    // height of an image in pixels
+	Thumbnail *Thumbnail `json:"thumbnail,omitempty"` // thumbnail of an image, sent in an attachment event once generated
+}
+
+// Thumbnail represents the thumbnail of an image attached to a hub message
+type Thumbnail struct {
+	URL    string `json:"url"`    // url the thumbnail is downloaded from
+	Width  int    `json:"width"`  // width of the thumbnail in pixels
+	Height int    `json:"height"` // height of the thumbnail in pixels
+}
+
+// Preview represents the preview of a web page linked in a hub message
+type Preview struct {
+	URL         string `json:"url"`                   // url of the page as linked in the text
+	Title       string `json:"title"`                 // title of the page
+	Description string `json:"description,omitempty"` // description of the page
+	Image       string `json:"image,omitempty"`       // url of the image of the page
+	SiteName    string `json:"site_name,omitempty"`   // name of the site of the page
+}
+
+// inboundFrame is a frame sent by a client, a JSON object of this type or the plain text of a message
+type inboundFrame struct {
+	Text        string   `json:"text"`        // text of the message, or of the reply of a bot to a command
+	Attachments []string `json:"attachments"` // ids of the attachments uploaded by the client
+
+	Command *CommandSpec `json:"command"`  // command a bot registers
+	ReplyTo string       `json:"reply_to"` // id of the command invocation a bot replies to
+	Error   string       `json:"error"`    // usage error a bot replies with instead of a text
+}
+
+// CommandSpec describes a command a bot registers with the hub, the invocations of the command are sent to
+// the bot in command events
+type CommandSpec struct {
+	Name        string     `json:"name"`                  // name of the command, without the slash
+	Usage       string     `json:"usage,omitempty"`       // arguments of the command shown by /help, e.g. "<service>"
+	Description string     `json:"description,omitempty"` // what the command does, shown by /help
+	Permission  Permission `json:"permission,omitempty"`  // who may run the command, everyone if empty
+}
+
+// CommandInvocation is an invocation of a bot command, sent to the bot in a command event. the bot replies
+// with a frame whose reply_to is the id of the invocation
+type CommandInvocation struct {
+	ID      string `json:"id"`      // id of the invocation
+	Name    string `json:"name"`    // name of the command
+	Args    string `json:"args"`    // text after the name of the command
+	Invoker string `json:"invoker"` // username of the user who ran the command
+}
+
+// event types sent by the hub to the clients
+const (
+	MessageEvent      = "message"      // a chat message
+	AttachmentEvent   = "attachment"   // an attachment of a sent message was updated, e.g. its thumbnail was generated
+	PreviewEvent      = "preview"      // the previews of the links of a sent message were fetched, the message is identified by its key
+	NotificationEvent = "notification" // the client's user was mentioned in a message
+	ErrorEvent        = "error"        // an error related to the client's last action
+	ReplyEvent        = "reply"        // the reply to a command of the client, sent to the client only
+	CommandEvent      = "command"      // an invocation of a command of the bot, sent to the bot only
+)
+
+// Event represents a frame sent by the hub to a client
+type Event struct {
+	Type         string             `json:"type"`                   // type of the event
+	Message      *Message           `json:"message,omitempty"`      // message of a message or preview event
+	Attachment   *Attachment        `json:"attachment,omitempty"`   // updated attachment of an attachment event
+	Notification *Notification      `json:"notification,omitempty"` // notification of a notification event
+	Command      *CommandInvocation `json:"command,omitempty"`      // invocation of a command event
+	Error        string             `json:"error,omitempty"`        // error of an error event
+}
+
+// Notification represents the notification of a user mentioned in a hub message
+type Notification struct {
+	ID        uint      `json:"id"`         // id of the notification
+	MessageID uint      `json:"message_id"` // id of the message mentioning the user
+	Author    string    `json:"author"`     // author of the message
+	Room      string    `json:"room"`       // room of the message
+	Text      string    `json:"text"`       // text of the message
+	Read      bool      `json:"read"`       // whether the user has read the notification
+	CreatedAt time.Time `json:"created_at"` // time the message is saved
+}
+
+// Durability defines when messages are broadcast relative to being saved into the repository
+type Durability string
+
+const (
+	// DurabilityAsync broadcasts messages immediately and saves them into the repository in the background
+	DurabilityAsync Durability = "async"
+	// DurabilitySync saves messages into the repository in batches and broadcasts them only after they are saved
+	DurabilitySync Durability = "sync"
+)
+
+// AttachmentStore retrieves the attachments the clients attach to their messages
+type AttachmentStore interface {
+	// GetAttachment retrieves an attachment by id
+	GetAttachment(ctx context.Context, id string) (*repository.Attachment, error)
+}
+
+// UserStore retrieves the registered users, bots included
+type UserStore interface {
+	// GetUser retrieves a user by username
+	GetUser(ctx context.Context, username string) (*repository.User, error)
+}
+
+// LinkPreviewer fetches the previews of the pages linked in the messages in the background
+type LinkPreviewer interface {
+	// Enqueue queues the input urls to have their previews fetched, the callback is called once they are fetched
+	Enqueue(urls []string, callback unfurl.PreviewCallback) error
+}
+
+// EventEmitter emits the events of the chat to the webhooks subscribing to them in the background
+type EventEmitter interface {
+	// Emit queues an event of the input type and data to be delivered, without blocking
+	Emit(eventType string, data any) error
+}
+
+// HubConfig holds the configurations of a hub
+type HubConfig struct {
+	Durability Durability // message durability mode of the hub
+
+	// Attachments retrieves the attachments of the messages, clients cannot attach files if nil
+	Attachments AttachmentStore
+
+	// AttachmentURL returns the url an attachment is downloaded from
+	AttachmentURL func(id string) string
+
+	// ThumbnailURL returns the url the thumbnail of an attachment is downloaded from
+	ThumbnailURL func(id string) string
+
+	// Users retrieves the registered users so the nicknames cannot pass for them, only the connected users
+	// are checked if nil
+	Users UserStore
+
+	// LinkPreviews fetches the previews of the links in the messages, links are not previewed if nil
+	LinkPreviews LinkPreviewer
+
+	// Events emits the events of the chat to the webhooks, no event is emitted if nil
+	Events EventEmitter
+
+	// Admins are the usernames of the users allowed to run the commands restricted to administrators
+	Admins []string
+
+	// Commands are registered along with the built-in commands, replacing the built-in commands of the
+	// same names
+	Commands []Command
+
+	// MessageLimiter limits the rate of the frames every user sends, messages and commands alike. nil
+	// disables the limit
+	MessageLimiter *ratelimit.Limiter
+}
+
+// inboundMessage is a message received from a client, or the error of a frame the client sent
+type inboundMessage struct {
+	sender  *Client
+	message Message
+	err     error
+}
+
+// savedMessage is the id of a message saved into the repository in async mode and the notifications
+// of the users it mentions
+type savedMessage struct {
+	message       *Message
+	id            uint
+	notifications []*repository.Notification
+}
+
+// messagePreviews are the fetched previews of the links of a hub message
+type messagePreviews struct {
+	message  *Message
+	previews []unfurl.Preview
+}
+
+// persistedMessage is the result of saving an inbound message into the repository in sync mode
+type persistedMessage struct {
+	inbound       inboundMessage
+	notifications []*repository.Notification
+	err           error
+}
+
+// commandRequest is a command frame of a client: a slash command to run, the registration of a bot command
+// or the reply of a bot to an invocation of its command
+type commandRequest struct {
+	sender *Client
+	text   string       // text of the slash command
+	spec   *CommandSpec // command the bot registers
+	reply  *commandReply
+}
+
+// commandReply is the reply of a bot to an invocation of its command
+type commandReply struct {
+	id   string // id of the invocation
+	text string // text sent to the invoker
+	err  string // usage error sent to the invoker instead of the text
+}
+
+// nicknameCheck is the result of looking up the registered user of the nickname a client asked for
+type nicknameCheck struct {
+	client   *Client
+	nickname string
+	err      error // errNicknameTaken if another user has the nickname as username, or the error of the lookup
+}
+
+// invocation is an invocation of a bot command waiting for the reply of the bot
+type invocation struct {
+	invoker *Client
+	bot     *Client
 }
@@ -0,0 +1,178 @@
+package chatbot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DefaultPrefix starts the commands handled by a Bot unless another prefix is set
+const DefaultPrefix = "!"
+
+// Command is a command sent to the chat, e.g. "!deploy api staging" is the command "deploy" with the
+// arguments "api" and "staging"
+type Command struct {
+	Name    string   // name of the command, without its prefix
+	Args    []string // arguments of the command, split on white space
+	Message *Message // message the command was sent in
+}
+
+// HandlerFunc handles a command, the returned text is posted to the chat as the reply of the bot unless it
+// is empty. a returned error is posted as the reply instead
+type HandlerFunc func(ctx context.Context, command Command) (string, error)
+
+// SlashHandlerFunc handles an invocation of a slash command of the bot, the returned text is sent to the
+// invoker only. a returned error is sent to the invoker as an error instead
+type SlashHandlerFunc func(ctx context.Context, invocation Invocation) (string, error)
+
+// Bot reacts to the commands sent to the chat through a Client, the commands are dispatched to the handlers
+// registered for their names. the messages of the bot and unknown commands are ignored
+type Bot struct {
+	client *Client
+	prefix string
+
+	// mu guards handlers and slashCommands so handlers are registered while the bot runs
+	mu            sync.RWMutex
+	handlers      map[string]HandlerFunc
+	slashCommands map[string]slashCommand
+}
+
+// slashCommand is a slash command of the bot with its handler
+type slashCommand struct {
+	spec    CommandSpec
+	handler SlashHandlerFunc
+}
+
+// NewBot returns a Bot reacting to the commands starting with the input prefix, DefaultPrefix if empty
+func NewBot(client *Client, prefix string) *Bot {
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+
+	return &Bot{
+		client:        client,
+		prefix:        prefix,
+		handlers:      make(map[string]HandlerFunc),
+		slashCommands: make(map[string]slashCommand),
+	}
+}
+
+// Handle registers the handler of the command of the input name, replacing its previous handler
+func (b *Bot) Handle(name string, handler HandlerFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers[name] = handler
+}
+
+// HandleSlash registers the handler of the slash command of the input spec, the command is registered with
+// the hub when the bot runs. it is called before Run
+func (b *Bot) HandleSlash(spec CommandSpec, handler SlashHandlerFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.slashCommands[spec.Name] = slashCommand{spec: spec, handler: handler}
+}
+
+// Run dispatches the commands received by the bot until the input context is done or the connection is
+// closed, and closes the connection. the commands are handled one at a time, in the order they are received
+func (b *Bot) Run(ctx context.Context) error {
+	// a blocked Receive returns once the connection is closed
+	stop := context.AfterFunc(ctx, func() { b.client.Close() })
+	defer stop()
+	defer b.client.Close()
+
+	b.mu.RLock()
+	for _, command := range b.slashCommands {
+		if err := b.client.RegisterCommand(command.spec); err != nil {
+			b.mu.RUnlock()
+			return fmt.Errorf("cannot register command /%s: %w", command.spec.Name, err)
+		}
+	}
+	b.mu.RUnlock()
+
+	for {
+		event, err := b.client.Receive()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		if event.Type == CommandEvent && event.Command != nil {
+			if err := b.runSlash(ctx, *event.Command); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return fmt.Errorf("cannot send reply: %w", err)
+			}
+			continue
+		}
+
+		if event.Type != MessageEvent || event.Message == nil || event.Message.Author == b.client.Username() {
+			continue
+		}
+
+		command, ok := b.parse(event.Message)
+		if !ok {
+			continue
+		}
+
+		b.mu.RLock()
+		handler, ok := b.handlers[command.Name]
+		b.mu.RUnlock()
+		if !ok {
+			continue
+		}
+
+		reply, err := handler(ctx, command)
+		if err != nil {
+			reply = "error: " + err.Error()
+		}
+		if reply == "" {
+			continue
+		}
+
+		if err := b.client.Send(reply); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("cannot send reply: %w", err)
+		}
+	}
+}
+
+// runSlash runs the handler of the input invocation and replies to its invoker, the hub waits for a reply to
+// every invocation so unknown commands are replied to with an error
+func (b *Bot) runSlash(ctx context.Context, invocation Invocation) error {
+	b.mu.RLock()
+	command, ok := b.slashCommands[invocation.Name]
+	b.mu.RUnlock()
+	if !ok {
+		return b.client.ReplyError(invocation.ID, "unknown command /"+invocation.Name)
+	}
+
+	reply, err := command.handler(ctx, invocation)
+	if err != nil {
+		return b.client.ReplyError(invocation.ID, err.Error())
+	}
+
+	return b.client.Reply(invocation.ID, reply)
+}
+
+// parse returns the command of the input message, false if the message is not a command
+func (b *Bot) parse(message *Message) (Command, bool) {
+	text, ok := strings.CutPrefix(message.Text, b.prefix)
+	if !ok {
+		return Command{}, false
+	}
+
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(text, fields[0]) {
+		return Command{}, false
+	}
+
+	return Command{Name: fields[0], Args: fields[1:], Message: message}, true
+}
@@ -0,0 +1,177 @@
+package chatbot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+// testToken is the API token accepted by the test server
+const testToken = "bot_test_token"
+
+// newTestServer returns a test server standing in for the chat server: it authenticates the bot "test_bot"
+// by testToken, sends the input events to the bot once it connects and forwards the frames of the bot
+func newTestServer(t *testing.T, events []Event) (*httptest.Server, <-chan string) {
+	frames := make(chan string, 10)
+	upgrader := websocket.Upgrader{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/bots/me", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+testToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"username": "test_bot", "owner": "test_owner"})
+	})
+	mux.HandleFunc("GET /api/chat", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer "+testToken, r.Header.Get("Authorization"))
+		require.Equal(t, "false", r.URL.Query().Get("history"))
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		for _, event := range events {
+			require.NoError(t, conn.WriteJSON(event))
+		}
+		for {
+			_, frame, err := conn.ReadMessage()
+			if err != nil {
+				close(frames)
+				return
+			}
+			frames <- string(frame)
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server, frames
+}
+
+// receiveFrame returns the next frame the bot sent to the test server
+func receiveFrame(t *testing.T, frames <-chan string) string {
+	select {
+	case frame := <-frames:
+		return frame
+	case <-time.After(5 * time.Second):
+		t.Fatal("no frame sent by the bot")
+		return ""
+	}
+}
+
+// TestDial tests connecting bots to the chat hub
+func TestDial(t *testing.T) {
+	server, frames := newTestServer(t, []Event{{Type: MessageEvent, Message: &Message{ID: 1, Author: "someone", Text: "hi"}}})
+
+	client, err := Dial(context.Background(), server.URL+"/", testToken)
+	require.NoError(t, err)
+	require.Equal(t, "test_bot", client.Username())
+
+	event, err := client.Receive()
+	require.NoError(t, err)
+	require.Equal(t, MessageEvent, event.Type)
+	require.Equal(t, &Message{ID: 1, Author: "someone", Text: "hi"}, event.Message)
+
+	require.NoError(t, client.Send(`hello "world"`))
+	require.Equal(t, `{"text":"hello \"world\""}`, receiveFrame(t, frames))
+
+	require.NoError(t, client.Close())
+	_, err = client.Receive()
+	require.Error(t, err)
+
+	_, err = Dial(context.Background(), server.URL, "bot_other_token")
+	require.ErrorIs(t, err, ErrUnauthorized)
+
+	_, err = Dial(context.Background(), "ftp://example.com", testToken)
+	require.Error(t, err)
+}
+
+// TestBot tests dispatching commands to the handlers of a Bot
+func TestBot(t *testing.T) {
+	server, frames := newTestServer(t, []Event{
+		{Type: "notification"},
+		{Type: MessageEvent, Message: &Message{Author: "someone", Text: "just chatting"}},
+		{Type: MessageEvent, Message: &Message{Author: "test_bot", Text: "!echo my own reply"}},
+		{Type: MessageEvent, Message: &Message{Author: "someone", Text: "!unknown command"}},
+		{Type: MessageEvent, Message: &Message{Author: "someone", Text: "! echo spaced"}},
+		{Type: MessageEvent, Message: &Message{Author: "someone", Text: "!echo  hello   world"}},
+		{Type: MessageEvent, Message: &Message{Author: "someone", Text: "!fail"}},
+		{Type: MessageEvent, Message: &Message{Author: "someone", Text: "!quiet"}},
+		{Type: MessageEvent, Message: &Message{Author: "other", Text: "!echo last"}},
+	})
+
+	client, err := Dial(context.Background(), server.URL, testToken)
+	require.NoError(t, err)
+
+	bot := NewBot(client, "")
+	var authors []string
+	bot.Handle("echo", func(ctx context.Context, command Command) (string, error) {
+		authors = append(authors, command.Message.Author)
+		return command.Message.Author + " said " + command.Args[0] + " " + command.Args[len(command.Args)-1], nil
+	})
+	bot.Handle("fail", func(ctx context.Context, command Command) (string, error) {
+		return "", errors.New("deploy failed")
+	})
+	bot.Handle("quiet", func(ctx context.Context, command Command) (string, error) {
+		return "", nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- bot.Run(ctx) }()
+
+	require.Equal(t, `{"text":"someone said hello world"}`, receiveFrame(t, frames))
+	require.Equal(t, `{"text":"error: deploy failed"}`, receiveFrame(t, frames))
+	require.Equal(t, `{"text":"other said last last"}`, receiveFrame(t, frames))
+
+	// the bot stops and leaves once its context is canceled
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+	require.Equal(t, []string{"someone", "other"}, authors)
+
+	_, ok := <-frames
+	require.False(t, ok)
+}
+
+// TestBot_SlashCommands tests registering the slash commands of a Bot and replying to their invocations
+func TestBot_SlashCommands(t *testing.T) {
+	server, frames := newTestServer(t, []Event{
+		{Type: CommandEvent, Command: &Invocation{ID: "1", Name: "deploy", Args: "api", Invoker: "someone"}},
+		{Type: CommandEvent, Command: &Invocation{ID: "2", Name: "deploy", Invoker: "someone"}},
+		{Type: CommandEvent, Command: &Invocation{ID: "3", Name: "unknown", Invoker: "someone"}},
+	})
+
+	client, err := Dial(context.Background(), server.URL, testToken)
+	require.NoError(t, err)
+
+	bot := NewBot(client, "")
+	bot.HandleSlash(CommandSpec{Name: "deploy", Usage: "<service>", Description: "deploy a service"},
+		func(ctx context.Context, invocation Invocation) (string, error) {
+			if invocation.Args == "" {
+				return "", errors.New("usage: /deploy <service>")
+			}
+			return invocation.Invoker + " deployed " + invocation.Args, nil
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- bot.Run(ctx) }()
+
+	require.JSONEq(t, `{"command":{"name":"deploy","usage":"<service>","description":"deploy a service"}}`,
+		receiveFrame(t, frames))
+	require.Equal(t, `{"reply_to":"1","text":"someone deployed api"}`, receiveFrame(t, frames))
+	require.JSONEq(t, `{"reply_to":"2","error":"usage: /deploy <service>"}`, receiveFrame(t, frames))
+	require.Equal(t, `{"reply_to":"3","error":"unknown command /unknown"}`, receiveFrame(t, frames))
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+}
@@ -0,0 +1,213 @@
+// Package chatbot is a client library for the bots of the chat server. a bot connects to the chat hub with
+// the API token its owner created, receives the messages sent to the chat and posts replies
+package chatbot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrUnauthorized is returned when the server rejects the API token of the bot
+var ErrUnauthorized = errors.New("api token rejected by the server")
+
+// writeWait is the time allowed to write a frame to the server
+const writeWait = 10 * time.Second
+
+// event types received from the chat hub
+const (
+	MessageEvent = "message" // a chat message
+	ErrorEvent   = "error"   // an error related to the last message of the bot
+	CommandEvent = "command" // an invocation of a slash command registered by the bot
+)
+
+// Message is a chat message received from the hub
+type Message struct {
+	ID     uint   `json:"id,omitempty"` // id of the message, zero until the message is saved in some modes
+	Author string `json:"author"`       // username of the author of the message
+	Text   string `json:"text"`         // text of the message
+	HTML   string `json:"html"`         // sanitized html of the text
+}
+
+// CommandSpec describes a slash command a bot registers, users run it by sending "/name args" to the chat
+type CommandSpec struct {
+	Name        string `json:"name"`                  // name of the command, lowercase letters, digits, '_' and '-'
+	Usage       string `json:"usage,omitempty"`       // arguments of the command shown by /help, e.g. "<service>"
+	Description string `json:"description,omitempty"` // what the command does, shown by /help
+	Permission  string `json:"permission,omitempty"`  // "admin" to let only the administrators run it
+}
+
+// Invocation is an invocation of a slash command of the bot, the bot replies to it with Reply or ReplyError
+type Invocation struct {
+	ID      string `json:"id"`      // id of the invocation the reply refers to
+	Name    string `json:"name"`    // name of the command
+	Args    string `json:"args"`    // text after the name of the command
+	Invoker string `json:"invoker"` // username of the user who ran the command
+}
+
+// Event is a frame received from the hub, events of other types than MessageEvent, ErrorEvent and
+// CommandEvent may be received and can be ignored
+type Event struct {
+	Type    string      `json:"type"`              // type of the event
+	Message *Message    `json:"message,omitempty"` // message of a message event
+	Error   string      `json:"error,omitempty"`   // error of an error event
+	Command *Invocation `json:"command,omitempty"` // invocation of a command event
+}
+
+// Client is a connection of a bot to the chat hub. Receive is called from one goroutine at a time, Send is
+// safe for concurrent use
+type Client struct {
+	conn     *websocket.Conn
+	username string
+
+	// writeMu serializes the writes to the connection
+	writeMu sync.Mutex
+}
+
+// Dial connects a bot to the chat hub of the server at the input base url, e.g. "https://chat.example.com",
+// authenticating with the input API token. the bot only receives the messages sent after it connects.
+// returns ErrUnauthorized if the token is rejected
+func Dial(ctx context.Context, serverURL, apiToken string) (*Client, error) {
+	base, err := url.Parse(strings.TrimSuffix(serverURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported server url scheme %q", base.Scheme)
+	}
+
+	header := http.Header{"Authorization": []string{"Bearer " + apiToken}}
+
+	username, err := currentBot(ctx, base.String()+"/api/bots/me", header)
+	if err != nil {
+		return nil, err
+	}
+
+	chatURL := *base
+	chatURL.Scheme = strings.Replace(base.Scheme, "http", "ws", 1)
+	chatURL.Path += "/api/chat"
+	chatURL.RawQuery = "history=false"
+
+	conn, res, err := websocket.DefaultDialer.DialContext(ctx, chatURL.String(), header)
+	if err != nil {
+		if res != nil && res.StatusCode == http.StatusUnauthorized {
+			return nil, ErrUnauthorized
+		}
+		return nil, err
+	}
+
+	return &Client{conn: conn, username: username}, nil
+}
+
+// currentBot returns the username of the bot authenticated by the input header
+func currentBot(ctx context.Context, url string, header http.Header) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header = header.Clone()
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden:
+		return "", ErrUnauthorized
+	case res.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+
+	var bot struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&bot); err != nil {
+		return "", err
+	}
+
+	return bot.Username, nil
+}
+
+// Username returns the username of the bot
+func (c *Client) Username() string {
+	return c.username
+}
+
+// Receive blocks until the next event is received from the hub, returns an error once the connection is
+// closed
+func (c *Client) Receive() (*Event, error) {
+	var event Event
+	if err := c.conn.ReadJSON(&event); err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// Send posts a message with the input text to the chat, as the bot
+func (c *Client) Send(text string) error {
+	return c.write(struct {
+		Text string `json:"text"`
+	}{Text: text})
+}
+
+// RegisterCommand registers a slash command of the bot with the hub, its invocations are received in command
+// events until the bot disconnects. the hub sends an error event if the command is invalid or taken
+func (c *Client) RegisterCommand(spec CommandSpec) error {
+	return c.write(struct {
+		Command CommandSpec `json:"command"`
+	}{Command: spec})
+}
+
+// Reply sends the input text to the invoker of the input invocation only, the reply is not posted to the chat
+func (c *Client) Reply(invocationID, text string) error {
+	return c.write(struct {
+		ReplyTo string `json:"reply_to"`
+		Text    string `json:"text"`
+	}{ReplyTo: invocationID, Text: text})
+}
+
+// ReplyError sends the input error to the invoker of the input invocation, e.g. a usage error
+func (c *Client) ReplyError(invocationID, message string) error {
+	return c.write(struct {
+		ReplyTo string `json:"reply_to"`
+		Error   string `json:"error"`
+	}{ReplyTo: invocationID, Error: message})
+}
+
+// write sends the input value to the hub as a JSON frame
+func (c *Client) write(v any) error {
+	frame, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := c.conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+		return err
+	}
+
+	return c.conn.WriteMessage(websocket.TextMessage, frame)
+}
+
+// Close tells the hub the bot leaves and closes the connection, a blocked Receive returns
+func (c *Client) Close() error {
+	c.writeMu.Lock()
+	_ = c.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeWait))
+	c.writeMu.Unlock()
+
+	return c.conn.Close()
+}
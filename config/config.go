@@ -3,21 +3,96 @@ package config
 import (
 	"fmt"
 	"github.com/spf13/viper"
+	"net"
 	"time"
 )
 
 // Config holds configuration variables
 type Config struct {
-	isProductionEnv        bool          // if we are in the production environment or not
-	databaseAddress        string        // address of the database
-	testDatabaseAddress    string        // address of the test database
-	serverAddress          string        // address of the server
-	tokenSymmetricKey      string        // symmetric key of to make and verify tokens
-	accessTokenDuration    time.Duration // access token duration
-	refreshTokenDuration   time.Duration // refresh token duration
-	accessTokenCookiePath  string        // access token's cookie path
-	refreshTokenCookiePath string        // refresh token's cookie path
-	usernameCookiePath     string        // username's cookie path
+	isProductionEnv              bool          // if we are in the production environment or not
+	databaseDriver               string        // repository backend, "postgres", "sqlite" or "memory"
+	databaseAddress              string        // address of the database
+	testDatabaseAddress          string        // address of the test database
+	serverAddress                string        // address of the server
+	tokenSymmetricKey            string        // symmetric key of to make and verify tokens
+	accessTokenDuration          time.Duration // access token duration
+	refreshTokenDuration         time.Duration // refresh token duration
+	accessTokenCookiePath        string        // access token's cookie path
+	refreshTokenCookiePath       string        // refresh token's cookie path
+	usernameCookiePath           string        // username's cookie path
+	shutdownTimeout              time.Duration // time allowed for the server to shut down gracefully
+	messageDurability            string        // message durability mode, "async" or "sync"
+	messageBatchSize             int           // maximum number of messages saved together in a single insert
+	messageBatchWindow           time.Duration // maximum time a batch of messages waits for more messages
+	messageQueueSize             int           // maximum number of messages waiting to be saved
+	messageWriteRetries          int           // maximum number of retries of a batch failed with a transient error
+	messageRetryBackoff          time.Duration // backoff before the first retry of a failed batch
+	databaseReadTimeout          time.Duration // maximum duration of a database read
+	databaseWriteTimeout         time.Duration // maximum duration of a database write
+	databaseMaxOpenConns         int           // maximum number of open database connections
+	databaseMaxIdleConns         int           // maximum number of idle database connections
+	databaseConnMaxLifetime      time.Duration // maximum time a database connection is reused
+	databaseConnMaxIdleTime      time.Duration // maximum time a database connection stays idle
+	databaseConnectRetries       int           // maximum number of retries of the first database connection
+	databaseConnectBackoff       time.Duration // backoff before the first retry of the database connection
+	databaseReplicaAddresses     []string      // addresses of the read replicas of the database
+	databaseReadYourWritesWindow time.Duration // time the reads of a user who wrote are served by the primary
+	blobStore                    string        // blob store of the attachments, "file" or "s3"
+	blobStorePath                string        // directory of the file blob store
+	blobStoreS3Endpoint          string        // url of the S3-compatible service of the s3 blob store
+	blobStoreS3Region            string        // region of the s3 blob store
+	blobStoreS3Bucket            string        // bucket of the s3 blob store
+	blobStoreS3AccessKeyID       string        // access key of the s3 blob store
+	blobStoreS3SecretAccessKey   string        // secret key of the s3 blob store
+	attachmentMaxSize            int           // maximum size of an attachment in bytes
+	attachmentAllowedTypes       []string      // content types attachments may have
+	thumbnailWorkers             int           // number of thumbnails of image attachments generated concurrently
+	thumbnailQueueSize           int           // maximum number of image attachments waiting for their thumbnails
+	thumbnailMaxDimension        int           // maximum width and height of thumbnails in pixels
+	linkPreviewWorkers           int           // number of link previews fetched concurrently, 0 disables link previews
+	linkPreviewQueueSize         int           // maximum number of messages waiting for the previews of their links
+	linkPreviewTimeout           time.Duration // maximum time spent on fetching the preview of a link
+	linkPreviewCacheSize         int           // maximum number of links whose previews are cached
+	linkPreviewCacheTTL          time.Duration // time the preview of a link is cached for
+	adminUsernames               []string      // usernames of the administrators of the server
+	webhookWorkers               int           // number of webhook events delivered concurrently
+	webhookQueueSize             int           // maximum number of webhook events waiting to be delivered
+	webhookTimeout               time.Duration // maximum time of a webhook delivery attempt
+	webhookMaxAttempts           int           // maximum number of attempts to deliver a webhook event
+	webhookBackoff               time.Duration // delay before the first retry of a webhook delivery, doubled after every attempt
+	webhookMaxBackoff            time.Duration // maximum delay between the attempts of a webhook delivery
+	webhookMaxFailures           int           // number of consecutive failed deliveries after which a webhook is disabled
+	webhookAllowPrivateNetworks  bool          // whether the webhooks may deliver events to loopback and private addresses
+	incomingHookRate             int           // messages an incoming hook may post per minute
+	incomingHookBurst            int           // messages an incoming hook may post at once
+	rateLimitStore               string        // store of the rate limit buckets, "memory" or "database" to share them between instances
+	authRate                     int           // signup, login and refresh requests an address may make per minute, 0 disables the limit
+	authBurst                    int           // signup, login and refresh requests an address may make at once
+	apiRate                      int           // requests a user may make to the authenticated endpoints per minute, 0 disables the limit
+	apiBurst                     int           // requests a user may make to the authenticated endpoints at once
+	messageRate                  int           // messages and commands a user may send to the hub per minute, 0 disables the limit
+	messageBurst                 int           // messages and commands a user may send to the hub at once
+	trustedProxies               []string      // addresses or CIDR ranges of the reverse proxies whose X-Forwarded-For header is trusted
+	loginMaxFailures             int           // consecutive failed logins of a username before it is locked out, 0 disables the lockout
+	loginAddressMaxFailures      int           // consecutive failed logins from an address before it is locked out, 0 disables the lockout
+	loginLockoutDuration         time.Duration // time a username or an address is locked out for
+	loginFailureDelay            time.Duration // delay before the next login of a username after its second failed login, doubled after every failure
+	loginFailureWindow           time.Duration // time after which the failed logins of a username or an address are forgotten
+	totpIssuer                   string        // name of the service shown by the authenticator apps next to the codes of the users
+	twoFactorTokenDuration       time.Duration // time a user has to send the code of the authenticator after the password of a login
+	passwordResetURL             string        // url of the page the users reset their password on, the reset token is added to its query
+	passwordResetTokenDuration   time.Duration // time a password reset token is valid for after it is sent
+	mailer                       string        // mailer of the emails to the users, "log", "file" or "smtp"
+	mailFrom                     string        // sender of the emails to the users, an address or "name <address>"
+	mailDir                      string        // directory the file mailer drops the emails into
+	mailQueueSize                int           // maximum number of emails waiting to be sent in the background
+	mailTimeout                  time.Duration // maximum time spent sending one email
+	smtpAddress                  string        // host:port of the SMTP server of the smtp mailer
+	smtpUsername                 string        // username of the SMTP server, empty if it does not authenticate the clients
+	smtpPassword                 string        // password of the SMTP server
+	emailVerificationURL         string        // url of the page the users verify their email address on, the verification token is added to its query
+	emailVerificationDuration    time.Duration // time an email verification link is valid for after it is sent
+	requireVerifiedEmail         bool          // whether the users need a verified email address to join the hub
 }
 
 // IsProductionEnv returns isProductionEnv config variable
@@ -55,6 +130,11 @@ func (c Config) RefreshTokenDuration() time.Duration {
 	return c.refreshTokenDuration
 }
 
+// DatabaseDriver returns the repository backend
+func (c Config) DatabaseDriver() string {
+	return c.databaseDriver
+}
+
 // DatabaseAddress returns database address
 func (c Config) DatabaseAddress() string {
 	return c.databaseAddress
@@ -70,6 +150,371 @@ func (c Config) ServerAddress() string {
 	return c.serverAddress
 }
 
+// ShutdownTimeout returns the time allowed for the server to shut down gracefully
+func (c Config) ShutdownTimeout() time.Duration {
+	return c.shutdownTimeout
+}
+
+// MessageDurability returns the message durability mode
+func (c Config) MessageDurability() string {
+	return c.messageDurability
+}
+
+// MessageBatchSize returns the maximum number of messages saved together in a single insert
+func (c Config) MessageBatchSize() int {
+	return c.messageBatchSize
+}
+
+// MessageBatchWindow returns the maximum time a batch of messages waits for more messages
+func (c Config) MessageBatchWindow() time.Duration {
+	return c.messageBatchWindow
+}
+
+// MessageQueueSize returns the maximum number of messages waiting to be saved
+func (c Config) MessageQueueSize() int {
+	return c.messageQueueSize
+}
+
+// MessageWriteRetries returns the maximum number of retries of a batch of messages failed with a transient error
+func (c Config) MessageWriteRetries() int {
+	return c.messageWriteRetries
+}
+
+// MessageRetryBackoff returns the backoff before the first retry of a failed batch of messages
+func (c Config) MessageRetryBackoff() time.Duration {
+	return c.messageRetryBackoff
+}
+
+// DatabaseReadTimeout returns the maximum duration of a database read
+func (c Config) DatabaseReadTimeout() time.Duration {
+	return c.databaseReadTimeout
+}
+
+// DatabaseWriteTimeout returns the maximum duration of a database write
+func (c Config) DatabaseWriteTimeout() time.Duration {
+	return c.databaseWriteTimeout
+}
+
+// DatabaseMaxOpenConns returns the maximum number of open database connections, 0 means unlimited
+func (c Config) DatabaseMaxOpenConns() int {
+	return c.databaseMaxOpenConns
+}
+
+// DatabaseMaxIdleConns returns the maximum number of idle database connections kept in the pool
+func (c Config) DatabaseMaxIdleConns() int {
+	return c.databaseMaxIdleConns
+}
+
+// DatabaseConnMaxLifetime returns the maximum time a database connection is reused
+func (c Config) DatabaseConnMaxLifetime() time.Duration {
+	return c.databaseConnMaxLifetime
+}
+
+// DatabaseConnMaxIdleTime returns the maximum time a database connection stays idle
+func (c Config) DatabaseConnMaxIdleTime() time.Duration {
+	return c.databaseConnMaxIdleTime
+}
+
+// DatabaseConnectRetries returns the maximum number of retries of the first database connection
+func (c Config) DatabaseConnectRetries() int {
+	return c.databaseConnectRetries
+}
+
+// DatabaseConnectBackoff returns the backoff before the first retry of the database connection
+func (c Config) DatabaseConnectBackoff() time.Duration {
+	return c.databaseConnectBackoff
+}
+
+// DatabaseReplicaAddresses returns the addresses of the read replicas of the database
+func (c Config) DatabaseReplicaAddresses() []string {
+	return c.databaseReplicaAddresses
+}
+
+// DatabaseReadYourWritesWindow returns the time the reads of a user who just wrote are served by the primary database
+func (c Config) DatabaseReadYourWritesWindow() time.Duration {
+	return c.databaseReadYourWritesWindow
+}
+
+// BlobStore returns the blob store of the attachments
+func (c Config) BlobStore() string {
+	return c.blobStore
+}
+
+// BlobStorePath returns the directory of the file blob store
+func (c Config) BlobStorePath() string {
+	return c.blobStorePath
+}
+
+// BlobStoreS3Endpoint returns the url of the S3-compatible service of the s3 blob store
+func (c Config) BlobStoreS3Endpoint() string {
+	return c.blobStoreS3Endpoint
+}
+
+// BlobStoreS3Region returns the region of the s3 blob store
+func (c Config) BlobStoreS3Region() string {
+	return c.blobStoreS3Region
+}
+
+// BlobStoreS3Bucket returns the bucket of the s3 blob store
+func (c Config) BlobStoreS3Bucket() string {
+	return c.blobStoreS3Bucket
+}
+
+// BlobStoreS3AccessKeyID returns the access key of the s3 blob store
+func (c Config) BlobStoreS3AccessKeyID() string {
+	return c.blobStoreS3AccessKeyID
+}
+
+// BlobStoreS3SecretAccessKey returns the secret key of the s3 blob store
+func (c Config) BlobStoreS3SecretAccessKey() string {
+	return c.blobStoreS3SecretAccessKey
+}
+
+// AttachmentMaxSize returns the maximum size of an attachment in bytes
+func (c Config) AttachmentMaxSize() int {
+	return c.attachmentMaxSize
+}
+
+// AttachmentAllowedTypes returns the content types attachments may have
+func (c Config) AttachmentAllowedTypes() []string {
+	return c.attachmentAllowedTypes
+}
+
+// ThumbnailWorkers returns the number of thumbnails of image attachments generated concurrently
+func (c Config) ThumbnailWorkers() int {
+	return c.thumbnailWorkers
+}
+
+// ThumbnailQueueSize returns the maximum number of image attachments waiting for their thumbnails
+func (c Config) ThumbnailQueueSize() int {
+	return c.thumbnailQueueSize
+}
+
+// ThumbnailMaxDimension returns the maximum width and height of thumbnails in pixels
+func (c Config) ThumbnailMaxDimension() int {
+	return c.thumbnailMaxDimension
+}
+
+// LinkPreviewWorkers returns the number of link previews fetched concurrently, 0 disables link previews
+func (c Config) LinkPreviewWorkers() int {
+	return c.linkPreviewWorkers
+}
+
+// LinkPreviewQueueSize returns the maximum number of messages waiting for the previews of their links
+func (c Config) LinkPreviewQueueSize() int {
+	return c.linkPreviewQueueSize
+}
+
+// LinkPreviewTimeout returns the maximum time spent on fetching the preview of a link
+func (c Config) LinkPreviewTimeout() time.Duration {
+	return c.linkPreviewTimeout
+}
+
+// LinkPreviewCacheSize returns the maximum number of links whose previews are cached
+func (c Config) LinkPreviewCacheSize() int {
+	return c.linkPreviewCacheSize
+}
+
+// LinkPreviewCacheTTL returns the time the preview of a link is cached for
+func (c Config) LinkPreviewCacheTTL() time.Duration {
+	return c.linkPreviewCacheTTL
+}
+
+// AdminUsernames returns the usernames of the administrators of the server
+func (c Config) AdminUsernames() []string {
+	return c.adminUsernames
+}
+
+// WebhookWorkers returns the number of webhook events delivered concurrently
+func (c Config) WebhookWorkers() int {
+	return c.webhookWorkers
+}
+
+// WebhookQueueSize returns the maximum number of webhook events waiting to be delivered
+func (c Config) WebhookQueueSize() int {
+	return c.webhookQueueSize
+}
+
+// WebhookTimeout returns the maximum time of a webhook delivery attempt
+func (c Config) WebhookTimeout() time.Duration {
+	return c.webhookTimeout
+}
+
+// WebhookMaxAttempts returns the maximum number of attempts to deliver a webhook event
+func (c Config) WebhookMaxAttempts() int {
+	return c.webhookMaxAttempts
+}
+
+// WebhookBackoff returns the delay before the first retry of a webhook delivery, doubled after every attempt
+func (c Config) WebhookBackoff() time.Duration {
+	return c.webhookBackoff
+}
+
+// WebhookMaxBackoff returns the maximum delay between the attempts of a webhook delivery
+func (c Config) WebhookMaxBackoff() time.Duration {
+	return c.webhookMaxBackoff
+}
+
+// WebhookMaxFailures returns the number of consecutive failed deliveries after which a webhook is disabled
+func (c Config) WebhookMaxFailures() int {
+	return c.webhookMaxFailures
+}
+
+// WebhookAllowPrivateNetworks returns whether the webhooks may deliver events to loopback and private addresses
+func (c Config) WebhookAllowPrivateNetworks() bool {
+	return c.webhookAllowPrivateNetworks
+}
+
+// IncomingHookRate returns the number of messages an incoming hook may post per minute
+func (c Config) IncomingHookRate() int {
+	return c.incomingHookRate
+}
+
+// IncomingHookBurst returns the number of messages an incoming hook may post at once before it is rate limited
+func (c Config) IncomingHookBurst() int {
+	return c.incomingHookBurst
+}
+
+// RateLimitStore returns the store of the rate limit buckets, "memory" or "database"
+func (c Config) RateLimitStore() string {
+	return c.rateLimitStore
+}
+
+// AuthRate returns the number of signup, login and refresh requests an address may make per minute
+func (c Config) AuthRate() int {
+	return c.authRate
+}
+
+// AuthBurst returns the number of signup, login and refresh requests an address may make at once
+func (c Config) AuthBurst() int {
+	return c.authBurst
+}
+
+// APIRate returns the number of requests a user may make to the authenticated endpoints per minute
+func (c Config) APIRate() int {
+	return c.apiRate
+}
+
+// APIBurst returns the number of requests a user may make to the authenticated endpoints at once
+func (c Config) APIBurst() int {
+	return c.apiBurst
+}
+
+// MessageRate returns the number of messages and commands a user may send to the hub per minute
+func (c Config) MessageRate() int {
+	return c.messageRate
+}
+
+// MessageBurst returns the number of messages and commands a user may send to the hub at once
+func (c Config) MessageBurst() int {
+	return c.messageBurst
+}
+
+// TrustedProxies returns the addresses and CIDR ranges of the reverse proxies whose X-Forwarded-For header is trusted
+func (c Config) TrustedProxies() []string {
+	return c.trustedProxies
+}
+
+// LoginMaxFailures returns the number of consecutive failed logins of a username before it is locked out
+func (c Config) LoginMaxFailures() int {
+	return c.loginMaxFailures
+}
+
+// LoginAddressMaxFailures returns the number of consecutive failed logins from an address before it is locked out
+func (c Config) LoginAddressMaxFailures() int {
+	return c.loginAddressMaxFailures
+}
+
+// LoginLockoutDuration returns the time a username or an address is locked out for
+func (c Config) LoginLockoutDuration() time.Duration {
+	return c.loginLockoutDuration
+}
+
+// LoginFailureDelay returns the delay before the next login of a username after its second failed login, doubled after every failure
+func (c Config) LoginFailureDelay() time.Duration {
+	return c.loginFailureDelay
+}
+
+// LoginFailureWindow returns the time after which the failed logins of a username or an address are forgotten
+func (c Config) LoginFailureWindow() time.Duration {
+	return c.loginFailureWindow
+}
+
+// TOTPIssuer returns the name of the service shown by the authenticator apps next to the codes of the users
+func (c Config) TOTPIssuer() string {
+	return c.totpIssuer
+}
+
+// TwoFactorTokenDuration returns the time a user has to send the code of the authenticator after the password of a login
+func (c Config) TwoFactorTokenDuration() time.Duration {
+	return c.twoFactorTokenDuration
+}
+
+// PasswordResetURL returns the url of the page the users reset their password on
+func (c Config) PasswordResetURL() string {
+	return c.passwordResetURL
+}
+
+// PasswordResetTokenDuration returns the time a password reset token is valid for after it is sent
+func (c Config) PasswordResetTokenDuration() time.Duration {
+	return c.passwordResetTokenDuration
+}
+
+// Mailer returns the mailer of the emails to the users, "log", "file" or "smtp"
+func (c Config) Mailer() string {
+	return c.mailer
+}
+
+// MailFrom returns the sender of the emails to the users
+func (c Config) MailFrom() string {
+	return c.mailFrom
+}
+
+// MailDir returns the directory the file mailer drops the emails into
+func (c Config) MailDir() string {
+	return c.mailDir
+}
+
+// MailQueueSize returns the maximum number of emails waiting to be sent in the background
+func (c Config) MailQueueSize() int {
+	return c.mailQueueSize
+}
+
+// MailTimeout returns the maximum time spent sending one email
+func (c Config) MailTimeout() time.Duration {
+	return c.mailTimeout
+}
+
+// SMTPAddress returns the host:port of the SMTP server of the smtp mailer
+func (c Config) SMTPAddress() string {
+	return c.smtpAddress
+}
+
+// SMTPUsername returns the username of the SMTP server
+func (c Config) SMTPUsername() string {
+	return c.smtpUsername
+}
+
+// SMTPPassword returns the password of the SMTP server
+func (c Config) SMTPPassword() string {
+	return c.smtpPassword
+}
+
+// EmailVerificationURL returns the url of the page the users verify their email address on
+func (c Config) EmailVerificationURL() string {
+	return c.emailVerificationURL
+}
+
+// EmailVerificationDuration returns the time an email verification link is valid for after it is sent
+func (c Config) EmailVerificationDuration() time.Duration {
+	return c.emailVerificationDuration
+}
+
+// RequireVerifiedEmail returns whether the users need a verified email address to join the hub
+func (c Config) RequireVerifiedEmail() bool {
+	return c.requireVerifiedEmail
+}
+
 // GetConfig returns a config object loaded with the config variables of the
 // file specified in the input
 func GetConfig(configFileName, configFileType, configFilePath string) *Config {
@@ -80,6 +525,84 @@ func GetConfig(configFileName, configFileType, configFilePath string) *Config {
 	// read environment variables
 	viper.AutomaticEnv()
 
+	// default values of the optional configurations
+	viper.SetDefault("ACCESS_TOKEN_COOKIE_PATH", "/api")
+	viper.SetDefault("DATABASE_DRIVER", "postgres")
+	viper.SetDefault("SHUTDOWN_TIMEOUT", "10s")
+	viper.SetDefault("MESSAGE_DURABILITY", "async")
+	viper.SetDefault("MESSAGE_BATCH_SIZE", 100)
+	viper.SetDefault("MESSAGE_BATCH_WINDOW", "5ms")
+	viper.SetDefault("MESSAGE_QUEUE_SIZE", 1000)
+	viper.SetDefault("MESSAGE_WRITE_RETRIES", 3)
+	viper.SetDefault("MESSAGE_RETRY_BACKOFF", "100ms")
+	viper.SetDefault("DATABASE_READ_TIMEOUT", "5s")
+	viper.SetDefault("DATABASE_WRITE_TIMEOUT", "5s")
+	viper.SetDefault("DATABASE_MAX_OPEN_CONNS", 25)
+	viper.SetDefault("DATABASE_MAX_IDLE_CONNS", 5)
+	viper.SetDefault("DATABASE_CONN_MAX_LIFETIME", "30m")
+	viper.SetDefault("DATABASE_CONN_MAX_IDLE_TIME", "5m")
+	viper.SetDefault("DATABASE_CONNECT_RETRIES", 5)
+	viper.SetDefault("DATABASE_CONNECT_BACKOFF", "1s")
+	viper.SetDefault("DATABASE_READ_YOUR_WRITES_WINDOW", "5s")
+	viper.SetDefault("BLOB_STORE", "file")
+	viper.SetDefault("BLOB_STORE_PATH", "data/attachments")
+	viper.SetDefault("BLOB_STORE_S3_ENDPOINT", "")
+	viper.SetDefault("BLOB_STORE_S3_REGION", "us-east-1")
+	viper.SetDefault("BLOB_STORE_S3_BUCKET", "")
+	viper.SetDefault("BLOB_STORE_S3_ACCESS_KEY_ID", "")
+	viper.SetDefault("BLOB_STORE_S3_SECRET_ACCESS_KEY", "")
+	viper.SetDefault("ATTACHMENT_MAX_SIZE", 10<<20)
+	viper.SetDefault("ATTACHMENT_ALLOWED_TYPES", []string{
+		"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf", "text/plain",
+	})
+	viper.SetDefault("THUMBNAIL_WORKERS", 2)
+	viper.SetDefault("THUMBNAIL_QUEUE_SIZE", 100)
+	viper.SetDefault("THUMBNAIL_MAX_DIMENSION", 320)
+	viper.SetDefault("LINK_PREVIEW_WORKERS", 2)
+	viper.SetDefault("LINK_PREVIEW_QUEUE_SIZE", 100)
+	viper.SetDefault("LINK_PREVIEW_TIMEOUT", "5s")
+	viper.SetDefault("LINK_PREVIEW_CACHE_SIZE", 1000)
+	viper.SetDefault("LINK_PREVIEW_CACHE_TTL", "1h")
+	viper.SetDefault("ADMIN_USERNAMES", []string{})
+	viper.SetDefault("WEBHOOK_WORKERS", 2)
+	viper.SetDefault("WEBHOOK_QUEUE_SIZE", 1000)
+	viper.SetDefault("WEBHOOK_TIMEOUT", "10s")
+	viper.SetDefault("WEBHOOK_MAX_ATTEMPTS", 5)
+	viper.SetDefault("WEBHOOK_BACKOFF", "1s")
+	viper.SetDefault("WEBHOOK_MAX_BACKOFF", "1m")
+	viper.SetDefault("WEBHOOK_MAX_FAILURES", 10)
+	viper.SetDefault("WEBHOOK_ALLOW_PRIVATE_NETWORKS", false)
+	viper.SetDefault("INCOMING_HOOK_RATE", 30)
+	viper.SetDefault("INCOMING_HOOK_BURST", 10)
+	viper.SetDefault("RATE_LIMIT_STORE", "memory")
+	viper.SetDefault("AUTH_RATE", 10)
+	viper.SetDefault("AUTH_BURST", 5)
+	viper.SetDefault("API_RATE", 300)
+	viper.SetDefault("API_BURST", 60)
+	viper.SetDefault("MESSAGE_RATE", 120)
+	viper.SetDefault("MESSAGE_BURST", 20)
+	viper.SetDefault("TRUSTED_PROXIES", []string{})
+	viper.SetDefault("LOGIN_MAX_FAILURES", 5)
+	viper.SetDefault("LOGIN_ADDRESS_MAX_FAILURES", 20)
+	viper.SetDefault("LOGIN_LOCKOUT_DURATION", "15m")
+	viper.SetDefault("LOGIN_FAILURE_DELAY", "1s")
+	viper.SetDefault("LOGIN_FAILURE_WINDOW", "15m")
+	viper.SetDefault("TOTP_ISSUER", "Chat Hub")
+	viper.SetDefault("TWO_FACTOR_TOKEN_DURATION", "5m")
+	viper.SetDefault("PASSWORD_RESET_URL", "http://localhost:8080/reset")
+	viper.SetDefault("PASSWORD_RESET_TOKEN_DURATION", "1h")
+	viper.SetDefault("MAILER", "log")
+	viper.SetDefault("MAIL_FROM", "Chat Hub <no-reply@localhost>")
+	viper.SetDefault("MAIL_DIR", "data/mail")
+	viper.SetDefault("MAIL_QUEUE_SIZE", 100)
+	viper.SetDefault("MAIL_TIMEOUT", "30s")
+	viper.SetDefault("SMTP_ADDRESS", "localhost:25")
+	viper.SetDefault("SMTP_USERNAME", "")
+	viper.SetDefault("SMTP_PASSWORD", "")
+	viper.SetDefault("EMAIL_VERIFICATION_URL", "http://localhost:8080/verify")
+	viper.SetDefault("EMAIL_VERIFICATION_DURATION", "24h")
+	viper.SetDefault("REQUIRE_VERIFIED_EMAIL", false)
+
 	// read configurations
 	if err := viper.ReadInConfig(); err != nil {
 		panic(fmt.Errorf("unable to read config file: %w", err))
@@ -94,16 +617,196 @@ func GetConfig(configFileName, configFileType, configFilePath string) *Config {
 	if err != nil {
 		panic(fmt.Errorf("unable to read config file: %w", err))
 	}
+	databaseDriver := viper.GetString("DATABASE_DRIVER")
+	if databaseDriver != "postgres" && databaseDriver != "sqlite" && databaseDriver != "memory" {
+		panic(fmt.Errorf("unable to read config file: invalid database driver %q", databaseDriver))
+	}
+	shutdownTimeout, err := time.ParseDuration(viper.GetString("SHUTDOWN_TIMEOUT"))
+	if err != nil {
+		panic(fmt.Errorf("unable to read config file: %w", err))
+	}
+	messageDurability := viper.GetString("MESSAGE_DURABILITY")
+	if messageDurability != "async" && messageDurability != "sync" {
+		panic(fmt.Errorf("unable to read config file: invalid message durability %q", messageDurability))
+	}
+	messageBatchWindow, err := time.ParseDuration(viper.GetString("MESSAGE_BATCH_WINDOW"))
+	if err != nil {
+		panic(fmt.Errorf("unable to read config file: %w", err))
+	}
+	messageRetryBackoff, err := time.ParseDuration(viper.GetString("MESSAGE_RETRY_BACKOFF"))
+	if err != nil {
+		panic(fmt.Errorf("unable to read config file: %w", err))
+	}
+	databaseReadTimeout, err := time.ParseDuration(viper.GetString("DATABASE_READ_TIMEOUT"))
+	if err != nil {
+		panic(fmt.Errorf("unable to read config file: %w", err))
+	}
+	databaseWriteTimeout, err := time.ParseDuration(viper.GetString("DATABASE_WRITE_TIMEOUT"))
+	if err != nil {
+		panic(fmt.Errorf("unable to read config file: %w", err))
+	}
+	databaseConnMaxLifetime, err := time.ParseDuration(viper.GetString("DATABASE_CONN_MAX_LIFETIME"))
+	if err != nil {
+		panic(fmt.Errorf("unable to read config file: %w", err))
+	}
+	databaseConnMaxIdleTime, err := time.ParseDuration(viper.GetString("DATABASE_CONN_MAX_IDLE_TIME"))
+	if err != nil {
+		panic(fmt.Errorf("unable to read config file: %w", err))
+	}
+	databaseConnectBackoff, err := time.ParseDuration(viper.GetString("DATABASE_CONNECT_BACKOFF"))
+	if err != nil {
+		panic(fmt.Errorf("unable to read config file: %w", err))
+	}
+	databaseReadYourWritesWindow, err := time.ParseDuration(viper.GetString("DATABASE_READ_YOUR_WRITES_WINDOW"))
+	if err != nil {
+		panic(fmt.Errorf("unable to read config file: %w", err))
+	}
+	linkPreviewTimeout, err := time.ParseDuration(viper.GetString("LINK_PREVIEW_TIMEOUT"))
+	if err != nil {
+		panic(fmt.Errorf("unable to read config file: %w", err))
+	}
+	linkPreviewCacheTTL, err := time.ParseDuration(viper.GetString("LINK_PREVIEW_CACHE_TTL"))
+	if err != nil {
+		panic(fmt.Errorf("unable to read config file: %w", err))
+	}
+	webhookTimeout, err := time.ParseDuration(viper.GetString("WEBHOOK_TIMEOUT"))
+	if err != nil {
+		panic(fmt.Errorf("unable to read config file: %w", err))
+	}
+	webhookBackoff, err := time.ParseDuration(viper.GetString("WEBHOOK_BACKOFF"))
+	if err != nil {
+		panic(fmt.Errorf("unable to read config file: %w", err))
+	}
+	webhookMaxBackoff, err := time.ParseDuration(viper.GetString("WEBHOOK_MAX_BACKOFF"))
+	if err != nil {
+		panic(fmt.Errorf("unable to read config file: %w", err))
+	}
+	rateLimitStore := viper.GetString("RATE_LIMIT_STORE")
+	if rateLimitStore != "memory" && rateLimitStore != "database" {
+		panic(fmt.Errorf("unable to read config file: invalid rate limit store %q", rateLimitStore))
+	}
+	trustedProxies := viper.GetStringSlice("TRUSTED_PROXIES")
+	for _, proxy := range trustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			panic(fmt.Errorf("unable to read config file: invalid trusted proxy %q", proxy))
+		}
+	}
+	loginLockoutDuration, err := time.ParseDuration(viper.GetString("LOGIN_LOCKOUT_DURATION"))
+	if err != nil {
+		panic(fmt.Errorf("unable to read config file: %w", err))
+	}
+	loginFailureDelay, err := time.ParseDuration(viper.GetString("LOGIN_FAILURE_DELAY"))
+	if err != nil {
+		panic(fmt.Errorf("unable to read config file: %w", err))
+	}
+	loginFailureWindow, err := time.ParseDuration(viper.GetString("LOGIN_FAILURE_WINDOW"))
+	if err != nil {
+		panic(fmt.Errorf("unable to read config file: %w", err))
+	}
+	twoFactorTokenDuration, err := time.ParseDuration(viper.GetString("TWO_FACTOR_TOKEN_DURATION"))
+	if err != nil {
+		panic(fmt.Errorf("unable to read config file: %w", err))
+	}
+	passwordResetTokenDuration, err := time.ParseDuration(viper.GetString("PASSWORD_RESET_TOKEN_DURATION"))
+	if err != nil {
+		panic(fmt.Errorf("unable to read config file: %w", err))
+	}
+	mailer := viper.GetString("MAILER")
+	if mailer != "log" && mailer != "file" && mailer != "smtp" {
+		panic(fmt.Errorf("unable to read config file: invalid mailer %q", mailer))
+	}
+	mailTimeout, err := time.ParseDuration(viper.GetString("MAIL_TIMEOUT"))
+	if err != nil {
+		panic(fmt.Errorf("unable to read config file: %w", err))
+	}
+	emailVerificationDuration, err := time.ParseDuration(viper.GetString("EMAIL_VERIFICATION_DURATION"))
+	if err != nil {
+		panic(fmt.Errorf("unable to read config file: %w", err))
+	}
 	return &Config{
-		isProductionEnv:        viper.Get("IS_PRODUCTION_ENV").(bool),
-		databaseAddress:        viper.Get("DATABASE_ADDRESS").(string),
-		testDatabaseAddress:    viper.Get("TEST_DATABASE_ADDRESS").(string),
-		serverAddress:          viper.Get("SERVER_ADDRESS").(string),
-		tokenSymmetricKey:      viper.Get("TOKEN_SYMMETRIC_KEY").(string),
-		accessTokenDuration:    accessTokenDuration,
-		refreshTokenDuration:   refreshTokenDuration,
-		accessTokenCookiePath:  viper.Get("ACCESS_TOKEN_COOKIE_PATH").(string),
-		refreshTokenCookiePath: viper.Get("REFRESH_TOKEN_COOKIE_PATH").(string),
-		usernameCookiePath:     viper.Get("USERNAME_COOKIE_PATH").(string),
+		isProductionEnv:              viper.Get("IS_PRODUCTION_ENV").(bool),
+		databaseDriver:               databaseDriver,
+		databaseAddress:              viper.Get("DATABASE_ADDRESS").(string),
+		testDatabaseAddress:          viper.Get("TEST_DATABASE_ADDRESS").(string),
+		serverAddress:                viper.Get("SERVER_ADDRESS").(string),
+		tokenSymmetricKey:            viper.Get("TOKEN_SYMMETRIC_KEY").(string),
+		accessTokenDuration:          accessTokenDuration,
+		refreshTokenDuration:         refreshTokenDuration,
+		accessTokenCookiePath:        viper.Get("ACCESS_TOKEN_COOKIE_PATH").(string),
+		refreshTokenCookiePath:       viper.Get("REFRESH_TOKEN_COOKIE_PATH").(string),
+		usernameCookiePath:           viper.Get("USERNAME_COOKIE_PATH").(string),
+		shutdownTimeout:              shutdownTimeout,
+		messageDurability:            messageDurability,
+		messageBatchSize:             viper.GetInt("MESSAGE_BATCH_SIZE"),
+		messageBatchWindow:           messageBatchWindow,
+		messageQueueSize:             viper.GetInt("MESSAGE_QUEUE_SIZE"),
+		messageWriteRetries:          viper.GetInt("MESSAGE_WRITE_RETRIES"),
+		messageRetryBackoff:          messageRetryBackoff,
+		databaseReadTimeout:          databaseReadTimeout,
+		databaseWriteTimeout:         databaseWriteTimeout,
+		databaseMaxOpenConns:         viper.GetInt("DATABASE_MAX_OPEN_CONNS"),
+		databaseMaxIdleConns:         viper.GetInt("DATABASE_MAX_IDLE_CONNS"),
+		databaseConnMaxLifetime:      databaseConnMaxLifetime,
+		databaseConnMaxIdleTime:      databaseConnMaxIdleTime,
+		databaseConnectRetries:       viper.GetInt("DATABASE_CONNECT_RETRIES"),
+		databaseConnectBackoff:       databaseConnectBackoff,
+		databaseReplicaAddresses:     viper.GetStringSlice("DATABASE_REPLICA_ADDRESSES"),
+		databaseReadYourWritesWindow: databaseReadYourWritesWindow,
+		blobStore:                    viper.GetString("BLOB_STORE"),
+		blobStorePath:                viper.GetString("BLOB_STORE_PATH"),
+		blobStoreS3Endpoint:          viper.GetString("BLOB_STORE_S3_ENDPOINT"),
+		blobStoreS3Region:            viper.GetString("BLOB_STORE_S3_REGION"),
+		blobStoreS3Bucket:            viper.GetString("BLOB_STORE_S3_BUCKET"),
+		blobStoreS3AccessKeyID:       viper.GetString("BLOB_STORE_S3_ACCESS_KEY_ID"),
+		blobStoreS3SecretAccessKey:   viper.GetString("BLOB_STORE_S3_SECRET_ACCESS_KEY"),
+		attachmentMaxSize:            viper.GetInt("ATTACHMENT_MAX_SIZE"),
+		attachmentAllowedTypes:       viper.GetStringSlice("ATTACHMENT_ALLOWED_TYPES"),
+		thumbnailWorkers:             viper.GetInt("THUMBNAIL_WORKERS"),
+		thumbnailQueueSize:           viper.GetInt("THUMBNAIL_QUEUE_SIZE"),
+		thumbnailMaxDimension:        viper.GetInt("THUMBNAIL_MAX_DIMENSION"),
+		linkPreviewWorkers:           viper.GetInt("LINK_PREVIEW_WORKERS"),
+		linkPreviewQueueSize:         viper.GetInt("LINK_PREVIEW_QUEUE_SIZE"),
+		linkPreviewTimeout:           linkPreviewTimeout,
+		linkPreviewCacheSize:         viper.GetInt("LINK_PREVIEW_CACHE_SIZE"),
+		linkPreviewCacheTTL:          linkPreviewCacheTTL,
+		adminUsernames:               viper.GetStringSlice("ADMIN_USERNAMES"),
+		webhookWorkers:               viper.GetInt("WEBHOOK_WORKERS"),
+		webhookQueueSize:             viper.GetInt("WEBHOOK_QUEUE_SIZE"),
+		webhookTimeout:               webhookTimeout,
+		webhookMaxAttempts:           viper.GetInt("WEBHOOK_MAX_ATTEMPTS"),
+		webhookBackoff:               webhookBackoff,
+		webhookMaxBackoff:            webhookMaxBackoff,
+		webhookMaxFailures:           viper.GetInt("WEBHOOK_MAX_FAILURES"),
+		webhookAllowPrivateNetworks:  viper.GetBool("WEBHOOK_ALLOW_PRIVATE_NETWORKS"),
+		incomingHookRate:             viper.GetInt("INCOMING_HOOK_RATE"),
+		incomingHookBurst:            viper.GetInt("INCOMING_HOOK_BURST"),
+		rateLimitStore:               rateLimitStore,
+		authRate:                     viper.GetInt("AUTH_RATE"),
+		authBurst:                    viper.GetInt("AUTH_BURST"),
+		apiRate:                      viper.GetInt("API_RATE"),
+		apiBurst:                     viper.GetInt("API_BURST"),
+		messageRate:                  viper.GetInt("MESSAGE_RATE"),
+		messageBurst:                 viper.GetInt("MESSAGE_BURST"),
+		trustedProxies:               trustedProxies,
+		loginMaxFailures:             viper.GetInt("LOGIN_MAX_FAILURES"),
+		loginAddressMaxFailures:      viper.GetInt("LOGIN_ADDRESS_MAX_FAILURES"),
+		loginLockoutDuration:         loginLockoutDuration,
+		loginFailureDelay:            loginFailureDelay,
+		loginFailureWindow:           loginFailureWindow,
+		totpIssuer:                   viper.GetString("TOTP_ISSUER"),
+		twoFactorTokenDuration:       twoFactorTokenDuration,
+		passwordResetURL:             viper.GetString("PASSWORD_RESET_URL"),
+		passwordResetTokenDuration:   passwordResetTokenDuration,
+		mailer:                       mailer,
+		mailFrom:                     viper.GetString("MAIL_FROM"),
+		mailDir:                      viper.GetString("MAIL_DIR"),
+		mailQueueSize:                viper.GetInt("MAIL_QUEUE_SIZE"),
+		mailTimeout:                  mailTimeout,
+		smtpAddress:                  viper.GetString("SMTP_ADDRESS"),
+		smtpUsername:                 viper.GetString("SMTP_USERNAME"),
+		smtpPassword:                 viper.GetString("SMTP_PASSWORD"),
+		emailVerificationURL:         viper.GetString("EMAIL_VERIFICATION_URL"),
+		emailVerificationDuration:    emailVerificationDuration,
+		requireVerifiedEmail:         viper.GetBool("REQUIRE_VERIFIED_EMAIL"),
 	}
 }
@@ -0,0 +1,76 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// FileMailer implements Mailer by dropping every email as an .eml file into a directory instead of sending
+// it, for local use and for the tests of the clients. the files open in any mail client
+type FileMailer struct {
+	dir  string
+	from *netmail.Address
+}
+
+// ensure FileMailer implements Mailer interface
+var _ Mailer = (*FileMailer)(nil)
+
+// NewFileMailer returns a FileMailer dropping the emails of the input sender into the input directory, which
+// is created if missing
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	sender, err := parseAddress(from)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+
+	return &FileMailer{dir: dir, from: sender}, nil
+}
+
+// Send writes the input message into a temporary file and renames it to an .eml file, so readers of the
+// directory never see a partially written email. the files are named after the time they are sent
+func (m *FileMailer) Send(ctx context.Context, message Message) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	email, err := format(m.from, message, now)
+	if err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := strconv.FormatInt(now.UnixNano(), 10) + "-" + hex.EncodeToString(suffix) + ".eml"
+
+	file, err := os.CreateTemp(m.dir, ".mail-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+
+	if _, err := file.Write(email); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), filepath.Join(m.dir, name))
+}
@@ -0,0 +1,109 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidAddress is returned for the messages whose sender or recipient is not a valid email address
+var ErrInvalidAddress = errors.New("invalid email address")
+
+// Message is an email sent to a user
+type Message struct {
+	// To is the email address of the recipient
+	To string
+	// Subject of the email
+	Subject string
+	// Text is the plain text body of the email
+	Text string
+}
+
+// Mailer sends emails to the users
+type Mailer interface {
+	// Send sends the input message, returns once the message is handed over
+	Send(ctx context.Context, message Message) error
+}
+
+// LogMailer implements Mailer by writing the emails to a log instead of sending them, for local use
+type LogMailer struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+// ensure LogMailer implements Mailer interface
+var _ Mailer = (*LogMailer)(nil)
+
+// NewLogMailer returns a LogMailer writing the emails to the input writer
+func NewLogMailer(writer io.Writer) *LogMailer {
+	return &LogMailer{writer: writer}
+}
+
+// Send writes the input message to the log, the messages written at once are not interleaved
+func (m *LogMailer) Send(ctx context.Context, message Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := fmt.Fprintf(m.writer, "mail to %s\nsubject: %s\n\n%s\n\n", message.To, message.Subject, message.Text)
+	return err
+}
+
+// parseAddress returns the address of the input "name <address>" or "address" email address
+func parseAddress(address string) (*netmail.Address, error) {
+	parsed, err := netmail.ParseAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidAddress, address)
+	}
+
+	return parsed, nil
+}
+
+// format returns the input message sent by the input sender at the input time as an RFC 5322 email, the
+// body is quoted-printable so any text is sent as is
+func format(from *netmail.Address, message Message, date time.Time) ([]byte, error) {
+	to, err := parseAddress(message.To)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+
+	var email bytes.Buffer
+	fmt.Fprintf(&email, "From: %s\r\n", from.String())
+	fmt.Fprintf(&email, "To: %s\r\n", to.String())
+	fmt.Fprintf(&email, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&email, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&email, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), domain)
+	email.WriteString("MIME-Version: 1.0\r\n")
+	email.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	email.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	body := quotedprintable.NewWriter(&email)
+	if _, err := body.Write([]byte(message.Text)); err != nil {
+		return nil, err
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+	email.WriteString("\r\n")
+
+	return email.Bytes(), nil
+}
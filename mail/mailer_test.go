@@ -0,0 +1,132 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/require"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestLogMailer tests writing the emails to a log
+func TestLogMailer(t *testing.T) {
+	var log bytes.Buffer
+	mailer := NewLogMailer(&log)
+
+	err := mailer.Send(context.Background(), Message{To: "alice", Subject: "Reset your password", Text: "https://chat.example.com/reset"})
+	require.NoError(t, err)
+	require.Equal(t, "mail to alice\nsubject: Reset your password\n\nhttps://chat.example.com/reset\n\n", log.String())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, mailer.Send(ctx, Message{To: "bob"}), context.Canceled)
+	require.NotContains(t, log.String(), "bob")
+}
+
+// TestFormat tests formatting the messages as emails
+func TestFormat(t *testing.T) {
+	from, err := parseAddress("Chat Hub <no-reply@chat.example.com>")
+	require.NoError(t, err)
+	date := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name          string
+		message       Message
+		checkResponse func(t *testing.T, email *netmail.Message, err error)
+	}{
+		{
+			name:    "OK",
+			message: Message{To: "alice@example.com", Subject: "Verify your email", Text: "Open the link:\n\nhttps://chat.example.com/verify?token=a=b"},
+			checkResponse: func(t *testing.T, email *netmail.Message, err error) {
+				require.NoError(t, err)
+				require.Equal(t, `"Chat Hub" <no-reply@chat.example.com>`, email.Header.Get("From"))
+				require.Equal(t, "<alice@example.com>", email.Header.Get("To"))
+				require.Equal(t, "Verify your email", email.Header.Get("Subject"))
+				sent, err := email.Header.Date()
+				require.NoError(t, err)
+				require.True(t, date.Equal(sent))
+				require.Regexp(t, `^<[0-9a-f]{32}@chat\.example\.com>$`, email.Header.Get("Message-ID"))
+
+				body, err := io.ReadAll(quotedprintable.NewReader(email.Body))
+				require.NoError(t, err)
+				require.Equal(t, "Open the link:\r\n\r\nhttps://chat.example.com/verify?token=a=b\r\n", string(body))
+			},
+		},
+		{
+			name:    "HeaderInjection",
+			message: Message{To: "alice@example.com", Subject: "Hello\r\nBcc: mallory@example.com"},
+			checkResponse: func(t *testing.T, email *netmail.Message, err error) {
+				require.NoError(t, err)
+				require.Empty(t, email.Header.Get("Bcc"))
+				subject, err := new(mime.WordDecoder).DecodeHeader(email.Header.Get("Subject"))
+				require.NoError(t, err)
+				require.Equal(t, "Hello\r\nBcc: mallory@example.com", subject)
+			},
+		},
+		{
+			name:    "InvalidAddress",
+			message: Message{To: "alice\r\nBcc: mallory@example.com"},
+			checkResponse: func(t *testing.T, email *netmail.Message, err error) {
+				require.ErrorIs(t, err, ErrInvalidAddress)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			formatted, err := format(from, tc.message, date)
+			if err != nil {
+				tc.checkResponse(t, nil, err)
+				return
+			}
+			email, err := netmail.ReadMessage(bytes.NewReader(formatted))
+			require.NoError(t, err)
+			tc.checkResponse(t, email, nil)
+		})
+	}
+}
+
+// TestFileMailer tests dropping the emails into a directory
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	_, err := NewFileMailer(dir, "not an address")
+	require.ErrorIs(t, err, ErrInvalidAddress)
+
+	mailer, err := NewFileMailer(dir, "no-reply@chat.example.com")
+	require.NoError(t, err)
+
+	for _, to := range []string{"alice@example.com", "bob@example.com"} {
+		require.NoError(t, mailer.Send(context.Background(), Message{To: to, Subject: "Reset your password", Text: "https://chat.example.com/reset"}))
+	}
+	require.ErrorIs(t, mailer.Send(context.Background(), Message{To: "carol"}), ErrInvalidAddress)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, mailer.Send(ctx, Message{To: "dave@example.com"}), context.Canceled)
+
+	// only the emails which are sent are in the directory, without temporary files
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	recipients := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		require.Equal(t, ".eml", filepath.Ext(entry.Name()))
+		file, err := os.Open(filepath.Join(dir, entry.Name()))
+		require.NoError(t, err)
+		email, err := netmail.ReadMessage(file)
+		require.NoError(t, err)
+		require.Equal(t, "Reset your password", email.Header.Get("Subject"))
+		recipients = append(recipients, email.Header.Get("To"))
+		require.NoError(t, file.Close())
+	}
+	require.ElementsMatch(t, []string{"<alice@example.com>", "<bob@example.com>"}, recipients)
+}
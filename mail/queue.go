@@ -0,0 +1,125 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// errors returned by Queue
+var (
+	ErrQueueFull = errors.New("mail queue is full")
+	ErrClosed    = errors.New("mail queue is closed")
+)
+
+// QueueConfig holds the configurations of a Queue
+type QueueConfig struct {
+	Workers   int           // number of emails sent concurrently
+	QueueSize int           // maximum number of emails waiting to be sent
+	Timeout   time.Duration // maximum time spent sending one email
+}
+
+// Queue is a bounded pool of workers sending emails in the background, so the requests sending them do not
+// wait for the mail server. the emails which cannot be sent are logged and dropped
+type Queue struct {
+	mailer Mailer
+	config QueueConfig
+
+	// emails waiting to be sent
+	queue chan Message
+
+	// mu guards closed so no email is queued after the queue is closed
+	mu     sync.RWMutex
+	closed bool
+
+	// workers is done when all queued emails are sent after the queue is closed
+	workers sync.WaitGroup
+}
+
+// NewQueue creates a Queue sending the emails with the input mailer and starts its workers
+func NewQueue(mailer Mailer, config QueueConfig) *Queue {
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+	if config.QueueSize < 1 {
+		config.QueueSize = 1
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+
+	q := &Queue{
+		mailer: mailer,
+		config: config,
+		queue:  make(chan Message, config.QueueSize),
+	}
+
+	q.workers.Add(config.Workers)
+	for i := 0; i < config.Workers; i++ {
+		go q.run()
+	}
+
+	return q
+}
+
+// Enqueue queues the input message to be sent. returns ErrQueueFull without blocking if the queue is full
+func (q *Queue) Enqueue(message Message) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrClosed
+	}
+
+	select {
+	case q.queue <- message:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting new emails and waits until all queued emails are sent or the input context is done
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.queue)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run sends queued emails until the queue is closed and drained
+func (q *Queue) run() {
+	defer q.workers.Done()
+
+	for message := range q.queue {
+		if err := q.send(message); err != nil {
+			log.Error().Err(err).Str("subject", message.Subject).Msg("failed to send email")
+		}
+	}
+}
+
+// send sends the input message within the timeout of the queue
+func (q *Queue) send(message Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), q.config.Timeout)
+	defer cancel()
+
+	return q.mailer.Send(ctx, message)
+}
@@ -0,0 +1,76 @@
+package mail
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// mailerFunc implements Mailer with a function
+type mailerFunc func(ctx context.Context, message Message) error
+
+// Send calls the function with the input message
+func (f mailerFunc) Send(ctx context.Context, message Message) error {
+	return f(ctx, message)
+}
+
+// TestQueue tests sending the emails in the background
+func TestQueue(t *testing.T) {
+	t.Run("Send", func(t *testing.T) {
+		sent := make(chan Message, 2)
+		queue := NewQueue(mailerFunc(func(ctx context.Context, message Message) error {
+			sent <- message
+			return nil
+		}), QueueConfig{QueueSize: 2})
+
+		require.NoError(t, queue.Enqueue(Message{To: "alice"}))
+		require.NoError(t, queue.Enqueue(Message{To: "bob"}))
+
+		// the queued emails are sent before the queue is closed
+		require.NoError(t, queue.Close(context.Background()))
+		require.Equal(t, "alice", (<-sent).To)
+		require.Equal(t, "bob", (<-sent).To)
+		require.ErrorIs(t, queue.Enqueue(Message{To: "carol"}), ErrClosed)
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		errs := make(chan error, 1)
+		queue := NewQueue(mailerFunc(func(ctx context.Context, message Message) error {
+			<-ctx.Done()
+			errs <- ctx.Err()
+			return ctx.Err()
+		}), QueueConfig{Timeout: 50 * time.Millisecond})
+
+		// the email is queued without waiting for the mailer, which gives up at the timeout of the queue
+		start := time.Now()
+		require.NoError(t, queue.Enqueue(Message{To: "alice"}))
+		require.Less(t, time.Since(start), 50*time.Millisecond)
+		require.ErrorIs(t, <-errs, context.DeadlineExceeded)
+		require.NoError(t, queue.Close(context.Background()))
+	})
+
+	t.Run("QueueFull", func(t *testing.T) {
+		sending, release := make(chan struct{}), make(chan struct{})
+		queue := NewQueue(mailerFunc(func(ctx context.Context, message Message) error {
+			sending <- struct{}{}
+			<-release
+			return nil
+		}), QueueConfig{QueueSize: 1})
+
+		// the worker holds the first email and the queue the second
+		require.NoError(t, queue.Enqueue(Message{To: "alice"}))
+		<-sending
+		require.NoError(t, queue.Enqueue(Message{To: "bob"}))
+		require.ErrorIs(t, queue.Enqueue(Message{To: "carol"}), ErrQueueFull)
+
+		// closing gives up when the context is done before the queue is drained
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, queue.Close(ctx), context.DeadlineExceeded)
+
+		close(release)
+		<-sending
+		require.NoError(t, queue.Close(context.Background()))
+	})
+}
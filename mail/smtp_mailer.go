@@ -0,0 +1,123 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SMTPConfig configures an SMTPMailer
+type SMTPConfig struct {
+	Address  string // host:port of the SMTP server
+	Username string // username of the SMTP server, empty if it does not authenticate the clients
+	Password string // password of the SMTP server
+	From     string // sender of the emails, an address or "name <address>"
+}
+
+// SMTPMailer implements Mailer by sending the emails through an SMTP server. the connection is upgraded with
+// STARTTLS whenever the server offers it, and the credentials are only sent over TLS or to a local server
+type SMTPMailer struct {
+	address  string
+	host     string
+	username string
+	password string
+	from     *netmail.Address
+}
+
+// ensure SMTPMailer implements Mailer interface
+var _ Mailer = (*SMTPMailer)(nil)
+
+// NewSMTPMailer returns an SMTPMailer sending the emails through the server of the input configurations
+func NewSMTPMailer(config SMTPConfig) (*SMTPMailer, error) {
+	host, _, err := net.SplitHostPort(config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid smtp address %q: %w", config.Address, err)
+	}
+	sender, err := parseAddress(config.From)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SMTPMailer{
+		address:  config.Address,
+		host:     host,
+		username: config.Username,
+		password: config.Password,
+		from:     sender,
+	}, nil
+}
+
+// Send sends the input message through the SMTP server, the whole exchange with the server is bounded by
+// the deadline of the context
+func (m *SMTPMailer) Send(ctx context.Context, message Message) error {
+	to, err := parseAddress(message.To)
+	if err != nil {
+		return err
+	}
+	email, err := format(m.from, message, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// the commands of the exchange do not take the context, the connection is closed once it is done
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	err = m.send(conn, to.Address, email)
+	if err != nil && ctx.Err() != nil {
+		return errors.Join(ctx.Err(), err)
+	}
+	return err
+}
+
+// send sends the input email to the input recipient through the SMTP connection
+func (m *SMTPMailer) send(conn net.Conn, to string, email []byte) error {
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(m.from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+
+	data, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := data.Write(email); err != nil {
+		return err
+	}
+	if err := data.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
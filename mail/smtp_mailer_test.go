@@ -0,0 +1,278 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"github.com/stretchr/testify/require"
+	"io"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpEnvelope is an email received by the SMTP stand-in
+type smtpEnvelope struct {
+	from string
+	to   []string
+	data []byte
+}
+
+// smtpServer is a local stand-in of an SMTP server, it authenticates the clients with AUTH PLAIN and rejects
+// the recipients of the rejected address
+type smtpServer struct {
+	listener net.Listener
+	username string
+	password string
+	rejected string
+	// stall makes the server accept the connections without ever greeting the clients
+	stall bool
+
+	mu       sync.Mutex
+	received []smtpEnvelope
+}
+
+// newSMTPServer listens on a local port for an SMTP stand-in, it is stopped at the end of the test
+func newSMTPServer(t *testing.T, username, password string) *smtpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	return &smtpServer{listener: listener, username: username, password: password, rejected: "mallory@example.com"}
+}
+
+// start serves the clients of the server until the end of the test
+func (s *smtpServer) start(t *testing.T) {
+	var wg sync.WaitGroup
+	t.Cleanup(func() {
+		s.listener.Close()
+		wg.Wait()
+	})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := s.listener.Accept()
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer conn.Close()
+				s.serve(conn)
+			}()
+		}
+	}()
+}
+
+// address returns the host:port of the server
+func (s *smtpServer) address() string {
+	return s.listener.Addr().String()
+}
+
+// emails returns the emails received by the server
+func (s *smtpServer) emails() []smtpEnvelope {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]smtpEnvelope(nil), s.received...)
+}
+
+// serve runs the SMTP exchange of a client
+func (s *smtpServer) serve(conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if s.stall {
+		io.Copy(io.Discard, conn)
+		return
+	}
+
+	text := textproto.NewConn(conn)
+	reply := func(format string, args ...any) { text.PrintfLine(format, args...) }
+
+	reply("220 localhost ESMTP")
+	authenticated := s.username == ""
+	var envelope smtpEnvelope
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			credentials, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(arg, "PLAIN "))
+			if err == nil && string(credentials) == "\x00"+s.username+"\x00"+s.password {
+				authenticated = true
+				reply("235 2.7.0 authentication succeeded")
+			} else {
+				reply("535 5.7.8 authentication credentials invalid")
+			}
+		case "MAIL":
+			if !authenticated {
+				reply("530 5.7.0 authentication required")
+				continue
+			}
+			envelope = smtpEnvelope{from: strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")}
+			reply("250 2.1.0 ok")
+		case "RCPT":
+			to := strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>")
+			if to == s.rejected {
+				reply("550 5.1.1 mailbox unavailable")
+				continue
+			}
+			envelope.to = append(envelope.to, to)
+			reply("250 2.1.5 ok")
+		case "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			envelope.data = data
+			s.mu.Lock()
+			s.received = append(s.received, envelope)
+			s.mu.Unlock()
+			reply("250 2.0.0 queued")
+		case "QUIT":
+			reply("221 2.0.0 bye")
+			return
+		default:
+			reply("502 5.5.2 command not implemented")
+		}
+	}
+}
+
+// TestSMTPMailer tests sending the emails through an SMTP server
+func TestSMTPMailer(t *testing.T) {
+	message := Message{To: "alice@example.com", Subject: "Verify your email", Text: "https://chat.example.com/verify?token=abc"}
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(server *smtpServer, config *SMTPConfig)
+		message       Message
+		checkResponse func(t *testing.T, server *smtpServer, err error)
+	}{
+		{
+			name:    "OK",
+			message: message,
+			checkResponse: func(t *testing.T, server *smtpServer, err error) {
+				require.NoError(t, err)
+				emails := server.emails()
+				require.Len(t, emails, 1)
+				require.Equal(t, "no-reply@chat.example.com", emails[0].from)
+				require.Equal(t, []string{"alice@example.com"}, emails[0].to)
+
+				email, err := netmail.ReadMessage(bytes.NewReader(emails[0].data))
+				require.NoError(t, err)
+				require.Equal(t, `"Chat Hub" <no-reply@chat.example.com>`, email.Header.Get("From"))
+				require.Equal(t, "<alice@example.com>", email.Header.Get("To"))
+				require.Equal(t, "Verify your email", email.Header.Get("Subject"))
+				body, err := io.ReadAll(quotedprintable.NewReader(email.Body))
+				require.NoError(t, err)
+				// the stand-in reads the lines of the data without their CR
+				require.Equal(t, "https://chat.example.com/verify?token=abc\n", string(body))
+			},
+		},
+		{
+			name: "NoAuthentication",
+			buildStubs: func(server *smtpServer, config *SMTPConfig) {
+				server.username = ""
+				config.Username = ""
+			},
+			message: message,
+			checkResponse: func(t *testing.T, server *smtpServer, err error) {
+				require.NoError(t, err)
+				require.Len(t, server.emails(), 1)
+			},
+		},
+		{
+			name: "WrongPassword",
+			buildStubs: func(server *smtpServer, config *SMTPConfig) {
+				config.Password = "wrong"
+			},
+			message: message,
+			checkResponse: func(t *testing.T, server *smtpServer, err error) {
+				var smtpErr *textproto.Error
+				require.ErrorAs(t, err, &smtpErr)
+				require.Equal(t, 535, smtpErr.Code)
+				require.Empty(t, server.emails())
+			},
+		},
+		{
+			name:    "RejectedRecipient",
+			message: Message{To: "mallory@example.com", Subject: message.Subject, Text: message.Text},
+			checkResponse: func(t *testing.T, server *smtpServer, err error) {
+				var smtpErr *textproto.Error
+				require.ErrorAs(t, err, &smtpErr)
+				require.Equal(t, 550, smtpErr.Code)
+				require.Empty(t, server.emails())
+			},
+		},
+		{
+			name:    "InvalidAddress",
+			message: Message{To: "alice", Subject: message.Subject, Text: message.Text},
+			checkResponse: func(t *testing.T, server *smtpServer, err error) {
+				require.ErrorIs(t, err, ErrInvalidAddress)
+				require.Empty(t, server.emails())
+			},
+		},
+		{
+			name: "Timeout",
+			buildStubs: func(server *smtpServer, config *SMTPConfig) {
+				server.stall = true
+			},
+			message: message,
+			checkResponse: func(t *testing.T, server *smtpServer, err error) {
+				require.ErrorIs(t, err, context.DeadlineExceeded)
+				require.Empty(t, server.emails())
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			server := newSMTPServer(t, "chat", "secret")
+			config := SMTPConfig{
+				Address:  server.address(),
+				Username: "chat",
+				Password: "secret",
+				From:     "Chat Hub <no-reply@chat.example.com>",
+			}
+			if tc.buildStubs != nil {
+				tc.buildStubs(server, &config)
+			}
+			server.start(t)
+
+			mailer, err := NewSMTPMailer(config)
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			tc.checkResponse(t, server, mailer.Send(ctx, tc.message))
+		})
+	}
+}
+
+// TestNewSMTPMailer tests validating the configurations of the SMTP mailer
+func TestNewSMTPMailer(t *testing.T) {
+	_, err := NewSMTPMailer(SMTPConfig{Address: "localhost", From: "no-reply@chat.example.com"})
+	require.Error(t, err)
+
+	_, err = NewSMTPMailer(SMTPConfig{Address: "localhost:25", From: "no-reply"})
+	require.ErrorIs(t, err, ErrInvalidAddress)
+
+	_, err = NewSMTPMailer(SMTPConfig{Address: "localhost:25", From: "no-reply@chat.example.com"})
+	require.NoError(t, err)
+}
@@ -3,11 +3,19 @@ package main
 import (
 	"Chat-Server/api"
 	"Chat-Server/config"
+	"Chat-Server/mail"
+	"Chat-Server/repository"
+	"Chat-Server/repository/db/memory"
 	"Chat-Server/repository/db/postgres"
+	"Chat-Server/repository/db/sqlite"
+	"Chat-Server/storage"
 	"Chat-Server/token"
+	"context"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
@@ -19,8 +27,31 @@ func main() {
 		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 	}
 
+	// the context is canceled on interrupt and termination signals to stop connecting to the
+	// database and to shut the server down gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// connect to repository
-	repository := postgres.GetPostgresRepository(configs.DatabaseAddress())
+	repository := newRepository(ctx, configs)
+
+	// run the migrate subcommand instead of the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := runMigrate(repository, os.Args[2:])
+		repository.Close()
+		if err != nil {
+			log.Fatal().Err(err).Msg("cannot migrate the database")
+		}
+		return
+	}
+
+	// refuse to run against a schema this server is not built for
+	if err := verifySchema(repository); err != nil {
+		log.Fatal().Err(err).Msg("database schema does not match this server, run the migrate subcommand")
+	}
+
+	// bound the duration of every repository operation
+	repository = withTimeouts(configs, repository)
 
 	// get a new paseto token maker
 	tokenMaker, err := token.NewPasetoMaker(configs.TokenSymmetricKey())
@@ -29,11 +60,105 @@ func main() {
 	}
 
 	// get a new server instance
-	server := api.NewServer(repository, tokenMaker, configs)
+	server := api.NewServer(repository, newBlobStore(configs), newMailer(configs), tokenMaker, configs)
 
 	// start server
-	err = server.Start(configs.ServerAddress())
+	err = server.Start(ctx, configs.ServerAddress())
 	if err != nil {
-		log.Fatal().Err(err).Msg("cannot start server")
+		log.Error().Err(err).Msg("server did not shut down gracefully")
+	}
+
+	// close the database after the server is stopped
+	if err := repository.Close(); err != nil {
+		log.Error().Err(err).Msg("cannot close the database")
+	}
+}
+
+// newRepository returns the repository backend selected in the configurations
+func newRepository(ctx context.Context, configs *config.Config) repository.Repository {
+	switch configs.DatabaseDriver() {
+	case "memory":
+		log.Warn().Msg("using the in-memory repository, data is lost when the server stops")
+		return memory.NewMemoryRepository()
+	case "sqlite":
+		sqliteRepository, err := sqlite.NewSQLiteRepository(configs.DatabaseAddress())
+		if err != nil {
+			log.Fatal().Err(err).Msg("cannot open sqlite database")
+		}
+		return sqliteRepository
+	default:
+		postgresRepository, err := postgres.NewPostgresRepository(ctx, postgres.Config{
+			Address:         configs.DatabaseAddress(),
+			MaxOpenConns:    configs.DatabaseMaxOpenConns(),
+			MaxIdleConns:    configs.DatabaseMaxIdleConns(),
+			ConnMaxLifetime: configs.DatabaseConnMaxLifetime(),
+			ConnMaxIdleTime: configs.DatabaseConnMaxIdleTime(),
+			ConnectRetries:  configs.DatabaseConnectRetries(),
+			ConnectBackoff:  configs.DatabaseConnectBackoff(),
+
+			ReplicaAddresses:     configs.DatabaseReplicaAddresses(),
+			ReadYourWritesWindow: configs.DatabaseReadYourWritesWindow(),
+		})
+		if err != nil {
+			log.Fatal().Err(err).Msg("cannot connect to postgres database")
+		}
+		return postgresRepository
 	}
 }
+
+// newBlobStore returns the blob store of the attachments selected in the configurations
+func newBlobStore(configs *config.Config) storage.BlobStore {
+	if configs.BlobStore() == "s3" {
+		s3Store, err := storage.NewS3Store(storage.S3Config{
+			Endpoint:        configs.BlobStoreS3Endpoint(),
+			Region:          configs.BlobStoreS3Region(),
+			Bucket:          configs.BlobStoreS3Bucket(),
+			AccessKeyID:     configs.BlobStoreS3AccessKeyID(),
+			SecretAccessKey: configs.BlobStoreS3SecretAccessKey(),
+		})
+		if err != nil {
+			log.Fatal().Err(err).Msg("cannot create the s3 blob store")
+		}
+		return s3Store
+	}
+
+	fileStore, err := storage.NewFileStore(configs.BlobStorePath())
+	if err != nil {
+		log.Fatal().Err(err).Msg("cannot create the file blob store")
+	}
+	return fileStore
+}
+
+// newMailer returns the mailer of the emails to the users selected in the configurations
+func newMailer(configs *config.Config) mail.Mailer {
+	switch configs.Mailer() {
+	case "smtp":
+		smtpMailer, err := mail.NewSMTPMailer(mail.SMTPConfig{
+			Address:  configs.SMTPAddress(),
+			Username: configs.SMTPUsername(),
+			Password: configs.SMTPPassword(),
+			From:     configs.MailFrom(),
+		})
+		if err != nil {
+			log.Fatal().Err(err).Msg("cannot create the smtp mailer")
+		}
+		return smtpMailer
+	case "file":
+		fileMailer, err := mail.NewFileMailer(configs.MailDir(), configs.MailFrom())
+		if err != nil {
+			log.Fatal().Err(err).Msg("cannot create the file mailer")
+		}
+		return fileMailer
+	default:
+		log.Warn().Msg("using the log mailer, the emails to the users are written to the log")
+		return mail.NewLogMailer(os.Stderr)
+	}
+}
+
+// withTimeouts returns the input repository bounded by the database timeouts of the configurations
+func withTimeouts(configs *config.Config, r repository.Repository) repository.Repository {
+	return repository.WithTimeouts(r, repository.Timeouts{
+		Read:  configs.DatabaseReadTimeout(),
+		Write: configs.DatabaseWriteTimeout(),
+	})
+}
@@ -0,0 +1,396 @@
+// Package markup renders the text of the messages. a small Markdown subset and @mentions are rendered
+// into html, every other character is escaped, so the rendered html only ever contains the tags and
+// attributes generated here and is safe to insert into a page as is
+package markup
+
+import (
+	"strings"
+	"unicode"
+)
+
+// entity types
+const (
+	EntityMention = "mention" // an @username mention, the value is the username
+	EntityLink    = "link"    // a link, the value is its url
+	EntityCode    = "code"    // an inline code span or a code block, the value is the code
+)
+
+// maxDepth is the maximum nesting depth of the emphasis, deeper delimiters are kept as text
+const maxDepth = 8
+
+// Entity is a structured element of a text. the offset and the length are in unicode code points of the
+// text and cover the entity with its markup, e.g. the backticks of a code span
+type Entity struct {
+	Type   string
+	Offset int
+	Length int
+	Value  string
+}
+
+// Result is the rendered form of a text
+type Result struct {
+	HTML     string
+	Entities []Entity
+}
+
+// Render renders the input text. supported are **bold**, *italic* and _italic_, ~~strikethrough~~,
+// `inline code`, ```code blocks```, [text](url) links, bare http(s) links, @mentions and line breaks
+func Render(text string) Result {
+	r := &renderer{text: []rune(text)}
+	r.block()
+
+	return Result{HTML: r.html.String(), Entities: r.entities}
+}
+
+// Mentions returns the distinct usernames mentioned in the input entities, in the order they appear
+func Mentions(entities []Entity) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+	for _, entity := range entities {
+		if entity.Type == EntityMention && !seen[strings.ToLower(entity.Value)] {
+			seen[strings.ToLower(entity.Value)] = true
+			usernames = append(usernames, entity.Value)
+		}
+	}
+
+	return usernames
+}
+
+// renderer renders a text into html
+type renderer struct {
+	text     []rune
+	html     strings.Builder
+	entities []Entity
+}
+
+// block renders the text, code blocks are rendered as they are and the text between them is rendered inline
+func (r *renderer) block() {
+	start := 0
+	for i := 0; i < len(r.text); i++ {
+		if !r.hasPrefix(i, "```") {
+			continue
+		}
+
+		end := r.index(i+3, len(r.text), "```")
+		if end < 0 {
+			break
+		}
+
+		r.inline(start, i, 0, false)
+
+		// the rest of the opening line is the language of the code, which is not rendered
+		code := i + 3
+		if newline := r.indexRune(code, end, '\n'); newline >= 0 && isWord(r.text[code:newline]) {
+			code = newline + 1
+		}
+		content := strings.TrimPrefix(string(r.text[code:end]), "\n")
+
+		r.html.WriteString("<pre><code>")
+		r.html.WriteString(escape(content))
+		r.html.WriteString("</code></pre>")
+		r.entities = append(r.entities, Entity{Type: EntityCode, Offset: i, Length: end + 3 - i, Value: content})
+
+		i = end + 2
+		start = end + 3
+	}
+
+	r.inline(start, len(r.text), 0, false)
+}
+
+// inline renders the text between the input indices. inLink is set inside the text of links, which cannot
+// contain other links
+func (r *renderer) inline(start, end, depth int, inLink bool) {
+	for i := start; i < end; {
+		if next, ok := r.special(i, end, depth, inLink); ok {
+			i = next
+			continue
+		}
+
+		if r.text[i] == '\n' {
+			r.html.WriteString("<br>")
+		} else {
+			r.html.WriteString(escape(string(r.text[i])))
+		}
+		i++
+	}
+}
+
+// special renders the markup starting at the input index if there is one, and returns the index after it
+func (r *renderer) special(i, end, depth int, inLink bool) (int, bool) {
+	switch r.text[i] {
+	case '`':
+		closing := r.indexRune(i+1, end, '`')
+		if closing < 0 || closing == i+1 || r.indexRune(i+1, closing, '\n') >= 0 {
+			return 0, false
+		}
+		code := string(r.text[i+1 : closing])
+		r.html.WriteString("<code>")
+		r.html.WriteString(escape(code))
+		r.html.WriteString("</code>")
+		r.entities = append(r.entities, Entity{Type: EntityCode, Offset: i, Length: closing + 1 - i, Value: code})
+		return closing + 1, true
+
+	case '*', '_', '~':
+		return r.emphasis(i, end, depth, inLink)
+
+	case '@':
+		return r.mention(i, end)
+
+	case '[':
+		if inLink {
+			return 0, false
+		}
+		return r.link(i, end, depth)
+
+	case 'h', 'H':
+		if inLink {
+			return 0, false
+		}
+		return r.autolink(i, end)
+	}
+
+	return 0, false
+}
+
+// emphasis delimiters and their tags, longer delimiters first
+var emphases = []struct {
+	delimiter string
+	tag       string
+}{
+	{delimiter: "**", tag: "strong"},
+	{delimiter: "~~", tag: "del"},
+	{delimiter: "*", tag: "em"},
+	{delimiter: "_", tag: "em"},
+}
+
+// emphasis renders the emphasis starting at the input index. the emphasized text cannot start or end with
+// a space, and underscores only emphasize whole words so snake_case words are kept as they are
+func (r *renderer) emphasis(i, end, depth int, inLink bool) (int, bool) {
+	if depth >= maxDepth {
+		return 0, false
+	}
+
+	for _, emphasis := range emphases {
+		if !r.hasPrefix(i, emphasis.delimiter) {
+			continue
+		}
+		size := len(emphasis.delimiter)
+		if emphasis.delimiter == "_" && i > 0 && isWordRune(r.text[i-1]) {
+			return 0, false
+		}
+
+		contentStart := i + size
+		if contentStart >= end || unicode.IsSpace(r.text[contentStart]) || r.text[contentStart] == '~' && emphasis.delimiter == "~~" {
+			return 0, false
+		}
+
+		for closing := r.index(contentStart+1, end, emphasis.delimiter); closing >= 0; closing = r.index(closing+1, end, emphasis.delimiter) {
+			if unicode.IsSpace(r.text[closing-1]) {
+				continue
+			}
+			if emphasis.delimiter == "_" && closing+1 < len(r.text) && isWordRune(r.text[closing+1]) {
+				continue
+			}
+			// a single asterisk is not closed by a double one, which is bold nested in the italic text
+			if emphasis.delimiter == "*" && (r.hasPrefix(closing, "**") || r.text[closing-1] == '*') {
+				continue
+			}
+
+			r.html.WriteString("<" + emphasis.tag + ">")
+			r.inline(contentStart, closing, depth+1, inLink)
+			r.html.WriteString("</" + emphasis.tag + ">")
+			return closing + size, true
+		}
+
+		return 0, false
+	}
+
+	return 0, false
+}
+
+// mention renders the mention starting at the input index. mentions are usernames, which start with a
+// letter and contain letters, digits and underscores, preceded by @ at the start of a word
+func (r *renderer) mention(i, end int) (int, bool) {
+	if i > 0 && (isWordRune(r.text[i-1]) || r.text[i-1] == '@') {
+		return 0, false
+	}
+
+	j := i + 1
+	if j >= end || !isASCIILetter(r.text[j]) {
+		return 0, false
+	}
+	for j < end && (isASCIILetter(r.text[j]) || isASCIIDigit(r.text[j]) || r.text[j] == '_') {
+		j++
+	}
+
+	username := string(r.text[i+1 : j])
+	if len(username) < 4 || len(username) > 64 || (j < end && isWordRune(r.text[j])) {
+		return 0, false
+	}
+
+	r.html.WriteString(`<span class="mention" data-username="` + escape(username) + `">@` + escape(username) + `</span>`)
+	r.entities = append(r.entities, Entity{Type: EntityMention, Offset: i, Length: j - i, Value: username})
+	return j, true
+}
+
+// link renders the [text](url) link starting at the input index
+func (r *renderer) link(i, end, depth int) (int, bool) {
+	textEnd := r.indexRune(i+1, end, ']')
+	if textEnd < 0 || textEnd == i+1 || textEnd+1 >= end || r.text[textEnd+1] != '(' {
+		return 0, false
+	}
+	urlEnd := r.indexRune(textEnd+2, end, ')')
+	if urlEnd < 0 {
+		return 0, false
+	}
+
+	url := strings.TrimSpace(string(r.text[textEnd+2 : urlEnd]))
+	if !isSafeURL(url) {
+		return 0, false
+	}
+
+	r.openLink(url)
+	r.inline(i+1, textEnd, depth+1, true)
+	r.html.WriteString("</a>")
+	r.entities = append(r.entities, Entity{Type: EntityLink, Offset: i, Length: urlEnd + 1 - i, Value: url})
+	return urlEnd + 1, true
+}
+
+// trailingPunctuation is not part of the bare links it ends, it usually ends the sentence around them
+const trailingPunctuation = ".,:;!?)]}'\"*_~"
+
+// autolink renders the bare http or https link starting at the input index
+func (r *renderer) autolink(i, end int) (int, bool) {
+	if i > 0 && isWordRune(r.text[i-1]) {
+		return 0, false
+	}
+
+	prefix := ""
+	for _, scheme := range []string{"http://", "https://"} {
+		if r.hasFoldPrefix(i, scheme) {
+			prefix = scheme
+		}
+	}
+	if prefix == "" {
+		return 0, false
+	}
+
+	j := i + len(prefix)
+	for j < end && !unicode.IsSpace(r.text[j]) && !strings.ContainsRune(`<>"'`+"`", r.text[j]) {
+		j++
+	}
+	for j > i+len(prefix) && strings.ContainsRune(trailingPunctuation, r.text[j-1]) {
+		// a closing parenthesis ends the link only if the link opens one, like wikipedia links do
+		if r.text[j-1] == ')' && strings.Count(string(r.text[i:j]), "(") >= strings.Count(string(r.text[i:j]), ")") {
+			break
+		}
+		j--
+	}
+	if j == i+len(prefix) {
+		return 0, false
+	}
+
+	url := string(r.text[i:j])
+	r.openLink(url)
+	r.html.WriteString(escape(url))
+	r.html.WriteString("</a>")
+	r.entities = append(r.entities, Entity{Type: EntityLink, Offset: i, Length: j - i, Value: url})
+	return j, true
+}
+
+// openLink writes the opening tag of a link to the input url, links open in a new tab without access to the chat
+func (r *renderer) openLink(url string) {
+	r.html.WriteString(`<a href="` + escape(url) + `" target="_blank" rel="noopener noreferrer nofollow">`)
+}
+
+// isSafeURL reports whether the input url is an absolute http, https or mailto url, other schemes such as
+// javascript and data urls are not linked
+func isSafeURL(url string) bool {
+	lower := strings.ToLower(url)
+	for _, scheme := range []string{"http://", "https://", "mailto:"} {
+		if strings.HasPrefix(lower, scheme) && len(url) > len(scheme) && !strings.ContainsAny(url, " \t\n") {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPrefix reports whether the text at the input index starts with the input prefix
+func (r *renderer) hasPrefix(i int, prefix string) bool {
+	if i < 0 {
+		return false
+	}
+	for _, c := range prefix {
+		if i >= len(r.text) || r.text[i] != c {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// hasFoldPrefix reports whether the text at the input index starts with the input ascii prefix, ignoring case
+func (r *renderer) hasFoldPrefix(i int, prefix string) bool {
+	return i+len(prefix) <= len(r.text) && strings.EqualFold(string(r.text[i:i+len(prefix)]), prefix)
+}
+
+// index returns the index of the first occurrence of the input string in the text between the input
+// indices, or -1
+func (r *renderer) index(start, end int, s string) int {
+	for i := start; i+len(s) <= end; i++ {
+		if r.hasPrefix(i, s) {
+			return i
+		}
+	}
+	return -1
+}
+
+// indexRune returns the index of the first occurrence of the input rune in the text between the input
+// indices, or -1
+func (r *renderer) indexRune(start, end int, c rune) int {
+	for i := start; i < end; i++ {
+		if r.text[i] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// escape escapes the html special characters of the input text
+func escape(text string) string {
+	return htmlEscaper.Replace(text)
+}
+
+// htmlEscaper escapes the characters which are special in html text and in quoted attribute values
+var htmlEscaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&#34;",
+	"'", "&#39;",
+)
+
+// isWord reports whether the input runes are a single word, like the language of a code block
+func isWord(runes []rune) bool {
+	for _, c := range runes {
+		if !isWordRune(c) && c != '-' && c != '+' && c != '#' {
+			return false
+		}
+	}
+	return true
+}
+
+// isWordRune reports whether the input rune is a letter, a digit or an underscore
+func isWordRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_'
+}
+
+// isASCIILetter reports whether the input rune is an ascii letter
+func isASCIILetter(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isASCIIDigit reports whether the input rune is an ascii digit
+func isASCIIDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
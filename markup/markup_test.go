@@ -0,0 +1,64 @@
+package markup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// linkAttributes are the attributes of the rendered links after their url
+const linkAttributes = `" target="_blank" rel="noopener noreferrer nofollow">`
+
+// TestRender tests rendering texts into html
+func TestRender(t *testing.T) {
+	testCases := []struct {
+		name string
+		text string
+		html string
+	}{
+		{name: "Plain", text: "hello world", html: "hello world"},
+		{name: "Escaped", text: `<script>alert("x")</script> & 'y'`, html: "&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; &amp; &#39;y&#39;"},
+		{name: "Bold", text: "a **bold** move", html: "a <strong>bold</strong> move"},
+		{name: "Italic", text: "*one* and _two_", html: "<em>one</em> and <em>two</em>"},
+		{name: "Strikethrough", text: "~~gone~~", html: "<del>gone</del>"},
+		{name: "Nested", text: "*very **bold** text*", html: "<em>very <strong>bold</strong> text</em>"},
+		{name: "SnakeCase", text: "snake_case_name and 2*3*4", html: "snake_case_name and 2<em>3</em>4"},
+		{name: "Spaces", text: "a * b * c and ** d**", html: "a * b * c and ** d**"},
+		{name: "Tildes", text: "~~~~~~ and ~~~x~~", html: "~~~~~~ and ~<del>x</del>"},
+		{name: "Unclosed", text: "**open and `code", html: "**open and `code"},
+		{name: "InlineCode", text: "run `go test <pkg>` now", html: "run <code>go test &lt;pkg&gt;</code> now"},
+		{name: "CodeIsLiteral", text: "`**not bold** @someone`", html: "<code>**not bold** @someone</code>"},
+		{name: "CodeBlock", text: "see\n```go\nfmt.Println(\"<hi>\")\n```\nok", html: "see<br><pre><code>fmt.Println(&#34;&lt;hi&gt;&#34;)\n</code></pre><br>ok"},
+		{name: "LineBreaks", text: "one\ntwo", html: "one<br>two"},
+		{name: "Link", text: "[the *docs*](https://example.com/a?b=1&c=2)", html: `<a href="https://example.com/a?b=1&amp;c=2` + linkAttributes + "the <em>docs</em></a>"},
+		{name: "UnsafeLink", text: "[click](javascript:alert(1))", html: "[click](javascript:alert(1))"},
+		{name: "DataLink", text: "[img](data:text/html,<b>)", html: "[img](data:text/html,&lt;b&gt;)"},
+		{name: "Autolink", text: "see https://example.com/x.", html: `see <a href="https://example.com/x` + linkAttributes + "https://example.com/x</a>."},
+		{name: "AutolinkQuote", text: `http://example.com/"onmouseover="x`, html: `<a href="http://example.com/` + linkAttributes + `http://example.com/</a>&#34;onmouseover=&#34;x`},
+		{name: "Mention", text: "hi @alice_1!", html: `hi <span class="mention" data-username="alice_1">@alice_1</span>!`},
+		{name: "NotMentions", text: "mail bob@example.com, @ab, @1user", html: "mail bob@example.com, @ab, @1user"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.html, Render(tc.text).HTML)
+		})
+	}
+}
+
+// TestRender_Entities tests the entities of rendered texts
+func TestRender_Entities(t *testing.T) {
+	result := Render("héllo @alice, see [docs](https://example.com) and `x := 1`\n```\ncode\n``` https://go.dev @alice")
+
+	require.Equal(t, []Entity{
+		{Type: EntityMention, Offset: 6, Length: 6, Value: "alice"},
+		{Type: EntityLink, Offset: 18, Length: 27, Value: "https://example.com"},
+		{Type: EntityCode, Offset: 50, Length: 8, Value: "x := 1"},
+		{Type: EntityCode, Offset: 59, Length: 12, Value: "code\n"},
+		{Type: EntityLink, Offset: 72, Length: 14, Value: "https://go.dev"},
+		{Type: EntityMention, Offset: 87, Length: 6, Value: "alice"},
+	}, result.Entities)
+
+	require.Equal(t, []string{"alice"}, Mentions(result.Entities))
+	require.Empty(t, Render("plain text").Entities)
+}
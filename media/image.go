@@ -0,0 +1,204 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+// maxPixels is the maximum number of pixels of the images thumbnails are generated for, larger images
+// are rejected before they are decoded so a small image bomb cannot exhaust the memory
+const maxPixels = 50_000_000
+
+// IsImage reports whether the input content type is an image type whose metadata is stripped and
+// whose dimensions are read
+func IsImage(contentType string) bool {
+	switch contentType {
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+		return true
+	default:
+		return false
+	}
+}
+
+// CanThumbnail reports whether thumbnails are generated for images of the input content type
+func CanThumbnail(contentType string) bool {
+	switch contentType {
+	case "image/png", "image/jpeg", "image/gif":
+		return true
+	default:
+		return false
+	}
+}
+
+// Dimensions returns the width and the height of the input image of the input content type without
+// decoding its pixels
+func Dimensions(data []byte, contentType string) (int, int, error) {
+	switch contentType {
+	case "image/png", "image/jpeg", "image/gif":
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return 0, 0, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+		}
+		return config.Width, config.Height, nil
+	case "image/webp":
+		return webpDimensions(data)
+	default:
+		return 0, 0, fmt.Errorf("%w: unsupported content type %s", ErrInvalidImage, contentType)
+	}
+}
+
+// webpDimensions reads the dimensions of a webp image from the header of its first chunk
+func webpDimensions(data []byte) (int, int, error) {
+	if len(data) < 30 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return 0, 0, fmt.Errorf("%w: missing webp header", ErrInvalidImage)
+	}
+
+	chunk := data[20:]
+	switch string(data[12:16]) {
+	case "VP8X":
+		// 24 bit canvas width and height minus one
+		width := int(chunk[4]) | int(chunk[5])<<8 | int(chunk[6])<<16
+		height := int(chunk[7]) | int(chunk[8])<<8 | int(chunk[9])<<16
+		return width + 1, height + 1, nil
+	case "VP8 ":
+		// frame tag, start code and 14 bit width and height
+		if chunk[3] != 0x9d || chunk[4] != 0x01 || chunk[5] != 0x2a {
+			return 0, 0, fmt.Errorf("%w: missing vp8 start code", ErrInvalidImage)
+		}
+		width := int(binary.LittleEndian.Uint16(chunk[6:]) & 0x3fff)
+		height := int(binary.LittleEndian.Uint16(chunk[8:]) & 0x3fff)
+		return width, height, nil
+	case "VP8L":
+		// signature and 14 bit width and height minus one
+		if chunk[0] != 0x2f {
+			return 0, 0, fmt.Errorf("%w: missing vp8l signature", ErrInvalidImage)
+		}
+		bits := binary.LittleEndian.Uint32(chunk[1:])
+		return int(bits&0x3fff) + 1, int(bits>>14&0x3fff) + 1, nil
+	default:
+		return 0, 0, fmt.Errorf("%w: unknown webp chunk %q", ErrInvalidImage, data[12:16])
+	}
+}
+
+// Thumbnail is a generated thumbnail of an image
+type Thumbnail struct {
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+}
+
+// MakeThumbnail decodes the input image of the input content type and returns a thumbnail which fits
+// in a square of the input size, keeping the aspect ratio. images smaller than the square are not
+// enlarged. thumbnails of jpeg images are jpeg, thumbnails of other images are png so their
+// transparency is kept
+func MakeThumbnail(data []byte, contentType string, size int) (*Thumbnail, error) {
+	if !CanThumbnail(contentType) {
+		return nil, fmt.Errorf("%w: unsupported content type %s", ErrInvalidImage, contentType)
+	}
+
+	width, height, err := Dimensions(data, contentType)
+	if err != nil {
+		return nil, err
+	}
+	if width < 1 || height < 1 || width*height > maxPixels {
+		return nil, fmt.Errorf("%w: unsupported dimensions %dx%d", ErrInvalidImage, width, height)
+	}
+
+	var source image.Image
+	switch contentType {
+	case "image/jpeg":
+		source, err = jpeg.Decode(bytes.NewReader(data))
+	case "image/png":
+		source, err = png.Decode(bytes.NewReader(data))
+	case "image/gif":
+		// thumbnails of animated gifs are their first frame
+		source, err = gif.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	thumbnailWidth, thumbnailHeight := fit(width, height, size)
+	scaled := scale(source, thumbnailWidth, thumbnailHeight)
+
+	var encoded bytes.Buffer
+	thumbnail := &Thumbnail{Width: thumbnailWidth, Height: thumbnailHeight}
+	if contentType == "image/jpeg" {
+		thumbnail.ContentType = "image/jpeg"
+		err = jpeg.Encode(&encoded, scaled, &jpeg.Options{Quality: 80})
+	} else {
+		thumbnail.ContentType = "image/png"
+		err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&encoded, scaled)
+	}
+	if err != nil {
+		return nil, err
+	}
+	thumbnail.Data = encoded.Bytes()
+
+	return thumbnail, nil
+}
+
+// fit returns the dimensions of an image of the input dimensions scaled down to fit in a square of
+// the input size
+func fit(width, height, size int) (int, int) {
+	if width <= size && height <= size {
+		return width, height
+	}
+
+	if width >= height {
+		return size, max(1, height*size/width)
+	}
+	return max(1, width*size/height), size
+}
+
+// maxSamples is the maximum number of source pixels averaged on each axis for a thumbnail pixel, which
+// bounds the time spent on very large images
+const maxSamples = 4
+
+// scale returns the input image scaled to the input dimensions. every pixel of the result is the
+// average of the source pixels it covers, sampled on a grid of at most maxSamples per axis
+func scale(source image.Image, width, height int) image.Image {
+	bounds := source.Bounds()
+	scaled := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		top := bounds.Min.Y + y*bounds.Dy()/height
+		bottom := max(top+1, bounds.Min.Y+(y+1)*bounds.Dy()/height)
+		stepY := max(1, (bottom-top)/maxSamples)
+
+		for x := 0; x < width; x++ {
+			left := bounds.Min.X + x*bounds.Dx()/width
+			right := max(left+1, bounds.Min.X+(x+1)*bounds.Dx()/width)
+			stepX := max(1, (right-left)/maxSamples)
+
+			var r, g, b, a, n uint64
+			for sy := top; sy < bottom; sy += stepY {
+				for sx := left; sx < right; sx += stepX {
+					// premultiplied 16 bit components
+					pr, pg, pb, pa := source.At(sx, sy).RGBA()
+					r += uint64(pr)
+					g += uint64(pg)
+					b += uint64(pb)
+					a += uint64(pa)
+					n++
+				}
+			}
+
+			scaled.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+
+	return scaled
+}
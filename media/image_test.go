@@ -0,0 +1,102 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestDimensions tests Dimensions
+func TestDimensions(t *testing.T) {
+	testCases := []struct {
+		name          string
+		data          []byte
+		contentType   string
+		width, height int
+	}{
+		{name: "JPEG", data: encodeJPEG(t, 40, 30), contentType: "image/jpeg", width: 40, height: 30},
+		{name: "PNG", data: encodePNG(t, 30, 40), contentType: "image/png", width: 30, height: 40},
+		{name: "GIF", data: encodeGIF(t, 25, 25), contentType: "image/gif", width: 25, height: 25},
+		{name: "WebPExtended", data: webpFile(vp8xChunk(0, 1920, 1080), vp8lChunk(1920, 1080)), contentType: "image/webp", width: 1920, height: 1080},
+		{name: "WebPLossless", data: webpFile(vp8lChunk(640, 480), webpChunk("PAD ", "0123456789")), contentType: "image/webp", width: 640, height: 480},
+		{name: "WebPLossy", data: webpFile(webpChunk("VP8 ", "\x00\x00\x00\x9d\x01\x2a\x20\x03\x58\x02\x00\x00")), contentType: "image/webp", width: 800, height: 600},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			width, height, err := Dimensions(tc.data, tc.contentType)
+			require.NoError(t, err)
+			require.Equal(t, tc.width, width)
+			require.Equal(t, tc.height, height)
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, _, err := Dimensions([]byte("not an image"), "image/png")
+		require.ErrorIs(t, err, ErrInvalidImage)
+
+		_, _, err = Dimensions([]byte("not an image"), "image/webp")
+		require.ErrorIs(t, err, ErrInvalidImage)
+
+		_, _, err = Dimensions([]byte("text"), "text/plain")
+		require.ErrorIs(t, err, ErrInvalidImage)
+	})
+}
+
+// TestMakeThumbnail tests MakeThumbnail
+func TestMakeThumbnail(t *testing.T) {
+	testCases := []struct {
+		name          string
+		data          []byte
+		contentType   string
+		thumbnailType string
+		width, height int
+	}{
+		{name: "Landscape", data: encodeJPEG(t, 800, 400), contentType: "image/jpeg", thumbnailType: "image/jpeg", width: 320, height: 160},
+		{name: "Portrait", data: encodePNG(t, 300, 900), contentType: "image/png", thumbnailType: "image/png", width: 106, height: 320},
+		{name: "GIF", data: encodeGIF(t, 640, 640), contentType: "image/gif", thumbnailType: "image/png", width: 320, height: 320},
+		{name: "Small", data: encodePNG(t, 100, 50), contentType: "image/png", thumbnailType: "image/png", width: 100, height: 50},
+		{name: "Thin", data: encodePNG(t, 2000, 2), contentType: "image/png", thumbnailType: "image/png", width: 320, height: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			thumbnail, err := MakeThumbnail(tc.data, tc.contentType, 320)
+			require.NoError(t, err)
+			require.Equal(t, tc.thumbnailType, thumbnail.ContentType)
+			require.Equal(t, tc.width, thumbnail.Width)
+			require.Equal(t, tc.height, thumbnail.Height)
+
+			decoded, format, err := image.Decode(bytes.NewReader(thumbnail.Data))
+			require.NoError(t, err)
+			require.Equal(t, "image/"+format, tc.thumbnailType)
+			require.Equal(t, image.Rect(0, 0, tc.width, tc.height), decoded.Bounds())
+		})
+	}
+
+	t.Run("Colors", func(t *testing.T) {
+		thumbnail, err := MakeThumbnail(encodePNG(t, 64, 64), "image/png", 8)
+		require.NoError(t, err)
+
+		decoded, _, err := image.Decode(bytes.NewReader(thumbnail.Data))
+		require.NoError(t, err)
+
+		// the gradient of the test image is kept: red grows from left to right, green from top to bottom
+		left, _, _, _ := decoded.At(0, 4).RGBA()
+		right, _, _, _ := decoded.At(7, 4).RGBA()
+		require.Less(t, left, right)
+		_, top, _, _ := decoded.At(4, 0).RGBA()
+		_, bottom, _, _ := decoded.At(4, 7).RGBA()
+		require.Less(t, top, bottom)
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		_, err := MakeThumbnail(webpFile(vp8lChunk(640, 480)), "image/webp", 320)
+		require.ErrorIs(t, err, ErrInvalidImage)
+
+		_, err = MakeThumbnail([]byte("not an image"), "image/png", 320)
+		require.ErrorIs(t, err, ErrInvalidImage)
+	})
+}
@@ -0,0 +1,321 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidImage is returned when the content of an image does not match its format
+var ErrInvalidImage = errors.New("invalid image")
+
+// StripMetadata returns the input image of the input content type without the metadata which may
+// identify its author or where it was taken, such as EXIF, XMP, IPTC and text comments. the image
+// data is copied untouched, so the image is not re-encoded and keeps its quality. content types
+// other than png, jpeg, gif and webp are returned as is
+func StripMetadata(data []byte, contentType string) ([]byte, error) {
+	switch contentType {
+	case "image/jpeg":
+		return stripJPEG(data)
+	case "image/png":
+		return stripPNG(data)
+	case "image/gif":
+		return stripGIF(data)
+	case "image/webp":
+		return stripWebP(data)
+	default:
+		return data, nil
+	}
+}
+
+// jpeg markers
+const (
+	markerSOI  = 0xd8 // start of image
+	markerEOI  = 0xd9 // end of image
+	markerSOS  = 0xda // start of scan, the compressed image data follows
+	markerAPP0 = 0xe0 // JFIF header
+	markerAPP2 = 0xe2 // ICC profile
+	markerAPPE = 0xee // Adobe header, describes the color transform
+	markerCOM  = 0xfe // comment
+)
+
+// stripJPEG drops the application segments other than the JFIF header, the ICC profile and the Adobe
+// header, and the comments, of the segments before the image data
+func stripJPEG(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xff || data[1] != markerSOI {
+		return nil, fmt.Errorf("%w: missing jpeg start of image", ErrInvalidImage)
+	}
+
+	stripped := bytes.NewBuffer(make([]byte, 0, len(data)))
+	stripped.Write(data[:2])
+
+	for i := 2; i < len(data); {
+		if data[i] != 0xff {
+			return nil, fmt.Errorf("%w: jpeg marker expected at %d", ErrInvalidImage, i)
+		}
+
+		// markers may be preceded by fill bytes
+		start := i
+		for i < len(data) && data[i] == 0xff {
+			i++
+		}
+		if i == len(data) {
+			return nil, fmt.Errorf("%w: truncated jpeg", ErrInvalidImage)
+		}
+		marker := data[i]
+		i++
+
+		switch {
+		case marker == markerEOI:
+			stripped.Write(data[start:i])
+			return stripped.Bytes(), nil
+		case marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7):
+			// markers without a segment
+			stripped.Write(data[start:i])
+			continue
+		}
+
+		if i+2 > len(data) {
+			return nil, fmt.Errorf("%w: truncated jpeg", ErrInvalidImage)
+		}
+		end := i + int(binary.BigEndian.Uint16(data[i:]))
+		if end > len(data) || end < i+2 {
+			return nil, fmt.Errorf("%w: truncated jpeg segment", ErrInvalidImage)
+		}
+
+		if marker == markerSOS {
+			// the image data and the segments after it are kept as they are
+			stripped.Write(data[start:])
+			return stripped.Bytes(), nil
+		}
+
+		if keepJPEGSegment(marker, data[i+2:end]) {
+			stripped.Write(data[start:end])
+		}
+		i = end
+	}
+
+	return nil, fmt.Errorf("%w: truncated jpeg", ErrInvalidImage)
+}
+
+// keepJPEGSegment reports whether a jpeg segment of the input marker and payload is kept
+func keepJPEGSegment(marker byte, payload []byte) bool {
+	switch {
+	case marker == markerCOM:
+		return false
+	case marker == markerAPP2:
+		return bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00"))
+	case marker == markerAPP0, marker == markerAPPE:
+		return true
+	case marker > markerAPP0 && marker <= 0xef:
+		return false
+	default:
+		return true
+	}
+}
+
+// pngSignature is the signature at the start of png files
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngMetadataChunks are the png chunks dropped by stripPNG
+var pngMetadataChunks = map[string]bool{
+	"tEXt": true, // text
+	"zTXt": true, // compressed text
+	"iTXt": true, // international text, holds XMP
+	"eXIf": true, // exif
+	"tIME": true, // last modification time
+}
+
+// stripPNG drops the text, exif and modification time chunks
+func stripPNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, fmt.Errorf("%w: missing png signature", ErrInvalidImage)
+	}
+
+	stripped := bytes.NewBuffer(make([]byte, 0, len(data)))
+	stripped.Write(pngSignature)
+
+	for i := len(pngSignature); i < len(data); {
+		// a chunk is its length, its type, its data and its crc
+		if i+8 > len(data) {
+			return nil, fmt.Errorf("%w: truncated png chunk", ErrInvalidImage)
+		}
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		chunkType := string(data[i+4 : i+8])
+		end := i + 12 + length
+		if length < 0 || end > len(data) || end < i {
+			return nil, fmt.Errorf("%w: truncated png chunk", ErrInvalidImage)
+		}
+
+		if !pngMetadataChunks[chunkType] {
+			stripped.Write(data[i:end])
+		}
+		if chunkType == "IEND" {
+			return stripped.Bytes(), nil
+		}
+		i = end
+	}
+
+	return nil, fmt.Errorf("%w: missing png end", ErrInvalidImage)
+}
+
+// gif blocks and extensions
+const (
+	gifExtension       = 0x21
+	gifImageDescriptor = 0x2c
+	gifTrailer         = 0x3b
+
+	gifCommentLabel     = 0xfe
+	gifApplicationLabel = 0xff
+)
+
+// gifAnimationApplications are the application extensions kept by stripGIF, they control animations
+var gifAnimationApplications = [][]byte{[]byte("NETSCAPE2.0"), []byte("ANIMEXTS1.0")}
+
+// stripGIF drops the comment extensions and the application extensions other than the animation ones,
+// which hold XMP
+func stripGIF(data []byte) ([]byte, error) {
+	if len(data) < 13 || (!bytes.HasPrefix(data, []byte("GIF87a")) && !bytes.HasPrefix(data, []byte("GIF89a"))) {
+		return nil, fmt.Errorf("%w: missing gif header", ErrInvalidImage)
+	}
+
+	// the header, the logical screen descriptor and the global color table
+	i := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		i += 3 << ((flags & 0x07) + 1)
+	}
+	if i > len(data) {
+		return nil, fmt.Errorf("%w: truncated gif color table", ErrInvalidImage)
+	}
+
+	stripped := bytes.NewBuffer(make([]byte, 0, len(data)))
+	stripped.Write(data[:i])
+
+	for i < len(data) {
+		start := i
+		switch data[i] {
+		case gifTrailer:
+			stripped.WriteByte(gifTrailer)
+			return stripped.Bytes(), nil
+
+		case gifExtension:
+			if i+2 > len(data) {
+				return nil, fmt.Errorf("%w: truncated gif extension", ErrInvalidImage)
+			}
+			label := data[i+1]
+			end, err := skipGIFSubBlocks(data, i+2)
+			if err != nil {
+				return nil, err
+			}
+			if keepGIFExtension(label, data[i+2:end]) {
+				stripped.Write(data[start:end])
+			}
+			i = end
+
+		case gifImageDescriptor:
+			// the image descriptor, the local color table, the lzw code size and the image data
+			i += 10
+			if i > len(data) {
+				return nil, fmt.Errorf("%w: truncated gif image descriptor", ErrInvalidImage)
+			}
+			if flags := data[i-1]; flags&0x80 != 0 {
+				i += 3 << ((flags & 0x07) + 1)
+			}
+			end, err := skipGIFSubBlocks(data, i+1)
+			if err != nil {
+				return nil, err
+			}
+			stripped.Write(data[start:end])
+			i = end
+
+		default:
+			return nil, fmt.Errorf("%w: unknown gif block %#x", ErrInvalidImage, data[i])
+		}
+	}
+
+	return nil, fmt.Errorf("%w: missing gif trailer", ErrInvalidImage)
+}
+
+// skipGIFSubBlocks returns the index after the sub-blocks starting at the input index
+func skipGIFSubBlocks(data []byte, i int) (int, error) {
+	for {
+		if i >= len(data) {
+			return 0, fmt.Errorf("%w: truncated gif sub-blocks", ErrInvalidImage)
+		}
+		size := int(data[i])
+		i++
+		if size == 0 {
+			return i, nil
+		}
+		i += size
+	}
+}
+
+// keepGIFExtension reports whether a gif extension of the input label and sub-blocks is kept
+func keepGIFExtension(label byte, subBlocks []byte) bool {
+	switch label {
+	case gifCommentLabel:
+		return false
+	case gifApplicationLabel:
+		// the first sub-block is the application identifier
+		if len(subBlocks) < 12 || subBlocks[0] != 11 {
+			return false
+		}
+		for _, application := range gifAnimationApplications {
+			if bytes.Equal(subBlocks[1:12], application) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// webp extended format flags of the metadata chunks
+const (
+	webpFlagEXIF = 0x08
+	webpFlagXMP  = 0x04
+)
+
+// stripWebP drops the EXIF and XMP chunks and their flags in the extended format header
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("%w: missing webp header", ErrInvalidImage)
+	}
+
+	chunks := bytes.NewBuffer(make([]byte, 0, len(data)))
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, fmt.Errorf("%w: truncated webp chunk", ErrInvalidImage)
+		}
+		fourCC := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+
+		// chunks are padded to an even size
+		end := i + 8 + size + size%2
+		if size < 0 || end > len(data) || end < i {
+			return nil, fmt.Errorf("%w: truncated webp chunk", ErrInvalidImage)
+		}
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := bytes.Clone(data[i:end])
+			if size > 0 {
+				chunk[8] &^= webpFlagEXIF | webpFlagXMP
+			}
+			chunks.Write(chunk)
+		default:
+			chunks.Write(data[i:end])
+		}
+		i = end
+	}
+
+	stripped := make([]byte, 12, 12+chunks.Len())
+	copy(stripped, data[:12])
+	binary.LittleEndian.PutUint32(stripped[4:], uint32(4+chunks.Len()))
+
+	return append(stripped, chunks.Bytes()...), nil
+}
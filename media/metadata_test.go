@@ -0,0 +1,224 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// secret is the metadata written into the test images which must not survive StripMetadata
+const secret = "GPS 35.6892 51.3890"
+
+// testImage returns an image of the input dimensions with a gradient
+func testImage(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 255 / width), G: uint8(y * 255 / height), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+// encodeJPEG encodes a test image of the input dimensions as jpeg
+func encodeJPEG(t *testing.T, width, height int) []byte {
+	var buffer bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buffer, testImage(width, height), nil))
+	return buffer.Bytes()
+}
+
+// encodePNG encodes a test image of the input dimensions as png
+func encodePNG(t *testing.T, width, height int) []byte {
+	var buffer bytes.Buffer
+	require.NoError(t, png.Encode(&buffer, testImage(width, height)))
+	return buffer.Bytes()
+}
+
+// encodeGIF encodes a test image of the input dimensions as gif
+func encodeGIF(t *testing.T, width, height int) []byte {
+	var buffer bytes.Buffer
+	require.NoError(t, gif.Encode(&buffer, testImage(width, height), nil))
+	return buffer.Bytes()
+}
+
+// jpegSegment returns a jpeg segment of the input marker and payload
+func jpegSegment(marker byte, payload string) []byte {
+	segment := []byte{0xff, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// pngChunk returns a png chunk of the input type and data
+func pngChunk(chunkType, data string) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, chunkType...)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE([]byte(chunkType+data)))
+}
+
+// webpChunk returns a webp chunk of the input fourCC and data
+func webpChunk(fourCC, data string) []byte {
+	chunk := append([]byte(fourCC), binary.LittleEndian.AppendUint32(nil, uint32(len(data)))...)
+	chunk = append(chunk, data...)
+	if len(data)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+// webpFile returns a webp file of the input chunks
+func webpFile(chunks ...[]byte) []byte {
+	body := []byte("WEBP")
+	for _, chunk := range chunks {
+		body = append(body, chunk...)
+	}
+	return append(append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...), body...)
+}
+
+// vp8xChunk returns a webp extended format header of the input flags and dimensions
+func vp8xChunk(flags byte, width, height int) []byte {
+	data := []byte{flags, 0, 0, 0}
+	data = append(data, byte(width-1), byte((width-1)>>8), byte((width-1)>>16))
+	data = append(data, byte(height-1), byte((height-1)>>8), byte((height-1)>>16))
+	return webpChunk("VP8X", string(data))
+}
+
+// vp8lChunk returns a webp lossless chunk header of the input dimensions, without the image data
+func vp8lChunk(width, height int) []byte {
+	bits := uint32(width-1) | uint32(height-1)<<14
+	return webpChunk("VP8L", string(append([]byte{0x2f}, binary.LittleEndian.AppendUint32(nil, bits)...)))
+}
+
+// TestStripMetadata tests StripMetadata
+func TestStripMetadata(t *testing.T) {
+	t.Run("JPEG", func(t *testing.T) {
+		original := encodeJPEG(t, 16, 8)
+
+		// insert exif, xmp, an icc profile and a comment after the start of image
+		var data []byte
+		data = append(data, original[:2]...)
+		data = append(data, jpegSegment(0xe1, "Exif\x00\x00"+secret)...)
+		data = append(data, jpegSegment(0xe1, "http://ns.adobe.com/xap/1.0/\x00"+secret)...)
+		data = append(data, jpegSegment(0xe2, "ICC_PROFILE\x00\x01\x01profile")...)
+		data = append(data, jpegSegment(0xed, "Photoshop 3.0\x00"+secret)...)
+		data = append(data, jpegSegment(0xfe, secret)...)
+		data = append(data, original[2:]...)
+
+		stripped, err := StripMetadata(data, "image/jpeg")
+		require.NoError(t, err)
+		require.NotContains(t, string(stripped), secret)
+		require.Contains(t, string(stripped), "ICC_PROFILE")
+
+		// the image data is untouched
+		require.Equal(t, len(original)+len(jpegSegment(0xe2, "ICC_PROFILE\x00\x01\x01profile")), len(stripped))
+		expected, err := jpeg.Decode(bytes.NewReader(original))
+		require.NoError(t, err)
+		decoded, err := jpeg.Decode(bytes.NewReader(stripped))
+		require.NoError(t, err)
+		require.Equal(t, expected, decoded)
+	})
+
+	t.Run("PNG", func(t *testing.T) {
+		original := encodePNG(t, 16, 8)
+
+		// insert text, exif and time chunks after the header chunk
+		headerEnd := len(pngSignature) + 25
+		var data []byte
+		data = append(data, original[:headerEnd]...)
+		data = append(data, pngChunk("tEXt", "Comment\x00"+secret)...)
+		data = append(data, pngChunk("iTXt", "XML:com.adobe.xmp\x00\x00\x00\x00\x00"+secret)...)
+		data = append(data, pngChunk("eXIf", "MM\x00*"+secret)...)
+		data = append(data, pngChunk("tIME", "\x07\xea\x0a\x13\x0c\x00\x00")...)
+		data = append(data, original[headerEnd:]...)
+
+		stripped, err := StripMetadata(data, "image/png")
+		require.NoError(t, err)
+		require.Equal(t, original, stripped)
+	})
+
+	t.Run("GIF", func(t *testing.T) {
+		original := encodeGIF(t, 16, 8)
+
+		// insert a comment, an xmp application extension and the animation extension before the image
+		comment := append([]byte{0x21, 0xfe, byte(len(secret))}, secret+"\x00"...)
+		xmp := append([]byte{0x21, 0xff, 11}, "XMP DataXMP"...)
+		xmp = append(append(xmp, byte(len(secret))), secret+"\x00"...)
+		loop := append([]byte{0x21, 0xff, 11}, "NETSCAPE2.0\x03\x01\x00\x00\x00"...)
+
+		imageStart := bytes.IndexByte(original, 0x2c)
+		var data []byte
+		data = append(data, original[:imageStart]...)
+		data = append(data, comment...)
+		data = append(data, xmp...)
+		data = append(data, loop...)
+		data = append(data, original[imageStart:]...)
+
+		stripped, err := StripMetadata(data, "image/gif")
+		require.NoError(t, err)
+		require.NotContains(t, string(stripped), secret)
+		require.Contains(t, string(stripped), "NETSCAPE2.0")
+
+		expected, err := gif.Decode(bytes.NewReader(original))
+		require.NoError(t, err)
+		decoded, err := gif.Decode(bytes.NewReader(stripped))
+		require.NoError(t, err)
+		require.Equal(t, expected, decoded)
+	})
+
+	t.Run("WebP", func(t *testing.T) {
+		data := webpFile(
+			vp8xChunk(webpFlagEXIF|webpFlagXMP|0x10, 16, 8),
+			vp8lChunk(16, 8),
+			webpChunk("EXIF", "MM\x00*"+secret),
+			webpChunk("XMP ", secret),
+		)
+
+		stripped, err := StripMetadata(data, "image/webp")
+		require.NoError(t, err)
+		require.Equal(t, webpFile(vp8xChunk(0x10, 16, 8), vp8lChunk(16, 8)), stripped)
+	})
+
+	t.Run("Other", func(t *testing.T) {
+		data := []byte(secret)
+
+		stripped, err := StripMetadata(data, "text/plain")
+		require.NoError(t, err)
+		require.Equal(t, data, stripped)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		jpegData := encodeJPEG(t, 16, 8)
+		pngData := encodePNG(t, 16, 8)
+		gifData := encodeGIF(t, 16, 8)
+
+		testCases := []struct {
+			name        string
+			data        []byte
+			contentType string
+		}{
+			{name: "JPEGHeader", data: []byte("not a jpeg"), contentType: "image/jpeg"},
+			{name: "JPEGTruncated", data: jpegData[:20], contentType: "image/jpeg"},
+			{name: "PNGHeader", data: []byte("not a png"), contentType: "image/png"},
+			{name: "PNGTruncated", data: pngData[:len(pngData)-12], contentType: "image/png"},
+			{name: "GIFHeader", data: []byte("not a gif"), contentType: "image/gif"},
+			{name: "GIFTruncated", data: gifData[:len(gifData)-1], contentType: "image/gif"},
+			{name: "WebPHeader", data: []byte("not a webp"), contentType: "image/webp"},
+			{name: "WebPTruncated", data: webpFile(vp8xChunk(0, 16, 8))[:20], contentType: "image/webp"},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				_, err := StripMetadata(tc.data, tc.contentType)
+				require.ErrorIs(t, err, ErrInvalidImage)
+			})
+		}
+	})
+}
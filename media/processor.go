@@ -0,0 +1,199 @@
+package media
+
+import (
+	"Chat-Server/repository"
+	"Chat-Server/storage"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// errors returned by Processor
+var (
+	ErrQueueFull = errors.New("thumbnail queue is full")
+	ErrClosed    = errors.New("thumbnail processor is closed")
+)
+
+// AttachmentKey returns the blob store key of the content of the attachment with the input id
+func AttachmentKey(id string) string {
+	return "attachments/" + id
+}
+
+// ThumbnailKey returns the blob store key of the thumbnail of the attachment with the input id
+func ThumbnailKey(id string) string {
+	return "thumbnails/" + id
+}
+
+// ThumbnailRepository is the part of the repository the thumbnails are recorded in
+type ThumbnailRepository interface {
+	SetAttachmentThumbnail(ctx context.Context, id string, thumbnail *repository.Thumbnail) (*repository.Attachment, error)
+}
+
+// ThumbnailCallback is called with the updated attachment once its thumbnail is generated
+type ThumbnailCallback func(attachment *repository.Attachment)
+
+// ProcessorConfig holds the configurations of a Processor
+type ProcessorConfig struct {
+	Workers       int           // number of thumbnails generated concurrently
+	QueueSize     int           // maximum number of attachments waiting for their thumbnails
+	ThumbnailSize int           // maximum width and height of thumbnails in pixels
+	Timeout       time.Duration // maximum time spent on the thumbnail of one attachment
+}
+
+// Processor is a bounded pool of workers generating the thumbnails of image attachments in the
+// background, so uploads do not wait for images to be decoded and scaled
+type Processor struct {
+	store      storage.BlobStore
+	repository ThumbnailRepository
+	config     ProcessorConfig
+	callback   ThumbnailCallback
+
+	// attachments waiting for their thumbnails
+	queue chan *repository.Attachment
+
+	// mu guards closed so no attachment is queued after the queue is closed
+	mu     sync.RWMutex
+	closed bool
+
+	// workers is done when all queued attachments are processed after the processor is closed
+	workers sync.WaitGroup
+}
+
+// NewProcessor creates a Processor and starts its workers. thumbnails are read from and written to
+// the input blob store and recorded in the input repository, then the callback is called
+func NewProcessor(store storage.BlobStore, thumbnails ThumbnailRepository, config ProcessorConfig, callback ThumbnailCallback) *Processor {
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+	if config.QueueSize < 1 {
+		config.QueueSize = 1
+	}
+	if config.ThumbnailSize < 1 {
+		config.ThumbnailSize = 320
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+
+	p := &Processor{
+		store:      store,
+		repository: thumbnails,
+		config:     config,
+		callback:   callback,
+		queue:      make(chan *repository.Attachment, config.QueueSize),
+	}
+
+	p.workers.Add(config.Workers)
+	for i := 0; i < config.Workers; i++ {
+		go p.run()
+	}
+
+	return p
+}
+
+// Enqueue queues the input attachment to have its thumbnail generated. attachments which are not
+// images thumbnails are generated for are ignored. returns ErrQueueFull without blocking if the
+// queue is full
+func (p *Processor) Enqueue(attachment *repository.Attachment) error {
+	if !CanThumbnail(attachment.ContentType) {
+		return nil
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrClosed
+	}
+
+	select {
+	case p.queue <- attachment:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting new attachments and waits until all queued attachments are processed
+// or the input context is done
+func (p *Processor) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run processes queued attachments until the queue is closed and drained
+func (p *Processor) run() {
+	defer p.workers.Done()
+
+	for attachment := range p.queue {
+		updated, err := p.process(attachment)
+		if err != nil {
+			log.Error().Err(err).Str("attachment", attachment.ID).Msg("failed to generate thumbnail")
+			continue
+		}
+
+		if p.callback != nil {
+			p.callback(updated)
+		}
+	}
+}
+
+// process generates, stores and records the thumbnail of the input attachment
+func (p *Processor) process(attachment *repository.Attachment) (*repository.Attachment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.Timeout)
+	defer cancel()
+
+	reader, err := p.store.Get(ctx, AttachmentKey(attachment.ID))
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, attachment.Size+1))
+	reader.Close()
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != attachment.Size {
+		return nil, fmt.Errorf("%w: %d bytes, expected %d", storage.ErrSizeMismatch, len(data), attachment.Size)
+	}
+
+	thumbnail, err := MakeThumbnail(data, attachment.ContentType, p.config.ThumbnailSize)
+	if err != nil {
+		return nil, err
+	}
+
+	size := int64(len(thumbnail.Data))
+	err = p.store.Put(ctx, ThumbnailKey(attachment.ID), bytes.NewReader(thumbnail.Data), size, thumbnail.ContentType)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.repository.SetAttachmentThumbnail(ctx, attachment.ID, &repository.Thumbnail{
+		ContentType: thumbnail.ContentType,
+		Width:       thumbnail.Width,
+		Height:      thumbnail.Height,
+		Size:        size,
+	})
+}
@@ -0,0 +1,147 @@
+package media
+
+import (
+	"Chat-Server/repository"
+	mockdb "Chat-Server/repository/mock"
+	"Chat-Server/storage"
+	"bytes"
+	"context"
+	"image"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// putAttachment stores the input content as the content of an attachment and returns the attachment
+func putAttachment(t *testing.T, store storage.BlobStore, id, contentType string, content []byte) *repository.Attachment {
+	err := store.Put(context.Background(), AttachmentKey(id), bytes.NewReader(content), int64(len(content)), contentType)
+	require.NoError(t, err)
+
+	return &repository.Attachment{ID: id, ContentType: contentType, Size: int64(len(content))}
+}
+
+// receiveAttachment waits for the next attachment passed to the thumbnail callback
+func receiveAttachment(t *testing.T, attachments chan *repository.Attachment) *repository.Attachment {
+	select {
+	case attachment := <-attachments:
+		return attachment
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "thumbnail was not generated")
+		return nil
+	}
+}
+
+// TestProcessor tests Processor
+func TestProcessor(t *testing.T) {
+	store, err := storage.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	t.Run("Thumbnail", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockdb.NewMockRepository(ctrl)
+
+		attachment := putAttachment(t, store, "thumbnail", "image/jpeg", encodeJPEG(t, 640, 480))
+		repo.EXPECT().
+			SetAttachmentThumbnail(gomock.Any(), "thumbnail", gomock.Any()).
+			Times(1).
+			DoAndReturn(func(_ context.Context, id string, thumbnail *repository.Thumbnail) (*repository.Attachment, error) {
+				require.Equal(t, "image/jpeg", thumbnail.ContentType)
+				require.Equal(t, 100, thumbnail.Width)
+				require.Equal(t, 75, thumbnail.Height)
+				return &repository.Attachment{ID: id, Thumbnail: thumbnail}, nil
+			})
+
+		attachments := make(chan *repository.Attachment, 1)
+		processor := NewProcessor(store, repo, ProcessorConfig{ThumbnailSize: 100}, func(attachment *repository.Attachment) {
+			attachments <- attachment
+		})
+		defer processor.Close(context.Background())
+
+		require.NoError(t, processor.Enqueue(attachment))
+		updated := receiveAttachment(t, attachments)
+		require.Equal(t, "thumbnail", updated.ID)
+
+		// the thumbnail is stored next to the attachment
+		reader, err := store.Get(context.Background(), ThumbnailKey("thumbnail"))
+		require.NoError(t, err)
+		defer reader.Close()
+		content, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.Equal(t, updated.Thumbnail.Size, int64(len(content)))
+
+		decoded, _, err := image.Decode(bytes.NewReader(content))
+		require.NoError(t, err)
+		require.Equal(t, image.Rect(0, 0, 100, 75), decoded.Bounds())
+	})
+
+	t.Run("NotImage", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockdb.NewMockRepository(ctrl)
+		repo.EXPECT().SetAttachmentThumbnail(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+		processor := NewProcessor(store, repo, ProcessorConfig{}, nil)
+		require.NoError(t, processor.Enqueue(&repository.Attachment{ID: "text", ContentType: "text/plain"}))
+		require.NoError(t, processor.Close(context.Background()))
+	})
+
+	t.Run("InvalidImage", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockdb.NewMockRepository(ctrl)
+		repo.EXPECT().SetAttachmentThumbnail(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+		attachment := putAttachment(t, store, "invalid", "image/png", []byte("not a png"))
+		processor := NewProcessor(store, repo, ProcessorConfig{}, func(*repository.Attachment) {
+			require.Fail(t, "callback called for an invalid image")
+		})
+		require.NoError(t, processor.Enqueue(attachment))
+		require.NoError(t, processor.Close(context.Background()))
+
+		_, err := store.Get(context.Background(), ThumbnailKey("invalid"))
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockdb.NewMockRepository(ctrl)
+
+		processor := NewProcessor(store, repo, ProcessorConfig{}, nil)
+		require.NoError(t, processor.Close(context.Background()))
+
+		err := processor.Enqueue(&repository.Attachment{ID: "closed", ContentType: "image/png"})
+		require.ErrorIs(t, err, ErrClosed)
+	})
+
+	t.Run("QueueFull", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockdb.NewMockRepository(ctrl)
+
+		// block the only worker in the repository until the queue is full
+		release := make(chan struct{})
+		attachment := putAttachment(t, store, "queue", "image/png", encodePNG(t, 4, 4))
+		repo.EXPECT().
+			SetAttachmentThumbnail(gomock.Any(), "queue", gomock.Any()).
+			Times(2).
+			DoAndReturn(func(_ context.Context, id string, thumbnail *repository.Thumbnail) (*repository.Attachment, error) {
+				<-release
+				return &repository.Attachment{ID: id, Thumbnail: thumbnail}, nil
+			})
+
+		attachments := make(chan *repository.Attachment, 2)
+		processor := NewProcessor(store, repo, ProcessorConfig{Workers: 1, QueueSize: 1}, func(attachment *repository.Attachment) {
+			attachments <- attachment
+		})
+
+		require.NoError(t, processor.Enqueue(attachment))
+		require.Eventually(t, func() bool { return len(processor.queue) == 0 }, 5*time.Second, time.Millisecond)
+		require.NoError(t, processor.Enqueue(attachment))
+		require.ErrorIs(t, processor.Enqueue(attachment), ErrQueueFull)
+
+		// closing waits for the queued attachments
+		close(release)
+		require.NoError(t, processor.Close(context.Background()))
+		require.Len(t, attachments, 2)
+	})
+}
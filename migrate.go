@@ -0,0 +1,121 @@
+package main
+
+import (
+	"Chat-Server/repository"
+	"Chat-Server/repository/db/migrate"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// migrateUsage is the usage of the migrate subcommand
+const migrateUsage = `usage: main migrate <command>
+
+commands:
+  up            apply all pending migrations
+  down          revert the latest applied migration
+  status        list the migrations and whether they are applied
+  to <version>  apply or revert migrations until the schema is at the version, 0 reverts all`
+
+// errNoSchema is returned for repositories without a versioned sql schema
+var errNoSchema = errors.New("the repository backend has no sql schema to migrate")
+
+// schemaMigrator is implemented by the repository backends with a versioned sql schema
+type schemaMigrator interface {
+	Migrator() (*migrate.Migrator, error)
+}
+
+// newMigrator returns the migrator of the input repository, or errNoSchema if it has no sql schema
+func newMigrator(r repository.Repository) (*migrate.Migrator, error) {
+	sm, ok := r.(schemaMigrator)
+	if !ok {
+		return nil, errNoSchema
+	}
+
+	return sm.Migrator()
+}
+
+// verifySchema returns an error if the schema of the repository is not at the version this server is built for
+func verifySchema(r repository.Repository) error {
+	migrator, err := newMigrator(r)
+	if errors.Is(err, errNoSchema) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return migrator.Verify()
+}
+
+// runMigrate runs the migrate subcommand with the input arguments against the input repository
+func runMigrate(r repository.Repository, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	migrator, err := newMigrator(r)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		err = migrator.Up()
+	case args[0] == "down" && len(args) == 1:
+		err = migrator.Down()
+	case args[0] == "to" && len(args) == 2:
+		version, parseErr := strconv.ParseUint(args[1], 10, 0)
+		if parseErr != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		err = migrator.To(uint(version))
+	case args[0] == "status" && len(args) == 1:
+		return printMigrationStatus(migrator)
+	default:
+		return errors.New(migrateUsage)
+	}
+	if err != nil {
+		return err
+	}
+
+	version, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("schema is at version %d, latest version is %d\n", version, migrator.Latest())
+
+	return nil
+}
+
+// printMigrationStatus prints the known migrations and whether each is applied
+func printMigrationStatus(migrator *migrate.Migrator) error {
+	status, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, migration := range status {
+		appliedAt := "pending"
+		if migration.Applied {
+			appliedAt = migration.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", migration.Version, migration.Name, appliedAt)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	// pending migrations are listed above, but applied migrations unknown to this server are not
+	if err := migrator.Verify(); errors.Is(err, migrate.ErrUnknownVersion) {
+		return err
+	}
+
+	return nil
+}
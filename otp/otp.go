@@ -0,0 +1,169 @@
+// Package otp implements the time-based one-time passwords of RFC 6238, as generated by the authenticator
+// apps, and the single-use recovery codes replacing them when the authenticator is lost
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Digits is the number of digits of a code
+	Digits = 6
+
+	// modulo truncates the codes to Digits digits
+	modulo = 1_000_000
+
+	// Period is the time a code is valid for, the length of a time step
+	Period = 30 * time.Second
+
+	// Skew is the number of time steps before and after the current one whose codes are accepted, for the
+	// clocks of the authenticators which are off and the codes typed at the end of their step
+	Skew = 1
+
+	// secretSize is the size in bytes of a secret, the size of the sha1 hmac key recommended by RFC 4226
+	secretSize = 20
+
+	// recoveryCodeSize is the number of random characters of a recovery code
+	recoveryCodeSize = 10
+)
+
+// encoding is the base32 encoding of the secrets, without padding as the authenticator apps expect
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a new random secret, base32 encoded
+func GenerateSecret() (string, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return encoding.EncodeToString(secret), nil
+}
+
+// URI returns the otpauth uri of the input secret, which the authenticator apps read from a QR code to
+// generate the codes of the account of the issuer
+func URI(issuer, account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(Digits))
+	query.Set("period", fmt.Sprint(int(Period.Seconds())))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// Step returns the time step of the input time
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period.Seconds())
+}
+
+// Code returns the code of the input secret at the input time
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return code(key, Step(t)), nil
+}
+
+// Validate reports whether the input code is the code of the input secret at a time step within Skew steps
+// of the input time, and after the input step, and returns its step. the step of the last accepted code is
+// passed so a code is accepted only once
+func Validate(secret, input string, t time.Time, after int64) (int64, bool) {
+	key, err := decodeSecret(secret)
+	if err != nil || len(input) != Digits {
+		return 0, false
+	}
+
+	now := Step(t)
+	for step := now - Skew; step <= now+Skew; step++ {
+		if step <= after {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(code(key, step)), []byte(input)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// IsCode reports whether the input looks like a code rather than a recovery code
+func IsCode(input string) bool {
+	if len(input) != Digits {
+		return false
+	}
+
+	for _, r := range input {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// GenerateRecoveryCodes returns the input number of new random recovery codes, e.g. "k3vq9-x2mfa"
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	random := make([]byte, n*recoveryCodeSize)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+
+	// the lowercase base32 alphabet, each random byte picks one of its 32 characters
+	const alphabet = "abcdefghijklmnopqrstuvwxyz234567"
+
+	codes := make([]string, n)
+	for i := range codes {
+		var code strings.Builder
+		for j, b := range random[i*recoveryCodeSize : (i+1)*recoveryCodeSize] {
+			if j == recoveryCodeSize/2 {
+				code.WriteByte('-')
+			}
+			code.WriteByte(alphabet[b%32])
+		}
+		codes[i] = code.String()
+	}
+
+	return codes, nil
+}
+
+// NormalizeRecoveryCode returns the input recovery code as it is generated, a recovery code is typed in
+// any case and with surrounding white space
+func NormalizeRecoveryCode(input string) string {
+	return strings.ToLower(strings.TrimSpace(input))
+}
+
+// code returns the code of the input key at the input time step, the dynamic truncation of RFC 4226
+func code(key []byte, step int64) string {
+	mac := hmac.New(sha1.New, key)
+	binary.Write(mac, binary.BigEndian, step)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", Digits, value%modulo)
+}
+
+// decodeSecret decodes a base32 secret, the secrets typed by the users may be lowercase or padded
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.TrimRight(strings.ToUpper(secret), "=")
+
+	key, err := encoding.DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,105 @@
+package otp
+
+import (
+	"encoding/base32"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCode tests the codes against the sha1 test vectors of RFC 6238, truncated to 6 digits
+func TestCode(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	testCases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, testCase := range testCases {
+		code, err := Code(secret, time.Unix(testCase.unix, 0))
+		require.NoError(t, err)
+		require.Equal(t, testCase.code, code)
+	}
+
+	// the secrets are decoded in any case and with or without padding
+	code, err := Code(strings.ToLower(strings.TrimRight(secret, "=")), time.Unix(59, 0))
+	require.NoError(t, err)
+	require.Equal(t, "287082", code)
+
+	_, err = Code("not base32!", time.Now())
+	require.Error(t, err)
+}
+
+// TestValidate tests validating the codes within the skew, and only once
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	require.Len(t, secret, 32)
+
+	now := time.Unix(1_700_000_000, 0)
+	code, err := Code(secret, now)
+	require.NoError(t, err)
+
+	step, ok := Validate(secret, code, now, 0)
+	require.True(t, ok)
+	require.Equal(t, Step(now), step)
+
+	// the code of the previous step is accepted, not the one before
+	step, ok = Validate(secret, code, now.Add(Period), 0)
+	require.True(t, ok)
+	require.Equal(t, Step(now), step)
+	_, ok = Validate(secret, code, now.Add(2*Period), 0)
+	require.False(t, ok)
+
+	// a code is not accepted again once its step is accepted
+	_, ok = Validate(secret, code, now, step)
+	require.False(t, ok)
+
+	_, ok = Validate(secret, "000000x", now, 0)
+	require.False(t, ok)
+	_, ok = Validate("not base32!", code, now, 0)
+	require.False(t, ok)
+}
+
+// TestURI tests the otpauth uri of a secret
+func TestURI(t *testing.T) {
+	uri, err := url.Parse(URI("Chat Hub", "alice", "JBSWY3DPEHPK3PXP"))
+	require.NoError(t, err)
+
+	require.Equal(t, "otpauth", uri.Scheme)
+	require.Equal(t, "totp", uri.Host)
+	require.Equal(t, "/Chat Hub:alice", uri.Path)
+	require.Equal(t, "JBSWY3DPEHPK3PXP", uri.Query().Get("secret"))
+	require.Equal(t, "Chat Hub", uri.Query().Get("issuer"))
+	require.Equal(t, "6", uri.Query().Get("digits"))
+	require.Equal(t, "30", uri.Query().Get("period"))
+}
+
+// TestRecoveryCodes tests generating and normalizing the recovery codes
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		require.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, code)
+		require.False(t, IsCode(code))
+		require.Equal(t, code, NormalizeRecoveryCode(" "+strings.ToUpper(code)+"\n"))
+		require.False(t, seen[code])
+		seen[code] = true
+	}
+
+	require.True(t, IsCode("012345"))
+	require.False(t, IsCode("01234"))
+}
@@ -0,0 +1,91 @@
+// Package ratelimit limits the rate of the requests of clients with a token bucket per client. the buckets
+// are kept by a Store, in memory for a single instance of the server or in the database so the instances
+// of the server share them
+package ratelimit
+
+import (
+	"Chat-Server/repository"
+	"context"
+	"time"
+)
+
+// Limit is the rate limit of a key: its bucket holds at most Burst tokens and gains PerMinute tokens per
+// minute, a request takes one token. a limit of zero requests per minute disables the rate limiting
+type Limit struct {
+	PerMinute int // requests allowed per minute
+	Burst     int // requests allowed at once, at least one
+}
+
+// Disabled reports whether the limit allows every request
+func (l Limit) Disabled() bool {
+	return l.PerMinute <= 0
+}
+
+// interval returns the time a bucket takes to gain one token
+func (l Limit) interval() time.Duration {
+	return time.Minute / time.Duration(l.PerMinute)
+}
+
+// burst returns the maximum number of tokens of a bucket
+func (l Limit) burst() float64 {
+	return float64(max(l.Burst, 1))
+}
+
+// Store keeps the token buckets of the keys
+type Store interface {
+	// Take takes a token from the bucket of the input key, returns false and the time until the bucket has
+	// a token again if the bucket is empty
+	Take(ctx context.Context, key string, limit Limit) (bool, time.Duration, error)
+}
+
+// Limiter limits the rate of the requests of every key, e.g. of every user or address. the limiters sharing
+// a store have their own buckets
+type Limiter struct {
+	store Store
+	name  string
+	limit Limit
+}
+
+// New returns a Limiter keeping its buckets in the input store, the name of the limiter is unique among
+// the limiters of the store
+func New(store Store, name string, limit Limit) *Limiter {
+	return &Limiter{store: store, name: name, limit: limit}
+}
+
+// Allow reports whether a request of the input key is allowed, and if not the time until it is. a nil
+// Limiter and a Limiter of a disabled limit allow every request
+func (l *Limiter) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	if l == nil || l.limit.Disabled() {
+		return true, 0, nil
+	}
+
+	return l.store.Take(ctx, l.name+":"+key, l.limit)
+}
+
+// take takes a token from the input bucket at the input time, returns false and the time until the bucket
+// has a token again if the bucket is empty. a bucket which is never updated is full
+func take(bucket *repository.RateLimitBucket, now time.Time, limit Limit) (bool, time.Duration) {
+	interval, burst := limit.interval(), limit.burst()
+
+	switch {
+	case bucket.UpdatedAt.IsZero():
+		bucket.Tokens = burst
+		bucket.UpdatedAt = now
+	case now.After(bucket.UpdatedAt):
+		// the clocks of the instances may differ, a bucket only gains tokens as time goes forward
+		bucket.Tokens = min(burst, bucket.Tokens+float64(now.Sub(bucket.UpdatedAt))/float64(interval))
+		bucket.UpdatedAt = now
+	}
+
+	allowed := bucket.Tokens >= 1
+	if allowed {
+		bucket.Tokens--
+	}
+	bucket.FullAt = bucket.UpdatedAt.Add(time.Duration((burst - bucket.Tokens) * float64(interval)))
+
+	if !allowed {
+		return false, time.Duration((1 - bucket.Tokens) * float64(interval))
+	}
+
+	return true, 0
+}
@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"Chat-Server/repository"
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is the minimum time between two sweeps of the full buckets of a store
+const sweepInterval = time.Minute
+
+// MemoryStore keeps the buckets in memory, for a single instance of the server. it is safe for concurrent use
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*repository.RateLimitBucket
+
+	// full buckets are dropped at most once per sweepInterval, a key without a bucket has a full one
+	sweptAt time.Time
+
+	// now returns the current time, replaced in tests
+	now func() time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*repository.RateLimitBucket), now: time.Now}
+}
+
+// Take takes a token from the bucket of the input key in memory
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return false, 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.sweptAt) >= sweepInterval {
+		s.sweptAt = now
+		for key, bucket := range s.buckets {
+			if !bucket.FullAt.After(now) {
+				delete(s.buckets, key)
+			}
+		}
+	}
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &repository.RateLimitBucket{Key: key}
+		s.buckets[key] = bucket
+	}
+	allowed, retryAfter := take(bucket, now, limit)
+
+	return allowed, retryAfter, nil
+}
+
+// RepositoryStore keeps the buckets in the repository, so the instances of the server sharing the database
+// share the buckets. it is safe for concurrent use
+type RepositoryStore struct {
+	repository repository.Repository
+
+	// full buckets are deleted at most once per sweepInterval by every instance
+	mu      sync.Mutex
+	sweptAt time.Time
+
+	// now returns the current time, replaced in tests
+	now func() time.Time
+}
+
+// NewRepositoryStore returns a RepositoryStore keeping its buckets in the input repository
+func NewRepositoryStore(repository repository.Repository) *RepositoryStore {
+	return &RepositoryStore{repository: repository, now: time.Now}
+}
+
+// Take takes a token from the bucket of the input key in the repository
+func (s *RepositoryStore) Take(ctx context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	now := s.now().UTC().Truncate(time.Microsecond)
+
+	if s.sweepDue(now) {
+		if err := s.repository.DeleteFullRateLimitBuckets(ctx, now); err != nil {
+			return false, 0, err
+		}
+	}
+
+	var allowed bool
+	var retryAfter time.Duration
+	err := s.repository.UpdateRateLimitBucket(ctx, key, func(bucket *repository.RateLimitBucket) {
+		allowed, retryAfter = take(bucket, now, limit)
+	})
+	if err != nil {
+		return false, 0, err
+	}
+
+	return allowed, retryAfter, nil
+}
+
+// sweepDue reports whether the full buckets are deleted at the input time
+func (s *RepositoryStore) sweepDue(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.sweptAt) < sweepInterval {
+		return false
+	}
+	s.sweptAt = now
+
+	return true
+}
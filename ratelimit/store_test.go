@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"Chat-Server/repository"
+	"Chat-Server/repository/db/memory"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestStores tests taking and refilling the tokens of the buckets of the stores
+func TestStores(t *testing.T) {
+	testCases := []struct {
+		name     string
+		newStore func(now func() time.Time) (Store, func(key string) bool)
+	}{
+		{
+			name: "Memory",
+			newStore: func(now func() time.Time) (Store, func(key string) bool) {
+				store := NewMemoryStore()
+				store.now = now
+				return store, func(key string) bool {
+					_, ok := store.buckets[key]
+					return ok
+				}
+			},
+		},
+		{
+			name: "Repository",
+			newStore: func(now func() time.Time) (Store, func(key string) bool) {
+				repo := memory.NewMemoryRepository()
+				store := NewRepositoryStore(repo)
+				store.now = now
+				return store, func(key string) bool {
+					var ok bool
+					err := repo.UpdateRateLimitBucket(context.Background(), key, func(bucket *repository.RateLimitBucket) {
+						ok = !bucket.UpdatedAt.IsZero()
+					})
+					require.NoError(t, err)
+					return ok
+				}
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now()
+			store, hasBucket := testCase.newStore(func() time.Time { return now })
+			limit := Limit{PerMinute: 60, Burst: 2}
+
+			take := func(key string) (bool, time.Duration) {
+				allowed, retryAfter, err := store.Take(ctx, key, limit)
+				require.NoError(t, err)
+				return allowed, retryAfter
+			}
+
+			// the burst is allowed at once, then the bucket is empty until it gains a token
+			for range 2 {
+				allowed, _ := take("first")
+				require.True(t, allowed)
+			}
+			allowed, retryAfter := take("first")
+			require.False(t, allowed)
+			require.Equal(t, time.Second, retryAfter)
+
+			// every key has its own bucket
+			allowed, _ = take("second")
+			require.True(t, allowed)
+
+			now = now.Add(500 * time.Millisecond)
+			allowed, retryAfter = take("first")
+			require.False(t, allowed)
+			require.Equal(t, 500*time.Millisecond, retryAfter)
+
+			now = now.Add(500 * time.Millisecond)
+			allowed, _ = take("first")
+			require.True(t, allowed)
+
+			// the bucket holds no more than the burst
+			now = now.Add(time.Hour)
+			for range 2 {
+				allowed, _ = take("first")
+				require.True(t, allowed)
+			}
+			allowed, _ = take("first")
+			require.False(t, allowed)
+
+			// the full buckets are swept
+			require.False(t, hasBucket("second"))
+		})
+	}
+}
+
+// TestLimiter tests the limiters sharing a store
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	login := New(store, "login", Limit{PerMinute: 1, Burst: 1})
+	messages := New(store, "messages", Limit{PerMinute: 1, Burst: 1})
+
+	allowed, _, err := login.Allow(ctx, "127.0.0.1")
+	require.NoError(t, err)
+	require.True(t, allowed)
+
+	allowed, retryAfter, err := login.Allow(ctx, "127.0.0.1")
+	require.NoError(t, err)
+	require.False(t, allowed)
+	require.InDelta(t, time.Minute, retryAfter, float64(time.Second))
+
+	// the limiters of a store have their own buckets
+	allowed, _, err = messages.Allow(ctx, "127.0.0.1")
+	require.NoError(t, err)
+	require.True(t, allowed)
+
+	// a nil limiter and a disabled limit allow every request
+	var none *Limiter
+	disabled := New(store, "disabled", Limit{Burst: 1})
+	for range 3 {
+		for _, limiter := range []*Limiter{none, disabled} {
+			allowed, _, err = limiter.Allow(ctx, "127.0.0.1")
+			require.NoError(t, err)
+			require.True(t, allowed)
+		}
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, _, err = messages.Allow(canceled, "127.0.0.1")
+	require.ErrorIs(t, err, context.Canceled)
+}
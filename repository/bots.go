@@ -0,0 +1,16 @@
+package repository
+
+import "time"
+
+// Bot represents a bot user, an account of a program owned by a human user. bots cannot log in, they
+// authenticate with a long-lived API token
+type Bot struct {
+	// Username of the bot user, the user is created with the bot
+	Username string
+	// Owner is the username of the human user who created the bot
+	Owner string
+	// TokenHash is the hex encoded sha256 hash of the API token of the bot, the token itself is never stored
+	TokenHash string
+	// CreatedAt is the time the bot is created
+	CreatedAt time.Time
+}
@@ -0,0 +1,34 @@
+package repository
+
+import "context"
+
+// userContextKey is the context key of the user on whose behalf repository operations run
+type userContextKey struct{}
+
+// WithUser returns a context of the input one carrying the user on whose behalf repository operations
+// run. repositories reading from replicas serve the reads of a user who just wrote from the primary,
+// so users read their own writes
+func WithUser(ctx context.Context, username string) context.Context {
+	return context.WithValue(ctx, userContextKey{}, username)
+}
+
+// UserFromContext returns the user set in the input context with WithUser
+func UserFromContext(ctx context.Context) (string, bool) {
+	username, ok := ctx.Value(userContextKey{}).(string)
+	return username, ok
+}
+
+// primaryContextKey is the context key marking the reads which need the primary
+type primaryContextKey struct{}
+
+// WithPrimary returns a context of the input one whose reads are served from the primary. the reads checking
+// credentials use it, so a changed password is checked on every instance as soon as it is changed
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
+
+// PrimaryFromContext reports whether the reads of the input context need the primary, see WithPrimary
+func PrimaryFromContext(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryContextKey{}).(bool)
+	return primary
+}
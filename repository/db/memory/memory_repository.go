@@ -0,0 +1,1250 @@
+package memory
+
+import (
+	"Chat-Server/repository"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryRepository implements Repository, keeps all the data in memory. it is safe
+// for concurrent use and is meant for local development and tests. its methods never
+// block on I/O so the context is only checked before an operation starts
+type MemoryRepository struct {
+	mu sync.RWMutex
+
+	users       map[string]repository.User
+	messages    []repository.Message
+	sessions    map[uint]repository.Session
+	attachments map[string]repository.Attachment
+
+	// notifications in the order they are created, the id of a notification is its index + 1
+	notifications []repository.Notification
+
+	webhooks map[uint]repository.Webhook
+
+	// webhook delivery attempts in the order they are made
+	deliveries []repository.WebhookDelivery
+
+	incomingHooks map[uint]repository.IncomingHook
+
+	bots map[string]repository.Bot
+
+	rateLimitBuckets map[string]repository.RateLimitBucket
+	loginThrottles   map[string]repository.LoginThrottle
+
+	// lockouts in the order they are created, the id of a lockout is its index + 1
+	lockouts []repository.Lockout
+
+	twoFactors map[string]repository.TwoFactor
+
+	// password resets by the hashes of their tokens
+	passwordResets map[string]repository.PasswordReset
+
+	// last assigned session, webhook, webhook delivery and incoming hook ids
+	lastSessionID      uint
+	lastWebhookID      uint
+	lastDeliveryID     uint
+	lastIncomingHookID uint
+}
+
+// ensure MemoryRepository implements Repository interface
+var _ repository.Repository = (*MemoryRepository)(nil)
+
+// NewMemoryRepository returns a new empty MemoryRepository
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		users:       make(map[string]repository.User),
+		sessions:    make(map[uint]repository.Session),
+		attachments: make(map[string]repository.Attachment),
+		webhooks:    make(map[uint]repository.Webhook),
+
+		incomingHooks: make(map[uint]repository.IncomingHook),
+		bots:          make(map[string]repository.Bot),
+
+		rateLimitBuckets: make(map[string]repository.RateLimitBucket),
+		loginThrottles:   make(map[string]repository.LoginThrottle),
+
+		twoFactors:     make(map[string]repository.TwoFactor),
+		passwordResets: make(map[string]repository.PasswordReset),
+	}
+}
+
+// Close does nothing, there is nothing to release
+func (m *MemoryRepository) Close() error {
+	return nil
+}
+
+// Ping always succeeds, the memory is always reachable
+func (m *MemoryRepository) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// AddMessage saves the input message in memory
+func (m *MemoryRepository) AddMessage(ctx context.Context, message *repository.Message) (*repository.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[message.Author]; !ok {
+		return nil, repository.ErrAuthorNotFound
+	}
+	if err := m.checkAttachments([]*repository.Message{message}); err != nil {
+		return nil, err
+	}
+
+	return m.addMessage(message), nil
+}
+
+// AddMessages saves the input messages in memory, either all of the messages are saved or none of them
+func (m *MemoryRepository) AddMessages(ctx context.Context, messages []*repository.Message) ([]*repository.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(messages) == 0 {
+		return nil, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, message := range messages {
+		if _, ok := m.users[message.Author]; !ok {
+			return nil, repository.ErrAuthorNotFound
+		}
+	}
+	if err := m.checkAttachments(messages); err != nil {
+		return nil, err
+	}
+
+	savedMessages := make([]*repository.Message, len(messages))
+	for i, message := range messages {
+		savedMessages[i] = m.addMessage(message)
+	}
+
+	return savedMessages, nil
+}
+
+// checkAttachments returns ErrConflict if an attachment of the input messages is not an unattached
+// attachment of its message's author, or is attached to two of the messages. the caller must hold the lock
+func (m *MemoryRepository) checkAttachments(messages []*repository.Message) error {
+	attached := make(map[string]bool)
+	for _, message := range messages {
+		for _, messageAttachment := range message.Attachments {
+			attachment, ok := m.attachments[messageAttachment.ID]
+			if !ok || attachment.Owner != message.Author || attachment.MessageID != 0 || attached[attachment.ID] {
+				return repository.ErrConflict
+			}
+			attached[attachment.ID] = true
+		}
+	}
+
+	return nil
+}
+
+// addMessage appends the input message to the messages, assigns its id and attaches its attachments,
+// the caller must hold the lock
+func (m *MemoryRepository) addMessage(message *repository.Message) *repository.Message {
+	newMessage := repository.Message{
+		ID:        uint(len(m.messages) + 1),
+		Text:      message.Text,
+		Rendered:  message.Rendered,
+		Entities:  slices.Clone(message.Entities),
+		Author:    message.Author,
+		Room:      message.RoomName(),
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+
+	for _, messageAttachment := range message.Attachments {
+		attachment := m.attachments[messageAttachment.ID]
+		attachment.MessageID = newMessage.ID
+		m.attachments[attachment.ID] = attachment
+		newMessage.Attachments = append(newMessage.Attachments, &attachment)
+	}
+	m.messages = append(m.messages, newMessage)
+
+	savedMessage := m.copyMessage(newMessage)
+	for _, username := range repository.Mentions(message) {
+		if _, ok := m.users[username]; !ok {
+			continue
+		}
+
+		notification := repository.Notification{
+			ID:        uint(len(m.notifications) + 1),
+			Username:  username,
+			MessageID: newMessage.ID,
+			CreatedAt: newMessage.CreatedAt,
+		}
+		m.notifications = append(m.notifications, notification)
+		savedMessage.Notifications = append(savedMessage.Notifications, m.copyNotification(notification))
+	}
+
+	return savedMessage
+}
+
+// copyNotification returns a copy of the input notification with the author, room and text of its
+// message. the caller must hold the lock
+func (m *MemoryRepository) copyNotification(notification repository.Notification) *repository.Notification {
+	message := m.messages[notification.MessageID-1]
+	notification.Author = message.Author
+	notification.Room = message.Room
+	notification.Text = message.Text
+
+	return &notification
+}
+
+// copyMessage returns a copy of the input message with the current state of its attachments, the
+// copy shares nothing with the saved message. the caller must hold the lock
+func (m *MemoryRepository) copyMessage(message repository.Message) *repository.Message {
+	message.Entities = slices.Clone(message.Entities)
+
+	attachments := message.Attachments
+	message.Attachments = nil
+	for _, attachment := range attachments {
+		message.Attachments = append(message.Attachments, copyAttachment(m.attachments[attachment.ID]))
+	}
+
+	return &message
+}
+
+// copyAttachment returns a copy of the input attachment which shares nothing with it
+func copyAttachment(attachment repository.Attachment) *repository.Attachment {
+	if attachment.Thumbnail != nil {
+		thumbnail := *attachment.Thumbnail
+		attachment.Thumbnail = &thumbnail
+	}
+
+	return &attachment
+}
+
+// GetAllMessages retrieves all messages in the order they are saved
+func (m *MemoryRepository) GetAllMessages(ctx context.Context) ([]*repository.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	messages := make([]*repository.Message, len(m.messages))
+	for i := range m.messages {
+		messages[i] = m.copyMessage(m.messages[i])
+	}
+
+	return messages, nil
+}
+
+// SearchMessages retrieves the messages matching the input search, newest first, by matching
+// the messages one by one
+func (m *MemoryRepository) SearchMessages(ctx context.Context, search *repository.MessageSearch) ([]*repository.MessageMatch, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	terms := repository.SearchTerms(search.Text)
+
+	matches := []*repository.MessageMatch{}
+	for i := len(m.messages) - 1; i >= 0 && len(matches) < search.Limit; i-- {
+		message := m.messages[i]
+
+		switch {
+		case search.Author != "" && message.Author != search.Author,
+			search.Room != "" && message.Room != search.Room,
+			!search.Since.IsZero() && message.CreatedAt.Before(search.Since),
+			!search.Until.IsZero() && message.CreatedAt.After(search.Until),
+			search.BeforeID != 0 && message.ID >= search.BeforeID:
+			continue
+		}
+
+		if snippet, ok := repository.NaiveMatch(message.Text, terms); ok {
+			matches = append(matches, &repository.MessageMatch{Message: *m.copyMessage(message), Snippet: snippet})
+		}
+	}
+
+	return matches, nil
+}
+
+// GetNotifications retrieves the notifications matching the input query, newest first
+func (m *MemoryRepository) GetNotifications(ctx context.Context, query *repository.NotificationQuery) ([]*repository.Notification, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	notifications := []*repository.Notification{}
+	for i := len(m.notifications) - 1; i >= 0 && len(notifications) < query.Limit; i-- {
+		notification := m.notifications[i]
+
+		switch {
+		case notification.Username != query.Username,
+			query.UnreadOnly && notification.Read,
+			query.BeforeID != 0 && notification.ID >= query.BeforeID:
+			continue
+		}
+
+		notifications = append(notifications, m.copyNotification(notification))
+	}
+
+	return notifications, nil
+}
+
+// CountUnreadNotifications returns the number of unread notifications of the input user
+func (m *MemoryRepository) CountUnreadNotifications(ctx context.Context, username string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var count int64
+	for _, notification := range m.notifications {
+		if notification.Username == username && !notification.Read {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// MarkNotificationsRead marks the notifications of the input user with the input ids as read, all
+// the notifications of the user if there is no id
+func (m *MemoryRepository) MarkNotificationsRead(ctx context.Context, username string, ids []uint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.notifications {
+		if m.notifications[i].Username == username && (len(ids) == 0 || slices.Contains(ids, m.notifications[i].ID)) {
+			m.notifications[i].Read = true
+		}
+	}
+
+	return nil
+}
+
+// AddAttachment saves the input attachment in memory, unattached
+func (m *MemoryRepository) AddAttachment(ctx context.Context, attachment *repository.Attachment) (*repository.Attachment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[attachment.Owner]; !ok {
+		return nil, repository.ErrAuthorNotFound
+	}
+	if _, ok := m.attachments[attachment.ID]; ok {
+		return nil, repository.ErrConflict
+	}
+
+	newAttachment := *copyAttachment(*attachment)
+	newAttachment.MessageID = 0
+	newAttachment.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	m.attachments[newAttachment.ID] = newAttachment
+
+	return copyAttachment(newAttachment), nil
+}
+
+// GetAttachment retrieves an attachment by id
+func (m *MemoryRepository) GetAttachment(ctx context.Context, id string) (*repository.Attachment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	attachment, ok := m.attachments[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	return copyAttachment(attachment), nil
+}
+
+// SetAttachmentThumbnail sets the thumbnail of an attachment
+func (m *MemoryRepository) SetAttachmentThumbnail(ctx context.Context, id string, thumbnail *repository.Thumbnail) (*repository.Attachment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attachment, ok := m.attachments[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	newThumbnail := *thumbnail
+	attachment.Thumbnail = &newThumbnail
+	m.attachments[id] = attachment
+
+	return copyAttachment(attachment), nil
+}
+
+// AddIncomingHook saves the input incoming hook and its bot user in memory
+func (m *MemoryRepository) AddIncomingHook(ctx context.Context, hook *repository.IncomingHook) (*repository.IncomingHook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[hook.Username]; ok {
+		return nil, repository.ErrUserExists
+	}
+	if _, ok := m.users[hook.CreatedBy]; !ok {
+		return nil, repository.ErrAuthorNotFound
+	}
+	for _, existing := range m.incomingHooks {
+		if existing.TokenHash == hook.TokenHash {
+			return nil, repository.ErrConflict
+		}
+	}
+
+	// the bot user has no password, so nobody logs in as the bot
+	m.users[hook.Username] = repository.User{Username: hook.Username}
+
+	m.lastIncomingHookID++
+	newHook := repository.IncomingHook{
+		ID:        m.lastIncomingHookID,
+		TokenHash: hook.TokenHash,
+		Name:      hook.Name,
+		Username:  hook.Username,
+		CreatedBy: hook.CreatedBy,
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	m.incomingHooks[newHook.ID] = newHook
+
+	return &newHook, nil
+}
+
+// GetIncomingHookByToken retrieves an incoming hook by the hash of its token from memory
+func (m *MemoryRepository) GetIncomingHookByToken(ctx context.Context, tokenHash string) (*repository.IncomingHook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, hook := range m.incomingHooks {
+		if hook.TokenHash == tokenHash {
+			return &hook, nil
+		}
+	}
+
+	return nil, repository.ErrNotFound
+}
+
+// GetIncomingHooks retrieves all incoming hooks from memory in the order they are created
+func (m *MemoryRepository) GetIncomingHooks(ctx context.Context) ([]*repository.IncomingHook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hooks := make([]*repository.IncomingHook, 0, len(m.incomingHooks))
+	for _, hook := range m.incomingHooks {
+		hooks = append(hooks, &hook)
+	}
+	slices.SortFunc(hooks, func(a, b *repository.IncomingHook) int {
+		return int(a.ID) - int(b.ID)
+	})
+
+	return hooks, nil
+}
+
+// RevokeIncomingHook revokes an incoming hook in memory
+func (m *MemoryRepository) RevokeIncomingHook(ctx context.Context, id uint) (*repository.IncomingHook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hook, ok := m.incomingHooks[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	hook.Revoked = true
+	m.incomingHooks[id] = hook
+
+	return &hook, nil
+}
+
+// AddBot saves the input bot and its bot user in memory
+func (m *MemoryRepository) AddBot(ctx context.Context, bot *repository.Bot) (*repository.Bot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[bot.Username]; ok {
+		return nil, repository.ErrUserExists
+	}
+	if _, ok := m.users[bot.Owner]; !ok {
+		return nil, repository.ErrAuthorNotFound
+	}
+	if m.botTokenExists(bot.TokenHash) {
+		return nil, repository.ErrConflict
+	}
+
+	// the bot user has no password, so nobody logs in as the bot
+	m.users[bot.Username] = repository.User{Username: bot.Username}
+
+	newBot := repository.Bot{
+		Username:  bot.Username,
+		Owner:     bot.Owner,
+		TokenHash: bot.TokenHash,
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	m.bots[newBot.Username] = newBot
+
+	return &newBot, nil
+}
+
+// GetBot retrieves a bot by username from memory
+func (m *MemoryRepository) GetBot(ctx context.Context, username string) (*repository.Bot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	bot, ok := m.bots[username]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	return &bot, nil
+}
+
+// GetBotByToken retrieves a bot by the hash of its API token from memory
+func (m *MemoryRepository) GetBotByToken(ctx context.Context, tokenHash string) (*repository.Bot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, bot := range m.bots {
+		if bot.TokenHash == tokenHash {
+			return &bot, nil
+		}
+	}
+
+	return nil, repository.ErrNotFound
+}
+
+// GetBots retrieves the bots of an owner from memory in the order they are created
+func (m *MemoryRepository) GetBots(ctx context.Context, owner string) ([]*repository.Bot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	bots := make([]*repository.Bot, 0)
+	for _, bot := range m.bots {
+		if bot.Owner == owner {
+			bots = append(bots, &bot)
+		}
+	}
+	slices.SortFunc(bots, func(a, b *repository.Bot) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Username, b.Username)
+	})
+
+	return bots, nil
+}
+
+// SetBotToken replaces the hash of the API token of a bot in memory
+func (m *MemoryRepository) SetBotToken(ctx context.Context, username, tokenHash string) (*repository.Bot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bot, ok := m.bots[username]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	if m.botTokenExists(tokenHash) {
+		return nil, repository.ErrConflict
+	}
+	bot.TokenHash = tokenHash
+	m.bots[username] = bot
+
+	return &bot, nil
+}
+
+// botTokenExists reports whether a bot has the input token hash, the caller holds the lock
+func (m *MemoryRepository) botTokenExists(tokenHash string) bool {
+	for _, bot := range m.bots {
+		if bot.TokenHash == tokenHash {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UpdateRateLimitBucket updates the rate limit bucket of a key in memory, the lock is held during the update
+func (m *MemoryRepository) UpdateRateLimitBucket(ctx context.Context, key string, update func(bucket *repository.RateLimitBucket)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bucket, ok := m.rateLimitBuckets[key]
+	if !ok {
+		bucket = repository.RateLimitBucket{Key: key}
+	}
+	update(&bucket)
+	bucket.Key = key
+	m.rateLimitBuckets[key] = bucket
+
+	return nil
+}
+
+// DeleteFullRateLimitBuckets deletes the rate limit buckets which are full at the input time from memory
+func (m *MemoryRepository) DeleteFullRateLimitBuckets(ctx context.Context, now time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, bucket := range m.rateLimitBuckets {
+		if !bucket.FullAt.After(now) {
+			delete(m.rateLimitBuckets, key)
+		}
+	}
+
+	return nil
+}
+
+// GetLoginThrottle retrieves the login throttle of a key from memory
+func (m *MemoryRepository) GetLoginThrottle(ctx context.Context, key string) (*repository.LoginThrottle, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	throttle, ok := m.loginThrottles[key]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	return &throttle, nil
+}
+
+// UpdateLoginThrottle updates the login throttle of a key in memory, the lock is held during the update
+func (m *MemoryRepository) UpdateLoginThrottle(ctx context.Context, key string, update func(throttle *repository.LoginThrottle)) (*repository.LoginThrottle, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	throttle, ok := m.loginThrottles[key]
+	if !ok {
+		throttle = repository.LoginThrottle{Key: key}
+	}
+	update(&throttle)
+	throttle.Key = key
+	m.loginThrottles[key] = throttle
+
+	return &throttle, nil
+}
+
+// DeleteLoginThrottle deletes the login throttle of a key from memory
+func (m *MemoryRepository) DeleteLoginThrottle(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.loginThrottles, key)
+
+	return nil
+}
+
+// DeleteExpiredLoginThrottles deletes the login throttles which expire before the input time from memory
+func (m *MemoryRepository) DeleteExpiredLoginThrottles(ctx context.Context, now time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, throttle := range m.loginThrottles {
+		if throttle.ExpiresAt.Before(now) {
+			delete(m.loginThrottles, key)
+		}
+	}
+
+	return nil
+}
+
+// AddLockout saves the audit record of a lockout in memory
+func (m *MemoryRepository) AddLockout(ctx context.Context, lockout *repository.Lockout) (*repository.Lockout, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	newLockout := *lockout
+	newLockout.ID = uint(len(m.lockouts) + 1)
+	newLockout.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	m.lockouts = append(m.lockouts, newLockout)
+
+	return &newLockout, nil
+}
+
+// GetLockouts retrieves the latest lockouts from memory, newest first
+func (m *MemoryRepository) GetLockouts(ctx context.Context, limit int) ([]*repository.Lockout, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	lockouts := []*repository.Lockout{}
+	for i := len(m.lockouts) - 1; i >= 0 && len(lockouts) < limit; i-- {
+		lockout := m.lockouts[i]
+		lockouts = append(lockouts, &lockout)
+	}
+
+	return lockouts, nil
+}
+
+// AddWebhook saves the input webhook in memory
+func (m *MemoryRepository) AddWebhook(ctx context.Context, webhook *repository.Webhook) (*repository.Webhook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[webhook.CreatedBy]; !ok {
+		return nil, repository.ErrAuthorNotFound
+	}
+
+	m.lastWebhookID++
+	newWebhook := repository.Webhook{
+		ID:        m.lastWebhookID,
+		URL:       webhook.URL,
+		Secret:    webhook.Secret,
+		Events:    slices.Clone(webhook.Events),
+		CreatedBy: webhook.CreatedBy,
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	m.webhooks[newWebhook.ID] = newWebhook
+
+	return copyWebhook(newWebhook), nil
+}
+
+// copyWebhook returns a copy of the input webhook which shares nothing with it
+func copyWebhook(webhook repository.Webhook) *repository.Webhook {
+	webhook.Events = slices.Clone(webhook.Events)
+
+	return &webhook
+}
+
+// GetWebhook retrieves a webhook by id
+func (m *MemoryRepository) GetWebhook(ctx context.Context, id uint) (*repository.Webhook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	webhook, ok := m.webhooks[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	return copyWebhook(webhook), nil
+}
+
+// GetWebhooks retrieves all webhooks in the order they are registered
+func (m *MemoryRepository) GetWebhooks(ctx context.Context) ([]*repository.Webhook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	webhooks := make([]*repository.Webhook, 0, len(m.webhooks))
+	for _, webhook := range m.webhooks {
+		webhooks = append(webhooks, copyWebhook(webhook))
+	}
+	slices.SortFunc(webhooks, func(a, b *repository.Webhook) int {
+		return int(a.ID) - int(b.ID)
+	})
+
+	return webhooks, nil
+}
+
+// DeleteWebhook deletes a webhook and its delivery attempts
+func (m *MemoryRepository) DeleteWebhook(ctx context.Context, id uint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.webhooks[id]; !ok {
+		return repository.ErrNotFound
+	}
+
+	delete(m.webhooks, id)
+	m.deliveries = slices.DeleteFunc(m.deliveries, func(delivery repository.WebhookDelivery) bool {
+		return delivery.WebhookID == id
+	})
+
+	return nil
+}
+
+// EnableWebhook enables a webhook and resets its failures
+func (m *MemoryRepository) EnableWebhook(ctx context.Context, id uint) (*repository.Webhook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	webhook, ok := m.webhooks[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	webhook.Failures = 0
+	webhook.Disabled = false
+	m.webhooks[id] = webhook
+
+	return copyWebhook(webhook), nil
+}
+
+// RecordWebhookResult resets the failures of a webhook if an event is delivered to it, otherwise
+// increments them and disables the webhook once they reach maxFailures
+func (m *MemoryRepository) RecordWebhookResult(ctx context.Context, id uint, delivered bool, maxFailures int) (*repository.Webhook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	webhook, ok := m.webhooks[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	if delivered {
+		webhook.Failures = 0
+	} else {
+		webhook.Failures++
+		webhook.Disabled = webhook.Disabled || webhook.Failures >= maxFailures
+	}
+	m.webhooks[id] = webhook
+
+	return copyWebhook(webhook), nil
+}
+
+// AddWebhookDelivery saves the input delivery attempt in memory
+func (m *MemoryRepository) AddWebhookDelivery(ctx context.Context, delivery *repository.WebhookDelivery) (*repository.WebhookDelivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.webhooks[delivery.WebhookID]; !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	m.lastDeliveryID++
+	newDelivery := *delivery
+	newDelivery.ID = m.lastDeliveryID
+	newDelivery.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	m.deliveries = append(m.deliveries, newDelivery)
+
+	return &newDelivery, nil
+}
+
+// GetWebhookDeliveries retrieves the latest delivery attempts of a webhook, newest first
+func (m *MemoryRepository) GetWebhookDeliveries(ctx context.Context, webhookID uint, limit int) ([]*repository.WebhookDelivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	deliveries := []*repository.WebhookDelivery{}
+	for i := len(m.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		if m.deliveries[i].WebhookID == webhookID {
+			delivery := m.deliveries[i]
+			deliveries = append(deliveries, &delivery)
+		}
+	}
+
+	return deliveries, nil
+}
+
+// SetTwoFactor saves the TOTP authenticator of a user in memory, replacing the one the user has
+func (m *MemoryRepository) SetTwoFactor(ctx context.Context, twoFactor *repository.TwoFactor) (*repository.TwoFactor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[twoFactor.Username]; !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	newTwoFactor := *twoFactor
+	newTwoFactor.RecoveryCodes = slices.Clone(twoFactor.RecoveryCodes)
+	newTwoFactor.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	m.twoFactors[twoFactor.Username] = newTwoFactor
+
+	return cloneTwoFactor(newTwoFactor), nil
+}
+
+// GetTwoFactor retrieves the TOTP authenticator of a user from memory
+func (m *MemoryRepository) GetTwoFactor(ctx context.Context, username string) (*repository.TwoFactor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	twoFactor, ok := m.twoFactors[username]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	return cloneTwoFactor(twoFactor), nil
+}
+
+// UpdateTwoFactor updates the TOTP authenticator of a user in memory, the lock is held during the update
+func (m *MemoryRepository) UpdateTwoFactor(ctx context.Context, username string, update func(twoFactor *repository.TwoFactor) error) (*repository.TwoFactor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	twoFactor, ok := m.twoFactors[username]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	updated := cloneTwoFactor(twoFactor)
+	if err := update(updated); err != nil {
+		return nil, err
+	}
+	updated.Username, updated.CreatedAt = twoFactor.Username, twoFactor.CreatedAt
+	m.twoFactors[username] = *cloneTwoFactor(*updated)
+
+	return updated, nil
+}
+
+// DeleteTwoFactor deletes the TOTP authenticator of a user from memory
+func (m *MemoryRepository) DeleteTwoFactor(ctx context.Context, username string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.twoFactors[username]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(m.twoFactors, username)
+
+	return nil
+}
+
+// AddPasswordReset saves the input password reset in memory
+func (m *MemoryRepository) AddPasswordReset(ctx context.Context, reset *repository.PasswordReset) (*repository.PasswordReset, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[reset.Username]; !ok {
+		return nil, repository.ErrNotFound
+	}
+	if _, ok := m.passwordResets[reset.TokenHash]; ok {
+		return nil, repository.ErrConflict
+	}
+
+	newReset := *reset
+	newReset.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	m.passwordResets[newReset.TokenHash] = newReset
+
+	return &newReset, nil
+}
+
+// ConsumePasswordReset deletes and returns the password reset of a token hash from memory
+func (m *MemoryRepository) ConsumePasswordReset(ctx context.Context, tokenHash string, now time.Time) (*repository.PasswordReset, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reset, ok := m.passwordResets[tokenHash]
+	if !ok || reset.ExpiresAt.Before(now) {
+		return nil, repository.ErrNotFound
+	}
+	delete(m.passwordResets, tokenHash)
+
+	return &reset, nil
+}
+
+// DeleteExpiredPasswordResets deletes the password resets which expire before the input time from memory
+func (m *MemoryRepository) DeleteExpiredPasswordResets(ctx context.Context, now time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for tokenHash, reset := range m.passwordResets {
+		if reset.ExpiresAt.Before(now) {
+			delete(m.passwordResets, tokenHash)
+		}
+	}
+
+	return nil
+}
+
+// cloneTwoFactor returns a copy of the input authenticator which does not share its recovery codes
+func cloneTwoFactor(twoFactor repository.TwoFactor) *repository.TwoFactor {
+	twoFactor.RecoveryCodes = slices.Clone(twoFactor.RecoveryCodes)
+	return &twoFactor
+}
+
+// AddUser saves the input user in memory
+func (m *MemoryRepository) AddUser(ctx context.Context, user *repository.User) (*repository.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[user.Username]; ok {
+		return nil, repository.ErrUserExists
+	}
+
+	newUser := *user
+	m.users[user.Username] = newUser
+
+	return &newUser, nil
+}
+
+// GetUser retrieves user by username
+func (m *MemoryRepository) GetUser(ctx context.Context, username string) (*repository.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[username]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	return &user, nil
+}
+
+// SetEmail replaces the email address of a user with an unverified one in memory
+func (m *MemoryRepository) SetEmail(ctx context.Context, username, email string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[username]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	user.Email, user.EmailVerified = email, false
+	m.users[username] = user
+
+	return nil
+}
+
+// VerifyEmail marks the email address of a user as verified in memory
+func (m *MemoryRepository) VerifyEmail(ctx context.Context, username, email string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[username]
+	if !ok || email == "" || user.Email != email {
+		return repository.ErrNotFound
+	}
+	user.EmailVerified = true
+	m.users[username] = user
+
+	return nil
+}
+
+// ChangePassword replaces the password of a user, blocks the sessions and deletes the password resets of
+// the user in memory
+func (m *MemoryRepository) ChangePassword(ctx context.Context, username, password string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[username]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	user.Password = password
+	m.users[username] = user
+
+	for id, session := range m.sessions {
+		if session.Username == username {
+			session.IsBlocked = true
+			m.sessions[id] = session
+		}
+	}
+	for tokenHash, reset := range m.passwordResets {
+		if reset.Username == username {
+			delete(m.passwordResets, tokenHash)
+		}
+	}
+
+	return nil
+}
+
+// AddSession saves the input session in memory
+func (m *MemoryRepository) AddSession(ctx context.Context, session *repository.Session) (*repository.Session, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[session.Username]; !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	m.lastSessionID++
+	newSession := *session
+	newSession.ID = m.lastSessionID
+	m.sessions[newSession.ID] = newSession
+
+	return &newSession, nil
+}
+
+// GetSession retrieves a session by id
+func (m *MemoryRepository) GetSession(ctx context.Context, id uint) (*repository.Session, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	session, ok := m.sessions[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	return &session, nil
+}
+
+// GetSessionByRefreshToken retrieves the session of a refresh token by the hash of the token
+func (m *MemoryRepository) GetSessionByRefreshToken(ctx context.Context, refreshTokenHash string) (*repository.Session, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, session := range m.sessions {
+		if session.RefreshToken == refreshTokenHash {
+			return &session, nil
+		}
+	}
+
+	return nil, repository.ErrNotFound
+}
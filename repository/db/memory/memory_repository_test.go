@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"Chat-Server/repository"
+	"Chat-Server/repository/repositorytest"
+	"testing"
+)
+
+// TestMemoryRepository runs the repository conformance tests against MemoryRepository
+func TestMemoryRepository(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.Repository {
+		return NewMemoryRepository()
+	})
+}
@@ -0,0 +1,332 @@
+// Package migrate applies the versioned sql migrations of the repository backends. migrations are
+// embedded into the binary as pairs of up and down sql files named <version>_<name>.<up|down>.sql,
+// one directory per dialect, and the applied versions are recorded in the schema_migrations table
+package migrate
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// errors returned by Migrator
+var (
+	ErrUnknownVersion    = errors.New("database schema version is unknown to this server")
+	ErrPendingMigrations = errors.New("database schema has pending migrations")
+)
+
+// Dialect is the sql dialect of a database
+type Dialect string
+
+// dialects with embedded migrations
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+//go:embed migrations
+var migrations embed.FS
+
+// migrationFileName matches the name of a migration file
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a versioned change of the database schema
+type Migration struct {
+	Version uint
+	Name    string
+	up      string
+	down    string
+}
+
+// MigrationStatus is a migration and whether it is applied to the database
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies migrations to a database
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration // sorted by version
+}
+
+// New returns a Migrator applying the embedded migrations of the input dialect to the input database
+func New(db *sql.DB, dialect Dialect) (*Migrator, error) {
+	dir, err := fs.Sub(migrations, path.Join("migrations", string(dialect)))
+	if err != nil {
+		return nil, err
+	}
+
+	return newMigrator(db, dialect, dir)
+}
+
+// newMigrator returns a Migrator applying the migrations in the input directory to the input database
+func newMigrator(db *sql.DB, dialect Dialect, dir fs.FS) (*Migrator, error) {
+	migrations, err := loadMigrations(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(migrations) == 0 {
+		return nil, fmt.Errorf("no migrations found for dialect %q", dialect)
+	}
+
+	m := &Migrator{
+		db:         db,
+		dialect:    dialect,
+		migrations: migrations,
+	}
+
+	if err := m.createVersionTable(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// loadMigrations reads the migrations in the input directory, every version needs both its up and down file
+func loadMigrations(dir fs.FS) ([]Migration, error) {
+	files, err := fs.ReadDir(dir, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[uint]*Migration)
+	for _, file := range files {
+		match := migrationFileName.FindStringSubmatch(file.Name())
+		if file.IsDir() || match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", file.Name())
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 0)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("invalid migration version in %q", file.Name())
+		}
+
+		content, err := fs.ReadFile(dir, file.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[uint(version)]
+		if !ok {
+			migration = &Migration{Version: uint(version), Name: match[2]}
+			byVersion[uint(version)] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names %q and %q", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.up = string(content)
+		} else {
+			migration.down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.up == "" || migration.down == "" {
+			return nil, fmt.Errorf("migration %d needs both an up and a down file", migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// createVersionTable creates the table recording the applied migrations if it does not exist
+func (m *Migrator) createVersionTable() error {
+	_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT NOT NULL PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	return err
+}
+
+// Latest returns the version of the latest known migration
+func (m *Migrator) Latest() uint {
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the schema version of the database, the version of its latest applied migration or 0
+func (m *Migrator) Version() (uint, error) {
+	var version sql.NullInt64
+	if err := m.db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, err
+	}
+
+	return uint(version.Int64), nil
+}
+
+// Status returns the known migrations and whether each is applied
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		status[i] = MigrationStatus{Migration: migration, Applied: ok, AppliedAt: appliedAt}
+	}
+
+	return status, nil
+}
+
+// Verify returns ErrUnknownVersion if the database has a migration applied that is unknown to this
+// server, and ErrPendingMigrations if a known migration is not applied yet
+func (m *Migrator) Verify() error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+
+	for version := range applied {
+		if _, ok := m.find(version); !ok {
+			return fmt.Errorf("%w: version %d, latest known version is %d", ErrUnknownVersion, version, m.Latest())
+		}
+	}
+
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			return fmt.Errorf("%w: migration %d_%s is not applied", ErrPendingMigrations, migration.Version, migration.Name)
+		}
+	}
+
+	return nil
+}
+
+// Up applies all pending migrations
+func (m *Migrator) Up() error {
+	return m.To(m.Latest())
+}
+
+// Down reverts the latest applied migration, it does nothing if no migration is applied
+func (m *Migrator) Down() error {
+	version, err := m.Version()
+	if err != nil || version == 0 {
+		return err
+	}
+
+	i, ok := m.find(version)
+	if !ok {
+		return fmt.Errorf("%w: version %d", ErrUnknownVersion, version)
+	}
+
+	var previous uint
+	if i > 0 {
+		previous = m.migrations[i-1].Version
+	}
+
+	return m.To(previous)
+}
+
+// To applies or reverts migrations until the schema is at the input version, 0 reverts all migrations.
+// every migration runs in its own transaction along with the update of the schema_migrations table
+func (m *Migrator) To(version uint) error {
+	if _, ok := m.find(version); !ok && version != 0 {
+		return fmt.Errorf("%w: version %d", ErrUnknownVersion, version)
+	}
+
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	for appliedVersion := range applied {
+		if _, ok := m.find(appliedVersion); !ok {
+			return fmt.Errorf("%w: version %d", ErrUnknownVersion, appliedVersion)
+		}
+	}
+
+	// revert the applied migrations above the target version, latest first
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; ok && migration.Version > version {
+			if err := m.run(migration, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	// apply the pending migrations up to the target version, earliest first
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok && migration.Version <= version {
+			if err := m.run(migration, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// run applies or reverts the input migration and records it in the schema_migrations table
+func (m *Migrator) run(migration Migration, up bool) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	script, record, args := migration.down, "DELETE FROM schema_migrations WHERE version = "+m.bind(1), []any{migration.Version}
+	if up {
+		script = migration.up
+		record = "INSERT INTO schema_migrations (version, applied_at) VALUES (" + m.bind(1) + ", " + m.bind(2) + ")"
+		args = append(args, time.Now().UTC())
+	}
+
+	if _, err := tx.Exec(script); err != nil {
+		return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	if _, err := tx.Exec(record, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// applied returns the versions of the applied migrations and when they were applied
+func (m *Migrator) applied() (map[uint]time.Time, error) {
+	rows, err := m.db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[uint]time.Time)
+	for rows.Next() {
+		var version uint
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// find returns the index of the known migration with the input version
+func (m *Migrator) find(version uint) (int, bool) {
+	i := sort.Search(len(m.migrations), func(i int) bool { return m.migrations[i].Version >= version })
+	return i, i < len(m.migrations) && m.migrations[i].Version == version
+}
+
+// bind returns the placeholder of the n-th query argument in the dialect of the database
+func (m *Migrator) bind(n int) string {
+	if m.dialect == Postgres {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
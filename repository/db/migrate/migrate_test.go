@@ -0,0 +1,171 @@
+package migrate
+
+import (
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+// testMigrations are three migrations of a sqlite database
+var testMigrations = fstest.MapFS{
+	"0001_create_users.up.sql":      {Data: []byte("CREATE TABLE users (username TEXT PRIMARY KEY);")},
+	"0001_create_users.down.sql":    {Data: []byte("DROP TABLE users;")},
+	"0002_create_messages.up.sql":   {Data: []byte("CREATE TABLE messages (id INTEGER PRIMARY KEY, text TEXT);")},
+	"0002_create_messages.down.sql": {Data: []byte("DROP TABLE messages;")},
+	"0003_index_messages.up.sql":    {Data: []byte("CREATE INDEX idx_messages_text ON messages (text);")},
+	"0003_index_messages.down.sql":  {Data: []byte("DROP INDEX idx_messages_text;")},
+}
+
+// newTestDB returns a private in-memory sqlite database
+func newTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// requireTables requires the sqlite database to have exactly the input tables besides schema_migrations
+func requireTables(t *testing.T, db *sql.DB, tables ...string) {
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name != 'schema_migrations' ORDER BY name")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	existing := []string{}
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		existing = append(existing, name)
+	}
+	require.NoError(t, rows.Err())
+	require.ElementsMatch(t, tables, existing)
+}
+
+// TestMigrator tests Migrator
+func TestMigrator(t *testing.T) {
+	t.Run("UpAndDown", func(t *testing.T) {
+		db := newTestDB(t)
+		m, err := newMigrator(db, SQLite, testMigrations)
+		require.NoError(t, err)
+		require.Equal(t, uint(3), m.Latest())
+		require.ErrorIs(t, m.Verify(), ErrPendingMigrations)
+
+		require.NoError(t, m.Up())
+		require.NoError(t, m.Verify())
+		requireTables(t, db, "users", "messages")
+
+		version, err := m.Version()
+		require.NoError(t, err)
+		require.Equal(t, uint(3), version)
+
+		// up is a no-op once all migrations are applied
+		require.NoError(t, m.Up())
+
+		require.NoError(t, m.Down())
+		version, err = m.Version()
+		require.NoError(t, err)
+		require.Equal(t, uint(2), version)
+		require.ErrorIs(t, m.Verify(), ErrPendingMigrations)
+
+		require.NoError(t, m.Down())
+		require.NoError(t, m.Down())
+		requireTables(t, db)
+
+		// down is a no-op once all migrations are reverted
+		require.NoError(t, m.Down())
+		version, err = m.Version()
+		require.NoError(t, err)
+		require.Zero(t, version)
+	})
+	t.Run("To", func(t *testing.T) {
+		db := newTestDB(t)
+		m, err := newMigrator(db, SQLite, testMigrations)
+		require.NoError(t, err)
+
+		require.NoError(t, m.To(1))
+		requireTables(t, db, "users")
+
+		require.NoError(t, m.To(3))
+		requireTables(t, db, "users", "messages")
+
+		require.NoError(t, m.To(0))
+		requireTables(t, db)
+
+		require.ErrorIs(t, m.To(4), ErrUnknownVersion)
+	})
+	t.Run("Status", func(t *testing.T) {
+		db := newTestDB(t)
+		m, err := newMigrator(db, SQLite, testMigrations)
+		require.NoError(t, err)
+		require.NoError(t, m.To(2))
+
+		status, err := m.Status()
+		require.NoError(t, err)
+		require.Len(t, status, 3)
+		for i, migration := range status {
+			require.Equal(t, uint(i+1), migration.Version)
+			require.Equal(t, i < 2, migration.Applied)
+			require.Equal(t, i < 2, !migration.AppliedAt.IsZero())
+		}
+		require.Equal(t, "create_messages", status[1].Name)
+	})
+	t.Run("UnknownVersion", func(t *testing.T) {
+		db := newTestDB(t)
+		m, err := newMigrator(db, SQLite, testMigrations)
+		require.NoError(t, err)
+		require.NoError(t, m.Up())
+
+		// a newer server applied a migration this server does not know
+		_, err = db.Exec("INSERT INTO schema_migrations (version, applied_at) VALUES (4, CURRENT_TIMESTAMP)")
+		require.NoError(t, err)
+
+		require.ErrorIs(t, m.Verify(), ErrUnknownVersion)
+		require.ErrorIs(t, m.Up(), ErrUnknownVersion)
+		require.ErrorIs(t, m.Down(), ErrUnknownVersion)
+	})
+	t.Run("FailedMigrationIsRolledBack", func(t *testing.T) {
+		db := newTestDB(t)
+		migrations := fstest.MapFS{
+			"0001_create_users.up.sql":   testMigrations["0001_create_users.up.sql"],
+			"0001_create_users.down.sql": testMigrations["0001_create_users.down.sql"],
+			"0002_broken.up.sql":         {Data: []byte("CREATE TABLE messages (id INTEGER); CREATE TABLE;")},
+			"0002_broken.down.sql":       {Data: []byte("DROP TABLE messages;")},
+		}
+		m, err := newMigrator(db, SQLite, migrations)
+		require.NoError(t, err)
+
+		require.Error(t, m.Up())
+		requireTables(t, db, "users")
+
+		version, err := m.Version()
+		require.NoError(t, err)
+		require.Equal(t, uint(1), version)
+	})
+	t.Run("InvalidMigrations", func(t *testing.T) {
+		db := newTestDB(t)
+
+		_, err := newMigrator(db, SQLite, fstest.MapFS{
+			"0001_create_users.up.sql": testMigrations["0001_create_users.up.sql"],
+		})
+		require.Error(t, err)
+
+		_, err = newMigrator(db, SQLite, fstest.MapFS{
+			"create_users.up.sql": testMigrations["0001_create_users.up.sql"],
+		})
+		require.Error(t, err)
+	})
+	t.Run("EmbeddedMigrations", func(t *testing.T) {
+		m, err := New(newTestDB(t), SQLite)
+		require.NoError(t, err)
+		require.NoError(t, m.Up())
+		require.NoError(t, m.Verify())
+		require.NoError(t, m.To(0))
+
+		_, err = New(newTestDB(t), Postgres)
+		require.NoError(t, err)
+	})
+}
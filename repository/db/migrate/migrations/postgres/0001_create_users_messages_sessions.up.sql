@@ -0,0 +1,31 @@
+-- the initial schema matches the tables GORM AutoMigrate created before versioned migrations,
+-- IF NOT EXISTS lets databases created by AutoMigrate adopt the migrations
+CREATE TABLE IF NOT EXISTS users (
+    username TEXT NOT NULL,
+    password TEXT NOT NULL,
+    CONSTRAINT users_pkey PRIMARY KEY (username)
+);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id BIGSERIAL NOT NULL,
+    author TEXT NOT NULL,
+    text TEXT NOT NULL,
+    CONSTRAINT messages_pkey PRIMARY KEY (id),
+    CONSTRAINT fk_messages_user FOREIGN KEY (author) REFERENCES users (username)
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id BIGSERIAL NOT NULL,
+    username TEXT NOT NULL,
+    refresh_token TEXT NOT NULL,
+    user_agent TEXT NOT NULL,
+    client_ip TEXT NOT NULL,
+    is_blocked BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    deleted_at TIMESTAMPTZ,
+    CONSTRAINT sessions_pkey PRIMARY KEY (id),
+    CONSTRAINT fk_sessions_user FOREIGN KEY (username) REFERENCES users (username)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_deleted_at ON sessions (deleted_at);
@@ -0,0 +1,6 @@
+DROP INDEX idx_messages_room_id;
+DROP INDEX idx_messages_search_vector;
+
+ALTER TABLE messages DROP COLUMN search_vector;
+ALTER TABLE messages DROP COLUMN created_at;
+ALTER TABLE messages DROP COLUMN room;
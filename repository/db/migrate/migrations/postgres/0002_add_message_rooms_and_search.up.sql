@@ -0,0 +1,8 @@
+-- messages saved before this migration get the time of the migration as their creation time
+ALTER TABLE messages ADD COLUMN room TEXT NOT NULL DEFAULT 'general';
+ALTER TABLE messages ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE messages ADD COLUMN search_vector TSVECTOR
+    GENERATED ALWAYS AS (to_tsvector('english', text)) STORED;
+
+CREATE INDEX idx_messages_search_vector ON messages USING GIN (search_vector);
+CREATE INDEX idx_messages_room_id ON messages (room, id);
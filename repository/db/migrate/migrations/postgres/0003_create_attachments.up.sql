@@ -0,0 +1,17 @@
+-- attachments are uploaded before the message they are attached to is sent, message_id is set
+-- when the message is saved
+CREATE TABLE attachments (
+    id TEXT NOT NULL,
+    owner TEXT NOT NULL,
+    message_id BIGINT,
+    filename TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    checksum TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT attachments_pkey PRIMARY KEY (id),
+    CONSTRAINT fk_attachments_user FOREIGN KEY (owner) REFERENCES users (username),
+    CONSTRAINT fk_attachments_message FOREIGN KEY (message_id) REFERENCES messages (id)
+);
+
+CREATE INDEX idx_attachments_message_id ON attachments (message_id);
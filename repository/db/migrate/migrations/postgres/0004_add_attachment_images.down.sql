@@ -0,0 +1,6 @@
+ALTER TABLE attachments DROP COLUMN thumbnail_size;
+ALTER TABLE attachments DROP COLUMN thumbnail_height;
+ALTER TABLE attachments DROP COLUMN thumbnail_width;
+ALTER TABLE attachments DROP COLUMN thumbnail_content_type;
+ALTER TABLE attachments DROP COLUMN height;
+ALTER TABLE attachments DROP COLUMN width;
@@ -0,0 +1,8 @@
+-- width and height are 0 for files which are not images, thumbnail_content_type is empty until the
+-- thumbnail of an image is generated
+ALTER TABLE attachments ADD COLUMN width INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE attachments ADD COLUMN height INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE attachments ADD COLUMN thumbnail_content_type TEXT NOT NULL DEFAULT '';
+ALTER TABLE attachments ADD COLUMN thumbnail_width INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE attachments ADD COLUMN thumbnail_height INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE attachments ADD COLUMN thumbnail_size BIGINT NOT NULL DEFAULT 0;
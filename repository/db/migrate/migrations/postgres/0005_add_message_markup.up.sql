@@ -0,0 +1,4 @@
+-- rendered is the sanitized html of the text and entities the json array of its mentions, links and code
+-- spans. messages saved before keep an empty rendered text and are rendered when they are loaded
+ALTER TABLE messages ADD COLUMN rendered TEXT NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN entities TEXT NOT NULL DEFAULT '[]';
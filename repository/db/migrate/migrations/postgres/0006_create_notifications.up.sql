@@ -0,0 +1,13 @@
+-- a notification is created for every user mentioned in a message when the message is saved
+CREATE TABLE notifications (
+    id BIGSERIAL NOT NULL,
+    username TEXT NOT NULL,
+    message_id BIGINT NOT NULL,
+    is_read BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT notifications_pkey PRIMARY KEY (id),
+    CONSTRAINT fk_notifications_user FOREIGN KEY (username) REFERENCES users (username),
+    CONSTRAINT fk_notifications_message FOREIGN KEY (message_id) REFERENCES messages (id)
+);
+
+CREATE INDEX idx_notifications_username_id ON notifications (username, id);
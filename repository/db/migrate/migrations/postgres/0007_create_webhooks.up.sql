@@ -0,0 +1,30 @@
+-- webhooks are registered by admins, events holds a json array of the subscribed event types
+CREATE TABLE webhooks (
+    id BIGSERIAL NOT NULL,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT NOT NULL,
+    created_by TEXT NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    disabled BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT webhooks_pkey PRIMARY KEY (id),
+    CONSTRAINT fk_webhooks_user FOREIGN KEY (created_by) REFERENCES users (username)
+);
+
+-- every attempt to deliver an event to a webhook is recorded
+CREATE TABLE webhook_deliveries (
+    id BIGSERIAL NOT NULL,
+    webhook_id BIGINT NOT NULL,
+    event_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    attempt INTEGER NOT NULL,
+    status_code INTEGER NOT NULL,
+    error TEXT NOT NULL,
+    duration_ms BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT webhook_deliveries_pkey PRIMARY KEY (id),
+    CONSTRAINT fk_webhook_deliveries_webhook FOREIGN KEY (webhook_id) REFERENCES webhooks (id)
+);
+
+CREATE INDEX idx_webhook_deliveries_webhook_id_id ON webhook_deliveries (webhook_id, id);
@@ -0,0 +1 @@
+DROP TABLE incoming_hooks;
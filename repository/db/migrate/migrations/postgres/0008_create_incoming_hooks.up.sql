@@ -0,0 +1,15 @@
+-- incoming hooks post messages as their bot users, only the hash of the token of their url is stored
+CREATE TABLE incoming_hooks (
+    id BIGSERIAL NOT NULL,
+    token_hash TEXT NOT NULL,
+    name TEXT NOT NULL,
+    username TEXT NOT NULL,
+    created_by TEXT NOT NULL,
+    revoked BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT incoming_hooks_pkey PRIMARY KEY (id),
+    CONSTRAINT fk_incoming_hooks_bot FOREIGN KEY (username) REFERENCES users (username),
+    CONSTRAINT fk_incoming_hooks_user FOREIGN KEY (created_by) REFERENCES users (username)
+);
+
+CREATE UNIQUE INDEX idx_incoming_hooks_token_hash ON incoming_hooks (token_hash);
@@ -0,0 +1,13 @@
+-- bots are users owned by a human user, they authenticate with an API token of which only the hash is stored
+CREATE TABLE bots (
+    username TEXT NOT NULL,
+    owner TEXT NOT NULL,
+    token_hash TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT bots_pkey PRIMARY KEY (username),
+    CONSTRAINT fk_bots_user FOREIGN KEY (username) REFERENCES users (username),
+    CONSTRAINT fk_bots_owner FOREIGN KEY (owner) REFERENCES users (username)
+);
+
+CREATE UNIQUE INDEX idx_bots_token_hash ON bots (token_hash);
+CREATE INDEX idx_bots_owner ON bots (owner);
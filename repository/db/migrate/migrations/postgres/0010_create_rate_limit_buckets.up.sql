@@ -0,0 +1,10 @@
+-- token buckets of the rate limited keys, shared by the instances of the server
+CREATE TABLE rate_limit_buckets (
+    key TEXT NOT NULL,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    full_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT rate_limit_buckets_pkey PRIMARY KEY (key)
+);
+
+CREATE INDEX idx_rate_limit_buckets_full_at ON rate_limit_buckets (full_at);
@@ -0,0 +1,23 @@
+-- consecutive failed logins of the usernames and the client addresses, shared by the instances of the server
+CREATE TABLE login_throttles (
+    key TEXT NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT login_throttles_pkey PRIMARY KEY (key)
+);
+
+CREATE INDEX idx_login_throttles_expires_at ON login_throttles (expires_at);
+
+-- audit records of the lockouts, the username is not a foreign key since unknown usernames are locked out too
+CREATE TABLE lockouts (
+    id BIGSERIAL NOT NULL,
+    scope TEXT NOT NULL,
+    username TEXT NOT NULL,
+    client_ip TEXT NOT NULL,
+    failures INTEGER NOT NULL,
+    locked_until TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT lockouts_pkey PRIMARY KEY (id)
+);
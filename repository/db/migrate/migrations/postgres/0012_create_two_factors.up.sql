@@ -0,0 +1,11 @@
+-- TOTP authenticators of the users, recovery_codes holds a json array of the hashes of the unused recovery codes
+CREATE TABLE two_factors (
+    username TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT false,
+    recovery_codes TEXT NOT NULL,
+    last_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT two_factors_pkey PRIMARY KEY (username),
+    CONSTRAINT fk_two_factors_user FOREIGN KEY (username) REFERENCES users (username)
+);
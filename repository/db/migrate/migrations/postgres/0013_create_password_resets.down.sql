@@ -0,0 +1,3 @@
+DROP INDEX idx_sessions_username;
+DROP INDEX idx_sessions_refresh_token;
+DROP TABLE password_resets;
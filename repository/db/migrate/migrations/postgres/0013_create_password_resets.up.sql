@@ -0,0 +1,16 @@
+-- pending resets of the passwords of the users, only the hashes of the reset tokens are stored
+CREATE TABLE password_resets (
+    token_hash TEXT NOT NULL,
+    username TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT password_resets_pkey PRIMARY KEY (token_hash),
+    CONSTRAINT fk_password_resets_user FOREIGN KEY (username) REFERENCES users (username)
+);
+
+CREATE INDEX idx_password_resets_username ON password_resets (username);
+CREATE INDEX idx_password_resets_expires_at ON password_resets (expires_at);
+
+-- the sessions are looked up by the hashes of their refresh tokens
+CREATE INDEX idx_sessions_refresh_token ON sessions (refresh_token);
+CREATE INDEX idx_sessions_username ON sessions (username);
@@ -0,0 +1,3 @@
+-- optional email addresses of the users, empty for the users without one
+ALTER TABLE users ADD COLUMN email TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT false;
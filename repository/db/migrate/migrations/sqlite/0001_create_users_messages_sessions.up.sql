@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS users (
+    username TEXT NOT NULL PRIMARY KEY,
+    password TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    author TEXT NOT NULL,
+    text TEXT NOT NULL,
+    CONSTRAINT fk_messages_user FOREIGN KEY (author) REFERENCES users (username)
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    username TEXT NOT NULL,
+    refresh_token TEXT NOT NULL,
+    user_agent TEXT NOT NULL,
+    client_ip TEXT NOT NULL,
+    is_blocked NUMERIC NOT NULL DEFAULT false,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    deleted_at DATETIME,
+    CONSTRAINT fk_sessions_user FOREIGN KEY (username) REFERENCES users (username)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_deleted_at ON sessions (deleted_at);
@@ -0,0 +1,4 @@
+DROP INDEX idx_messages_room_id;
+
+ALTER TABLE messages DROP COLUMN created_at;
+ALTER TABLE messages DROP COLUMN room;
@@ -0,0 +1,7 @@
+-- sqlite cannot add a column defaulting to the current time, messages saved before this migration
+-- get the time of the migration as their creation time and new messages are saved with theirs
+ALTER TABLE messages ADD COLUMN room TEXT NOT NULL DEFAULT 'general';
+ALTER TABLE messages ADD COLUMN created_at DATETIME;
+UPDATE messages SET created_at = CURRENT_TIMESTAMP;
+
+CREATE INDEX idx_messages_room_id ON messages (room, id);
@@ -0,0 +1,2 @@
+ALTER TABLE messages DROP COLUMN entities;
+ALTER TABLE messages DROP COLUMN rendered;
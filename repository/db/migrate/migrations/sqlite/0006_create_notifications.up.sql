@@ -0,0 +1,12 @@
+-- a notification is created for every user mentioned in a message when the message is saved
+CREATE TABLE notifications (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    username TEXT NOT NULL,
+    message_id INTEGER NOT NULL,
+    is_read NUMERIC NOT NULL DEFAULT false,
+    created_at DATETIME NOT NULL,
+    CONSTRAINT fk_notifications_user FOREIGN KEY (username) REFERENCES users (username),
+    CONSTRAINT fk_notifications_message FOREIGN KEY (message_id) REFERENCES messages (id)
+);
+
+CREATE INDEX idx_notifications_username_id ON notifications (username, id);
@@ -0,0 +1,28 @@
+-- webhooks are registered by admins, events holds a json array of the subscribed event types
+CREATE TABLE webhooks (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT NOT NULL,
+    created_by TEXT NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    disabled NUMERIC NOT NULL DEFAULT false,
+    created_at DATETIME NOT NULL,
+    CONSTRAINT fk_webhooks_user FOREIGN KEY (created_by) REFERENCES users (username)
+);
+
+-- every attempt to deliver an event to a webhook is recorded
+CREATE TABLE webhook_deliveries (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    webhook_id INTEGER NOT NULL,
+    event_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    attempt INTEGER NOT NULL,
+    status_code INTEGER NOT NULL,
+    error TEXT NOT NULL,
+    duration_ms INTEGER NOT NULL,
+    created_at DATETIME NOT NULL,
+    CONSTRAINT fk_webhook_deliveries_webhook FOREIGN KEY (webhook_id) REFERENCES webhooks (id)
+);
+
+CREATE INDEX idx_webhook_deliveries_webhook_id_id ON webhook_deliveries (webhook_id, id);
@@ -0,0 +1,14 @@
+-- incoming hooks post messages as their bot users, only the hash of the token of their url is stored
+CREATE TABLE incoming_hooks (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    token_hash TEXT NOT NULL,
+    name TEXT NOT NULL,
+    username TEXT NOT NULL,
+    created_by TEXT NOT NULL,
+    revoked NUMERIC NOT NULL DEFAULT false,
+    created_at DATETIME NOT NULL,
+    CONSTRAINT fk_incoming_hooks_bot FOREIGN KEY (username) REFERENCES users (username),
+    CONSTRAINT fk_incoming_hooks_user FOREIGN KEY (created_by) REFERENCES users (username)
+);
+
+CREATE UNIQUE INDEX idx_incoming_hooks_token_hash ON incoming_hooks (token_hash);
@@ -0,0 +1,9 @@
+-- token buckets of the rate limited keys, shared by the instances of the server
+CREATE TABLE rate_limit_buckets (
+    key TEXT NOT NULL PRIMARY KEY,
+    tokens REAL NOT NULL,
+    updated_at DATETIME NOT NULL,
+    full_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_rate_limit_buckets_full_at ON rate_limit_buckets (full_at);
@@ -0,0 +1,10 @@
+-- TOTP authenticators of the users, recovery_codes holds a json array of the hashes of the unused recovery codes
+CREATE TABLE two_factors (
+    username TEXT NOT NULL PRIMARY KEY,
+    secret TEXT NOT NULL,
+    enabled NUMERIC NOT NULL DEFAULT false,
+    recovery_codes TEXT NOT NULL,
+    last_step INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL,
+    CONSTRAINT fk_two_factors_user FOREIGN KEY (username) REFERENCES users (username)
+);
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Attachment represents a file attached to a message, MessageID is nil until the file is attached
+type Attachment struct {
+	ID          string `gorm:"column:id;primaryKey"`
+	Owner       string `gorm:"column:owner;not null"`
+	MessageID   *uint  `gorm:"column:message_id"`
+	Filename    string `gorm:"column:filename;not null"`
+	ContentType string `gorm:"column:content_type;not null"`
+	Size        int64  `gorm:"column:size;not null"`
+	Checksum    string `gorm:"column:checksum;not null"`
+	Width       int    `gorm:"column:width;not null"`
+	Height      int    `gorm:"column:height;not null"`
+
+	// thumbnail of an image, ThumbnailContentType is empty if there is no thumbnail
+	ThumbnailContentType string `gorm:"column:thumbnail_content_type;not null"`
+	ThumbnailWidth       int    `gorm:"column:thumbnail_width;not null"`
+	ThumbnailHeight      int    `gorm:"column:thumbnail_height;not null"`
+	ThumbnailSize        int64  `gorm:"column:thumbnail_size;not null"`
+
+	CreatedAt time.Time `gorm:"column:created_at;not null"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Bot represents a bot user owned by a human user in the database
+type Bot struct {
+	Username  string    `gorm:"column:username;primaryKey"`
+	Owner     string    `gorm:"column:owner;not null"`
+	TokenHash string    `gorm:"column:token_hash;not null"`
+	CreatedAt time.Time `gorm:"column:created_at;not null"`
+}
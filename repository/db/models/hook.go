@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// IncomingHook represents an integration posting messages through its secret url
+type IncomingHook struct {
+	ID        uint      `gorm:"column:id;primaryKey"`
+	TokenHash string    `gorm:"column:token_hash;not null"`
+	Name      string    `gorm:"column:name;not null"`
+	Username  string    `gorm:"column:username;not null"`
+	CreatedBy string    `gorm:"column:created_by;not null"`
+	Revoked   bool      `gorm:"column:revoked;default:false;not null"`
+	CreatedAt time.Time `gorm:"column:created_at;not null"`
+}
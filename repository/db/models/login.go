@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// LoginThrottle represents the consecutive failed logins of a username or of a client address in the database
+type LoginThrottle struct {
+	Key           string    `gorm:"column:key;primaryKey"`
+	Failures      int       `gorm:"column:failures;default:0;not null"`
+	LastFailureAt time.Time `gorm:"column:last_failure_at;not null"`
+	LockedUntil   time.Time `gorm:"column:locked_until;not null"`
+	ExpiresAt     time.Time `gorm:"column:expires_at;not null"`
+}
+
+// Lockout represents the audit record of a username or a client address locked out after failed logins
+type Lockout struct {
+	ID          uint      `gorm:"column:id;primaryKey"`
+	Scope       string    `gorm:"column:scope;not null"`
+	Username    string    `gorm:"column:username;not null"`
+	ClientIP    string    `gorm:"column:client_ip;not null"`
+	Failures    int       `gorm:"column:failures;not null"`
+	LockedUntil time.Time `gorm:"column:locked_until;not null"`
+	CreatedAt   time.Time `gorm:"column:created_at;not null"`
+}
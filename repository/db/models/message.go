@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Message represents a message in the chat server
+type Message struct {
+	ID          uint         `gorm:"column:id;primaryKey"`
+	Author      string       `gorm:"column:author;not null"`
+	Text        string       `gorm:"column:text;not null"`
+	Rendered    string       `gorm:"column:rendered;not null"`
+	Entities    []Entity     `gorm:"column:entities;serializer:json;not null"`
+	Room        string       `gorm:"column:room;not null"`
+	CreatedAt   time.Time    `gorm:"column:created_at;not null"`
+	User        User         `gorm:"foreignKey:Author;references:Username"`
+	Attachments []Attachment `gorm:"foreignKey:MessageID"`
+
+	// Notifications of the mentioned users, only loaded when the message is saved
+	Notifications []Notification `gorm:"foreignKey:MessageID"`
+}
+
+// Entity represents a mention, link or code span of the text of a message, the entities of a message are
+// stored as a json array
+type Entity struct {
+	Type   string `json:"type"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	Value  string `json:"value"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Notification represents a notification of a user mentioned in a message
+type Notification struct {
+	ID        uint      `gorm:"column:id;primaryKey"`
+	Username  string    `gorm:"column:username;not null"`
+	MessageID uint      `gorm:"column:message_id;not null"`
+	IsRead    bool      `gorm:"column:is_read;default:false;not null"`
+	CreatedAt time.Time `gorm:"column:created_at;not null"`
+	Message   Message   `gorm:"foreignKey:MessageID"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// PasswordReset represents a pending reset of the password of a user in the database
+type PasswordReset struct {
+	TokenHash string    `gorm:"column:token_hash;primaryKey"`
+	Username  string    `gorm:"column:username;not null"`
+	CreatedAt time.Time `gorm:"column:created_at;not null"`
+	ExpiresAt time.Time `gorm:"column:expires_at;not null"`
+}
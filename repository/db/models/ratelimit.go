@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// RateLimitBucket represents the token bucket of a rate limited key in the database
+type RateLimitBucket struct {
+	Key       string    `gorm:"column:key;primaryKey"`
+	Tokens    float64   `gorm:"column:tokens;not null"`
+	UpdatedAt time.Time `gorm:"column:updated_at;not null;autoUpdateTime:false"`
+	FullAt    time.Time `gorm:"column:full_at;not null"`
+}
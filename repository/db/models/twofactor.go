@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// TwoFactor represents the TOTP authenticator of a user in the database
+type TwoFactor struct {
+	Username      string    `gorm:"column:username;primaryKey"`
+	Secret        string    `gorm:"column:secret;not null"`
+	Enabled       bool      `gorm:"column:enabled;default:false;not null"`
+	RecoveryCodes []string  `gorm:"column:recovery_codes;serializer:json;not null"`
+	LastStep      int64     `gorm:"column:last_step;default:0;not null"`
+	CreatedAt     time.Time `gorm:"column:created_at;not null"`
+}
@@ -0,0 +1,9 @@
+package models
+
+// User represents a user in the database
+type User struct {
+	Username      string `gorm:"column:username;primaryKey"`
+	Password      string `gorm:"column:password;not null"`
+	Email         string `gorm:"column:email;default:'';not null"`
+	EmailVerified bool   `gorm:"column:email_verified;default:false;not null"`
+}
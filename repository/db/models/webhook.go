@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Webhook represents an http endpoint receiving the events of the chat
+type Webhook struct {
+	ID        uint      `gorm:"column:id;primaryKey"`
+	URL       string    `gorm:"column:url;not null"`
+	Secret    string    `gorm:"column:secret;not null"`
+	Events    []string  `gorm:"column:events;serializer:json;not null"`
+	CreatedBy string    `gorm:"column:created_by;not null"`
+	Failures  int       `gorm:"column:failures;default:0;not null"`
+	Disabled  bool      `gorm:"column:disabled;default:false;not null"`
+	CreatedAt time.Time `gorm:"column:created_at;not null"`
+}
+
+// WebhookDelivery represents an attempt to deliver an event to a webhook
+type WebhookDelivery struct {
+	ID         uint      `gorm:"column:id;primaryKey"`
+	WebhookID  uint      `gorm:"column:webhook_id;not null"`
+	EventID    string    `gorm:"column:event_id;not null"`
+	EventType  string    `gorm:"column:event_type;not null"`
+	Attempt    int       `gorm:"column:attempt;not null"`
+	StatusCode int       `gorm:"column:status_code;not null"`
+	Error      string    `gorm:"column:error;not null"`
+	DurationMs int64     `gorm:"column:duration_ms;not null"`
+	CreatedAt  time.Time `gorm:"column:created_at;not null"`
+}
@@ -0,0 +1,112 @@
+package postgres
+
+import (
+	"Chat-Server/repository"
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"net"
+	"strings"
+)
+
+// postgres error codes
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+// translateError maps the errors of postgres and gorm to the errors of the repository package,
+// the original error is wrapped along with the repository error
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	// a canceled operation is reported as is, the caller gave up on it. an operation over its
+	// deadline is reported as unavailable since the database did not answer in time, and as
+	// uncertain since it may have been committed before the client gave up on it
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w: %w", repository.ErrUnavailable, repository.ErrUncertain, err)
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %w", repository.ErrNotFound, err)
+	}
+	if isTransient(err) {
+		if isUncertain(err) {
+			return fmt.Errorf("%w: %w: %w", repository.ErrUnavailable, repository.ErrUncertain, err)
+		}
+		return fmt.Errorf("%w: %w", repository.ErrUnavailable, err)
+	}
+
+	var pgError *pgconn.PgError
+	if !errors.As(err, &pgError) {
+		return err
+	}
+
+	switch pgError.Code {
+	case uniqueViolation:
+		if pgError.ConstraintName == "users_pkey" {
+			return fmt.Errorf("%w: %w", repository.ErrUserExists, err)
+		}
+		return fmt.Errorf("%w: %w", repository.ErrConflict, err)
+	case foreignKeyViolation:
+		switch pgError.ConstraintName {
+		case "fk_messages_user", "fk_attachments_user", "fk_webhooks_user", "fk_incoming_hooks_user", "fk_bots_owner":
+			return fmt.Errorf("%w: %w", repository.ErrAuthorNotFound, err)
+		case "fk_sessions_user", "fk_webhook_deliveries_webhook", "fk_two_factors_user",
+			"fk_password_resets_user":
+			return fmt.Errorf("%w: %w", repository.ErrNotFound, err)
+		}
+		return fmt.Errorf("%w: %w", repository.ErrConflict, err)
+	}
+
+	return err
+}
+
+// isTransient reports whether the operation which returned the input error may succeed if retried,
+// connection failures, serialization failures, deadlocks and server shutdowns are transient
+func isTransient(err error) bool {
+	var pgError *pgconn.PgError
+	if errors.As(err, &pgError) {
+		switch {
+		case strings.HasPrefix(pgError.Code, "08"): // connection exception
+			return true
+		case pgError.Code == "40001", pgError.Code == "40P01": // serialization failure, deadlock detected
+			return true
+		case pgError.Code == "53300": // too many connections
+			return true
+		case strings.HasPrefix(pgError.Code, "57P"): // admin shutdown, crash shutdown, cannot connect now
+			return true
+		}
+		return false
+	}
+
+	var netError net.Error
+	return errors.Is(err, sqldriver.ErrBadConn) ||
+		pgconn.SafeToRetry(err) ||
+		pgconn.Timeout(err) ||
+		errors.As(err, &netError)
+}
+
+// isUncertain reports whether the operation which returned the input transient error may have been applied,
+// the connection failed without the server reporting an error after the operation may have been sent. an
+// operation whose connection could not be opened was never sent
+func isUncertain(err error) bool {
+	var pgError *pgconn.PgError
+	if errors.As(err, &pgError) {
+		return false
+	}
+	var opError *net.OpError
+	if errors.As(err, &opError) && opError.Op == "dial" {
+		return false
+	}
+
+	return !errors.Is(err, sqldriver.ErrBadConn) && !pgconn.SafeToRetry(err)
+}
@@ -2,26 +2,46 @@ package postgres
 
 import (
 	"Chat-Server/config"
+	"context"
+	"github.com/rs/zerolog/log"
 	"os"
 	"testing"
 )
 
+// postgresRepository is the PostgresRepository of the test database
+var postgresRepository *PostgresRepository
+
 // TestMain performs initializations before tests are run and does cleanups after tests are run
 func TestMain(m *testing.M) {
 	conf := config.GetConfig("config", "json", "../../../config")
 
-	// initialize the singleton instance of PostgresRepository with the address of the test database
-	_ = GetPostgresRepository(conf.TestDatabaseAddress())
+	// connect to the test database
+	var err error
+	postgresRepository, err = NewPostgresRepository(context.Background(), Config{Address: conf.TestDatabaseAddress()})
+	if err != nil {
+		log.Fatal().Err(err).Msg("cannot connect to the test database")
+	}
+
+	// bring the schema of the test database to the latest version
+	migrator, err := postgresRepository.Migrator()
+	if err != nil {
+		log.Fatal().Err(err).Msg("cannot create a migrator of the test database")
+	}
+	if err := migrator.Up(); err != nil {
+		log.Fatal().Err(err).Msg("cannot migrate the test database")
+	}
 
 	code := m.Run()
 
 	cleanupDatabase()
+	postgresRepository.Close()
 
 	os.Exit(code)
 }
 
 // cleanupDatabase removes all records from all tables in the database
 func cleanupDatabase() {
+	postgresRepository.db.Exec("DELETE FROM attachments")
 	postgresRepository.db.Exec("DELETE FROM messages")
 	postgresRepository.db.Exec("DELETE FROM sessions")
 	postgresRepository.db.Exec("DELETE FROM users")
@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"Chat-Server/repository"
+	"context"
+	"errors"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// replicaSet routes reads to the read replicas of the primary database in turn
+type replicaSet struct {
+	replicas []*gorm.DB
+
+	// next is the index of the replica serving the next read
+	next atomic.Uint64
+
+	// recentWrites holds the users who wrote within the read-your-writes window
+	recentWrites *recentWrites
+}
+
+// pick returns the replica serving a read in the input context, or nil if the read needs the primary
+func (r *replicaSet) pick(ctx context.Context) *gorm.DB {
+	if len(r.replicas) == 0 || repository.PrimaryFromContext(ctx) {
+		return nil
+	}
+
+	// replicas may lag behind, a user who just wrote reads from the primary to see its own writes
+	if username, ok := repository.UserFromContext(ctx); ok && r.recentWrites.contains(username) {
+		return nil
+	}
+
+	return r.replicas[(r.next.Add(1)-1)%uint64(len(r.replicas))]
+}
+
+// recentWrites records when users last wrote, users are forgotten once the window is over
+type recentWrites struct {
+	window time.Duration
+
+	mu        sync.Mutex
+	writes    map[string]time.Time
+	lastPrune time.Time
+}
+
+// newRecentWrites returns an empty recentWrites remembering writes for the input window
+func newRecentWrites(window time.Duration) *recentWrites {
+	return &recentWrites{
+		window:    window,
+		writes:    make(map[string]time.Time),
+		lastPrune: time.Now(),
+	}
+}
+
+// record records a write of the input users
+func (w *recentWrites) record(usernames ...string) {
+	if w.window <= 0 {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	for _, username := range usernames {
+		w.writes[username] = now
+	}
+
+	// forget the users whose window is over once per window so the map does not grow forever
+	if now.Sub(w.lastPrune) > w.window {
+		for username, writtenAt := range w.writes {
+			if now.Sub(writtenAt) > w.window {
+				delete(w.writes, username)
+			}
+		}
+		w.lastPrune = now
+	}
+}
+
+// contains reports whether the input user wrote within the window
+func (w *recentWrites) contains(username string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	writtenAt, ok := w.writes[username]
+	return ok && time.Since(writtenAt) <= w.window
+}
+
+// read runs the input read-only query on a replica and falls back to the primary if the replica fails,
+// the query returns repository errors. reads needing the primary run on the primary only
+func (p *PostgresRepository) read(ctx context.Context, query func(db *gorm.DB) error) error {
+	replica := p.replicas.pick(ctx)
+	if replica == nil {
+		return query(p.db.WithContext(ctx))
+	}
+
+	err := query(replica.WithContext(ctx))
+	if err == nil || errors.Is(err, repository.ErrNotFound) || ctx.Err() != nil {
+		return err
+	}
+
+	log.Warn().Err(err).Msg("read replica failed, falling back to primary")
+
+	return query(p.db.WithContext(ctx))
+}
+
+// recordWrite records a write of the input users and of the user of the input context
+func (p *PostgresRepository) recordWrite(ctx context.Context, usernames ...string) {
+	if len(p.replicas.replicas) == 0 {
+		return
+	}
+
+	if username, ok := repository.UserFromContext(ctx); ok {
+		usernames = append(usernames, username)
+	}
+
+	p.replicas.recentWrites.record(usernames...)
+}
@@ -2,98 +2,1464 @@ package postgres
 
 import (
 	"Chat-Server/repository"
-	"Chat-Server/repository/db/postgres/models"
+	"Chat-Server/repository/db/migrate"
+	"Chat-Server/repository/db/models"
+	"context"
+	"errors"
+	"fmt"
 	"github.com/rs/zerolog/log"
 	driver "gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"sync"
+	"gorm.io/gorm/clause"
+	"slices"
+	"time"
 )
 
-// PostgresRepository implements Repository
+// PostgresRepository implements Repository, reads are served by the read replicas if there are any
 type PostgresRepository struct {
-	db *gorm.DB
+	db       *gorm.DB
+	replicas *replicaSet
 }
 
 // ensure PostgresRepository implements Repository interface
-var _ = (*PostgresRepository)(nil)
+var _ repository.Repository = (*PostgresRepository)(nil)
 
-// postgres is the singleton instance of SQLDatabase
-var postgresRepository PostgresRepository
+// Config holds the configurations of a PostgresRepository
+type Config struct {
+	Address         string        // address of the database
+	MaxOpenConns    int           // maximum number of open connections, 0 means unlimited
+	MaxIdleConns    int           // maximum number of idle connections kept in the pool
+	ConnMaxLifetime time.Duration // maximum time a connection is reused, 0 means forever
+	ConnMaxIdleTime time.Duration // maximum time a connection stays idle, 0 means forever
+	ConnectRetries  int           // maximum number of retries of the first connection
+	ConnectBackoff  time.Duration // backoff before the first retry, doubled after each retry
 
-// once is used to ensure the singleton instance is initialize once
-var once sync.Once
+	ReplicaAddresses     []string      // addresses of the read replicas of the database
+	ReadYourWritesWindow time.Duration // time the reads of a user who wrote are served by the primary
+}
+
+// NewPostgresRepository connects to the postgres database of the input configurations and returns a new
+// PostgresRepository. connecting is retried with backoff while the database is unavailable, until the
+// retries are exhausted or the input context is done
+func NewPostgresRepository(ctx context.Context, config Config) (*PostgresRepository, error) {
+	db, err := open(config.Address, config)
+	if err != nil {
+		return nil, err
+	}
+
+	postgresRepository := &PostgresRepository{
+		db: db,
+		replicas: &replicaSet{
+			recentWrites: newRecentWrites(config.ReadYourWritesWindow),
+		},
+	}
+
+	backoff := config.ConnectBackoff
+	for retry := 0; ; retry++ {
+		err = postgresRepository.Ping(ctx)
+		if err == nil {
+			break
+		}
+		if retry >= config.ConnectRetries || !errors.Is(err, repository.ErrUnavailable) {
+			postgresRepository.Close()
+			return nil, err
+		}
+
+		log.Warn().Err(err).Dur("backoff", backoff).Msg("cannot connect to database, retrying")
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			postgresRepository.Close()
+			return nil, ctx.Err()
+		}
+	}
 
-// GetPostgresRepository returns a new PostgresRepository
-func GetPostgresRepository(address string) *PostgresRepository {
-	once.Do(func() {
-		// get a db session
-		db, err := gorm.Open(driver.Open(address))
+	// replicas are not waited for, reads fall back to the primary while a replica is unreachable
+	for _, address := range config.ReplicaAddresses {
+		replica, err := open(address, config)
 		if err != nil {
-			log.Fatal().Err(err).Msg("cannot connect to database")
+			postgresRepository.Close()
+			return nil, err
+		}
+		postgresRepository.replicas.replicas = append(postgresRepository.replicas.replicas, replica)
+
+		if err := ping(ctx, replica); err != nil {
+			log.Warn().Err(err).Msg("cannot connect to read replica")
 		}
+	}
+
+	return postgresRepository, nil
+}
+
+// open returns a connection pool of the database at the input address with the pool settings of the
+// input configurations, connections are opened lazily so the database is not connected to yet
+func open(address string, config Config) (*gorm.DB, error) {
+	db, err := gorm.Open(driver.Open(address), &gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	// connection pool settings
+	sqlDB.SetMaxOpenConns(config.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(config.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(config.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+
+	return db, nil
+}
+
+// ping checks the input database is reachable
+func ping(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	return translateError(sqlDB.PingContext(ctx))
+}
 
-		// migrate models
-		db.AutoMigrate(&models.User{})
-		db.AutoMigrate(&models.Message{})
-		db.AutoMigrate(&models.Session{})
+// Ping checks the primary postgres database is reachable, reads do not need the replicas
+func (p *PostgresRepository) Ping(ctx context.Context) error {
+	return ping(ctx, p.db)
+}
 
-		postgresRepository = PostgresRepository{
-			db: db,
+// Close closes the connections of the primary database and of the replicas
+func (p *PostgresRepository) Close() error {
+	var errs []error
+	for _, db := range append([]*gorm.DB{p.db}, p.replicas.replicas...) {
+		sqlDB, err := db.DB()
+		if err == nil {
+			err = sqlDB.Close()
 		}
-	})
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Migrator returns a migrator of the postgres database schema
+func (p *PostgresRepository) Migrator() (*migrate.Migrator, error) {
+	sqlDB, err := p.db.DB()
+	if err != nil {
+		return nil, err
+	}
 
-	return &postgresRepository
+	return migrate.New(sqlDB, migrate.Postgres)
 }
 
 // AddMessage saves the input message to the postgres database
-func (p *PostgresRepository) AddMessage(message *repository.Message) (*repository.Message, error) {
-	// initialize a message model
-	newMessage := models.Message{
-		Text:   message.Text,
-		Author: message.Author,
+func (p *PostgresRepository) AddMessage(ctx context.Context, message *repository.Message) (*repository.Message, error) {
+	savedMessages, err := p.AddMessages(ctx, []*repository.Message{message})
+	if err != nil {
+		return nil, err
+	}
+
+	return savedMessages[0], nil
+}
+
+// AddMessages saves the input messages to the postgres database with a multi-row insert and attaches
+// their attachments in the same transaction
+func (p *PostgresRepository) AddMessages(ctx context.Context, messages []*repository.Message) ([]*repository.Message, error) {
+	if len(messages) == 0 {
+		return nil, nil
+	}
+
+	// initialize message models
+	newMessages := make([]models.Message, len(messages))
+	createdAt := now()
+	for i, message := range messages {
+		newMessages[i] = models.Message{
+			Text:      message.Text,
+			Rendered:  message.Rendered,
+			Entities:  toModelEntities(message.Entities),
+			Author:    message.Author,
+			Room:      message.RoomName(),
+			CreatedAt: createdAt,
+		}
 	}
 
-	// save the message to the database
-	if err := p.db.Create(&newMessage).Error; err != nil {
+	// save the messages to the database, ids are assigned in the order of the rows
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newMessages).Error; err != nil {
+			return translateError(err)
+		}
+
+		if err := attach(tx, messages, newMessages); err != nil {
+			return err
+		}
+
+		return notify(tx, messages, newMessages)
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	return message, nil
+	savedMessages := make([]*repository.Message, len(newMessages))
+	var writers []string
+	for i, newMessage := range newMessages {
+		savedMessages[i] = toRepositoryMessage(&newMessage)
+		writers = append(writers, newMessage.Author)
+		for _, notification := range newMessage.Notifications {
+			writers = append(writers, notification.Username)
+		}
+	}
+	p.recordWrite(ctx, writers...)
+
+	return savedMessages, nil
+}
+
+// attach attaches the attachments of the input messages to their saved models and loads them into the
+// models, returns repository.ErrConflict if an attachment is not an unattached attachment of the author
+func attach(tx *gorm.DB, messages []*repository.Message, newMessages []models.Message) error {
+	for i, message := range messages {
+		if len(message.Attachments) == 0 {
+			continue
+		}
+
+		ids := make([]string, len(message.Attachments))
+		for j, attachment := range message.Attachments {
+			ids[j] = attachment.ID
+		}
+
+		res := tx.Model(&models.Attachment{}).
+			Where("id IN ? AND owner = ? AND message_id IS NULL", ids, message.Author).
+			Update("message_id", newMessages[i].ID)
+		if res.Error != nil {
+			return translateError(res.Error)
+		}
+		if res.RowsAffected != int64(len(ids)) {
+			return repository.ErrConflict
+		}
+
+		err := orderAttachments(tx).Where("message_id = ?", newMessages[i].ID).Find(&newMessages[i].Attachments).Error
+		if err != nil {
+			return translateError(err)
+		}
+	}
+
+	return nil
+}
+
+// notify creates the notifications of the existing users mentioned in the input messages and loads them
+// into the saved models of the messages
+func notify(tx *gorm.DB, messages []*repository.Message, newMessages []models.Message) error {
+	var mentioned []string
+	for _, message := range messages {
+		mentioned = append(mentioned, repository.Mentions(message)...)
+	}
+	if len(mentioned) == 0 {
+		return nil
+	}
+
+	// mentions of usernames which are not registered are not notified
+	var existing []string
+	if err := tx.Model(&models.User{}).Where("username IN ?", mentioned).Pluck("username", &existing).Error; err != nil {
+		return translateError(err)
+	}
+
+	var notifications []models.Notification
+	for i, message := range messages {
+		for _, username := range repository.Mentions(message) {
+			if slices.Contains(existing, username) {
+				notifications = append(notifications, models.Notification{
+					Username:  username,
+					MessageID: newMessages[i].ID,
+					CreatedAt: newMessages[i].CreatedAt,
+				})
+			}
+		}
+	}
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	if err := tx.Create(&notifications).Error; err != nil {
+		return translateError(err)
+	}
+
+	// the notifications are created in the order of the messages
+	for i, j := 0, 0; i < len(newMessages); i++ {
+		for ; j < len(notifications) && notifications[j].MessageID == newMessages[i].ID; j++ {
+			newMessages[i].Notifications = append(newMessages[i].Notifications, notifications[j])
+		}
+	}
+
+	return nil
+}
+
+// orderAttachments orders the attachments of a message in the order they are uploaded
+func orderAttachments(db *gorm.DB) *gorm.DB {
+	return db.Order("created_at ASC, id ASC")
 }
 
 // GetAllMessages retrieves all messages from the database
-func (p *PostgresRepository) GetAllMessages() (messages []*repository.Message, err error) {
-	err = p.db.
-		Raw("SELECT * FROM messages ORDER BY messages.id ASC").
-		Scan(&messages).Error
+func (p *PostgresRepository) GetAllMessages(ctx context.Context) ([]*repository.Message, error) {
+	var rows []models.Message
+	err := p.read(ctx, func(db *gorm.DB) error {
+		rows = nil
+		return translateError(db.Preload("Attachments", orderAttachments).Order("id ASC").Find(&rows).Error)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]*repository.Message, len(rows))
+	for i := range rows {
+		messages[i] = toRepositoryMessage(&rows[i])
+	}
+
+	return messages, nil
+}
+
+// headlineOptions are the ts_headline options of the snippets of the search results
+var headlineOptions = fmt.Sprintf(
+	"StartSel=\"%s\", StopSel=\"%s\", MaxWords=20, MinWords=8, MaxFragments=2, FragmentDelimiter=\" ... \"",
+	repository.SnippetMatchStart, repository.SnippetMatchEnd,
+)
+
+// SearchMessages retrieves the messages matching the input search with postgres full-text search,
+// newest first. the search text is parsed with websearch_to_tsquery so quoted phrases, "or" and
+// negated words with "-" are supported
+func (p *PostgresRepository) SearchMessages(ctx context.Context, search *repository.MessageSearch) (matches []*repository.MessageMatch, err error) {
+	err = p.read(ctx, func(db *gorm.DB) error {
+		query := db.
+			Table("messages, websearch_to_tsquery('english', ?) query", search.Text).
+			Select(
+				"messages.id, messages.author, messages.text, messages.rendered, messages.entities, messages.room, messages.created_at, "+
+					"ts_headline('english', messages.text, query, ?) AS snippet",
+				headlineOptions,
+			).
+			Where("messages.search_vector @@ query")
+
+		if search.Author != "" {
+			query = query.Where("messages.author = ?", search.Author)
+		}
+		if search.Room != "" {
+			query = query.Where("messages.room = ?", search.Room)
+		}
+		if !search.Since.IsZero() {
+			query = query.Where("messages.created_at >= ?", search.Since)
+		}
+		if !search.Until.IsZero() {
+			query = query.Where("messages.created_at <= ?", search.Until)
+		}
+		if search.BeforeID != 0 {
+			query = query.Where("messages.id < ?", search.BeforeID)
+		}
+
+		var rows []searchRow
+		err := query.Order("messages.id DESC").Limit(search.Limit).Scan(&rows).Error
+		if err != nil {
+			return translateError(err)
+		}
+
+		matches = make([]*repository.MessageMatch, len(rows))
+		for i, row := range rows {
+			matches[i] = row.toMessageMatch()
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// GetNotifications retrieves the notifications matching the input query with their messages, newest first
+func (p *PostgresRepository) GetNotifications(ctx context.Context, query *repository.NotificationQuery) ([]*repository.Notification, error) {
+	var rows []models.Notification
+	err := p.read(ctx, func(db *gorm.DB) error {
+		rows = nil
+		return translateError(db.Scopes(notificationQuery(query)).Find(&rows).Error)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	notifications := make([]*repository.Notification, len(rows))
+	for i := range rows {
+		notifications[i] = toRepositoryNotification(&rows[i], &rows[i].Message)
+	}
+
+	return notifications, nil
+}
+
+// notificationQuery returns the scope selecting the notifications matching the input query
+func notificationQuery(query *repository.NotificationQuery) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Joins("Message").Where("notifications.username = ?", query.Username)
+		if query.UnreadOnly {
+			db = db.Where("notifications.is_read = ?", false)
+		}
+		if query.BeforeID != 0 {
+			db = db.Where("notifications.id < ?", query.BeforeID)
+		}
+
+		return db.Order("notifications.id DESC").Limit(query.Limit)
+	}
+}
+
+// CountUnreadNotifications returns the number of unread notifications of the input user
+func (p *PostgresRepository) CountUnreadNotifications(ctx context.Context, username string) (int64, error) {
+	var count int64
+	err := p.read(ctx, func(db *gorm.DB) error {
+		err := db.Model(&models.Notification{}).
+			Where("username = ? AND is_read = ?", username, false).
+			Count(&count).Error
+		return translateError(err)
+	})
+
+	return count, err
+}
+
+// MarkNotificationsRead marks the notifications of the input user with the input ids as read, all
+// the notifications of the user if there is no id
+func (p *PostgresRepository) MarkNotificationsRead(ctx context.Context, username string, ids []uint) error {
+	query := p.db.WithContext(ctx).Model(&models.Notification{}).Where("username = ?", username)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+
+	if err := query.Update("is_read", true).Error; err != nil {
+		return translateError(err)
+	}
+	p.recordWrite(ctx, username)
+
+	return nil
+}
+
+// AddAttachment saves the input attachment into the postgres database, unattached
+func (p *PostgresRepository) AddAttachment(ctx context.Context, attachment *repository.Attachment) (*repository.Attachment, error) {
+	newAttachment := models.Attachment{
+		ID:          attachment.ID,
+		Owner:       attachment.Owner,
+		Filename:    attachment.Filename,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		Checksum:    attachment.Checksum,
+		Width:       attachment.Width,
+		Height:      attachment.Height,
+		CreatedAt:   now(),
+	}
+
+	if err := p.db.WithContext(ctx).Create(&newAttachment).Error; err != nil {
+		return nil, translateError(err)
+	}
+	p.recordWrite(ctx, newAttachment.Owner)
+
+	return toRepositoryAttachment(&newAttachment), nil
+}
+
+// GetAttachment retrieves an attachment by id from the postgres database
+func (p *PostgresRepository) GetAttachment(ctx context.Context, id string) (attachment *repository.Attachment, err error) {
+	err = p.read(ctx, func(db *gorm.DB) error {
+		var row models.Attachment
+		if err := db.Where("id = ?", id).First(&row).Error; err != nil {
+			return translateError(err)
+		}
+
+		attachment = toRepositoryAttachment(&row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// SetAttachmentThumbnail sets the thumbnail of an attachment in the postgres database
+func (p *PostgresRepository) SetAttachmentThumbnail(ctx context.Context, id string, thumbnail *repository.Thumbnail) (*repository.Attachment, error) {
+	var updated models.Attachment
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Attachment{}).Where("id = ?", id).Updates(map[string]any{
+			"thumbnail_content_type": thumbnail.ContentType,
+			"thumbnail_width":        thumbnail.Width,
+			"thumbnail_height":       thumbnail.Height,
+			"thumbnail_size":         thumbnail.Size,
+		})
+		if res.Error != nil {
+			return translateError(res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return repository.ErrNotFound
+		}
+
+		return translateError(tx.Where("id = ?", id).First(&updated).Error)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p.recordWrite(ctx, updated.Owner)
+
+	return toRepositoryAttachment(&updated), nil
+}
+
+// AddWebhook saves the input webhook into the postgres database
+func (p *PostgresRepository) AddWebhook(ctx context.Context, webhook *repository.Webhook) (*repository.Webhook, error) {
+	newWebhook := models.Webhook{
+		URL:       webhook.URL,
+		Secret:    webhook.Secret,
+		Events:    webhook.Events,
+		CreatedBy: webhook.CreatedBy,
+		CreatedAt: now(),
+	}
+
+	if err := p.db.WithContext(ctx).Create(&newWebhook).Error; err != nil {
+		return nil, translateError(err)
+	}
+	p.recordWrite(ctx)
+
+	return toRepositoryWebhook(&newWebhook), nil
+}
+
+// GetWebhook retrieves a webhook by id from the postgres database
+func (p *PostgresRepository) GetWebhook(ctx context.Context, id uint) (webhook *repository.Webhook, err error) {
+	err = p.read(ctx, func(db *gorm.DB) error {
+		var row models.Webhook
+		if err := db.Where("id = ?", id).First(&row).Error; err != nil {
+			return translateError(err)
+		}
+
+		webhook = toRepositoryWebhook(&row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	return
 }
 
+// GetWebhooks retrieves all webhooks from the postgres database in the order they are registered
+func (p *PostgresRepository) GetWebhooks(ctx context.Context) ([]*repository.Webhook, error) {
+	var rows []models.Webhook
+	err := p.read(ctx, func(db *gorm.DB) error {
+		rows = nil
+		return translateError(db.Order("id ASC").Find(&rows).Error)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks := make([]*repository.Webhook, len(rows))
+	for i := range rows {
+		webhooks[i] = toRepositoryWebhook(&rows[i])
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook deletes a webhook and its delivery attempts from the postgres database
+func (p *PostgresRepository) DeleteWebhook(ctx context.Context, id uint) error {
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", id).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return translateError(err)
+		}
+
+		res := tx.Where("id = ?", id).Delete(&models.Webhook{})
+		if res.Error != nil {
+			return translateError(res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return repository.ErrNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	p.recordWrite(ctx)
+
+	return nil
+}
+
+// EnableWebhook enables a webhook and resets its failures in the postgres database
+func (p *PostgresRepository) EnableWebhook(ctx context.Context, id uint) (*repository.Webhook, error) {
+	return p.updateWebhook(ctx, id, map[string]any{"failures": 0, "disabled": false})
+}
+
+// RecordWebhookResult resets the failures of a webhook if an event is delivered to it, otherwise
+// increments them in the postgres database and disables the webhook once they reach maxFailures
+func (p *PostgresRepository) RecordWebhookResult(ctx context.Context, id uint, delivered bool, maxFailures int) (*repository.Webhook, error) {
+	if delivered {
+		return p.updateWebhook(ctx, id, map[string]any{"failures": 0})
+	}
+
+	return p.updateWebhook(ctx, id, map[string]any{
+		"failures": gorm.Expr("failures + 1"),
+		"disabled": gorm.Expr("disabled OR failures + 1 >= ?", maxFailures),
+	})
+}
+
+// updateWebhook applies the input updates to a webhook and returns the updated webhook
+func (p *PostgresRepository) updateWebhook(ctx context.Context, id uint, updates map[string]any) (*repository.Webhook, error) {
+	var updated models.Webhook
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Webhook{}).Where("id = ?", id).Updates(updates)
+		if res.Error != nil {
+			return translateError(res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return repository.ErrNotFound
+		}
+
+		return translateError(tx.Where("id = ?", id).First(&updated).Error)
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.recordWrite(ctx)
+
+	return toRepositoryWebhook(&updated), nil
+}
+
+// AddWebhookDelivery saves the input delivery attempt into the postgres database
+func (p *PostgresRepository) AddWebhookDelivery(ctx context.Context, delivery *repository.WebhookDelivery) (*repository.WebhookDelivery, error) {
+	newDelivery := models.WebhookDelivery{
+		WebhookID:  delivery.WebhookID,
+		EventID:    delivery.EventID,
+		EventType:  delivery.EventType,
+		Attempt:    delivery.Attempt,
+		StatusCode: delivery.StatusCode,
+		Error:      delivery.Error,
+		DurationMs: delivery.Duration.Milliseconds(),
+		CreatedAt:  now(),
+	}
+
+	if err := p.db.WithContext(ctx).Create(&newDelivery).Error; err != nil {
+		return nil, translateError(err)
+	}
+	p.recordWrite(ctx)
+
+	return toRepositoryWebhookDelivery(&newDelivery), nil
+}
+
+// GetWebhookDeliveries retrieves the latest delivery attempts of a webhook from the postgres database, newest first
+func (p *PostgresRepository) GetWebhookDeliveries(ctx context.Context, webhookID uint, limit int) ([]*repository.WebhookDelivery, error) {
+	var rows []models.WebhookDelivery
+	err := p.read(ctx, func(db *gorm.DB) error {
+		rows = nil
+		return translateError(db.Where("webhook_id = ?", webhookID).Order("id DESC").Limit(limit).Find(&rows).Error)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]*repository.WebhookDelivery, len(rows))
+	for i := range rows {
+		deliveries[i] = toRepositoryWebhookDelivery(&rows[i])
+	}
+
+	return deliveries, nil
+}
+
+// AddIncomingHook saves the input incoming hook and its bot user into the postgres database
+func (p *PostgresRepository) AddIncomingHook(ctx context.Context, hook *repository.IncomingHook) (*repository.IncomingHook, error) {
+	newHook := models.IncomingHook{
+		TokenHash: hook.TokenHash,
+		Name:      hook.Name,
+		Username:  hook.Username,
+		CreatedBy: hook.CreatedBy,
+		CreatedAt: now(),
+	}
+
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// the bot user has no password, so nobody logs in as the bot
+		if err := tx.Create(&models.User{Username: hook.Username}).Error; err != nil {
+			return translateError(err)
+		}
+
+		return translateError(tx.Create(&newHook).Error)
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.recordWrite(ctx, newHook.Username)
+
+	return toRepositoryIncomingHook(&newHook), nil
+}
+
+// GetIncomingHookByToken retrieves an incoming hook by the hash of its token from the postgres database, the
+// primary is read so a revoked hook is rejected on every instance as soon as it is revoked
+func (p *PostgresRepository) GetIncomingHookByToken(ctx context.Context, tokenHash string) (*repository.IncomingHook, error) {
+	var row models.IncomingHook
+	if err := p.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&row).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return toRepositoryIncomingHook(&row), nil
+}
+
+// GetIncomingHooks retrieves all incoming hooks from the postgres database in the order they are created
+func (p *PostgresRepository) GetIncomingHooks(ctx context.Context) ([]*repository.IncomingHook, error) {
+	var rows []models.IncomingHook
+	err := p.read(ctx, func(db *gorm.DB) error {
+		rows = nil
+		return translateError(db.Order("id ASC").Find(&rows).Error)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hooks := make([]*repository.IncomingHook, len(rows))
+	for i := range rows {
+		hooks[i] = toRepositoryIncomingHook(&rows[i])
+	}
+
+	return hooks, nil
+}
+
+// RevokeIncomingHook revokes an incoming hook in the postgres database
+func (p *PostgresRepository) RevokeIncomingHook(ctx context.Context, id uint) (*repository.IncomingHook, error) {
+	var updated models.IncomingHook
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.IncomingHook{}).Where("id = ?", id).Update("revoked", true)
+		if res.Error != nil {
+			return translateError(res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return repository.ErrNotFound
+		}
+
+		return translateError(tx.Where("id = ?", id).First(&updated).Error)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the revocation is read from the primary by the next request of the hook, whoever makes it
+	p.recordWrite(ctx, updated.Username)
+
+	return toRepositoryIncomingHook(&updated), nil
+}
+
+// AddBot saves the input bot and its bot user into the postgres database
+func (p *PostgresRepository) AddBot(ctx context.Context, bot *repository.Bot) (*repository.Bot, error) {
+	newBot := models.Bot{
+		Username:  bot.Username,
+		Owner:     bot.Owner,
+		TokenHash: bot.TokenHash,
+		CreatedAt: now(),
+	}
+
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// the bot user has no password, so nobody logs in as the bot
+		if err := tx.Create(&models.User{Username: bot.Username}).Error; err != nil {
+			return translateError(err)
+		}
+
+		return translateError(tx.Create(&newBot).Error)
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.recordWrite(ctx, newBot.Username)
+
+	return toRepositoryBot(&newBot), nil
+}
+
+// GetBot retrieves a bot by username from the postgres database
+func (p *PostgresRepository) GetBot(ctx context.Context, username string) (bot *repository.Bot, err error) {
+	err = p.read(ctx, func(db *gorm.DB) error {
+		var row models.Bot
+		if err := db.Where("username = ?", username).First(&row).Error; err != nil {
+			return translateError(err)
+		}
+
+		bot = toRepositoryBot(&row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// GetBotByToken retrieves a bot by the hash of its API token from the postgres database, the
+// primary is read so a replaced token is rejected on every instance as soon as it is replaced
+func (p *PostgresRepository) GetBotByToken(ctx context.Context, tokenHash string) (*repository.Bot, error) {
+	var row models.Bot
+	if err := p.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&row).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return toRepositoryBot(&row), nil
+}
+
+// GetBots retrieves the bots of an owner from the postgres database in the order they are created
+func (p *PostgresRepository) GetBots(ctx context.Context, owner string) ([]*repository.Bot, error) {
+	var rows []models.Bot
+	err := p.read(ctx, func(db *gorm.DB) error {
+		rows = nil
+		return translateError(db.Where("owner = ?", owner).Order("created_at ASC, username ASC").Find(&rows).Error)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bots := make([]*repository.Bot, len(rows))
+	for i := range rows {
+		bots[i] = toRepositoryBot(&rows[i])
+	}
+
+	return bots, nil
+}
+
+// SetBotToken replaces the hash of the API token of a bot in the postgres database
+func (p *PostgresRepository) SetBotToken(ctx context.Context, username, tokenHash string) (*repository.Bot, error) {
+	var updated models.Bot
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Bot{}).Where("username = ?", username).Update("token_hash", tokenHash)
+		if res.Error != nil {
+			return translateError(res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return repository.ErrNotFound
+		}
+
+		return translateError(tx.Where("username = ?", username).First(&updated).Error)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the old token is rejected by the next request of the bot, whichever replica serves it
+	p.recordWrite(ctx, username)
+
+	return toRepositoryBot(&updated), nil
+}
+
+// UpdateRateLimitBucket updates the rate limit bucket of a key in the postgres database, the row of the bucket
+// is locked during the update so the updates of the instances of the server do not overlap
+func (p *PostgresRepository) UpdateRateLimitBucket(ctx context.Context, key string, update func(bucket *repository.RateLimitBucket)) error {
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// the row is created first so the concurrent updates of a new bucket wait for each other on its lock
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RateLimitBucket{Key: key})
+		if res.Error != nil {
+			return translateError(res.Error)
+		}
+
+		var row models.RateLimitBucket
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&row).Error
+		if err != nil {
+			return translateError(err)
+		}
+
+		bucket := toRepositoryRateLimitBucket(&row)
+		update(bucket)
+
+		err = tx.Model(&models.RateLimitBucket{}).Where("key = ?", key).Updates(map[string]any{
+			"tokens":     bucket.Tokens,
+			"updated_at": bucket.UpdatedAt,
+			"full_at":    bucket.FullAt,
+		}).Error
+		return translateError(err)
+	})
+}
+
+// DeleteFullRateLimitBuckets deletes the rate limit buckets which are full at the input time from the
+// postgres database
+func (p *PostgresRepository) DeleteFullRateLimitBuckets(ctx context.Context, now time.Time) error {
+	err := p.db.WithContext(ctx).Where("full_at <= ?", now).Delete(&models.RateLimitBucket{}).Error
+	return translateError(err)
+}
+
+// GetLoginThrottle retrieves the login throttle of a key from the postgres database, the primary is read
+// so a lockout applies on every instance as soon as it is saved
+func (p *PostgresRepository) GetLoginThrottle(ctx context.Context, key string) (*repository.LoginThrottle, error) {
+	var row models.LoginThrottle
+
+	if err := p.db.WithContext(ctx).Where("key = ?", key).First(&row).Error; err != nil {
+		return nil, translateError(err)
+	}
+
+	return toRepositoryLoginThrottle(&row), nil
+}
+
+// UpdateLoginThrottle updates the login throttle of a key in the postgres database, the row of the throttle
+// is locked during the update so the failed logins on the instances of the server are all counted
+func (p *PostgresRepository) UpdateLoginThrottle(ctx context.Context, key string, update func(throttle *repository.LoginThrottle)) (*repository.LoginThrottle, error) {
+	var throttle *repository.LoginThrottle
+
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.LoginThrottle{Key: key})
+		if res.Error != nil {
+			return translateError(res.Error)
+		}
+
+		var row models.LoginThrottle
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&row).Error; err != nil {
+			return translateError(err)
+		}
+
+		throttle = toRepositoryLoginThrottle(&row)
+		update(throttle)
+		throttle.Key = key
+
+		err := tx.Model(&models.LoginThrottle{}).Where("key = ?", key).Updates(map[string]any{
+			"failures":        throttle.Failures,
+			"last_failure_at": throttle.LastFailureAt,
+			"locked_until":    throttle.LockedUntil,
+			"expires_at":      throttle.ExpiresAt,
+		}).Error
+		return translateError(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return throttle, nil
+}
+
+// DeleteLoginThrottle deletes the login throttle of a key from the postgres database
+func (p *PostgresRepository) DeleteLoginThrottle(ctx context.Context, key string) error {
+	err := p.db.WithContext(ctx).Where("key = ?", key).Delete(&models.LoginThrottle{}).Error
+	return translateError(err)
+}
+
+// DeleteExpiredLoginThrottles deletes the login throttles which expire before the input time from the
+// postgres database
+func (p *PostgresRepository) DeleteExpiredLoginThrottles(ctx context.Context, now time.Time) error {
+	err := p.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&models.LoginThrottle{}).Error
+	return translateError(err)
+}
+
+// AddLockout saves the audit record of a lockout into the postgres database
+func (p *PostgresRepository) AddLockout(ctx context.Context, lockout *repository.Lockout) (*repository.Lockout, error) {
+	newLockout := models.Lockout{
+		Scope:       lockout.Scope,
+		Username:    lockout.Username,
+		ClientIP:    lockout.ClientIP,
+		Failures:    lockout.Failures,
+		LockedUntil: lockout.LockedUntil,
+		CreatedAt:   now(),
+	}
+
+	if err := p.db.WithContext(ctx).Create(&newLockout).Error; err != nil {
+		return nil, translateError(err)
+	}
+	p.recordWrite(ctx)
+
+	return toRepositoryLockout(&newLockout), nil
+}
+
+// GetLockouts retrieves the latest lockouts from the postgres database, newest first
+func (p *PostgresRepository) GetLockouts(ctx context.Context, limit int) ([]*repository.Lockout, error) {
+	var rows []models.Lockout
+	err := p.read(ctx, func(db *gorm.DB) error {
+		rows = nil
+		return translateError(db.Order("id DESC").Limit(limit).Find(&rows).Error)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	lockouts := make([]*repository.Lockout, len(rows))
+	for i := range rows {
+		lockouts[i] = toRepositoryLockout(&rows[i])
+	}
+
+	return lockouts, nil
+}
+
+// SetTwoFactor saves the TOTP authenticator of a user into the postgres database, replacing the one the user has
+func (p *PostgresRepository) SetTwoFactor(ctx context.Context, twoFactor *repository.TwoFactor) (*repository.TwoFactor, error) {
+	newTwoFactor := models.TwoFactor{
+		Username:      twoFactor.Username,
+		Secret:        twoFactor.Secret,
+		Enabled:       twoFactor.Enabled,
+		RecoveryCodes: twoFactor.RecoveryCodes,
+		LastStep:      twoFactor.LastStep,
+		CreatedAt:     now(),
+	}
+	if newTwoFactor.RecoveryCodes == nil {
+		newTwoFactor.RecoveryCodes = []string{}
+	}
+
+	err := p.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(&newTwoFactor).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	p.recordWrite(ctx, newTwoFactor.Username)
+
+	return toRepositoryTwoFactor(&newTwoFactor), nil
+}
+
+// GetTwoFactor retrieves the TOTP authenticator of a user from the postgres database, the
+// primary is read so an authenticator is required on every instance as soon as it is enabled
+func (p *PostgresRepository) GetTwoFactor(ctx context.Context, username string) (*repository.TwoFactor, error) {
+	var row models.TwoFactor
+	if err := p.db.WithContext(ctx).Where("username = ?", username).First(&row).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return toRepositoryTwoFactor(&row), nil
+}
+
+// UpdateTwoFactor updates the TOTP authenticator of a user in the postgres database, the row of the
+// authenticator is locked during the update so a code is accepted once by all the instances
+func (p *PostgresRepository) UpdateTwoFactor(ctx context.Context, username string, update func(twoFactor *repository.TwoFactor) error) (*repository.TwoFactor, error) {
+	var twoFactor *repository.TwoFactor
+
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var row models.TwoFactor
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("username = ?", username).First(&row).Error; err != nil {
+			return translateError(err)
+		}
+
+		twoFactor = toRepositoryTwoFactor(&row)
+		if err := update(twoFactor); err != nil {
+			return err
+		}
+		twoFactor.Username, twoFactor.CreatedAt = row.Username, row.CreatedAt
+		if twoFactor.RecoveryCodes == nil {
+			twoFactor.RecoveryCodes = []string{}
+		}
+
+		// the columns are selected so the zero values are saved too, the recovery codes are serialized
+		err := tx.Model(&row).Select("secret", "enabled", "recovery_codes", "last_step").Updates(&models.TwoFactor{
+			Secret:        twoFactor.Secret,
+			Enabled:       twoFactor.Enabled,
+			RecoveryCodes: twoFactor.RecoveryCodes,
+			LastStep:      twoFactor.LastStep,
+		}).Error
+		return translateError(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.recordWrite(ctx, username)
+
+	return twoFactor, nil
+}
+
+// DeleteTwoFactor deletes the TOTP authenticator of a user from the postgres database
+func (p *PostgresRepository) DeleteTwoFactor(ctx context.Context, username string) error {
+	res := p.db.WithContext(ctx).Where("username = ?", username).Delete(&models.TwoFactor{})
+	if res.Error != nil {
+		return translateError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+	p.recordWrite(ctx, username)
+
+	return nil
+}
+
+// AddPasswordReset saves the input password reset into the postgres database
+func (p *PostgresRepository) AddPasswordReset(ctx context.Context, reset *repository.PasswordReset) (*repository.PasswordReset, error) {
+	newReset := models.PasswordReset{
+		TokenHash: reset.TokenHash,
+		Username:  reset.Username,
+		CreatedAt: now(),
+		ExpiresAt: reset.ExpiresAt,
+	}
+
+	if err := p.db.WithContext(ctx).Create(&newReset).Error; err != nil {
+		return nil, translateError(err)
+	}
+	p.recordWrite(ctx, newReset.Username)
+
+	return toRepositoryPasswordReset(&newReset), nil
+}
+
+// ConsumePasswordReset deletes and returns the password reset of a token hash from the postgres database,
+// the row of the reset is locked so a token is consumed once by all the instances
+func (p *PostgresRepository) ConsumePasswordReset(ctx context.Context, tokenHash string, now time.Time) (*repository.PasswordReset, error) {
+	var reset models.PasswordReset
+
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ? AND expires_at >= ?", tokenHash, now).
+			First(&reset).Error
+		if err != nil {
+			return translateError(err)
+		}
+
+		return translateError(tx.Where("token_hash = ?", tokenHash).Delete(&models.PasswordReset{}).Error)
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.recordWrite(ctx, reset.Username)
+
+	return toRepositoryPasswordReset(&reset), nil
+}
+
+// DeleteExpiredPasswordResets deletes the password resets which expire before the input time from the postgres database
+func (p *PostgresRepository) DeleteExpiredPasswordResets(ctx context.Context, now time.Time) error {
+	err := p.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&models.PasswordReset{}).Error
+	return translateError(err)
+}
+
 // AddUser saves the input user into the postgres database
-func (p *PostgresRepository) AddUser(user *repository.User) (*repository.User, error) {
+func (p *PostgresRepository) AddUser(ctx context.Context, user *repository.User) (*repository.User, error) {
 	newUser := models.User{
-		Username: user.Username,
-		Password: user.Password,
+		Username:      user.Username,
+		Password:      user.Password,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
 	}
 
-	if err := p.db.Create(&newUser).Error; err != nil {
-		return nil, err
+	if err := p.db.WithContext(ctx).Create(&newUser).Error; err != nil {
+		return nil, translateError(err)
 	}
+	p.recordWrite(ctx, newUser.Username)
 
 	return user, nil
 }
 
 // GetUser retrieves user by username from the postgres database
-func (p *PostgresRepository) GetUser(username string) (user *repository.User, err error) {
-	res := p.db.
-		Model(models.User{}).
-		Where("username = ?", username).
-		Scan(&user)
+func (p *PostgresRepository) GetUser(ctx context.Context, username string) (user *repository.User, err error) {
+	err = p.read(ctx, func(db *gorm.DB) error {
+		res := db.
+			Model(models.User{}).
+			Where("username = ?", username).
+			Scan(&user)
 
-	if res.RowsAffected == 0 {
-		err = gorm.ErrRecordNotFound
+		if res.Error != nil {
+			return translateError(res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return repository.ErrNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return
 }
+
+// SetEmail replaces the email address of a user with an unverified one in the postgres database
+func (p *PostgresRepository) SetEmail(ctx context.Context, username, email string) error {
+	res := p.db.WithContext(ctx).Model(&models.User{}).Where("username = ?", username).
+		Updates(map[string]any{"email": email, "email_verified": false})
+	if res.Error != nil {
+		return translateError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+	p.recordWrite(ctx, username)
+
+	return nil
+}
+
+// VerifyEmail marks the email address of a user as verified in the postgres database, the address is compared
+// in the update so an address replaced meanwhile is not verified
+func (p *PostgresRepository) VerifyEmail(ctx context.Context, username, email string) error {
+	res := p.db.WithContext(ctx).Model(&models.User{}).
+		Where("username = ? AND email = ? AND email <> ''", username, email).
+		Update("email_verified", true)
+	if res.Error != nil {
+		return translateError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+	p.recordWrite(ctx, username)
+
+	return nil
+}
+
+// ChangePassword replaces the password of a user, blocks the sessions and deletes the password resets of
+// the user in the postgres database
+func (p *PostgresRepository) ChangePassword(ctx context.Context, username, password string) error {
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.User{}).Where("username = ?", username).Update("password", password)
+		if res.Error != nil {
+			return translateError(res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return repository.ErrNotFound
+		}
+
+		err := tx.Model(&models.Session{}).Where("username = ?", username).Update("is_blocked", true).Error
+		if err != nil {
+			return translateError(err)
+		}
+
+		return translateError(tx.Where("username = ?", username).Delete(&models.PasswordReset{}).Error)
+	})
+	if err != nil {
+		return err
+	}
+	p.recordWrite(ctx, username)
+
+	return nil
+}
+
+// AddSession saves the input session into the postgres database
+func (p *PostgresRepository) AddSession(ctx context.Context, session *repository.Session) (*repository.Session, error) {
+	newSession := models.Session{
+		UserUsername: session.Username,
+		RefreshToken: session.RefreshToken,
+		UserAgent:    session.UserAgent,
+		ClientIP:     session.ClientIP,
+		IsBlocked:    session.IsBlocked,
+		CreatedAt:    session.CreatedAt,
+		ExpiresAt:    session.ExpiresAt,
+	}
+
+	if err := p.db.WithContext(ctx).Create(&newSession).Error; err != nil {
+		return nil, translateError(err)
+	}
+	p.recordWrite(ctx, newSession.UserUsername)
+
+	return toRepositorySession(&newSession), nil
+}
+
+// GetSession retrieves a session by id from the postgres database
+func (p *PostgresRepository) GetSession(ctx context.Context, id uint) (*repository.Session, error) {
+	var session models.Session
+
+	err := p.read(ctx, func(db *gorm.DB) error {
+		return translateError(db.Where("id = ?", id).First(&session).Error)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toRepositorySession(&session), nil
+}
+
+// GetSessionByRefreshToken retrieves the session of a refresh token by the hash of the token from the postgres
+// database, the primary is read so a blocked session is rejected on every instance as soon as it is blocked
+func (p *PostgresRepository) GetSessionByRefreshToken(ctx context.Context, refreshTokenHash string) (*repository.Session, error) {
+	var session models.Session
+
+	if err := p.db.WithContext(ctx).Where("refresh_token = ?", refreshTokenHash).First(&session).Error; err != nil {
+		return nil, translateError(err)
+	}
+
+	return toRepositorySession(&session), nil
+}
+
+// toRepositoryMessage converts a message model to a repository message
+func toRepositoryMessage(message *models.Message) *repository.Message {
+	repositoryMessage := &repository.Message{
+		ID:        message.ID,
+		Text:      message.Text,
+		Rendered:  message.Rendered,
+		Author:    message.Author,
+		Room:      message.Room,
+		CreatedAt: message.CreatedAt.UTC(),
+	}
+	for _, entity := range message.Entities {
+		repositoryMessage.Entities = append(repositoryMessage.Entities, repository.Entity(entity))
+	}
+	for i := range message.Attachments {
+		repositoryMessage.Attachments = append(repositoryMessage.Attachments, toRepositoryAttachment(&message.Attachments[i]))
+	}
+	for i := range message.Notifications {
+		repositoryMessage.Notifications = append(repositoryMessage.Notifications, toRepositoryNotification(&message.Notifications[i], message))
+	}
+
+	return repositoryMessage
+}
+
+// toRepositoryNotification converts a notification model and the model of its message to a repository notification
+func toRepositoryNotification(notification *models.Notification, message *models.Message) *repository.Notification {
+	return &repository.Notification{
+		ID:        notification.ID,
+		Username:  notification.Username,
+		MessageID: notification.MessageID,
+		Author:    message.Author,
+		Room:      message.Room,
+		Text:      message.Text,
+		Read:      notification.IsRead,
+		CreatedAt: notification.CreatedAt.UTC(),
+	}
+}
+
+// toModelEntities converts repository entities to entity models, never nil so an empty json array is stored
+func toModelEntities(entities []repository.Entity) []models.Entity {
+	modelEntities := make([]models.Entity, len(entities))
+	for i, entity := range entities {
+		modelEntities[i] = models.Entity(entity)
+	}
+
+	return modelEntities
+}
+
+// toRepositoryAttachment converts an attachment model to a repository attachment
+func toRepositoryAttachment(attachment *models.Attachment) *repository.Attachment {
+	repositoryAttachment := &repository.Attachment{
+		ID:          attachment.ID,
+		Owner:       attachment.Owner,
+		Filename:    attachment.Filename,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		Checksum:    attachment.Checksum,
+		Width:       attachment.Width,
+		Height:      attachment.Height,
+		CreatedAt:   attachment.CreatedAt.UTC(),
+	}
+	if attachment.MessageID != nil {
+		repositoryAttachment.MessageID = *attachment.MessageID
+	}
+	if attachment.ThumbnailContentType != "" {
+		repositoryAttachment.Thumbnail = &repository.Thumbnail{
+			ContentType: attachment.ThumbnailContentType,
+			Width:       attachment.ThumbnailWidth,
+			Height:      attachment.ThumbnailHeight,
+			Size:        attachment.ThumbnailSize,
+		}
+	}
+
+	return repositoryAttachment
+}
+
+// searchRow is a row of the results of a message search
+type searchRow struct {
+	models.Message
+	Snippet string `gorm:"column:snippet"`
+}
+
+// toMessageMatch converts a search row to a repository message match
+func (r *searchRow) toMessageMatch() *repository.MessageMatch {
+	return &repository.MessageMatch{
+		Message: *toRepositoryMessage(&r.Message),
+		Snippet: r.Snippet,
+	}
+}
+
+// toRepositoryRateLimitBucket converts a rate limit bucket model to a repository rate limit bucket
+func toRepositoryRateLimitBucket(bucket *models.RateLimitBucket) *repository.RateLimitBucket {
+	return &repository.RateLimitBucket{
+		Key:       bucket.Key,
+		Tokens:    bucket.Tokens,
+		UpdatedAt: bucket.UpdatedAt,
+		FullAt:    bucket.FullAt,
+	}
+}
+
+// toRepositoryLoginThrottle converts a login throttle model to a repository login throttle
+func toRepositoryLoginThrottle(throttle *models.LoginThrottle) *repository.LoginThrottle {
+	return &repository.LoginThrottle{
+		Key:           throttle.Key,
+		Failures:      throttle.Failures,
+		LastFailureAt: throttle.LastFailureAt,
+		LockedUntil:   throttle.LockedUntil,
+		ExpiresAt:     throttle.ExpiresAt,
+	}
+}
+
+// toRepositoryLockout converts a lockout model to a repository lockout
+func toRepositoryLockout(lockout *models.Lockout) *repository.Lockout {
+	return &repository.Lockout{
+		ID:          lockout.ID,
+		Scope:       lockout.Scope,
+		Username:    lockout.Username,
+		ClientIP:    lockout.ClientIP,
+		Failures:    lockout.Failures,
+		LockedUntil: lockout.LockedUntil,
+		CreatedAt:   lockout.CreatedAt,
+	}
+}
+
+// toRepositoryTwoFactor converts a TOTP authenticator model to a repository TOTP authenticator
+func toRepositoryTwoFactor(twoFactor *models.TwoFactor) *repository.TwoFactor {
+	return &repository.TwoFactor{
+		Username:      twoFactor.Username,
+		Secret:        twoFactor.Secret,
+		Enabled:       twoFactor.Enabled,
+		RecoveryCodes: twoFactor.RecoveryCodes,
+		LastStep:      twoFactor.LastStep,
+		CreatedAt:     twoFactor.CreatedAt,
+	}
+}
+
+// toRepositoryPasswordReset converts a password reset model to a repository password reset
+func toRepositoryPasswordReset(reset *models.PasswordReset) *repository.PasswordReset {
+	return &repository.PasswordReset{
+		TokenHash: reset.TokenHash,
+		Username:  reset.Username,
+		CreatedAt: reset.CreatedAt,
+		ExpiresAt: reset.ExpiresAt,
+	}
+}
+
+// toRepositoryBot converts a bot model to a repository bot
+func toRepositoryBot(bot *models.Bot) *repository.Bot {
+	return &repository.Bot{
+		Username:  bot.Username,
+		Owner:     bot.Owner,
+		TokenHash: bot.TokenHash,
+		CreatedAt: bot.CreatedAt.UTC(),
+	}
+}
+
+// toRepositoryIncomingHook converts an incoming hook model to a repository incoming hook
+func toRepositoryIncomingHook(hook *models.IncomingHook) *repository.IncomingHook {
+	return &repository.IncomingHook{
+		ID:        hook.ID,
+		TokenHash: hook.TokenHash,
+		Name:      hook.Name,
+		Username:  hook.Username,
+		CreatedBy: hook.CreatedBy,
+		Revoked:   hook.Revoked,
+		CreatedAt: hook.CreatedAt.UTC(),
+	}
+}
+
+// toRepositoryWebhook converts a webhook model to a repository webhook
+func toRepositoryWebhook(webhook *models.Webhook) *repository.Webhook {
+	return &repository.Webhook{
+		ID:        webhook.ID,
+		URL:       webhook.URL,
+		Secret:    webhook.Secret,
+		Events:    webhook.Events,
+		CreatedBy: webhook.CreatedBy,
+		Failures:  webhook.Failures,
+		Disabled:  webhook.Disabled,
+		CreatedAt: webhook.CreatedAt.UTC(),
+	}
+}
+
+// toRepositoryWebhookDelivery converts a webhook delivery model to a repository webhook delivery
+func toRepositoryWebhookDelivery(delivery *models.WebhookDelivery) *repository.WebhookDelivery {
+	return &repository.WebhookDelivery{
+		ID:         delivery.ID,
+		WebhookID:  delivery.WebhookID,
+		EventID:    delivery.EventID,
+		EventType:  delivery.EventType,
+		Attempt:    delivery.Attempt,
+		StatusCode: delivery.StatusCode,
+		Error:      delivery.Error,
+		Duration:   time.Duration(delivery.DurationMs) * time.Millisecond,
+		CreatedAt:  delivery.CreatedAt.UTC(),
+	}
+}
+
+// now returns the current time with the microsecond precision of postgres timestamps
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// toRepositorySession converts a session model to a repository session
+func toRepositorySession(session *models.Session) *repository.Session {
+	return &repository.Session{
+		ID:           session.ID,
+		Username:     session.UserUsername,
+		RefreshToken: session.RefreshToken,
+		UserAgent:    session.UserAgent,
+		ClientIP:     session.ClientIP,
+		IsBlocked:    session.IsBlocked,
+		CreatedAt:    session.CreatedAt,
+		ExpiresAt:    session.ExpiresAt,
+	}
+}
@@ -0,0 +1,54 @@
+package sqlite
+
+import (
+	"Chat-Server/repository"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+)
+
+// translateError maps the errors of sqlite and gorm to the errors of the repository package, the
+// original error is wrapped along with the repository error. sqlite does not report the name of a
+// violated key constraint, so the caller passes the error of a key violation in its operation,
+// nil reports key violations as repository.ErrConflict
+func translateError(err error, violation error) error {
+	if err == nil {
+		return nil
+	}
+
+	// a canceled operation is reported as is, the caller gave up on it. an operation over its
+	// deadline is reported as unavailable since the database did not answer in time, and as
+	// uncertain since it may have been committed before the client gave up on it
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w: %w", repository.ErrUnavailable, repository.ErrUncertain, err)
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %w", repository.ErrNotFound, err)
+	}
+
+	var sqliteError sqlite3.Error
+	if !errors.As(err, &sqliteError) {
+		return err
+	}
+
+	switch {
+	case sqliteError.Code == sqlite3.ErrBusy, sqliteError.Code == sqlite3.ErrLocked:
+		// the database is locked by another writer
+		return fmt.Errorf("%w: %w", repository.ErrUnavailable, err)
+	case sqliteError.ExtendedCode == sqlite3.ErrConstraintPrimaryKey,
+		sqliteError.ExtendedCode == sqlite3.ErrConstraintUnique,
+		sqliteError.ExtendedCode == sqlite3.ErrConstraintForeignKey:
+		if violation == nil {
+			violation = repository.ErrConflict
+		}
+		return fmt.Errorf("%w: %w", violation, err)
+	}
+
+	return err
+}
@@ -0,0 +1,1258 @@
+package sqlite
+
+import (
+	"Chat-Server/repository"
+	"Chat-Server/repository/db/migrate"
+	"Chat-Server/repository/db/models"
+	"context"
+	driver "gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"slices"
+	"time"
+)
+
+// SQLiteRepository implements Repository
+type SQLiteRepository struct {
+	db *gorm.DB
+}
+
+// ensure SQLiteRepository implements Repository interface
+var _ repository.Repository = (*SQLiteRepository)(nil)
+
+// NewSQLiteRepository opens the sqlite database at the input address and returns a new SQLiteRepository,
+// the address is a file path or ":memory:" for a private in-memory database
+func NewSQLiteRepository(address string) (*SQLiteRepository, error) {
+	db, err := gorm.Open(driver.Open(address))
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	// sqlite allows a single writer at a time, and pragmas and in-memory databases belong to a single connection
+	sqlDB.SetMaxOpenConns(1)
+
+	// sqlite does not enforce foreign keys unless asked to
+	if err := db.Exec("PRAGMA foreign_keys = ON").Error; err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	return &SQLiteRepository{
+		db: db,
+	}, nil
+}
+
+// Close closes the database connections
+func (s *SQLiteRepository) Close() error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.Close()
+}
+
+// Ping checks the sqlite database is reachable
+func (s *SQLiteRepository) Ping(ctx context.Context) error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+
+	return translateError(sqlDB.PingContext(ctx), nil)
+}
+
+// Migrator returns a migrator of the sqlite database schema
+func (s *SQLiteRepository) Migrator() (*migrate.Migrator, error) {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.New(sqlDB, migrate.SQLite)
+}
+
+// AddMessage saves the input message to the sqlite database
+func (s *SQLiteRepository) AddMessage(ctx context.Context, message *repository.Message) (*repository.Message, error) {
+	savedMessages, err := s.AddMessages(ctx, []*repository.Message{message})
+	if err != nil {
+		return nil, err
+	}
+
+	return savedMessages[0], nil
+}
+
+// AddMessages saves the input messages to the sqlite database with a multi-row insert and attaches
+// their attachments in the same transaction
+func (s *SQLiteRepository) AddMessages(ctx context.Context, messages []*repository.Message) ([]*repository.Message, error) {
+	if len(messages) == 0 {
+		return nil, nil
+	}
+
+	newMessages := make([]models.Message, len(messages))
+	createdAt := now()
+	for i, message := range messages {
+		newMessages[i] = models.Message{
+			Text:      message.Text,
+			Rendered:  message.Rendered,
+			Entities:  toModelEntities(message.Entities),
+			Author:    message.Author,
+			Room:      message.RoomName(),
+			CreatedAt: createdAt,
+		}
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newMessages).Error; err != nil {
+			return translateError(err, repository.ErrAuthorNotFound)
+		}
+
+		if err := attach(tx, messages, newMessages); err != nil {
+			return err
+		}
+
+		return notify(tx, messages, newMessages)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	savedMessages := make([]*repository.Message, len(newMessages))
+	for i, newMessage := range newMessages {
+		savedMessages[i] = toRepositoryMessage(&newMessage)
+	}
+
+	return savedMessages, nil
+}
+
+// attach attaches the attachments of the input messages to their saved models and loads them into the
+// models, returns repository.ErrConflict if an attachment is not an unattached attachment of the author
+func attach(tx *gorm.DB, messages []*repository.Message, newMessages []models.Message) error {
+	for i, message := range messages {
+		if len(message.Attachments) == 0 {
+			continue
+		}
+
+		ids := make([]string, len(message.Attachments))
+		for j, attachment := range message.Attachments {
+			ids[j] = attachment.ID
+		}
+
+		res := tx.Model(&models.Attachment{}).
+			Where("id IN ? AND owner = ? AND message_id IS NULL", ids, message.Author).
+			Update("message_id", newMessages[i].ID)
+		if res.Error != nil {
+			return translateError(res.Error, nil)
+		}
+		if res.RowsAffected != int64(len(ids)) {
+			return repository.ErrConflict
+		}
+
+		err := orderAttachments(tx).Where("message_id = ?", newMessages[i].ID).Find(&newMessages[i].Attachments).Error
+		if err != nil {
+			return translateError(err, nil)
+		}
+	}
+
+	return nil
+}
+
+// notify creates the notifications of the existing users mentioned in the input messages and loads them
+// into the saved models of the messages
+func notify(tx *gorm.DB, messages []*repository.Message, newMessages []models.Message) error {
+	var mentioned []string
+	for _, message := range messages {
+		mentioned = append(mentioned, repository.Mentions(message)...)
+	}
+	if len(mentioned) == 0 {
+		return nil
+	}
+
+	// mentions of usernames which are not registered are not notified
+	var existing []string
+	if err := tx.Model(&models.User{}).Where("username IN ?", mentioned).Pluck("username", &existing).Error; err != nil {
+		return translateError(err, nil)
+	}
+
+	var notifications []models.Notification
+	for i, message := range messages {
+		for _, username := range repository.Mentions(message) {
+			if slices.Contains(existing, username) {
+				notifications = append(notifications, models.Notification{
+					Username:  username,
+					MessageID: newMessages[i].ID,
+					CreatedAt: newMessages[i].CreatedAt,
+				})
+			}
+		}
+	}
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	if err := tx.Create(&notifications).Error; err != nil {
+		return translateError(err, nil)
+	}
+
+	// the notifications are created in the order of the messages
+	for i, j := 0, 0; i < len(newMessages); i++ {
+		for ; j < len(notifications) && notifications[j].MessageID == newMessages[i].ID; j++ {
+			newMessages[i].Notifications = append(newMessages[i].Notifications, notifications[j])
+		}
+	}
+
+	return nil
+}
+
+// orderAttachments orders the attachments of a message in the order they are uploaded
+func orderAttachments(db *gorm.DB) *gorm.DB {
+	return db.Order("created_at ASC, id ASC")
+}
+
+// GetAllMessages retrieves all messages from the sqlite database
+func (s *SQLiteRepository) GetAllMessages(ctx context.Context) ([]*repository.Message, error) {
+	var rows []models.Message
+	err := s.db.WithContext(ctx).Preload("Attachments", orderAttachments).Order("id ASC").Find(&rows).Error
+	if err != nil {
+		return nil, translateError(err, nil)
+	}
+
+	messages := make([]*repository.Message, len(rows))
+	for i := range rows {
+		messages[i] = toRepositoryMessage(&rows[i])
+	}
+
+	return messages, nil
+}
+
+// SearchMessages retrieves the messages matching the input search, newest first. sqlite has no
+// full-text index here, so the messages passing the other filters are matched one by one
+func (s *SQLiteRepository) SearchMessages(ctx context.Context, search *repository.MessageSearch) ([]*repository.MessageMatch, error) {
+	terms := repository.SearchTerms(search.Text)
+
+	query := s.db.WithContext(ctx).Model(&models.Message{})
+	if search.Author != "" {
+		query = query.Where("author = ?", search.Author)
+	}
+	if search.Room != "" {
+		query = query.Where("room = ?", search.Room)
+	}
+	if !search.Since.IsZero() {
+		query = query.Where("created_at >= ?", search.Since.UTC())
+	}
+	if !search.Until.IsZero() {
+		query = query.Where("created_at <= ?", search.Until.UTC())
+	}
+	if search.BeforeID != 0 {
+		query = query.Where("id < ?", search.BeforeID)
+	}
+
+	rows, err := query.Order("id DESC").Rows()
+	if err != nil {
+		return nil, translateError(err, nil)
+	}
+	defer rows.Close()
+
+	matches := []*repository.MessageMatch{}
+	for len(matches) < search.Limit && rows.Next() {
+		var message models.Message
+		if err := s.db.ScanRows(rows, &message); err != nil {
+			return nil, translateError(err, nil)
+		}
+
+		if snippet, ok := repository.NaiveMatch(message.Text, terms); ok {
+			matches = append(matches, &repository.MessageMatch{
+				Message: *toRepositoryMessage(&message),
+				Snippet: snippet,
+			})
+		}
+	}
+
+	return matches, translateError(rows.Err(), nil)
+}
+
+// GetNotifications retrieves the notifications matching the input query with their messages, newest first
+func (s *SQLiteRepository) GetNotifications(ctx context.Context, query *repository.NotificationQuery) ([]*repository.Notification, error) {
+	var rows []models.Notification
+	err := s.db.WithContext(ctx).Scopes(notificationQuery(query)).Find(&rows).Error
+	if err != nil {
+		return nil, translateError(err, nil)
+	}
+
+	notifications := make([]*repository.Notification, len(rows))
+	for i := range rows {
+		notifications[i] = toRepositoryNotification(&rows[i], &rows[i].Message)
+	}
+
+	return notifications, nil
+}
+
+// notificationQuery returns the scope selecting the notifications matching the input query
+func notificationQuery(query *repository.NotificationQuery) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Joins("Message").Where("notifications.username = ?", query.Username)
+		if query.UnreadOnly {
+			db = db.Where("notifications.is_read = ?", false)
+		}
+		if query.BeforeID != 0 {
+			db = db.Where("notifications.id < ?", query.BeforeID)
+		}
+
+		return db.Order("notifications.id DESC").Limit(query.Limit)
+	}
+}
+
+// CountUnreadNotifications returns the number of unread notifications of the input user
+func (s *SQLiteRepository) CountUnreadNotifications(ctx context.Context, username string) (int64, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.Notification{}).
+		Where("username = ? AND is_read = ?", username, false).
+		Count(&count).Error
+
+	return count, translateError(err, nil)
+}
+
+// MarkNotificationsRead marks the notifications of the input user with the input ids as read, all
+// the notifications of the user if there is no id
+func (s *SQLiteRepository) MarkNotificationsRead(ctx context.Context, username string, ids []uint) error {
+	query := s.db.WithContext(ctx).Model(&models.Notification{}).Where("username = ?", username)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+
+	return translateError(query.Update("is_read", true).Error, nil)
+}
+
+// AddAttachment saves the input attachment into the sqlite database, unattached
+func (s *SQLiteRepository) AddAttachment(ctx context.Context, attachment *repository.Attachment) (*repository.Attachment, error) {
+	newAttachment := models.Attachment{
+		ID:          attachment.ID,
+		Owner:       attachment.Owner,
+		Filename:    attachment.Filename,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		Checksum:    attachment.Checksum,
+		Width:       attachment.Width,
+		Height:      attachment.Height,
+		CreatedAt:   now(),
+	}
+
+	if err := s.db.WithContext(ctx).Create(&newAttachment).Error; err != nil {
+		return nil, translateError(err, repository.ErrAuthorNotFound)
+	}
+
+	return toRepositoryAttachment(&newAttachment), nil
+}
+
+// GetAttachment retrieves an attachment by id from the sqlite database
+func (s *SQLiteRepository) GetAttachment(ctx context.Context, id string) (*repository.Attachment, error) {
+	var attachment models.Attachment
+
+	if err := s.db.WithContext(ctx).Where("id = ?", id).First(&attachment).Error; err != nil {
+		return nil, translateError(err, nil)
+	}
+
+	return toRepositoryAttachment(&attachment), nil
+}
+
+// SetAttachmentThumbnail sets the thumbnail of an attachment in the sqlite database
+func (s *SQLiteRepository) SetAttachmentThumbnail(ctx context.Context, id string, thumbnail *repository.Thumbnail) (*repository.Attachment, error) {
+	var updated models.Attachment
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Attachment{}).Where("id = ?", id).Updates(map[string]any{
+			"thumbnail_content_type": thumbnail.ContentType,
+			"thumbnail_width":        thumbnail.Width,
+			"thumbnail_height":       thumbnail.Height,
+			"thumbnail_size":         thumbnail.Size,
+		})
+		if res.Error != nil {
+			return translateError(res.Error, nil)
+		}
+		if res.RowsAffected == 0 {
+			return repository.ErrNotFound
+		}
+
+		return translateError(tx.Where("id = ?", id).First(&updated).Error, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toRepositoryAttachment(&updated), nil
+}
+
+// AddWebhook saves the input webhook into the sqlite database
+func (s *SQLiteRepository) AddWebhook(ctx context.Context, webhook *repository.Webhook) (*repository.Webhook, error) {
+	newWebhook := models.Webhook{
+		URL:       webhook.URL,
+		Secret:    webhook.Secret,
+		Events:    webhook.Events,
+		CreatedBy: webhook.CreatedBy,
+		CreatedAt: now(),
+	}
+
+	if err := s.db.WithContext(ctx).Create(&newWebhook).Error; err != nil {
+		return nil, translateError(err, repository.ErrAuthorNotFound)
+	}
+
+	return toRepositoryWebhook(&newWebhook), nil
+}
+
+// GetWebhook retrieves a webhook by id from the sqlite database
+func (s *SQLiteRepository) GetWebhook(ctx context.Context, id uint) (*repository.Webhook, error) {
+	var webhook models.Webhook
+
+	if err := s.db.WithContext(ctx).Where("id = ?", id).First(&webhook).Error; err != nil {
+		return nil, translateError(err, nil)
+	}
+
+	return toRepositoryWebhook(&webhook), nil
+}
+
+// GetWebhooks retrieves all webhooks from the sqlite database in the order they are registered
+func (s *SQLiteRepository) GetWebhooks(ctx context.Context) ([]*repository.Webhook, error) {
+	var rows []models.Webhook
+	if err := s.db.WithContext(ctx).Order("id ASC").Find(&rows).Error; err != nil {
+		return nil, translateError(err, nil)
+	}
+
+	webhooks := make([]*repository.Webhook, len(rows))
+	for i := range rows {
+		webhooks[i] = toRepositoryWebhook(&rows[i])
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook deletes a webhook and its delivery attempts from the sqlite database
+func (s *SQLiteRepository) DeleteWebhook(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", id).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return translateError(err, nil)
+		}
+
+		res := tx.Where("id = ?", id).Delete(&models.Webhook{})
+		if res.Error != nil {
+			return translateError(res.Error, nil)
+		}
+		if res.RowsAffected == 0 {
+			return repository.ErrNotFound
+		}
+
+		return nil
+	})
+}
+
+// EnableWebhook enables a webhook and resets its failures in the sqlite database
+func (s *SQLiteRepository) EnableWebhook(ctx context.Context, id uint) (*repository.Webhook, error) {
+	return s.updateWebhook(ctx, id, map[string]any{"failures": 0, "disabled": false})
+}
+
+// RecordWebhookResult resets the failures of a webhook if an event is delivered to it, otherwise
+// increments them in the sqlite database and disables the webhook once they reach maxFailures
+func (s *SQLiteRepository) RecordWebhookResult(ctx context.Context, id uint, delivered bool, maxFailures int) (*repository.Webhook, error) {
+	if delivered {
+		return s.updateWebhook(ctx, id, map[string]any{"failures": 0})
+	}
+
+	return s.updateWebhook(ctx, id, map[string]any{
+		"failures": gorm.Expr("failures + 1"),
+		"disabled": gorm.Expr("disabled OR failures + 1 >= ?", maxFailures),
+	})
+}
+
+// updateWebhook applies the input updates to a webhook and returns the updated webhook
+func (s *SQLiteRepository) updateWebhook(ctx context.Context, id uint, updates map[string]any) (*repository.Webhook, error) {
+	var updated models.Webhook
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Webhook{}).Where("id = ?", id).Updates(updates)
+		if res.Error != nil {
+			return translateError(res.Error, nil)
+		}
+		if res.RowsAffected == 0 {
+			return repository.ErrNotFound
+		}
+
+		return translateError(tx.Where("id = ?", id).First(&updated).Error, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toRepositoryWebhook(&updated), nil
+}
+
+// AddWebhookDelivery saves the input delivery attempt into the sqlite database
+func (s *SQLiteRepository) AddWebhookDelivery(ctx context.Context, delivery *repository.WebhookDelivery) (*repository.WebhookDelivery, error) {
+	newDelivery := models.WebhookDelivery{
+		WebhookID:  delivery.WebhookID,
+		EventID:    delivery.EventID,
+		EventType:  delivery.EventType,
+		Attempt:    delivery.Attempt,
+		StatusCode: delivery.StatusCode,
+		Error:      delivery.Error,
+		DurationMs: delivery.Duration.Milliseconds(),
+		CreatedAt:  now(),
+	}
+
+	if err := s.db.WithContext(ctx).Create(&newDelivery).Error; err != nil {
+		return nil, translateError(err, repository.ErrNotFound)
+	}
+
+	return toRepositoryWebhookDelivery(&newDelivery), nil
+}
+
+// GetWebhookDeliveries retrieves the latest delivery attempts of a webhook from the sqlite database, newest first
+func (s *SQLiteRepository) GetWebhookDeliveries(ctx context.Context, webhookID uint, limit int) ([]*repository.WebhookDelivery, error) {
+	var rows []models.WebhookDelivery
+	err := s.db.WithContext(ctx).Where("webhook_id = ?", webhookID).Order("id DESC").Limit(limit).Find(&rows).Error
+	if err != nil {
+		return nil, translateError(err, nil)
+	}
+
+	deliveries := make([]*repository.WebhookDelivery, len(rows))
+	for i := range rows {
+		deliveries[i] = toRepositoryWebhookDelivery(&rows[i])
+	}
+
+	return deliveries, nil
+}
+
+// AddIncomingHook saves the input incoming hook and its bot user into the sqlite database
+func (s *SQLiteRepository) AddIncomingHook(ctx context.Context, hook *repository.IncomingHook) (*repository.IncomingHook, error) {
+	newHook := models.IncomingHook{
+		TokenHash: hook.TokenHash,
+		Name:      hook.Name,
+		Username:  hook.Username,
+		CreatedBy: hook.CreatedBy,
+		CreatedAt: now(),
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// the bot user has no password, so nobody logs in as the bot
+		if err := tx.Create(&models.User{Username: hook.Username}).Error; err != nil {
+			return translateError(err, repository.ErrUserExists)
+		}
+
+		return translateError(tx.Create(&newHook).Error, repository.ErrAuthorNotFound)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toRepositoryIncomingHook(&newHook), nil
+}
+
+// GetIncomingHookByToken retrieves an incoming hook by the hash of its token from the sqlite database
+func (s *SQLiteRepository) GetIncomingHookByToken(ctx context.Context, tokenHash string) (*repository.IncomingHook, error) {
+	var hook models.IncomingHook
+
+	if err := s.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&hook).Error; err != nil {
+		return nil, translateError(err, nil)
+	}
+
+	return toRepositoryIncomingHook(&hook), nil
+}
+
+// GetIncomingHooks retrieves all incoming hooks from the sqlite database in the order they are created
+func (s *SQLiteRepository) GetIncomingHooks(ctx context.Context) ([]*repository.IncomingHook, error) {
+	var rows []models.IncomingHook
+	if err := s.db.WithContext(ctx).Order("id ASC").Find(&rows).Error; err != nil {
+		return nil, translateError(err, nil)
+	}
+
+	hooks := make([]*repository.IncomingHook, len(rows))
+	for i := range rows {
+		hooks[i] = toRepositoryIncomingHook(&rows[i])
+	}
+
+	return hooks, nil
+}
+
+// RevokeIncomingHook revokes an incoming hook in the sqlite database
+func (s *SQLiteRepository) RevokeIncomingHook(ctx context.Context, id uint) (*repository.IncomingHook, error) {
+	var updated models.IncomingHook
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.IncomingHook{}).Where("id = ?", id).Update("revoked", true)
+		if res.Error != nil {
+			return translateError(res.Error, nil)
+		}
+		if res.RowsAffected == 0 {
+			return repository.ErrNotFound
+		}
+
+		return translateError(tx.Where("id = ?", id).First(&updated).Error, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toRepositoryIncomingHook(&updated), nil
+}
+
+// AddBot saves the input bot and its bot user into the sqlite database
+func (s *SQLiteRepository) AddBot(ctx context.Context, bot *repository.Bot) (*repository.Bot, error) {
+	newBot := models.Bot{
+		Username:  bot.Username,
+		Owner:     bot.Owner,
+		TokenHash: bot.TokenHash,
+		CreatedAt: now(),
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// the bot user has no password, so nobody logs in as the bot
+		if err := tx.Create(&models.User{Username: bot.Username}).Error; err != nil {
+			return translateError(err, repository.ErrUserExists)
+		}
+
+		return translateError(tx.Create(&newBot).Error, repository.ErrAuthorNotFound)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toRepositoryBot(&newBot), nil
+}
+
+// GetBot retrieves a bot by username from the sqlite database
+func (s *SQLiteRepository) GetBot(ctx context.Context, username string) (*repository.Bot, error) {
+	var bot models.Bot
+
+	if err := s.db.WithContext(ctx).Where("username = ?", username).First(&bot).Error; err != nil {
+		return nil, translateError(err, nil)
+	}
+
+	return toRepositoryBot(&bot), nil
+}
+
+// GetBotByToken retrieves a bot by the hash of its API token from the sqlite database
+func (s *SQLiteRepository) GetBotByToken(ctx context.Context, tokenHash string) (*repository.Bot, error) {
+	var bot models.Bot
+
+	if err := s.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&bot).Error; err != nil {
+		return nil, translateError(err, nil)
+	}
+
+	return toRepositoryBot(&bot), nil
+}
+
+// GetBots retrieves the bots of an owner from the sqlite database in the order they are created
+func (s *SQLiteRepository) GetBots(ctx context.Context, owner string) ([]*repository.Bot, error) {
+	var rows []models.Bot
+	if err := s.db.WithContext(ctx).Where("owner = ?", owner).Order("created_at ASC, username ASC").Find(&rows).Error; err != nil {
+		return nil, translateError(err, nil)
+	}
+
+	bots := make([]*repository.Bot, len(rows))
+	for i := range rows {
+		bots[i] = toRepositoryBot(&rows[i])
+	}
+
+	return bots, nil
+}
+
+// SetBotToken replaces the hash of the API token of a bot in the sqlite database
+func (s *SQLiteRepository) SetBotToken(ctx context.Context, username, tokenHash string) (*repository.Bot, error) {
+	var updated models.Bot
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Bot{}).Where("username = ?", username).Update("token_hash", tokenHash)
+		if res.Error != nil {
+			return translateError(res.Error, nil)
+		}
+		if res.RowsAffected == 0 {
+			return repository.ErrNotFound
+		}
+
+		return translateError(tx.Where("username = ?", username).First(&updated).Error, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toRepositoryBot(&updated), nil
+}
+
+// UpdateRateLimitBucket updates the rate limit bucket of a key in the sqlite database, the single connection
+// of the database serializes the transactions so the updates of a bucket do not overlap
+func (s *SQLiteRepository) UpdateRateLimitBucket(ctx context.Context, key string, update func(bucket *repository.RateLimitBucket)) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RateLimitBucket{Key: key})
+		if res.Error != nil {
+			return translateError(res.Error, nil)
+		}
+
+		var row models.RateLimitBucket
+		if err := tx.Where("key = ?", key).First(&row).Error; err != nil {
+			return translateError(err, nil)
+		}
+
+		bucket := toRepositoryRateLimitBucket(&row)
+		update(bucket)
+
+		err := tx.Model(&models.RateLimitBucket{}).Where("key = ?", key).Updates(map[string]any{
+			"tokens":     bucket.Tokens,
+			"updated_at": bucket.UpdatedAt,
+			"full_at":    bucket.FullAt,
+		}).Error
+		return translateError(err, nil)
+	})
+}
+
+// DeleteFullRateLimitBuckets deletes the rate limit buckets which are full at the input time from the sqlite
+// database
+func (s *SQLiteRepository) DeleteFullRateLimitBuckets(ctx context.Context, now time.Time) error {
+	err := s.db.WithContext(ctx).Where("full_at <= ?", now).Delete(&models.RateLimitBucket{}).Error
+	return translateError(err, nil)
+}
+
+// GetLoginThrottle retrieves the login throttle of a key from the sqlite database
+func (s *SQLiteRepository) GetLoginThrottle(ctx context.Context, key string) (*repository.LoginThrottle, error) {
+	var row models.LoginThrottle
+
+	if err := s.db.WithContext(ctx).Where("key = ?", key).First(&row).Error; err != nil {
+		return nil, translateError(err, nil)
+	}
+
+	return toRepositoryLoginThrottle(&row), nil
+}
+
+// UpdateLoginThrottle updates the login throttle of a key in the sqlite database, the single connection
+// of the database serializes the transactions so the updates of a throttle do not overlap
+func (s *SQLiteRepository) UpdateLoginThrottle(ctx context.Context, key string, update func(throttle *repository.LoginThrottle)) (*repository.LoginThrottle, error) {
+	var throttle *repository.LoginThrottle
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.LoginThrottle{Key: key})
+		if res.Error != nil {
+			return translateError(res.Error, nil)
+		}
+
+		var row models.LoginThrottle
+		if err := tx.Where("key = ?", key).First(&row).Error; err != nil {
+			return translateError(err, nil)
+		}
+
+		throttle = toRepositoryLoginThrottle(&row)
+		update(throttle)
+		throttle.Key = key
+
+		err := tx.Model(&models.LoginThrottle{}).Where("key = ?", key).Updates(map[string]any{
+			"failures":        throttle.Failures,
+			"last_failure_at": throttle.LastFailureAt,
+			"locked_until":    throttle.LockedUntil,
+			"expires_at":      throttle.ExpiresAt,
+		}).Error
+		return translateError(err, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return throttle, nil
+}
+
+// DeleteLoginThrottle deletes the login throttle of a key from the sqlite database
+func (s *SQLiteRepository) DeleteLoginThrottle(ctx context.Context, key string) error {
+	err := s.db.WithContext(ctx).Where("key = ?", key).Delete(&models.LoginThrottle{}).Error
+	return translateError(err, nil)
+}
+
+// DeleteExpiredLoginThrottles deletes the login throttles which expire before the input time from the
+// sqlite database
+func (s *SQLiteRepository) DeleteExpiredLoginThrottles(ctx context.Context, now time.Time) error {
+	err := s.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&models.LoginThrottle{}).Error
+	return translateError(err, nil)
+}
+
+// AddLockout saves the audit record of a lockout into the sqlite database
+func (s *SQLiteRepository) AddLockout(ctx context.Context, lockout *repository.Lockout) (*repository.Lockout, error) {
+	newLockout := models.Lockout{
+		Scope:       lockout.Scope,
+		Username:    lockout.Username,
+		ClientIP:    lockout.ClientIP,
+		Failures:    lockout.Failures,
+		LockedUntil: lockout.LockedUntil,
+		CreatedAt:   now(),
+	}
+
+	if err := s.db.WithContext(ctx).Create(&newLockout).Error; err != nil {
+		return nil, translateError(err, nil)
+	}
+
+	return toRepositoryLockout(&newLockout), nil
+}
+
+// GetLockouts retrieves the latest lockouts from the sqlite database, newest first
+func (s *SQLiteRepository) GetLockouts(ctx context.Context, limit int) ([]*repository.Lockout, error) {
+	var rows []models.Lockout
+	if err := s.db.WithContext(ctx).Order("id DESC").Limit(limit).Find(&rows).Error; err != nil {
+		return nil, translateError(err, nil)
+	}
+
+	lockouts := make([]*repository.Lockout, len(rows))
+	for i := range rows {
+		lockouts[i] = toRepositoryLockout(&rows[i])
+	}
+
+	return lockouts, nil
+}
+
+// SetTwoFactor saves the TOTP authenticator of a user into the sqlite database, replacing the one the user has
+func (s *SQLiteRepository) SetTwoFactor(ctx context.Context, twoFactor *repository.TwoFactor) (*repository.TwoFactor, error) {
+	newTwoFactor := models.TwoFactor{
+		Username:      twoFactor.Username,
+		Secret:        twoFactor.Secret,
+		Enabled:       twoFactor.Enabled,
+		RecoveryCodes: twoFactor.RecoveryCodes,
+		LastStep:      twoFactor.LastStep,
+		CreatedAt:     now(),
+	}
+	if newTwoFactor.RecoveryCodes == nil {
+		newTwoFactor.RecoveryCodes = []string{}
+	}
+
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(&newTwoFactor).Error
+	if err != nil {
+		return nil, translateError(err, repository.ErrNotFound)
+	}
+
+	return toRepositoryTwoFactor(&newTwoFactor), nil
+}
+
+// GetTwoFactor retrieves the TOTP authenticator of a user from the sqlite database
+func (s *SQLiteRepository) GetTwoFactor(ctx context.Context, username string) (*repository.TwoFactor, error) {
+	var twoFactor models.TwoFactor
+
+	if err := s.db.WithContext(ctx).Where("username = ?", username).First(&twoFactor).Error; err != nil {
+		return nil, translateError(err, nil)
+	}
+
+	return toRepositoryTwoFactor(&twoFactor), nil
+}
+
+// UpdateTwoFactor updates the TOTP authenticator of a user in the sqlite database, the single connection of
+// the database serializes the transactions so the updates of an authenticator do not overlap
+func (s *SQLiteRepository) UpdateTwoFactor(ctx context.Context, username string, update func(twoFactor *repository.TwoFactor) error) (*repository.TwoFactor, error) {
+	var twoFactor *repository.TwoFactor
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var row models.TwoFactor
+		if err := tx.Where("username = ?", username).First(&row).Error; err != nil {
+			return translateError(err, nil)
+		}
+
+		twoFactor = toRepositoryTwoFactor(&row)
+		if err := update(twoFactor); err != nil {
+			return err
+		}
+		twoFactor.Username, twoFactor.CreatedAt = row.Username, row.CreatedAt
+		if twoFactor.RecoveryCodes == nil {
+			twoFactor.RecoveryCodes = []string{}
+		}
+
+		// the columns are selected so the zero values are saved too, the recovery codes are serialized
+		err := tx.Model(&row).Select("secret", "enabled", "recovery_codes", "last_step").Updates(&models.TwoFactor{
+			Secret:        twoFactor.Secret,
+			Enabled:       twoFactor.Enabled,
+			RecoveryCodes: twoFactor.RecoveryCodes,
+			LastStep:      twoFactor.LastStep,
+		}).Error
+		return translateError(err, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return twoFactor, nil
+}
+
+// DeleteTwoFactor deletes the TOTP authenticator of a user from the sqlite database
+func (s *SQLiteRepository) DeleteTwoFactor(ctx context.Context, username string) error {
+	res := s.db.WithContext(ctx).Where("username = ?", username).Delete(&models.TwoFactor{})
+	if res.Error != nil {
+		return translateError(res.Error, nil)
+	}
+	if res.RowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+// AddPasswordReset saves the input password reset into the sqlite database
+func (s *SQLiteRepository) AddPasswordReset(ctx context.Context, reset *repository.PasswordReset) (*repository.PasswordReset, error) {
+	newReset := models.PasswordReset{
+		TokenHash: reset.TokenHash,
+		Username:  reset.Username,
+		CreatedAt: now(),
+		ExpiresAt: reset.ExpiresAt,
+	}
+
+	if err := s.db.WithContext(ctx).Create(&newReset).Error; err != nil {
+		return nil, translateError(err, repository.ErrNotFound)
+	}
+
+	return toRepositoryPasswordReset(&newReset), nil
+}
+
+// ConsumePasswordReset deletes and returns the password reset of a token hash from the sqlite database
+func (s *SQLiteRepository) ConsumePasswordReset(ctx context.Context, tokenHash string, now time.Time) (*repository.PasswordReset, error) {
+	var reset models.PasswordReset
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("token_hash = ? AND expires_at >= ?", tokenHash, now).First(&reset).Error; err != nil {
+			return translateError(err, nil)
+		}
+
+		res := tx.Where("token_hash = ?", tokenHash).Delete(&models.PasswordReset{})
+		if res.Error != nil {
+			return translateError(res.Error, nil)
+		}
+		if res.RowsAffected == 0 {
+			return repository.ErrNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toRepositoryPasswordReset(&reset), nil
+}
+
+// DeleteExpiredPasswordResets deletes the password resets which expire before the input time from the sqlite database
+func (s *SQLiteRepository) DeleteExpiredPasswordResets(ctx context.Context, now time.Time) error {
+	err := s.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&models.PasswordReset{}).Error
+	return translateError(err, nil)
+}
+
+// AddUser saves the input user into the sqlite database
+func (s *SQLiteRepository) AddUser(ctx context.Context, user *repository.User) (*repository.User, error) {
+	newUser := models.User{
+		Username:      user.Username,
+		Password:      user.Password,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+	}
+
+	if err := s.db.WithContext(ctx).Create(&newUser).Error; err != nil {
+		return nil, translateError(err, repository.ErrUserExists)
+	}
+
+	return user, nil
+}
+
+// GetUser retrieves user by username from the sqlite database
+func (s *SQLiteRepository) GetUser(ctx context.Context, username string) (*repository.User, error) {
+	var user models.User
+
+	if err := s.db.WithContext(ctx).Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, translateError(err, nil)
+	}
+
+	return &repository.User{
+		Username:      user.Username,
+		Password:      user.Password,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+	}, nil
+}
+
+// SetEmail replaces the email address of a user with an unverified one in the sqlite database
+func (s *SQLiteRepository) SetEmail(ctx context.Context, username, email string) error {
+	res := s.db.WithContext(ctx).Model(&models.User{}).Where("username = ?", username).
+		Updates(map[string]any{"email": email, "email_verified": false})
+	if res.Error != nil {
+		return translateError(res.Error, nil)
+	}
+	if res.RowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// VerifyEmail marks the email address of a user as verified in the sqlite database, the address is compared
+// in the update so an address replaced meanwhile is not verified
+func (s *SQLiteRepository) VerifyEmail(ctx context.Context, username, email string) error {
+	res := s.db.WithContext(ctx).Model(&models.User{}).
+		Where("username = ? AND email = ? AND email <> ''", username, email).
+		Update("email_verified", true)
+	if res.Error != nil {
+		return translateError(res.Error, nil)
+	}
+	if res.RowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// ChangePassword replaces the password of a user, blocks the sessions and deletes the password resets of
+// the user in the sqlite database
+func (s *SQLiteRepository) ChangePassword(ctx context.Context, username, password string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.User{}).Where("username = ?", username).Update("password", password)
+		if res.Error != nil {
+			return translateError(res.Error, nil)
+		}
+		if res.RowsAffected == 0 {
+			return repository.ErrNotFound
+		}
+
+		err := tx.Model(&models.Session{}).Where("username = ?", username).Update("is_blocked", true).Error
+		if err != nil {
+			return translateError(err, nil)
+		}
+
+		err = tx.Where("username = ?", username).Delete(&models.PasswordReset{}).Error
+		return translateError(err, nil)
+	})
+}
+
+// AddSession saves the input session into the sqlite database
+func (s *SQLiteRepository) AddSession(ctx context.Context, session *repository.Session) (*repository.Session, error) {
+	newSession := models.Session{
+		UserUsername: session.Username,
+		RefreshToken: session.RefreshToken,
+		UserAgent:    session.UserAgent,
+		ClientIP:     session.ClientIP,
+		IsBlocked:    session.IsBlocked,
+		CreatedAt:    session.CreatedAt,
+		ExpiresAt:    session.ExpiresAt,
+	}
+
+	if err := s.db.WithContext(ctx).Create(&newSession).Error; err != nil {
+		return nil, translateError(err, repository.ErrNotFound)
+	}
+
+	return toRepositorySession(&newSession), nil
+}
+
+// GetSession retrieves a session by id from the sqlite database
+func (s *SQLiteRepository) GetSession(ctx context.Context, id uint) (*repository.Session, error) {
+	var session models.Session
+
+	if err := s.db.WithContext(ctx).Where("id = ?", id).First(&session).Error; err != nil {
+		return nil, translateError(err, nil)
+	}
+
+	return toRepositorySession(&session), nil
+}
+
+// GetSessionByRefreshToken retrieves the session of a refresh token by the hash of the token from the sqlite database
+func (s *SQLiteRepository) GetSessionByRefreshToken(ctx context.Context, refreshTokenHash string) (*repository.Session, error) {
+	var session models.Session
+
+	if err := s.db.WithContext(ctx).Where("refresh_token = ?", refreshTokenHash).First(&session).Error; err != nil {
+		return nil, translateError(err, nil)
+	}
+
+	return toRepositorySession(&session), nil
+}
+
+// toRepositoryMessage converts a message model to a repository message
+func toRepositoryMessage(message *models.Message) *repository.Message {
+	repositoryMessage := &repository.Message{
+		ID:        message.ID,
+		Text:      message.Text,
+		Rendered:  message.Rendered,
+		Author:    message.Author,
+		Room:      message.Room,
+		CreatedAt: message.CreatedAt.UTC(),
+	}
+	for _, entity := range message.Entities {
+		repositoryMessage.Entities = append(repositoryMessage.Entities, repository.Entity(entity))
+	}
+	for i := range message.Attachments {
+		repositoryMessage.Attachments = append(repositoryMessage.Attachments, toRepositoryAttachment(&message.Attachments[i]))
+	}
+	for i := range message.Notifications {
+		repositoryMessage.Notifications = append(repositoryMessage.Notifications, toRepositoryNotification(&message.Notifications[i], message))
+	}
+
+	return repositoryMessage
+}
+
+// toRepositoryNotification converts a notification model and the model of its message to a repository notification
+func toRepositoryNotification(notification *models.Notification, message *models.Message) *repository.Notification {
+	return &repository.Notification{
+		ID:        notification.ID,
+		Username:  notification.Username,
+		MessageID: notification.MessageID,
+		Author:    message.Author,
+		Room:      message.Room,
+		Text:      message.Text,
+		Read:      notification.IsRead,
+		CreatedAt: notification.CreatedAt.UTC(),
+	}
+}
+
+// toModelEntities converts repository entities to entity models, never nil so an empty json array is stored
+func toModelEntities(entities []repository.Entity) []models.Entity {
+	modelEntities := make([]models.Entity, len(entities))
+	for i, entity := range entities {
+		modelEntities[i] = models.Entity(entity)
+	}
+
+	return modelEntities
+}
+
+// toRepositoryAttachment converts an attachment model to a repository attachment
+func toRepositoryAttachment(attachment *models.Attachment) *repository.Attachment {
+	repositoryAttachment := &repository.Attachment{
+		ID:          attachment.ID,
+		Owner:       attachment.Owner,
+		Filename:    attachment.Filename,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		Checksum:    attachment.Checksum,
+		Width:       attachment.Width,
+		Height:      attachment.Height,
+		CreatedAt:   attachment.CreatedAt.UTC(),
+	}
+	if attachment.MessageID != nil {
+		repositoryAttachment.MessageID = *attachment.MessageID
+	}
+	if attachment.ThumbnailContentType != "" {
+		repositoryAttachment.Thumbnail = &repository.Thumbnail{
+			ContentType: attachment.ThumbnailContentType,
+			Width:       attachment.ThumbnailWidth,
+			Height:      attachment.ThumbnailHeight,
+			Size:        attachment.ThumbnailSize,
+		}
+	}
+
+	return repositoryAttachment
+}
+
+// toRepositoryRateLimitBucket converts a rate limit bucket model to a repository rate limit bucket
+func toRepositoryRateLimitBucket(bucket *models.RateLimitBucket) *repository.RateLimitBucket {
+	return &repository.RateLimitBucket{
+		Key:       bucket.Key,
+		Tokens:    bucket.Tokens,
+		UpdatedAt: bucket.UpdatedAt,
+		FullAt:    bucket.FullAt,
+	}
+}
+
+// toRepositoryLoginThrottle converts a login throttle model to a repository login throttle
+func toRepositoryLoginThrottle(throttle *models.LoginThrottle) *repository.LoginThrottle {
+	return &repository.LoginThrottle{
+		Key:           throttle.Key,
+		Failures:      throttle.Failures,
+		LastFailureAt: throttle.LastFailureAt,
+		LockedUntil:   throttle.LockedUntil,
+		ExpiresAt:     throttle.ExpiresAt,
+	}
+}
+
+// toRepositoryLockout converts a lockout model to a repository lockout
+func toRepositoryLockout(lockout *models.Lockout) *repository.Lockout {
+	return &repository.Lockout{
+		ID:          lockout.ID,
+		Scope:       lockout.Scope,
+		Username:    lockout.Username,
+		ClientIP:    lockout.ClientIP,
+		Failures:    lockout.Failures,
+		LockedUntil: lockout.LockedUntil,
+		CreatedAt:   lockout.CreatedAt,
+	}
+}
+
+// toRepositoryTwoFactor converts a TOTP authenticator model to a repository TOTP authenticator
+func toRepositoryTwoFactor(twoFactor *models.TwoFactor) *repository.TwoFactor {
+	return &repository.TwoFactor{
+		Username:      twoFactor.Username,
+		Secret:        twoFactor.Secret,
+		Enabled:       twoFactor.Enabled,
+		RecoveryCodes: twoFactor.RecoveryCodes,
+		LastStep:      twoFactor.LastStep,
+		CreatedAt:     twoFactor.CreatedAt,
+	}
+}
+
+// toRepositoryPasswordReset converts a password reset model to a repository password reset
+func toRepositoryPasswordReset(reset *models.PasswordReset) *repository.PasswordReset {
+	return &repository.PasswordReset{
+		TokenHash: reset.TokenHash,
+		Username:  reset.Username,
+		CreatedAt: reset.CreatedAt,
+		ExpiresAt: reset.ExpiresAt,
+	}
+}
+
+// toRepositoryBot converts a bot model to a repository bot
+func toRepositoryBot(bot *models.Bot) *repository.Bot {
+	return &repository.Bot{
+		Username:  bot.Username,
+		Owner:     bot.Owner,
+		TokenHash: bot.TokenHash,
+		CreatedAt: bot.CreatedAt.UTC(),
+	}
+}
+
+// toRepositoryIncomingHook converts an incoming hook model to a repository incoming hook
+func toRepositoryIncomingHook(hook *models.IncomingHook) *repository.IncomingHook {
+	return &repository.IncomingHook{
+		ID:        hook.ID,
+		TokenHash: hook.TokenHash,
+		Name:      hook.Name,
+		Username:  hook.Username,
+		CreatedBy: hook.CreatedBy,
+		Revoked:   hook.Revoked,
+		CreatedAt: hook.CreatedAt.UTC(),
+	}
+}
+
+// toRepositoryWebhook converts a webhook model to a repository webhook
+func toRepositoryWebhook(webhook *models.Webhook) *repository.Webhook {
+	return &repository.Webhook{
+		ID:        webhook.ID,
+		URL:       webhook.URL,
+		Secret:    webhook.Secret,
+		Events:    webhook.Events,
+		CreatedBy: webhook.CreatedBy,
+		Failures:  webhook.Failures,
+		Disabled:  webhook.Disabled,
+		CreatedAt: webhook.CreatedAt.UTC(),
+	}
+}
+
+// toRepositoryWebhookDelivery converts a webhook delivery model to a repository webhook delivery
+func toRepositoryWebhookDelivery(delivery *models.WebhookDelivery) *repository.WebhookDelivery {
+	return &repository.WebhookDelivery{
+		ID:         delivery.ID,
+		WebhookID:  delivery.WebhookID,
+		EventID:    delivery.EventID,
+		EventType:  delivery.EventType,
+		Attempt:    delivery.Attempt,
+		StatusCode: delivery.StatusCode,
+		Error:      delivery.Error,
+		Duration:   time.Duration(delivery.DurationMs) * time.Millisecond,
+		CreatedAt:  delivery.CreatedAt.UTC(),
+	}
+}
+
+// now returns the current time, with the microsecond precision of the other backends
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// toRepositorySession converts a session model to a repository session
+func toRepositorySession(session *models.Session) *repository.Session {
+	return &repository.Session{
+		ID:           session.ID,
+		Username:     session.UserUsername,
+		RefreshToken: session.RefreshToken,
+		UserAgent:    session.UserAgent,
+		ClientIP:     session.ClientIP,
+		IsBlocked:    session.IsBlocked,
+		CreatedAt:    session.CreatedAt,
+		ExpiresAt:    session.ExpiresAt,
+	}
+}
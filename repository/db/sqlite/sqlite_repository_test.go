@@ -0,0 +1,28 @@
+package sqlite
+
+import (
+	"Chat-Server/repository"
+	"Chat-Server/repository/repositorytest"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// newTestRepository returns a SQLiteRepository backed by a private in-memory database
+func newTestRepository(t *testing.T) *SQLiteRepository {
+	sqliteRepository, err := NewSQLiteRepository(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { sqliteRepository.Close() })
+
+	migrator, err := sqliteRepository.Migrator()
+	require.NoError(t, err)
+	require.NoError(t, migrator.Up())
+
+	return sqliteRepository
+}
+
+// TestSQLiteRepository runs the repository conformance tests against SQLiteRepository
+func TestSQLiteRepository(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.Repository {
+		return newTestRepository(t)
+	})
+}
@@ -0,0 +1,22 @@
+package repository
+
+import "errors"
+
+// errors returned by the repositories, every backend maps its own errors to these so the
+// business layer does not depend on any database or driver. the original error of the
+// backend is wrapped along with these errors
+var (
+	// ErrUserExists is returned when adding a user whose username is already taken
+	ErrUserExists = errors.New("user already exists")
+	// ErrNotFound is returned when the requested record does not exist
+	ErrNotFound = errors.New("record not found")
+	// ErrAuthorNotFound is returned when adding a message whose author does not exist
+	ErrAuthorNotFound = errors.New("author not found")
+	// ErrConflict is returned when a record conflicts with an existing one
+	ErrConflict = errors.New("conflicting record")
+	// ErrUnavailable is returned on transient failures, the operation may succeed if retried
+	ErrUnavailable = errors.New("repository unavailable")
+	// ErrUncertain is returned along with ErrUnavailable when the operation may have been applied anyway, e.g.
+	// when its deadline passed while the database was committing it. retrying such a write may apply it twice
+	ErrUncertain = errors.New("outcome unknown")
+)
@@ -0,0 +1,23 @@
+package repository
+
+import "time"
+
+// IncomingHook represents an integration posting messages into the chat through its secret url, e.g. a
+// CI or alerting service. the messages of a hook are authored by its bot user
+type IncomingHook struct {
+	// ID of the hook, assigned when the hook is saved
+	ID uint
+	// TokenHash is the hex encoded sha256 hash of the secret token of the url of the hook, the token
+	// itself is never stored
+	TokenHash string
+	// Name describes the integration of the hook
+	Name string
+	// Username of the bot user the messages of the hook are posted as, the user is created with the hook
+	Username string
+	// CreatedBy is the username of the admin who created the hook
+	CreatedBy string
+	// Revoked reports whether the hook is revoked, revoked hooks cannot post messages anymore
+	Revoked bool
+	// CreatedAt is the time the hook is created
+	CreatedAt time.Time
+}
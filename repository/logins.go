@@ -0,0 +1,40 @@
+package repository
+
+import "time"
+
+// scopes of the login throttles and lockouts
+const (
+	LoginScopeUsername = "username" // failed logins of a username, whoever tried them
+	LoginScopeAddress  = "address"  // failed logins from a client address, whatever the usernames
+)
+
+// LoginThrottle tracks the consecutive failed logins of a username or of a client address
+type LoginThrottle struct {
+	// Key of the throttle, the scope and the username or address, e.g. "username:alice"
+	Key string
+	// Failures is the number of consecutive failed logins
+	Failures int
+	// LastFailureAt is the time of the last failed login, zero for a key without failures
+	LastFailureAt time.Time
+	// LockedUntil is the time the lockout of the key ends, zero if the key is never locked out
+	LockedUntil time.Time
+	// ExpiresAt is the time the throttle stops having an effect, it is deleted from then
+	ExpiresAt time.Time
+}
+
+// Lockout is the audit record of a username or a client address locked out after failed logins
+type Lockout struct {
+	// ID of the lockout, assigned when the lockout is saved
+	ID uint
+	// Scope of the lockout, LoginScopeUsername or LoginScopeAddress
+	Scope string
+	// Username and ClientIP of the failed login which caused the lockout
+	Username string
+	ClientIP string
+	// Failures is the number of consecutive failed logins of the locked out username or address
+	Failures int
+	// LockedUntil is the time the lockout ends
+	LockedUntil time.Time
+	// CreatedAt is the time of the lockout
+	CreatedAt time.Time
+}
@@ -11,7 +11,9 @@ package mockdb
 
 import (
 	repository "Chat-Server/repository"
+	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "go.uber.org/mock/gomock"
 )
@@ -39,62 +41,769 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
+// AddAttachment mocks base method.
+func (m *MockRepository) AddAttachment(arg0 context.Context, arg1 *repository.Attachment) (*repository.Attachment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddAttachment", arg0, arg1)
+	ret0, _ := ret[0].(*repository.Attachment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddAttachment indicates an expected call of AddAttachment.
+func (mr *MockRepositoryMockRecorder) AddAttachment(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAttachment", reflect.TypeOf((*MockRepository)(nil).AddAttachment), arg0, arg1)
+}
+
+// AddBot mocks base method.
+func (m *MockRepository) AddBot(arg0 context.Context, arg1 *repository.Bot) (*repository.Bot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddBot", arg0, arg1)
+	ret0, _ := ret[0].(*repository.Bot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddBot indicates an expected call of AddBot.
+func (mr *MockRepositoryMockRecorder) AddBot(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddBot", reflect.TypeOf((*MockRepository)(nil).AddBot), arg0, arg1)
+}
+
+// AddIncomingHook mocks base method.
+func (m *MockRepository) AddIncomingHook(arg0 context.Context, arg1 *repository.IncomingHook) (*repository.IncomingHook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddIncomingHook", arg0, arg1)
+	ret0, _ := ret[0].(*repository.IncomingHook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddIncomingHook indicates an expected call of AddIncomingHook.
+func (mr *MockRepositoryMockRecorder) AddIncomingHook(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddIncomingHook", reflect.TypeOf((*MockRepository)(nil).AddIncomingHook), arg0, arg1)
+}
+
+// AddLockout mocks base method.
+func (m *MockRepository) AddLockout(arg0 context.Context, arg1 *repository.Lockout) (*repository.Lockout, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddLockout", arg0, arg1)
+	ret0, _ := ret[0].(*repository.Lockout)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddLockout indicates an expected call of AddLockout.
+func (mr *MockRepositoryMockRecorder) AddLockout(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddLockout", reflect.TypeOf((*MockRepository)(nil).AddLockout), arg0, arg1)
+}
+
 // AddMessage mocks base method.
-func (m *MockRepository) AddMessage(arg0 *repository.Message) (*repository.Message, error) {
+func (m *MockRepository) AddMessage(arg0 context.Context, arg1 *repository.Message) (*repository.Message, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddMessage", arg0)
+	ret := m.ctrl.Call(m, "AddMessage", arg0, arg1)
 	ret0, _ := ret[0].(*repository.Message)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // AddMessage indicates an expected call of AddMessage.
-func (mr *MockRepositoryMockRecorder) AddMessage(arg0 any) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) AddMessage(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddMessage", reflect.TypeOf((*MockRepository)(nil).AddMessage), arg0, arg1)
+}
+
+// AddMessages mocks base method.
+func (m *MockRepository) AddMessages(arg0 context.Context, arg1 []*repository.Message) ([]*repository.Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddMessages", arg0, arg1)
+	ret0, _ := ret[0].([]*repository.Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddMessages indicates an expected call of AddMessages.
+func (mr *MockRepositoryMockRecorder) AddMessages(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddMessages", reflect.TypeOf((*MockRepository)(nil).AddMessages), arg0, arg1)
+}
+
+// AddPasswordReset mocks base method.
+func (m *MockRepository) AddPasswordReset(arg0 context.Context, arg1 *repository.PasswordReset) (*repository.PasswordReset, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddPasswordReset", arg0, arg1)
+	ret0, _ := ret[0].(*repository.PasswordReset)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddPasswordReset indicates an expected call of AddPasswordReset.
+func (mr *MockRepositoryMockRecorder) AddPasswordReset(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPasswordReset", reflect.TypeOf((*MockRepository)(nil).AddPasswordReset), arg0, arg1)
+}
+
+// AddSession mocks base method.
+func (m *MockRepository) AddSession(arg0 context.Context, arg1 *repository.Session) (*repository.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddSession", arg0, arg1)
+	ret0, _ := ret[0].(*repository.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddSession indicates an expected call of AddSession.
+func (mr *MockRepositoryMockRecorder) AddSession(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddMessage", reflect.TypeOf((*MockRepository)(nil).AddMessage), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddSession", reflect.TypeOf((*MockRepository)(nil).AddSession), arg0, arg1)
 }
 
 // AddUser mocks base method.
-func (m *MockRepository) AddUser(arg0 *repository.User) (*repository.User, error) {
+func (m *MockRepository) AddUser(arg0 context.Context, arg1 *repository.User) (*repository.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddUser", arg0)
+	ret := m.ctrl.Call(m, "AddUser", arg0, arg1)
 	ret0, _ := ret[0].(*repository.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // AddUser indicates an expected call of AddUser.
-func (mr *MockRepositoryMockRecorder) AddUser(arg0 any) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) AddUser(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUser", reflect.TypeOf((*MockRepository)(nil).AddUser), arg0, arg1)
+}
+
+// AddWebhook mocks base method.
+func (m *MockRepository) AddWebhook(arg0 context.Context, arg1 *repository.Webhook) (*repository.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddWebhook", arg0, arg1)
+	ret0, _ := ret[0].(*repository.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddWebhook indicates an expected call of AddWebhook.
+func (mr *MockRepositoryMockRecorder) AddWebhook(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWebhook", reflect.TypeOf((*MockRepository)(nil).AddWebhook), arg0, arg1)
+}
+
+// AddWebhookDelivery mocks base method.
+func (m *MockRepository) AddWebhookDelivery(arg0 context.Context, arg1 *repository.WebhookDelivery) (*repository.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddWebhookDelivery", arg0, arg1)
+	ret0, _ := ret[0].(*repository.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddWebhookDelivery indicates an expected call of AddWebhookDelivery.
+func (mr *MockRepositoryMockRecorder) AddWebhookDelivery(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWebhookDelivery", reflect.TypeOf((*MockRepository)(nil).AddWebhookDelivery), arg0, arg1)
+}
+
+// ChangePassword mocks base method.
+func (m *MockRepository) ChangePassword(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePassword", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ChangePassword indicates an expected call of ChangePassword.
+func (mr *MockRepositoryMockRecorder) ChangePassword(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockRepository)(nil).ChangePassword), arg0, arg1, arg2)
+}
+
+// Close mocks base method.
+func (m *MockRepository) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockRepositoryMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockRepository)(nil).Close))
+}
+
+// ConsumePasswordReset mocks base method.
+func (m *MockRepository) ConsumePasswordReset(arg0 context.Context, arg1 string, arg2 time.Time) (*repository.PasswordReset, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumePasswordReset", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*repository.PasswordReset)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumePasswordReset indicates an expected call of ConsumePasswordReset.
+func (mr *MockRepositoryMockRecorder) ConsumePasswordReset(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumePasswordReset", reflect.TypeOf((*MockRepository)(nil).ConsumePasswordReset), arg0, arg1, arg2)
+}
+
+// CountUnreadNotifications mocks base method.
+func (m *MockRepository) CountUnreadNotifications(arg0 context.Context, arg1 string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountUnreadNotifications", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountUnreadNotifications indicates an expected call of CountUnreadNotifications.
+func (mr *MockRepositoryMockRecorder) CountUnreadNotifications(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountUnreadNotifications", reflect.TypeOf((*MockRepository)(nil).CountUnreadNotifications), arg0, arg1)
+}
+
+// DeleteExpiredLoginThrottles mocks base method.
+func (m *MockRepository) DeleteExpiredLoginThrottles(arg0 context.Context, arg1 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredLoginThrottles", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredLoginThrottles indicates an expected call of DeleteExpiredLoginThrottles.
+func (mr *MockRepositoryMockRecorder) DeleteExpiredLoginThrottles(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredLoginThrottles", reflect.TypeOf((*MockRepository)(nil).DeleteExpiredLoginThrottles), arg0, arg1)
+}
+
+// DeleteExpiredPasswordResets mocks base method.
+func (m *MockRepository) DeleteExpiredPasswordResets(arg0 context.Context, arg1 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredPasswordResets", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredPasswordResets indicates an expected call of DeleteExpiredPasswordResets.
+func (mr *MockRepositoryMockRecorder) DeleteExpiredPasswordResets(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredPasswordResets", reflect.TypeOf((*MockRepository)(nil).DeleteExpiredPasswordResets), arg0, arg1)
+}
+
+// DeleteFullRateLimitBuckets mocks base method.
+func (m *MockRepository) DeleteFullRateLimitBuckets(arg0 context.Context, arg1 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteFullRateLimitBuckets", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteFullRateLimitBuckets indicates an expected call of DeleteFullRateLimitBuckets.
+func (mr *MockRepositoryMockRecorder) DeleteFullRateLimitBuckets(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteFullRateLimitBuckets", reflect.TypeOf((*MockRepository)(nil).DeleteFullRateLimitBuckets), arg0, arg1)
+}
+
+// DeleteLoginThrottle mocks base method.
+func (m *MockRepository) DeleteLoginThrottle(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteLoginThrottle", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteLoginThrottle indicates an expected call of DeleteLoginThrottle.
+func (mr *MockRepositoryMockRecorder) DeleteLoginThrottle(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteLoginThrottle", reflect.TypeOf((*MockRepository)(nil).DeleteLoginThrottle), arg0, arg1)
+}
+
+// DeleteTwoFactor mocks base method.
+func (m *MockRepository) DeleteTwoFactor(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTwoFactor", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTwoFactor indicates an expected call of DeleteTwoFactor.
+func (mr *MockRepositoryMockRecorder) DeleteTwoFactor(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTwoFactor", reflect.TypeOf((*MockRepository)(nil).DeleteTwoFactor), arg0, arg1)
+}
+
+// DeleteWebhook mocks base method.
+func (m *MockRepository) DeleteWebhook(arg0 context.Context, arg1 uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhook", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhook indicates an expected call of DeleteWebhook.
+func (mr *MockRepositoryMockRecorder) DeleteWebhook(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhook", reflect.TypeOf((*MockRepository)(nil).DeleteWebhook), arg0, arg1)
+}
+
+// EnableWebhook mocks base method.
+func (m *MockRepository) EnableWebhook(arg0 context.Context, arg1 uint) (*repository.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnableWebhook", arg0, arg1)
+	ret0, _ := ret[0].(*repository.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnableWebhook indicates an expected call of EnableWebhook.
+func (mr *MockRepositoryMockRecorder) EnableWebhook(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUser", reflect.TypeOf((*MockRepository)(nil).AddUser), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableWebhook", reflect.TypeOf((*MockRepository)(nil).EnableWebhook), arg0, arg1)
 }
 
 // GetAllMessages mocks base method.
-func (m *MockRepository) GetAllMessages() ([]*repository.Message, error) {
+func (m *MockRepository) GetAllMessages(arg0 context.Context) ([]*repository.Message, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllMessages")
+	ret := m.ctrl.Call(m, "GetAllMessages", arg0)
 	ret0, _ := ret[0].([]*repository.Message)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAllMessages indicates an expected call of GetAllMessages.
-func (mr *MockRepositoryMockRecorder) GetAllMessages() *gomock.Call {
+func (mr *MockRepositoryMockRecorder) GetAllMessages(arg0 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllMessages", reflect.TypeOf((*MockRepository)(nil).GetAllMessages))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllMessages", reflect.TypeOf((*MockRepository)(nil).GetAllMessages), arg0)
+}
+
+// GetAttachment mocks base method.
+func (m *MockRepository) GetAttachment(arg0 context.Context, arg1 string) (*repository.Attachment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAttachment", arg0, arg1)
+	ret0, _ := ret[0].(*repository.Attachment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAttachment indicates an expected call of GetAttachment.
+func (mr *MockRepositoryMockRecorder) GetAttachment(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAttachment", reflect.TypeOf((*MockRepository)(nil).GetAttachment), arg0, arg1)
+}
+
+// GetBot mocks base method.
+func (m *MockRepository) GetBot(arg0 context.Context, arg1 string) (*repository.Bot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBot", arg0, arg1)
+	ret0, _ := ret[0].(*repository.Bot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBot indicates an expected call of GetBot.
+func (mr *MockRepositoryMockRecorder) GetBot(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBot", reflect.TypeOf((*MockRepository)(nil).GetBot), arg0, arg1)
+}
+
+// GetBotByToken mocks base method.
+func (m *MockRepository) GetBotByToken(arg0 context.Context, arg1 string) (*repository.Bot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBotByToken", arg0, arg1)
+	ret0, _ := ret[0].(*repository.Bot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBotByToken indicates an expected call of GetBotByToken.
+func (mr *MockRepositoryMockRecorder) GetBotByToken(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBotByToken", reflect.TypeOf((*MockRepository)(nil).GetBotByToken), arg0, arg1)
+}
+
+// GetBots mocks base method.
+func (m *MockRepository) GetBots(arg0 context.Context, arg1 string) ([]*repository.Bot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBots", arg0, arg1)
+	ret0, _ := ret[0].([]*repository.Bot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBots indicates an expected call of GetBots.
+func (mr *MockRepositoryMockRecorder) GetBots(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBots", reflect.TypeOf((*MockRepository)(nil).GetBots), arg0, arg1)
+}
+
+// GetIncomingHookByToken mocks base method.
+func (m *MockRepository) GetIncomingHookByToken(arg0 context.Context, arg1 string) (*repository.IncomingHook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIncomingHookByToken", arg0, arg1)
+	ret0, _ := ret[0].(*repository.IncomingHook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIncomingHookByToken indicates an expected call of GetIncomingHookByToken.
+func (mr *MockRepositoryMockRecorder) GetIncomingHookByToken(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIncomingHookByToken", reflect.TypeOf((*MockRepository)(nil).GetIncomingHookByToken), arg0, arg1)
+}
+
+// GetIncomingHooks mocks base method.
+func (m *MockRepository) GetIncomingHooks(arg0 context.Context) ([]*repository.IncomingHook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIncomingHooks", arg0)
+	ret0, _ := ret[0].([]*repository.IncomingHook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIncomingHooks indicates an expected call of GetIncomingHooks.
+func (mr *MockRepositoryMockRecorder) GetIncomingHooks(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIncomingHooks", reflect.TypeOf((*MockRepository)(nil).GetIncomingHooks), arg0)
+}
+
+// GetLockouts mocks base method.
+func (m *MockRepository) GetLockouts(arg0 context.Context, arg1 int) ([]*repository.Lockout, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLockouts", arg0, arg1)
+	ret0, _ := ret[0].([]*repository.Lockout)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLockouts indicates an expected call of GetLockouts.
+func (mr *MockRepositoryMockRecorder) GetLockouts(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLockouts", reflect.TypeOf((*MockRepository)(nil).GetLockouts), arg0, arg1)
+}
+
+// GetLoginThrottle mocks base method.
+func (m *MockRepository) GetLoginThrottle(arg0 context.Context, arg1 string) (*repository.LoginThrottle, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoginThrottle", arg0, arg1)
+	ret0, _ := ret[0].(*repository.LoginThrottle)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoginThrottle indicates an expected call of GetLoginThrottle.
+func (mr *MockRepositoryMockRecorder) GetLoginThrottle(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoginThrottle", reflect.TypeOf((*MockRepository)(nil).GetLoginThrottle), arg0, arg1)
+}
+
+// GetNotifications mocks base method.
+func (m *MockRepository) GetNotifications(arg0 context.Context, arg1 *repository.NotificationQuery) ([]*repository.Notification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNotifications", arg0, arg1)
+	ret0, _ := ret[0].([]*repository.Notification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetNotifications indicates an expected call of GetNotifications.
+func (mr *MockRepositoryMockRecorder) GetNotifications(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNotifications", reflect.TypeOf((*MockRepository)(nil).GetNotifications), arg0, arg1)
+}
+
+// GetSession mocks base method.
+func (m *MockRepository) GetSession(arg0 context.Context, arg1 uint) (*repository.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSession", arg0, arg1)
+	ret0, _ := ret[0].(*repository.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSession indicates an expected call of GetSession.
+func (mr *MockRepositoryMockRecorder) GetSession(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSession", reflect.TypeOf((*MockRepository)(nil).GetSession), arg0, arg1)
+}
+
+// GetSessionByRefreshToken mocks base method.
+func (m *MockRepository) GetSessionByRefreshToken(arg0 context.Context, arg1 string) (*repository.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSessionByRefreshToken", arg0, arg1)
+	ret0, _ := ret[0].(*repository.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSessionByRefreshToken indicates an expected call of GetSessionByRefreshToken.
+func (mr *MockRepositoryMockRecorder) GetSessionByRefreshToken(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessionByRefreshToken", reflect.TypeOf((*MockRepository)(nil).GetSessionByRefreshToken), arg0, arg1)
+}
+
+// GetTwoFactor mocks base method.
+func (m *MockRepository) GetTwoFactor(arg0 context.Context, arg1 string) (*repository.TwoFactor, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTwoFactor", arg0, arg1)
+	ret0, _ := ret[0].(*repository.TwoFactor)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTwoFactor indicates an expected call of GetTwoFactor.
+func (mr *MockRepositoryMockRecorder) GetTwoFactor(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTwoFactor", reflect.TypeOf((*MockRepository)(nil).GetTwoFactor), arg0, arg1)
 }
 
 // GetUser mocks base method.
-func (m *MockRepository) GetUser(arg0 string) (*repository.User, error) {
+func (m *MockRepository) GetUser(arg0 context.Context, arg1 string) (*repository.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUser", arg0)
+	ret := m.ctrl.Call(m, "GetUser", arg0, arg1)
 	ret0, _ := ret[0].(*repository.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUser indicates an expected call of GetUser.
-func (mr *MockRepositoryMockRecorder) GetUser(arg0 any) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) GetUser(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockRepository)(nil).GetUser), arg0, arg1)
+}
+
+// GetWebhook mocks base method.
+func (m *MockRepository) GetWebhook(arg0 context.Context, arg1 uint) (*repository.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhook", arg0, arg1)
+	ret0, _ := ret[0].(*repository.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhook indicates an expected call of GetWebhook.
+func (mr *MockRepositoryMockRecorder) GetWebhook(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhook", reflect.TypeOf((*MockRepository)(nil).GetWebhook), arg0, arg1)
+}
+
+// GetWebhookDeliveries mocks base method.
+func (m *MockRepository) GetWebhookDeliveries(arg0 context.Context, arg1 uint, arg2 int) ([]*repository.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookDeliveries", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]*repository.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookDeliveries indicates an expected call of GetWebhookDeliveries.
+func (mr *MockRepositoryMockRecorder) GetWebhookDeliveries(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookDeliveries", reflect.TypeOf((*MockRepository)(nil).GetWebhookDeliveries), arg0, arg1, arg2)
+}
+
+// GetWebhooks mocks base method.
+func (m *MockRepository) GetWebhooks(arg0 context.Context) ([]*repository.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhooks", arg0)
+	ret0, _ := ret[0].([]*repository.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhooks indicates an expected call of GetWebhooks.
+func (mr *MockRepositoryMockRecorder) GetWebhooks(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhooks", reflect.TypeOf((*MockRepository)(nil).GetWebhooks), arg0)
+}
+
+// MarkNotificationsRead mocks base method.
+func (m *MockRepository) MarkNotificationsRead(arg0 context.Context, arg1 string, arg2 []uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkNotificationsRead", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkNotificationsRead indicates an expected call of MarkNotificationsRead.
+func (mr *MockRepositoryMockRecorder) MarkNotificationsRead(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkNotificationsRead", reflect.TypeOf((*MockRepository)(nil).MarkNotificationsRead), arg0, arg1, arg2)
+}
+
+// Ping mocks base method.
+func (m *MockRepository) Ping(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockRepositoryMockRecorder) Ping(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockRepository)(nil).Ping), arg0)
+}
+
+// RecordWebhookResult mocks base method.
+func (m *MockRepository) RecordWebhookResult(arg0 context.Context, arg1 uint, arg2 bool, arg3 int) (*repository.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordWebhookResult", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*repository.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordWebhookResult indicates an expected call of RecordWebhookResult.
+func (mr *MockRepositoryMockRecorder) RecordWebhookResult(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordWebhookResult", reflect.TypeOf((*MockRepository)(nil).RecordWebhookResult), arg0, arg1, arg2, arg3)
+}
+
+// RevokeIncomingHook mocks base method.
+func (m *MockRepository) RevokeIncomingHook(arg0 context.Context, arg1 uint) (*repository.IncomingHook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeIncomingHook", arg0, arg1)
+	ret0, _ := ret[0].(*repository.IncomingHook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeIncomingHook indicates an expected call of RevokeIncomingHook.
+func (mr *MockRepositoryMockRecorder) RevokeIncomingHook(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeIncomingHook", reflect.TypeOf((*MockRepository)(nil).RevokeIncomingHook), arg0, arg1)
+}
+
+// SearchMessages mocks base method.
+func (m *MockRepository) SearchMessages(arg0 context.Context, arg1 *repository.MessageSearch) ([]*repository.MessageMatch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchMessages", arg0, arg1)
+	ret0, _ := ret[0].([]*repository.MessageMatch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchMessages indicates an expected call of SearchMessages.
+func (mr *MockRepositoryMockRecorder) SearchMessages(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchMessages", reflect.TypeOf((*MockRepository)(nil).SearchMessages), arg0, arg1)
+}
+
+// SetAttachmentThumbnail mocks base method.
+func (m *MockRepository) SetAttachmentThumbnail(arg0 context.Context, arg1 string, arg2 *repository.Thumbnail) (*repository.Attachment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAttachmentThumbnail", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*repository.Attachment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetAttachmentThumbnail indicates an expected call of SetAttachmentThumbnail.
+func (mr *MockRepositoryMockRecorder) SetAttachmentThumbnail(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAttachmentThumbnail", reflect.TypeOf((*MockRepository)(nil).SetAttachmentThumbnail), arg0, arg1, arg2)
+}
+
+// SetBotToken mocks base method.
+func (m *MockRepository) SetBotToken(arg0 context.Context, arg1, arg2 string) (*repository.Bot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetBotToken", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*repository.Bot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetBotToken indicates an expected call of SetBotToken.
+func (mr *MockRepositoryMockRecorder) SetBotToken(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBotToken", reflect.TypeOf((*MockRepository)(nil).SetBotToken), arg0, arg1, arg2)
+}
+
+// SetEmail mocks base method.
+func (m *MockRepository) SetEmail(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetEmail", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetEmail indicates an expected call of SetEmail.
+func (mr *MockRepositoryMockRecorder) SetEmail(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetEmail", reflect.TypeOf((*MockRepository)(nil).SetEmail), arg0, arg1, arg2)
+}
+
+// SetTwoFactor mocks base method.
+func (m *MockRepository) SetTwoFactor(arg0 context.Context, arg1 *repository.TwoFactor) (*repository.TwoFactor, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTwoFactor", arg0, arg1)
+	ret0, _ := ret[0].(*repository.TwoFactor)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetTwoFactor indicates an expected call of SetTwoFactor.
+func (mr *MockRepositoryMockRecorder) SetTwoFactor(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTwoFactor", reflect.TypeOf((*MockRepository)(nil).SetTwoFactor), arg0, arg1)
+}
+
+// UpdateLoginThrottle mocks base method.
+func (m *MockRepository) UpdateLoginThrottle(arg0 context.Context, arg1 string, arg2 func(*repository.LoginThrottle)) (*repository.LoginThrottle, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateLoginThrottle", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*repository.LoginThrottle)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateLoginThrottle indicates an expected call of UpdateLoginThrottle.
+func (mr *MockRepositoryMockRecorder) UpdateLoginThrottle(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateLoginThrottle", reflect.TypeOf((*MockRepository)(nil).UpdateLoginThrottle), arg0, arg1, arg2)
+}
+
+// UpdateRateLimitBucket mocks base method.
+func (m *MockRepository) UpdateRateLimitBucket(arg0 context.Context, arg1 string, arg2 func(*repository.RateLimitBucket)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRateLimitBucket", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateRateLimitBucket indicates an expected call of UpdateRateLimitBucket.
+func (mr *MockRepositoryMockRecorder) UpdateRateLimitBucket(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRateLimitBucket", reflect.TypeOf((*MockRepository)(nil).UpdateRateLimitBucket), arg0, arg1, arg2)
+}
+
+// UpdateTwoFactor mocks base method.
+func (m *MockRepository) UpdateTwoFactor(arg0 context.Context, arg1 string, arg2 func(*repository.TwoFactor) error) (*repository.TwoFactor, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTwoFactor", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*repository.TwoFactor)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateTwoFactor indicates an expected call of UpdateTwoFactor.
+func (mr *MockRepositoryMockRecorder) UpdateTwoFactor(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTwoFactor", reflect.TypeOf((*MockRepository)(nil).UpdateTwoFactor), arg0, arg1, arg2)
+}
+
+// VerifyEmail mocks base method.
+func (m *MockRepository) VerifyEmail(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmail", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyEmail indicates an expected call of VerifyEmail.
+func (mr *MockRepositoryMockRecorder) VerifyEmail(arg0, arg1, arg2 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockRepository)(nil).GetUser), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmail", reflect.TypeOf((*MockRepository)(nil).VerifyEmail), arg0, arg1, arg2)
 }
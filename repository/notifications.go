@@ -0,0 +1,47 @@
+package repository
+
+import "time"
+
+// EntityMention is the type of the entities of the texts mentioning users
+const EntityMention = "mention"
+
+// Notification represents a notification of a user mentioned in a message
+type Notification struct {
+	// ID of the notification, assigned when the notification is saved
+	ID uint
+	// Username of the mentioned user the notification is for
+	Username string
+	// MessageID is the ID of the message mentioning the user
+	MessageID uint
+	// Author, Room and Text of the message mentioning the user
+	Author string
+	Room   string
+	Text   string
+	// Read reports whether the user has read the notification
+	Read bool
+	// CreatedAt is the time the notification is created, the time the message is saved
+	CreatedAt time.Time
+}
+
+// NotificationQuery holds the filters of the notifications of a user
+type NotificationQuery struct {
+	Username   string // username of the user the notifications are for
+	UnreadOnly bool   // only unread notifications are returned
+	BeforeID   uint   // cursor, only notifications with a lower id are returned, no bound if zero
+	Limit      int    // maximum number of returned notifications
+}
+
+// Mentions returns the distinct usernames mentioned in the input message other than its author, the
+// users who are notified when the message is saved
+func Mentions(message *Message) []string {
+	var usernames []string
+	seen := map[string]bool{message.Author: true}
+	for _, entity := range message.Entities {
+		if entity.Type == EntityMention && !seen[entity.Value] {
+			seen[entity.Value] = true
+			usernames = append(usernames, entity.Value)
+		}
+	}
+
+	return usernames
+}
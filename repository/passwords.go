@@ -0,0 +1,15 @@
+package repository
+
+import "time"
+
+// PasswordReset is a pending reset of the password of a user, requested by the user who forgot it
+type PasswordReset struct {
+	// TokenHash is the hex encoded sha256 hash of the reset token sent to the user, the token itself is never stored
+	TokenHash string
+	// Username of the user
+	Username string
+	// CreatedAt is the time the reset is requested
+	CreatedAt time.Time
+	// ExpiresAt is the time after which the token is rejected
+	ExpiresAt time.Time
+}
@@ -0,0 +1,16 @@
+package repository
+
+import "time"
+
+// RateLimitBucket is the token bucket of a rate limited key, e.g. a user or an address, kept in the data
+// layer so the instances of the server share it
+type RateLimitBucket struct {
+	// Key of the bucket
+	Key string
+	// Tokens is the number of tokens in the bucket at UpdatedAt
+	Tokens float64
+	// UpdatedAt is the time the bucket is last updated, zero for a key without a bucket
+	UpdatedAt time.Time
+	// FullAt is the time the bucket is full again, the bucket is deleted from then
+	FullAt time.Time
+}
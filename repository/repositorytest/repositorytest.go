@@ -0,0 +1,1179 @@
+// Package repositorytest provides a conformance test suite for implementations of repository.Repository
+package repositorytest
+
+import (
+	"Chat-Server/repository"
+	"Chat-Server/util"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// NewRepository returns an empty repository to be tested
+type NewRepository func(t *testing.T) repository.Repository
+
+// Run runs the conformance test suite against the repositories returned by newRepository,
+// every test gets a new empty repository
+func Run(t *testing.T, newRepository NewRepository) {
+	t.Run("AddUser", func(t *testing.T) { testAddUser(t, newRepository(t)) })
+	t.Run("GetUser", func(t *testing.T) { testGetUser(t, newRepository(t)) })
+	t.Run("AddMessage", func(t *testing.T) { testAddMessage(t, newRepository(t)) })
+	t.Run("AddMessages", func(t *testing.T) { testAddMessages(t, newRepository(t)) })
+	t.Run("GetAllMessages", func(t *testing.T) { testGetAllMessages(t, newRepository(t)) })
+	t.Run("MessageMarkup", func(t *testing.T) { testMessageMarkup(t, newRepository(t)) })
+	t.Run("SearchMessages", func(t *testing.T) { testSearchMessages(t, newRepository(t)) })
+	t.Run("Attachments", func(t *testing.T) { testAttachments(t, newRepository(t)) })
+	t.Run("Notifications", func(t *testing.T) { testNotifications(t, newRepository(t)) })
+	t.Run("Webhooks", func(t *testing.T) { testWebhooks(t, newRepository(t)) })
+	t.Run("WebhookDeliveries", func(t *testing.T) { testWebhookDeliveries(t, newRepository(t)) })
+	t.Run("IncomingHooks", func(t *testing.T) { testIncomingHooks(t, newRepository(t)) })
+	t.Run("Bots", func(t *testing.T) { testBots(t, newRepository(t)) })
+	t.Run("RateLimitBuckets", func(t *testing.T) { testRateLimitBuckets(t, newRepository(t)) })
+	t.Run("LoginThrottles", func(t *testing.T) { testLoginThrottles(t, newRepository(t)) })
+	t.Run("Lockouts", func(t *testing.T) { testLockouts(t, newRepository(t)) })
+	t.Run("TwoFactors", func(t *testing.T) { testTwoFactors(t, newRepository(t)) })
+	t.Run("Sessions", func(t *testing.T) { testSessions(t, newRepository(t)) })
+	t.Run("PasswordResets", func(t *testing.T) { testPasswordResets(t, newRepository(t)) })
+	t.Run("ChangePassword", func(t *testing.T) { testChangePassword(t, newRepository(t)) })
+	t.Run("Emails", func(t *testing.T) { testEmails(t, newRepository(t)) })
+	t.Run("Ping", func(t *testing.T) { require.NoError(t, newRepository(t).Ping(context.Background())) })
+	t.Run("CanceledContext", func(t *testing.T) { testCanceledContext(t, newRepository(t)) })
+}
+
+// addRandomUser adds a random user to the repository
+func addRandomUser(t *testing.T, r repository.Repository) *repository.User {
+	hashedPassword, err := util.HashPassword(util.RandomPassword())
+	require.NoError(t, err)
+
+	user := &repository.User{
+		Username: util.RandomUsername() + util.RandomString(8, util.ALPHANUMERIC),
+		Password: hashedPassword,
+	}
+
+	res, err := r.AddUser(context.Background(), user)
+	require.NoError(t, err)
+	require.Equal(t, user.Username, res.Username)
+	require.Equal(t, user.Password, res.Password)
+
+	return res
+}
+
+// addRandomMessage adds a random message of the input author to the repository
+func addRandomMessage(t *testing.T, r repository.Repository, author string) *repository.Message {
+	message := &repository.Message{
+		Author: author,
+		Text:   util.RandomText(),
+	}
+
+	res, err := r.AddMessage(context.Background(), message)
+	require.NoError(t, err)
+	require.NotZero(t, res.ID)
+	require.Equal(t, message.Author, res.Author)
+	require.Equal(t, message.Text, res.Text)
+
+	return res
+}
+
+func testAddUser(t *testing.T, r repository.Repository) {
+	user := addRandomUser(t, r)
+
+	res, err := r.AddUser(context.Background(), &repository.User{Username: user.Username, Password: user.Password})
+	require.ErrorIs(t, err, repository.ErrUserExists)
+	require.Nil(t, res)
+}
+
+func testGetUser(t *testing.T, r repository.Repository) {
+	user := addRandomUser(t, r)
+
+	res, err := r.GetUser(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Equal(t, user, res)
+
+	res, err = r.GetUser(context.Background(), "non existing username")
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	require.Nil(t, res)
+}
+
+func testAddMessage(t *testing.T, r repository.Repository) {
+	user := addRandomUser(t, r)
+
+	first := addRandomMessage(t, r, user.Username)
+	second := addRandomMessage(t, r, user.Username)
+	require.Greater(t, second.ID, first.ID)
+
+	res, err := r.AddMessage(context.Background(), &repository.Message{Author: "non existing author", Text: util.RandomText()})
+	require.ErrorIs(t, err, repository.ErrAuthorNotFound)
+	require.Nil(t, res)
+}
+
+func testAddMessages(t *testing.T, r repository.Repository) {
+	user := addRandomUser(t, r)
+
+	messages := make([]*repository.Message, 5)
+	for i := range messages {
+		messages[i] = &repository.Message{Author: user.Username, Text: util.RandomText()}
+	}
+
+	res, err := r.AddMessages(context.Background(), messages)
+	require.NoError(t, err)
+	require.Len(t, res, len(messages))
+	for i := range messages {
+		require.Equal(t, messages[i].Author, res[i].Author)
+		require.Equal(t, messages[i].Text, res[i].Text)
+		if i > 0 {
+			require.Greater(t, res[i].ID, res[i-1].ID)
+		}
+	}
+
+	// a batch with an unknown author is not saved at all
+	res, err = r.AddMessages(context.Background(), []*repository.Message{
+		{Author: user.Username, Text: util.RandomText()},
+		{Author: "non existing author", Text: util.RandomText()},
+	})
+	require.ErrorIs(t, err, repository.ErrAuthorNotFound)
+	require.Nil(t, res)
+
+	allMessages, err := r.GetAllMessages(context.Background())
+	require.NoError(t, err)
+	require.Len(t, allMessages, len(messages))
+}
+
+func testGetAllMessages(t *testing.T, r repository.Repository) {
+	res, err := r.GetAllMessages(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, res)
+
+	users := []*repository.User{addRandomUser(t, r), addRandomUser(t, r)}
+
+	var messages []*repository.Message
+	for i := 0; i < 10; i++ {
+		messages = append(messages, addRandomMessage(t, r, users[i%2].Username))
+	}
+
+	res, err = r.GetAllMessages(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, messages, res)
+}
+
+func testMessageMarkup(t *testing.T, r repository.Repository) {
+	user := addRandomUser(t, r)
+
+	message := &repository.Message{
+		Author:   user.Username,
+		Text:     "hi @someone, see `code`",
+		Rendered: `hi <span class="mention" data-username="someone">@someone</span>, see <code>code</code>`,
+		Entities: []repository.Entity{
+			{Type: "mention", Offset: 3, Length: 8, Value: "someone"},
+			{Type: "code", Offset: 17, Length: 6, Value: "code"},
+		},
+	}
+
+	res, err := r.AddMessage(context.Background(), message)
+	require.NoError(t, err)
+	require.Equal(t, message.Rendered, res.Rendered)
+	require.Equal(t, message.Entities, res.Entities)
+
+	// messages without entities are saved too
+	plain, err := r.AddMessage(context.Background(), &repository.Message{Author: user.Username, Text: "plain"})
+	require.NoError(t, err)
+	require.Empty(t, plain.Entities)
+
+	messages, err := r.GetAllMessages(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []*repository.Message{res, plain}, messages)
+}
+
+func testSearchMessages(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+	users := []*repository.User{addRandomUser(t, r), addRandomUser(t, r)}
+
+	start := time.Now().Add(-time.Second)
+	saved, err := r.AddMessages(ctx, []*repository.Message{
+		{Author: users[0].Username, Text: "the deployment failed again"},
+		{Author: users[1].Username, Text: "who broke the deployment?", Room: "ops"},
+		{Author: users[0].Username, Text: "lunch at noon"},
+		{Author: users[1].Username, Text: "rolling back the deployment now", Room: "ops"},
+		{Author: users[0].Username, Text: "deployment is green"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, repository.DefaultRoom, saved[0].Room)
+	require.Equal(t, "ops", saved[1].Room)
+	require.WithinDuration(t, time.Now(), saved[0].CreatedAt, 5*time.Second)
+
+	ids := func(matches []*repository.MessageMatch) []uint {
+		result := make([]uint, len(matches))
+		for i, match := range matches {
+			result[i] = match.ID
+		}
+		return result
+	}
+
+	// newest first with the matched terms delimited in the snippets
+	matches, err := r.SearchMessages(ctx, &repository.MessageSearch{Text: "Deployment", Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, []uint{saved[4].ID, saved[3].ID, saved[1].ID, saved[0].ID}, ids(matches))
+	for _, match := range matches {
+		require.Contains(t, match.Snippet, repository.SnippetMatchStart+"deployment"+repository.SnippetMatchEnd)
+	}
+	require.Equal(t, saved[4].Text, matches[0].Text)
+	require.Equal(t, saved[4].Author, matches[0].Author)
+
+	// author and room filters
+	matches, err = r.SearchMessages(ctx, &repository.MessageSearch{Text: "deployment", Author: users[0].Username, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, []uint{saved[4].ID, saved[0].ID}, ids(matches))
+
+	matches, err = r.SearchMessages(ctx, &repository.MessageSearch{Text: "deployment", Room: "ops", Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, []uint{saved[3].ID, saved[1].ID}, ids(matches))
+
+	// every word must match
+	matches, err = r.SearchMessages(ctx, &repository.MessageSearch{Text: "deployment green", Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, []uint{saved[4].ID}, ids(matches))
+
+	// date range
+	matches, err = r.SearchMessages(ctx, &repository.MessageSearch{Text: "deployment", Since: start, Until: time.Now().Add(time.Second), Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, matches, 4)
+
+	matches, err = r.SearchMessages(ctx, &repository.MessageSearch{Text: "deployment", Until: start, Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, matches)
+
+	// cursor pagination
+	matches, err = r.SearchMessages(ctx, &repository.MessageSearch{Text: "deployment", Limit: 3})
+	require.NoError(t, err)
+	require.Equal(t, []uint{saved[4].ID, saved[3].ID, saved[1].ID}, ids(matches))
+
+	matches, err = r.SearchMessages(ctx, &repository.MessageSearch{Text: "deployment", BeforeID: matches[2].ID, Limit: 3})
+	require.NoError(t, err)
+	require.Equal(t, []uint{saved[0].ID}, ids(matches))
+
+	// no match
+	matches, err = r.SearchMessages(ctx, &repository.MessageSearch{Text: "kubernetes", Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, matches)
+}
+
+// addRandomAttachment adds a random unattached attachment of the input owner to the repository
+func addRandomAttachment(t *testing.T, r repository.Repository, owner string) *repository.Attachment {
+	attachment := &repository.Attachment{
+		ID:          util.RandomString(32, util.ALPHANUMERIC),
+		Owner:       owner,
+		Filename:    util.RandomString(8, util.ALPHANUMERIC) + ".png",
+		ContentType: "image/png",
+		Size:        1024,
+		Checksum:    util.RandomString(64, util.ALPHANUMERIC),
+		Width:       640,
+		Height:      480,
+	}
+
+	res, err := r.AddAttachment(context.Background(), attachment)
+	require.NoError(t, err)
+	require.Equal(t, attachment.ID, res.ID)
+	require.Equal(t, attachment.Owner, res.Owner)
+	require.Equal(t, attachment.Filename, res.Filename)
+	require.Equal(t, attachment.ContentType, res.ContentType)
+	require.Equal(t, attachment.Size, res.Size)
+	require.Equal(t, attachment.Checksum, res.Checksum)
+	require.Equal(t, attachment.Width, res.Width)
+	require.Equal(t, attachment.Height, res.Height)
+	require.Nil(t, res.Thumbnail)
+	require.Zero(t, res.MessageID)
+	require.WithinDuration(t, time.Now(), res.CreatedAt, time.Minute)
+
+	return res
+}
+
+func testAttachments(t *testing.T, r repository.Repository) {
+	user := addRandomUser(t, r)
+	other := addRandomUser(t, r)
+
+	first := addRandomAttachment(t, r, user.Username)
+	second := addRandomAttachment(t, r, user.Username)
+	othersAttachment := addRandomAttachment(t, r, other.Username)
+
+	res, err := r.GetAttachment(context.Background(), first.ID)
+	require.NoError(t, err)
+	require.Equal(t, first, res)
+
+	_, err = r.GetAttachment(context.Background(), "non existing id")
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	_, err = r.AddAttachment(context.Background(), &repository.Attachment{ID: "orphan", Owner: "non existing username"})
+	require.ErrorIs(t, err, repository.ErrAuthorNotFound)
+
+	// attaching the attachment of another user fails and saves nothing
+	_, err = r.AddMessages(context.Background(), []*repository.Message{
+		{Author: user.Username, Text: "text", Attachments: []*repository.Attachment{{ID: first.ID}}},
+		{Author: user.Username, Text: "text", Attachments: []*repository.Attachment{{ID: othersAttachment.ID}}},
+	})
+	require.ErrorIs(t, err, repository.ErrConflict)
+
+	messages, err := r.GetAllMessages(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, messages)
+
+	// attaching unattached attachments of the author
+	message, err := r.AddMessage(context.Background(), &repository.Message{
+		Author:      user.Username,
+		Text:        "text",
+		Attachments: []*repository.Attachment{{ID: first.ID}, {ID: second.ID}},
+	})
+	require.NoError(t, err)
+	require.Len(t, message.Attachments, 2)
+	for i, attachment := range []*repository.Attachment{first, second} {
+		attachment.MessageID = message.ID
+		require.Equal(t, attachment, message.Attachments[i])
+	}
+
+	res, err = r.GetAttachment(context.Background(), first.ID)
+	require.NoError(t, err)
+	require.Equal(t, message.ID, res.MessageID)
+
+	messages, err = r.GetAllMessages(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []*repository.Message{message}, messages)
+
+	// an attachment is attached to a single message
+	_, err = r.AddMessage(context.Background(), &repository.Message{
+		Author:      user.Username,
+		Text:        "text",
+		Attachments: []*repository.Attachment{{ID: first.ID}},
+	})
+	require.ErrorIs(t, err, repository.ErrConflict)
+
+	_, err = r.AddMessage(context.Background(), &repository.Message{
+		Author:      user.Username,
+		Text:        "text",
+		Attachments: []*repository.Attachment{{ID: "non existing id"}},
+	})
+	require.ErrorIs(t, err, repository.ErrConflict)
+
+	// thumbnails are set after the attachments are uploaded, the messages carry them afterwards
+	thumbnail := &repository.Thumbnail{ContentType: "image/png", Width: 320, Height: 240, Size: 256}
+	res, err = r.SetAttachmentThumbnail(context.Background(), second.ID, thumbnail)
+	require.NoError(t, err)
+	require.Equal(t, thumbnail, res.Thumbnail)
+	require.Equal(t, message.ID, res.MessageID)
+
+	messages, err = r.GetAllMessages(context.Background())
+	require.NoError(t, err)
+	require.Nil(t, messages[0].Attachments[0].Thumbnail)
+	require.Equal(t, thumbnail, messages[0].Attachments[1].Thumbnail)
+
+	_, err = r.SetAttachmentThumbnail(context.Background(), "non existing id", thumbnail)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+// mention returns a mention entity of the input username
+func mention(username string) repository.Entity {
+	return repository.Entity{Type: repository.EntityMention, Length: len(username) + 1, Value: username}
+}
+
+func testNotifications(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+	author := addRandomUser(t, r)
+	first := addRandomUser(t, r)
+	second := addRandomUser(t, r)
+
+	// the author and unknown users are not notified, users mentioned twice are notified once
+	messages, err := r.AddMessages(ctx, []*repository.Message{
+		{Author: author.Username, Text: "one", Room: "ops", Entities: []repository.Entity{
+			mention(first.Username), mention(author.Username), mention("unknown_user"), mention(first.Username),
+		}},
+		{Author: author.Username, Text: "two"},
+		{Author: author.Username, Text: "three", Entities: []repository.Entity{mention(second.Username), mention(first.Username)}},
+	})
+	require.NoError(t, err)
+	require.Len(t, messages[0].Notifications, 1)
+	require.Empty(t, messages[1].Notifications)
+	require.Len(t, messages[2].Notifications, 2)
+
+	notification := messages[0].Notifications[0]
+	require.NotZero(t, notification.ID)
+	require.Equal(t, first.Username, notification.Username)
+	require.Equal(t, messages[0].ID, notification.MessageID)
+	require.Equal(t, author.Username, notification.Author)
+	require.Equal(t, "ops", notification.Room)
+	require.Equal(t, "one", notification.Text)
+	require.False(t, notification.Read)
+	require.Equal(t, messages[0].CreatedAt, notification.CreatedAt)
+	require.Equal(t, second.Username, messages[2].Notifications[0].Username)
+	require.Equal(t, first.Username, messages[2].Notifications[1].Username)
+
+	// the notifications of a user are retrieved newest first
+	notifications, err := r.GetNotifications(ctx, &repository.NotificationQuery{Username: first.Username, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, []*repository.Notification{messages[2].Notifications[1], notification}, notifications)
+
+	notifications, err = r.GetNotifications(ctx, &repository.NotificationQuery{Username: first.Username, Limit: 1})
+	require.NoError(t, err)
+	require.Equal(t, []*repository.Notification{messages[2].Notifications[1]}, notifications)
+
+	notifications, err = r.GetNotifications(ctx, &repository.NotificationQuery{Username: first.Username, BeforeID: notifications[0].ID, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, []*repository.Notification{notification}, notifications)
+
+	count, err := r.CountUnreadNotifications(ctx, first.Username)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), count)
+
+	// notifications of other users are not marked as read
+	require.NoError(t, r.MarkNotificationsRead(ctx, first.Username, []uint{notification.ID, messages[2].Notifications[0].ID}))
+
+	notifications, err = r.GetNotifications(ctx, &repository.NotificationQuery{Username: first.Username, UnreadOnly: true, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	require.Equal(t, messages[2].ID, notifications[0].MessageID)
+
+	notifications, err = r.GetNotifications(ctx, &repository.NotificationQuery{Username: first.Username, Limit: 10})
+	require.NoError(t, err)
+	require.True(t, notifications[1].Read)
+
+	count, err = r.CountUnreadNotifications(ctx, second.Username)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+
+	// all the notifications of a user are marked as read without ids
+	require.NoError(t, r.MarkNotificationsRead(ctx, first.Username, nil))
+	count, err = r.CountUnreadNotifications(ctx, first.Username)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	notifications, err = r.GetNotifications(ctx, &repository.NotificationQuery{Username: author.Username, Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, notifications)
+}
+
+// addRandomWebhook adds a webhook registered by the input admin to the repository
+func addRandomWebhook(t *testing.T, r repository.Repository, admin string) *repository.Webhook {
+	webhook := &repository.Webhook{
+		URL:       "https://example.com/hooks/" + util.RandomString(8, util.ALPHANUMERIC),
+		Secret:    util.RandomString(32, util.ALPHANUMERIC),
+		Events:    []string{"message.created", "user.joined"},
+		CreatedBy: admin,
+	}
+
+	res, err := r.AddWebhook(context.Background(), webhook)
+	require.NoError(t, err)
+	require.NotZero(t, res.ID)
+	require.Equal(t, webhook.URL, res.URL)
+	require.Equal(t, webhook.Secret, res.Secret)
+	require.Equal(t, webhook.Events, res.Events)
+	require.Equal(t, admin, res.CreatedBy)
+	require.Zero(t, res.Failures)
+	require.False(t, res.Disabled)
+	require.WithinDuration(t, time.Now(), res.CreatedAt, time.Minute)
+
+	return res
+}
+
+func testWebhooks(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+	admin := addRandomUser(t, r)
+	first := addRandomWebhook(t, r, admin.Username)
+	second := addRandomWebhook(t, r, admin.Username)
+
+	_, err := r.AddWebhook(ctx, &repository.Webhook{URL: first.URL, Events: first.Events, CreatedBy: "unknown_user"})
+	require.ErrorIs(t, err, repository.ErrAuthorNotFound)
+
+	res, err := r.GetWebhook(ctx, first.ID)
+	require.NoError(t, err)
+	require.Equal(t, first, res)
+
+	_, err = r.GetWebhook(ctx, second.ID+1)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	webhooks, err := r.GetWebhooks(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []*repository.Webhook{first, second}, webhooks)
+
+	// failures are counted until they reach the maximum, then the webhook is disabled
+	res, err = r.RecordWebhookResult(ctx, first.ID, false, 2)
+	require.NoError(t, err)
+	require.Equal(t, 1, res.Failures)
+	require.False(t, res.Disabled)
+
+	res, err = r.RecordWebhookResult(ctx, first.ID, false, 2)
+	require.NoError(t, err)
+	require.Equal(t, 2, res.Failures)
+	require.True(t, res.Disabled)
+	require.False(t, res.Subscribes("message.created"))
+
+	// a delivered event resets the failures but does not enable the webhook
+	res, err = r.RecordWebhookResult(ctx, first.ID, true, 2)
+	require.NoError(t, err)
+	require.Zero(t, res.Failures)
+	require.True(t, res.Disabled)
+
+	res, err = r.EnableWebhook(ctx, first.ID)
+	require.NoError(t, err)
+	require.False(t, res.Disabled)
+	require.True(t, res.Subscribes("message.created"))
+	require.False(t, res.Subscribes("user.signed_up"))
+
+	_, err = r.RecordWebhookResult(ctx, second.ID+1, false, 2)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = r.EnableWebhook(ctx, second.ID+1)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	require.NoError(t, r.DeleteWebhook(ctx, first.ID))
+	require.ErrorIs(t, r.DeleteWebhook(ctx, first.ID), repository.ErrNotFound)
+
+	webhooks, err = r.GetWebhooks(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []*repository.Webhook{second}, webhooks)
+}
+
+func testWebhookDeliveries(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+	admin := addRandomUser(t, r)
+	webhook := addRandomWebhook(t, r, admin.Username)
+	other := addRandomWebhook(t, r, admin.Username)
+
+	var deliveries []*repository.WebhookDelivery
+	for i, statusCode := range []int{500, 0, 200} {
+		delivery := &repository.WebhookDelivery{
+			WebhookID:  webhook.ID,
+			EventID:    "event",
+			EventType:  "message.created",
+			Attempt:    i + 1,
+			StatusCode: statusCode,
+			Duration:   time.Duration(i) * time.Second,
+		}
+		if statusCode != 200 {
+			delivery.Error = "failed"
+		}
+
+		res, err := r.AddWebhookDelivery(ctx, delivery)
+		require.NoError(t, err)
+		require.NotZero(t, res.ID)
+		require.Equal(t, delivery.Attempt, res.Attempt)
+		require.Equal(t, delivery.StatusCode, res.StatusCode)
+		require.Equal(t, delivery.Error, res.Error)
+		require.Equal(t, delivery.Duration, res.Duration)
+		require.WithinDuration(t, time.Now(), res.CreatedAt, time.Minute)
+		deliveries = append(deliveries, res)
+	}
+
+	_, err := r.AddWebhookDelivery(ctx, &repository.WebhookDelivery{WebhookID: other.ID + 1, EventID: "event", EventType: "user.joined", Attempt: 1})
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	// deliveries are retrieved newest first
+	res, err := r.GetWebhookDeliveries(ctx, webhook.ID, 2)
+	require.NoError(t, err)
+	require.Equal(t, []*repository.WebhookDelivery{deliveries[2], deliveries[1]}, res)
+
+	res, err = r.GetWebhookDeliveries(ctx, other.ID, 10)
+	require.NoError(t, err)
+	require.Empty(t, res)
+
+	// the deliveries of a deleted webhook are deleted with it
+	require.NoError(t, r.DeleteWebhook(ctx, webhook.ID))
+	res, err = r.GetWebhookDeliveries(ctx, webhook.ID, 10)
+	require.NoError(t, err)
+	require.Empty(t, res)
+}
+
+// addRandomIncomingHook adds a random incoming hook created by the input user to the repository
+func addRandomIncomingHook(t *testing.T, r repository.Repository, createdBy string) *repository.IncomingHook {
+	hook := &repository.IncomingHook{
+		TokenHash: util.RandomString(64, util.ALPHANUMERIC),
+		Name:      util.RandomText(),
+		Username:  util.RandomUsername() + util.RandomString(8, util.ALPHANUMERIC),
+		CreatedBy: createdBy,
+	}
+
+	res, err := r.AddIncomingHook(context.Background(), hook)
+	require.NoError(t, err)
+	require.NotZero(t, res.ID)
+	require.Equal(t, hook.TokenHash, res.TokenHash)
+	require.Equal(t, hook.Name, res.Name)
+	require.Equal(t, hook.Username, res.Username)
+	require.Equal(t, createdBy, res.CreatedBy)
+	require.False(t, res.Revoked)
+	require.WithinDuration(t, time.Now(), res.CreatedAt, time.Minute)
+
+	return res
+}
+
+func testIncomingHooks(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+	admin := addRandomUser(t, r)
+	first := addRandomIncomingHook(t, r, admin.Username)
+	second := addRandomIncomingHook(t, r, admin.Username)
+
+	// the bot user is created with the hook and cannot log in
+	bot, err := r.GetUser(ctx, first.Username)
+	require.NoError(t, err)
+	require.Empty(t, bot.Password)
+	addRandomMessage(t, r, bot.Username)
+
+	// the bot username must be free and the creator must exist, a failed hook creates no user
+	_, err = r.AddIncomingHook(ctx, &repository.IncomingHook{TokenHash: "token_hash", Username: admin.Username, CreatedBy: admin.Username})
+	require.ErrorIs(t, err, repository.ErrUserExists)
+	_, err = r.AddIncomingHook(ctx, &repository.IncomingHook{TokenHash: "token_hash", Username: "hook_bot", CreatedBy: "unknown_user"})
+	require.ErrorIs(t, err, repository.ErrAuthorNotFound)
+	_, err = r.GetUser(ctx, "hook_bot")
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	res, err := r.GetIncomingHookByToken(ctx, first.TokenHash)
+	require.NoError(t, err)
+	require.Equal(t, first, res)
+
+	_, err = r.GetIncomingHookByToken(ctx, "unknown_hash")
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	hooks, err := r.GetIncomingHooks(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []*repository.IncomingHook{first, second}, hooks)
+
+	res, err = r.RevokeIncomingHook(ctx, first.ID)
+	require.NoError(t, err)
+	require.True(t, res.Revoked)
+
+	res, err = r.GetIncomingHookByToken(ctx, first.TokenHash)
+	require.NoError(t, err)
+	require.True(t, res.Revoked)
+
+	_, err = r.RevokeIncomingHook(ctx, second.ID+1)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+// addRandomBot adds a random bot of the input owner to the repository
+func addRandomBot(t *testing.T, r repository.Repository, owner string) *repository.Bot {
+	bot := &repository.Bot{
+		Username:  util.RandomUsername() + util.RandomString(8, util.ALPHANUMERIC),
+		Owner:     owner,
+		TokenHash: util.RandomString(64, util.ALPHANUMERIC),
+	}
+
+	res, err := r.AddBot(context.Background(), bot)
+	require.NoError(t, err)
+	require.Equal(t, bot.Username, res.Username)
+	require.Equal(t, owner, res.Owner)
+	require.Equal(t, bot.TokenHash, res.TokenHash)
+	require.WithinDuration(t, time.Now(), res.CreatedAt, time.Minute)
+
+	return res
+}
+
+func testBots(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+	owner := addRandomUser(t, r)
+	first := addRandomBot(t, r, owner.Username)
+	second := addRandomBot(t, r, owner.Username)
+	other := addRandomBot(t, r, addRandomUser(t, r).Username)
+
+	// the bot user is created with the bot, cannot log in and authors messages
+	user, err := r.GetUser(ctx, first.Username)
+	require.NoError(t, err)
+	require.Empty(t, user.Password)
+	addRandomMessage(t, r, first.Username)
+
+	// the bot username must be free and the owner must exist, a failed bot creates no user
+	_, err = r.AddBot(ctx, &repository.Bot{Username: owner.Username, Owner: owner.Username, TokenHash: "token_hash"})
+	require.ErrorIs(t, err, repository.ErrUserExists)
+	_, err = r.AddBot(ctx, &repository.Bot{Username: "new_bot", Owner: "unknown_user", TokenHash: "token_hash"})
+	require.ErrorIs(t, err, repository.ErrAuthorNotFound)
+	_, err = r.GetUser(ctx, "new_bot")
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	res, err := r.GetBot(ctx, first.Username)
+	require.NoError(t, err)
+	require.Equal(t, first, res)
+
+	_, err = r.GetBot(ctx, owner.Username)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	res, err = r.GetBotByToken(ctx, second.TokenHash)
+	require.NoError(t, err)
+	require.Equal(t, second, res)
+
+	bots, err := r.GetBots(ctx, owner.Username)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []*repository.Bot{first, second}, bots)
+
+	bots, err = r.GetBots(ctx, other.Username)
+	require.NoError(t, err)
+	require.Empty(t, bots)
+
+	// the previous token stops working once it is replaced
+	res, err = r.SetBotToken(ctx, first.Username, "new_token_hash")
+	require.NoError(t, err)
+	require.Equal(t, "new_token_hash", res.TokenHash)
+	require.Equal(t, first.CreatedAt, res.CreatedAt)
+
+	_, err = r.GetBotByToken(ctx, first.TokenHash)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	res, err = r.GetBotByToken(ctx, "new_token_hash")
+	require.NoError(t, err)
+	require.Equal(t, first.Username, res.Username)
+
+	_, err = r.SetBotToken(ctx, owner.Username, "other_token_hash")
+	require.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func testRateLimitBuckets(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	// a key without a bucket gets a zero bucket
+	err := r.UpdateRateLimitBucket(ctx, "login:first", func(bucket *repository.RateLimitBucket) {
+		require.Equal(t, "login:first", bucket.Key)
+		require.Zero(t, bucket.Tokens)
+		require.True(t, bucket.UpdatedAt.IsZero())
+
+		bucket.Tokens = 2.5
+		bucket.UpdatedAt = now
+		bucket.FullAt = now.Add(time.Minute)
+	})
+	require.NoError(t, err)
+
+	err = r.UpdateRateLimitBucket(ctx, "login:second", func(bucket *repository.RateLimitBucket) {
+		bucket.Tokens = 4
+		bucket.UpdatedAt = now
+		bucket.FullAt = now.Add(time.Hour)
+	})
+	require.NoError(t, err)
+
+	// the updated bucket is saved
+	err = r.UpdateRateLimitBucket(ctx, "login:first", func(bucket *repository.RateLimitBucket) {
+		require.Equal(t, 2.5, bucket.Tokens)
+		require.True(t, now.Equal(bucket.UpdatedAt))
+		require.True(t, now.Add(time.Minute).Equal(bucket.FullAt))
+	})
+	require.NoError(t, err)
+
+	// only the buckets which are full are deleted
+	require.NoError(t, r.DeleteFullRateLimitBuckets(ctx, now.Add(time.Minute)))
+	err = r.UpdateRateLimitBucket(ctx, "login:first", func(bucket *repository.RateLimitBucket) {
+		require.True(t, bucket.UpdatedAt.IsZero())
+	})
+	require.NoError(t, err)
+	err = r.UpdateRateLimitBucket(ctx, "login:second", func(bucket *repository.RateLimitBucket) {
+		require.Equal(t, 4.0, bucket.Tokens)
+	})
+	require.NoError(t, err)
+
+	// the concurrent updates of a bucket do not overlap
+	const updates = 20
+	errs := make(chan error, updates)
+	for range updates {
+		go func() {
+			errs <- r.UpdateRateLimitBucket(ctx, "login:concurrent", func(bucket *repository.RateLimitBucket) {
+				bucket.Tokens++
+				bucket.UpdatedAt = now
+				bucket.FullAt = now
+			})
+		}()
+	}
+	for range updates {
+		require.NoError(t, <-errs)
+	}
+	err = r.UpdateRateLimitBucket(ctx, "login:concurrent", func(bucket *repository.RateLimitBucket) {
+		require.Equal(t, float64(updates), bucket.Tokens)
+	})
+	require.NoError(t, err)
+}
+
+func testLoginThrottles(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	_, err := r.GetLoginThrottle(ctx, "username:first")
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	// a key without a throttle gets a zero throttle
+	throttle, err := r.UpdateLoginThrottle(ctx, "username:first", func(throttle *repository.LoginThrottle) {
+		require.Equal(t, "username:first", throttle.Key)
+		require.Zero(t, throttle.Failures)
+		require.True(t, throttle.LockedUntil.IsZero())
+
+		throttle.Failures = 3
+		throttle.LastFailureAt = now
+		throttle.LockedUntil = now.Add(time.Minute)
+		throttle.ExpiresAt = now.Add(time.Minute)
+	})
+	require.NoError(t, err)
+	require.Equal(t, "username:first", throttle.Key)
+	require.Equal(t, 3, throttle.Failures)
+
+	_, err = r.UpdateLoginThrottle(ctx, "address:192.0.2.1", func(throttle *repository.LoginThrottle) {
+		throttle.Failures = 1
+		throttle.LastFailureAt = now
+		throttle.ExpiresAt = now.Add(time.Hour)
+	})
+	require.NoError(t, err)
+
+	// the updated throttle is saved
+	throttle, err = r.GetLoginThrottle(ctx, "username:first")
+	require.NoError(t, err)
+	require.Equal(t, 3, throttle.Failures)
+	require.True(t, now.Equal(throttle.LastFailureAt))
+	require.True(t, now.Add(time.Minute).Equal(throttle.LockedUntil))
+	require.True(t, now.Add(time.Minute).Equal(throttle.ExpiresAt))
+
+	// only the throttles which expire before the input time are deleted
+	require.NoError(t, r.DeleteExpiredLoginThrottles(ctx, now.Add(2*time.Minute)))
+	_, err = r.GetLoginThrottle(ctx, "username:first")
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	throttle, err = r.GetLoginThrottle(ctx, "address:192.0.2.1")
+	require.NoError(t, err)
+	require.Equal(t, 1, throttle.Failures)
+
+	require.NoError(t, r.DeleteLoginThrottle(ctx, "address:192.0.2.1"))
+	_, err = r.GetLoginThrottle(ctx, "address:192.0.2.1")
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	require.NoError(t, r.DeleteLoginThrottle(ctx, "address:192.0.2.1"))
+
+	// the concurrent failed logins are all counted
+	const updates = 20
+	errs := make(chan error, updates)
+	for range updates {
+		go func() {
+			_, err := r.UpdateLoginThrottle(ctx, "username:concurrent", func(throttle *repository.LoginThrottle) {
+				throttle.Failures++
+				throttle.LastFailureAt = now
+				throttle.ExpiresAt = now
+			})
+			errs <- err
+		}()
+	}
+	for range updates {
+		require.NoError(t, <-errs)
+	}
+	throttle, err = r.GetLoginThrottle(ctx, "username:concurrent")
+	require.NoError(t, err)
+	require.Equal(t, updates, throttle.Failures)
+}
+
+func testLockouts(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	lockouts, err := r.GetLockouts(ctx, 10)
+	require.NoError(t, err)
+	require.Empty(t, lockouts)
+
+	// the locked out usernames need not have an account
+	first, err := r.AddLockout(ctx, &repository.Lockout{
+		Scope:       repository.LoginScopeUsername,
+		Username:    util.RandomUsername(),
+		ClientIP:    util.RandomIPv4(),
+		Failures:    5,
+		LockedUntil: now.Add(15 * time.Minute),
+	})
+	require.NoError(t, err)
+	require.NotZero(t, first.ID)
+	require.Equal(t, 5, first.Failures)
+	require.WithinDuration(t, time.Now(), first.CreatedAt, time.Minute)
+
+	second, err := r.AddLockout(ctx, &repository.Lockout{
+		Scope:       repository.LoginScopeAddress,
+		Username:    util.RandomUsername(),
+		ClientIP:    util.RandomIPv4(),
+		Failures:    20,
+		LockedUntil: now.Add(15 * time.Minute),
+	})
+	require.NoError(t, err)
+
+	// the lockouts are retrieved newest first
+	lockouts, err = r.GetLockouts(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, lockouts, 2)
+	require.Equal(t, second.ID, lockouts[0].ID)
+	require.Equal(t, repository.LoginScopeAddress, lockouts[0].Scope)
+	require.Equal(t, second.ClientIP, lockouts[0].ClientIP)
+	require.True(t, second.LockedUntil.Equal(lockouts[0].LockedUntil))
+	require.Equal(t, first.ID, lockouts[1].ID)
+	require.Equal(t, first.Username, lockouts[1].Username)
+
+	lockouts, err = r.GetLockouts(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, lockouts, 1)
+	require.Equal(t, second.ID, lockouts[0].ID)
+}
+
+func testTwoFactors(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+	user := addRandomUser(t, r)
+
+	_, err := r.GetTwoFactor(ctx, user.Username)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = r.SetTwoFactor(ctx, &repository.TwoFactor{Username: util.RandomUsername(), Secret: "PENDING"})
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	pending, err := r.SetTwoFactor(ctx, &repository.TwoFactor{Username: user.Username, Secret: "PENDING"})
+	require.NoError(t, err)
+	require.False(t, pending.Enabled)
+	require.WithinDuration(t, time.Now(), pending.CreatedAt, time.Minute)
+
+	// a new enrollment replaces the pending one
+	_, err = r.SetTwoFactor(ctx, &repository.TwoFactor{Username: user.Username, Secret: "SECRET"})
+	require.NoError(t, err)
+
+	twoFactor, err := r.UpdateTwoFactor(ctx, user.Username, func(twoFactor *repository.TwoFactor) error {
+		require.Equal(t, "SECRET", twoFactor.Secret)
+		require.Empty(t, twoFactor.RecoveryCodes)
+
+		twoFactor.Enabled = true
+		twoFactor.RecoveryCodes = []string{"first_hash", "second_hash"}
+		twoFactor.LastStep = 42
+		return nil
+	})
+	require.NoError(t, err)
+	require.True(t, twoFactor.Enabled)
+
+	saved, err := r.GetTwoFactor(ctx, user.Username)
+	require.NoError(t, err)
+	require.Equal(t, user.Username, saved.Username)
+	require.Equal(t, "SECRET", saved.Secret)
+	require.True(t, saved.Enabled)
+	require.Equal(t, []string{"first_hash", "second_hash"}, saved.RecoveryCodes)
+	require.Equal(t, int64(42), saved.LastStep)
+
+	// the error of an update is returned and nothing is saved
+	errRejected := errors.New("rejected")
+	_, err = r.UpdateTwoFactor(ctx, user.Username, func(twoFactor *repository.TwoFactor) error {
+		twoFactor.Enabled = false
+		twoFactor.RecoveryCodes = nil
+		return errRejected
+	})
+	require.ErrorIs(t, err, errRejected)
+
+	// the zero values are saved too
+	_, err = r.UpdateTwoFactor(ctx, user.Username, func(twoFactor *repository.TwoFactor) error {
+		twoFactor.RecoveryCodes = twoFactor.RecoveryCodes[1:]
+		twoFactor.LastStep = 0
+		return nil
+	})
+	require.NoError(t, err)
+	saved, err = r.GetTwoFactor(ctx, user.Username)
+	require.NoError(t, err)
+	require.True(t, saved.Enabled)
+	require.Equal(t, []string{"second_hash"}, saved.RecoveryCodes)
+	require.Zero(t, saved.LastStep)
+
+	// the concurrent updates of an authenticator do not overlap
+	const updates = 20
+	errs := make(chan error, updates)
+	for range updates {
+		go func() {
+			_, err := r.UpdateTwoFactor(ctx, user.Username, func(twoFactor *repository.TwoFactor) error {
+				twoFactor.LastStep++
+				return nil
+			})
+			errs <- err
+		}()
+	}
+	for range updates {
+		require.NoError(t, <-errs)
+	}
+	saved, err = r.GetTwoFactor(ctx, user.Username)
+	require.NoError(t, err)
+	require.Equal(t, int64(updates), saved.LastStep)
+
+	require.NoError(t, r.DeleteTwoFactor(ctx, user.Username))
+	_, err = r.GetTwoFactor(ctx, user.Username)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	require.ErrorIs(t, r.DeleteTwoFactor(ctx, user.Username), repository.ErrNotFound)
+	_, err = r.UpdateTwoFactor(ctx, user.Username, func(twoFactor *repository.TwoFactor) error { return nil })
+	require.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func testSessions(t *testing.T, r repository.Repository) {
+	user := addRandomUser(t, r)
+
+	session := &repository.Session{
+		Username:     user.Username,
+		RefreshToken: util.RandomString(32, util.ALPHANUMERIC),
+		UserAgent:    "Mozilla/5.0",
+		ClientIP:     util.RandomIPv4(),
+		CreatedAt:    time.Now().UTC().Truncate(time.Second),
+		ExpiresAt:    time.Now().UTC().Truncate(time.Second).Add(time.Hour),
+	}
+
+	saved, err := r.AddSession(context.Background(), session)
+	require.NoError(t, err)
+	require.NotZero(t, saved.ID)
+
+	res, err := r.GetSession(context.Background(), saved.ID)
+	require.NoError(t, err)
+	require.Equal(t, session.Username, res.Username)
+	require.Equal(t, session.RefreshToken, res.RefreshToken)
+	require.Equal(t, session.UserAgent, res.UserAgent)
+	require.Equal(t, session.ClientIP, res.ClientIP)
+	require.False(t, res.IsBlocked)
+	require.WithinDuration(t, session.CreatedAt, res.CreatedAt, time.Second)
+	require.WithinDuration(t, session.ExpiresAt, res.ExpiresAt, time.Second)
+
+	res, err = r.GetSession(context.Background(), saved.ID+1000)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	require.Nil(t, res)
+
+	res, err = r.GetSessionByRefreshToken(context.Background(), session.RefreshToken)
+	require.NoError(t, err)
+	require.Equal(t, saved.ID, res.ID)
+
+	res, err = r.GetSessionByRefreshToken(context.Background(), "non existing refresh token")
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	require.Nil(t, res)
+
+	session.Username = "non existing username"
+	res, err = r.AddSession(context.Background(), session)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	require.Nil(t, res)
+}
+
+func testPasswordResets(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+	user := addRandomUser(t, r)
+	now := time.Now().UTC()
+
+	reset, err := r.AddPasswordReset(ctx, &repository.PasswordReset{
+		TokenHash: util.RandomString(64, util.ALPHANUMERIC),
+		Username:  user.Username,
+		ExpiresAt: now.Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.WithinDuration(t, now, reset.CreatedAt, time.Minute)
+
+	_, err = r.AddPasswordReset(ctx, &repository.PasswordReset{
+		TokenHash: util.RandomString(64, util.ALPHANUMERIC),
+		Username:  "non existing username",
+		ExpiresAt: now.Add(time.Hour),
+	})
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	// a token is consumed once
+	consumed, err := r.ConsumePasswordReset(ctx, reset.TokenHash, now)
+	require.NoError(t, err)
+	require.Equal(t, user.Username, consumed.Username)
+	require.WithinDuration(t, reset.ExpiresAt, consumed.ExpiresAt, time.Second)
+	_, err = r.ConsumePasswordReset(ctx, reset.TokenHash, now)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	// an expired token is rejected, and deleted with the other expired tokens
+	expired, err := r.AddPasswordReset(ctx, &repository.PasswordReset{
+		TokenHash: util.RandomString(64, util.ALPHANUMERIC),
+		Username:  user.Username,
+		ExpiresAt: now.Add(time.Minute),
+	})
+	require.NoError(t, err)
+	_, err = r.ConsumePasswordReset(ctx, expired.TokenHash, now.Add(2*time.Minute))
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	require.NoError(t, r.DeleteExpiredPasswordResets(ctx, now.Add(2*time.Minute)))
+	_, err = r.ConsumePasswordReset(ctx, expired.TokenHash, now)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func testChangePassword(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+	user := addRandomUser(t, r)
+	other := addRandomUser(t, r)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	addSession := func(username string) *repository.Session {
+		session, err := r.AddSession(ctx, &repository.Session{
+			Username:     username,
+			RefreshToken: util.RandomString(64, util.ALPHANUMERIC),
+			CreatedAt:    now,
+			ExpiresAt:    now.Add(time.Hour),
+		})
+		require.NoError(t, err)
+		return session
+	}
+	first, second, othersSession := addSession(user.Username), addSession(user.Username), addSession(other.Username)
+
+	reset, err := r.AddPasswordReset(ctx, &repository.PasswordReset{
+		TokenHash: util.RandomString(64, util.ALPHANUMERIC),
+		Username:  user.Username,
+		ExpiresAt: now.Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, r.ChangePassword(ctx, user.Username, "new_hash"))
+
+	saved, err := r.GetUser(ctx, user.Username)
+	require.NoError(t, err)
+	require.Equal(t, "new_hash", saved.Password)
+
+	// the sessions and the password resets of the user are revoked, the ones of the other users are kept
+	for _, session := range []*repository.Session{first, second} {
+		res, err := r.GetSession(ctx, session.ID)
+		require.NoError(t, err)
+		require.True(t, res.IsBlocked)
+	}
+	res, err := r.GetSession(ctx, othersSession.ID)
+	require.NoError(t, err)
+	require.False(t, res.IsBlocked)
+
+	_, err = r.ConsumePasswordReset(ctx, reset.TokenHash, now)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	require.ErrorIs(t, r.ChangePassword(ctx, "non existing username", "new_hash"), repository.ErrNotFound)
+}
+
+func testEmails(t *testing.T, r repository.Repository) {
+	ctx := context.Background()
+
+	user, err := r.AddUser(ctx, &repository.User{
+		Username: util.RandomUsername() + util.RandomString(8, util.LOWERCASE),
+		Password: "hash",
+		Email:    "first@example.com",
+	})
+	require.NoError(t, err)
+
+	saved, err := r.GetUser(ctx, user.Username)
+	require.NoError(t, err)
+	require.Equal(t, "first@example.com", saved.Email)
+	require.False(t, saved.EmailVerified)
+
+	// the address which is not the one of the user anymore is not verified
+	require.ErrorIs(t, r.VerifyEmail(ctx, user.Username, "other@example.com"), repository.ErrNotFound)
+	require.NoError(t, r.VerifyEmail(ctx, user.Username, "first@example.com"))
+	require.NoError(t, r.VerifyEmail(ctx, user.Username, "first@example.com"))
+	saved, err = r.GetUser(ctx, user.Username)
+	require.NoError(t, err)
+	require.True(t, saved.EmailVerified)
+
+	// a new address is unverified, an empty one removes it
+	require.NoError(t, r.SetEmail(ctx, user.Username, "second@example.com"))
+	saved, err = r.GetUser(ctx, user.Username)
+	require.NoError(t, err)
+	require.Equal(t, "second@example.com", saved.Email)
+	require.False(t, saved.EmailVerified)
+
+	require.NoError(t, r.SetEmail(ctx, user.Username, ""))
+	saved, err = r.GetUser(ctx, user.Username)
+	require.NoError(t, err)
+	require.Empty(t, saved.Email)
+	require.ErrorIs(t, r.VerifyEmail(ctx, user.Username, ""), repository.ErrNotFound)
+
+	require.ErrorIs(t, r.SetEmail(ctx, "non existing username", "first@example.com"), repository.ErrNotFound)
+	require.ErrorIs(t, r.VerifyEmail(ctx, "non existing username", "first@example.com"), repository.ErrNotFound)
+}
+
+func testCanceledContext(t *testing.T, r repository.Repository) {
+	user := addRandomUser(t, r)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := r.GetUser(ctx, user.Username)
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = r.AddMessage(ctx, &repository.Message{Author: user.Username, Text: util.RandomText()})
+	require.ErrorIs(t, err, context.Canceled)
+
+	messages, err := r.GetAllMessages(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, messages)
+}
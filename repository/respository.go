@@ -1,16 +1,184 @@
 package repository
 
-// Repository implements the required methods for the business layer to interact with the data layer
+import (
+	"context"
+	"time"
+)
+
+// Repository implements the required methods for the business layer to interact with the data layer,
+// every method except Close stops waiting on the data layer once its context is done
 type Repository interface {
-	// AddMessage adds a message to the data layer
-	AddMessage(message *Message) (*Message, error)
+	// AddMessage adds a message to the data layer along with attaching its attachments and notifying
+	// the existing users it mentions, returns ErrConflict if an attachment is not an unattached
+	// attachment of the message's author
+	AddMessage(ctx context.Context, message *Message) (*Message, error)
+
+	// AddMessages adds a batch of messages to the data layer in a single operation and returns
+	// them with their assigned IDs in the same order, attachments are attached as in AddMessage
+	AddMessages(ctx context.Context, messages []*Message) ([]*Message, error)
+
+	// GetAllMessages retrieves all messages from the database with their attachments
+	GetAllMessages(ctx context.Context) ([]*Message, error)
+
+	// SearchMessages retrieves the messages matching the input search, newest first
+	SearchMessages(ctx context.Context, search *MessageSearch) ([]*MessageMatch, error)
+
+	// GetNotifications retrieves the notifications matching the input query, newest first
+	GetNotifications(ctx context.Context, query *NotificationQuery) ([]*Notification, error)
+
+	// CountUnreadNotifications returns the number of unread notifications of a user
+	CountUnreadNotifications(ctx context.Context, username string) (int64, error)
+
+	// MarkNotificationsRead marks the notifications of a user with the input IDs as read, all the
+	// notifications of the user if no ID is given. IDs of notifications of other users are ignored
+	MarkNotificationsRead(ctx context.Context, username string, ids []uint) error
+
+	// AddAttachment adds an unattached attachment to the data layer
+	AddAttachment(ctx context.Context, attachment *Attachment) (*Attachment, error)
+
+	// GetAttachment retrieves an attachment by id
+	GetAttachment(ctx context.Context, id string) (*Attachment, error)
+
+	// SetAttachmentThumbnail sets the thumbnail of an attachment and returns the updated attachment
+	SetAttachmentThumbnail(ctx context.Context, id string, thumbnail *Thumbnail) (*Attachment, error)
+
+	// AddWebhook adds a webhook to the data layer
+	AddWebhook(ctx context.Context, webhook *Webhook) (*Webhook, error)
+
+	// GetWebhook retrieves a webhook by id
+	GetWebhook(ctx context.Context, id uint) (*Webhook, error)
+
+	// GetWebhooks retrieves all webhooks in the order they are registered
+	GetWebhooks(ctx context.Context) ([]*Webhook, error)
+
+	// DeleteWebhook deletes a webhook along with its delivery attempts
+	DeleteWebhook(ctx context.Context, id uint) error
+
+	// EnableWebhook enables a webhook and resets its failures, returns the updated webhook
+	EnableWebhook(ctx context.Context, id uint) (*Webhook, error)
+
+	// RecordWebhookResult records whether an event was delivered to a webhook: a delivered event resets
+	// its failures, a failed one increments them and disables the webhook once they reach maxFailures.
+	// returns the updated webhook
+	RecordWebhookResult(ctx context.Context, id uint, delivered bool, maxFailures int) (*Webhook, error)
+
+	// AddWebhookDelivery adds a delivery attempt of a webhook to the data layer
+	AddWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) (*WebhookDelivery, error)
+
+	// GetWebhookDeliveries retrieves the latest delivery attempts of a webhook, newest first
+	GetWebhookDeliveries(ctx context.Context, webhookID uint, limit int) ([]*WebhookDelivery, error)
+
+	// AddIncomingHook adds an incoming hook to the data layer along with its bot user, returns
+	// ErrUserExists if the username of the bot is taken
+	AddIncomingHook(ctx context.Context, hook *IncomingHook) (*IncomingHook, error)
+
+	// GetIncomingHookByToken retrieves an incoming hook by the hash of its token
+	GetIncomingHookByToken(ctx context.Context, tokenHash string) (*IncomingHook, error)
+
+	// GetIncomingHooks retrieves all incoming hooks in the order they are created
+	GetIncomingHooks(ctx context.Context) ([]*IncomingHook, error)
+
+	// RevokeIncomingHook revokes an incoming hook, returns the updated hook
+	RevokeIncomingHook(ctx context.Context, id uint) (*IncomingHook, error)
+
+	// AddBot adds a bot to the data layer along with its bot user, returns ErrUserExists if the username
+	// of the bot is taken and ErrAuthorNotFound if its owner does not exist
+	AddBot(ctx context.Context, bot *Bot) (*Bot, error)
+
+	// GetBot retrieves a bot by username
+	GetBot(ctx context.Context, username string) (*Bot, error)
+
+	// GetBotByToken retrieves a bot by the hash of its API token
+	GetBotByToken(ctx context.Context, tokenHash string) (*Bot, error)
 
-	// GetAllMessages retrieves all messages from the database
-	GetAllMessages() ([]*Message, error)
+	// GetBots retrieves the bots of an owner in the order they are created
+	GetBots(ctx context.Context, owner string) ([]*Bot, error)
+
+	// SetBotToken replaces the hash of the API token of a bot, the previous token stops working.
+	// returns the updated bot
+	SetBotToken(ctx context.Context, username, tokenHash string) (*Bot, error)
+
+	// UpdateRateLimitBucket calls update with the bucket of a key, a zero bucket with the key if it has none,
+	// and saves the updated bucket. the updates of the bucket of a key do not overlap
+	UpdateRateLimitBucket(ctx context.Context, key string, update func(bucket *RateLimitBucket)) error
+
+	// DeleteFullRateLimitBuckets deletes the buckets which are full at the input time
+	DeleteFullRateLimitBuckets(ctx context.Context, now time.Time) error
+
+	// GetLoginThrottle retrieves the login throttle of a key
+	GetLoginThrottle(ctx context.Context, key string) (*LoginThrottle, error)
+
+	// UpdateLoginThrottle calls update with the login throttle of a key, a zero throttle with the key if it
+	// has none, and saves and returns the updated throttle. the updates of the throttle of a key do not overlap
+	UpdateLoginThrottle(ctx context.Context, key string, update func(throttle *LoginThrottle)) (*LoginThrottle, error)
+
+	// DeleteLoginThrottle deletes the login throttle of a key, if it has one
+	DeleteLoginThrottle(ctx context.Context, key string) error
+
+	// DeleteExpiredLoginThrottles deletes the login throttles which expire before the input time
+	DeleteExpiredLoginThrottles(ctx context.Context, now time.Time) error
+
+	// AddLockout adds the audit record of a lockout to the data layer
+	AddLockout(ctx context.Context, lockout *Lockout) (*Lockout, error)
+
+	// GetLockouts retrieves the latest lockouts, newest first
+	GetLockouts(ctx context.Context, limit int) ([]*Lockout, error)
+
+	// SetTwoFactor saves the TOTP authenticator of a user, replacing the one the user has. returns
+	// ErrNotFound if the user does not exist
+	SetTwoFactor(ctx context.Context, twoFactor *TwoFactor) (*TwoFactor, error)
+
+	// GetTwoFactor retrieves the TOTP authenticator of a user
+	GetTwoFactor(ctx context.Context, username string) (*TwoFactor, error)
+
+	// UpdateTwoFactor calls update with the TOTP authenticator of a user and saves and returns the updated
+	// authenticator, the error of update is returned and nothing is saved. the updates of the authenticator
+	// of a user do not overlap, so a code or a recovery code is accepted once
+	UpdateTwoFactor(ctx context.Context, username string, update func(twoFactor *TwoFactor) error) (*TwoFactor, error)
+
+	// DeleteTwoFactor deletes the TOTP authenticator of a user
+	DeleteTwoFactor(ctx context.Context, username string) error
+
+	// AddPasswordReset adds a password reset to the data layer, returns ErrNotFound if the user does not exist
+	AddPasswordReset(ctx context.Context, reset *PasswordReset) (*PasswordReset, error)
+
+	// ConsumePasswordReset deletes and returns the password reset of a token hash, so a token is used once.
+	// returns ErrNotFound if there is none or it expires before the input time
+	ConsumePasswordReset(ctx context.Context, tokenHash string, now time.Time) (*PasswordReset, error)
+
+	// DeleteExpiredPasswordResets deletes the password resets which expire before the input time
+	DeleteExpiredPasswordResets(ctx context.Context, now time.Time) error
 
 	// AddUser adds a user to the data layer
-	AddUser(user *User) (*User, error)
+	AddUser(ctx context.Context, user *User) (*User, error)
 
 	// GetUser retrieves a user by username
-	GetUser(username string) (*User, error)
+	GetUser(ctx context.Context, username string) (*User, error)
+
+	// SetEmail replaces the email address of a user with an unverified one, an empty address removes it.
+	// returns ErrNotFound if the user does not exist
+	SetEmail(ctx context.Context, username, email string) error
+
+	// VerifyEmail marks the email address of a user as verified, returns ErrNotFound if the user does not
+	// exist or its address is not the input one anymore
+	VerifyEmail(ctx context.Context, username, email string) error
+
+	// ChangePassword replaces the password of a user, blocks all the sessions of the user and deletes the
+	// password resets of the user. returns ErrNotFound if the user does not exist
+	ChangePassword(ctx context.Context, username, password string) error
+
+	// AddSession adds a user session to the data layer
+	AddSession(ctx context.Context, session *Session) (*Session, error)
+
+	// GetSession retrieves a session by id
+	GetSession(ctx context.Context, id uint) (*Session, error)
+
+	// GetSessionByRefreshToken retrieves the session of a refresh token by the hash of the token
+	GetSessionByRefreshToken(ctx context.Context, refreshTokenHash string) (*Session, error)
+
+	// Ping checks the data layer is reachable and can serve requests
+	Ping(ctx context.Context) error
+
+	// Close releases the resources of the data layer
+	Close() error
 }
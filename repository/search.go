@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// delimiters of the matched terms in the snippets of the search results, private use characters so
+// the snippets stay plain text and the api escapes them before turning the delimiters into html
+const (
+	SnippetMatchStart = "\ue000"
+	SnippetMatchEnd   = "\ue001"
+)
+
+// snippetRadius is the number of characters of a naive snippet around its first match
+const snippetRadius = 40
+
+// MessageSearch holds the filters of a message search
+type MessageSearch struct {
+	Text     string    // text the messages match, every word of it must be in a message
+	Author   string    // author of the messages, all authors if empty
+	Room     string    // room of the messages, all rooms if empty
+	Since    time.Time // earliest creation time of the messages, no bound if zero
+	Until    time.Time // latest creation time of the messages, no bound if zero
+	BeforeID uint      // cursor, only messages with a lower id are returned, no bound if zero
+	Limit    int       // maximum number of returned messages
+}
+
+// MessageMatch is a message matching a search
+type MessageMatch struct {
+	Message
+	// Snippet is the part of the text matching the search with the matched terms between
+	// SnippetMatchStart and SnippetMatchEnd
+	Snippet string
+}
+
+// SearchTerms splits the input search text into its lower case words
+func SearchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// NaiveMatch is the search of the repositories without a full-text index, it reports whether the input
+// text contains every input term, case-insensitively, and returns a snippet of the text around its
+// first match with every match delimited
+func NaiveMatch(text string, terms []string) (string, bool) {
+	if len(terms) == 0 {
+		return "", false
+	}
+
+	// byte offsets of the matches in the lower case text, which keeps the offsets of the text for
+	// the characters whose lower case has the same length
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		lower = text
+	}
+
+	type match struct{ start, end int }
+	var matches []match
+	for _, term := range terms {
+		found := false
+		for offset := 0; ; {
+			i := strings.Index(lower[offset:], term)
+			if i < 0 {
+				break
+			}
+			matches = append(matches, match{offset + i, offset + i + len(term)})
+			offset += i + len(term)
+			found = true
+		}
+		if !found {
+			return "", false
+		}
+	}
+
+	// sort the matches and merge the overlapping ones
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+	merged := matches[:1]
+	for _, m := range matches[1:] {
+		last := &merged[len(merged)-1]
+		if m.start <= last.end {
+			last.end = max(last.end, m.end)
+			continue
+		}
+		merged = append(merged, m)
+	}
+
+	// the snippet spans snippetRadius characters around the first match
+	start := merged[0].start
+	for n := 0; n < snippetRadius && start > 0; n++ {
+		_, size := utf8.DecodeLastRuneInString(text[:start])
+		start -= size
+	}
+	end := merged[0].end
+	for n := 0; n < snippetRadius && end < len(text); n++ {
+		_, size := utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+
+	var snippet strings.Builder
+	if start > 0 {
+		snippet.WriteString("...")
+	}
+	offset := start
+	for _, m := range merged {
+		if m.start >= end {
+			break
+		}
+		snippet.WriteString(text[offset:m.start])
+		snippet.WriteString(SnippetMatchStart)
+		snippet.WriteString(text[m.start:min(m.end, end)])
+		snippet.WriteString(SnippetMatchEnd)
+		offset = min(m.end, end)
+	}
+	snippet.WriteString(text[offset:end])
+	if end < len(text) {
+		snippet.WriteString("...")
+	}
+
+	return snippet.String(), true
+}
@@ -0,0 +1,81 @@
+package repository_test
+
+import (
+	"Chat-Server/repository"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// mark delimits the input text like a matched term in a snippet
+func mark(text string) string {
+	return repository.SnippetMatchStart + text + repository.SnippetMatchEnd
+}
+
+// TestNaiveMatch tests NaiveMatch
+func TestNaiveMatch(t *testing.T) {
+	before, after := strings.Repeat("lorem ipsum ", 10), strings.Repeat(" dolor sit", 10)
+
+	testCases := []struct {
+		name    string
+		text    string
+		search  string
+		snippet string
+		ok      bool
+	}{
+		{
+			name:    "CaseInsensitive",
+			text:    "Hello World",
+			search:  "hello",
+			snippet: mark("Hello") + " World",
+			ok:      true,
+		},
+		{
+			name:    "EveryTermMarked",
+			text:    "go is fun, go!",
+			search:  "GO fun",
+			snippet: mark("go") + " is " + mark("fun") + ", " + mark("go") + "!",
+			ok:      true,
+		},
+		{
+			name:    "OverlappingTermsMerged",
+			text:    "deployment",
+			search:  "deploy ployment",
+			snippet: mark("deployment"),
+			ok:      true,
+		},
+		{
+			name:   "MissingTerm",
+			text:   "Hello World",
+			search: "hello there",
+		},
+		{
+			name:   "NoTerms",
+			text:   "Hello World",
+			search: "?!",
+		},
+		{
+			name:    "LongTextTrimmed",
+			text:    before + "needle" + after,
+			search:  "needle",
+			snippet: "..." + before[len(before)-40:] + mark("needle") + after[:40] + "...",
+			ok:      true,
+		},
+		{
+			name:    "Unicode",
+			text:    "سلام دنیا",
+			search:  "دنیا",
+			snippet: "سلام " + mark("دنیا"),
+			ok:      true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			snippet, ok := repository.NaiveMatch(testCase.text, repository.SearchTerms(testCase.search))
+			require.Equal(t, testCase.ok, ok)
+			require.Equal(t, testCase.snippet, snippet)
+		})
+	}
+}
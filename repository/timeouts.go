@@ -0,0 +1,449 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// Timeouts holds the maximum duration of the repository operations, zero means no timeout
+type Timeouts struct {
+	Read  time.Duration // timeout of the operations retrieving data
+	Write time.Duration // timeout of the operations saving data
+}
+
+// timeoutRepository is a Repository bounding the duration of every operation of the wrapped repository
+type timeoutRepository struct {
+	repository Repository
+	timeouts   Timeouts
+}
+
+// WithTimeouts returns a Repository running the operations of the input repository with the input
+// timeouts, the timeouts only shorten the deadlines of the contexts passed by the callers
+func WithTimeouts(repository Repository, timeouts Timeouts) Repository {
+	return &timeoutRepository{
+		repository: repository,
+		timeouts:   timeouts,
+	}
+}
+
+// withTimeout returns a context derived from the input context which is done after the input timeout
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Close closes the wrapped repository
+func (t *timeoutRepository) Close() error {
+	return t.repository.Close()
+}
+
+// Ping checks the wrapped repository with the read timeout
+func (t *timeoutRepository) Ping(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Read)
+	defer cancel()
+
+	return t.repository.Ping(ctx)
+}
+
+// AddMessage adds a message with the write timeout
+func (t *timeoutRepository) AddMessage(ctx context.Context, message *Message) (*Message, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.AddMessage(ctx, message)
+}
+
+// AddMessages adds a batch of messages with the write timeout
+func (t *timeoutRepository) AddMessages(ctx context.Context, messages []*Message) ([]*Message, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.AddMessages(ctx, messages)
+}
+
+// GetAllMessages retrieves all messages with the read timeout
+func (t *timeoutRepository) GetAllMessages(ctx context.Context) ([]*Message, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Read)
+	defer cancel()
+
+	return t.repository.GetAllMessages(ctx)
+}
+
+// SearchMessages searches messages with the read timeout
+func (t *timeoutRepository) SearchMessages(ctx context.Context, search *MessageSearch) ([]*MessageMatch, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Read)
+	defer cancel()
+
+	return t.repository.SearchMessages(ctx, search)
+}
+
+// GetNotifications retrieves notifications with the read timeout
+func (t *timeoutRepository) GetNotifications(ctx context.Context, query *NotificationQuery) ([]*Notification, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Read)
+	defer cancel()
+
+	return t.repository.GetNotifications(ctx, query)
+}
+
+// CountUnreadNotifications counts unread notifications with the read timeout
+func (t *timeoutRepository) CountUnreadNotifications(ctx context.Context, username string) (int64, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Read)
+	defer cancel()
+
+	return t.repository.CountUnreadNotifications(ctx, username)
+}
+
+// MarkNotificationsRead marks notifications as read with the write timeout
+func (t *timeoutRepository) MarkNotificationsRead(ctx context.Context, username string, ids []uint) error {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.MarkNotificationsRead(ctx, username, ids)
+}
+
+// AddAttachment adds an attachment with the write timeout
+func (t *timeoutRepository) AddAttachment(ctx context.Context, attachment *Attachment) (*Attachment, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.AddAttachment(ctx, attachment)
+}
+
+// GetAttachment retrieves an attachment with the read timeout
+func (t *timeoutRepository) GetAttachment(ctx context.Context, id string) (*Attachment, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Read)
+	defer cancel()
+
+	return t.repository.GetAttachment(ctx, id)
+}
+
+// SetAttachmentThumbnail sets the thumbnail of an attachment with the write timeout
+func (t *timeoutRepository) SetAttachmentThumbnail(ctx context.Context, id string, thumbnail *Thumbnail) (*Attachment, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.SetAttachmentThumbnail(ctx, id, thumbnail)
+}
+
+// AddWebhook adds a webhook with the write timeout
+func (t *timeoutRepository) AddWebhook(ctx context.Context, webhook *Webhook) (*Webhook, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.AddWebhook(ctx, webhook)
+}
+
+// GetWebhook retrieves a webhook with the read timeout
+func (t *timeoutRepository) GetWebhook(ctx context.Context, id uint) (*Webhook, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Read)
+	defer cancel()
+
+	return t.repository.GetWebhook(ctx, id)
+}
+
+// GetWebhooks retrieves all webhooks with the read timeout
+func (t *timeoutRepository) GetWebhooks(ctx context.Context) ([]*Webhook, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Read)
+	defer cancel()
+
+	return t.repository.GetWebhooks(ctx)
+}
+
+// DeleteWebhook deletes a webhook with the write timeout
+func (t *timeoutRepository) DeleteWebhook(ctx context.Context, id uint) error {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.DeleteWebhook(ctx, id)
+}
+
+// EnableWebhook enables a webhook with the write timeout
+func (t *timeoutRepository) EnableWebhook(ctx context.Context, id uint) (*Webhook, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.EnableWebhook(ctx, id)
+}
+
+// RecordWebhookResult records the result of a webhook delivery with the write timeout
+func (t *timeoutRepository) RecordWebhookResult(ctx context.Context, id uint, delivered bool, maxFailures int) (*Webhook, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.RecordWebhookResult(ctx, id, delivered, maxFailures)
+}
+
+// AddWebhookDelivery adds a webhook delivery attempt with the write timeout
+func (t *timeoutRepository) AddWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) (*WebhookDelivery, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.AddWebhookDelivery(ctx, delivery)
+}
+
+// GetWebhookDeliveries retrieves webhook delivery attempts with the read timeout
+func (t *timeoutRepository) GetWebhookDeliveries(ctx context.Context, webhookID uint, limit int) ([]*WebhookDelivery, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Read)
+	defer cancel()
+
+	return t.repository.GetWebhookDeliveries(ctx, webhookID, limit)
+}
+
+// AddIncomingHook adds an incoming hook with the write timeout
+func (t *timeoutRepository) AddIncomingHook(ctx context.Context, hook *IncomingHook) (*IncomingHook, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.AddIncomingHook(ctx, hook)
+}
+
+// GetIncomingHookByToken retrieves an incoming hook by token with the read timeout
+func (t *timeoutRepository) GetIncomingHookByToken(ctx context.Context, tokenHash string) (*IncomingHook, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Read)
+	defer cancel()
+
+	return t.repository.GetIncomingHookByToken(ctx, tokenHash)
+}
+
+// GetIncomingHooks retrieves all incoming hooks with the read timeout
+func (t *timeoutRepository) GetIncomingHooks(ctx context.Context) ([]*IncomingHook, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Read)
+	defer cancel()
+
+	return t.repository.GetIncomingHooks(ctx)
+}
+
+// RevokeIncomingHook revokes an incoming hook with the write timeout
+func (t *timeoutRepository) RevokeIncomingHook(ctx context.Context, id uint) (*IncomingHook, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.RevokeIncomingHook(ctx, id)
+}
+
+// AddBot adds a bot with the write timeout
+func (t *timeoutRepository) AddBot(ctx context.Context, bot *Bot) (*Bot, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.AddBot(ctx, bot)
+}
+
+// GetBot retrieves a bot with the read timeout
+func (t *timeoutRepository) GetBot(ctx context.Context, username string) (*Bot, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Read)
+	defer cancel()
+
+	return t.repository.GetBot(ctx, username)
+}
+
+// GetBotByToken retrieves a bot by token with the read timeout
+func (t *timeoutRepository) GetBotByToken(ctx context.Context, tokenHash string) (*Bot, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Read)
+	defer cancel()
+
+	return t.repository.GetBotByToken(ctx, tokenHash)
+}
+
+// GetBots retrieves the bots of an owner with the read timeout
+func (t *timeoutRepository) GetBots(ctx context.Context, owner string) ([]*Bot, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Read)
+	defer cancel()
+
+	return t.repository.GetBots(ctx, owner)
+}
+
+// SetBotToken replaces the token of a bot with the write timeout
+func (t *timeoutRepository) SetBotToken(ctx context.Context, username, tokenHash string) (*Bot, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.SetBotToken(ctx, username, tokenHash)
+}
+
+// UpdateRateLimitBucket updates the rate limit bucket of a key with the write timeout
+func (t *timeoutRepository) UpdateRateLimitBucket(ctx context.Context, key string, update func(bucket *RateLimitBucket)) error {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.UpdateRateLimitBucket(ctx, key, update)
+}
+
+// DeleteFullRateLimitBuckets deletes the full rate limit buckets with the write timeout
+func (t *timeoutRepository) DeleteFullRateLimitBuckets(ctx context.Context, now time.Time) error {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.DeleteFullRateLimitBuckets(ctx, now)
+}
+
+// GetLoginThrottle retrieves the login throttle of a key with the read timeout
+func (t *timeoutRepository) GetLoginThrottle(ctx context.Context, key string) (*LoginThrottle, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Read)
+	defer cancel()
+
+	return t.repository.GetLoginThrottle(ctx, key)
+}
+
+// UpdateLoginThrottle updates the login throttle of a key with the write timeout
+func (t *timeoutRepository) UpdateLoginThrottle(ctx context.Context, key string, update func(throttle *LoginThrottle)) (*LoginThrottle, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.UpdateLoginThrottle(ctx, key, update)
+}
+
+// DeleteLoginThrottle deletes the login throttle of a key with the write timeout
+func (t *timeoutRepository) DeleteLoginThrottle(ctx context.Context, key string) error {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.DeleteLoginThrottle(ctx, key)
+}
+
+// DeleteExpiredLoginThrottles deletes the expired login throttles with the write timeout
+func (t *timeoutRepository) DeleteExpiredLoginThrottles(ctx context.Context, now time.Time) error {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.DeleteExpiredLoginThrottles(ctx, now)
+}
+
+// AddLockout adds the audit record of a lockout with the write timeout
+func (t *timeoutRepository) AddLockout(ctx context.Context, lockout *Lockout) (*Lockout, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.AddLockout(ctx, lockout)
+}
+
+// GetLockouts retrieves the latest lockouts with the read timeout
+func (t *timeoutRepository) GetLockouts(ctx context.Context, limit int) ([]*Lockout, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Read)
+	defer cancel()
+
+	return t.repository.GetLockouts(ctx, limit)
+}
+
+// SetTwoFactor saves the TOTP authenticator of a user with the write timeout
+func (t *timeoutRepository) SetTwoFactor(ctx context.Context, twoFactor *TwoFactor) (*TwoFactor, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.SetTwoFactor(ctx, twoFactor)
+}
+
+// GetTwoFactor retrieves the TOTP authenticator of a user with the read timeout
+func (t *timeoutRepository) GetTwoFactor(ctx context.Context, username string) (*TwoFactor, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Read)
+	defer cancel()
+
+	return t.repository.GetTwoFactor(ctx, username)
+}
+
+// UpdateTwoFactor updates the TOTP authenticator of a user with the write timeout
+func (t *timeoutRepository) UpdateTwoFactor(ctx context.Context, username string, update func(twoFactor *TwoFactor) error) (*TwoFactor, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.UpdateTwoFactor(ctx, username, update)
+}
+
+// DeleteTwoFactor deletes the TOTP authenticator of a user with the write timeout
+func (t *timeoutRepository) DeleteTwoFactor(ctx context.Context, username string) error {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.DeleteTwoFactor(ctx, username)
+}
+
+// AddPasswordReset adds a password reset with the write timeout
+func (t *timeoutRepository) AddPasswordReset(ctx context.Context, reset *PasswordReset) (*PasswordReset, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.AddPasswordReset(ctx, reset)
+}
+
+// ConsumePasswordReset deletes and returns the password reset of a token hash with the write timeout
+func (t *timeoutRepository) ConsumePasswordReset(ctx context.Context, tokenHash string, now time.Time) (*PasswordReset, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.ConsumePasswordReset(ctx, tokenHash, now)
+}
+
+// DeleteExpiredPasswordResets deletes the expired password resets with the write timeout
+func (t *timeoutRepository) DeleteExpiredPasswordResets(ctx context.Context, now time.Time) error {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.DeleteExpiredPasswordResets(ctx, now)
+}
+
+// AddUser adds a user with the write timeout
+func (t *timeoutRepository) AddUser(ctx context.Context, user *User) (*User, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.AddUser(ctx, user)
+}
+
+// GetUser retrieves a user with the read timeout
+func (t *timeoutRepository) GetUser(ctx context.Context, username string) (*User, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Read)
+	defer cancel()
+
+	return t.repository.GetUser(ctx, username)
+}
+
+// SetEmail replaces the email address of a user with the write timeout
+func (t *timeoutRepository) SetEmail(ctx context.Context, username, email string) error {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.SetEmail(ctx, username, email)
+}
+
+// VerifyEmail marks the email address of a user as verified with the write timeout
+func (t *timeoutRepository) VerifyEmail(ctx context.Context, username, email string) error {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.VerifyEmail(ctx, username, email)
+}
+
+// ChangePassword replaces the password of a user with the write timeout
+func (t *timeoutRepository) ChangePassword(ctx context.Context, username, password string) error {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.ChangePassword(ctx, username, password)
+}
+
+// AddSession adds a session with the write timeout
+func (t *timeoutRepository) AddSession(ctx context.Context, session *Session) (*Session, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Write)
+	defer cancel()
+
+	return t.repository.AddSession(ctx, session)
+}
+
+// GetSession retrieves a session with the read timeout
+func (t *timeoutRepository) GetSession(ctx context.Context, id uint) (*Session, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Read)
+	defer cancel()
+
+	return t.repository.GetSession(ctx, id)
+}
+
+// GetSessionByRefreshToken retrieves the session of a refresh token with the read timeout
+func (t *timeoutRepository) GetSessionByRefreshToken(ctx context.Context, refreshTokenHash string) (*Session, error) {
+	ctx, cancel := withTimeout(ctx, t.timeouts.Read)
+	defer cancel()
+
+	return t.repository.GetSessionByRefreshToken(ctx, refreshTokenHash)
+}
@@ -0,0 +1,74 @@
+package repository_test
+
+import (
+	"Chat-Server/repository"
+	mockdb "Chat-Server/repository/mock"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// requireDeadline returns a gomock action requiring the context of a call to be done within the input timeout
+func requireDeadline(t *testing.T, timeout time.Duration) func(ctx context.Context, _ any) {
+	return func(ctx context.Context, _ any) {
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+		require.WithinDuration(t, time.Now().Add(timeout), deadline, timeout/2)
+	}
+}
+
+// TestWithTimeouts tests WithTimeouts
+func TestWithTimeouts(t *testing.T) {
+	t.Run("ReadAndWriteTimeouts", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockdb.NewMockRepository(ctrl)
+		r := repository.WithTimeouts(repo, repository.Timeouts{Read: time.Second, Write: time.Minute})
+
+		repo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(1).Do(requireDeadline(t, time.Second))
+		repo.EXPECT().AddUser(gomock.Any(), gomock.Any()).Times(1).Do(requireDeadline(t, time.Minute))
+
+		_, _ = r.GetUser(context.Background(), "username")
+		_, _ = r.AddUser(context.Background(), &repository.User{})
+	})
+	t.Run("CallerDeadlineIsKept", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockdb.NewMockRepository(ctrl)
+		r := repository.WithTimeouts(repo, repository.Timeouts{Read: time.Hour})
+
+		repo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(1).Do(requireDeadline(t, time.Second))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, _ = r.GetUser(ctx, "username")
+	})
+	t.Run("NoTimeout", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockdb.NewMockRepository(ctrl)
+		r := repository.WithTimeouts(repo, repository.Timeouts{})
+
+		repo.EXPECT().GetAllMessages(gomock.Any()).Times(1).Do(func(ctx context.Context) {
+			_, ok := ctx.Deadline()
+			require.False(t, ok)
+		})
+
+		_, _ = r.GetAllMessages(context.Background())
+	})
+	t.Run("DeadlineExceeded", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockdb.NewMockRepository(ctrl)
+		r := repository.WithTimeouts(repo, repository.Timeouts{Write: 10 * time.Millisecond})
+
+		repo.EXPECT().AddMessage(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+			func(ctx context.Context, _ *repository.Message) (*repository.Message, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+		)
+
+		_, err := r.AddMessage(context.Background(), &repository.Message{})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
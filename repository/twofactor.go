@@ -0,0 +1,19 @@
+package repository
+
+import "time"
+
+// TwoFactor is the TOTP authenticator of a user
+type TwoFactor struct {
+	// Username of the user
+	Username string
+	// Secret is the base32 secret shared with the authenticator
+	Secret string
+	// Enabled is false until the user confirms the enrollment with a code of the authenticator
+	Enabled bool
+	// RecoveryCodes are the hashes of the unused recovery codes
+	RecoveryCodes []string
+	// LastStep is the time step of the last accepted code, the codes of it and of the steps before are rejected
+	LastStep int64
+	// CreatedAt is the time of the enrollment
+	CreatedAt time.Time
+}
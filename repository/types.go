@@ -1,11 +1,87 @@
 package repository
 
+import "time"
+
 // The Message represents a repository message
 type Message struct {
+	// ID of the message, assigned when the message is saved
+	ID uint
 	// Text of the message
 	Text string
+	// Rendered is the sanitized html of the text, empty for messages saved before the texts were rendered
+	Rendered string
+	// Entities are the mentions, links and code spans of the text
+	Entities []Entity
 	// Author of the message (username of the person who sent the message)
 	Author string
+	// Room the message is sent to, DefaultRoom if empty
+	Room string
+	// CreatedAt is the time the message is saved
+	CreatedAt time.Time
+	// Attachments of the message, only their IDs are needed to attach them when adding the message
+	Attachments []*Attachment
+	// Notifications of the existing users mentioned in the message, created when the message is saved
+	Notifications []*Notification
+}
+
+// DefaultRoom is the room of the messages sent without a room
+const DefaultRoom = "general"
+
+// RoomName returns the room of the message, DefaultRoom if the message has no room
+func (m *Message) RoomName() string {
+	if m.Room == "" {
+		return DefaultRoom
+	}
+	return m.Room
+}
+
+// Entity represents a structured element of the text of a message, its offset and length are in unicode
+// code points of the text
+type Entity struct {
+	// Type of the entity: mention, link or code
+	Type string
+	// Offset of the entity in the text
+	Offset int
+	// Length of the entity in the text
+	Length int
+	// Value of the entity: the username of a mention, the url of a link or the code of a code span
+	Value string
+}
+
+// Attachment represents a file uploaded by a user to be attached to a message, the content of the
+// file is kept in a blob store under the ID of the attachment
+type Attachment struct {
+	// ID of the attachment, assigned by the uploader
+	ID string
+	// Owner of the attachment (username of the person who uploaded the file)
+	Owner string
+	// MessageID is the ID of the message the attachment is attached to, 0 until it is attached
+	MessageID uint
+	// Filename of the uploaded file
+	Filename string
+	// ContentType of the file
+	ContentType string
+	// Size of the file in bytes
+	Size int64
+	// Checksum is the hex encoded sha256 hash of the file
+	Checksum string
+	// Width and Height of an image in pixels, 0 for other files
+	Width, Height int
+	// Thumbnail of an image, nil until it is generated and for other files
+	Thumbnail *Thumbnail
+	// CreatedAt is the time the attachment is uploaded
+	CreatedAt time.Time
+}
+
+// Thumbnail represents the thumbnail of an image attachment, the thumbnail is kept in a blob store
+// next to the image
+type Thumbnail struct {
+	// ContentType of the thumbnail
+	ContentType string
+	// Width and Height of the thumbnail in pixels
+	Width, Height int
+	// Size of the thumbnail in bytes
+	Size int64
 }
 
 // User represents a repository user
@@ -14,4 +90,28 @@ type User struct {
 	Username string
 	// Password of the user
 	Password string
+	// Email is the optional email address of the user, empty if the user has none
+	Email string
+	// EmailVerified reports whether the user opened the verification link sent to the email address
+	EmailVerified bool
+}
+
+// Session represents a repository user session
+type Session struct {
+	// ID of the session, assigned when the session is saved
+	ID uint
+	// Username of the session's user
+	Username string
+	// RefreshToken is the hex encoded sha256 hash of the refresh token of the session
+	RefreshToken string
+	// UserAgent of the client which started the session
+	UserAgent string
+	// ClientIP of the client which started the session
+	ClientIP string
+	// IsBlocked reports whether the session is blocked
+	IsBlocked bool
+	// CreatedAt is the time the session is created
+	CreatedAt time.Time
+	// ExpiresAt is the time the session expires
+	ExpiresAt time.Time
 }
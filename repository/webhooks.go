@@ -0,0 +1,58 @@
+package repository
+
+import "time"
+
+// Webhook represents an http endpoint registered by an admin to receive the events of the chat
+type Webhook struct {
+	// ID of the webhook, assigned when the webhook is saved
+	ID uint
+	// URL the events are posted to
+	URL string
+	// Secret is the key of the HMAC signatures of the deliveries
+	Secret string
+	// Events are the types of the events the webhook subscribes to
+	Events []string
+	// CreatedBy is the username of the admin who registered the webhook
+	CreatedBy string
+	// Failures is the number of consecutive events the webhook failed to receive
+	Failures int
+	// Disabled reports whether the webhook is disabled after too many consecutive failures, disabled
+	// webhooks receive no events until they are enabled again
+	Disabled bool
+	// CreatedAt is the time the webhook is registered
+	CreatedAt time.Time
+}
+
+// Subscribes reports whether the webhook is enabled and subscribes to the input event type
+func (w *Webhook) Subscribes(eventType string) bool {
+	if w.Disabled {
+		return false
+	}
+	for _, event := range w.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery represents an attempt to deliver an event to a webhook
+type WebhookDelivery struct {
+	// ID of the delivery attempt, assigned when the attempt is saved
+	ID uint
+	// WebhookID is the ID of the webhook the event is delivered to
+	WebhookID uint
+	// EventID and EventType of the delivered event
+	EventID   string
+	EventType string
+	// Attempt is the number of the attempt, starting from 1
+	Attempt int
+	// StatusCode of the response of the webhook, 0 if there is no response
+	StatusCode int
+	// Error of a failed attempt, empty if the attempt succeeded
+	Error string
+	// Duration of the attempt
+	Duration time.Duration
+	// CreatedAt is the time the attempt is made
+	CreatedAt time.Time
+}
@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// errors returned by MessageWriter
+var (
+	ErrWriterQueueFull = errors.New("message writer queue is full")
+	ErrWriterClosed    = errors.New("message writer is closed")
+)
+
+// WriteCallback is called with the saved message, or the error, once a queued message is written
+type WriteCallback func(message *Message, err error)
+
+// MessageWriterConfig holds the configurations of a MessageWriter
+type MessageWriterConfig struct {
+	QueueSize    int           // maximum number of messages waiting to be written
+	BatchSize    int           // maximum number of messages written in a single insert
+	BatchWindow  time.Duration // maximum time a batch waits for more messages
+	MaxRetries   int           // maximum number of retries of a batch failed with ErrUnavailable, but not ErrUncertain
+	RetryBackoff time.Duration // backoff before the first retry, doubled after each retry
+}
+
+// MessageWriter is a bounded write pipeline in front of a Repository. queued messages are
+// written in batches with multi-row inserts, one batch after another, so messages are
+// saved in the same order they are queued in
+type MessageWriter struct {
+	repository Repository
+	config     MessageWriterConfig
+
+	// messages waiting to be written
+	queue chan queuedMessage
+
+	// mu guards closed so no message is queued after the queue is closed
+	mu     sync.RWMutex
+	closed bool
+
+	// done is closed when all queued messages are written after the writer is closed
+	done chan struct{}
+}
+
+// queuedMessage is a message waiting to be written
+type queuedMessage struct {
+	message  *Message
+	callback WriteCallback
+}
+
+// NewMessageWriter creates a MessageWriter and starts writing messages queued in it into the input repository
+func NewMessageWriter(repository Repository, config MessageWriterConfig) *MessageWriter {
+	if config.QueueSize < 1 {
+		config.QueueSize = 1
+	}
+	if config.BatchSize < 1 {
+		config.BatchSize = 1
+	}
+
+	w := &MessageWriter{
+		repository: repository,
+		config:     config,
+		queue:      make(chan queuedMessage, config.QueueSize),
+		done:       make(chan struct{}),
+	}
+	go w.run()
+
+	return w
+}
+
+// Write queues the input message to be written, the callback is called from the writer's go routine
+// once the message is written. returns ErrWriterQueueFull without blocking if the queue is full
+func (w *MessageWriter) Write(message *Message, callback WriteCallback) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return ErrWriterClosed
+	}
+
+	select {
+	case w.queue <- queuedMessage{message: message, callback: callback}:
+		return nil
+	default:
+		return ErrWriterQueueFull
+	}
+}
+
+// QueueDepth returns the number of messages waiting to be written
+func (w *MessageWriter) QueueDepth() int {
+	return len(w.queue)
+}
+
+// Close stops accepting new messages and waits until all queued messages are written
+// or the input context is done
+func (w *MessageWriter) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run collects queued messages into batches and writes them until the queue is closed and drained
+func (w *MessageWriter) run() {
+	defer close(w.done)
+
+	for {
+		// wait for the first message of the batch
+		queued, ok := <-w.queue
+		if !ok {
+			return
+		}
+		batch := []queuedMessage{queued}
+
+		// collect more messages until the batch is full or the batch window is over
+		window := time.NewTimer(w.config.BatchWindow)
+	collect:
+		for len(batch) < w.config.BatchSize {
+			select {
+			case queued, ok = <-w.queue:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, queued)
+			case <-window.C:
+				break collect
+			}
+		}
+		window.Stop()
+
+		w.writeBatch(batch)
+		if !ok {
+			return
+		}
+	}
+}
+
+// writeBatch writes a batch of messages with a multi-row insert and calls their callbacks. if the
+// batch fails while the repository is available its messages are written one by one so a single
+// invalid message does not fail the others
+func (w *MessageWriter) writeBatch(batch []queuedMessage) {
+	messages := make([]*Message, len(batch))
+	for i, queued := range batch {
+		messages[i] = queued.message
+	}
+
+	savedMessages, err := w.addMessages(messages)
+	if err == nil {
+		for i, queued := range batch {
+			queued.callback(savedMessages[i], nil)
+		}
+		return
+	}
+
+	if len(batch) == 1 || errors.Is(err, ErrUnavailable) {
+		for _, queued := range batch {
+			queued.callback(nil, err)
+		}
+		return
+	}
+
+	for _, queued := range batch {
+		savedMessage, err := w.repository.AddMessage(context.Background(), queued.message)
+		queued.callback(savedMessage, err)
+	}
+}
+
+// addMessages adds the input messages to the repository, retrying with backoff while the repository is unavailable.
+// queued messages outlive the requests which queued them, so writes are bounded only by the repository timeouts.
+// a batch which may have been inserted anyway is not retried, since the messages would be inserted twice
+func (w *MessageWriter) addMessages(messages []*Message) ([]*Message, error) {
+	backoff := w.config.RetryBackoff
+
+	for retry := 0; ; retry++ {
+		savedMessages, err := w.repository.AddMessages(context.Background(), messages)
+		if err == nil || retry >= w.config.MaxRetries || !errors.Is(err, ErrUnavailable) || errors.Is(err, ErrUncertain) {
+			return savedMessages, err
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
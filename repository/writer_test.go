@@ -0,0 +1,240 @@
+package repository_test
+
+import (
+	"Chat-Server/repository"
+	mockdb "Chat-Server/repository/mock"
+	"Chat-Server/util"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// errTransient is a transient error returned by a repository
+var errTransient = fmt.Errorf("%w: connection reset by peer", repository.ErrUnavailable)
+
+// writeResult is the result of a written message passed to its callback
+type writeResult struct {
+	message *repository.Message
+	err     error
+}
+
+// writeRandomMessages queues n random messages and returns them and a channel receiving their results
+func writeRandomMessages(t *testing.T, writer *repository.MessageWriter, n int) ([]*repository.Message, chan writeResult) {
+	messages := make([]*repository.Message, n)
+	results := make(chan writeResult, n)
+
+	for i := range messages {
+		messages[i] = &repository.Message{Author: util.RandomUsername(), Text: util.RandomText()}
+		err := writer.Write(messages[i], func(message *repository.Message, err error) {
+			results <- writeResult{message: message, err: err}
+		})
+		require.NoError(t, err)
+	}
+
+	return messages, results
+}
+
+// receiveResult waits for the next write result
+func receiveResult(t *testing.T, results chan writeResult) writeResult {
+	select {
+	case result := <-results:
+		return result
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "message was not written")
+		return writeResult{}
+	}
+}
+
+// saveMessages returns the input messages with ids assigned, like a repository does
+func saveMessages(_ context.Context, messages []*repository.Message) ([]*repository.Message, error) {
+	saved := make([]*repository.Message, len(messages))
+	for i, message := range messages {
+		saved[i] = &repository.Message{ID: uint(i + 1), Author: message.Author, Text: message.Text}
+	}
+	return saved, nil
+}
+
+// TestMessageWriter tests MessageWriter
+func TestMessageWriter(t *testing.T) {
+	t.Run("BatchByCount", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockdb.NewMockRepository(ctrl)
+		repo.EXPECT().AddMessages(gomock.Any(), gomock.Len(3)).Times(1).DoAndReturn(saveMessages)
+
+		writer := repository.NewMessageWriter(repo, repository.MessageWriterConfig{
+			QueueSize:   10,
+			BatchSize:   3,
+			BatchWindow: time.Hour,
+		})
+		defer writer.Close(context.Background())
+
+		messages, results := writeRandomMessages(t, writer, 3)
+		for i, message := range messages {
+			result := receiveResult(t, results)
+			require.NoError(t, result.err)
+			require.Equal(t, uint(i+1), result.message.ID)
+			require.Equal(t, message.Text, result.message.Text)
+		}
+	})
+	t.Run("BatchByWindow", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockdb.NewMockRepository(ctrl)
+		repo.EXPECT().AddMessages(gomock.Any(), gomock.Len(2)).Times(1).DoAndReturn(saveMessages)
+
+		writer := repository.NewMessageWriter(repo, repository.MessageWriterConfig{
+			QueueSize:   10,
+			BatchSize:   10,
+			BatchWindow: 50 * time.Millisecond,
+		})
+		defer writer.Close(context.Background())
+
+		_, results := writeRandomMessages(t, writer, 2)
+		for i := 0; i < 2; i++ {
+			require.NoError(t, receiveResult(t, results).err)
+		}
+	})
+	t.Run("RetryTransientError", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockdb.NewMockRepository(ctrl)
+		gomock.InOrder(
+			repo.EXPECT().AddMessages(gomock.Any(), gomock.Len(1)).Times(2).Return(nil, errTransient),
+			repo.EXPECT().AddMessages(gomock.Any(), gomock.Len(1)).Times(1).DoAndReturn(saveMessages),
+		)
+
+		writer := repository.NewMessageWriter(repo, repository.MessageWriterConfig{
+			QueueSize:    10,
+			BatchSize:    1,
+			MaxRetries:   2,
+			RetryBackoff: time.Millisecond,
+		})
+		defer writer.Close(context.Background())
+
+		_, results := writeRandomMessages(t, writer, 1)
+		require.NoError(t, receiveResult(t, results).err)
+	})
+	t.Run("UncertainErrorNotRetried", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockdb.NewMockRepository(ctrl)
+		errUncertain := fmt.Errorf("%w: %w: %w", repository.ErrUnavailable, repository.ErrUncertain, context.DeadlineExceeded)
+
+		// the batch may have been inserted, so it is neither retried nor inserted message by message
+		repo.EXPECT().AddMessages(gomock.Any(), gomock.Len(2)).Times(1).Return(nil, errUncertain)
+
+		writer := repository.NewMessageWriter(repo, repository.MessageWriterConfig{
+			QueueSize:    10,
+			BatchSize:    2,
+			BatchWindow:  time.Hour,
+			MaxRetries:   2,
+			RetryBackoff: time.Millisecond,
+		})
+		defer writer.Close(context.Background())
+
+		_, results := writeRandomMessages(t, writer, 2)
+		for i := 0; i < 2; i++ {
+			require.ErrorIs(t, receiveResult(t, results).err, repository.ErrUncertain)
+		}
+	})
+	t.Run("RetriesExhausted", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockdb.NewMockRepository(ctrl)
+		repo.EXPECT().AddMessages(gomock.Any(), gomock.Len(2)).Times(3).Return(nil, errTransient)
+
+		writer := repository.NewMessageWriter(repo, repository.MessageWriterConfig{
+			QueueSize:    10,
+			BatchSize:    2,
+			BatchWindow:  time.Hour,
+			MaxRetries:   2,
+			RetryBackoff: time.Millisecond,
+		})
+		defer writer.Close(context.Background())
+
+		_, results := writeRandomMessages(t, writer, 2)
+		for i := 0; i < 2; i++ {
+			require.ErrorIs(t, receiveResult(t, results).err, errTransient)
+		}
+	})
+	t.Run("FallbackToSingleInserts", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockdb.NewMockRepository(ctrl)
+		errAuthorNotFound := fmt.Errorf("%w: violates foreign key constraint", repository.ErrAuthorNotFound)
+
+		writer := repository.NewMessageWriter(repo, repository.MessageWriterConfig{
+			QueueSize:   10,
+			BatchSize:   2,
+			BatchWindow: time.Hour,
+			MaxRetries:  2,
+		})
+		defer writer.Close(context.Background())
+
+		// the whole batch fails because of its first message, so the messages are written one by one
+		repo.EXPECT().AddMessages(gomock.Any(), gomock.Len(2)).Times(1).Return(nil, errAuthorNotFound)
+		gomock.InOrder(
+			repo.EXPECT().AddMessage(gomock.Any(), gomock.Any()).Times(1).Return(nil, errAuthorNotFound),
+			repo.EXPECT().AddMessage(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+				func(_ context.Context, message *repository.Message) (*repository.Message, error) {
+					return message, nil
+				},
+			),
+		)
+
+		messages, results := writeRandomMessages(t, writer, 2)
+
+		result := receiveResult(t, results)
+		require.ErrorIs(t, result.err, errAuthorNotFound)
+		require.Nil(t, result.message)
+
+		result = receiveResult(t, results)
+		require.NoError(t, result.err)
+		require.Equal(t, messages[1], result.message)
+	})
+	t.Run("QueueFull", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockdb.NewMockRepository(ctrl)
+
+		// block the writer on its first batch so the next messages stay in the queue
+		unblock := make(chan struct{})
+		repo.EXPECT().AddMessages(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+			func(ctx context.Context, messages []*repository.Message) ([]*repository.Message, error) {
+				<-unblock
+				return saveMessages(ctx, messages)
+			},
+		)
+
+		writer := repository.NewMessageWriter(repo, repository.MessageWriterConfig{QueueSize: 2, BatchSize: 1})
+		defer writer.Close(context.Background())
+
+		_, results := writeRandomMessages(t, writer, 1)
+		require.Eventually(t, func() bool { return writer.QueueDepth() == 0 }, time.Second, time.Millisecond)
+
+		writeRandomMessages(t, writer, 2)
+		require.Equal(t, 2, writer.QueueDepth())
+
+		err := writer.Write(&repository.Message{}, func(*repository.Message, error) {})
+		require.ErrorIs(t, err, repository.ErrWriterQueueFull)
+
+		close(unblock)
+		require.NoError(t, receiveResult(t, results).err)
+	})
+	t.Run("CloseFlushesQueue", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockdb.NewMockRepository(ctrl)
+		repo.EXPECT().AddMessages(gomock.Any(), gomock.Len(3)).Times(1).DoAndReturn(saveMessages)
+
+		writer := repository.NewMessageWriter(repo, repository.MessageWriterConfig{
+			QueueSize:   10,
+			BatchSize:   10,
+			BatchWindow: time.Hour,
+		})
+
+		_, results := writeRandomMessages(t, writer, 3)
+		require.NoError(t, writer.Close(context.Background()))
+		require.Len(t, results, 3)
+
+		err := writer.Write(&repository.Message{}, func(*repository.Message, error) {})
+		require.ErrorIs(t, err, repository.ErrWriterClosed)
+	})
+}
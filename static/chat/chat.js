@@ -33,6 +33,11 @@ document.addEventListener('DOMContentLoaded', async () => {
     const chatWindow = document.getElementById('chat-window');
     const messageInput = document.getElementById('message-input');
     const sendButton = document.getElementById('send-button');
+    const attachButton = document.getElementById('attach-button');
+    const fileInput = document.getElementById('file-input');
+    const notificationsButton = document.getElementById('notifications-button');
+    const unreadCount = document.getElementById('unread-count');
+    let unreadNotifications = [];
 
     socket.onerror = () => {
         alert('Not Authorized. Please login.');
@@ -41,8 +46,27 @@ document.addEventListener('DOMContentLoaded', async () => {
 
     socket.onmessage = (event) => {
         const data = JSON.parse(event.data);
-        console.log(data)
-        addMessage(data.author, data.text);
+        switch (data.type) {
+            case 'message':
+                addMessage(data.message);
+                break;
+            case 'reply':
+                addReply(data.message);
+                break;
+            case 'preview':
+                updatePreviews(data.message);
+                break;
+            case 'attachment':
+                updateAttachment(data.attachment);
+                break;
+            case 'notification':
+                unreadNotifications.unshift(data.notification);
+                setUnreadCount(unreadCount.textContent === '' ? 1 : Number(unreadCount.textContent) + 1);
+                break;
+            case 'error':
+                alert(`Error: ${data.error}`);
+                break;
+        }
     };
 
     sendButton.addEventListener('click', () => {
@@ -55,19 +79,202 @@ document.addEventListener('DOMContentLoaded', async () => {
         }
     });
 
-    function sendMessage() {
+    notificationsButton.addEventListener('click', async () => {
+        // jump to the latest unread mention and mark all the mentions as read
+        const latest = unreadNotifications[0];
+        const messageElement = latest && chatWindow.querySelector(`[data-message-id="${latest.message_id}"]`);
+        if (messageElement) {
+            messageElement.scrollIntoView({behavior: 'smooth'});
+        }
+
+        const response = await fetch('https://chat-hub.liara.run/api/notifications/read', {method: 'POST'});
+        if (response.ok) {
+            unreadNotifications = [];
+            setUnreadCount(0);
+        }
+    });
+
+    function setUnreadCount(count) {
+        unreadCount.textContent = count > 0 ? String(count) : '';
+        unreadCount.hidden = count === 0;
+    }
+
+    async function loadNotifications() {
+        const response = await fetch('https://chat-hub.liara.run/api/notifications?unread=true');
+        if (response.ok) {
+            const data = await response.json();
+            unreadNotifications = data.notifications;
+            setUnreadCount(data.unread_count);
+        }
+    }
+
+    loadNotifications();
+
+    attachButton.addEventListener('click', () => {
+        fileInput.click();
+    });
+
+    fileInput.addEventListener('change', () => {
+        attachButton.classList.toggle('has-files', fileInput.files.length > 0);
+    });
+
+    async function uploadFile(file) {
+        const form = new FormData();
+        form.append('file', file);
+
+        const response = await fetch('https://chat-hub.liara.run/api/attachments', {
+            method: 'POST',
+            body: form,
+        });
+        const data = await response.json();
+        if (!response.ok) {
+            throw new Error(`${file.name}: ${data.error}`);
+        }
+        return data.id;
+    }
+
+    async function sendMessage() {
         const text = messageInput.value.trim();
-        if (text !== '') {
-            socket.send(text);
-            messageInput.value = '';
+        const files = Array.from(fileInput.files);
+        if (text === '' && files.length === 0) {
+            return;
+        }
+
+        let attachments = [];
+        try {
+            attachments = await Promise.all(files.map(uploadFile));
+        } catch (error) {
+            alert(`Error: ${error.message}`);
+            return;
         }
+
+        socket.send(JSON.stringify({text: text, attachments: attachments}));
+        messageInput.value = '';
+        fileInput.value = '';
+        attachButton.classList.remove('has-files');
     }
 
-    function addMessage(author, text) {
+    function renderAttachment(attachment) {
+        const attachmentElement = document.createElement('div');
+        attachmentElement.classList.add('attachment');
+        attachmentElement.dataset.attachmentId = attachment.id;
+
+        const link = document.createElement('a');
+        link.href = attachment.url;
+        if (attachment.content_type.startsWith('image/')) {
+            // the thumbnail is shown once generated, the full image is opened by clicking it
+            const image = document.createElement('img');
+            const preview = attachment.thumbnail || attachment;
+            image.src = preview.url;
+            image.alt = attachment.filename;
+            if (preview.width && preview.height) {
+                image.width = preview.width;
+                image.height = preview.height;
+            }
+            link.target = '_blank';
+            link.appendChild(image);
+        } else {
+            link.textContent = attachment.filename;
+        }
+        attachmentElement.appendChild(link);
+
+        return attachmentElement;
+    }
+
+    function addAttachment(messageElement, attachment) {
+        messageElement.appendChild(renderAttachment(attachment));
+    }
+
+    function updateAttachment(attachment) {
+        chatWindow.querySelectorAll('.attachment').forEach(element => {
+            if (element.dataset.attachmentId === attachment.id) {
+                element.replaceWith(renderAttachment(attachment));
+            }
+        });
+    }
+
+    function renderPreview(preview) {
+        const link = document.createElement('a');
+        link.classList.add('preview');
+        link.href = preview.url;
+        link.target = '_blank';
+        link.rel = 'noopener noreferrer';
+
+        if (preview.image) {
+            const image = document.createElement('img');
+            image.src = preview.image;
+            image.alt = '';
+            image.referrerPolicy = 'no-referrer';
+            link.appendChild(image);
+        }
+        [['site-name', preview.site_name], ['title', preview.title], ['description', preview.description]].forEach(([name, value]) => {
+            if (value) {
+                const element = document.createElement('div');
+                element.classList.add(name);
+                element.textContent = value;
+                link.appendChild(element);
+            }
+        });
+
+        return link;
+    }
+
+    function addPreviews(messageElement, previews) {
+        messageElement.querySelectorAll('.preview').forEach(element => element.remove());
+        previews.forEach(preview => messageElement.appendChild(renderPreview(preview)));
+    }
+
+    // the messages are matched by their keys, the messages broadcast before they are saved have no id yet
+    function updatePreviews(message) {
+        const messageElement = chatWindow.querySelector(`[data-message-key="${message.key}"]`);
+        if (messageElement) {
+            if (message.id) {
+                messageElement.dataset.messageId = message.id;
+            }
+            addPreviews(messageElement, message.previews || []);
+        }
+    }
+
+    // the replies to the commands are only sent to the invoker and are not saved, so they are styled apart
+    // from the messages and are gone once the page is reloaded
+    function addReply(message) {
+        addMessage({...message, author: `${message.author || 'chat hub'} (only visible to you)`}, true);
+    }
+
+    function addMessage(message, ephemeral = false) {
         const messageElement = document.createElement('div');
         messageElement.classList.add('chat-message');
-        messageElement.classList.add(author === username ? 'right' : 'left');
-        messageElement.innerHTML = `<div class="sender-id">${author}</div><div class="text">${text}</div>`;
+        messageElement.classList.add(message.author === username ? 'right' : 'left');
+        if (ephemeral) {
+            messageElement.classList.add('ephemeral');
+        }
+        if (message.id) {
+            messageElement.dataset.messageId = message.id;
+        }
+        if (message.key) {
+            messageElement.dataset.messageKey = message.key;
+        }
+        const authorElement = document.createElement('div');
+        authorElement.classList.add('sender-id');
+        authorElement.textContent = message.author;
+
+        // the html of the text is rendered and sanitized by the server, the raw text is never inserted as html
+        const textElement = document.createElement('div');
+        textElement.classList.add('text');
+        if (message.html) {
+            textElement.innerHTML = message.html;
+        } else {
+            textElement.textContent = message.text;
+        }
+        textElement.querySelectorAll('.mention').forEach(mention => {
+            if (mention.dataset.username === username) {
+                mention.classList.add('self');
+            }
+        });
+
+        messageElement.append(authorElement, textElement);
+        (message.attachments || []).forEach(attachment => addAttachment(messageElement, attachment));
+        addPreviews(messageElement, message.previews || []);
         chatWindow.appendChild(messageElement);
         chatWindow.scrollTop = chatWindow.scrollHeight;
     }
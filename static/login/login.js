@@ -35,6 +35,30 @@ document.addEventListener('DOMContentLoaded', function() {
                 return;
             }
 
+            // users with two-factor authentication send a code of their authenticator, or a recovery code
+            const body = await response.text();
+            const challenge = body ? JSON.parse(body) : {};
+            if (challenge.two_factor_token) {
+                const code = prompt('Enter the code of your authenticator app, or a recovery code:');
+                if (!code) {
+                    return;
+                }
+
+                const secondStep = await fetch('https://chat-hub.liara.run/api/login/2fa', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    body: JSON.stringify({ two_factor_token: challenge.two_factor_token, code: code.trim() })
+                });
+
+                if (!secondStep.ok) {
+                    const errorData = await secondStep.json();
+                    alert(`Error: ${errorData.error}`);
+                    return;
+                }
+            }
+
             window.location.href = 'https://chat-hub.liara.run/chat';
         } catch (error) {
             console.error('Error:', error);
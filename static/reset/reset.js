@@ -0,0 +1,90 @@
+document.addEventListener('DOMContentLoaded', function() {
+    const forgotForm = document.getElementById('forgot-form');
+    const resetForm = document.getElementById('reset-form');
+    const token = new URLSearchParams(window.location.search).get('token');
+
+    // the link of the reset email has the token, the new password is chosen with it
+    if (token) {
+        forgotForm.hidden = true;
+        resetForm.hidden = false;
+    }
+
+    forgotForm.addEventListener('submit', async function(event) {
+        event.preventDefault();
+
+        const username = forgotForm.querySelector('input[name="username"]').value;
+
+        try {
+            const response = await fetch('https://chat-hub.liara.run/api/password/forgot', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json'
+                },
+                body: JSON.stringify({ username })
+            });
+
+            if (!response.ok) {
+                const errorData = await response.json();
+                alert(`Error: ${errorData.error}`);
+                return;
+            }
+
+            alert('If the account exists, a reset link is on its way.');
+        } catch (error) {
+            console.error('Error:', error);
+            alert('An error occurred while requesting the reset link. Please try again.');
+        }
+    });
+
+    resetForm.addEventListener('submit', async function(event) {
+        event.preventDefault();
+
+        const password = resetForm.querySelector('input[name="password"]').value;
+        const confirm = resetForm.querySelector('input[name="confirm"]').value;
+
+        const passwordError = validatePassword(password);
+        if (passwordError) {
+            alert(`Password Error: ${passwordError}`);
+            return;
+        }
+        if (password !== confirm) {
+            alert('Password Error: The passwords do not match.');
+            return;
+        }
+
+        try {
+            const response = await fetch('https://chat-hub.liara.run/api/password/reset', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json'
+                },
+                body: JSON.stringify({ token, new_password: password })
+            });
+
+            if (!response.ok) {
+                const errorData = await response.json();
+                alert(`Error: ${errorData.error}`);
+                return;
+            }
+
+            alert('Your password is reset, log in with the new password.');
+            window.location.href = 'https://chat-hub.liara.run/login';
+        } catch (error) {
+            console.error('Error:', error);
+            alert('An error occurred while resetting the password. Please try again.');
+        }
+    });
+
+    function validatePassword(password) {
+        if (password.length < 8) {
+            return "Password must be at least 8 characters.";
+        }
+        if (password.length > 64) {
+            return "Password must be at most 64 characters.";
+        }
+        if (!/^[a-zA-Z0-9_!@#$%&*^.]*$/.test(password)) {
+            return "Invalid character in password; only alphabets, digits, and the following special characters are allowed: _!@#$%&*.^";
+        }
+        return null;
+    }
+});
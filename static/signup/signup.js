@@ -6,6 +6,7 @@ document.addEventListener('DOMContentLoaded', function() {
 
         const username = form.querySelector('input[name="username"]').value;
         const password = form.querySelector('input[name="password"]').value;
+        const email = form.querySelector('input[name="email"]').value.trim();
 
         const usernameError = validateUsername(username);
         const passwordError = validatePassword(password);
@@ -26,7 +27,7 @@ document.addEventListener('DOMContentLoaded', function() {
                 headers: {
                     'Content-Type': 'application/json'
                 },
-                body: JSON.stringify({ username, password })
+                body: JSON.stringify({ username, password, email })
             });
 
             if (!response.ok) {
@@ -35,6 +36,10 @@ document.addEventListener('DOMContentLoaded', function() {
                 return;
             }
 
+            // the verification link is sent to the email address, the account works until it is opened
+            if (email) {
+                alert('A verification link is on its way to your email address.');
+            }
             window.location.href = 'https://chat-hub.liara.run/chat';
         } catch (error) {
             console.error('Error:', error);
@@ -0,0 +1,30 @@
+document.addEventListener('DOMContentLoaded', async function() {
+    const status = document.getElementById('status');
+    const token = new URLSearchParams(window.location.search).get('token');
+
+    if (!token) {
+        status.textContent = 'The verification link is missing its token.';
+        return;
+    }
+
+    try {
+        const response = await fetch('https://chat-hub.liara.run/api/email/verify', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify({ token })
+        });
+
+        if (!response.ok) {
+            const errorData = await response.json();
+            status.textContent = `Error: ${errorData.error}`;
+            return;
+        }
+
+        status.textContent = 'Your email address is verified.';
+    } catch (error) {
+        console.error('Error:', error);
+        status.textContent = 'An error occurred while verifying the email address. Please try again.';
+    }
+});
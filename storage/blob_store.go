@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// errors returned by the blob stores
+var (
+	ErrNotFound     = errors.New("blob not found")
+	ErrInvalidKey   = errors.New("invalid blob key")
+	ErrSizeMismatch = errors.New("blob size does not match its content")
+)
+
+// BlobStore stores files, blobs, under keys. keys are slash separated paths of segments made of
+// letters, digits, '-', '_' and '.', so they are safe as file paths and as urls
+type BlobStore interface {
+	// Put stores the content read from the input reader under the input key, replacing the blob stored
+	// under the key if there is one. the content must be exactly of the input size
+	Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error
+
+	// Get returns a reader of the blob stored under the input key, the reader must be closed.
+	// returns ErrNotFound if there is no blob under the key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// Delete deletes the blob stored under the input key, deleting a missing blob is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// validateKey returns ErrInvalidKey if the input key is not a valid blob key
+func validateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("%w: empty key", ErrInvalidKey)
+	}
+
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+		}
+
+		for _, r := range segment {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			case r == '-', r == '_', r == '.':
+			default:
+				return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// contextReader is a reader which stops reading once its context is done
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+// Read reads from the underlying reader unless the context is done
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testBlobStore runs the tests every BlobStore implementation must pass against the input store
+func testBlobStore(t *testing.T, store BlobStore) {
+	ctx := context.Background()
+
+	t.Run("PutGet", func(t *testing.T) {
+		content := []byte("hello world")
+		require.NoError(t, store.Put(ctx, "attachments/put-get.txt", bytes.NewReader(content), int64(len(content)), "text/plain"))
+
+		reader, err := store.Get(ctx, "attachments/put-get.txt")
+		require.NoError(t, err)
+		defer reader.Close()
+
+		got, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.Equal(t, content, got)
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		require.NoError(t, store.Put(ctx, "replace", strings.NewReader("first"), 5, ""))
+		require.NoError(t, store.Put(ctx, "replace", strings.NewReader("second"), 6, ""))
+
+		reader, err := store.Get(ctx, "replace")
+		require.NoError(t, err)
+		defer reader.Close()
+
+		got, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.Equal(t, "second", string(got))
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := store.Get(ctx, "missing")
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		require.NoError(t, store.Put(ctx, "delete", strings.NewReader("text"), 4, ""))
+		require.NoError(t, store.Delete(ctx, "delete"))
+
+		_, err := store.Get(ctx, "delete")
+		require.ErrorIs(t, err, ErrNotFound)
+
+		// deleting a missing blob is not an error
+		require.NoError(t, store.Delete(ctx, "delete"))
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		for _, key := range []string{"", "../escape", "a//b", "a/./b", "/absolute", "space key", "query?key"} {
+			err := store.Put(ctx, key, strings.NewReader("text"), 4, "")
+			require.ErrorIs(t, err, ErrInvalidKey, key)
+
+			_, err = store.Get(ctx, key)
+			require.ErrorIs(t, err, ErrInvalidKey, key)
+		}
+	})
+
+	t.Run("CanceledContext", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		require.ErrorIs(t, store.Put(canceled, "canceled", strings.NewReader("text"), 4, ""), context.Canceled)
+
+		_, err := store.Get(ctx, "canceled")
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FileStore implements BlobStore on the local filesystem, every blob is a file under the root directory
+type FileStore struct {
+	root string
+}
+
+// ensure FileStore implements BlobStore interface
+var _ BlobStore = (*FileStore)(nil)
+
+// NewFileStore returns a FileStore storing blobs under the input directory, which is created if missing
+func NewFileStore(root string) (*FileStore, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, err
+	}
+
+	return &FileStore{root: root}, nil
+}
+
+// Root returns the directory the blobs are stored under
+func (s *FileStore) Root() string {
+	return s.root
+}
+
+// path returns the path of the file of the blob with the input key
+func (s *FileStore) path(key string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes the content into a temporary file and renames it to the file of the key, so readers
+// never see a partially written blob
+func (s *FileStore) Put(ctx context.Context, key string, content io.Reader, size int64, _ string) (err error) {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+
+	// one more byte than the size is read to detect a content larger than the size
+	written, err := io.Copy(file, io.LimitReader(contextReader{ctx: ctx, reader: content}, size+1))
+	if err != nil {
+		return err
+	}
+	if written != size {
+		return fmt.Errorf("%w: %d bytes, expected %d", ErrSizeMismatch, written, size)
+	}
+
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+// Get opens the file of the blob with the input key
+func (s *FileStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+
+	return file, err
+}
+
+// Delete removes the file of the blob with the input key
+func (s *FileStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
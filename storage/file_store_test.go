@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestFileStore tests FileStore
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "blobs"))
+	require.NoError(t, err)
+
+	testBlobStore(t, store)
+}
+
+// TestFileStore_SizeMismatch tests that FileStore does not keep a blob whose content does not match its size
+func TestFileStore_SizeMismatch(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewFileStore(root)
+	require.NoError(t, err)
+
+	err = store.Put(context.Background(), "short", strings.NewReader("text"), 10, "")
+	require.ErrorIs(t, err, ErrSizeMismatch)
+
+	err = store.Put(context.Background(), "long", strings.NewReader("text"), 2, "")
+	require.ErrorIs(t, err, ErrSizeMismatch)
+
+	// neither the blobs nor their temporary files are left behind
+	entries, err := os.ReadDir(root)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxErrorBody is the maximum size of an error response read from the S3-compatible service
+const maxErrorBody = 64 << 10
+
+// S3Config holds the configurations of an S3Store
+type S3Config struct {
+	Endpoint        string       // url of the S3-compatible service, e.g. "https://s3.us-east-1.amazonaws.com"
+	Region          string       // region requests are signed for
+	Bucket          string       // bucket blobs are stored in
+	AccessKeyID     string       // access key requests are signed with
+	SecretAccessKey string       // secret key requests are signed with
+	HTTPClient      *http.Client // client sending the requests, http.DefaultClient if nil
+}
+
+// S3Store implements BlobStore on an S3-compatible object storage service, every blob is an object
+// of the bucket. objects are addressed path-style, which every S3-compatible service supports
+type S3Store struct {
+	endpoint    *url.URL
+	region      string
+	bucket      string
+	credentials credentials
+	client      *http.Client
+
+	// now returns the time requests are signed at
+	now func() time.Time
+}
+
+// ensure S3Store implements BlobStore interface
+var _ BlobStore = (*S3Store)(nil)
+
+// NewS3Store returns an S3Store of the input configurations
+func NewS3Store(config S3Config) (*S3Store, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q: scheme must be http or https", config.Endpoint)
+	}
+	if config.Bucket == "" {
+		return nil, errors.New("s3 bucket is not set")
+	}
+
+	client := config.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &S3Store{
+		endpoint: endpoint,
+		region:   config.Region,
+		bucket:   config.Bucket,
+		credentials: credentials{
+			accessKeyID:     config.AccessKeyID,
+			secretAccessKey: config.SecretAccessKey,
+		},
+		client: client,
+		now:    time.Now,
+	}, nil
+}
+
+// Put uploads the content as the object of the key, the payload is not signed so the content is
+// streamed without being read twice
+func (s *S3Store) Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error {
+	req, err := s.newRequest(ctx, http.MethodPut, key, content)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	res, err := s.do(req, unsignedPayload)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	return nil
+}
+
+// Get downloads the object of the key
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.newRequest(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Body, nil
+}
+
+// Delete deletes the object of the key, S3 does not report deleting a missing object as an error
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.do(req, emptyPayloadHash)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	return nil
+}
+
+// newRequest returns a request of the input method on the object of the input key
+func (s *S3Store) newRequest(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	// keys only have characters which need no escaping in a path
+	objectURL := s.endpoint.JoinPath(s.bucket, key)
+
+	return http.NewRequestWithContext(ctx, method, objectURL.String(), body)
+}
+
+// do signs and sends the input request, responses other than 2xx are returned as errors
+func (s *S3Store) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	signV4(req, payloadHash, s.credentials, s.region, "s3", s.now())
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return res, nil
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, req.URL.Path)
+	}
+
+	return nil, fmt.Errorf("s3 %s %s: %s", req.Method, req.URL.Path, readS3Error(res))
+}
+
+// s3Error is the error document of the S3 api
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// readS3Error returns a description of the error of the input error response
+func readS3Error(res *http.Response) string {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+
+	var document s3Error
+	if err := xml.Unmarshal(body, &document); err != nil || document.Code == "" {
+		return res.Status
+	}
+
+	return strings.TrimSpace(fmt.Sprintf("%s: %s %s", res.Status, document.Code, document.Message))
+}
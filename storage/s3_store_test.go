@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testAccessKeyID     = "AKIDEXAMPLE"
+	testSecretAccessKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion          = "us-east-1"
+	testBucket          = "attachments"
+)
+
+// s3StandIn is a local stand-in of an S3-compatible service, it serves the objects of a single
+// bucket path-style and rejects the requests not signed with the test credentials
+type s3StandIn struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+// ServeHTTP serves the object requests of the stand-in
+func (s *s3StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.verifySignature(r) {
+		writeS3Error(w, http.StatusForbidden, "SignatureDoesNotMatch")
+		return
+	}
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+testBucket+"/")
+	if !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		content, err := io.ReadAll(r.Body)
+		if err != nil || int64(len(content)) != r.ContentLength {
+			writeS3Error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		s.objects[key] = content
+		s.types[key] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		content, ok := s.objects[key]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Type", s.types[key])
+		w.Write(content)
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// verifySignature reports whether the input request is signed with the test credentials
+func (s *s3StandIn) verifySignature(r *http.Request) bool {
+	signedAt, err := time.Parse(amzDateFormat, r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+
+	// sign a copy of the request with the test credentials and compare the signatures
+	signed := r.Clone(context.Background())
+	signed.Header = http.Header{}
+	for name, values := range r.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || (strings.HasPrefix(lower, "x-amz-") && lower != "x-amz-date") {
+			signed.Header[name] = values
+		}
+	}
+	signV4(signed, r.Header.Get("X-Amz-Content-Sha256"), credentials{
+		accessKeyID:     testAccessKeyID,
+		secretAccessKey: testSecretAccessKey,
+	}, testRegion, "s3", signedAt)
+
+	return signed.Header.Get("Authorization") == r.Header.Get("Authorization")
+}
+
+// writeS3Error writes an S3 error document of the input code
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, http.StatusText(status))
+}
+
+// newS3StandIn starts a stand-in S3-compatible service and returns an S3Store of its bucket
+func newS3StandIn(t *testing.T, secretAccessKey string) *S3Store {
+	server := httptest.NewServer(&s3StandIn{
+		objects: make(map[string][]byte),
+		types:   make(map[string]string),
+	})
+	t.Cleanup(server.Close)
+
+	store, err := NewS3Store(S3Config{
+		Endpoint:        server.URL,
+		Region:          testRegion,
+		Bucket:          testBucket,
+		AccessKeyID:     testAccessKeyID,
+		SecretAccessKey: secretAccessKey,
+	})
+	require.NoError(t, err)
+
+	return store
+}
+
+// TestS3Store tests S3Store against a local stand-in of an S3-compatible service
+func TestS3Store(t *testing.T) {
+	testBlobStore(t, newS3StandIn(t, testSecretAccessKey))
+}
+
+// TestS3Store_WrongCredentials tests that S3Store reports the errors of the service
+func TestS3Store_WrongCredentials(t *testing.T) {
+	store := newS3StandIn(t, "wrong secret")
+
+	err := store.Put(context.Background(), "key", strings.NewReader("text"), 4, "text/plain")
+	require.ErrorContains(t, err, "SignatureDoesNotMatch")
+	require.NotErrorIs(t, err, ErrNotFound)
+}
+
+// TestNewS3Store tests the validation of the configurations of NewS3Store
+func TestNewS3Store(t *testing.T) {
+	_, err := NewS3Store(S3Config{Endpoint: "localhost:9000", Bucket: testBucket})
+	require.Error(t, err)
+
+	_, err = NewS3Store(S3Config{Endpoint: "http://localhost:9000"})
+	require.Error(t, err)
+
+	_, err = NewS3Store(S3Config{Endpoint: "http://localhost:9000", Bucket: testBucket})
+	require.NoError(t, err)
+}
+
+// TestSignV4 tests signV4 against the "get-vanilla" case of the signature version 4 test suite
+func TestSignV4(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	require.NoError(t, err)
+
+	signV4(req, emptyPayloadHash, credentials{
+		accessKeyID:     testAccessKeyID,
+		secretAccessKey: testSecretAccessKey,
+	}, testRegion, "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	require.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+	require.Equal(t,
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+			"SignedHeaders=host;x-amz-date, "+
+			"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		req.Header.Get("Authorization"),
+	)
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// signingAlgorithm is the algorithm of AWS signature version 4
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+
+	// amzDateFormat is the format of the X-Amz-Date header
+	amzDateFormat = "20060102T150405Z"
+
+	// unsignedPayload is the payload hash of requests whose body is not signed
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// emptyPayloadHash is the payload hash of requests without a body
+var emptyPayloadHash = hashHex(nil)
+
+// credentials are the keys requests to an S3-compatible service are signed with
+type credentials struct {
+	accessKeyID     string
+	secretAccessKey string
+}
+
+// signV4 signs the input request with AWS signature version 4 at the input time, the host, the
+// content type and the "X-Amz-" headers are signed. payloadHash is the hex sha256 hash of the body
+// or unsignedPayload
+func signV4(req *http.Request, payloadHash string, creds credentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format(amzDateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	scope := strings.Join([]string{amzDate[:8], region, service, "aws4_request"}, "/")
+	canonicalHeaders, signedHeaders := canonicalizeHeaders(req)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQuery(req.URL),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		signingAlgorithm,
+		amzDate,
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretAccessKey), amzDate[:8])
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", signingAlgorithm+
+		" Credential="+creds.accessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+
+		", Signature="+signature)
+}
+
+// canonicalURI returns the escaped path of the input url, "/" for an empty path
+func canonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// canonicalQuery returns the query of the input url with its parameters sorted and escaped
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	parameters := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			parameters = append(parameters, escape(name)+"="+escape(value))
+		}
+	}
+	sort.Strings(parameters)
+
+	return strings.Join(parameters, "&")
+}
+
+// escape escapes the input string the way signature version 4 expects, spaces become "%20"
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// canonicalizeHeaders returns the canonical headers and the signed headers list of the input request
+func canonicalizeHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || strings.HasPrefix(name, "x-amz-") {
+			trimmed := make([]string, len(values))
+			for i, value := range values {
+				trimmed[i] = strings.Join(strings.Fields(value), " ")
+			}
+			headers[name] = strings.Join(trimmed, ",")
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+
+	return canonical.String(), strings.Join(names, ";")
+}
+
+// hashHex returns the hex sha256 hash of the input data
+func hashHex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of the input data with the input key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -6,4 +6,9 @@ import "time"
 type Maker interface {
 	CreateToken(username string, duration time.Duration) (string, *Payload, error)
 	VerifyToken(token string) (*Payload, error)
+
+	// CreatePurposeToken creates a token only verified by VerifyPurposeToken with the same purpose, e.g. the
+	// token of the second step of a login. VerifyToken rejects it so it is never taken for an access token
+	CreatePurposeToken(purpose, username string, duration time.Duration) (string, *Payload, error)
+	VerifyPurposeToken(token, purpose string) (*Payload, error)
 }
@@ -40,6 +40,22 @@ func (m *MockMaker) EXPECT() *MockMakerMockRecorder {
 	return m.recorder
 }
 
+// CreatePurposeToken mocks base method.
+func (m *MockMaker) CreatePurposeToken(arg0, arg1 string, arg2 time.Duration) (string, *token.Payload, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePurposeToken", arg0, arg1, arg2)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(*token.Payload)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreatePurposeToken indicates an expected call of CreatePurposeToken.
+func (mr *MockMakerMockRecorder) CreatePurposeToken(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePurposeToken", reflect.TypeOf((*MockMaker)(nil).CreatePurposeToken), arg0, arg1, arg2)
+}
+
 // CreateToken mocks base method.
 func (m *MockMaker) CreateToken(arg0 string, arg1 time.Duration) (string, *token.Payload, error) {
 	m.ctrl.T.Helper()
@@ -56,6 +72,21 @@ func (mr *MockMakerMockRecorder) CreateToken(arg0, arg1 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateToken", reflect.TypeOf((*MockMaker)(nil).CreateToken), arg0, arg1)
 }
 
+// VerifyPurposeToken mocks base method.
+func (m *MockMaker) VerifyPurposeToken(arg0, arg1 string) (*token.Payload, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyPurposeToken", arg0, arg1)
+	ret0, _ := ret[0].(*token.Payload)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyPurposeToken indicates an expected call of VerifyPurposeToken.
+func (mr *MockMakerMockRecorder) VerifyPurposeToken(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyPurposeToken", reflect.TypeOf((*MockMaker)(nil).VerifyPurposeToken), arg0, arg1)
+}
+
 // VerifyToken mocks base method.
 func (m *MockMaker) VerifyToken(arg0 string) (*token.Payload, error) {
 	m.ctrl.T.Helper()
@@ -27,21 +27,32 @@ func NewPasetoMaker(symmetricKey string) (Maker, error) {
 
 // CreateToken creates adn returns a token
 func (maker *PasetoMaker) CreateToken(username string, duration time.Duration) (string, *Payload, error) {
+	return maker.CreatePurposeToken("", username, duration)
+}
+
+// VerifyToken verifies the input token and if valid, returns the payload
+func (maker *PasetoMaker) VerifyToken(token string) (*Payload, error) {
+	return maker.VerifyPurposeToken(token, "")
+}
+
+// CreatePurposeToken creates and returns a token of the input purpose
+func (maker *PasetoMaker) CreatePurposeToken(purpose, username string, duration time.Duration) (string, *Payload, error) {
 	payload, err := NewPayload(username, duration)
 	if err != nil {
 		return "", nil, err
 	}
+	payload.Purpose = purpose
 
 	pasetoToken, err := maker.paseto.Encrypt(maker.symmetricKey, payload, nil)
 	return pasetoToken, payload, err
 }
 
-// VerifyToken verifies the input token and if valid, returns the payload
-func (maker *PasetoMaker) VerifyToken(token string) (*Payload, error) {
+// VerifyPurposeToken verifies the input token of the input purpose and if valid, returns the payload
+func (maker *PasetoMaker) VerifyPurposeToken(token, purpose string) (*Payload, error) {
 	payload := &Payload{}
 
 	err := maker.paseto.Decrypt(token, maker.symmetricKey, payload, nil)
-	if err != nil {
+	if err != nil || payload.Purpose != purpose {
 		return nil, ErrInvalidToken
 	}
 
@@ -48,6 +48,29 @@ func TestPasetoMaker(t *testing.T) {
 		require.Equal(t, err, ErrExpiredToken)
 		require.Nil(t, returnedPayload)
 	})
+	t.Run("Purpose", func(t *testing.T) {
+		username := util.RandomUsername()
+
+		token, payload, err := maker.CreatePurposeToken("2fa", username, time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, "2fa", payload.Purpose)
+
+		returnedPayload, err := maker.VerifyPurposeToken(token, "2fa")
+		require.NoError(t, err)
+		require.Equal(t, username, returnedPayload.Username)
+		require.Equal(t, "2fa", returnedPayload.Purpose)
+
+		// a token of a purpose is not an access token, nor a token of another purpose
+		_, err = maker.VerifyToken(token)
+		require.Equal(t, ErrInvalidToken, err)
+		_, err = maker.VerifyPurposeToken(token, "reset")
+		require.Equal(t, ErrInvalidToken, err)
+
+		accessToken, _, err := maker.CreateToken(username, time.Minute)
+		require.NoError(t, err)
+		_, err = maker.VerifyPurposeToken(accessToken, "2fa")
+		require.Equal(t, ErrInvalidToken, err)
+	})
 	t.Run("InvalidPasetoToken", func(t *testing.T) {
 		payload, err := maker.VerifyToken("invalid token")
 		require.Error(t, err)
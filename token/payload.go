@@ -18,6 +18,9 @@ type Payload struct {
 	Username  string    `json:"username"`
 	IssuedAt  time.Time `json:"issued_at"`
 	ExpiredAt time.Time `json:"expired_at"`
+
+	// Purpose of the token, empty for the access tokens
+	Purpose string `json:"purpose,omitempty"`
 }
 
 // NewPayload creates and returns a new payload
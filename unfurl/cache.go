@@ -0,0 +1,82 @@
+package unfurl
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// cache is a bounded cache of the previews of urls, or of the errors fetching them, the least recently
+// used entries are evicted first and the entries expire after the ttl
+type cache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// recency is the list of cacheEntry values, the most recently used first
+	recency *list.List
+}
+
+// cacheEntry is the cached result of fetching the preview of a url
+type cacheEntry struct {
+	url       string
+	preview   *Preview
+	err       error
+	expiresAt time.Time
+}
+
+// newCache returns a cache of at most size entries expiring after ttl
+func newCache(size int, ttl time.Duration) *cache {
+	return &cache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		recency: list.New(),
+	}
+}
+
+// get returns the cached entry of the input url, reports false if it is not cached or expired
+func (c *cache) get(url string) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[url]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.recency.Remove(element)
+		delete(c.entries, url)
+		return nil, false
+	}
+
+	c.recency.MoveToFront(element)
+	return entry, true
+}
+
+// set caches the preview or error of the input url
+func (c *cache) set(url string, preview *Preview, err error) {
+	if c.size < 1 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cacheEntry{url: url, preview: preview, err: err, expiresAt: time.Now().Add(c.ttl)}
+	if element, ok := c.entries[url]; ok {
+		element.Value = entry
+		c.recency.MoveToFront(element)
+		return
+	}
+
+	c.entries[url] = c.recency.PushFront(entry)
+	for c.recency.Len() > c.size {
+		oldest := c.recency.Back()
+		c.recency.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).url)
+	}
+}
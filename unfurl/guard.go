@@ -0,0 +1,75 @@
+package unfurl
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+)
+
+// ErrForbiddenAddress is returned when a url resolves to an address which is not public
+var ErrForbiddenAddress = errors.New("address is not public")
+
+// nonPublicPrefixes are the special-purpose ranges not covered by the netip predicates which must not be
+// reachable through link previews
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade nat
+	netip.MustParsePrefix("192.0.0.0/24"),    // ietf protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, includes broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),    // nat64, embeds ipv4 addresses
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use nat64
+	netip.MustParsePrefix("2001::/32"),       // teredo, embeds ipv4 addresses
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4, embeds ipv4 addresses
+}
+
+// IsPublicAddress reports whether the input address is a public unicast address, i.e. not a loopback,
+// private, link-local, multicast or otherwise special-purpose address
+func IsPublicAddress(address netip.Addr) bool {
+	address = address.Unmap()
+
+	if !address.IsValid() ||
+		address.IsUnspecified() ||
+		address.IsLoopback() ||
+		address.IsPrivate() ||
+		address.IsLinkLocalUnicast() ||
+		address.IsLinkLocalMulticast() ||
+		address.IsInterfaceLocalMulticast() ||
+		address.IsMulticast() {
+		return false
+	}
+
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(address) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// GuardedDialer returns a dialer which refuses to connect to addresses which are not public. the address
+// is checked when the connection is made, after the host name is resolved, so a host name cannot resolve
+// to a public address when it is checked and to a private one when it is connected to. the requests made
+// with it must not go through a proxy, which would be the only address checked
+func GuardedDialer(dialer *net.Dialer) *net.Dialer {
+	guarded := *dialer
+	guarded.Control = func(network, address string, _ syscall.RawConn) error {
+		addrPort, err := netip.ParseAddrPort(address)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+		}
+		if !IsPublicAddress(addrPort.Addr()) {
+			return fmt.Errorf("%w: %s", ErrForbiddenAddress, addrPort.Addr())
+		}
+		return nil
+	}
+
+	return &guarded
+}
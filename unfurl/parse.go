@@ -0,0 +1,161 @@
+package unfurl
+
+import (
+	"io"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// maximum lengths of the texts of a preview in characters, longer texts are truncated
+const (
+	maxTitleLength       = 200
+	maxDescriptionLength = 300
+	maxSiteNameLength    = 100
+)
+
+// parsePage reads the metadata of an html page from its head. Open Graph properties are preferred,
+// then the twitter card and the standard title and description. relative image urls are resolved
+// against the input base url
+func parsePage(reader io.Reader, base *url.URL) Preview {
+	var preview Preview
+	var title, description, twitterTitle, twitterDescription, twitterImage string
+
+	tokenizer := html.NewTokenizer(reader)
+	inTitle := false
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			// the end of the page, or of the part of it which was read
+			return preview.withFallbacks(title, description, twitterTitle, twitterDescription, twitterImage, base)
+
+		case html.TextToken:
+			if inTitle {
+				title += string(tokenizer.Text())
+			}
+
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch atom.Lookup(name) {
+			case atom.Title:
+				inTitle = false
+			case atom.Head:
+				return preview.withFallbacks(title, description, twitterTitle, twitterDescription, twitterImage, base)
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttributes := tokenizer.TagName()
+			switch atom.Lookup(name) {
+			case atom.Title:
+				inTitle = tokenType == html.StartTagToken && title == ""
+			case atom.Body:
+				// the metadata is in the head
+				return preview.withFallbacks(title, description, twitterTitle, twitterDescription, twitterImage, base)
+			case atom.Meta:
+				if !hasAttributes {
+					continue
+				}
+				key, content := metaAttributes(tokenizer)
+				switch key {
+				case "og:title":
+					preview.Title = content
+				case "og:description":
+					preview.Description = content
+				case "og:image", "og:image:url", "og:image:secure_url":
+					if preview.Image == "" {
+						preview.Image = content
+					}
+				case "og:site_name":
+					preview.SiteName = content
+				case "twitter:title":
+					twitterTitle = content
+				case "twitter:description":
+					twitterDescription = content
+				case "twitter:image", "twitter:image:src":
+					twitterImage = content
+				case "description":
+					description = content
+				}
+			}
+		}
+	}
+}
+
+// metaAttributes returns the property or name of the meta tag the tokenizer is at, lower cased, and its content
+func metaAttributes(tokenizer *html.Tokenizer) (string, string) {
+	var key, content string
+	for {
+		name, value, more := tokenizer.TagAttr()
+		switch string(name) {
+		case "property", "name":
+			if key == "" {
+				key = strings.ToLower(strings.TrimSpace(string(value)))
+			}
+		case "content":
+			content = string(value)
+		}
+		if !more {
+			return key, content
+		}
+	}
+}
+
+// withFallbacks fills the fields of the preview missing Open Graph properties with the other metadata of
+// the page, then normalizes them
+func (p Preview) withFallbacks(title, description, twitterTitle, twitterDescription, twitterImage string, base *url.URL) Preview {
+	p.Title = firstNonEmpty(p.Title, twitterTitle, title)
+	p.Description = firstNonEmpty(p.Description, twitterDescription, description)
+	p.Image = firstNonEmpty(p.Image, twitterImage)
+
+	p.Title = truncate(collapseSpaces(p.Title), maxTitleLength)
+	p.Description = truncate(collapseSpaces(p.Description), maxDescriptionLength)
+	p.SiteName = truncate(collapseSpaces(p.SiteName), maxSiteNameLength)
+	p.Image = resolveImage(p.Image, base)
+
+	return p
+}
+
+// resolveImage resolves the input image url against the input base url, urls which are not http or https
+// urls, such as data and javascript urls, are dropped
+func resolveImage(image string, base *url.URL) string {
+	image = strings.TrimSpace(image)
+	if image == "" {
+		return ""
+	}
+
+	parsed, err := base.Parse(image)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ""
+	}
+
+	return parsed.String()
+}
+
+// firstNonEmpty returns the first of the input values with non-space characters
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// collapseSpaces trims the input text and replaces every run of whitespace with a single space
+func collapseSpaces(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// truncate cuts the input text to at most max characters, marking the cut with an ellipsis
+func truncate(text string, max int) string {
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+
+	runes := []rune(text)
+	return strings.TrimSpace(string(runes[:max-1])) + "…"
+}
@@ -0,0 +1,279 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/html/charset"
+)
+
+// errors returned by Unfurler
+var (
+	ErrNoPreview   = errors.New("page has no preview")
+	ErrUnsupported = errors.New("url is not an html page")
+	ErrQueueFull   = errors.New("link preview queue is full")
+	ErrClosed      = errors.New("link preview worker is closed")
+)
+
+// maxRedirects is the maximum number of redirects followed to fetch a page
+const maxRedirects = 3
+
+// Preview is the preview of a web page linked in a message
+type Preview struct {
+	URL         string // url of the page as linked in the message
+	Title       string // title of the page
+	Description string // description of the page, may be empty
+	Image       string // url of the image of the page, may be empty
+	SiteName    string // name of the site of the page, may be empty
+}
+
+// PreviewCallback is called with the previews of the urls of a job, it is not called if no url has a preview
+type PreviewCallback func(previews []Preview)
+
+// Config holds the configurations of an Unfurler
+type Config struct {
+	Workers     int           // number of jobs processed concurrently
+	QueueSize   int           // maximum number of jobs waiting to be processed
+	Timeout     time.Duration // maximum time spent on fetching the preview of a url
+	MaxBodySize int64         // maximum number of bytes of a page read to find its metadata
+	CacheSize   int           // maximum number of urls whose previews are cached
+	CacheTTL    time.Duration // time the preview of a url is cached for
+	UserAgent   string        // user agent of the requests
+
+	// AllowPrivateNetworks allows fetching previews from loopback and private addresses, it must be
+	// enabled only in tests since it lets the users of the chat make requests to the internal services
+	AllowPrivateNetworks bool
+}
+
+// Unfurler fetches the previews of web pages linked in messages. jobs are processed in the background by a
+// bounded pool of workers, the previews are cached and only fetched from public addresses
+type Unfurler struct {
+	config Config
+	client *http.Client
+	cache  *cache
+
+	// jobs waiting to be processed
+	queue chan job
+
+	// mu guards closed so no job is queued after the queue is closed
+	mu     sync.RWMutex
+	closed bool
+
+	// workers is done when all queued jobs are processed after the unfurler is closed
+	workers sync.WaitGroup
+}
+
+// job is a list of urls waiting for their previews
+type job struct {
+	urls     []string
+	callback PreviewCallback
+}
+
+// New creates an Unfurler and starts its workers
+func New(config Config) *Unfurler {
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+	if config.QueueSize < 1 {
+		config.QueueSize = 1
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = 512 << 10
+	}
+	if config.CacheTTL <= 0 {
+		config.CacheTTL = time.Hour
+	}
+	if config.UserAgent == "" {
+		config.UserAgent = "ChatServerBot/1.0 (link preview)"
+	}
+
+	dialer := &net.Dialer{Timeout: config.Timeout}
+	if !config.AllowPrivateNetworks {
+		dialer = GuardedDialer(dialer)
+	}
+
+	u := &Unfurler{
+		config: config,
+		client: &http.Client{
+			Transport: &http.Transport{
+				// requests never go through a proxy, so the dialer checks the addresses of the pages
+				Proxy:                 nil,
+				DialContext:           dialer.DialContext,
+				TLSHandshakeTimeout:   config.Timeout,
+				ResponseHeaderTimeout: config.Timeout,
+				MaxIdleConns:          config.Workers,
+				IdleConnTimeout:       time.Minute,
+			},
+			CheckRedirect: checkRedirect,
+		},
+		cache: newCache(config.CacheSize, config.CacheTTL),
+		queue: make(chan job, config.QueueSize),
+	}
+
+	u.workers.Add(config.Workers)
+	for i := 0; i < config.Workers; i++ {
+		go u.run()
+	}
+
+	return u
+}
+
+// checkRedirect follows at most maxRedirects redirects to http and https urls
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return fmt.Errorf("%w: redirect to %s", ErrUnsupported, req.URL.Scheme)
+	}
+	return nil
+}
+
+// Enqueue queues the input urls to have their previews fetched, the callback is called from a worker
+// once they are fetched. returns ErrQueueFull without blocking if the queue is full
+func (u *Unfurler) Enqueue(urls []string, callback PreviewCallback) error {
+	if len(urls) == 0 {
+		return nil
+	}
+
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	if u.closed {
+		return ErrClosed
+	}
+
+	select {
+	case u.queue <- job{urls: urls, callback: callback}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting new jobs and waits until all queued jobs are processed or the input context is done
+func (u *Unfurler) Close(ctx context.Context) error {
+	u.mu.Lock()
+	if !u.closed {
+		u.closed = true
+		close(u.queue)
+	}
+	u.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		u.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run processes queued jobs until the queue is closed and drained
+func (u *Unfurler) run() {
+	defer u.workers.Done()
+
+	for job := range u.queue {
+		var previews []Preview
+		for _, url := range job.urls {
+			preview, err := u.Unfurl(context.Background(), url)
+			if err != nil {
+				log.Debug().Err(err).Str("url", url).Msg("no link preview")
+				continue
+			}
+			previews = append(previews, *preview)
+		}
+
+		if len(previews) > 0 && job.callback != nil {
+			job.callback(previews)
+		}
+	}
+}
+
+// Unfurl returns the preview of the page of the input url, from the cache if it was fetched recently
+func (u *Unfurler) Unfurl(ctx context.Context, rawURL string) (*Preview, error) {
+	if entry, ok := u.cache.get(rawURL); ok {
+		return entry.preview, entry.err
+	}
+
+	preview, err := u.fetch(ctx, rawURL)
+
+	// failures are cached too, so a broken or slow page is not fetched for every message linking it.
+	// the cancellation of the caller says nothing about the page
+	if ctx.Err() == nil {
+		u.cache.set(rawURL, preview, err)
+	}
+
+	return preview, err
+}
+
+// fetch fetches the page of the input url and reads its preview
+func (u *Unfurler) fetch(ctx context.Context, rawURL string) (*Preview, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, parsed.Scheme)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, u.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", u.config.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9")
+
+	res, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d", ErrNoPreview, res.StatusCode)
+	}
+	contentType := res.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, contentType)
+	}
+
+	// only the head of the page is needed, pages larger than the limit are read partially
+	body, err := charset.NewReader(io.LimitReader(res.Body, u.config.MaxBodySize), contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	// relative urls in the page are relative to the url after the redirects
+	preview := parsePage(body, res.Request.URL)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if preview.Title == "" {
+		return nil, ErrNoPreview
+	}
+	preview.URL = rawURL
+
+	return &preview, nil
+}
@@ -0,0 +1,296 @@
+package unfurl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// pages are the pages served by the stand-in web server
+var pages = map[string]struct {
+	contentType string
+	body        string
+}{
+	"/article": {
+		contentType: "text/html; charset=utf-8",
+		body: `<!doctype html><html><head>
+			<title>Fallback title</title>
+			<meta property="og:title" content="Go &amp; websockets">
+			<meta property="og:description" content="  How the chat
+				hub broadcasts messages  ">
+			<meta property="og:image" content="/images/cover.png">
+			<meta property="og:site_name" content="Example Blog">
+			</head><body><meta property="og:title" content="ignored"></body></html>`,
+	},
+	"/plain": {
+		contentType: "text/html",
+		body:        `<html><head><title> Plain  page </title><meta name="description" content="Just a page"></head></html>`,
+	},
+	"/latin1": {
+		contentType: "text/html; charset=iso-8859-1",
+		body:        "<html><head><title>Caf\xe9</title></head></html>",
+	},
+	"/twitter": {
+		contentType: "text/html",
+		body: `<html><head>
+			<meta name="twitter:title" content="Card title">
+			<meta name="twitter:image" content="javascript:alert(1)">
+			</head></html>`,
+	},
+	"/untitled": {
+		contentType: "text/html",
+		body:        `<html><head></head><body><h1>No title</h1></body></html>`,
+	},
+	"/image": {
+		contentType: "image/png",
+		body:        "\x89PNG\r\n\x1a\n",
+	},
+}
+
+// standIn is a local stand-in of the web servers the linked pages are fetched from
+type standIn struct {
+	*httptest.Server
+	requests atomic.Int32
+}
+
+// newStandIn starts a stand-in web server serving the test pages
+func newStandIn(t *testing.T) *standIn {
+	s := &standIn{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "/article", http.StatusFound)
+			return
+		case "/redirect-loop":
+			http.Redirect(w, r, "/redirect-loop", http.StatusFound)
+			return
+		case "/slow":
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+			return
+		case "/large":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, "<html><head><!-- ")
+			fmt.Fprint(w, strings.Repeat("padding ", 1<<16))
+			fmt.Fprint(w, "--><title>Too far</title></head></html>")
+			return
+		}
+
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", page.contentType)
+		fmt.Fprint(w, page.body)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// newTestUnfurler returns an Unfurler allowed to fetch the pages of the local stand-in
+func newTestUnfurler(t *testing.T, config Config) *Unfurler {
+	config.AllowPrivateNetworks = true
+	unfurler := New(config)
+	t.Cleanup(func() { unfurler.Close(context.Background()) })
+
+	return unfurler
+}
+
+// TestUnfurl tests reading the previews of pages
+func TestUnfurl(t *testing.T) {
+	server := newStandIn(t)
+	unfurler := newTestUnfurler(t, Config{CacheSize: 100})
+
+	testCases := []struct {
+		name    string
+		path    string
+		preview Preview
+	}{
+		{
+			name: "OpenGraph",
+			path: "/article",
+			preview: Preview{
+				Title:       "Go & websockets",
+				Description: "How the chat hub broadcasts messages",
+				Image:       server.URL + "/images/cover.png",
+				SiteName:    "Example Blog",
+			},
+		},
+		{
+			name:    "Redirect",
+			path:    "/redirect",
+			preview: Preview{Title: "Go & websockets", Description: "How the chat hub broadcasts messages", Image: server.URL + "/images/cover.png", SiteName: "Example Blog"},
+		},
+		{
+			name:    "TitleAndDescription",
+			path:    "/plain",
+			preview: Preview{Title: "Plain page", Description: "Just a page"},
+		},
+		{
+			name:    "Charset",
+			path:    "/latin1",
+			preview: Preview{Title: "Café"},
+		},
+		{
+			name:    "TwitterCard",
+			path:    "/twitter",
+			preview: Preview{Title: "Card title"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.preview.URL = server.URL + tc.path
+
+			preview, err := unfurler.Unfurl(context.Background(), server.URL+tc.path)
+			require.NoError(t, err)
+			require.Equal(t, tc.preview, *preview)
+		})
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		errorCases := []struct {
+			name string
+			url  string
+			err  error
+		}{
+			{name: "NoTitle", url: server.URL + "/untitled", err: ErrNoPreview},
+			{name: "NotFound", url: server.URL + "/missing", err: ErrNoPreview},
+			{name: "NotHTML", url: server.URL + "/image", err: ErrUnsupported},
+			{name: "Scheme", url: "ftp://" + server.Listener.Addr().String() + "/article", err: ErrUnsupported},
+			{name: "TooLarge", url: server.URL + "/large", err: ErrNoPreview},
+		}
+
+		for _, tc := range errorCases {
+			t.Run(tc.name, func(t *testing.T) {
+				_, err := unfurler.Unfurl(context.Background(), tc.url)
+				require.ErrorIs(t, err, tc.err)
+			})
+		}
+	})
+
+	t.Run("RedirectLoop", func(t *testing.T) {
+		_, err := unfurler.Unfurl(context.Background(), server.URL+"/redirect-loop")
+		require.ErrorContains(t, err, "redirects")
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		unfurler := newTestUnfurler(t, Config{Timeout: 50 * time.Millisecond})
+
+		start := time.Now()
+		_, err := unfurler.Unfurl(context.Background(), server.URL+"/slow")
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Less(t, time.Since(start), time.Second)
+	})
+}
+
+// TestUnfurl_PrivateNetworks tests that previews are not fetched from loopback and private addresses
+func TestUnfurl_PrivateNetworks(t *testing.T) {
+	server := newStandIn(t)
+	unfurler := New(Config{})
+	defer unfurler.Close(context.Background())
+
+	for _, url := range []string{
+		server.URL + "/article",
+		strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/article",
+		"http://10.0.0.1/",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://[::1]/",
+	} {
+		_, err := unfurler.Unfurl(context.Background(), url)
+		require.ErrorIs(t, err, ErrForbiddenAddress, url)
+	}
+	require.Zero(t, server.requests.Load())
+}
+
+// TestUnfurl_Cache tests that previews and failures are cached
+func TestUnfurl_Cache(t *testing.T) {
+	server := newStandIn(t)
+	unfurler := newTestUnfurler(t, Config{CacheSize: 1, CacheTTL: time.Hour})
+
+	for i := 0; i < 3; i++ {
+		preview, err := unfurler.Unfurl(context.Background(), server.URL+"/article")
+		require.NoError(t, err)
+		require.Equal(t, "Go & websockets", preview.Title)
+	}
+	require.Equal(t, int32(1), server.requests.Load())
+
+	// failures are cached, and the cache holds a single url
+	for i := 0; i < 2; i++ {
+		_, err := unfurler.Unfurl(context.Background(), server.URL+"/untitled")
+		require.ErrorIs(t, err, ErrNoPreview)
+	}
+	require.Equal(t, int32(2), server.requests.Load())
+
+	_, err := unfurler.Unfurl(context.Background(), server.URL+"/article")
+	require.NoError(t, err)
+	require.Equal(t, int32(3), server.requests.Load())
+
+	// expired previews are fetched again
+	unfurler = newTestUnfurler(t, Config{CacheSize: 1, CacheTTL: time.Nanosecond})
+	for i := 0; i < 2; i++ {
+		_, err := unfurler.Unfurl(context.Background(), server.URL+"/plain")
+		require.NoError(t, err)
+	}
+	require.Equal(t, int32(5), server.requests.Load())
+}
+
+// TestUnfurler_Enqueue tests fetching previews in the background
+func TestUnfurler_Enqueue(t *testing.T) {
+	server := newStandIn(t)
+
+	t.Run("Previews", func(t *testing.T) {
+		unfurler := newTestUnfurler(t, Config{Workers: 2, QueueSize: 10})
+
+		results := make(chan []Preview, 1)
+		err := unfurler.Enqueue([]string{server.URL + "/article", server.URL + "/missing", server.URL + "/plain"}, func(previews []Preview) {
+			results <- previews
+		})
+		require.NoError(t, err)
+
+		select {
+		case previews := <-results:
+			require.Len(t, previews, 2)
+			require.Equal(t, server.URL+"/article", previews[0].URL)
+			require.Equal(t, server.URL+"/plain", previews[1].URL)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "previews were not fetched")
+		}
+	})
+
+	t.Run("NoPreviews", func(t *testing.T) {
+		unfurler := newTestUnfurler(t, Config{})
+
+		err := unfurler.Enqueue([]string{server.URL + "/missing"}, func([]Preview) {
+			require.Fail(t, "callback called without previews")
+		})
+		require.NoError(t, err)
+		require.NoError(t, unfurler.Close(context.Background()))
+	})
+
+	t.Run("QueueFull", func(t *testing.T) {
+		unfurler := newTestUnfurler(t, Config{Workers: 1, QueueSize: 1, Timeout: 200 * time.Millisecond})
+
+		// the only worker waits for the slow page while the queue fills
+		require.NoError(t, unfurler.Enqueue([]string{server.URL + "/slow"}, nil))
+		require.Eventually(t, func() bool { return len(unfurler.queue) == 0 }, 5*time.Second, time.Millisecond)
+		require.NoError(t, unfurler.Enqueue([]string{server.URL + "/slow?again"}, nil))
+		require.ErrorIs(t, unfurler.Enqueue([]string{server.URL + "/plain"}, nil), ErrQueueFull)
+
+		require.NoError(t, unfurler.Close(context.Background()))
+		require.ErrorIs(t, unfurler.Enqueue([]string{server.URL + "/plain"}, nil), ErrClosed)
+	})
+}
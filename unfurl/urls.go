@@ -0,0 +1,47 @@
+package unfurl
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// urlPattern matches the http and https urls in a text
+var urlPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"'` + "`" + `]+`)
+
+// trailingPunctuation is trimmed from the end of the matched urls, it usually ends the sentence around the url
+const trailingPunctuation = ".,:;!?)]}'\""
+
+// ExtractURLs returns the distinct http and https urls in the input text, in the order they appear,
+// at most max of them. fragments are removed since they point into the same page
+func ExtractURLs(text string, max int) []string {
+	var urls []string
+	seen := make(map[string]bool)
+
+	for _, match := range urlPattern.FindAllStringIndex(text, -1) {
+		if len(urls) >= max {
+			break
+		}
+
+		// a closing parenthesis ends the url only if the url opens one, like wikipedia urls do
+		end := match[0] + len(strings.TrimRight(text[match[0]:match[1]], trailingPunctuation))
+		candidate := text[match[0]:end]
+		if strings.Count(candidate, "(") > strings.Count(candidate, ")") && strings.HasPrefix(text[end:], ")") {
+			candidate += ")"
+		}
+
+		parsed, err := url.Parse(candidate)
+		if err != nil || parsed.Hostname() == "" {
+			continue
+		}
+		parsed.Fragment = ""
+		normalized := parsed.String()
+
+		if !seen[normalized] {
+			seen[normalized] = true
+			urls = append(urls, normalized)
+		}
+	}
+
+	return urls
+}
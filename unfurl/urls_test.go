@@ -0,0 +1,67 @@
+package unfurl
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestExtractURLs tests ExtractURLs
+func TestExtractURLs(t *testing.T) {
+	testCases := []struct {
+		name string
+		text string
+		max  int
+		urls []string
+	}{
+		{name: "None", text: "no links here, www.example.com is not one", max: 3},
+		{name: "Single", text: "look at https://example.com/post?id=1", max: 3, urls: []string{"https://example.com/post?id=1"}},
+		{name: "Punctuation", text: "(see http://example.com/a.) and https://example.com/b!", max: 3, urls: []string{"http://example.com/a", "https://example.com/b"}},
+		{name: "Parentheses", text: "https://en.wikipedia.org/wiki/Go_(programming_language) is nice", max: 3, urls: []string{"https://en.wikipedia.org/wiki/Go_(programming_language)"}},
+		{name: "Duplicates", text: "https://example.com/#top https://example.com/ https://example.com/#bottom", max: 3, urls: []string{"https://example.com/"}},
+		{name: "Max", text: "http://a.example http://b.example http://c.example", max: 2, urls: []string{"http://a.example", "http://b.example"}},
+		{name: "CaseInsensitive", text: "HTTPS://EXAMPLE.COM/Path", max: 3, urls: []string{"https://EXAMPLE.COM/Path"}},
+		{name: "NoHost", text: "http:// and https:///path", max: 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.urls, ExtractURLs(tc.text, tc.max))
+		})
+	}
+}
+
+// TestIsPublicAddress tests IsPublicAddress
+func TestIsPublicAddress(t *testing.T) {
+	testCases := []struct {
+		address string
+		public  bool
+	}{
+		{address: "93.184.216.34", public: true},
+		{address: "2606:2800:220:1:248:1893:25c8:1946", public: true},
+		{address: "127.0.0.1"},
+		{address: "10.1.2.3"},
+		{address: "172.16.0.1"},
+		{address: "192.168.1.1"},
+		{address: "169.254.169.254"},
+		{address: "100.64.0.1"},
+		{address: "0.0.0.0"},
+		{address: "255.255.255.255"},
+		{address: "224.0.0.1"},
+		{address: "::1"},
+		{address: "::"},
+		{address: "fe80::1"},
+		{address: "fd00::1"},
+		{address: "::ffff:127.0.0.1"},
+		{address: "::ffff:10.0.0.1"},
+		{address: "64:ff9b::a00:1"},
+		{address: "2002:a00:1::"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.address, func(t *testing.T) {
+			require.Equal(t, tc.public, IsPublicAddress(netip.MustParseAddr(tc.address)))
+		})
+	}
+}
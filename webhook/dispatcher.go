@@ -0,0 +1,390 @@
+package webhook
+
+import (
+	"Chat-Server/repository"
+	"Chat-Server/unfurl"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// errors returned by Dispatcher
+var (
+	ErrQueueFull = errors.New("webhook event queue is full")
+	ErrClosed    = errors.New("webhook dispatcher is closed")
+)
+
+// maxErrorLength is the maximum length of the error recorded for a failed delivery attempt
+const maxErrorLength = 512
+
+// DeliveryRepository is the part of the repository the webhooks are read from and their deliveries recorded in
+type DeliveryRepository interface {
+	GetWebhooks(ctx context.Context) ([]*repository.Webhook, error)
+	AddWebhookDelivery(ctx context.Context, delivery *repository.WebhookDelivery) (*repository.WebhookDelivery, error)
+	RecordWebhookResult(ctx context.Context, id uint, delivered bool, maxFailures int) (*repository.Webhook, error)
+}
+
+// Config holds the configurations of a Dispatcher
+type Config struct {
+	Workers     int           // number of events delivered concurrently
+	QueueSize   int           // maximum number of events waiting to be delivered
+	Timeout     time.Duration // maximum time spent on one delivery attempt
+	MaxAttempts int           // maximum number of attempts to deliver an event to a webhook
+	Backoff     time.Duration // wait before the second attempt, doubled after every attempt
+	MaxBackoff  time.Duration // maximum wait between two attempts
+	MaxFailures int           // number of consecutive failed events after which a webhook is disabled
+	CacheTTL    time.Duration // time the registered webhooks are cached for
+
+	// AllowPrivateNetworks allows delivering events to loopback and private addresses, for the webhooks of
+	// the internal services. without it a webhook url cannot reach the services next to the server
+	AllowPrivateNetworks bool
+}
+
+// Dispatcher delivers the events of the chat to the webhooks subscribing to them. events are delivered in the
+// background by a bounded pool of workers, every worker delivers an event to all its webhooks concurrently
+type Dispatcher struct {
+	repository DeliveryRepository
+	config     Config
+	client     *http.Client
+
+	// ctx is canceled when the dispatcher gives up on the queued events, so the workers stop waiting
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// events waiting to be delivered
+	queue chan job
+
+	// mu guards closed so no event is queued after the queue is closed
+	mu     sync.RWMutex
+	closed bool
+
+	// workers is done when all queued events are delivered after the dispatcher is closed
+	workers sync.WaitGroup
+
+	// cacheMu guards the cached webhooks and their expiration
+	cacheMu   sync.Mutex
+	webhooks  []*repository.Webhook
+	expiresAt time.Time
+}
+
+// job is an event waiting to be delivered, its body is encoded when it is emitted
+type job struct {
+	id        string
+	eventType string
+	body      []byte
+}
+
+// New creates a Dispatcher reading the webhooks from and recording the deliveries in the input repository,
+// and starts its workers
+func New(repository DeliveryRepository, config Config) *Dispatcher {
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+	if config.QueueSize < 1 {
+		config.QueueSize = 1
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.MaxAttempts < 1 {
+		config.MaxAttempts = 1
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = time.Second
+	}
+	if config.MaxBackoff < config.Backoff {
+		config.MaxBackoff = config.Backoff
+	}
+	if config.MaxFailures < 1 {
+		config.MaxFailures = 1
+	}
+	if config.CacheTTL <= 0 {
+		config.CacheTTL = 30 * time.Second
+	}
+
+	dialer := &net.Dialer{Timeout: config.Timeout}
+	if !config.AllowPrivateNetworks {
+		dialer = unfurl.GuardedDialer(dialer)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		repository: repository,
+		config:     config,
+		client: &http.Client{
+			Timeout: config.Timeout,
+			Transport: &http.Transport{
+				// requests never go through a proxy, so the dialer checks the addresses of the webhooks
+				Proxy:               nil,
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: config.Timeout,
+				MaxIdleConns:        config.Workers,
+				IdleConnTimeout:     time.Minute,
+			},
+			// a redirect is not followed, it is reported as a failed delivery so the admins fix the url
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		ctx:    ctx,
+		cancel: cancel,
+		queue:  make(chan job, config.QueueSize),
+	}
+
+	d.workers.Add(config.Workers)
+	for i := 0; i < config.Workers; i++ {
+		go d.run()
+	}
+
+	return d
+}
+
+// Emit queues an event of the input type and data to be delivered to the webhooks subscribing to it. the data is
+// encoded before Emit returns. returns ErrQueueFull without blocking if the queue is full
+func (d *Dispatcher) Emit(eventType string, data any) error {
+	event := Event{
+		ID:        uuid.NewString(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		return ErrClosed
+	}
+
+	select {
+	case d.queue <- job{id: event.ID, eventType: eventType, body: body}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Invalidate drops the cached webhooks, the next event reads them from the repository. it is called when the
+// webhooks are changed so the change applies to the next event
+func (d *Dispatcher) Invalidate() {
+	d.cacheMu.Lock()
+	defer d.cacheMu.Unlock()
+
+	d.webhooks = nil
+	d.expiresAt = time.Time{}
+}
+
+// Close stops accepting new events and waits until all queued events are delivered or the input context is
+// done, in which case the attempts in progress are abandoned
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		return ctx.Err()
+	}
+}
+
+// run delivers queued events until the queue is closed and drained
+func (d *Dispatcher) run() {
+	defer d.workers.Done()
+
+	for job := range d.queue {
+		webhooks, err := d.subscribers(job.eventType)
+		if err != nil {
+			log.Error().Err(err).Str("event", job.eventType).Msg("cannot load webhooks")
+			continue
+		}
+
+		var deliveries sync.WaitGroup
+		deliveries.Add(len(webhooks))
+		for _, webhook := range webhooks {
+			go func() {
+				defer deliveries.Done()
+				d.deliver(webhook, job)
+			}()
+		}
+		deliveries.Wait()
+	}
+}
+
+// subscribers returns the enabled webhooks subscribing to the input event type, the webhooks are cached
+func (d *Dispatcher) subscribers(eventType string) ([]*repository.Webhook, error) {
+	d.cacheMu.Lock()
+	defer d.cacheMu.Unlock()
+
+	if time.Now().After(d.expiresAt) {
+		webhooks, err := d.repository.GetWebhooks(d.ctx)
+		if err != nil {
+			return nil, err
+		}
+		d.webhooks = webhooks
+		d.expiresAt = time.Now().Add(d.config.CacheTTL)
+	}
+
+	var subscribers []*repository.Webhook
+	for _, webhook := range d.webhooks {
+		if webhook.Subscribes(eventType) {
+			subscribers = append(subscribers, webhook)
+		}
+	}
+
+	return subscribers, nil
+}
+
+// deliver posts the event of the input job to the input webhook until it is delivered or all attempts fail,
+// every attempt is recorded and the result updates the failures of the webhook
+func (d *Dispatcher) deliver(webhook *repository.Webhook, job job) {
+	delivered := false
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		statusCode, err := d.post(webhook, job)
+		delivery := &repository.WebhookDelivery{
+			WebhookID:  webhook.ID,
+			EventID:    job.id,
+			EventType:  job.eventType,
+			Attempt:    attempt,
+			StatusCode: statusCode,
+			Duration:   time.Since(start),
+		}
+		if err != nil {
+			delivery.Error = truncate(err.Error(), maxErrorLength)
+		}
+
+		// the attempts are recorded even while closing, the repository has its own timeouts
+		if _, recordErr := d.repository.AddWebhookDelivery(context.Background(), delivery); recordErr != nil {
+			log.Error().Err(recordErr).Uint("webhook", webhook.ID).Msg("cannot record webhook delivery")
+		}
+
+		if err == nil {
+			delivered = true
+			break
+		}
+		if attempt >= d.config.MaxAttempts || !retryable(statusCode) || !d.sleep(d.backoff(attempt)) {
+			break
+		}
+	}
+
+	// an event abandoned on shutdown is no failure of the webhook
+	if !delivered && d.ctx.Err() != nil {
+		return
+	}
+
+	updated, err := d.repository.RecordWebhookResult(context.Background(), webhook.ID, delivered, d.config.MaxFailures)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			log.Error().Err(err).Uint("webhook", webhook.ID).Msg("cannot record webhook result")
+		}
+		return
+	}
+
+	if updated.Disabled {
+		log.Warn().Uint("webhook", webhook.ID).Str("url", webhook.URL).Int("failures", updated.Failures).
+			Msg("webhook disabled after too many failures")
+		d.Invalidate()
+	}
+}
+
+// post makes one attempt to deliver the event of the input job to the input webhook, returns the status code
+// of the response, 0 if there is none, and an error if the event is not delivered
+func (d *Dispatcher) post(webhook *repository.Webhook, job job) (int, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, webhook.URL, bytes.NewReader(job.body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ChatServerWebhooks/1.0")
+	req.Header.Set(HeaderID, job.id)
+	req.Header.Set(HeaderEvent, job.eventType)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, job.body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	// the body is drained so the connection is reused, the response says nothing more than its status
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}
+
+// retryable reports whether a failed attempt with the input status code is retried. client errors other than
+// timeouts and rate limits will fail again, attempts without a response are retried
+func retryable(statusCode int) bool {
+	if statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	return statusCode < 400 || statusCode > 499
+}
+
+// backoff returns the wait after the input failed attempt, doubled after every attempt up to the maximum
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	wait := d.config.Backoff
+	for i := 1; i < attempt && wait < d.config.MaxBackoff; i++ {
+		wait *= 2
+	}
+
+	return min(wait, d.config.MaxBackoff)
+}
+
+// sleep waits for the input duration, returns false if the dispatcher gives up on the queued events meanwhile
+func (d *Dispatcher) sleep(wait time.Duration) bool {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-d.ctx.Done():
+		return false
+	}
+}
+
+// truncate returns the input string cut to at most length bytes
+func truncate(s string, length int) string {
+	if len(s) <= length {
+		return s
+	}
+
+	return s[:length]
+}
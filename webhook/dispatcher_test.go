@@ -0,0 +1,273 @@
+package webhook
+
+import (
+	"Chat-Server/repository"
+	"Chat-Server/repository/db/memory"
+	"Chat-Server/unfurl"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestWebhook registers a webhook of the input url subscribing to the input events in a new memory repository
+func newTestWebhook(t *testing.T, url string, events ...string) (*memory.MemoryRepository, *repository.Webhook) {
+	repo := memory.NewMemoryRepository()
+	_, err := repo.AddUser(context.Background(), &repository.User{Username: "admin_user", Password: "password"})
+	require.NoError(t, err)
+
+	webhook, err := repo.AddWebhook(context.Background(), &repository.Webhook{
+		URL:       url,
+		Secret:    "webhook_secret",
+		Events:    events,
+		CreatedBy: "admin_user",
+	})
+	require.NoError(t, err)
+
+	return repo, webhook
+}
+
+// testConfig returns the configurations of a dispatcher retrying quickly
+func testConfig() Config {
+	return Config{Workers: 1, QueueSize: 10, Timeout: time.Second, MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond, MaxFailures: 2, AllowPrivateNetworks: true}
+}
+
+// closeDispatcher waits until the queued events are delivered
+func closeDispatcher(t *testing.T, dispatcher *Dispatcher) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	require.NoError(t, dispatcher.Close(ctx))
+}
+
+// TestDispatcher tests delivering events with Dispatcher
+func TestDispatcher(t *testing.T) {
+	t.Run("Signed", func(t *testing.T) {
+		requests := make(chan *http.Request, 1)
+		bodies := make(chan []byte, 1)
+		endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			requests <- r
+			bodies <- body
+		}))
+		defer endpoint.Close()
+
+		repo, webhook := newTestWebhook(t, endpoint.URL, EventUserJoined)
+		dispatcher := New(repo, testConfig())
+		require.NoError(t, dispatcher.Emit(EventUserJoined, UserData{Username: "user_name"}))
+		closeDispatcher(t, dispatcher)
+
+		req, body := <-requests, <-bodies
+		require.Equal(t, http.MethodPost, req.Method)
+		require.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		require.Equal(t, EventUserJoined, req.Header.Get(HeaderEvent))
+
+		timestamp := req.Header.Get(HeaderTimestamp)
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now(), time.Unix(unix, 0), time.Minute)
+		require.True(t, Verify("webhook_secret", timestamp, req.Header.Get(HeaderSignature), body))
+		require.False(t, Verify("other_secret", timestamp, req.Header.Get(HeaderSignature), body))
+
+		var event struct {
+			ID   string   `json:"id"`
+			Type string   `json:"type"`
+			Data UserData `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(body, &event))
+		require.Equal(t, req.Header.Get(HeaderID), event.ID)
+		require.Equal(t, EventUserJoined, event.Type)
+		require.Equal(t, "user_name", event.Data.Username)
+
+		deliveries, err := repo.GetWebhookDeliveries(context.Background(), webhook.ID, 10)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		require.Equal(t, event.ID, deliveries[0].EventID)
+		require.Equal(t, 1, deliveries[0].Attempt)
+		require.Equal(t, http.StatusOK, deliveries[0].StatusCode)
+		require.Empty(t, deliveries[0].Error)
+	})
+
+	t.Run("Retried", func(t *testing.T) {
+		var calls atomic.Int32
+		endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer endpoint.Close()
+
+		repo, webhook := newTestWebhook(t, endpoint.URL, EventMessageCreated)
+		dispatcher := New(repo, testConfig())
+		require.NoError(t, dispatcher.Emit(EventMessageCreated, MessageData{ID: 1}))
+		closeDispatcher(t, dispatcher)
+
+		// every attempt is recorded, the delivered event resets the failures
+		deliveries, err := repo.GetWebhookDeliveries(context.Background(), webhook.ID, 10)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 3)
+		require.Equal(t, 3, deliveries[0].Attempt)
+		require.Equal(t, http.StatusOK, deliveries[0].StatusCode)
+		require.Equal(t, 1, deliveries[2].Attempt)
+		require.Equal(t, http.StatusServiceUnavailable, deliveries[2].StatusCode)
+		require.Equal(t, "unexpected status 503", deliveries[2].Error)
+		require.Equal(t, deliveries[0].EventID, deliveries[2].EventID)
+
+		updated, err := repo.GetWebhook(context.Background(), webhook.ID)
+		require.NoError(t, err)
+		require.Zero(t, updated.Failures)
+	})
+
+	t.Run("ClientError", func(t *testing.T) {
+		var calls atomic.Int32
+		endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer endpoint.Close()
+
+		// a client error is not retried but counts as a failure
+		repo, webhook := newTestWebhook(t, endpoint.URL, EventMessageCreated)
+		dispatcher := New(repo, testConfig())
+		require.NoError(t, dispatcher.Emit(EventMessageCreated, MessageData{ID: 1}))
+		closeDispatcher(t, dispatcher)
+
+		require.Equal(t, int32(1), calls.Load())
+		updated, err := repo.GetWebhook(context.Background(), webhook.ID)
+		require.NoError(t, err)
+		require.Equal(t, 1, updated.Failures)
+		require.False(t, updated.Disabled)
+	})
+
+	t.Run("PrivateNetwork", func(t *testing.T) {
+		var calls atomic.Int32
+		endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+		}))
+		defer endpoint.Close()
+
+		// the events are not delivered to the loopback address of the endpoint unless private networks are allowed
+		repo, webhook := newTestWebhook(t, endpoint.URL, EventMessageCreated)
+		config := testConfig()
+		config.AllowPrivateNetworks = false
+		dispatcher := New(repo, config)
+		require.NoError(t, dispatcher.Emit(EventMessageCreated, MessageData{ID: 1}))
+		closeDispatcher(t, dispatcher)
+
+		require.Zero(t, calls.Load())
+		deliveries, err := repo.GetWebhookDeliveries(context.Background(), webhook.ID, 10)
+		require.NoError(t, err)
+		require.Len(t, deliveries, config.MaxAttempts)
+		require.Zero(t, deliveries[0].StatusCode)
+		require.Contains(t, deliveries[0].Error, unfurl.ErrForbiddenAddress.Error())
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		var calls atomic.Int32
+		endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer endpoint.Close()
+
+		// the webhook is disabled after two failed events and receives no more events
+		repo, webhook := newTestWebhook(t, endpoint.URL, EventMessageCreated)
+		dispatcher := New(repo, testConfig())
+		for i := 0; i < 3; i++ {
+			require.NoError(t, dispatcher.Emit(EventMessageCreated, MessageData{ID: uint(i + 1)}))
+		}
+		closeDispatcher(t, dispatcher)
+
+		require.Equal(t, int32(6), calls.Load())
+		updated, err := repo.GetWebhook(context.Background(), webhook.ID)
+		require.NoError(t, err)
+		require.Equal(t, 2, updated.Failures)
+		require.True(t, updated.Disabled)
+	})
+
+	t.Run("NotSubscribed", func(t *testing.T) {
+		var calls atomic.Int32
+		endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+		}))
+		defer endpoint.Close()
+
+		repo, _ := newTestWebhook(t, endpoint.URL, EventUserSignedUp)
+		dispatcher := New(repo, testConfig())
+		require.NoError(t, dispatcher.Emit(EventMessageCreated, MessageData{ID: 1}))
+		closeDispatcher(t, dispatcher)
+
+		require.Zero(t, calls.Load())
+	})
+
+	t.Run("Invalidate", func(t *testing.T) {
+		calls := make(chan string, 2)
+		endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls <- r.Header.Get(HeaderEvent)
+		}))
+		defer endpoint.Close()
+
+		// webhooks registered after the cache is filled receive events once the cache is invalidated
+		repo, _ := newTestWebhook(t, endpoint.URL, EventUserJoined)
+		config := testConfig()
+		config.CacheTTL = time.Hour
+		dispatcher := New(repo, config)
+		defer closeDispatcher(t, dispatcher)
+
+		require.NoError(t, dispatcher.Emit(EventUserJoined, UserData{Username: "user_name"}))
+		require.Equal(t, EventUserJoined, <-calls)
+
+		_, err := repo.AddWebhook(context.Background(), &repository.Webhook{URL: endpoint.URL, Events: []string{EventUserSignedUp}, CreatedBy: "admin_user"})
+		require.NoError(t, err)
+		dispatcher.Invalidate()
+
+		require.NoError(t, dispatcher.Emit(EventUserSignedUp, UserData{Username: "user_name"}))
+		require.Equal(t, EventUserSignedUp, <-calls)
+	})
+}
+
+// TestDispatcher_Close tests emitting events to a closed or full Dispatcher
+func TestDispatcher_Close(t *testing.T) {
+	block := make(chan struct{})
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer endpoint.Close()
+	defer close(block)
+
+	repo, _ := newTestWebhook(t, endpoint.URL, EventUserJoined)
+	config := testConfig()
+	config.QueueSize = 1
+	dispatcher := New(repo, config)
+
+	// the worker is blocked on the first event, the second one waits in the queue
+	require.NoError(t, dispatcher.Emit(EventUserJoined, UserData{}))
+	require.Eventually(t, func() bool {
+		return dispatcher.Emit(EventUserJoined, UserData{}) == nil
+	}, time.Second, time.Millisecond)
+	require.ErrorIs(t, dispatcher.Emit(EventUserJoined, UserData{}), ErrQueueFull)
+
+	// closing gives up on the blocked events once its context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, dispatcher.Close(ctx), context.DeadlineExceeded)
+	require.ErrorIs(t, dispatcher.Emit(EventUserJoined, UserData{}), ErrClosed)
+}
+
+// TestBackoff tests the waits between the attempts
+func TestBackoff(t *testing.T) {
+	dispatcher := &Dispatcher{config: Config{Backoff: time.Second, MaxBackoff: 5 * time.Second}}
+
+	require.Equal(t, time.Second, dispatcher.backoff(1))
+	require.Equal(t, 2*time.Second, dispatcher.backoff(2))
+	require.Equal(t, 4*time.Second, dispatcher.backoff(3))
+	require.Equal(t, 5*time.Second, dispatcher.backoff(4))
+	require.Equal(t, 5*time.Second, dispatcher.backoff(60))
+}
@@ -0,0 +1,94 @@
+// Package webhook delivers the events of the chat to the http endpoints registered by the admins.
+// events are posted as json, signed with the secret of the endpoint, and retried with exponential backoff
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// types of the events webhooks subscribe to
+const (
+	EventMessageCreated = "message.created"
+	EventMessageEdited  = "message.edited"
+	EventMessageDeleted = "message.deleted"
+	EventUserSignedUp   = "user.signed_up"
+	EventUserJoined     = "user.joined"
+)
+
+// EventTypes are all the types of the events webhooks subscribe to
+var EventTypes = []string{
+	EventMessageCreated,
+	EventMessageEdited,
+	EventMessageDeleted,
+	EventUserSignedUp,
+	EventUserJoined,
+}
+
+// headers of the requests delivering the events
+const (
+	HeaderID        = "X-Webhook-ID"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// signaturePrefix is the prefix of the signatures naming their algorithm
+const signaturePrefix = "sha256="
+
+// IsEventType reports whether the input string is the type of an event webhooks subscribe to
+func IsEventType(eventType string) bool {
+	for _, t := range EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is the json body of the requests delivering an event
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// MessageData is the data of the message events
+type MessageData struct {
+	ID        uint      `json:"id"`
+	Author    string    `json:"author"`
+	Room      string    `json:"room"`
+	Text      string    `json:"text"`
+	HTML      string    `json:"html"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserData is the data of the user events
+type UserData struct {
+	Username string `json:"username"`
+}
+
+// Sign returns the signature of the input body sent at the input unix timestamp: the hex encoded
+// HMAC-SHA256 of the timestamp and the body joined by a dot, keyed by the secret of the webhook
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether the input signature is the signature of the input body and timestamp, receivers
+// should also reject timestamps too far from their clock so the requests cannot be replayed
+func Verify(secret, timestamp, signature string, body []byte) bool {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body)))
+}